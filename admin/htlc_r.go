@@ -4,7 +4,6 @@ import (
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/config"
-	conn2tracker "github.com/omnilaboratory/obd/conn"
 	"github.com/omnilaboratory/obd/dao"
 	"github.com/omnilaboratory/obd/omnicore"
 	"github.com/omnilaboratory/obd/service"
@@ -42,8 +41,12 @@ func InterUserGetNextNode(dataTx interface{}, user *bean.User) (channelId string
 		if user.PeerId == latestCommitmentTx.PeerIdA {
 			msg.RecipientUserPeerId = latestCommitmentTx.PeerIdB
 		}
-		msg.RecipientNodePeerId = conn2tracker.GetUserP2pNodeId(msg.RecipientUserPeerId)
+		msg.RecipientNodePeerId, _ = service.GetUserP2pNodeId(msg.RecipientUserPeerId)
 		amount, _ = decimal.NewFromFloat(currNodeTx.HtlcAmountToPayee).Mul(decimal.NewFromFloat(1 + config.HtlcFeeRate*float64(totalStep-currStep-1))).Round(8).Float64()
+		// the routing packet of the onion only has the current and the next channels, the amount is in the onion
+		if currNodeTx.HtlcForwardAmount > 0 {
+			amount = currNodeTx.HtlcForwardAmount
+		}
 		if len(msg.RecipientNodePeerId) == 0 {
 			return "", 0, nil
 		}
@@ -73,7 +76,7 @@ func InterUserGetHtlcRFromLocalForPreNode(dataTx interface{}, user *bean.User) (
 			if user.PeerId == newNodeTx.PeerIdA {
 				msg.RecipientUserPeerId = newNodeTx.PeerIdB
 			}
-			msg.RecipientNodePeerId = conn2tracker.GetUserP2pNodeId(msg.RecipientUserPeerId)
+			msg.RecipientNodePeerId, _ = service.GetUserP2pNodeId(msg.RecipientUserPeerId)
 			if len(msg.RecipientNodePeerId) == 0 {
 				return "", "", nil
 			}
@@ -17,6 +17,7 @@ type User struct {
 	P2PLocalPeerId  string    `json:"p2p_local_peer_id"`
 	PeerId          string    `json:"peer_id"`
 	Mnemonic        string    `json:"mnemonic"`
+	WalletXpub      string    `json:"wallet_xpub"` // the xpub of m/44'/coinType', when login by signing the challenge
 	State           UserState `json:"state"`
 	IsAdmin         bool      `json:"is_admin"`
 	ChangeExtKey    *bip32.Key
@@ -28,12 +28,17 @@ type ChannelInfoRequest struct {
 	PeerIdB    string       `json:"peer_idb"`
 	AmountA    float64      `json:"amount_a"`
 	AmountB    float64      `json:"amount_b"`
+	// the htlc fee rate and the cltv delta of the announcing node when it forwards, the tracker weighs the routes by them
+	FeeRate       float64 `json:"fee_rate,omitempty"`
+	TimeLockDelta int     `json:"time_lock_delta,omitempty"`
 }
 
 //节点登录
 type ObdNodeLoginRequest struct {
 	NodeId     string `json:"node_id"`
 	P2PAddress string `json:"p2p_address"`
+	// the payers wrap the onions of the htlcs by it
+	OnionPubKey string `json:"onion_pub_key,omitempty"`
 }
 
 //节点的用户登录
@@ -47,3 +52,11 @@ type TrackerLockChannelRequest struct {
 	UserId    string `json:"user_id"`
 	ChannelId string `json:"channel_id"`
 }
+
+//用户peerId迁移
+type UserPeerIdMigrationRequest struct {
+	OldPeerId string `json:"old_peer_id"`
+	NewPeerId string `json:"new_peer_id"`
+	// the node id of the obd which created the user db, the tracker keeps the users under it
+	OldObdNodeId string `json:"old_obd_node_id"`
+}
@@ -0,0 +1,287 @@
+package enum
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrorCode the stable code of the failed reply, so that the clients need not parse the error msg.
+// the codes are never changed or reused, new codes are appended to their groups.
+type ErrorCode int
+
+const (
+	ErrorCode_none    ErrorCode = 0
+	ErrorCode_unknown ErrorCode = 1
+
+	ErrorCode_common_wrong                         ErrorCode = 101
+	ErrorCode_common_empty                         ErrorCode = 102
+	ErrorCode_common_savedBefore                   ErrorCode = 103
+	ErrorCode_common_invilidMsgHash                ErrorCode = 104
+	ErrorCode_common_notFound                      ErrorCode = 105
+	ErrorCode_common_failToSign                    ErrorCode = 106
+	ErrorCode_common_userNotOnline                 ErrorCode = 107
+	ErrorCode_common_userNotInTx                   ErrorCode = 108
+	ErrorCode_common_notExistProperty              ErrorCode = 109
+	ErrorCode_common_amountMustGreater             ErrorCode = 110
+	ErrorCode_common_failToParseInputsFromUnsendTx ErrorCode = 111
+	ErrorCode_common_wrongAddressOfRD              ErrorCode = 112
+	ErrorCode_common_errorObdPeerId                ErrorCode = 113
+	ErrorCode_common_newTxMsg                      ErrorCode = 114
+
+	ErrorCode_ws_errorJson       ErrorCode = 201
+	ErrorCode_ws_wrongJson       ErrorCode = 202
+	ErrorCode_ws_longRequestId   ErrorCode = 203
+	ErrorCode_ws_notExistMsgType ErrorCode = 204
+	ErrorCode_ws_noModule        ErrorCode = 205
+	ErrorCode_ws_shuttingDown    ErrorCode = 206
+
+	ErrorCode_user_nilUser             ErrorCode = 301
+	ErrorCode_user_needLogin           ErrorCode = 302
+	ErrorCode_user_notExistOrOnline    ErrorCode = 303
+	ErrorCode_user_noLoginChallenge    ErrorCode = 304
+	ErrorCode_user_wrongLoginSignature ErrorCode = 305
+	ErrorCode_user_privateExtKey       ErrorCode = 306
+	ErrorCode_user_wrongXpubPath       ErrorCode = 307
+	ErrorCode_user_outboxFull          ErrorCode = 308
+	ErrorCode_user_wrongSession        ErrorCode = 309
+	ErrorCode_user_senderOutboxFull    ErrorCode = 310
+	ErrorCode_user_loginAtOtherNode    ErrorCode = 311
+
+	ErrorCode_credential_required  ErrorCode = 401
+	ErrorCode_credential_wrong     ErrorCode = 402
+	ErrorCode_credential_revoked   ErrorCode = 403
+	ErrorCode_credential_expired   ErrorCode = 404
+	ErrorCode_credential_noScope   ErrorCode = 405
+	ErrorCode_credential_wrongIp   ErrorCode = 406
+	ErrorCode_credential_maxAmount ErrorCode = 407
+	ErrorCode_credential_noAmount  ErrorCode = 408
+
+	ErrorCode_channel_notFoundChannelInCreate               ErrorCode = 501
+	ErrorCode_channel_notThePeerIdB                         ErrorCode = 502
+	ErrorCode_channel_changePubkeyForChannel                ErrorCode = 503
+	ErrorCode_channel_wrongLatestCommitmentTxState          ErrorCode = 504
+	ErrorCode_channel_notFoundLatestCommitmentTx            ErrorCode = 505
+	ErrorCode_channel_LatestCommitmentTxNotInReadySendState ErrorCode = 506
+	ErrorCode_channel_cannotDelChannel                      ErrorCode = 507
+
+	ErrorCode_funding_notFoundChannelByTempId         ErrorCode = 601
+	ErrorCode_funding_notFoundChannelByChannelId      ErrorCode = 602
+	ErrorCode_funding_notFundBtcState                 ErrorCode = 603
+	ErrorCode_funding_notFundAssetState               ErrorCode = 604
+	ErrorCode_funding_failDecodeRawTransaction        ErrorCode = 605
+	ErrorCode_funding_needChangeFundTx                ErrorCode = 606
+	ErrorCode_funding_notEnoughBtcFundingTime         ErrorCode = 607
+	ErrorCode_funding_enoughBtcFundingTime            ErrorCode = 608
+	ErrorCode_funding_btcAmountMustGreater            ErrorCode = 609
+	ErrorCode_funding_btcTxBeenSend                   ErrorCode = 610
+	ErrorCode_funding_fundTxIsRunning                 ErrorCode = 611
+	ErrorCode_funding_notFoundFundBtcTx               ErrorCode = 612
+	ErrorCode_funding_notFoundFundAssetTx             ErrorCode = 613
+	ErrorCode_funding_noVin                           ErrorCode = 614
+	ErrorCode_funding_notFoundVout                    ErrorCode = 615
+	ErrorCode_funding_wrongBtcHexVin                  ErrorCode = 616
+	ErrorCode_funding_wrongFunderAddressFromBtcHex    ErrorCode = 617
+	ErrorCode_funding_wrongChannelAddressFromBtcHex   ErrorCode = 618
+	ErrorCode_funding_wrongFunderAddressFromAssetHex  ErrorCode = 619
+	ErrorCode_funding_wrongChannelAddressFromAssetHex ErrorCode = 620
+
+	ErrorCode_rsmc_broadcastedChannel     ErrorCode = 701
+	ErrorCode_rsmc_notTargetUser          ErrorCode = 702
+	ErrorCode_rsmc_errorCommitmentTxType  ErrorCode = 703
+	ErrorCode_rsmc_errorCommitmentTxState ErrorCode = 704
+	ErrorCode_rsmc_notEnoughBalance       ErrorCode = 705
+	ErrorCode_rsmc_wrongPrivateKeyForLast ErrorCode = 706
+	ErrorCode_rsmc_wrongChannelPrivateKey ErrorCode = 707
+	ErrorCode_rsmc_notSameValueWhenCreate ErrorCode = 708
+	ErrorCode_rsmc_notPairPrivAndPubKey   ErrorCode = 709
+	ErrorCode_rsmc_notDoItAgain           ErrorCode = 710
+	ErrorCode_rsmc_failToCreate           ErrorCode = 711
+	ErrorCode_rsmc_failToGetInput         ErrorCode = 712
+
+	ErrorCode_htlc_expiryTimeAfterNow         ErrorCode = 801
+	ErrorCode_htlc_noPrivatePath              ErrorCode = 802
+	ErrorCode_htlc_noChanneFromRountingPacket ErrorCode = 803
+	ErrorCode_htlc_notTheHltcSender           ErrorCode = 804
+	ErrorCode_htlc_wrongRForH                 ErrorCode = 805
+	ErrorCode_htlc_failToGetBlockHeight       ErrorCode = 806
+	ErrorCode_htlc_timeOut                    ErrorCode = 807
+	ErrorCode_htlc_wrongChannelState          ErrorCode = 808
+	ErrorCode_htlc_partsNotArrived            ErrorCode = 809
+	ErrorCode_htlc_partsTimeOut               ErrorCode = 810
+	ErrorCode_htlc_paymentSucceeded           ErrorCode = 811
+	ErrorCode_htlc_feeOverLimit               ErrorCode = 812
+	ErrorCode_htlc_attemptsUsedUp             ErrorCode = 813
+	ErrorCode_htlc_retryTimeOut               ErrorCode = 814
+	ErrorCode_htlc_wrongOnion                 ErrorCode = 815
+	ErrorCode_htlc_unknownNextPeer            ErrorCode = 816
+	ErrorCode_htlc_insufficientBalance        ErrorCode = 817
+	ErrorCode_htlc_expiryTooSoon              ErrorCode = 818
+	ErrorCode_htlc_invoiceExpired             ErrorCode = 819
+	ErrorCode_htlc_unknownPaymentHash         ErrorCode = 820
+	ErrorCode_htlc_forwardFailed              ErrorCode = 821
+	ErrorCode_htlc_notFoundHtlcToFail         ErrorCode = 822
+	ErrorCode_htlc_failedByNextHops           ErrorCode = 823
+	ErrorCode_htlc_noTrackerForPath           ErrorCode = 824
+	ErrorCode_htlc_noHopKeys                  ErrorCode = 825
+	ErrorCode_htlc_wrongAmountToPayee         ErrorCode = 826
+
+	ErrorCode_event_wrongType ErrorCode = 901
+)
+
+// the error tips of the codes
+var errorCodeTips = map[ErrorCode]string{
+	ErrorCode_common_wrong:                                  Tips_common_wrong,
+	ErrorCode_common_empty:                                  Tips_common_empty,
+	ErrorCode_common_savedBefore:                            Tips_common_savedBefore,
+	ErrorCode_common_invilidMsgHash:                         Tips_common_invilidMsgHash,
+	ErrorCode_common_notFound:                               Tips_common_notFound,
+	ErrorCode_common_failToSign:                             Tips_common_failToSign,
+	ErrorCode_common_userNotOnline:                          Tips_common_userNotOnline,
+	ErrorCode_common_userNotInTx:                            Tips_common_userNotInTx,
+	ErrorCode_common_notExistProperty:                       Tips_common_notExistProperty,
+	ErrorCode_common_amountMustGreater:                      Tips_common_amountMustGreater,
+	ErrorCode_common_failToParseInputsFromUnsendTx:          Tips_common_failToParseInputsFromUnsendTx,
+	ErrorCode_common_wrongAddressOfRD:                       Tips_common_wrongAddressOfRD,
+	ErrorCode_common_errorObdPeerId:                         Tips_common_errorObdPeerId,
+	ErrorCode_common_newTxMsg:                               Tips_common_newTxMsg,
+	ErrorCode_ws_errorJson:                                  Tips_ws_errorJson,
+	ErrorCode_ws_wrongJson:                                  Tips_ws_wrongJson,
+	ErrorCode_ws_longRequestId:                              Tips_ws_longRequestId,
+	ErrorCode_ws_notExistMsgType:                            Tips_ws_notExistMsgType,
+	ErrorCode_ws_noModule:                                   Tips_ws_noModule,
+	ErrorCode_ws_shuttingDown:                               Tips_ws_shuttingDown,
+	ErrorCode_user_nilUser:                                  Tips_user_nilUser,
+	ErrorCode_user_needLogin:                                Tips_user_needLogin,
+	ErrorCode_user_notExistOrOnline:                         Tips_user_notExistOrOnline,
+	ErrorCode_user_noLoginChallenge:                         Tips_user_noLoginChallenge,
+	ErrorCode_user_wrongLoginSignature:                      Tips_user_wrongLoginSignature,
+	ErrorCode_user_privateExtKey:                            Tips_user_privateExtKey,
+	ErrorCode_user_wrongXpubPath:                            Tips_user_wrongXpubPath,
+	ErrorCode_user_outboxFull:                               Tips_user_outboxFull,
+	ErrorCode_user_wrongSession:                             Tips_user_wrongSession,
+	ErrorCode_user_senderOutboxFull:                         Tips_user_senderOutboxFull,
+	ErrorCode_user_loginAtOtherNode:                         Tips_user_loginAtOtherNode,
+	ErrorCode_credential_required:                           Tips_credential_required,
+	ErrorCode_credential_wrong:                              Tips_credential_wrong,
+	ErrorCode_credential_revoked:                            Tips_credential_revoked,
+	ErrorCode_credential_expired:                            Tips_credential_expired,
+	ErrorCode_credential_noScope:                            Tips_credential_noScope,
+	ErrorCode_credential_wrongIp:                            Tips_credential_wrongIp,
+	ErrorCode_credential_maxAmount:                          Tips_credential_maxAmount,
+	ErrorCode_credential_noAmount:                           Tips_credential_noAmount,
+	ErrorCode_channel_notFoundChannelInCreate:               Tips_channel_notFoundChannelInCreate,
+	ErrorCode_channel_notThePeerIdB:                         Tips_channel_notThePeerIdB,
+	ErrorCode_channel_changePubkeyForChannel:                Tips_channel_changePubkeyForChannel,
+	ErrorCode_channel_wrongLatestCommitmentTxState:          Tips_channel_wrongLatestCommitmentTxState,
+	ErrorCode_channel_notFoundLatestCommitmentTx:            Tips_channel_notFoundLatestCommitmentTx,
+	ErrorCode_channel_LatestCommitmentTxNotInReadySendState: Tips_channel_LatestCommitmentTxNotInReadySendState,
+	ErrorCode_channel_cannotDelChannel:                      Tips_channel_cannotDelChannel,
+	ErrorCode_funding_notFoundChannelByTempId:               Tips_funding_notFoundChannelByTempId,
+	ErrorCode_funding_notFoundChannelByChannelId:            Tips_funding_notFoundChannelByChannelId,
+	ErrorCode_funding_notFundBtcState:                       Tips_funding_notFundBtcState,
+	ErrorCode_funding_notFundAssetState:                     Tips_funding_notFundAssetState,
+	ErrorCode_funding_failDecodeRawTransaction:              Tips_funding_failDecodeRawTransaction,
+	ErrorCode_funding_needChangeFundTx:                      Tips_funding_needChangeFundTx,
+	ErrorCode_funding_notEnoughBtcFundingTime:               Tips_funding_notEnoughBtcFundingTime,
+	ErrorCode_funding_enoughBtcFundingTime:                  Tips_funding_enoughBtcFundingTime,
+	ErrorCode_funding_btcAmountMustGreater:                  Tips_funding_btcAmountMustGreater,
+	ErrorCode_funding_btcTxBeenSend:                         Tips_funding_btcTxBeenSend,
+	ErrorCode_funding_fundTxIsRunning:                       Tips_funding_fundTxIsRunning,
+	ErrorCode_funding_notFoundFundBtcTx:                     Tips_funding_notFoundFundBtcTx,
+	ErrorCode_funding_notFoundFundAssetTx:                   Tips_funding_notFoundFundAssetTx,
+	ErrorCode_funding_noVin:                                 Tips_funding_noVin,
+	ErrorCode_funding_notFoundVout:                          Tips_funding_notFoundVout,
+	ErrorCode_funding_wrongBtcHexVin:                        Tips_funding_wrongBtcHexVin,
+	ErrorCode_funding_wrongFunderAddressFromBtcHex:          Tips_funding_wrongFunderAddressFromBtcHex,
+	ErrorCode_funding_wrongChannelAddressFromBtcHex:         Tips_funding_wrongChannelAddressFromBtcHex,
+	ErrorCode_funding_wrongFunderAddressFromAssetHex:        Tips_funding_wrongFunderAddressFromAssetHex,
+	ErrorCode_funding_wrongChannelAddressFromAssetHex:       Tips_funding_wrongChannelAddressFromAssetHex,
+	ErrorCode_rsmc_broadcastedChannel:                       Tips_rsmc_broadcastedChannel,
+	ErrorCode_rsmc_notTargetUser:                            Tips_rsmc_notTargetUser,
+	ErrorCode_rsmc_errorCommitmentTxType:                    Tips_rsmc_errorCommitmentTxType,
+	ErrorCode_rsmc_errorCommitmentTxState:                   Tips_rsmc_errorCommitmentTxState,
+	ErrorCode_rsmc_notEnoughBalance:                         Tips_rsmc_notEnoughBalance,
+	ErrorCode_rsmc_wrongPrivateKeyForLast:                   Tips_rsmc_wrongPrivateKeyForLast,
+	ErrorCode_rsmc_wrongChannelPrivateKey:                   Tips_rsmc_wrongChannelPrivateKey,
+	ErrorCode_rsmc_notSameValueWhenCreate:                   Tips_rsmc_notSameValueWhenCreate,
+	ErrorCode_rsmc_notPairPrivAndPubKey:                     Tips_rsmc_notPairPrivAndPubKey,
+	ErrorCode_rsmc_notDoItAgain:                             Tips_rsmc_notDoItAgain,
+	ErrorCode_rsmc_failToCreate:                             Tips_rsmc_failToCreate,
+	ErrorCode_rsmc_failToGetInput:                           Tips_rsmc_failToGetInput,
+	ErrorCode_htlc_expiryTimeAfterNow:                       Tips_htlc_expiryTimeAfterNow,
+	ErrorCode_htlc_noPrivatePath:                            Tips_htlc_noPrivatePath,
+	ErrorCode_htlc_noChanneFromRountingPacket:               Tips_htlc_noChanneFromRountingPacket,
+	ErrorCode_htlc_notTheHltcSender:                         Tips_htlc_notTheHltcSender,
+	ErrorCode_htlc_wrongRForH:                               Tips_htlc_wrongRForH,
+	ErrorCode_htlc_failToGetBlockHeight:                     Tips_htlc_failToGetBlockHeight,
+	ErrorCode_htlc_timeOut:                                  Tips_htlc_timeOut,
+	ErrorCode_htlc_wrongChannelState:                        Tips_htlc_wrongChannelState,
+	ErrorCode_htlc_partsNotArrived:                          Tips_htlc_partsNotArrived,
+	ErrorCode_htlc_partsTimeOut:                             Tips_htlc_partsTimeOut,
+	ErrorCode_htlc_paymentSucceeded:                         Tips_htlc_paymentSucceeded,
+	ErrorCode_htlc_feeOverLimit:                             Tips_htlc_feeOverLimit,
+	ErrorCode_htlc_attemptsUsedUp:                           Tips_htlc_attemptsUsedUp,
+	ErrorCode_htlc_retryTimeOut:                             Tips_htlc_retryTimeOut,
+	ErrorCode_htlc_wrongOnion:                               Tips_htlc_wrongOnion,
+	ErrorCode_htlc_unknownNextPeer:                          Tips_htlc_unknownNextPeer,
+	ErrorCode_htlc_insufficientBalance:                      Tips_htlc_insufficientBalance,
+	ErrorCode_htlc_expiryTooSoon:                            Tips_htlc_expiryTooSoon,
+	ErrorCode_htlc_invoiceExpired:                           Tips_htlc_invoiceExpired,
+	ErrorCode_htlc_unknownPaymentHash:                       Tips_htlc_unknownPaymentHash,
+	ErrorCode_htlc_forwardFailed:                            Tips_htlc_forwardFailed,
+	ErrorCode_htlc_notFoundHtlcToFail:                       Tips_htlc_notFoundHtlcToFail,
+	ErrorCode_htlc_failedByNextHops:                         Tips_htlc_failedByNextHops,
+	ErrorCode_htlc_noTrackerForPath:                         Tips_htlc_noTrackerForPath,
+	ErrorCode_htlc_noHopKeys:                                Tips_htlc_noHopKeys,
+	ErrorCode_htlc_wrongAmountToPayee:                       Tips_htlc_wrongAmountToPayee,
+	ErrorCode_event_wrongType:                               Tips_event_wrongType,
+}
+
+// the format verbs in the tips, such as %s %d %.8f
+var tipsVerbRegexp = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+
+// CodeError the error with its code, the code is kept when the error is wrapped by fmt.Errorf with %w
+type CodeError struct {
+	Code ErrorCode
+	Msg  string
+}
+
+func (err *CodeError) Error() string {
+	return err.Msg
+}
+
+// NewError the error of the tips of the code. The tips are formatted by args if they have format verbs,
+// otherwise args are appended to them.
+func NewError(code ErrorCode, args ...interface{}) error {
+	tips := errorCodeTips[code]
+	if tipsVerbRegexp.MatchString(tips) {
+		return &CodeError{Code: code, Msg: fmt.Sprintf(tips, args...)}
+	}
+	return &CodeError{Code: code, Msg: tips + fmt.Sprint(args...)}
+}
+
+// WithCode the error of the msg, which is not made of the tips of the code, such as the error replied by another node
+func WithCode(code ErrorCode, msg string) error {
+	if code == ErrorCode_none {
+		code = ErrorCode_unknown
+	}
+	return &CodeError{Code: code, Msg: msg}
+}
+
+// ErrorCodeOf the code of the error, ErrorCode_unknown if it has no code
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ErrorCode_none
+	}
+	var codeError *CodeError
+	if errors.As(err, &codeError) {
+		return codeError.Code
+	}
+	return ErrorCode_unknown
+}
+
+// GetErrorTips the error tips of the code
+func GetErrorTips(code ErrorCode) string {
+	return errorCodeTips[code]
+}
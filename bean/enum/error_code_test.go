@@ -0,0 +1,44 @@
+package enum
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewError(t *testing.T) {
+	tests := []struct {
+		err  error
+		msg  string
+		code ErrorCode
+	}{
+		{NewError(ErrorCode_user_needLogin), Tips_user_needLogin, ErrorCode_user_needLogin},
+		{NewError(ErrorCode_common_empty, " recipient_user_peer_id"), Tips_common_empty + " recipient_user_peer_id", ErrorCode_common_empty},
+		{NewError(ErrorCode_user_notExistOrOnline, "abc"), fmt.Sprintf(Tips_user_notExistOrOnline, "abc"), ErrorCode_user_notExistOrOnline},
+		{NewError(ErrorCode_htlc_wrongChannelState, 1, 2), fmt.Sprintf(Tips_htlc_wrongChannelState, 1, 2), ErrorCode_htlc_wrongChannelState},
+		{fmt.Errorf("fail to pay: %w", NewError(ErrorCode_credential_noScope, Scope_Payment)), "fail to pay: " + Tips_credential_noScope + Scope_Payment, ErrorCode_credential_noScope},
+		{WithCode(ErrorCode_none, "replied by another node"), "replied by another node", ErrorCode_unknown},
+		{errors.New(Tips_user_needLogin), Tips_user_needLogin, ErrorCode_unknown},
+	}
+	for _, test := range tests {
+		if test.err.Error() != test.msg {
+			t.Errorf("the msg is %q, want %q", test.err.Error(), test.msg)
+		}
+		if code := ErrorCodeOf(test.err); code != test.code {
+			t.Errorf("the code of %q is %d, want %d", test.msg, code, test.code)
+		}
+	}
+	if code := ErrorCodeOf(nil); code != ErrorCode_none {
+		t.Errorf("the code of nil is %d, want none", code)
+	}
+}
+
+func TestErrorCodeUnique(t *testing.T) {
+	tips := make(map[string]ErrorCode)
+	for code, item := range errorCodeTips {
+		if other, ok := tips[item]; ok {
+			t.Errorf("codes %d and %d have the same tips", code, other)
+		}
+		tips[item] = code
+	}
+}
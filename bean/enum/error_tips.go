@@ -16,8 +16,33 @@ const (
 	Tips_common_errorObdPeerId                = "There is no connection with obd node %s, please verify the node address and try to connect again, or call protocol message -102003"
 	Tips_common_newTxMsg                      = "There is one pending transaction in the channel, please finish it before proceeding another"
 
-	Tips_user_nilUser          = "user is null, please login first."
-	Tips_user_notExistOrOnline = "%s does not exist, or is offline."
+	Tips_ws_errorJson       = "error json format"
+	Tips_ws_wrongJson       = "wrong json input"
+	Tips_ws_longRequestId   = "The request_id must not be longer than %d."
+	Tips_ws_notExistMsgType = "not exist the msg type"
+	Tips_ws_noModule        = "the msg type has no module"
+	Tips_ws_shuttingDown    = "obd is shutting down"
+
+	Tips_user_nilUser             = "user is null, please login first."
+	Tips_user_needLogin           = "please login"
+	Tips_user_notExistOrOnline    = "%s does not exist, or is offline."
+	Tips_user_noLoginChallenge    = "Please get the login challenge first, or it is expired."
+	Tips_user_wrongLoginSignature = "The signature of the login challenge is wrong."
+	Tips_user_privateExtKey       = "Please send the xpub, never send the xprv to obd."
+	Tips_user_wrongXpubPath       = "The xpub must be the one of m/44'/coinType'."
+	Tips_user_outboxFull          = "Too many msgs are waiting for %s to login."
+	Tips_user_wrongSession        = "The session is expired, or does not exist, please login again."
+	Tips_user_senderOutboxFull    = "Too many msgs from %s are waiting for the offline users."
+	Tips_user_loginAtOtherNode    = "The user has login at other node."
+
+	Tips_credential_required  = "Api credential is required."
+	Tips_credential_wrong     = "Wrong api credential."
+	Tips_credential_revoked   = "The api credential does not exist or is revoked."
+	Tips_credential_expired   = "The api credential is expired."
+	Tips_credential_noScope   = "The api credential has no scope: "
+	Tips_credential_wrongIp   = "The api credential is not allowed from ip: "
+	Tips_credential_maxAmount = "The amount exceeds the max amount of the api credential: "
+	Tips_credential_noAmount  = "The amount of the payment can not be measured for the max amount of the api credential."
 
 	Tips_channel_notFoundChannelInCreate               = "Channel msg: can not find the channel currently being created via temporary channel id: "
 	Tips_channel_notThePeerIdB                         = "Channel msg: you are not the channel acceptor."
@@ -69,4 +94,24 @@ const (
 	Tips_htlc_failToGetBlockHeight       = "Failed to get heigh of blocks, please try again later."
 	Tips_htlc_timeOut                    = "The transaction expired. Don't send R again."
 	Tips_htlc_wrongChannelState          = "This channel is processing an HTLC (channel state: %d) now, and is not available for other requests, which need the channel state to be: %d"
+	Tips_htlc_partsNotArrived            = "Only %s of the amount %s has arrived, R is released when all the parts of the payment arrive."
+	Tips_htlc_partsTimeOut               = "The parts of the payment did not arrive in time, they are cancelled."
+	Tips_htlc_paymentSucceeded           = "The payment of this H has succeeded, do not pay it again."
+	Tips_htlc_feeOverLimit               = "The fee %s of the path is over the limit %s of the payment."
+	Tips_htlc_attemptsUsedUp             = "The payment failed after %d attempts."
+	Tips_htlc_retryTimeOut               = "The payment is not retried after its timeout."
+	Tips_htlc_wrongOnion                 = "The onion of the htlc is wrong: %s."
+	Tips_htlc_unknownNextPeer            = "The next peer %s of the htlc is unknown, or is offline."
+	Tips_htlc_insufficientBalance        = "Not enough balance to forward the htlc, the balance is %s."
+	Tips_htlc_expiryTooSoon              = "The cltv expiry %d of the next htlc is too soon."
+	Tips_htlc_invoiceExpired             = "The invoice of the htlc is expired."
+	Tips_htlc_unknownPaymentHash         = "The payee does not know the H of the htlc."
+	Tips_htlc_forwardFailed              = "Fail to forward the htlc: %s."
+	Tips_htlc_notFoundHtlcToFail         = "Can not find the htlc of the channel %s to fail."
+	Tips_htlc_failedByNextHops           = "The htlc failed on the next hops, the reason is encrypted to the payer."
+	Tips_htlc_noTrackerForPath           = "No tracker can answer the path request of the payment."
+	Tips_htlc_noHopKeys                  = "The onion keys of the hops of the path are unknown, the path is not sent in plain text."
+	Tips_htlc_wrongAmountToPayee         = "The amount to payee %s is more than the amount %s of the htlc."
+
+	Tips_event_wrongType = "Unknown event type: "
 )
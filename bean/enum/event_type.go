@@ -0,0 +1,36 @@
+package enum
+
+// EventType the events which are pushed to the subscribers by -102018
+type EventType string
+
+const (
+	EventType_ChannelState       EventType = "channel_state"
+	EventType_CommitmentTxSigned EventType = "commitment_tx_signed"
+	EventType_HtlcAdded          EventType = "htlc_added"
+	EventType_HtlcSettled        EventType = "htlc_settled"
+	EventType_HtlcFailed         EventType = "htlc_failed"
+	EventType_HtlcCloseRequired  EventType = "htlc_close_required"
+	EventType_InvoicePaid        EventType = "invoice_paid"
+	EventType_BreachRemedySent   EventType = "breach_remedy_sent"
+	EventType_PaymentSucceeded   EventType = "payment_succeeded"
+	EventType_PaymentFailed      EventType = "payment_failed"
+	EventType_PaymentRetried     EventType = "payment_retried"
+)
+
+func CheckEventTypeExist(eventType EventType) bool {
+	switch eventType {
+	case EventType_ChannelState,
+		EventType_CommitmentTxSigned,
+		EventType_HtlcAdded,
+		EventType_HtlcSettled,
+		EventType_HtlcFailed,
+		EventType_HtlcCloseRequired,
+		EventType_InvoicePaid,
+		EventType_BreachRemedySent,
+		EventType_PaymentSucceeded,
+		EventType_PaymentFailed,
+		EventType_PaymentRetried:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package enum
+
+import (
+	"sync"
+)
+
+// MsgTypeInfo how a msg type from the websocket clients is checked before it is handled
+type MsgTypeInfo struct {
+	// the client must login before sending the msg
+	RequireLogin bool
+	// the msg must have recipient_user_peer_id and recipient_node_peer_id
+	RequireRecipient bool
+	// connect the obd node of the recipient, if it is not connected
+	ConnectRemoteNode bool
+	// the scope which the api credential must have to send the msg
+	Scope string
+}
+
+var msgTypeRegistry = struct {
+	sync.RWMutex
+	infos map[MsgType]MsgTypeInfo
+}{infos: make(map[MsgType]MsgTypeInfo)}
+
+// RegisterMsgType add the msg type to the registry, or replace its info
+func RegisterMsgType(msgType MsgType, info MsgTypeInfo) {
+	msgTypeRegistry.Lock()
+	defer msgTypeRegistry.Unlock()
+	msgTypeRegistry.infos[msgType] = info
+}
+
+func GetMsgTypeInfo(msgType MsgType) (info MsgTypeInfo, ok bool) {
+	msgTypeRegistry.RLock()
+	defer msgTypeRegistry.RUnlock()
+	info, ok = msgTypeRegistry.infos[msgType]
+	return info, ok
+}
+
+func CheckExist(msgType MsgType) bool {
+	if msgType == MsgType_Error_0 {
+		return true
+	}
+	_, ok := GetMsgTypeInfo(msgType)
+	return ok
+}
+
+// GetMsgTypeScope the scope which the credential must have to send the msg,
+// the msg types not registered require the admin scope
+func GetMsgTypeScope(msgType MsgType) string {
+	info, ok := GetMsgTypeInfo(msgType)
+	if ok == false {
+		return Scope_Admin
+	}
+	return info.Scope
+}
@@ -0,0 +1,18 @@
+package enum
+
+// the scopes of api credentials, a credential with the admin scope can send all messages
+const (
+	Scope_Any     = ""
+	Scope_Read    = "read"
+	Scope_Invoice = "invoice"
+	Scope_Payment = "payment"
+	Scope_Admin   = "admin"
+)
+
+func CheckScopeExist(scope string) bool {
+	switch scope {
+	case Scope_Read, Scope_Invoice, Scope_Payment, Scope_Admin:
+		return true
+	}
+	return false
+}
@@ -21,6 +21,7 @@ const (
 	MsgType_Tracker_GetHtlcPath_351       MsgType = 351
 	MsgType_Tracker_UpdateHtlcTxState_352 MsgType = 352
 	MsgType_Tracker_UpdateUserInfo_353    MsgType = 353
+	MsgType_Tracker_UpdateUserPeerId_354  MsgType = 354
 )
 
 // Transaction related messages, login is required: [-100000,-102000]
@@ -31,17 +32,26 @@ const (
 
 	// region
 	// Common messages, login is not required [-102000,-103000]
-	MsgType_UserLogin_2001             MsgType = -102001
-	MsgType_UserLogout_2002            MsgType = -102002
-	MsgType_p2p_ConnectPeer_2003       MsgType = -102003
-	MsgType_GetMnemonic_2004           MsgType = -102004
-	MsgType_GetObdNodeInfo_2005        MsgType = -102005
-	MsgType_GetMiniBtcFundAmount_2006  MsgType = -102006
-	MsgType_HeartBeat_2007             MsgType = -102007
-	MsgType_User_UpdateAdminToken_2008 MsgType = -102008
-	MsgType_User_GetInfo_2009          MsgType = -102009
-	MsgType_p2p_DisconnectPeer_2010    MsgType = -102010
-	MsgType_User_End_2099              MsgType = -102099
+	MsgType_UserLogin_2001              MsgType = -102001
+	MsgType_UserLogout_2002             MsgType = -102002
+	MsgType_p2p_ConnectPeer_2003        MsgType = -102003
+	MsgType_GetMnemonic_2004            MsgType = -102004
+	MsgType_GetObdNodeInfo_2005         MsgType = -102005
+	MsgType_GetMiniBtcFundAmount_2006   MsgType = -102006
+	MsgType_HeartBeat_2007              MsgType = -102007
+	MsgType_User_UpdateAdminToken_2008  MsgType = -102008
+	MsgType_User_GetInfo_2009           MsgType = -102009
+	MsgType_p2p_DisconnectPeer_2010     MsgType = -102010
+	MsgType_UserLoginChallenge_2011     MsgType = -102011
+	MsgType_UserLoginWithSignature_2012 MsgType = -102012
+	MsgType_Credential_Bake_2013        MsgType = -102013
+	MsgType_Credential_Revoke_2014      MsgType = -102014
+	MsgType_Credential_List_2015        MsgType = -102015
+	MsgType_Event_Subscribe_2016        MsgType = -102016
+	MsgType_Event_Unsubscribe_2017      MsgType = -102017
+	MsgType_Event_Push_2018             MsgType = -102018
+	MsgType_Outbox_Status_2019          MsgType = -102019
+	MsgType_User_End_2099               MsgType = -102099
 
 	MsgType_Core_GetNewAddress_2101                    MsgType = -102101
 	MsgType_Core_GetMiningInfo_2102                    MsgType = -102102
@@ -117,6 +127,8 @@ const (
 
 	MsgType_Htlc_GetLatestHT1aOrHE1b_3250             MsgType = -103250
 	MsgType_Htlc_GetHT1aOrHE1bBySomeCommitmentId_3251 MsgType = -103251
+	MsgType_Htlc_GetPayment_3252                      MsgType = -103252
+	MsgType_Htlc_ListPayments_3253                    MsgType = -103253
 	//endregion
 
 	// region
@@ -204,6 +216,10 @@ const (
 	MsgType_HTLC_SendHerdHex_46            MsgType = -46
 	MsgType_HTLC_RecvSignVerifyR_46        MsgType = -110046
 
+	MsgType_HTLC_SendFailHtlc_47 MsgType = -100047
+	MsgType_HTLC_FailHtlc_47     MsgType = -47
+	MsgType_HTLC_RecvFailHtlc_47 MsgType = -110047
+
 	MsgType_HTLC_Close_SendRequestCloseCurrTx_49       MsgType = -100049
 	MsgType_HTLC_Close_ClientSign_Alice_C4a_110        MsgType = -100110
 	MsgType_HTLC_Close_RequestCloseCurrTx_49           MsgType = -49
@@ -227,229 +243,3 @@ const (
 	MsgType_Atomic_RecvSwapAccept_81 MsgType = -110081
 	//endregion
 )
-
-func CheckExist(msgType MsgType) bool {
-	switch msgType {
-	case MsgType_Error_0:
-		return true
-	case MsgType_UserLogin_2001:
-		return true
-	case MsgType_UserLogout_2002:
-		return true
-	case MsgType_p2p_ConnectPeer_2003:
-		return true
-	case MsgType_p2p_DisconnectPeer_2010:
-		return true
-	case MsgType_GetMnemonic_2004:
-		return true
-	case MsgType_GetObdNodeInfo_2005:
-		return true
-	case MsgType_GetMiniBtcFundAmount_2006:
-		return true
-	case MsgType_HeartBeat_2007:
-		return true
-	case MsgType_User_UpdateAdminToken_2008:
-		return true
-	case MsgType_User_GetInfo_2009:
-		return true
-	case MsgType_Core_GetNewAddress_2101:
-		return true
-	case MsgType_Core_GetMiningInfo_2102:
-		return true
-	case MsgType_Core_GetNetworkInfo_2103:
-		return true
-	case MsgType_Core_SignMessageWithPrivKey_2104:
-		return true
-	case MsgType_Core_VerifyMessage_2105:
-		return true
-	case MsgType_Core_DumpPrivKey_2106:
-		return true
-	case MsgType_Core_ListUnspent_2107:
-		return true
-	case MsgType_Core_BalanceByAddress_2108:
-		return true
-	case MsgType_Core_FundingBTC_2109:
-		return true
-	case MsgType_Core_BtcCreateMultiSig_2110:
-		return true
-	case MsgType_Core_Btc_ImportPrivKey_2111:
-		return true
-	case MsgType_Core_Omni_GetBalance_2112:
-		return true
-	case MsgType_Core_Omni_CreateNewTokenFixed_2113:
-		return true
-	case MsgType_Core_Omni_CreateNewTokenManaged_2114:
-		return true
-	case MsgType_Core_Omni_GrantNewUnitsOfManagedToken_2115:
-		return true
-	case MsgType_Core_Omni_RevokeUnitsOfManagedToken_2116:
-		return true
-	case MsgType_Core_Omni_ListProperties_2117:
-		return true
-	case MsgType_Core_Omni_GetTransaction_2118:
-		return true
-	case MsgType_Core_Omni_GetProperty_2119:
-		return true
-	case MsgType_Core_Omni_FundingAsset_2120:
-		return true
-	case MsgType_Core_Omni_Send_2121:
-		return true
-	case MsgType_Core_GetTransactionByTxid_2122:
-		return true
-	case MsgType_Core_SignRawTransaction_2123:
-		return true
-	case MsgType_Mnemonic_CreateAddress_3000:
-		return true
-	case MsgType_Mnemonic_GetAddressByIndex_3001:
-		return true
-	case MsgType_SendChannelOpen_32:
-		return true
-	case MsgType_ChannelOpen_AllItem_3150:
-		return true
-	case MsgType_ChannelOpen_ItemByTempId_3151:
-		return true
-	case MsgType_ChannelOpen_Count_3152:
-		return true
-	case MsgType_ChannelOpen_DelItemByTempId_3153:
-		return true
-	case MsgType_GetChannelInfoByChannelId_3154:
-		return true
-	case MsgType_GetChannelInfoByDbId_3155:
-		return true
-	case MsgType_CheckChannelAddessExist_3156:
-		return true
-	case MsgType_SendChannelAccept_33:
-		return true
-	case MsgType_Funding_134:
-		return true
-	case MsgType_FundingCreate_SendAssetFundingCreated_34:
-		return true
-	case MsgType_ClientSign_AssetFunding_AliceSignC1a_1034:
-		return true
-	case MsgType_ClientSign_AssetFunding_AliceSignRD_1134:
-		return true
-	case MsgType_FundingCreate_Asset_AllItem_3100:
-		return true
-	case MsgType_FundingCreate_Asset_ItemById_3101:
-		return true
-	case MsgType_FundingCreate_Asset_ItemByChannelId_3102:
-		return true
-	case MsgType_FundingCreate_Asset_Count_3103:
-		return true
-	case MsgType_FundingCreate_SendBtcFundingCreated_340:
-		return true
-	case MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341:
-		return true
-	case MsgType_FundingCreate_Btc_AllItem_3104:
-		return true
-	case MsgType_FundingCreate_Btc_ItemById_3105:
-		return true
-	case MsgType_FundingCreate_Btc_ItemByTempChannelId_3106:
-		return true
-	case MsgType_FundingCreate_Btc_RDAllItem_3107:
-		return true
-	case MsgType_FundingCreate_Btc_ItemRDById_3108:
-		return true
-	case MsgType_FundingCreate_Btc_ItemRDByTempChannelId_3109:
-		return true
-	case MsgType_FundingCreate_Btc_ItemRDByTempChannelIdAndTxId_3110:
-		return true
-	case MsgType_FundingCreate_Btc_ItemByChannelId_3111:
-		return true
-	case MsgType_FundingSign_SendAssetFundingSigned_35:
-		return true
-	case MsgType_ClientSign_AssetFunding_RdAndBr_1035:
-		return true
-	case MsgType_FundingSign_SendBtcSign_350:
-		return true
-	case MsgType_CommitmentTx_SendCommitmentTransactionCreated_351:
-		return true
-	case MsgType_ClientSign_CommitmentTx_AliceSignC2a_360:
-		return true
-	case MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352:
-		return true
-	case MsgType_ClientSign_CommitmentTx_BobSignC2b_361:
-		return true
-	case MsgType_ClientSign_CommitmentTx_AliceSignC2b_362:
-		return true
-	case MsgType_ClientSign_CommitmentTx_AliceSignC2b_Rd_363:
-		return true
-	case MsgType_ClientSign_CommitmentTx_BobSignC2b_Rd_364:
-		return true
-	case MsgType_CommitmentTx_ItemsByChanId_3200:
-		return true
-	case MsgType_CommitmentTx_DelItemByChanId_3209:
-		return true
-	case MsgType_CommitmentTx_ItemById_3201:
-		return true
-	case MsgType_CommitmentTx_Count_3202:
-		return true
-	case MsgType_CommitmentTx_LatestCommitmentTxByChanId_3203:
-		return true
-	case MsgType_CommitmentTx_LatestRDByChanId_3204:
-		return true
-	case MsgType_CommitmentTx_LatestBRByChanId_3205:
-		return true
-	case MsgType_CommitmentTx_SendSomeCommitmentById_3206:
-		return true
-	case MsgType_CommitmentTx_AllRDByChanId_3207:
-		return true
-	case MsgType_CommitmentTx_AllBRByChanId_3208:
-		return true
-	case MsgType_Htlc_GetLatestHT1aOrHE1b_3250:
-		return true
-	case MsgType_Htlc_GetHT1aOrHE1bBySomeCommitmentId_3251:
-		return true
-	case MsgType_SendCloseChannelRequest_38:
-		return true
-	case MsgType_SendCloseChannelSign_39:
-		return true
-	case MsgType_HTLC_FindPath_401:
-		return true
-	case MsgType_HTLC_Invoice_402:
-		return true
-	case MsgType_HTLC_ParseInvoice_403:
-		return true
-	case MsgType_HTLC_SendAddHTLC_40:
-		return true
-	case MsgType_HTLC_ClientSign_Alice_C3a_100:
-		return true
-	case MsgType_HTLC_ClientSign_Bob_C3b_101:
-		return true
-	case MsgType_HTLC_ClientSign_Alice_C3b_102:
-		return true
-	case MsgType_HTLC_ClientSign_Alice_C3bSub_103:
-		return true
-	case MsgType_HTLC_ClientSign_Bob_C3bSub_104:
-		return true
-	case MsgType_HTLC_ClientSign_Alice_He_105:
-		return true
-	case MsgType_HTLC_ClientSign_Bob_HeSub_106:
-		return true
-	case MsgType_HTLC_ClientSign_Alice_HeSub_46:
-		return true
-	case MsgType_HTLC_SendAddHTLCSigned_41:
-		return true
-	case MsgType_HTLC_SendVerifyR_45:
-		return true
-	case MsgType_HTLC_Close_SendRequestCloseCurrTx_49:
-		return true
-	case MsgType_HTLC_Close_ClientSign_Alice_C4a_110:
-		return true
-	case MsgType_HTLC_Close_ClientSign_Bob_C4b_111:
-		return true
-	case MsgType_HTLC_Close_ClientSign_Alice_C4b_112:
-		return true
-	case MsgType_HTLC_Close_ClientSign_Alice_C4bSub_113:
-		return true
-	case MsgType_HTLC_Close_ClientSign_Bob_C4bSubResult_114:
-		return true
-	case MsgType_HTLC_Close_SendCloseSigned_50:
-		return true
-	case MsgType_Atomic_SendSwap_80:
-		return true
-	case MsgType_Atomic_SendSwapAccept_81:
-		return true
-	}
-	return false
-}
@@ -0,0 +1,21 @@
+package bean
+
+import (
+	"time"
+
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+// Event the lifecycle event of the channels, payments and invoices of the user
+type Event struct {
+	Type       enum.EventType `json:"type"`
+	UserPeerId string         `json:"user_peer_id"`
+	ChannelId  string         `json:"channel_id,omitempty"`
+	Data       interface{}    `json:"data,omitempty"`
+	CreateAt   time.Time      `json:"create_at"`
+}
+
+// EventSubscription the event types to subscribe or unsubscribe, all types if it is empty
+type EventSubscription struct {
+	EventTypes []enum.EventType `json:"event_types"`
+}
@@ -1,5 +1,7 @@
 package bean
 
+import "github.com/omnilaboratory/obd/bean/enum"
+
 //type -100402: invoice
 type HtlcRequestInvoice struct {
 	NetType string `json:"net_type"` //解析用
@@ -21,6 +23,12 @@ type HtlcRequestFindPathInfo struct {
 //type --100401: alice tell carl ,she wanna transfer some money to Carl
 type HtlcRequestFindPath struct {
 	Invoice string `json:"invoice"`
+	// the amount is split over at most max_parts routes when no route carries it
+	MaxParts int `json:"max_parts,omitempty"`
+	// the budget of the retries, the defaults are in the htlc section of conf.ini
+	MaxAttempts int     `json:"max_attempts,omitempty"`
+	FeeLimit    float64 `json:"fee_limit,omitempty"`
+	Timeout     int     `json:"timeout,omitempty"` //seconds
 	HtlcRequestFindPathInfo
 	typeLengthValue
 }
@@ -34,6 +42,8 @@ type CreateHtlcTxForC3a struct {
 	H                                string  `json:"h"`
 	CltvExpiry                       int     `json:"cltv_expiry"` //发起者设定的总的等待的区块个数
 	RoutingPacket                    string  `json:"routing_packet"`
+	TotalAmount                      float64 `json:"total_amount,omitempty"`        //多路径支付的总金额
+	Onion                            string  `json:"onion,omitempty"`               //the onion of the next hop, the channels of the path are not sent
 	LastTempAddressPrivateKey        string  `json:"last_temp_address_private_key"` //	上个RSMC委托交易用到的临时地址的私钥
 	CurrRsmcTempAddressIndex         int     `json:"curr_rsmc_temp_address_index"`
 	CurrRsmcTempAddressPubKey        string  `json:"curr_rsmc_temp_address_pub_key"` //	创建Cnx中的toRsmc的部分使用的临时地址的公钥
@@ -80,6 +90,8 @@ type CreateHtlcTxForC3aOfP2p struct {
 	H                                string               `json:"h"`
 	CltvExpiry                       int                  `json:"cltv_expiry"` //发起者设定的总的等待的区块个数
 	RoutingPacket                    string               `json:"routing_packet"`
+	TotalAmount                      float64              `json:"total_amount,omitempty"`                  //多路径支付的总金额
+	Onion                            string               `json:"onion,omitempty"`                         //the onion peeled by bob
 	LastTempAddressPrivateKey        string               `json:"last_temp_address_private_key"`           //	上个RSMC委托交易用到的临时地址的私钥
 	CurrRsmcTempAddressPubKey        string               `json:"curr_rsmc_temp_address_pub_key"`          //	创建Cnx中的toRsmc的部分使用的临时地址的公钥
 	CurrHtlcTempAddressPubKey        string               `json:"curr_htlc_temp_address_pub_key"`          //	创建Cnx中的toHtlc的部分使用的临时地址的公钥
@@ -355,6 +367,30 @@ type AliceSignHerdTxOfC3b struct {
 	ChannelId string `json:"channel_id"` //the global channel id.
 }
 
+// 反向传递htlc的失败
+
+// HtlcFailure why the htlc can not go on, and the next channel or peer which fails it
+type HtlcFailure struct {
+	FailureCode     enum.ErrorCode `json:"failure_code"`
+	Reason          string         `json:"reason"`
+	FailedChannelId string         `json:"failed_channel_id,omitempty"`
+	FailedPeerId    string         `json:"failed_peer_id,omitempty"`
+}
+
+// type 消息 100047 the receiver of the htlc can not forward it, or the payee rejects it
+type HtlcSendFail struct {
+	ChannelId string `json:"channel_id"` //the channel of the htlc to fail
+	HtlcFailure
+}
+
+// type p2p消息 47 the failure goes back to the payer, the sender of the failed htlc closes it by 100049
+type HtlcFail struct {
+	ChannelId string `json:"channel_id"`
+	H         string `json:"h"`
+	HtlcFailure
+	Onion string `json:"onion,omitempty"` //the failure encrypted to the payer, instead of the failure in plain
+}
+
 //
 //
 //
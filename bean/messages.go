@@ -8,8 +8,21 @@ import (
 var CurrObdNodeInfo ObdNodeInfo
 
 type ObdNodeInfo struct {
+	NodeId        string `json:"node_id"`
 	P2pAddress    string `json:"p2p_address"`
 	WebsocketLink string `json:"websocket_link"`
+	// obd works without the tracker for the local queries and the rsmc payments, and reconnects to it in the background.
+	// TrackerHost is the first connected tracker in the priority order, it finds the paths of the payments.
+	TrackerHost      string            `json:"tracker_host"`
+	TrackerConnected bool              `json:"tracker_connected"`
+	Trackers         []TrackerNodeInfo `json:"trackers"`
+}
+
+type TrackerNodeInfo struct {
+	Host      string `json:"host"`
+	Connected bool   `json:"connected"`
+	Healthy   bool   `json:"healthy"`
+	LastError string `json:"last_error,omitempty"`
 }
 
 //obd客户端请求消息体
@@ -20,15 +33,18 @@ type RequestMessage struct {
 	RecipientUserPeerId string       `json:"recipient_user_peer_id"`
 	RecipientNodePeerId string       `json:"recipient_node_peer_id"`
 	Data                string       `json:"data"`
+	RequestId           string       `json:"request_id,omitempty"` // optional, echoed on the replies
 }
 
 //obd答复消息体
 type ReplyMessage struct {
-	Type   enum.MsgType `json:"type"`
-	Status bool         `json:"status"`
-	From   string       `json:"from"`
-	To     string       `json:"to"`
-	Result interface{}  `json:"result"`
+	Type      enum.MsgType   `json:"type"`
+	Status    bool           `json:"status"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	RequestId string         `json:"request_id,omitempty"`
+	ErrorCode enum.ErrorCode `json:"error_code,omitempty"`
+	Result    interface{}    `json:"result"`
 }
 
 type UserState int
@@ -16,19 +16,78 @@ var (
 	ServerPort   = 60020
 	ReadTimeout  = 60 * time.Second
 	WriteTimeout = 60 * time.Second
+	// the deadline of the graceful shutdown
+	ShutdownTimeout = 30 * time.Second
 
 	GrpcServerPort = 50051
+	// the grpc session expires if it is idle for so long
+	GrpcSessionTimeout = 30 * time.Minute
+
+	// the websocket and grpc endpoints use tls, a self-signed certificate is generated in DataDirectory if they are empty
+	TlsCert    = ""
+	TlsKey     = ""
+	TlsDisable = false
+
+	// every websocket connection and grpc request must carry an api credential
+	ApiCredentialRequired = false
+
+	OutboxTTL = 24 * time.Hour
 
 	HtlcFeeRate = 0.0001
 	HtlcMaxFee  = 0.01
+	// the payee of a multi-path payment waits for all the parts, the parts are cancelled after the timeout
+	HtlcPartsTimeout = time.Minute
+	// the payer retries the failed payment by the other paths, until one of the budgets is used up
+	HtlcRetryAttempts = 3
+	HtlcRetryTimeout  = 5 * time.Minute
+	// the path request goes to the next tracker if the tracker does not reply in time
+	HtlcPathTimeout = 30 * time.Second
 
-	TrackerHost = "127.0.0.1:60060"
+	// the on-chain fee policy: the estimator is tracker, static or file, see omnicore/fee_estimator.go
+	FeeEstimatorType = "tracker"
+	// the file path or the url of the file estimator
+	FeeSource = ""
+	// satoshi per byte, the rate of the static estimator, and the fallback when the estimator fails
+	FeeRate    = 6.0
+	MinFeeRate = 4.0
+	// the conf targets of the transactions of every purpose
+	FundingConfTarget    = 10
+	CommitmentConfTarget = 10
+	SweepConfTarget      = 10
+
+	// the trackers in the priority order, the chain queries and the path finding fail over to the next one,
+	// the users and channels are announced to all of them
+	TrackerHosts = []string{"127.0.0.1:60060"}
+
+	// the chain is queried by the tracker or the omnicore node of obd, see conn/chain_backend.go
+	ChainBackendType = "tracker"
+	// the json-rpc of the omnicore node
+	ChainNodeHost = ""
+	ChainNodeUser = ""
+	ChainNodePass = ""
 
 	ChainNodeType = "regtest"
 	//P2P
 	P2P_hostIp     = "127.0.0.1"
 	P2P_port       = 4001
 	BootstrapPeers addrList
+	// encrypt the node key on disk when it is not empty
+	NodeKeyPassphrase = ""
+
+	// node key maintenance, obd exits after finishing the task
+	ExportNodeKey = flag.String("exportNodeKey", "", "Export the node key to the file")
+	ImportNodeKey = flag.String("importNodeKey", "", "Import the node key from the file exported before")
+	RotateNodeKey = flag.Bool("rotateNodeKey", false, "Generate a new node key and backup the current one")
+
+	// migrate the user dbs of old versions, the mnemonics are read from stdin, one per line
+	MigrateUserDb    = flag.Bool("migrateUserDb", false, "Migrate the user dbs of old versions to the wallet based peer ids")
+	LegacyMacAddress = flag.String("legacyMacAddress", "", "Mac address of the host which created the user dbs, default is the current one")
+	LegacyServerPort = flag.Int("legacyServerPort", 0, "Server port of the obd which created the user dbs, default is the current one")
+
+	// api credential maintenance, obd exits after finishing the task
+	BakeCredential    = flag.String("bakeCredential", "", "Bake an api credential with the scopes: read,invoice,payment,admin")
+	CredentialCaveats = flag.String("credentialCaveats", "", "Caveats of the baked credential, separated by ';', such as 'expire = 1700000000; ip = 127.0.0.1; max_amount = 1'")
+	RevokeCredential  = flag.String("revokeCredential", "", "Revoke the api credential by id")
 
 	Init_node_chain_hash = "1EXoDusjGwvnjZUyKkxZ4UHEf77z6A5S4P"
 
@@ -36,6 +95,19 @@ var (
 	dataDirectoryName = ".obd"
 )
 
+// GetDataDirectory the dataDirectory of the server section of the config file, or .obd in the home directory
+func GetDataDirectory(section *ini.Section) (string, error) {
+	specifiedDataDirectory, err := section.GetKey("dataDirectory")
+	if err == nil {
+		return specifiedDataDirectory.Value(), nil
+	}
+	homeDirectory, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+	return homeDirectory + "/" + dataDirectoryName, nil
+}
+
 func Init() {
 	testing.Init()
 	flag.Parse()
@@ -54,18 +126,19 @@ func Init() {
 	}
 	ServerPort = section.Key("port").MustInt(60020)
 	GrpcServerPort = section.Key("grpc_server_port").MustInt(50051)
+	GrpcSessionTimeout = time.Duration(section.Key("grpc_session_timeout").MustInt(1800)) * time.Second
 	ReadTimeout = time.Duration(section.Key("readTimeout").MustInt(60)) * time.Second
 	WriteTimeout = time.Duration(section.Key("writeTimeout").MustInt(60)) * time.Second
+	ShutdownTimeout = time.Duration(section.Key("shutdownTimeout").MustInt(30)) * time.Second
+	TlsCert = section.Key("tls_cert").String()
+	TlsKey = section.Key("tls_key").String()
+	TlsDisable = section.Key("tls_disable").MustBool(false)
+	ApiCredentialRequired = section.Key("api_credential_required").MustBool(false)
+	OutboxTTL = time.Duration(section.Key("outboxTtl").MustInt(86400)) * time.Second
 
-	specifiedDataDirectory, err := section.GetKey("dataDirectory")
-	if err == nil {
-		DataDirectory = specifiedDataDirectory.Value()
-	} else {
-		homeDirectory, err := homedir.Dir()
-		if err != nil {
-			panic(err.Error())
-		}
-		DataDirectory = homeDirectory + "/" + dataDirectoryName
+	DataDirectory, err = GetDataDirectory(section)
+	if err != nil {
+		panic(err.Error())
 	}
 
 	htlcNode, err := Cfg.GetSection("htlc")
@@ -75,6 +148,27 @@ func Init() {
 	}
 	HtlcFeeRate = htlcNode.Key("feeRate").MustFloat64(0.0001)
 	HtlcMaxFee = htlcNode.Key("maxFee").MustFloat64(0.01)
+	HtlcPartsTimeout = time.Duration(htlcNode.Key("partsTimeout").MustInt(60)) * time.Second
+	HtlcRetryAttempts = htlcNode.Key("retryAttempts").MustInt(3)
+	HtlcRetryTimeout = time.Duration(htlcNode.Key("retryTimeout").MustInt(300)) * time.Second
+	HtlcPathTimeout = time.Duration(htlcNode.Key("pathTimeout").MustInt(30)) * time.Second
+
+	// the fee section is optional
+	feeNode := Cfg.Section("fee")
+	FeeEstimatorType = feeNode.Key("estimator").MustString("tracker")
+	FeeSource = feeNode.Key("source").String()
+	FeeRate = feeNode.Key("feeRate").MustFloat64(6)
+	MinFeeRate = feeNode.Key("minFeeRate").MustFloat64(4)
+	FundingConfTarget = feeNode.Key("fundingConfTarget").MustInt(10)
+	CommitmentConfTarget = feeNode.Key("commitmentConfTarget").MustInt(10)
+	SweepConfTarget = feeNode.Key("sweepConfTarget").MustInt(10)
+
+	// the chainNode section is optional, obd uses the full node of the tracker without it
+	chainNode := Cfg.Section("chainNode")
+	ChainBackendType = chainNode.Key("backend").MustString("tracker")
+	ChainNodeHost = chainNode.Key("host").String()
+	ChainNodeUser = chainNode.Key("user").String()
+	ChainNodePass = chainNode.Key("pass").String()
 
 	p2pNode, err := Cfg.GetSection("p2p")
 	if err != nil {
@@ -83,6 +177,7 @@ func Init() {
 	}
 	P2P_hostIp = p2pNode.Key("hostIp").String()
 	P2P_port = p2pNode.Key("port").MustInt()
+	NodeKeyPassphrase = p2pNode.Key("nodeKeyPassphrase").String()
 
 	//tracker
 	tracker, err := Cfg.GetSection("tracker")
@@ -90,5 +185,8 @@ func Init() {
 		log.Println(err)
 		return
 	}
-	TrackerHost = tracker.Key("host").MustString("localhost:60060")
+	TrackerHosts = tracker.Key("host").Strings(",")
+	if len(TrackerHosts) == 0 {
+		TrackerHosts = []string{"localhost:60060"}
+	}
 }
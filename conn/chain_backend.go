@@ -0,0 +1,204 @@
+package conn2tracker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/omnilaboratory/obd/config"
+	"github.com/tidwall/gjson"
+)
+
+// ChainBackend the chain queries and the broadcasting of obd, the tracker proxies them to its full node by default,
+// and obd can query its own omnicore node instead. The results are the json of the full node if they are strings,
+// and every query returns the error of the backend, so a zero balance is told from an unavailable backend.
+// The queries end by the deadline or the cancel of the ctx, including the retries of the tracker.
+type ChainBackend interface {
+	GetBlockCount(ctx context.Context) (int, error)
+	GetOmniBalance(ctx context.Context, address string, propertyId int) (float64, error)
+	ListReceivedByAddress(ctx context.Context, address string) (string, error)
+	GetTransactionById(ctx context.Context, txid string) (string, error)
+	ListUnspent(ctx context.Context, address string) (string, error)
+	// satoshi per byte
+	EstimateSmartFee(ctx context.Context, confTarget int32) (float64, error)
+	// data is the json of the inputs and the outputs: {"inputs": [...], "outputs": {...}}
+	CreateRawTransaction(ctx context.Context, data string) (string, error)
+	OmniGetBalancesForAddress(ctx context.Context, address string, propertyId int) (*OmniBalance, error)
+	TestMemPoolAccept(ctx context.Context, hex string) (*MempoolAcceptResult, error)
+	SendRawTransaction(ctx context.Context, hex string) (string, error)
+	OmniDecodeTransaction(ctx context.Context, hex string) (string, error)
+	OmniListTransactions(ctx context.Context, address string) (string, error)
+	OmniGetProperty(ctx context.Context, propertyId int64) (string, error)
+	OmniGetTransaction(ctx context.Context, txid string) (string, error)
+	GetBalanceByAddress(ctx context.Context, address string) (float64, error)
+}
+
+// OmniBalance the balance of a property of an address
+type OmniBalance struct {
+	Balance  float64 `json:"balance"`
+	Reserved float64 `json:"reserved"`
+	Frozen   float64 `json:"frozen"`
+}
+
+// MempoolAcceptResult the result of testmempoolaccept of a raw transaction
+type MempoolAcceptResult struct {
+	Txid         string `json:"txid"`
+	Allowed      bool   `json:"allowed"`
+	RejectReason string `json:"reject-reason"`
+}
+
+// the result of omni_getbalance, the amounts are strings
+func parseOmniBalance(result string) (*OmniBalance, error) {
+	if gjson.Valid(result) == false || gjson.Get(result, "balance").Exists() == false {
+		return nil, errors.New("wrong omni balance " + result)
+	}
+	return &OmniBalance{
+		Balance:  gjson.Get(result, "balance").Float(),
+		Reserved: gjson.Get(result, "reserved").Float(),
+		Frozen:   gjson.Get(result, "frozen").Float(),
+	}, nil
+}
+
+// the result of testmempoolaccept, the array of the only transaction
+func parseMempoolAccept(result string) (*MempoolAcceptResult, error) {
+	array := gjson.Parse(result).Array()
+	if len(array) == 0 {
+		return nil, errors.New("wrong result of testmempoolaccept " + result)
+	}
+	return &MempoolAcceptResult{
+		Txid:         array[0].Get("txid").Str,
+		Allowed:      array[0].Get("allowed").Bool(),
+		RejectReason: array[0].Get("reject-reason").Str,
+	}, nil
+}
+
+// the types of the chain backends in the config
+const (
+	ChainBackendType_Tracker  = "tracker"
+	ChainBackendType_Omnicore = "omnicore"
+)
+
+// NewChainBackend create the backend of the type: tracker, or omnicore with the rpc host, user and pass of the node
+func NewChainBackend(backendType string, host string, user string, pass string) (ChainBackend, error) {
+	switch backendType {
+	case ChainBackendType_Tracker, "":
+		return &trackerChainBackend{}, nil
+	case ChainBackendType_Omnicore:
+		if len(host) == 0 {
+			return nil, errors.New("the host of the omnicore node is empty")
+		}
+		return newOmnicoreChainBackend(host, user, pass), nil
+	}
+	return nil, errors.New("wrong chain backend " + backendType)
+}
+
+var (
+	chainBackendLock sync.Mutex
+	chainBackend     ChainBackend
+)
+
+// SetChainBackend replace the backend created by the config
+func SetChainBackend(backend ChainBackend) {
+	chainBackendLock.Lock()
+	chainBackend = backend
+	chainBackendLock.Unlock()
+}
+
+func getChainBackend() ChainBackend {
+	chainBackendLock.Lock()
+	defer chainBackendLock.Unlock()
+	if chainBackend == nil {
+		backend, err := NewChainBackend(config.ChainBackendType, config.ChainNodeHost, config.ChainNodeUser, config.ChainNodePass)
+		if err != nil {
+			log.Println(err, ", use the tracker chain backend")
+			backend = &trackerChainBackend{}
+		}
+		chainBackend = backend
+	}
+	return chainBackend
+}
+
+// CheckChainBackend check that the backend is on the chain of the tracker: main, test or regtest
+func CheckChainBackend(chainNodeType string) error {
+	if _, ok := getChainBackend().(*omnicoreChainBackend); ok == false {
+		return nil
+	}
+	chain, err := GetBackendChainNodeType()
+	if err != nil {
+		return err
+	}
+	if chain != chainNodeType {
+		return errors.New("the omnicore node is on the chain " + chain + ", but the tracker is on " + chainNodeType)
+	}
+	return nil
+}
+
+// GetBackendChainNodeType the chain of the omnicore backend, obd knows its chain by it without the tracker
+func GetBackendChainNodeType() (string, error) {
+	backend, ok := getChainBackend().(*omnicoreChainBackend)
+	if ok == false {
+		return "", errors.New("the chain backend is the tracker")
+	}
+	return backend.client.CheckVersion()
+}
+
+func GetBlockCount(ctx context.Context) (int, error) {
+	return getChainBackend().GetBlockCount(ctx)
+}
+
+func GetOmniBalance(ctx context.Context, address string, propertyId int) (float64, error) {
+	return getChainBackend().GetOmniBalance(ctx, address, propertyId)
+}
+
+func ListReceivedByAddress(ctx context.Context, address string) (string, error) {
+	return getChainBackend().ListReceivedByAddress(ctx, address)
+}
+
+func GetTransactionById(ctx context.Context, txid string) (string, error) {
+	return getChainBackend().GetTransactionById(ctx, txid)
+}
+
+func ListUnspent(ctx context.Context, address string) (string, error) {
+	return getChainBackend().ListUnspent(ctx, address)
+}
+
+func EstimateSmartFee(ctx context.Context, confTarget int32) (float64, error) {
+	return getChainBackend().EstimateSmartFee(ctx, confTarget)
+}
+
+func CreateRawTransaction(ctx context.Context, data string) (string, error) {
+	return getChainBackend().CreateRawTransaction(ctx, data)
+}
+
+func OmniGetBalancesForAddress(ctx context.Context, address string, propertyId int) (*OmniBalance, error) {
+	return getChainBackend().OmniGetBalancesForAddress(ctx, address, propertyId)
+}
+
+func TestMemPoolAccept(ctx context.Context, hex string) (*MempoolAcceptResult, error) {
+	return getChainBackend().TestMemPoolAccept(ctx, hex)
+}
+
+func SendRawTransaction(ctx context.Context, hex string) (string, error) {
+	return getChainBackend().SendRawTransaction(ctx, hex)
+}
+
+func OmniDecodeTransaction(ctx context.Context, hex string) (string, error) {
+	return getChainBackend().OmniDecodeTransaction(ctx, hex)
+}
+
+func OmniListTransactions(ctx context.Context, address string) (string, error) {
+	return getChainBackend().OmniListTransactions(ctx, address)
+}
+
+func OmniGetProperty(ctx context.Context, propertyId int64) (string, error) {
+	return getChainBackend().OmniGetProperty(ctx, propertyId)
+}
+
+func OmniGetTransaction(ctx context.Context, txid string) (string, error) {
+	return getChainBackend().OmniGetTransaction(ctx, txid)
+}
+
+func GetBalanceByAddress(ctx context.Context, address string) (float64, error) {
+	return getChainBackend().GetBalanceByAddress(ctx, address)
+}
@@ -0,0 +1,149 @@
+package conn2tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/omnilaboratory/obd/tool"
+	"github.com/omnilaboratory/obd/tracker/rpc"
+)
+
+// omnicoreChainBackend queries the omnicore node of obd by json-rpc, the results are the same as the ones of the tracker
+type omnicoreChainBackend struct {
+	client *rpc.Client
+}
+
+func newOmnicoreChainBackend(host string, user string, pass string) *omnicoreChainBackend {
+	return &omnicoreChainBackend{client: rpc.NewClientWithConfig(rpc.ConnConfig{Host: host, User: user, Pass: pass})}
+}
+
+func (backend *omnicoreChainBackend) GetBlockCount(ctx context.Context) (int, error) {
+	return backend.client.WithContext(ctx).GetBlockCount()
+}
+
+func (backend *omnicoreChainBackend) GetOmniBalance(ctx context.Context, address string, propertyId int) (float64, error) {
+	balance, err := backend.OmniGetBalancesForAddress(ctx, address, propertyId)
+	if err != nil {
+		return 0, err
+	}
+	return balance.Balance, nil
+}
+
+func (backend *omnicoreChainBackend) ListReceivedByAddress(ctx context.Context, address string) (string, error) {
+	if tool.CheckIsAddress(address) == false {
+		return "", errors.New("error address")
+	}
+	return backend.client.WithContext(ctx).ListReceivedByAddress(address)
+}
+
+func (backend *omnicoreChainBackend) GetTransactionById(ctx context.Context, txid string) (string, error) {
+	if tool.CheckIsString(&txid) == false {
+		return "", errors.New("wrong txid")
+	}
+	return backend.client.WithContext(ctx).GetTransactionById(txid)
+}
+
+func (backend *omnicoreChainBackend) ListUnspent(ctx context.Context, address string) (string, error) {
+	if tool.CheckIsAddress(address) == false {
+		return "", errors.New("error address")
+	}
+	return backend.client.WithContext(ctx).ListUnspent(address)
+}
+
+func (backend *omnicoreChainBackend) EstimateSmartFee(ctx context.Context, confTarget int32) (float64, error) {
+	feeRate := backend.client.WithContext(ctx).EstimateSmartFee(int(confTarget))
+	if feeRate == 0 {
+		return 0, errors.New("fail to estimate the smart fee")
+	}
+	return feeRate, nil
+}
+
+func (backend *omnicoreChainBackend) CreateRawTransaction(ctx context.Context, data string) (string, error) {
+	tx := struct {
+		Inputs  []map[string]interface{} `json:"inputs"`
+		Outputs map[string]interface{}   `json:"outputs"`
+	}{}
+	if err := json.Unmarshal([]byte(data), &tx); err != nil {
+		log.Println(err)
+		return "", err
+	}
+	return backend.client.WithContext(ctx).CreateRawTransaction(tx.Inputs, tx.Outputs)
+}
+
+func (backend *omnicoreChainBackend) OmniGetBalancesForAddress(ctx context.Context, address string, propertyId int) (*OmniBalance, error) {
+	if tool.CheckIsAddress(address) == false {
+		return nil, errors.New("error address")
+	}
+	result, err := backend.client.WithContext(ctx).OmniGetbalance(address, propertyId)
+	if err != nil {
+		return nil, err
+	}
+	return parseOmniBalance(result)
+}
+
+func (backend *omnicoreChainBackend) TestMemPoolAccept(ctx context.Context, hex string) (*MempoolAcceptResult, error) {
+	if tool.CheckIsString(&hex) == false {
+		return nil, errors.New("error hex")
+	}
+	result, err := backend.client.WithContext(ctx).TestMemPoolAccept(hex)
+	if err != nil {
+		return nil, err
+	}
+	return parseMempoolAccept(result)
+}
+
+func (backend *omnicoreChainBackend) SendRawTransaction(ctx context.Context, hex string) (string, error) {
+	if tool.CheckIsString(&hex) == false {
+		return "", errors.New("error hex")
+	}
+	return backend.client.WithContext(ctx).SendRawTransaction(hex)
+}
+
+func (backend *omnicoreChainBackend) OmniDecodeTransaction(ctx context.Context, hex string) (string, error) {
+	if tool.CheckIsString(&hex) == false {
+		return "", errors.New("error hex")
+	}
+	return backend.client.WithContext(ctx).OmniDecodeTransaction(hex)
+}
+
+func (backend *omnicoreChainBackend) OmniListTransactions(ctx context.Context, address string) (string, error) {
+	if tool.CheckIsAddress(address) == false {
+		return "", errors.New("error address")
+	}
+	result, err := backend.client.WithContext(ctx).OmniListTransactions(address, 100, 0)
+	if err != nil {
+		return "", err
+	}
+	if result == "[]" {
+		return "", errors.New("no tx")
+	}
+	return result, nil
+}
+
+func (backend *omnicoreChainBackend) OmniGetProperty(ctx context.Context, propertyId int64) (string, error) {
+	if propertyId < 1 {
+		return "", errors.New("error propertyId")
+	}
+	return backend.client.WithContext(ctx).OmniGetProperty(propertyId)
+}
+
+func (backend *omnicoreChainBackend) OmniGetTransaction(ctx context.Context, txid string) (string, error) {
+	if tool.CheckIsString(&txid) == false {
+		return "", errors.New("wrong txid")
+	}
+	return backend.client.WithContext(ctx).OmniGettransaction(txid)
+}
+
+func (backend *omnicoreChainBackend) GetBalanceByAddress(ctx context.Context, address string) (float64, error) {
+	if tool.CheckIsAddress(address) == false {
+		return 0.0, errors.New("error address")
+	}
+	balance, err := backend.client.WithContext(ctx).GetBalanceByAddress(address)
+	if err != nil {
+		return 0.0, err
+	}
+	result, _ := balance.Round(8).Float64()
+	return result, nil
+}
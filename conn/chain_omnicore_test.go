@@ -0,0 +1,78 @@
+package conn2tracker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// a fake omnicore node, which replies the results of the methods
+func newFakeOmnicoreNode(t *testing.T, results map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     interface{}       `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		result, ok := results[req.Method]
+		if ok == false {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "error": map[string]interface{}{"code": -32601, "message": "Method not found"}})
+			return
+		}
+		if req.Method == "createrawtransaction" && len(req.Params) != 2 {
+			t.Errorf("createrawtransaction has %d params, want the inputs and the outputs", len(req.Params))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result})
+	}))
+}
+
+func TestOmnicoreChainBackend(t *testing.T) {
+	node := newFakeOmnicoreNode(t, map[string]interface{}{
+		"getblockcount":         120,
+		"omni_getbalance":       map[string]interface{}{"balance": "10.5", "reserved": "0"},
+		"createrawtransaction":  "0200000001",
+		"omni_listtransactions": []interface{}{},
+		"importaddress":         nil,
+		"listunspent":           []interface{}{map[string]interface{}{"amount": 0.1}, map[string]interface{}{"amount": 0.2}},
+	})
+	defer node.Close()
+
+	backend, err := NewChainBackend(ChainBackendType_Omnicore, node.URL, "user", "pass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, err := backend.GetBlockCount(context.Background()); err != nil || count != 120 {
+		t.Errorf("the block count is %d %v, want 120", count, err)
+	}
+	if balance, err := backend.GetOmniBalance(context.Background(), "ms6kvv4RXqiQE53HbZ7NhaPmZpngb4XRiY", 121); err != nil || balance != 10.5 {
+		t.Errorf("the omni balance is %v %v, want 10.5", balance, err)
+	}
+	if hex, err := backend.CreateRawTransaction(context.Background(), `{"inputs":[{"txid":"a","vout":0}],"outputs":{"ms6kvv4RXqiQE53HbZ7NhaPmZpngb4XRiY":0.1}}`); err != nil || hex != "0200000001" {
+		t.Errorf("the raw transaction is %q %v", hex, err)
+	}
+	if _, err := backend.OmniListTransactions(context.Background(), "ms6kvv4RXqiQE53HbZ7NhaPmZpngb4XRiY"); err == nil {
+		t.Error("no error for the empty transactions")
+	}
+	if balance, err := backend.GetBalanceByAddress(context.Background(), "ms6kvv4RXqiQE53HbZ7NhaPmZpngb4XRiY"); err != nil || balance != 0.3 {
+		t.Errorf("the btc balance is %v %v, want 0.3", balance, err)
+	}
+	if _, err := backend.SendRawTransaction(context.Background(), "0200000001"); err == nil {
+		t.Error("no error for the failed rpc")
+	}
+	if _, err := backend.TestMemPoolAccept(context.Background(), "0200000001"); err == nil {
+		t.Error("no error for the failed rpc")
+	}
+
+	// the request is not sent by the canceled ctx
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := backend.GetBlockCount(ctx); errors.Is(err, context.Canceled) == false {
+		t.Errorf("the error is %v, want the canceled ctx", err)
+	}
+}
@@ -0,0 +1,373 @@
+package conn2tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	url2 "net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omnilaboratory/obd/config"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	// ErrTrackerUnavailable the tracker is not reachable, the callers tell it from the empty results by errors.Is
+	ErrTrackerUnavailable = errors.New("the tracker is unavailable")
+	// ErrCircuitOpen the requests are not sent until the cooldown of the circuit breaker is over
+	ErrCircuitOpen = fmt.Errorf("%w: the circuit breaker is open", ErrTrackerUnavailable)
+)
+
+// TrackerError the tracker is reachable, but it replies an error message for the request
+type TrackerError struct {
+	Path       string
+	StatusCode int
+	Msg        string
+}
+
+func (err *TrackerError) Error() string {
+	if err.Msg == "" {
+		return fmt.Sprintf("the tracker replies %d for %s", err.StatusCode, err.Path)
+	}
+	return err.Msg
+}
+
+// IsTrackerUnavailable the request failed because the tracker is down, not because of the request
+func IsTrackerUnavailable(err error) bool {
+	return errors.Is(err, ErrTrackerUnavailable)
+}
+
+// circuitBreaker opens after the consecutive failures reach the threshold, and lets one trial request through
+// after the cooldown: the tracker is closed again by a success, or open for another cooldown by a failure.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openedAt  time.Time
+	trial     bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+func (breaker *circuitBreaker) allow() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	if breaker.failures < breaker.threshold {
+		return true
+	}
+	if breaker.trial || time.Since(breaker.openedAt) < breaker.cooldown {
+		return false
+	}
+	breaker.trial = true
+	return true
+}
+
+func (breaker *circuitBreaker) success() {
+	breaker.mu.Lock()
+	breaker.failures = 0
+	breaker.trial = false
+	breaker.mu.Unlock()
+}
+
+func (breaker *circuitBreaker) failure() {
+	breaker.mu.Lock()
+	breaker.failures++
+	breaker.trial = false
+	if breaker.failures >= breaker.threshold {
+		breaker.openedAt = time.Now()
+	}
+	breaker.mu.Unlock()
+}
+
+// trackerResponse the body of the tracker api: {"msg": ..., "data": ...}
+type trackerResponse struct {
+	path string
+	body gjson.Result
+}
+
+func (response *trackerResponse) data() gjson.Result {
+	return response.body.Get("data")
+}
+
+// the result of the full node in the data, the message of the tracker is the error if the data is empty
+func (response *trackerResponse) result() (string, error) {
+	result := response.data().Str
+	if result == "" {
+		return "", &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: response.body.Get("msg").Str}
+	}
+	return result, nil
+}
+
+// trackerClient the http client of the tracker api shared by all the requests. The requests go to the trackers in the
+// order of config.TrackerHosts, the healthy ones first: a tracker is skipped while its circuit breaker is open, and the
+// next one is tried if it is not reachable. Every attempt has its deadline, the round of the trackers is retried with
+// the exponential backoff.
+type trackerClient struct {
+	httpClient       *http.Client
+	timeout          time.Duration
+	retries          int
+	backoff          time.Duration
+	maxBackoff       time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	statuses map[string]*TrackerStatus
+}
+
+// TrackerStatus the result of the last health check of the tracker
+type TrackerStatus struct {
+	Host      string    `json:"host"`
+	Healthy   bool      `json:"healthy"`
+	LastError string    `json:"last_error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+func newTrackerClient() *trackerClient {
+	return &trackerClient{
+		httpClient: &http.Client{Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			MaxIdleConnsPerHost: 16,
+			IdleConnTimeout:     90 * time.Second,
+		}},
+		timeout:          10 * time.Second,
+		retries:          3,
+		backoff:          200 * time.Millisecond,
+		maxBackoff:       2 * time.Second,
+		breakerThreshold: 5,
+		breakerCooldown:  30 * time.Second,
+		breakers:         make(map[string]*circuitBreaker),
+		statuses:         make(map[string]*TrackerStatus),
+	}
+}
+
+var tracker = newTrackerClient()
+
+func (client *trackerClient) breakerOf(host string) *circuitBreaker {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	breaker, ok := client.breakers[host]
+	if ok == false {
+		breaker = newCircuitBreaker(client.breakerThreshold, client.breakerCooldown)
+		client.breakers[host] = breaker
+	}
+	return breaker
+}
+
+// the trackers in the priority order, the ones failed by the last health check are the last resort
+func (client *trackerClient) hosts() []string {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	healthy := make([]string, 0, len(config.TrackerHosts))
+	unhealthy := make([]string, 0)
+	for _, host := range config.TrackerHosts {
+		if status, ok := client.statuses[host]; ok && status.Healthy == false {
+			unhealthy = append(unhealthy, host)
+		} else {
+			healthy = append(healthy, host)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+func (client *trackerClient) get(ctx context.Context, path string, query url2.Values) (*trackerResponse, error) {
+	return client.do(ctx, client.hosts(), http.MethodGet, path, query, "")
+}
+
+// getFrom request the tracker without the failover, for the queries about the tracker itself
+func (client *trackerClient) getFrom(ctx context.Context, host string, path string, query url2.Values) (*trackerResponse, error) {
+	return client.do(ctx, []string{host}, http.MethodGet, path, query, "")
+}
+
+func (client *trackerClient) post(ctx context.Context, path string, body string) (*trackerResponse, error) {
+	return client.do(ctx, client.hosts(), http.MethodPost, path, nil, body)
+}
+
+func (client *trackerClient) getResult(ctx context.Context, path string, query url2.Values) (string, error) {
+	response, err := client.get(ctx, path, query)
+	if err != nil {
+		return "", err
+	}
+	return response.result()
+}
+
+// getOnce request the first available tracker once, without the retries and the failover, for the calls which are not
+// safe to repeat: the tracker may have sent the assets or broadcast the transaction before the attempt timed out
+func (client *trackerClient) getOnce(ctx context.Context, path string, query url2.Values) (*trackerResponse, error) {
+	for _, host := range client.hosts() {
+		breaker := client.breakerOf(host)
+		if breaker.allow() == false {
+			continue
+		}
+		response, retry, err := client.send(ctx, http.MethodGet, requestUrl(host, path, query), path, "")
+		if err != nil && retry && ctx.Err() == nil {
+			breaker.failure()
+		} else {
+			breaker.success()
+		}
+		return response, err
+	}
+	return nil, ErrCircuitOpen
+}
+
+func (client *trackerClient) getResultOnce(ctx context.Context, path string, query url2.Values) (string, error) {
+	response, err := client.getOnce(ctx, path, query)
+	if err != nil {
+		return "", err
+	}
+	return response.result()
+}
+
+// the retries stop when the ctx is done, the error wraps the error of the ctx then
+func (client *trackerClient) do(ctx context.Context, hosts []string, method string, path string, query url2.Values, body string) (*trackerResponse, error) {
+	var lastErr error
+	for attempt := 0; attempt <= client.retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(client.backoffOf(attempt))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("%w: %v", ctx.Err(), lastErr)
+			}
+		}
+		sent := false
+		for _, host := range hosts {
+			breaker := client.breakerOf(host)
+			if breaker.allow() == false {
+				continue
+			}
+			sent = true
+			response, retry, err := client.send(ctx, method, requestUrl(host, path, query), path, body)
+			if err == nil || retry == false {
+				breaker.success()
+				return response, err
+			}
+			if ctx.Err() != nil {
+				// the tracker is not blamed for the deadline of the caller
+				return nil, fmt.Errorf("%w: %v", ctx.Err(), err)
+			}
+			breaker.failure()
+			lastErr = err
+			log.Println("fail to request the tracker", host, path, "attempt", attempt+1, err)
+		}
+		if sent == false {
+			return nil, ErrCircuitOpen
+		}
+	}
+	return nil, fmt.Errorf("%w: %v", ErrTrackerUnavailable, lastErr)
+}
+
+func requestUrl(host string, path string, query url2.Values) string {
+	url := "http://" + host + path
+	if len(query) > 0 {
+		url += "?" + query.Encode()
+	}
+	return url
+}
+
+func (client *trackerClient) backoffOf(attempt int) time.Duration {
+	backoff := client.backoff << uint(attempt-1)
+	if backoff > client.maxBackoff || backoff <= 0 {
+		backoff = client.maxBackoff
+	}
+	return backoff
+}
+
+// checkHealth query the block count of every tracker, the tracker is healthy if both it and its full node reply.
+// A healthy tracker closes its circuit breaker, so the requests go back to it by its priority at once.
+func (client *trackerClient) checkHealth() {
+	for _, host := range config.TrackerHosts {
+		status := &TrackerStatus{Host: host, Healthy: true, CheckedAt: time.Now()}
+		response, _, err := client.send(context.Background(), http.MethodGet, requestUrl(host, "/api/rpc/getBlockCount", nil), "/api/rpc/getBlockCount", "")
+		if err == nil && response.data().Int() == 0 {
+			err = errors.New("the tracker replies no block count")
+		}
+		if err != nil {
+			status.Healthy = false
+			status.LastError = err.Error()
+		} else {
+			client.breakerOf(host).success()
+		}
+		client.mu.Lock()
+		client.statuses[host] = status
+		client.mu.Unlock()
+	}
+}
+
+var healthCheckOnce sync.Once
+
+// StartTrackerHealthCheck check the health of the trackers in the background every interval
+func StartTrackerHealthCheck(interval time.Duration) {
+	healthCheckOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				tracker.checkHealth()
+				<-ticker.C
+			}
+		}()
+	})
+}
+
+// GetTrackerStatuses the health of the trackers in the priority order, the unchecked ones are healthy
+func GetTrackerStatuses() []TrackerStatus {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	statuses := make([]TrackerStatus, 0, len(config.TrackerHosts))
+	for _, host := range config.TrackerHosts {
+		if status, ok := tracker.statuses[host]; ok {
+			statuses = append(statuses, *status)
+		} else {
+			statuses = append(statuses, TrackerStatus{Host: host, Healthy: true})
+		}
+	}
+	return statuses
+}
+
+// retry is true if the tracker is not reachable or not available for now, the attempt ends by the timeout of the client
+// or the deadline of the ctx, whichever is earlier
+func (client *trackerClient) send(ctx context.Context, method string, url string, path string, body string) (response *trackerResponse, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, client.timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, false, err
+	}
+	if body != "" {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.httpClient.Do(request)
+	if err != nil {
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return &trackerResponse{path: path, body: gjson.ParseBytes(data)}, false, nil
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, true, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	return nil, false, &TrackerError{Path: path, StatusCode: resp.StatusCode, Msg: gjson.GetBytes(data, "msg").Str}
+}
@@ -0,0 +1,212 @@
+package conn2tracker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/omnilaboratory/obd/config"
+)
+
+// a client with short backoffs, the tracker host is the test server
+func newTestTrackerClient(t *testing.T, handler http.HandlerFunc) (*trackerClient, func()) {
+	server := httptest.NewServer(handler)
+	trackerHosts := config.TrackerHosts
+	config.TrackerHosts = []string{strings.TrimPrefix(server.URL, "http://")}
+	client := newTrackerClient()
+	client.timeout = time.Second
+	client.backoff = time.Millisecond
+	client.maxBackoff = 5 * time.Millisecond
+	return client, func() {
+		config.TrackerHosts = trackerHosts
+		server.Close()
+	}
+}
+
+func TestTrackerClientRetry(t *testing.T) {
+	var count int32
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"msg":"blockCount","data":120}`))
+	})
+	defer stop()
+
+	response, err := client.get(context.Background(), "/api/rpc/getBlockCount", nil)
+	if err != nil || response.data().Int() != 120 || count != 3 {
+		t.Fatalf("the block count is %v %v after %d attempts", response, err, count)
+	}
+}
+
+func TestTrackerClientDeadline(t *testing.T) {
+	var count int32
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	defer stop()
+	client.backoff = 100 * time.Millisecond
+	client.maxBackoff = time.Second
+
+	// the retries stop at the deadline of the ctx, and the breaker is not blamed for it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.get(ctx, "/api/rpc/getBlockCount", nil)
+	if errors.Is(err, context.DeadlineExceeded) == false || count != 1 || time.Since(start) > time.Second {
+		t.Fatalf("the error is %v after %d attempts in %v", err, count, time.Since(start))
+	}
+	if client.breakerOf(config.TrackerHosts[0]).failures != 1 {
+		t.Fatalf("the breaker has %d failures, want 1", client.breakerOf(config.TrackerHosts[0]).failures)
+	}
+}
+
+func TestTrackerClientError(t *testing.T) {
+	var count int32
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if r.URL.Query().Get("hex") == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"msg":"error hex"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"msg":"missing-inputs","data":""}`))
+	})
+	defer stop()
+
+	// the error message of the tracker is not retried
+	_, err := client.get(context.Background(), "/api/rpc/testMemPoolAccept", nil)
+	trackerError := &TrackerError{}
+	if errors.As(err, &trackerError) == false || trackerError.StatusCode != http.StatusInternalServerError || err.Error() != "error hex" || count != 1 {
+		t.Fatalf("the error is %v after %d attempts", err, count)
+	}
+	if IsTrackerUnavailable(err) {
+		t.Fatal("the tracker is unavailable by the error of the request")
+	}
+	if _, err = client.getResult(context.Background(), "/api/rpc/sendRawTransaction", map[string][]string{"hex": {"00"}}); err == nil || err.Error() != "missing-inputs" {
+		t.Fatalf("the error of the empty data is %v", err)
+	}
+}
+
+func TestTrackerClientCircuitBreaker(t *testing.T) {
+	var count int32
+	var down int32 = 1
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"msg":"","data":"[]"}`))
+	})
+	defer stop()
+	client.breakerThreshold = 3
+	client.breakerCooldown = 50 * time.Millisecond
+
+	// the breaker opens after 3 failed attempts, the 4th one is not sent
+	_, err := client.get(context.Background(), "/api/rpc/listUnspent", nil)
+	if errors.Is(err, ErrCircuitOpen) == false || IsTrackerUnavailable(err) == false || count != 3 {
+		t.Fatalf("the error is %v after %d attempts", err, count)
+	}
+	if _, err = client.get(context.Background(), "/api/rpc/listUnspent", nil); errors.Is(err, ErrCircuitOpen) == false || count != 3 {
+		t.Fatalf("the request is sent by the open breaker: %v", err)
+	}
+
+	// the trial request after the cooldown closes the breaker
+	atomic.StoreInt32(&down, 0)
+	time.Sleep(60 * time.Millisecond)
+	if result, err := client.getResult(context.Background(), "/api/rpc/listUnspent", nil); err != nil || result != "[]" {
+		t.Fatalf("the result is %s %v after the cooldown", result, err)
+	}
+}
+
+func TestTrackerClientUnavailable(t *testing.T) {
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {})
+	defer stop()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+	config.TrackerHosts = []string{strings.TrimPrefix(closed.URL, "http://")}
+
+	// the connection to the closed server is refused
+	_, err := client.get(context.Background(), "/api/rpc/getBlockCount", nil)
+	if IsTrackerUnavailable(err) == false || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("the error is %v, want the unavailable tracker", err)
+	}
+}
+
+func TestTrackerClientFailover(t *testing.T) {
+	var primaryCount, backupCount int32
+	var down int32 = 1
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCount, 1)
+		if atomic.LoadInt32(&down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"msg":"primary","data":120}`))
+	})
+	defer stop()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&backupCount, 1)
+		_, _ = w.Write([]byte(`{"msg":"backup","data":121}`))
+	}))
+	defer backup.Close()
+	config.TrackerHosts = append(config.TrackerHosts, strings.TrimPrefix(backup.URL, "http://"))
+
+	// the request fails over to the backup without the backoff
+	response, err := client.get(context.Background(), "/api/rpc/getBlockCount", nil)
+	if err != nil || response.body.Get("msg").Str != "backup" || primaryCount != 1 || backupCount != 1 {
+		t.Fatalf("the response is %v %v, the primary is requested %d times", response, err, primaryCount)
+	}
+
+	// the unhealthy primary is the last resort
+	client.checkHealth()
+	statuses := client.statuses
+	if statuses[config.TrackerHosts[0]].Healthy || statuses[config.TrackerHosts[1]].Healthy == false {
+		t.Fatalf("the health of the trackers is %+v %+v", *statuses[config.TrackerHosts[0]], *statuses[config.TrackerHosts[1]])
+	}
+	if _, err = client.get(context.Background(), "/api/rpc/getBlockCount", nil); err != nil || primaryCount != 2 {
+		t.Fatalf("the unhealthy primary is requested %d times: %v", primaryCount, err)
+	}
+
+	// the requests go back to the primary after it is healthy again
+	atomic.StoreInt32(&down, 0)
+	client.checkHealth()
+	if response, err = client.get(context.Background(), "/api/rpc/getBlockCount", nil); err != nil || response.body.Get("msg").Str != "primary" {
+		t.Fatalf("the response is %v %v after the primary recovers", response, err)
+	}
+}
+
+func TestTrackerClientOnce(t *testing.T) {
+	var primaryCount, backupCount int32
+	client, stop := newTestTrackerClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&primaryCount, 1)
+		w.WriteHeader(http.StatusGatewayTimeout)
+	})
+	defer stop()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&backupCount, 1)
+		_, _ = w.Write([]byte(`{"msg":"","data":"txid"}`))
+	}))
+	defer backup.Close()
+	config.TrackerHosts = append(config.TrackerHosts, strings.TrimPrefix(backup.URL, "http://"))
+
+	// the primary may have sent the assets before it timed out, the send is neither retried nor failed over
+	if _, err := client.getResultOnce(context.Background(), "/api/rpc/omniSend", nil); err == nil || primaryCount != 1 || backupCount != 0 {
+		t.Fatalf("the error is %v, the primary is requested %d times and the backup %d times", err, primaryCount, backupCount)
+	}
+
+	// the canceled ctx of the caller stops the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.getResultOnce(ctx, "/api/rpc/omniSend", nil); errors.Is(err, context.Canceled) == false || primaryCount != 1 {
+		t.Fatalf("the error is %v after the ctx is canceled", err)
+	}
+}
@@ -1,596 +1,308 @@
 package conn2tracker
 
 import (
+	"context"
 	"errors"
-	"github.com/omnilaboratory/obd/config"
-	"github.com/omnilaboratory/obd/tool"
-	"github.com/tidwall/gjson"
-	"io/ioutil"
-	"log"
 	"net/http"
 	url2 "net/url"
 	"strconv"
-	"strings"
+	"sync"
 	"time"
+
+	"github.com/omnilaboratory/obd/tool"
 )
 
-var cacheBlock int
-var cacheBlockTime time.Time
-
-func GetBlockCount() (flag int) {
-	if cacheBlockTime.IsZero() == false {
-		if time.Now().Sub(cacheBlockTime).Minutes() > 1 {
-			cacheBlock = 0
-		}
-	}
-	if cacheBlock == 0 {
-		url := "http://" + config.TrackerHost + "/api/rpc/getBlockCount"
-		log.Println(url)
-		resp, err := http.Get(url)
-		if err != nil {
-			cacheBlock = 0
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode == http.StatusOK {
-			body, _ := ioutil.ReadAll(resp.Body)
-			cacheBlock = int(gjson.Get(string(body), "data").Int())
-			cacheBlockTime = time.Now()
-		}
-	}
-
-	return cacheBlock
+// trackerChainBackend queries the chain by the /api/rpc proxy of the tracker
+type trackerChainBackend struct{}
+
+var (
+	cacheLock      sync.Mutex
+	cacheBlock     int
+	cacheBlockTime time.Time
+	// conf target -> the fee rate and the time of it
+	cacheFeeRates = make(map[int32]cacheFeeRate)
+)
+
+type cacheFeeRate struct {
+	feeRate  float64
+	spanTime time.Time
 }
 
-func GetOmniBalance(address string, propertyId int) (balance float64) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getOmniBalance?address=" + address + "&propertyId=" + strconv.Itoa(propertyId)
-	log.Println(url)
-	resp, err := http.Get(url)
+func (backend *trackerChainBackend) GetBlockCount(ctx context.Context) (int, error) {
+	cacheLock.Lock()
+	if cacheBlock > 0 && time.Since(cacheBlockTime) < time.Minute {
+		count := cacheBlock
+		cacheLock.Unlock()
+		return count, nil
+	}
+	cacheLock.Unlock()
+
+	response, err := tracker.get(ctx, "/api/rpc/getBlockCount", nil)
 	if err != nil {
-		return 0
+		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Float()
+	count := int(response.data().Int())
+	if count == 0 {
+		return 0, &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: "wrong block count " + response.data().Raw}
 	}
-	return 0
+	cacheLock.Lock()
+	cacheBlock = count
+	cacheBlockTime = time.Now()
+	cacheLock.Unlock()
+	return count, nil
 }
 
-func ListReceivedByAddress(address string) (result string) {
+func (backend *trackerChainBackend) GetOmniBalance(ctx context.Context, address string, propertyId int) (float64, error) {
 	if tool.CheckIsAddress(address) == false {
-		return ""
+		return 0, errors.New("error address")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/listReceivedByAddress?address=" + address
-	resp, err := http.Get(url)
+	response, err := tracker.get(ctx, "/api/rpc/getOmniBalance", url2.Values{"address": {address}, "propertyId": {strconv.Itoa(propertyId)}})
 	if err != nil {
-		return ""
+		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+	if response.data().Exists() == false {
+		return 0, &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: response.body.Get("msg").Str}
 	}
-	return ""
+	return response.data().Float(), nil
 }
 
-func GetTransactionById(txid string) (result string) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getTransactionById?txid=" + txid
-	resp, err := http.Get(url)
-	if err != nil {
-		return ""
+func (backend *trackerChainBackend) ListReceivedByAddress(ctx context.Context, address string) (string, error) {
+	if tool.CheckIsAddress(address) == false {
+		return "", errors.New("error address")
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+	return tracker.getResult(ctx, "/api/rpc/listReceivedByAddress", url2.Values{"address": {address}})
+}
+
+func (backend *trackerChainBackend) GetTransactionById(ctx context.Context, txid string) (string, error) {
+	if tool.CheckIsString(&txid) == false {
+		return "", errors.New("wrong txid")
 	}
-	return ""
+	return tracker.getResult(ctx, "/api/rpc/getTransactionById", url2.Values{"txid": {txid}})
 }
-func ListUnspent(address string) (result string) {
+
+func (backend *trackerChainBackend) ListUnspent(ctx context.Context, address string) (string, error) {
 	if tool.CheckIsAddress(address) == false {
-		return ""
-	}
-	url := "http://" + config.TrackerHost + "/api/rpc/listUnspent?address=" + address
-	resp, err := http.Get(url)
-	if err != nil {
-		return ""
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+		return "", errors.New("error address")
 	}
-	return ""
+	return tracker.getResult(ctx, "/api/rpc/listUnspent", url2.Values{"address": {address}})
 }
 
-var smartFeeSpanTime time.Time
-var cacheFeeRate float64
-
-func EstimateSmartFee(confTarget int32) (result float64) {
-	if smartFeeSpanTime.IsZero() == false {
-		if time.Now().Sub(smartFeeSpanTime).Minutes() > 10 {
-			cacheFeeRate = 0
-		}
-	}
-	if cacheFeeRate == 0 {
-		url := "http://" + config.TrackerHost + "/api/rpc/estimateSmartFee?confTarget=" + strconv.Itoa(int(confTarget))
-		resp, err := http.Get(url)
-		if err != nil {
-			return 0
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode == http.StatusOK {
-			body, _ := ioutil.ReadAll(resp.Body)
-			cacheFeeRate = gjson.Get(string(body), "data").Float()
-			smartFeeSpanTime = time.Now()
-		}
-	}
-	return cacheFeeRate
-}
+func (backend *trackerChainBackend) EstimateSmartFee(ctx context.Context, confTarget int32) (float64, error) {
+	cacheLock.Lock()
+	cache, ok := cacheFeeRates[confTarget]
+	cacheLock.Unlock()
+	if ok && time.Since(cache.spanTime) < 10*time.Minute {
+		return cache.feeRate, nil
+	}
 
-func CreateRawTransaction(data string) (result string) {
-	url := "http://" + config.TrackerHost + "/api/rpc/createRawTransaction"
-	request, _ := http.NewRequest("POST", url, strings.NewReader(data))
-	resp, err := http.DefaultClient.Do(request)
+	response, err := tracker.get(ctx, "/api/rpc/estimateSmartFee", url2.Values{"confTarget": {strconv.Itoa(int(confTarget))}})
 	if err != nil {
-		return ""
+		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+	feeRate := response.data().Float()
+	if feeRate == 0 {
+		return 0, &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: "fail to estimate the smart fee"}
 	}
-	return ""
+	cacheLock.Lock()
+	cacheFeeRates[confTarget] = cacheFeeRate{feeRate: feeRate, spanTime: time.Now()}
+	cacheLock.Unlock()
+	return feeRate, nil
 }
 
-func OmniGetAllBalancesByAddress(address string) (result string) {
-	if tool.CheckIsAddress(address) == false {
-		return ""
-	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniGetAllBalancesForAddress?address=" + address
-	log.Println(url)
-	resp, err := http.Get(url)
+func (backend *trackerChainBackend) CreateRawTransaction(ctx context.Context, data string) (string, error) {
+	response, err := tracker.post(ctx, "/api/rpc/createRawTransaction", data)
 	if err != nil {
-		return ""
+		return "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+	return response.result()
+}
+
+func OmniGetAllBalancesByAddress(address string) (string, error) {
+	if tool.CheckIsAddress(address) == false {
+		return "", errors.New("error address")
 	}
-	return ""
+	return tracker.getResult(context.Background(), "/api/rpc/omniGetAllBalancesForAddress", url2.Values{"address": {address}})
 }
 
-func OmniGetBalancesForAddress(address string, propertyId int) (result string) {
+func (backend *trackerChainBackend) OmniGetBalancesForAddress(ctx context.Context, address string, propertyId int) (*OmniBalance, error) {
 	if tool.CheckIsAddress(address) == false {
-		return ""
+		return nil, errors.New("error address")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniGetBalancesForAddress?address=" + address + "&propertyId=" + strconv.Itoa(propertyId)
-	resp, err := http.Get(url)
+	result, err := tracker.getResult(ctx, "/api/rpc/omniGetBalancesForAddress", url2.Values{"address": {address}, "propertyId": {strconv.Itoa(propertyId)}})
 	if err != nil {
-		return ""
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
+		return nil, err
 	}
-	return ""
+	return parseOmniBalance(result)
 }
 
-func TestMemPoolAccept(hex string) (result string) {
+func (backend *trackerChainBackend) TestMemPoolAccept(ctx context.Context, hex string) (*MempoolAcceptResult, error) {
 	if tool.CheckIsString(&hex) == false {
-		return ""
+		return nil, errors.New("error hex")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/testMemPoolAccept?hex=" + hex
-	resp, err := http.Get(url)
+	result, err := tracker.getResult(ctx, "/api/rpc/testMemPoolAccept", url2.Values{"hex": {hex}})
 	if err != nil {
-		return ""
+		return nil, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Str
-	}
-	return ""
+	return parseMempoolAccept(result)
 }
 
-func SendRawTransaction(hex string) (result string, err error) {
+func (backend *trackerChainBackend) SendRawTransaction(ctx context.Context, hex string) (string, error) {
 	if tool.CheckIsString(&hex) == false {
 		return "", errors.New("error hex")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/sendRawTransaction?hex=" + hex
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error hex")
+	return tracker.getResult(ctx, "/api/rpc/sendRawTransaction", url2.Values{"hex": {hex}})
 }
 
-func OmniDecodeTransaction(hex string) (result string, err error) {
+func (backend *trackerChainBackend) OmniDecodeTransaction(ctx context.Context, hex string) (string, error) {
 	if tool.CheckIsString(&hex) == false {
 		return "", errors.New("error hex")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniDecodeTransaction?hex=" + hex
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error hex")
+	return tracker.getResult(ctx, "/api/rpc/omniDecodeTransaction", url2.Values{"hex": {hex}})
 }
 
-func OmniListTransactions(address string) (result string, err error) {
+func (backend *trackerChainBackend) OmniListTransactions(ctx context.Context, address string) (string, error) {
 	if tool.CheckIsAddress(address) == false {
 		return "", errors.New("error address")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniListTransactions?address=" + address
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(ctx, "/api/rpc/omniListTransactions", url2.Values{"address": {address}})
 }
 
-func OmniGetProperty(propertyId int64) (result string, err error) {
+func (backend *trackerChainBackend) OmniGetProperty(ctx context.Context, propertyId int64) (string, error) {
 	if propertyId < 1 {
 		return "", errors.New("error propertyId")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniGetProperty?propertyId=" + strconv.Itoa(int(propertyId))
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(ctx, "/api/rpc/omniGetProperty", url2.Values{"propertyId": {strconv.Itoa(int(propertyId))}})
 }
 
-func OmniGetTransaction(txid string) (result string, err error) {
-	if tool.CheckIsString(&txid) == false && len(txid) != 64 {
+func (backend *trackerChainBackend) OmniGetTransaction(ctx context.Context, txid string) (string, error) {
+	if tool.CheckIsString(&txid) == false || len(txid) != 64 {
 		return "", errors.New("wrong txid")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/omniGettransaction?txid=" + txid
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(ctx, "/api/rpc/omniGettransaction", url2.Values{"txid": {txid}})
 }
-func GetBalanceByAddress(address string) (result float64, err error) {
+
+func (backend *trackerChainBackend) GetBalanceByAddress(ctx context.Context, address string) (float64, error) {
 	if tool.CheckIsAddress(address) == false {
 		return 0.0, errors.New("error address")
 	}
-	url := "http://" + config.TrackerHost + "/api/rpc/getBalanceByAddress?address=" + address
-	resp, err := http.Get(url)
+	response, err := tracker.get(ctx, "/api/rpc/getBalanceByAddress", url2.Values{"address": {address}})
 	if err != nil {
 		return 0.0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Float(), err
+	if msg := response.body.Get("msg").Str; msg != "" || response.data().Str == "" {
+		return 0.0, &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: msg}
 	}
-	return 0.0, errors.New("error result")
+	return response.data().Float(), nil
 }
 
-func GetNewAddress(label string) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getNewAddress?label=" + label
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+// the calls below change the wallet of the tracker or send the assets, they are requested once without the failover
+func GetNewAddress(ctx context.Context, label string) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/getNewAddress", url2.Values{"label": {label}})
 }
 
-func OmniSend(fromAddress, toAddress string, propertyId int, amount float64) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/omniSend?fromAddress=" + fromAddress +
-		"&toAddress=" + toAddress +
-		"&propertyId=" + strconv.Itoa(propertyId) +
-		"&amount=" + tool.FloatToString(amount, 8)
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+func OmniSend(ctx context.Context, fromAddress, toAddress string, propertyId int, amount float64) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/omniSend", url2.Values{
+		"fromAddress": {fromAddress},
+		"toAddress":   {toAddress},
+		"propertyId":  {strconv.Itoa(propertyId)},
+		"amount":      {tool.FloatToString(amount, 8)},
+	})
 }
 
 func OmniListProperties() (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/omniListProperties"
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(context.Background(), "/api/rpc/omniListProperties", nil)
 }
 
-func OmniSendIssuanceFixed(fromAddress string, ecosystem int, divisibleType int, name string, data string, amount float64) (result string, err error) {
+func OmniSendIssuanceFixed(ctx context.Context, fromAddress string, ecosystem int, divisibleType int, name string, data string, amount float64) (result string, err error) {
 	if amount < 1 {
 		return "", errors.New("amount must be more than 1")
 	}
-
-	url := "http://" + config.TrackerHost + "/api/rpc/omniSendIssuanceFixed?fromAddress=" + fromAddress +
-		"&ecosystem=" + strconv.Itoa(ecosystem) +
-		"&divisibleType=" + strconv.Itoa(divisibleType) +
-		"&name=" + name +
-		"&data=" + data +
-		"&amount=" + tool.FloatToString(amount, 8)
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResultOnce(ctx, "/api/rpc/omniSendIssuanceFixed", url2.Values{
+		"fromAddress":   {fromAddress},
+		"ecosystem":     {strconv.Itoa(ecosystem)},
+		"divisibleType": {strconv.Itoa(divisibleType)},
+		"name":          {name},
+		"data":          {data},
+		"amount":        {tool.FloatToString(amount, 8)},
+	})
 }
 
-func OmniSendIssuanceManaged(fromAddress string, ecosystem int, divisibleType int, name string, data string) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/omniSendIssuanceManaged?fromAddress=" + fromAddress +
-		"&ecosystem=" + strconv.Itoa(ecosystem) +
-		"&divisibleType=" + strconv.Itoa(divisibleType) +
-		"&name=" + name +
-		"&data=" + data
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+func OmniSendIssuanceManaged(ctx context.Context, fromAddress string, ecosystem int, divisibleType int, name string, data string) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/omniSendIssuanceManaged", url2.Values{
+		"fromAddress":   {fromAddress},
+		"ecosystem":     {strconv.Itoa(ecosystem)},
+		"divisibleType": {strconv.Itoa(divisibleType)},
+		"name":          {name},
+		"data":          {data},
+	})
 }
 
-func OmniSendGrant(fromAddress string, propertyId int64, amount float64, memo string) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/omniSendGrant?fromAddress=" + fromAddress +
-		"&propertyId=" + strconv.Itoa(int(propertyId)) +
-		"&memo=" + memo +
-		"&amount=" + tool.FloatToString(amount, 8)
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+func OmniSendGrant(ctx context.Context, fromAddress string, propertyId int64, amount float64, memo string) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/omniSendGrant", url2.Values{
+		"fromAddress": {fromAddress},
+		"propertyId":  {strconv.Itoa(int(propertyId))},
+		"memo":        {memo},
+		"amount":      {tool.FloatToString(amount, 8)},
+	})
 }
 
-func OmniSendRevoke(fromAddress string, propertyId int64, amount float64, memo string) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/omniSendRevoke?fromAddress=" + fromAddress +
-		"&propertyId=" + strconv.Itoa(int(propertyId)) +
-		"&memo=" + memo +
-		"&amount=" + tool.FloatToString(amount, 8)
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+func OmniSendRevoke(ctx context.Context, fromAddress string, propertyId int64, amount float64, memo string) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/omniSendRevoke", url2.Values{
+		"fromAddress": {fromAddress},
+		"propertyId":  {strconv.Itoa(int(propertyId))},
+		"memo":        {memo},
+		"amount":      {tool.FloatToString(amount, 8)},
+	})
 }
 
-func BtcSignRawTransactionFromJson(data string) (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/btcSignRawTransactionFromJson?data=" + url2.QueryEscape(data)
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+func BtcSignRawTransactionFromJson(ctx context.Context, data string) (result string, err error) {
+	return tracker.getResultOnce(ctx, "/api/rpc/btcSignRawTransactionFromJson", url2.Values{"data": {data}})
 }
 
 func GetMiningInfo() (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getMiningInfo"
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(context.Background(), "/api/rpc/getMiningInfo", nil)
 }
+
 func GetNetworkInfo() (result string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getNetworkInfo"
-	log.Println(url)
-	resp, err := http.Get(url)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "data").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "data").Str, err
-	}
-	return "", errors.New("error result")
+	return tracker.getResult(context.Background(), "/api/rpc/getNetworkInfo", nil)
 }
-func GetChainNodeType() (chainNodeType, trackerP2pAddress string, err error) {
-	url := "http://" + config.TrackerHost + "/api/rpc/getChainNodeType"
-	resp, err := http.Get(url)
+
+// GetChainNodeType the chain and the p2p addresses of the tracker
+func GetChainNodeType(host string) (chainNodeType, trackerP2pAddress string, err error) {
+	response, err := tracker.getFrom(context.Background(), host, "/api/rpc/getChainNodeType", nil)
 	if err != nil {
 		return "", "", err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == http.StatusOK {
-		body, _ := ioutil.ReadAll(resp.Body)
-		err = nil
-		if gjson.Get(string(body), "chainNodeType").Str == "" {
-			err = errors.New(gjson.Get(string(body), "msg").Str)
-		}
-		return gjson.Get(string(body), "chainNodeType").Str, gjson.Get(string(body), "trackerP2pAddress").Str, err
+	chainNodeType = response.body.Get("chainNodeType").Str
+	if chainNodeType == "" {
+		return "", "", &TrackerError{Path: response.path, StatusCode: http.StatusOK, Msg: response.body.Get("msg").Str}
 	}
-	return "", "", errors.New("error result")
+	return chainNodeType, response.body.Get("trackerP2pAddress").Str, nil
 }
 
-func GetChannelState(channelId string) (flag int) {
-	url := "http://" + config.TrackerHost + "/api/v1/getChannelState?channelId=" + channelId
-	resp, err := http.Get(url)
+// GetChannelState the state of the channel on the tracker, 0 if the tracker does not know the channel
+func GetChannelState(channelId string) (state int, err error) {
+	response, err := tracker.get(context.Background(), "/api/v1/getChannelState", url2.Values{"channelId": {channelId}})
 	if err != nil {
-		return 0
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return int(gjson.Get(string(body), "data").Get("state").Int())
+		return 0, err
 	}
-	return 0
+	return int(response.data().Get("state").Int()), nil
 }
 
-func GetUserState(p2pNodeId, userId string) (flag int) {
-	url := "http://" + config.TrackerHost + "/api/v1/getUserState?userId=" + userId + "&p2pNodeId=" + p2pNodeId
-	log.Println(url)
-	resp, err := http.Get(url)
+// GetUserState 1 if the user is online on the obd node, 0 if not
+func GetUserState(p2pNodeId, userId string) (state int, err error) {
+	response, err := tracker.get(context.Background(), "/api/v1/getUserState", url2.Values{"userId": {userId}, "p2pNodeId": {p2pNodeId}})
 	if err != nil {
-		return 0
+		return 0, err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		log.Println(string(body))
-		return int(gjson.Get(string(body), "data").Get("state").Int())
-	}
-	return 0
+	return int(response.data().Get("state").Int()), nil
 }
 
-func GetUserP2pNodeId(userId string) (p2pNodeId string) {
-	url := "http://" + config.TrackerHost + "/api/v1/getUserP2pNodeId?userId=" + userId
-	//log.Println(url)
-	resp, err := http.Get(url)
+// GetUserP2pNodeId the p2p node id of the obd which the user is online on, empty if the user is offline
+func GetUserP2pNodeId(userId string) (p2pNodeId string, err error) {
+	response, err := tracker.get(context.Background(), "/api/v1/getUserP2pNodeId", url2.Values{"userId": {userId}})
 	if err != nil {
-		return ""
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		body, _ := ioutil.ReadAll(resp.Body)
-		return gjson.Get(string(body), "data").Get("info").String()
+		return "", err
 	}
-	return ""
+	return response.data().Get("info").String(), nil
 }
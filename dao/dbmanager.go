@@ -4,13 +4,21 @@ import (
 	"github.com/asdine/storm"
 	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/tool"
+	bolt "go.etcd.io/bbolt"
 	"log"
+	"os"
+	"strings"
+	"time"
 )
 
 //storm  doc  https://github.com/asdine/storm#getting-started
 
+const CredentialDBName = "credentials.db"
+
 type dbManager struct {
 	Db *storm.DB //db
+	// the api credentials, which do not depend on the chain
+	CredentialDb *storm.DB
 }
 
 var DBService dbManager
@@ -29,6 +37,42 @@ func (manager dbManager) GetGlobalDB() (*storm.DB, error) {
 	return DBService.Db, nil
 }
 
+// GetCredentialDB credentials.db in the data directory, it fails after one second if another obd is using it
+func (manager dbManager) GetCredentialDB() (*storm.DB, error) {
+	if DBService.CredentialDb == nil {
+		_ = tool.PathExistsAndCreate(config.DataDirectory)
+		db, e := storm.Open(config.DataDirectory+"/"+CredentialDBName, storm.BoltOptions(0600, &bolt.Options{Timeout: time.Second}))
+		if e != nil {
+			log.Println("open credential db fail")
+			return nil, e
+		}
+		DBService.CredentialDb = db
+	}
+	return DBService.CredentialDb, nil
+}
+
+// CloseGlobalDB close obdserver.db and credentials.db on shutdown
+func (manager dbManager) CloseGlobalDB() (err error) {
+	if DBService.CredentialDb != nil {
+		err = DBService.CredentialDb.Close()
+		DBService.CredentialDb = nil
+	}
+	if DBService.Db != nil {
+		err = DBService.Db.Close()
+		DBService.Db = nil
+	}
+	return err
+}
+
+// ExistUserDB the user has logged in this obd, so its db is created
+func (manager dbManager) ExistUserDB(peerId string) bool {
+	if len(peerId) == 0 || strings.ContainsAny(peerId, "/\\.") {
+		return false
+	}
+	_, err := os.Stat(config.DataDirectory + "/" + config.ChainNodeType + "/user_" + peerId + ".db")
+	return err == nil
+}
+
 func (manager dbManager) GetUserDB(peerId string) (*storm.DB, error) {
 	_dir := config.DataDirectory + "/" + config.ChainNodeType
 	_ = tool.PathExistsAndCreate(_dir)
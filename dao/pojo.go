@@ -195,6 +195,13 @@ type CommitmentTransaction struct {
 	HTLCMultiAddressScriptPubKey string  `json:"htlc_multi_address_script_pub_key,omitempty"`
 	AmountToHtlc                 float64 `json:"amount_to_htlc,omitempty"`
 	HtlcAmountToPayee            float64 `json:"htlc_amount_to_payee"`
+	HtlcTotalAmount              float64 `json:"htlc_total_amount,omitempty"`   //多路径支付的总金额
+	HtlcOnion                    string  `json:"htlc_onion,omitempty"`          //the onion for the next hop, peeled by the receiver of the htlc
+	HtlcForwardAmount            float64 `json:"htlc_forward_amount,omitempty"` //the amount to the next hop in the onion
+	HtlcForwardCltv              int     `json:"htlc_forward_cltv,omitempty"`   //the cltv expiry to the next hop in the onion
+	HtlcOnionSecret              string  `json:"htlc_onion_secret,omitempty"`   //the shared secret of the receiver of the htlc with the payer
+	HtlcOnionSecrets             string  `json:"htlc_onion_secrets,omitempty"`  //the shared secrets of the payer with the hops of the onion
+	HtlcFailReason               string  `json:"htlc_fail_reason,omitempty"`    //the failure of the htlc which comes back from the next hops
 	HtlcTxHex                    string  `json:"htlc_tx_hex,omitempty"`
 	HTLCTxid                     string  `json:"htlc_txid,omitempty"`
 	HtlcMemo                     string  `json:"htlc_memo,omitempty"`
@@ -10,6 +10,8 @@ type InvoiceInfo struct {
 	Detail   bean.HtlcRequestInvoice `json:"detail"`
 	Invoice  string                  `json:"invoice"`
 	CreateAt time.Time               `json:"create_at"`
+	// the h of the detail, the invoice is found by it
+	H string `storm:"index" json:"h"`
 }
 
 type HtlcHAndRImage struct {
@@ -139,3 +141,47 @@ const (
 	NS_Finish NormalState = 20
 	NS_Refuse NormalState = 30
 )
+
+type PaymentState string
+
+const (
+	PaymentState_InFlight  PaymentState = "in_flight"
+	PaymentState_Succeeded PaymentState = "succeeded"
+	PaymentState_Failed    PaymentState = "failed"
+)
+
+// the htlc payment of the payer by the paths of the tracker, it is retried by the other paths when an attempt fails
+type HtlcPayment struct {
+	Id                  int           `storm:"id,increment" json:"id" `
+	H                   string        `storm:"index" json:"h"`
+	RecipientUserPeerId string        `json:"recipient_user_peer_id"`
+	PropertyId          int64         `json:"property_id"`
+	Amount              float64       `json:"amount"`
+	MaxParts            int           `json:"max_parts,omitempty"`
+	MaxAttempts         int           `json:"max_attempts"`
+	FeeLimit            float64       `json:"fee_limit"`
+	Deadline            time.Time     `json:"deadline"`
+	ExcludedChannels    []string      `json:"excluded_channels"`
+	ExcludedPeers       []string      `json:"excluded_peers"`
+	Attempts            []HtlcAttempt `json:"attempts"`
+	State               PaymentState  `storm:"index" json:"state"`
+	R                   string        `json:"r,omitempty"`
+	FailureReason       string        `json:"failure_reason,omitempty"`
+	CreateAt            time.Time     `json:"create_at"`
+	FinishAt            time.Time     `json:"finish_at"`
+}
+
+// an attempt of the payment by a path, the path of a multi-path payment has the routes of all the parts
+type HtlcAttempt struct {
+	Path            string       `json:"path"`
+	HopKeys         string       `json:"hop_keys,omitempty"`    // the onion public keys of the hops, in the format of the path
+	HopAmounts      string       `json:"hop_amounts,omitempty"` // the amounts of the htlcs on the channels, in the format of the path
+	Fee             float64      `json:"fee"`
+	State           PaymentState `json:"state"`
+	FailedChannelId string       `json:"failed_channel_id,omitempty"`
+	FailedPeerId    string       `json:"failed_peer_id,omitempty"`
+	Error           string       `json:"error,omitempty"`
+	CreateAt        time.Time    `json:"create_at"`
+	// the routes of the parts of a multi-path attempt which are back to the payer, it fails when all of them are back
+	ClosedParts []string `json:"closed_parts,omitempty"`
+}
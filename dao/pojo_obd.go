@@ -17,3 +17,57 @@ type ObdConfig struct {
 	InitHashCode    string `json:"init_hash_code"`
 	AdminLoginToken string `json:"admin_login_token"`
 }
+
+// the root key of the api credentials, in credentials.db
+type CredentialRootKey struct {
+	Id       int       `storm:"id,increment" json:"id" `
+	RootKey  string    `json:"root_key"`
+	CreateAt time.Time `json:"create_at"`
+}
+
+// the api credentials baked by obd, a credential is revoked by its id
+type ApiCredential struct {
+	Id       string    `storm:"id" json:"id"`
+	Caveats  []string  `json:"caveats"`
+	Note     string    `json:"note"`
+	Revoked  bool      `json:"revoked"`
+	CreateAt time.Time `json:"create_at"`
+	RevokeAt time.Time `json:"revoke_at"`
+}
+
+// the user peer id changed from the legacy host based one to the wallet based one
+type UserPeerIdMigration struct {
+	Id        int    `storm:"id,increment" json:"id" `
+	OldPeerId string `json:"old_peer_id"`
+	NewPeerId string `json:"new_peer_id"`
+	// the node id of the obd which created the user db
+	OldObdNodeId string `json:"old_obd_node_id"`
+	// the hosts of the trackers which acknowledged the migration
+	NoticedTrackers []string  `json:"noticed_trackers"`
+	CreateAt        time.Time `json:"create_at"`
+}
+
+type OutboxState string
+
+const (
+	OutboxState_Queued    OutboxState = "queued"
+	OutboxState_Delivered OutboxState = "delivered"
+	OutboxState_Failed    OutboxState = "failed"
+	OutboxState_Expired   OutboxState = "expired"
+)
+
+// the p2p message to the offline user, it is delivered at the next login of the user
+type OutboxMessage struct {
+	Id                  int         `storm:"id,increment" json:"id" `
+	RecipientUserPeerId string      `storm:"index" json:"recipient_user_peer_id"`
+	RecipientNodePeerId string      `json:"recipient_node_peer_id"`
+	SenderUserPeerId    string      `json:"sender_user_peer_id"`
+	SenderNodePeerId    string      `json:"sender_node_peer_id"`
+	MsgType             int         `json:"msg_type"`
+	Status              bool        `json:"status"`
+	Data                string      `json:"data"`
+	State               OutboxState `storm:"index" json:"state"`
+	CreateAt            time.Time   `json:"create_at"`
+	ExpireAt            time.Time   `json:"expire_at"`
+	FinishAt            time.Time   `json:"finish_at"`
+}
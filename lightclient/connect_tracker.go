@@ -2,6 +2,7 @@ package lightclient
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
 	"github.com/gorilla/websocket"
@@ -19,78 +20,265 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
-var conn *websocket.Conn
-var ticker3m *time.Ticker
-var isReset = true
+var (
+	// the websockets to the trackers in the priority order of config.TrackerHosts
+	trackerConns []*trackerConn
+)
+
+const (
+	trackerReconnectMinBackoff = 2 * time.Second
+	trackerReconnectMaxBackoff = 2 * time.Minute
+	trackerWriteTimeout        = 10 * time.Second
+	trackerHealthCheckInterval = time.Minute
+	chainNodeTypeFileName      = "chain_node_type"
+)
+
+// trackerConn the websocket to one tracker, it is reconnected in the background after it is lost
+type trackerConn struct {
+	host string
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	lastError string
+
+	// gorilla websocket supports only one concurrent writer
+	writeLock sync.Mutex
+	reconnect chan struct{}
+}
+
+func newTrackerConn(host string) *trackerConn {
+	return &trackerConn{host: host, reconnect: make(chan struct{}, 1)}
+}
+
+func (tracker *trackerConn) current() *websocket.Conn {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	return tracker.conn
+}
+
+func (tracker *trackerConn) setError(err error) {
+	tracker.mu.Lock()
+	tracker.lastError = err.Error()
+	tracker.mu.Unlock()
+}
+
+// getObdNodeInfo the node info with the state of the tracker connections
+func getObdNodeInfo() bean.ObdNodeInfo {
+	info := bean.CurrObdNodeInfo
+	statuses := make(map[string]conn2tracker.TrackerStatus)
+	for _, status := range conn2tracker.GetTrackerStatuses() {
+		statuses[status.Host] = status
+	}
+	info.Trackers = make([]bean.TrackerNodeInfo, 0, len(trackerConns))
+	for _, tracker := range trackerConns {
+		tracker.mu.Lock()
+		trackerInfo := bean.TrackerNodeInfo{
+			Host:      tracker.host,
+			Connected: tracker.conn != nil,
+			Healthy:   statuses[tracker.host].Healthy,
+			LastError: tracker.lastError,
+		}
+		tracker.mu.Unlock()
+		if trackerInfo.LastError == "" {
+			trackerInfo.LastError = statuses[tracker.host].LastError
+		}
+		if trackerInfo.Connected && info.TrackerConnected == false {
+			info.TrackerHost = tracker.host
+			info.TrackerConnected = true
+		}
+		info.Trackers = append(info.Trackers, trackerInfo)
+	}
+	return info
+}
 
+// ConnectToTracker connect to the trackers at the start of obd. If no tracker is reachable, obd starts on the chain
+// of its omnicore backend or of the last connection, serves the local queries and the rsmc payments of the existing
+// channels, and reconnects to the trackers in the background.
 func ConnectToTracker() (err error) {
+	if service.TrackerChan == nil {
+		service.TrackerChan = make(chan []byte)
+	}
 
-	u := url.URL{Scheme: "ws", Host: config.TrackerHost, Path: "/ws"}
-	log.Printf("begin to connect to tracker: %s", u.String())
+	connected := false
+	trackerConns = make([]*trackerConn, 0, len(config.TrackerHosts))
+	for _, host := range config.TrackerHosts {
+		tracker := newTrackerConn(host)
+		trackerConns = append(trackerConns, tracker)
+		err = tracker.dial()
+		if err != nil {
+			log.Println("fail to connect to tracker", host, err)
+			tracker.setError(err)
+			tracker.notifyDisconnected()
+		} else {
+			connected = true
+		}
+	}
 
-	conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
+	if connected == false {
+		config.ChainNodeType, err = loadChainNodeType()
+		if err != nil {
+			log.Println("obd does not know its chain without tracker:", err)
+			return err
+		}
+		err = StartP2PNode()
+		if err != nil {
+			return err
+		}
+		log.Println("obd starts without tracker in " + config.ChainNodeType + ", and reconnects to it in the background")
+	}
+
+	startSchedule()
+	for _, tracker := range trackerConns {
+		go tracker.keepConnection()
+	}
+	conn2tracker.StartTrackerHealthCheck(trackerHealthCheckInterval)
+	return nil
+}
+
+// dial check the chain of the tracker, and then open the websocket to it
+func (tracker *trackerConn) dial() error {
+	chainNodeType, trackerP2pAddress, err := conn2tracker.GetChainNodeType(tracker.host)
 	if err != nil {
-		log.Println("fail to dial tracker:", err)
 		return err
 	}
-
-	chainNodeType, trackerP2pAddress, err := conn2tracker.GetChainNodeType()
+	if hostNode != nil && chainNodeType != config.ChainNodeType {
+		return errors.New("the tracker is on the chain " + chainNodeType + ", but obd runs on " + config.ChainNodeType)
+	}
+	err = conn2tracker.CheckChainBackend(chainNodeType)
 	if err != nil {
 		return err
 	}
-	config.ChainNodeType = chainNodeType
-	config.BootstrapPeers, _ = config.StringsToAddrs(strings.Split(trackerP2pAddress, ","))
 
-	err = StartP2PNode()
+	u := url.URL{Scheme: "ws", Host: tracker.host, Path: "/ws"}
+	log.Printf("begin to connect to tracker: %s", u.String())
+	wsConn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
 	if err != nil {
+		log.Println("fail to dial tracker:", err)
 		return err
 	}
 
-	if service.TrackerChan == nil {
-		service.TrackerChan = make(chan []byte)
+	bootstrapPeers, _ := config.StringsToAddrs(strings.Split(trackerP2pAddress, ","))
+	if hostNode == nil {
+		config.ChainNodeType = chainNodeType
+		saveChainNodeType(chainNodeType)
+		config.BootstrapPeers = bootstrapPeers
+		err = StartP2PNode()
+		if err != nil {
+			_ = wsConn.Close()
+			return err
+		}
+	} else {
+		connectBootstrapPeers(bootstrapPeers)
 	}
 
-	if isReset {
-		go readDataFromWs()
+	tracker.mu.Lock()
+	tracker.conn = wsConn
+	tracker.mu.Unlock()
+	go tracker.readDataFromWs(wsConn)
+	return nil
+}
+
+func (tracker *trackerConn) notifyDisconnected() {
+	select {
+	case tracker.reconnect <- struct{}{}:
+	default:
 	}
+}
 
-	if ticker3m == nil {
-		startSchedule()
+// disconnect close the websocket if it is the current one, and reconnect to the tracker
+func (tracker *trackerConn) disconnect(wsConn *websocket.Conn, err error) {
+	tracker.mu.Lock()
+	if tracker.conn != wsConn {
+		tracker.mu.Unlock()
+		return
 	}
-	return nil
+	tracker.conn = nil
+	tracker.lastError = err.Error()
+	tracker.mu.Unlock()
+
+	_ = wsConn.Close()
+	log.Println("socket to tracker", tracker.host, "get err:", err)
+	tracker.notifyDisconnected()
+}
+
+// keepConnection reconnect to the tracker with the exponential backoff after the connection is lost.
+// The tracker replies MsgType_Tracker_Connect_301 to the new connection, and then the users and channels are synced by SynData.
+func (tracker *trackerConn) keepConnection() {
+	for range tracker.reconnect {
+		backoff := trackerReconnectMinBackoff
+		for {
+			time.Sleep(backoff)
+			err := tracker.dial()
+			if err == nil {
+				log.Println("reconnect to tracker " + tracker.host)
+				break
+			}
+			tracker.setError(err)
+			backoff *= 2
+			if backoff > trackerReconnectMaxBackoff {
+				backoff = trackerReconnectMaxBackoff
+			}
+			log.Println("fail to reconnect to tracker", tracker.host, err, ", retry in", backoff)
+		}
+	}
+}
+
+// the chain of the omnicore backend, or the one of the last connection to the tracker
+func loadChainNodeType() (string, error) {
+	chainNodeType, err := conn2tracker.GetBackendChainNodeType()
+	if err == nil {
+		return chainNodeType, nil
+	}
+	content, err := ioutil.ReadFile(config.DataDirectory + "/" + chainNodeTypeFileName)
+	if err != nil {
+		return "", err
+	}
+	chainNodeType = strings.TrimSpace(string(content))
+	if chainNodeType == "" {
+		return "", errors.New("empty chain node type")
+	}
+	return chainNodeType, nil
+}
+
+func saveChainNodeType(chainNodeType string) {
+	err := ioutil.WriteFile(config.DataDirectory+"/"+chainNodeTypeFileName, []byte(chainNodeType), 0644)
+	if err != nil {
+		log.Println("fail to save the chain node type:", err)
+	}
+}
+
+// write the message to the tracker
+func (tracker *trackerConn) write(msg []byte) error {
+	wsConn := tracker.current()
+	if wsConn == nil {
+		return errors.New("obd is disconnected from tracker " + tracker.host)
+	}
+	tracker.writeLock.Lock()
+	_ = wsConn.SetWriteDeadline(time.Now().Add(trackerWriteTimeout))
+	err := wsConn.WriteMessage(websocket.TextMessage, msg)
+	tracker.writeLock.Unlock()
+	if err != nil {
+		tracker.disconnect(wsConn, err)
+	}
+	return err
 }
 
-func readDataFromWs() {
-	isReset = false
+func (tracker *trackerConn) readDataFromWs(wsConn *websocket.Conn) {
 	ticker := time.NewTicker(time.Minute * 2)
 	defer ticker.Stop()
 
-	defer func(ticker *time.Ticker) {
-		if r := recover(); r != nil {
-			log.Println("tracker goroutine recover")
-			ticker.Stop()
-			conn = nil
-			isReset = true
-		}
-	}(ticker)
-	defer conn.Close()
-
 	// read message
+	closed := make(chan struct{})
 	go func() {
+		defer close(closed)
 		for {
-			if conn == nil {
-				isReset = true
-				return
-			}
-
-			_, message, err := conn.ReadMessage()
+			_, message, err := wsConn.ReadMessage()
 			if err != nil {
-				isReset = true
-				log.Println("socket to tracker get err:", err)
-				conn = nil
+				tracker.disconnect(wsConn, err)
 				return
 			}
 
@@ -106,66 +294,96 @@ func readDataFromWs() {
 					requestMessage.Data = ""
 					if v.Kind() == reflect.Map {
 						dataMap := replyMessage.Result.(map[string]interface{})
+						if onPathReply(tracker.host, dataMap["senderPeerId"].(string), dataMap["h"].(string), dataMap["path"].(string)) == false {
+							break
+						}
 						requestMessage.RecipientUserPeerId = dataMap["senderPeerId"].(string)
 						requestMessage.Data = dataMap["h"].(string) + "_" + dataMap["path"].(string) + "_" + tool.FloatToString(dataMap["amount"].(float64), 8)
+						hopKeys, _ := dataMap["hop_keys"].(string)
+						hopAmounts, _ := dataMap["hop_amounts"].(string)
+						if len(hopKeys) > 0 || len(hopAmounts) > 0 {
+							requestMessage.Data += "_" + hopKeys
+						}
+						if len(hopAmounts) > 0 {
+							requestMessage.Data += "_" + hopAmounts
+						}
 						//requestMessage.Data = dataMap["h"].(string) + "_" + dataMap["path"].(string)
 					}
 					htlcTrackerDealModule(requestMessage)
 				case enum.MsgType_Tracker_Connect_301:
-					config.ChainNodeType = replyMessage.Result.(string)
-					go SynData()
+					// the chain of the tracker is checked by dial, sync the data of the new connection
+					go tracker.SynData()
+				case enum.MsgType_Tracker_UpdateUserPeerId_354:
+					// the tracker acknowledged the migrations, which are not sent to it again
+					migrations := make([]bean.UserPeerIdMigrationRequest, 0)
+					bytes, _ := json.Marshal(replyMessage.Result)
+					if replyMessage.Status && json.Unmarshal(bytes, &migrations) == nil {
+						service.OnTrackerNoticedUserPeerIdMigrations(tracker.host, migrations)
+					}
 				}
 			}
 		}
 	}()
 
-	// heartbeat and check whether
+	// heartbeat until the connection is closed
 	for {
 		select {
+		case <-closed:
+			return
 		case t := <-ticker.C:
-			if conn != nil {
-				info := make(map[string]interface{})
-				info["type"] = enum.MsgType_Tracker_HeartBeat_302
-				info["data"] = t.String()
-				bytes, err := json.Marshal(info)
-				err = conn.WriteMessage(websocket.TextMessage, bytes)
-				if err != nil {
-					log.Println("HeartBeat:", err)
-					isReset = true
-					log.Println("socket to tracker get err:", err)
-					conn = nil
-					return
-				}
-			} else {
+			info := make(map[string]interface{})
+			info["type"] = enum.MsgType_Tracker_HeartBeat_302
+			info["data"] = t.String()
+			bytes, _ := json.Marshal(info)
+			err := tracker.write(bytes)
+			if err != nil {
+				log.Println("HeartBeat:", err)
 				return
 			}
 		}
 	}
 }
 
-func SynData() {
-	log.Println("synData to tracker")
-	updateP2pAddressLogin()
-	sycUserInfos()
-	sycChannelInfos()
+// SynData announce the node, users and channels to the tracker of the new connection
+func (tracker *trackerConn) SynData() {
+	log.Println("synData to tracker", tracker.host)
+	tracker.updateP2pAddressLogin()
+	tracker.sycUserInfos()
+	tracker.sycChannelInfos()
+	tracker.noticeUserPeerIdMigrations()
+}
+
+// the migrations of the user peer ids, which the tracker has not acknowledged
+func (tracker *trackerConn) noticeUserPeerIdMigrations() {
+	migrations := service.GetUserPeerIdMigrationsToNotice(tracker.host)
+	if len(migrations) > 0 {
+		info := make(map[string]interface{})
+		info["type"] = enum.MsgType_Tracker_UpdateUserPeerId_354
+		info["data"] = migrations
+		bytes, err := json.Marshal(info)
+		if err == nil {
+			tracker.sendMsg(bytes)
+		}
+	}
 }
 
-func updateP2pAddressLogin() {
+func (tracker *trackerConn) updateP2pAddressLogin() {
 	info := make(map[string]interface{})
 	info["type"] = enum.MsgType_Tracker_NodeLogin_303
 	nodeLoginInfo := &bean.ObdNodeLoginRequest{}
 	nodeLoginInfo.NodeId = tool.GetObdNodeId()
 	nodeLoginInfo.P2PAddress = localServerDest
+	nodeLoginInfo.OnionPubKey = tool.GetOnionPubKey()
 	info["data"] = nodeLoginInfo
 	bytes, err := json.Marshal(info)
 	if err != nil {
 		log.Println(err)
 	} else {
-		sendMsgToTracker(bytes)
+		tracker.sendMsg(bytes)
 	}
 }
 
-func sycUserInfos() {
+func (tracker *trackerConn) sycUserInfos() {
 
 	nodes := make([]bean.ObdNodeUserLoginRequest, 0)
 	for userId, _ := range GlobalWsClientManager.OnlineClientMap {
@@ -180,13 +398,13 @@ func sycUserInfos() {
 		info["data"] = nodes
 		bytes, err := json.Marshal(&info)
 		if err == nil {
-			sendMsgToTracker(bytes)
+			tracker.sendMsg(bytes)
 		}
 	}
 }
 
 //同步通道信息
-func sycChannelInfos() {
+func (tracker *trackerConn) sycChannelInfos() {
 	nodes := getChannelInfos()
 	if len(nodes) > 0 {
 		info := make(map[string]interface{})
@@ -194,7 +412,7 @@ func sycChannelInfos() {
 		info["data"] = nodes
 		bytes, err := json.Marshal(info)
 		if err == nil {
-			sendMsgToTracker(bytes)
+			tracker.sendMsg(bytes)
 		}
 	}
 }
@@ -308,6 +526,7 @@ func checkChannel(userId string, db storm.Node, nodes []bean.ChannelInfoRequest)
 			request.PeerIdA = channelInfo.PeerIdA
 			request.PeerIdB = channelInfo.PeerIdB
 			request.CurrState = channelInfo.CurrState
+			request.FeeRate = config.HtlcFeeRate
 			if commitmentTransaction.Id > 0 {
 				request.AmountA = commitmentTransaction.AmountToRSMC
 				request.AmountB = commitmentTransaction.AmountToCounterparty
@@ -334,21 +553,155 @@ func checkChannel(userId string, db storm.Node, nodes []bean.ChannelInfoRequest)
 	return nodes
 }
 
+func (tracker *trackerConn) sendMsg(msg []byte) {
+	err := tracker.write(msg)
+	if err != nil {
+		log.Println("write:", err)
+	}
+}
+
+// sendMsgToTracker send the path finding request to the first connected tracker in the priority order, the tracker
+// replies the path on the same websocket. The announcements are sent to all the connected trackers. The message is
+// dropped by the disconnected trackers, the users and channels are synced again after the reconnection.
 func sendMsgToTracker(msg []byte) {
 	//log.Println("send to tracker", string(msg))
-	if conn == nil {
-		isReset = true
-		err := ConnectToTracker()
-		if err != nil {
-			log.Println(err)
+	if enum.MsgType(gjson.GetBytes(msg, "type").Int()) == enum.MsgType_Tracker_GetHtlcPath_351 {
+		sendPathRequest(msg)
+		return
+	}
+	for _, tracker := range trackerConns {
+		if tracker.current() != nil {
+			tracker.sendMsg(msg)
+		}
+	}
+}
+
+// pathRequest the path request of a payment, it goes to the next tracker when the write to the former one fails,
+// or the former one has no path or does not reply in config.HtlcPathTimeout
+type pathRequest struct {
+	msg         []byte
+	payerPeerId string
+	h           string
+	tried       map[string]bool
+	// the tracker waited for, and the timer of its reply
+	host  string
+	timer *time.Timer
+}
+
+var (
+	// the path requests waiting for the replies of the trackers, by the payer and h
+	pathRequests    = make(map[string]*pathRequest)
+	pathRequestLock sync.Mutex
+)
+
+func sendPathRequest(msg []byte) {
+	data := gjson.GetBytes(msg, "data")
+	request := &pathRequest{
+		msg:         msg,
+		payerPeerId: data.Get("real_payer_peer_id").Str,
+		h:           data.Get("h").Str,
+		tried:       make(map[string]bool),
+	}
+	key := request.payerPeerId + "_" + request.h
+	pathRequestLock.Lock()
+	pathRequests[key] = request
+	pathRequestLock.Unlock()
+	if request.send() == false {
+		request.finish()
+		reportNoTrackerForPath(request.payerPeerId, request.h)
+	}
+}
+
+// the first connected tracker in the priority order which has not got the request
+func (request *pathRequest) nextTracker() *trackerConn {
+	pathRequestLock.Lock()
+	defer pathRequestLock.Unlock()
+	for _, tracker := range trackerConns {
+		if request.tried[tracker.host] == false && tracker.current() != nil {
+			request.tried[tracker.host] = true
+			return tracker
+		}
+	}
+	return nil
+}
+
+// send the request to the next tracker, it is false if no tracker gets it
+func (request *pathRequest) send() bool {
+	for tracker := request.nextTracker(); tracker != nil; tracker = request.nextTracker() {
+		err := tracker.write(request.msg)
+		if err == nil {
+			request.waitReply(tracker.host)
+			return true
+		}
+		log.Println("fail to send the path request to tracker", tracker.host, err)
+	}
+	return false
+}
+
+// waitReply the request goes to the next tracker if the tracker does not reply in time
+func (request *pathRequest) waitReply(host string) {
+	pathRequestLock.Lock()
+	defer pathRequestLock.Unlock()
+	if request.timer != nil {
+		request.timer.Stop()
+	}
+	request.host = host
+	request.timer = time.AfterFunc(config.HtlcPathTimeout, func() {
+		pathRequestLock.Lock()
+		waiting := pathRequests[request.payerPeerId+"_"+request.h] == request && request.host == host
+		pathRequestLock.Unlock()
+		if waiting == false {
 			return
 		}
+		log.Println("tracker", host, "does not reply the path of", request.h, "in time")
+		if request.send() == false {
+			request.finish()
+			reportNoTrackerForPath(request.payerPeerId, request.h)
+		}
+	})
+}
+
+func (request *pathRequest) finish() {
+	key := request.payerPeerId + "_" + request.h
+	pathRequestLock.Lock()
+	if pathRequests[key] == request {
+		delete(pathRequests, key)
 	}
-	err := conn.WriteMessage(websocket.TextMessage, msg)
-	if err != nil {
-		log.Println("write:", err)
+	if request.timer != nil {
+		request.timer.Stop()
+	}
+	pathRequestLock.Unlock()
+}
+
+// onPathReply the path of the tracker is passed on to the payer, unless it is empty and the request is sent to another
+// tracker. It is false if the request is sent again, or the reply is not waited for any more.
+func onPathReply(host, payerPeerId, h, path string) bool {
+	pathRequestLock.Lock()
+	request := pathRequests[payerPeerId+"_"+h]
+	waiting := request != nil && request.host == host
+	pathRequestLock.Unlock()
+	if waiting == false {
+		log.Println("drop the path of", h, "by tracker", host, "which is not waited for")
+		return false
+	}
+	if path == "" && request.send() {
+		log.Println("no path of", h, "by the tracker, ask the next tracker")
+		return false
+	}
+	request.finish()
+	return true
+}
+
+// the payment fails if no tracker can answer its path request
+func reportNoTrackerForPath(payerPeerId, h string) {
+	err := enum.NewError(enum.ErrorCode_htlc_noTrackerForPath)
+	log.Println(err, h)
+	client := tempClientMap[payerPeerId]
+	if client == nil || client.User == nil {
 		return
 	}
+	service.PaymentService.OnPathNotFound(h, err.Error(), *client.User)
+	client.SendToMyself(enum.MsgType_HTLC_FindPath_401, false, client.errorData(err))
 }
 
 func startSchedule() {
@@ -371,18 +724,4 @@ func startSchedule() {
 			}
 		}
 	}()
-
-	go func() {
-		ticker3m = time.NewTicker(1 * time.Minute)
-		defer ticker3m.Stop()
-		for {
-			select {
-			case t := <-ticker3m.C:
-				if conn == nil {
-					log.Println("reconnect tracker ", t)
-					_ = ConnectToTracker()
-				}
-			}
-		}
-	}()
 }
@@ -0,0 +1,151 @@
+package lightclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/gorilla/websocket"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+)
+
+// a tracker which keeps the messages from obd
+func newTestTrackerConn(t *testing.T, host string) (*trackerConn, chan []byte, func()) {
+	messages := make(chan []byte, 8)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wsConn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer wsConn.Close()
+		for {
+			_, message, err := wsConn.ReadMessage()
+			if err != nil {
+				return
+			}
+			messages <- message
+		}
+	}))
+	wsConn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := newTrackerConn(host)
+	tracker.conn = wsConn
+	return tracker, messages, func() {
+		_ = wsConn.Close()
+		server.Close()
+	}
+}
+
+func receive(t *testing.T, messages chan []byte) []byte {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("the tracker got no message")
+		return nil
+	}
+}
+
+func TestPathRequestFailover(t *testing.T) {
+	closedTracker, _, stop1 := newTestTrackerConn(t, "tracker1")
+	defer stop1()
+	_ = closedTracker.conn.Close()
+	offlineTracker := newTrackerConn("tracker2")
+	tracker3, messages3, stop3 := newTestTrackerConn(t, "tracker3")
+	defer stop3()
+	tracker4, messages4, stop4 := newTestTrackerConn(t, "tracker4")
+	defer stop4()
+	trackers := trackerConns
+	trackerConns = []*trackerConn{closedTracker, offlineTracker, tracker3, tracker4}
+	defer func() { trackerConns = trackers }()
+
+	// the write to the first tracker fails, the second one is offline
+	msg, _ := json.Marshal(map[string]interface{}{"type": enum.MsgType_Tracker_GetHtlcPath_351, "data": map[string]interface{}{"h": "h", "real_payer_peer_id": "alice"}})
+	sendMsgToTracker(msg)
+	if string(receive(t, messages3)) != string(msg) {
+		t.Fatal("the path request is not sent to the third tracker")
+	}
+	if closedTracker.current() != nil {
+		t.Fatal("the tracker failed to write is still connected")
+	}
+
+	// the third tracker has no path, ask the fourth one
+	if onPathReply("tracker3", "alice", "h", "") {
+		t.Fatal("the empty path is passed on to the payer before the other trackers are asked")
+	}
+	if string(receive(t, messages4)) != string(msg) {
+		t.Fatal("the path request is not sent to the fourth tracker")
+	}
+	if onPathReply("tracker3", "alice", "h", "c1") {
+		t.Fatal("the late path of the third tracker is passed on to the payer")
+	}
+	if onPathReply("tracker4", "alice", "h", "") == false {
+		t.Fatal("the empty path of the last tracker is not passed on to the payer")
+	}
+	if len(pathRequests) != 0 {
+		t.Fatalf("the path requests %v are left", pathRequests)
+	}
+
+	// no tracker is connected, the payer is told at once
+	dir, err := ioutil.TempDir("", "obd_path_request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := storm.Open(dir + "/user_alice.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	client := &Client{Id: "client1", User: &bean.User{PeerId: "alice", Db: db}, SendChannel: make(chan []byte, 1)}
+	tempClientMap["alice"] = client
+	defer delete(tempClientMap, "alice")
+	trackerConns = []*trackerConn{offlineTracker}
+	sendMsgToTracker(msg)
+	reply := bean.ReplyMessage{}
+	_ = json.Unmarshal(<-client.SendChannel, &reply)
+	if reply.Type != enum.MsgType_HTLC_FindPath_401 || reply.Status || reply.ErrorCode != enum.ErrorCode_htlc_noTrackerForPath {
+		t.Fatalf("got the reply %+v, want no tracker for the path", reply)
+	}
+	if len(pathRequests) != 0 {
+		t.Fatalf("the path requests %v are left", pathRequests)
+	}
+
+	// the trackers do not reply in time, the request goes to the next one and at last the payer is told
+	pathTimeout := config.HtlcPathTimeout
+	config.HtlcPathTimeout = 50 * time.Millisecond
+	defer func() { config.HtlcPathTimeout = pathTimeout }()
+	trackerConns = []*trackerConn{tracker3, tracker4}
+	sendMsgToTracker(msg)
+	if string(receive(t, messages3)) != string(msg) {
+		t.Fatal("the path request is not sent to the third tracker")
+	}
+	if string(receive(t, messages4)) != string(msg) {
+		t.Fatal("the path request is not sent to the fourth tracker after the third one timed out")
+	}
+	select {
+	case message := <-client.SendChannel:
+		reply = bean.ReplyMessage{}
+		_ = json.Unmarshal(message, &reply)
+		if reply.Type != enum.MsgType_HTLC_FindPath_401 || reply.Status || reply.ErrorCode != enum.ErrorCode_htlc_noTrackerForPath {
+			t.Fatalf("got the reply %+v, want no tracker for the path", reply)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the payer is not told after all trackers timed out")
+	}
+	if onPathReply("tracker4", "alice", "h", "c1") {
+		t.Fatal("the path after the timeout is passed on to the payer")
+	}
+}
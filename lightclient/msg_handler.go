@@ -0,0 +1,229 @@
+package lightclient
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+// MsgHandler handle a msg from the websocket client, the same as the modules of Client
+type MsgHandler func(client *Client, msg bean.RequestMessage) (enum.SendTargetType, []byte, bool)
+
+var msgHandlers = struct {
+	sync.RWMutex
+	handlers map[enum.MsgType]MsgHandler
+}{handlers: make(map[enum.MsgType]MsgHandler)}
+
+// RegisterHandler register the handler of the msg types, and their info which is checked before the handler is called.
+// other packages can add their own msg types by it in init(), a msg type can be registered only once.
+func RegisterHandler(handler MsgHandler, info enum.MsgTypeInfo, msgTypes ...enum.MsgType) {
+	msgHandlers.Lock()
+	defer msgHandlers.Unlock()
+	for _, msgType := range msgTypes {
+		if _, ok := msgHandlers.handlers[msgType]; ok {
+			panic("the handler of msg type " + strconv.Itoa(int(msgType)) + " is registered twice")
+		}
+		msgHandlers.handlers[msgType] = handler
+		enum.RegisterMsgType(msgType, info)
+	}
+}
+
+func getMsgHandler(msgType enum.MsgType) MsgHandler {
+	msgHandlers.RLock()
+	defer msgHandlers.RUnlock()
+	return msgHandlers.handlers[msgType]
+}
+
+// the msgs of the user and the omnicore, which can be sent before login
+func commonMsg(scope string) enum.MsgTypeInfo {
+	return enum.MsgTypeInfo{Scope: scope}
+}
+
+// the queries and the msgs to sign transactions
+func loginMsg(scope string) enum.MsgTypeInfo {
+	return enum.MsgTypeInfo{RequireLogin: true, Scope: scope}
+}
+
+// the msgs sent to the users of the other obd nodes
+var p2pMsg = enum.MsgTypeInfo{RequireLogin: true, RequireRecipient: true, ConnectRemoteNode: true, Scope: enum.Scope_Payment}
+
+func init() {
+	// -102001 ~ -102099 user
+	RegisterHandler((*Client).UserModule, commonMsg(enum.Scope_Any),
+		enum.MsgType_UserLogin_2001,
+		enum.MsgType_UserLogout_2002,
+		enum.MsgType_HeartBeat_2007,
+		enum.MsgType_UserLoginChallenge_2011,
+		enum.MsgType_UserLoginWithSignature_2012)
+	RegisterHandler((*Client).UserModule, commonMsg(enum.Scope_Read),
+		enum.MsgType_GetObdNodeInfo_2005,
+		enum.MsgType_GetMiniBtcFundAmount_2006,
+		enum.MsgType_User_GetInfo_2009)
+	RegisterHandler((*Client).UserModule, commonMsg(enum.Scope_Admin),
+		enum.MsgType_p2p_ConnectPeer_2003,
+		enum.MsgType_User_UpdateAdminToken_2008,
+		enum.MsgType_p2p_DisconnectPeer_2010,
+		enum.MsgType_Credential_Bake_2013,
+		enum.MsgType_Credential_Revoke_2014,
+		enum.MsgType_Credential_List_2015)
+	RegisterHandler((*Client).HdWalletModule, commonMsg(enum.Scope_Any),
+		enum.MsgType_GetMnemonic_2004)
+	RegisterHandler((*Client).eventModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_Event_Subscribe_2016,
+		enum.MsgType_Event_Unsubscribe_2017)
+
+	// -102101 ~ -102199 omnicore
+	RegisterHandler((*Client).omniCoreModule, commonMsg(enum.Scope_Read),
+		enum.MsgType_Core_GetMiningInfo_2102,
+		enum.MsgType_Core_GetNetworkInfo_2103,
+		enum.MsgType_Core_VerifyMessage_2105,
+		enum.MsgType_Core_ListUnspent_2107,
+		enum.MsgType_Core_BalanceByAddress_2108,
+		enum.MsgType_Core_Omni_GetBalance_2112,
+		enum.MsgType_Core_Omni_ListProperties_2117,
+		enum.MsgType_Core_Omni_GetTransaction_2118,
+		enum.MsgType_Core_Omni_GetProperty_2119,
+		enum.MsgType_Core_GetTransactionByTxid_2122)
+	RegisterHandler((*Client).omniCoreModule, commonMsg(enum.Scope_Payment),
+		enum.MsgType_Core_GetNewAddress_2101,
+		enum.MsgType_Core_FundingBTC_2109,
+		enum.MsgType_Core_BtcCreateMultiSig_2110,
+		enum.MsgType_Core_Omni_FundingAsset_2120,
+		enum.MsgType_Core_Omni_Send_2121)
+	RegisterHandler((*Client).omniCoreModule, commonMsg(enum.Scope_Admin),
+		enum.MsgType_Core_SignMessageWithPrivKey_2104,
+		enum.MsgType_Core_DumpPrivKey_2106,
+		enum.MsgType_Core_Btc_ImportPrivKey_2111,
+		enum.MsgType_Core_Omni_CreateNewTokenFixed_2113,
+		enum.MsgType_Core_Omni_CreateNewTokenManaged_2114,
+		enum.MsgType_Core_Omni_GrantNewUnitsOfManagedToken_2115,
+		enum.MsgType_Core_Omni_RevokeUnitsOfManagedToken_2116,
+		enum.MsgType_Core_SignRawTransaction_2123)
+
+	// -3000 -3001
+	RegisterHandler((*Client).HdWalletModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_Mnemonic_CreateAddress_3000)
+	RegisterHandler((*Client).HdWalletModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_Mnemonic_GetAddressByIndex_3001)
+
+	//-32 -33  -38 and query for channel
+	RegisterHandler((*Client).ChannelModule, p2pMsg,
+		enum.MsgType_SendChannelOpen_32,
+		enum.MsgType_SendChannelAccept_33)
+	RegisterHandler((*Client).ChannelModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_SendCloseChannelRequest_38,
+		enum.MsgType_SendCloseChannelSign_39,
+		enum.MsgType_ChannelOpen_DelItemByTempId_3153)
+	RegisterHandler((*Client).ChannelModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_ChannelOpen_AllItem_3150,
+		enum.MsgType_ChannelOpen_ItemByTempId_3151,
+		enum.MsgType_ChannelOpen_Count_3152,
+		enum.MsgType_GetChannelInfoByChannelId_3154,
+		enum.MsgType_GetChannelInfoByDbId_3155,
+		enum.MsgType_CheckChannelAddessExist_3156)
+
+	//-34 -340 and query
+	RegisterHandler((*Client).FundingTransactionModule, p2pMsg,
+		enum.MsgType_FundingCreate_SendAssetFundingCreated_34,
+		enum.MsgType_FundingCreate_SendBtcFundingCreated_340,
+		enum.MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341,
+		enum.MsgType_Funding_134)
+	RegisterHandler((*Client).FundingTransactionModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_ClientSign_AssetFunding_AliceSignC1a_1034,
+		enum.MsgType_ClientSign_AssetFunding_AliceSignRD_1134)
+	RegisterHandler((*Client).FundingTransactionModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_FundingCreate_Asset_AllItem_3100,
+		enum.MsgType_FundingCreate_Asset_ItemById_3101,
+		enum.MsgType_FundingCreate_Asset_ItemByChannelId_3102,
+		enum.MsgType_FundingCreate_Asset_Count_3103,
+		enum.MsgType_FundingCreate_Btc_AllItem_3104,
+		enum.MsgType_FundingCreate_Btc_ItemById_3105,
+		enum.MsgType_FundingCreate_Btc_ItemByTempChannelId_3106,
+		enum.MsgType_FundingCreate_Btc_RDAllItem_3107,
+		enum.MsgType_FundingCreate_Btc_ItemRDById_3108,
+		enum.MsgType_FundingCreate_Btc_ItemRDByTempChannelId_3109,
+		enum.MsgType_FundingCreate_Btc_ItemRDByTempChannelIdAndTxId_3110,
+		enum.MsgType_FundingCreate_Btc_ItemByChannelId_3111)
+
+	//-35 -350
+	RegisterHandler((*Client).fundingSignModule, p2pMsg,
+		enum.MsgType_FundingSign_SendAssetFundingSigned_35,
+		enum.MsgType_FundingSign_SendBtcSign_350)
+	RegisterHandler((*Client).fundingSignModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_ClientSign_AssetFunding_RdAndBr_1035)
+
+	//-351 and query
+	RegisterHandler((*Client).CommitmentTxModule, p2pMsg,
+		enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351,
+		enum.MsgType_ClientSign_CommitmentTx_AliceSignC2a_360,
+		enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_Rd_363)
+	RegisterHandler((*Client).CommitmentTxModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_362,
+		enum.MsgType_CommitmentTx_SendSomeCommitmentById_3206,
+		enum.MsgType_CommitmentTx_DelItemByChanId_3209)
+	RegisterHandler((*Client).CommitmentTxModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_CommitmentTx_ItemsByChanId_3200,
+		enum.MsgType_CommitmentTx_ItemById_3201,
+		enum.MsgType_CommitmentTx_Count_3202,
+		enum.MsgType_CommitmentTx_LatestCommitmentTxByChanId_3203,
+		enum.MsgType_CommitmentTx_LatestRDByChanId_3204,
+		enum.MsgType_CommitmentTx_LatestBRByChanId_3205,
+		enum.MsgType_CommitmentTx_AllRDByChanId_3207,
+		enum.MsgType_CommitmentTx_AllBRByChanId_3208)
+
+	//-htlc query
+	RegisterHandler((*Client).htlcQueryModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_Htlc_GetLatestHT1aOrHE1b_3250,
+		enum.MsgType_Htlc_GetHT1aOrHE1bBySomeCommitmentId_3251,
+		enum.MsgType_Htlc_GetPayment_3252,
+		enum.MsgType_Htlc_ListPayments_3253)
+
+	//-352
+	RegisterHandler((*Client).commitmentTxSignModule, p2pMsg,
+		enum.MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352,
+		enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_361)
+	RegisterHandler((*Client).commitmentTxSignModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_Rd_364)
+
+	//-40 -41
+	RegisterHandler((*Client).HtlcHModule, p2pMsg,
+		enum.MsgType_HTLC_SendAddHTLC_40,
+		enum.MsgType_HTLC_ClientSign_Alice_C3a_100,
+		enum.MsgType_HTLC_ClientSign_Bob_C3b_101,
+		enum.MsgType_HTLC_ClientSign_Alice_C3bSub_103,
+		enum.MsgType_HTLC_ClientSign_Alice_He_105)
+	RegisterHandler((*Client).HtlcHModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_HTLC_FindPath_401,
+		enum.MsgType_HTLC_ClientSign_Alice_C3b_102,
+		enum.MsgType_HTLC_ClientSign_Bob_C3bSub_104,
+		enum.MsgType_HTLC_SendAddHTLCSigned_41)
+	RegisterHandler((*Client).HtlcHModule, loginMsg(enum.Scope_Invoice),
+		enum.MsgType_HTLC_Invoice_402)
+	RegisterHandler((*Client).HtlcHModule, loginMsg(enum.Scope_Read),
+		enum.MsgType_HTLC_ParseInvoice_403)
+
+	//-45 -46 -47
+	RegisterHandler((*Client).htlcTxModule, p2pMsg,
+		enum.MsgType_HTLC_SendVerifyR_45,
+		enum.MsgType_HTLC_ClientSign_Bob_HeSub_106,
+		enum.MsgType_HTLC_ClientSign_Alice_HeSub_46,
+		enum.MsgType_HTLC_SendFailHtlc_47)
+
+	// -49 -50
+	RegisterHandler((*Client).htlcCloseModule, p2pMsg,
+		enum.MsgType_HTLC_Close_SendRequestCloseCurrTx_49,
+		enum.MsgType_HTLC_Close_ClientSign_Bob_C4b_111,
+		enum.MsgType_HTLC_Close_ClientSign_Alice_C4bSub_113,
+		enum.MsgType_HTLC_Close_SendCloseSigned_50)
+	RegisterHandler((*Client).htlcCloseModule, loginMsg(enum.Scope_Payment),
+		enum.MsgType_HTLC_Close_ClientSign_Alice_C4a_110,
+		enum.MsgType_HTLC_Close_ClientSign_Alice_C4b_112,
+		enum.MsgType_HTLC_Close_ClientSign_Bob_C4bSubResult_114)
+
+	// -80 -81
+	RegisterHandler((*Client).atomicSwapModule, p2pMsg,
+		enum.MsgType_Atomic_SendSwap_80,
+		enum.MsgType_Atomic_SendSwapAccept_81)
+}
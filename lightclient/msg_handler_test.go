@@ -0,0 +1,46 @@
+package lightclient
+
+import (
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+func TestRegisterHandler(t *testing.T) {
+	if enum.CheckExist(enum.MsgType_SendChannelOpen_32) == false || getMsgHandler(enum.MsgType_SendChannelOpen_32) == nil {
+		t.Fatal("the channel msgs are not registered")
+	}
+	info, _ := enum.GetMsgTypeInfo(enum.MsgType_SendChannelOpen_32)
+	if info.RequireLogin == false || info.RequireRecipient == false || info.ConnectRemoteNode == false {
+		t.Fatal("wrong info of", enum.MsgType_SendChannelOpen_32, info)
+	}
+	if scope := enum.GetMsgTypeScope(enum.MsgType_HTLC_Invoice_402); scope != enum.Scope_Invoice {
+		t.Fatal("wrong scope of the invoice", scope)
+	}
+	if info, _ = enum.GetMsgTypeInfo(enum.MsgType_UserLogin_2001); info.RequireLogin {
+		t.Fatal("login requires login")
+	}
+
+	var msgType enum.MsgType = -109001
+	if enum.CheckExist(msgType) {
+		t.Fatal("msg type exists before it is registered")
+	}
+	RegisterHandler(func(client *Client, msg bean.RequestMessage) (enum.SendTargetType, []byte, bool) {
+		return enum.SendTargetType_SendToSomeone, []byte("ok"), true
+	}, enum.MsgTypeInfo{RequireLogin: true, Scope: enum.Scope_Read}, msgType)
+	if enum.CheckExist(msgType) == false || enum.GetMsgTypeScope(msgType) != enum.Scope_Read {
+		t.Fatal("the new msg type is not registered")
+	}
+	_, data, _ := getMsgHandler(msgType)(nil, bean.RequestMessage{Type: msgType})
+	if string(data) != "ok" {
+		t.Fatal("wrong handler of the new msg type")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("a msg type is registered twice")
+		}
+	}()
+	RegisterHandler((*Client).UserModule, enum.MsgTypeInfo{}, msgType)
+}
@@ -0,0 +1,47 @@
+package lightclient
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/service"
+)
+
+// the parts whose r is being sent by the payee, by the id of the commitment tx
+var releasingHtlcParts = make(map[string]bool)
+var releasingHtlcPartsLock sync.Mutex
+
+func init() {
+	service.CloseHtlcByObd = closeHtlcByObd
+}
+
+// release r for the part once, the last parts of a multi-path payment may arrive at the same time
+func releaseHtlcPart(part dao.CommitmentTransaction, r string, client Client, msg bean.RequestMessage) {
+	key := client.User.PeerId + "_" + strconv.Itoa(part.Id)
+	releasingHtlcPartsLock.Lock()
+	if releasingHtlcParts[key] {
+		releasingHtlcPartsLock.Unlock()
+		return
+	}
+	releasingHtlcParts[key] = true
+	releasingHtlcPartsLock.Unlock()
+
+	sendRForHtlcPart(part.ChannelId, r, client, msg)
+
+	releasingHtlcPartsLock.Lock()
+	delete(releasingHtlcParts, key)
+	releasingHtlcPartsLock.Unlock()
+}
+
+// obd signs the close of the htlc for the admin user, and sends it to the other side of the channel
+func closeHtlcByObd(htlcTx dao.CommitmentTransaction, user bean.User) {
+	counterparty := htlcTx.PeerIdB
+	if counterparty == user.PeerId {
+		counterparty = htlcTx.PeerIdA
+	}
+	msg := bean.RequestMessage{SenderUserPeerId: counterparty}
+	msg.SenderNodePeerId, _ = service.GetUserP2pNodeId(counterparty)
+	closeHtlc(msg, &htlcTx, Client{User: &user})
+}
@@ -448,6 +448,17 @@ func routerOfP2PNode(msg bean.RequestMessage, data string, client *Client) (retD
 			return string(retData), true, nil
 		}
 		defaultErr = err
+	case enum.MsgType_HTLC_FailHtlc_47:
+		responseData, _, toPrevious, err := service.HtlcFailTxService.OnGetFailAtSenderSide(data, *client.User)
+		if err == nil {
+			// the failure goes on to the payer, the htlc is closed by the htlc_close_required event to get back the amount
+			if toPrevious != nil {
+				go client.sendDataToP2PUser(*toPrevious, true, toPrevious.Data)
+			}
+			retData, _ := json.Marshal(responseData)
+			return string(retData), true, nil
+		}
+		defaultErr = err
 	case enum.MsgType_HTLC_Close_RequestCloseCurrTx_49:
 		responseData, err := service.HtlcCloseTxService.OnObdOfBobGet49PData(data, *client.User)
 		if err == nil {
@@ -650,6 +661,10 @@ func p2pMiddleNodeTransferData(msg *bean.RequestMessage, itemClient Client, data
 		msg.Type = enum.MsgType_HTLC_RecvSignVerifyR_46
 	}
 
+	if msg.Type == enum.MsgType_HTLC_FailHtlc_47 {
+		msg.Type = enum.MsgType_HTLC_RecvFailHtlc_47
+	}
+
 	if msg.Type == enum.MsgType_HTLC_Close_RequestCloseCurrTx_49 {
 		msg.Type = enum.MsgType_HTLC_Close_RecvRequestCloseCurrTx_49
 	}
@@ -678,38 +693,32 @@ func checkHForNextNodeOrRForBackward(toBob interface{}, client Client, msg bean.
 	r := admin.ROwnerGetHtlcRFromLocal(toBob, client.User)
 	// when currUser is the real payee, can get r from local db,then backward R (45 MsgType_HTLC_SendVerifyR_45)
 	if r != "" {
-		msg.Type = enum.MsgType_HTLC_SendVerifyR_45
 		c3b := toBob.(*dao.CommitmentTransaction)
-		sendR := bean.HtlcBobSendR{ChannelId: c3b.ChannelId, R: r}
-		marshal, _ := json.Marshal(sendR)
-		msg.Data = string(marshal)
-		retData, err := service.HtlcBackwardTxService.SendRToPreviousNodeAtBobSide(msg, *client.User)
-		if err == nil {
-			signedData, err := admin.HtlcBobSignedHeRdAtBobSide(retData, client.User)
-			if err == nil {
-				marshal, _ := json.Marshal(signedData)
-				msg.Data = string(marshal)
-				toAlice, err := service.HtlcBackwardTxService.OnBobSignedHeRdAtBobSide(msg, *client.User)
-				if err == nil {
-					marshal, _ := json.Marshal(toAlice)
-					msg.Type = enum.MsgType_HTLC_VerifyR_45
-					msg.Data = string(marshal)
-					client.sendDataToP2PUser(msg, true, msg.Data)
-				} else {
-					log.Println(err)
-				}
-			} else {
-				log.Println(err)
-			}
-		} else {
+		if failure := service.HtlcFailTxService.CheckHtlcAtPayeeSide(*c3b, *client.User); failure != nil {
+			failHtlcToPreNode(c3b, *failure, client)
+			return
+		}
+		// the parts of a multi-path payment wait for each other, r is released for all of them at last
+		if err := service.HtlcBackwardTxService.CheckHtlcPartsArrived(*c3b, *client.User); err != nil {
 			log.Println(err)
+			return
+		}
+		for _, part := range service.HtlcBackwardTxService.GetPendingHtlcParts(c3b.HtlcH, *client.User) {
+			partMsg := msg
+			if part.ChannelId != c3b.ChannelId {
+				partMsg.RecipientUserPeerId = part.HtlcSender
+				partMsg.RecipientNodePeerId, _ = service.GetUserP2pNodeId(part.HtlcSender)
+			}
+			releaseHtlcPart(part, r, client, partMsg)
 		}
 	} else {
 		// when currUser is the interUser, get next channel by h, to get the r
 		//trigger send 40
+		currNodeTx := toBob.(*dao.CommitmentTransaction)
 		channelId, amount, msg := admin.InterUserGetNextNode(toBob, client.User)
-		if len(channelId) > 0 {
-			currNodeTx := toBob.(*dao.CommitmentTransaction)
+		if len(channelId) == 0 {
+			failHtlcToPreNode(currNodeTx, service.HtlcFailTxService.GetForwardFailure(*currNodeTx, nil, *client.User), client)
+		} else {
 			msg.Type = enum.MsgType_HTLC_SendAddHTLC_40
 			createHtlcTxForC3a := bean.CreateHtlcTxForC3a{}
 			createHtlcTxForC3a.Amount = amount
@@ -719,13 +728,72 @@ func checkHForNextNodeOrRForBackward(toBob interface{}, client Client, msg bean.
 			createHtlcTxForC3a.H = currNodeTx.HtlcH
 			createHtlcTxForC3a.Memo = currNodeTx.HtlcMemo
 			createHtlcTxForC3a.RoutingPacket = currNodeTx.HtlcRoutingPacket
+			createHtlcTxForC3a.TotalAmount = currNodeTx.HtlcTotalAmount
+			// the cltv expiry to the next hop is set by the payer in the onion
+			if len(currNodeTx.HtlcOnion) > 0 {
+				createHtlcTxForC3a.Onion = currNodeTx.HtlcOnion
+				createHtlcTxForC3a.CltvExpiry = currNodeTx.HtlcForwardCltv
+			}
+			if failure := service.HtlcFailTxService.CheckHtlcExpiry(*currNodeTx, createHtlcTxForC3a.CltvExpiry); failure != nil {
+				failHtlcToPreNode(currNodeTx, *failure, client)
+				return
+			}
 			marshal, _ := json.Marshal(createHtlcTxForC3a)
 			msg.Data = string(marshal)
-			client.HtlcHModule(*msg)
+			if _, data, status := client.HtlcHModule(*msg); status == false {
+				failHtlcToPreNode(currNodeTx, service.HtlcFailTxService.GetForwardFailure(*currNodeTx, client.GetError(string(data)), *client.User), client)
+			}
 		}
 	}
 }
 
+// the htlc can not go on, the failure is sent back to the sender of the htlc, who closes it
+func failHtlcToPreNode(htlcTx *dao.CommitmentTransaction, failure bean.HtlcFailure, client Client) {
+	msg := bean.RequestMessage{Type: enum.MsgType_HTLC_SendFailHtlc_47}
+	msg.RecipientUserPeerId = htlcTx.HtlcSender
+	msg.RecipientNodePeerId, _ = service.GetUserP2pNodeId(htlcTx.HtlcSender)
+	marshal, _ := json.Marshal(bean.HtlcSendFail{ChannelId: htlcTx.ChannelId, HtlcFailure: failure})
+	msg.Data = string(marshal)
+	toSender, err := service.HtlcFailTxService.SendFailToPreviousNode(msg, *client.User)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	msg.Type = enum.MsgType_HTLC_FailHtlc_47
+	marshal, _ = json.Marshal(toSender)
+	msg.Data = string(marshal)
+	_ = client.sendDataToP2PUser(msg, true, msg.Data)
+}
+
+// the payee sends r to the payer of the part
+func sendRForHtlcPart(channelId, r string, client Client, msg bean.RequestMessage) {
+	msg.Type = enum.MsgType_HTLC_SendVerifyR_45
+	sendR := bean.HtlcBobSendR{ChannelId: channelId, R: r}
+	marshal, _ := json.Marshal(sendR)
+	msg.Data = string(marshal)
+	retData, err := service.HtlcBackwardTxService.SendRToPreviousNodeAtBobSide(msg, *client.User)
+	if err == nil {
+		signedData, err := admin.HtlcBobSignedHeRdAtBobSide(retData, client.User)
+		if err == nil {
+			marshal, _ := json.Marshal(signedData)
+			msg.Data = string(marshal)
+			toAlice, err := service.HtlcBackwardTxService.OnBobSignedHeRdAtBobSide(msg, *client.User)
+			if err == nil {
+				marshal, _ := json.Marshal(toAlice)
+				msg.Type = enum.MsgType_HTLC_VerifyR_45
+				msg.Data = string(marshal)
+				client.sendDataToP2PUser(msg, true, msg.Data)
+			} else {
+				log.Println(err)
+			}
+		} else {
+			log.Println(err)
+		}
+	} else {
+		log.Println(err)
+	}
+}
+
 // backward R
 func checkRToPreNode(toAlice interface{}, client Client) {
 	r, channelId, msg := admin.InterUserGetHtlcRFromLocalForPreNode(toAlice, client.User)
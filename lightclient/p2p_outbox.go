@@ -0,0 +1,82 @@
+package lightclient
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/service"
+)
+
+func init() {
+	service.OutboxService.AddListener(sendOutboxState)
+}
+
+// the recipient is offline, queue the msg until the recipient logins
+func queueP2PMsg(msg bean.RequestMessage, status bool) error {
+	// the state of a queued msg is useless after the sender logouts
+	if msg.Type == enum.MsgType_Outbox_Status_2019 {
+		return nil
+	}
+	_, err := service.OutboxService.Enqueue(msg, status)
+	return err
+}
+
+// deliver the queued msgs to the user who has just login, in the order they are queued
+func (client *Client) deliverOutbox() {
+	if client.User == nil {
+		return
+	}
+	for _, item := range service.OutboxService.TakeQueued(client.User.PeerId) {
+		msg := bean.RequestMessage{
+			Type:                enum.MsgType(item.MsgType),
+			SenderUserPeerId:    item.SenderUserPeerId,
+			SenderNodePeerId:    item.SenderNodePeerId,
+			RecipientUserPeerId: item.RecipientUserPeerId,
+			RecipientNodePeerId: item.RecipientNodePeerId,
+			Data:                item.Data,
+		}
+		service.OutboxService.Finish(item, deliverP2PMsg(client, msg, item.Status))
+	}
+}
+
+// tell the sender of the queued msg that it is queued, delivered, failed or expired
+func sendOutboxState(item dao.OutboxMessage) {
+	bytes, _ := json.Marshal(map[string]interface{}{
+		"outbox_id":              item.Id,
+		"msg_type":               item.MsgType,
+		"recipient_user_peer_id": item.RecipientUserPeerId,
+		"recipient_node_peer_id": item.RecipientNodePeerId,
+		"state":                  item.State,
+		"expire_at":              item.ExpireAt,
+	})
+	msg := bean.RequestMessage{
+		Type:                enum.MsgType_Outbox_Status_2019,
+		SenderUserPeerId:    item.RecipientUserPeerId,
+		SenderNodePeerId:    P2PLocalNodeId,
+		RecipientUserPeerId: item.SenderUserPeerId,
+		RecipientNodePeerId: item.SenderNodePeerId,
+		Data:                string(bytes),
+	}
+	if msg.RecipientNodePeerId == P2PLocalNodeId {
+		deliverOutboxState(msg)
+		return
+	}
+	bytes, _ = json.Marshal(msg)
+	if err := sendP2PMsg(msg.RecipientNodePeerId, string(bytes)); err != nil {
+		log.Println("fail to send the state of the queued msg", item.Id, err)
+	}
+}
+
+// the state is never queued, and it is not the reply of the request of the sender
+func deliverOutboxState(msg bean.RequestMessage) {
+	itemClient := GlobalWsClientManager.OnlineClientMap[msg.RecipientUserPeerId]
+	if itemClient == nil || itemClient.SendChannel == nil {
+		return
+	}
+	fromId := getP2PUserAddress(msg.SenderUserPeerId, msg.SenderNodePeerId)
+	toId := getP2PUserAddress(msg.RecipientUserPeerId, msg.RecipientNodePeerId)
+	itemClient.SendChannel <- getP2PReplyObj(msg.Data, msg.Type, true, fromId, toId, "")
+}
@@ -3,7 +3,6 @@ package lightclient
 import (
 	"bufio"
 	"context"
-	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -22,7 +21,6 @@ import (
 	"github.com/omnilaboratory/obd/service"
 	"github.com/omnilaboratory/obd/tool"
 	"log"
-	"math/rand"
 	"strings"
 	"sync"
 )
@@ -49,9 +47,7 @@ var P2pChannelMap map[string]*P2PChannel
 
 func generatePrivateKey() (crypto.PrivKey, error) {
 	if privateKey == nil {
-		nodeId := int64(binary.BigEndian.Uint64([]byte(tool.GetObdNodeId())))
-		r := rand.New(rand.NewSource(nodeId))
-		prvKey, _, err := crypto.GenerateECDSAKeyPair(r)
+		prvKey, err := tool.LoadOrCreateNodeKey(config.DataDirectory, config.NodeKeyPassphrase)
 		if err != nil {
 			log.Println(err)
 			return nil, err
@@ -61,8 +57,9 @@ func generatePrivateKey() (crypto.PrivKey, error) {
 	return privateKey, nil
 }
 
+// StartP2PNode start the p2p host and connect to the bootstrap peers of the tracker, the bootstrap peers of the
+// other trackers are connected by connectBootstrapPeers
 func StartP2PNode() (err error) {
-
 	log.Println("start to p2p node")
 	prvKey, err := generatePrivateKey()
 	if err != nil {
@@ -87,8 +84,10 @@ func StartP2PNode() (err error) {
 	P2pChannelMap = make(map[string]*P2PChannel)
 	P2PLocalNodeId = hostNode.ID().Pretty()
 	service.P2PLocalNodeId = P2PLocalNodeId
+	service.IsP2PNodeConnected = isP2PNodeConnected
 
 	localServerDest = fmt.Sprintf("/ip4/%s/tcp/%v/p2p/%s", config.P2P_hostIp, config.P2P_port, hostNode.ID().Pretty())
+	bean.CurrObdNodeInfo.NodeId = P2PLocalNodeId
 	bean.CurrObdNodeInfo.P2pAddress = localServerDest
 	log.Println("local p2p address", localServerDest)
 
@@ -114,14 +113,23 @@ func StartP2PNode() (err error) {
 		return err
 	}
 
+	connectBootstrapPeers(config.BootstrapPeers)
+
+	routingDiscovery = discovery.NewRoutingDiscovery(kademliaDHT)
+	discovery.Advertise(ctx, routingDiscovery, obdRendezvousString)
+
+	return nil
+}
+
+func connectBootstrapPeers(bootstrapPeers []multiaddr.Multiaddr) {
 	var wg sync.WaitGroup
-	for _, peerAddr := range config.BootstrapPeers {
+	for _, peerAddr := range bootstrapPeers {
 		peerInfo, _ := peer.AddrInfoFromP2pAddr(peerAddr)
 		wg.Add(1)
 
 		go func() {
 			defer wg.Done()
-			err = hostNode.Connect(ctx, *peerInfo)
+			err := hostNode.Connect(ctx, *peerInfo)
 
 			if err != nil {
 				log.Println(err, peerInfo)
@@ -134,11 +142,6 @@ func StartP2PNode() (err error) {
 		}()
 	}
 	wg.Wait()
-
-	routingDiscovery = discovery.NewRoutingDiscovery(kademliaDHT)
-	discovery.Advertise(ctx, routingDiscovery, obdRendezvousString)
-
-	return nil
 }
 
 var routingDiscovery *discovery.RoutingDiscovery
@@ -327,8 +330,9 @@ func readData(s network.Stream, rw *bufio.ReadWriter) {
 			//log.Println(str)
 			reqData := &bean.RequestMessage{}
 			err := json.Unmarshal([]byte(str), reqData)
-			if err == nil {
+			if err == nil && beginRequest() {
 				err = getDataFromP2PSomeone(*reqData)
+				endRequest()
 				if err != nil {
 					//msg := err.Error() + "~"
 					//_, _ = rw.WriteString(msg)
@@ -370,6 +374,15 @@ func sendP2PMsg(remoteP2PPeerId string, msg string) error {
 	return nil
 }
 
+// isP2PNodeConnected the network of the host is safe for the concurrent use, unlike the P2pChannelMap
+func isP2PNodeConnected(nodePeerId string) bool {
+	id, err := peer.Decode(nodePeerId)
+	if err != nil {
+		return false
+	}
+	return hostNode.Network().Connectedness(id) == network.Connected
+}
+
 func sendInfoOnUserStateChange(userId string) {
 	for key := range trackerNodeIdMap {
 		findID, err := peer.Decode(key)
@@ -0,0 +1,64 @@
+package lightclient
+
+import (
+	"sync"
+
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+// the max length of the request id from the client
+const maxRequestIdLength = 64
+
+// the request ids are read by the p2p goroutines, when they send msgs to the client
+var requestIdLock sync.Mutex
+
+// the request id of the msg being handled, which is echoed on the replies to the client
+func (client *Client) setRequestId(requestId string) {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	client.requestId = requestId
+	client.lastError = nil
+}
+
+func (client *Client) getRequestId() string {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	return client.requestId
+}
+
+// remember the request id of the msg sent to the other user,
+// it is echoed on the next msg from the user, which is the response of the msg.
+func (client *Client) addP2PRequestId(userPeerId, requestId string) {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	if client.p2pRequestIds == nil {
+		client.p2pRequestIds = make(map[string]string)
+	}
+	client.p2pRequestIds[userPeerId] = requestId
+}
+
+func (client *Client) takeP2PRequestId(userPeerId string) string {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	requestId := client.p2pRequestIds[userPeerId]
+	delete(client.p2pRequestIds, userPeerId)
+	return requestId
+}
+
+// errorData remember the error of the msg being handled, and return its message as the data of the reply
+func (client *Client) errorData(err error) string {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	client.lastError = err
+	return err.Error()
+}
+
+// GetError the error of the msg being handled whose message is the data, which carries the error code
+func (client *Client) GetError(data string) error {
+	requestIdLock.Lock()
+	defer requestIdLock.Unlock()
+	if client.lastError != nil && client.lastError.Error() == data {
+		return client.lastError
+	}
+	return enum.WithCode(enum.ErrorCode_unknown, data)
+}
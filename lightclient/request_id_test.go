@@ -0,0 +1,38 @@
+package lightclient
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+func TestReplyErrorCode(t *testing.T) {
+	client := &Client{Id: "client1", SendChannel: make(chan []byte, 1)}
+	client.setRequestId("1")
+
+	// the reply of the error has its code, even if the error msg has other words
+	data := client.errorData(enum.NewError(enum.ErrorCode_credential_noScope, "htlc"))
+	client.SendToMyself(enum.MsgType_HTLC_SendAddHTLC_40, false, data)
+	reply := bean.ReplyMessage{}
+	_ = json.Unmarshal(<-client.SendChannel, &reply)
+	if reply.ErrorCode != enum.ErrorCode_credential_noScope || reply.RequestId != "1" {
+		t.Fatalf("got the error code %d of the request %s, want %d of 1", reply.ErrorCode, reply.RequestId, enum.ErrorCode_credential_noScope)
+	}
+
+	// the error is forgotten by the next request
+	client.setRequestId("2")
+	client.SendToMyself(enum.MsgType_HTLC_SendAddHTLC_40, false, data)
+	_ = json.Unmarshal(<-client.SendChannel, &reply)
+	if reply.ErrorCode != enum.ErrorCode_unknown {
+		t.Fatalf("got the error code %d, want the unknown error", reply.ErrorCode)
+	}
+
+	client.SendToMyself(enum.MsgType_HTLC_SendAddHTLC_40, true, "{}")
+	reply = bean.ReplyMessage{}
+	_ = json.Unmarshal(<-client.SendChannel, &reply)
+	if reply.ErrorCode != enum.ErrorCode_none {
+		t.Fatalf("got the error code %d of the succeeded reply", reply.ErrorCode)
+	}
+}
@@ -4,11 +4,14 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
 	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/service"
 	"github.com/omnilaboratory/obd/tool"
 	"github.com/satori/go.uuid"
 	"github.com/unrolled/secure"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 )
@@ -21,7 +24,11 @@ func InitRouter() *gin.Engine {
 	//router.Use(TlsHandler())
 
 	go GlobalWsClientManager.Start()
-	bean.CurrObdNodeInfo.WebsocketLink = "ws://" + config.P2P_hostIp + ":" + strconv.Itoa(config.ServerPort) + "/ws" + config.ChainNodeType
+	scheme := "wss://"
+	if config.TlsDisable {
+		scheme = "ws://"
+	}
+	bean.CurrObdNodeInfo.WebsocketLink = scheme + config.P2P_hostIp + ":" + strconv.Itoa(config.ServerPort) + "/ws" + config.ChainNodeType
 	router.GET("/ws"+config.ChainNodeType, wsClientConnect)
 
 	return router
@@ -46,6 +53,21 @@ func TlsHandler() gin.HandlerFunc {
 
 func wsClientConnect(c *gin.Context) {
 
+	// the api credential is in the header, or in the query for browsers
+	credential := c.GetHeader("credential")
+	if len(credential) == 0 {
+		credential = c.Query("credential")
+	}
+	if len(credential) > 0 {
+		if _, err := service.CredentialService.Verify(credential); err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		}
+	} else if config.ApiCredentialRequired {
+		c.String(http.StatusUnauthorized, enum.Tips_credential_required)
+		return
+	}
+
 	wsConn, err := (&websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}).Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println(err)
@@ -53,10 +75,18 @@ func wsClientConnect(c *gin.Context) {
 		return
 	}
 
+	// the ip checked by the credential is the one of the connection, the header X-Forwarded-For can be forged
+	remoteIp, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		remoteIp = c.Request.RemoteAddr
+	}
 	client := &Client{
 		Id:          uuid.NewV4().String(),
 		Socket:      wsConn,
-		SendChannel: make(chan []byte)}
+		SendChannel: make(chan []byte),
+		events:      make(chan []byte, eventQueueSize),
+		credential:  credential,
+		remoteIp:    remoteIp}
 
 	session := c.GetHeader("session")
 	if session == tool.GetGRpcSession() {
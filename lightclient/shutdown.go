@@ -0,0 +1,107 @@
+package lightclient
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/omnilaboratory/obd/service"
+)
+
+// the requests of websocket clients and p2p nodes, which are being handled
+var runningRequests = struct {
+	sync.Mutex
+	count   int
+	closing bool
+	idle    chan struct{}
+}{idle: make(chan struct{})}
+
+// a request is refused when obd is shutting down
+func beginRequest() bool {
+	runningRequests.Lock()
+	defer runningRequests.Unlock()
+	if runningRequests.closing {
+		return false
+	}
+	runningRequests.count++
+	return true
+}
+
+func endRequest() {
+	runningRequests.Lock()
+	defer runningRequests.Unlock()
+	runningRequests.count--
+	if runningRequests.closing && runningRequests.count == 0 {
+		close(runningRequests.idle)
+	}
+}
+
+// StopRequests refuse new requests, and wait for the running ones until ctx is done
+func StopRequests(ctx context.Context) error {
+	runningRequests.Lock()
+	if runningRequests.closing == false {
+		runningRequests.closing = true
+		if runningRequests.count == 0 {
+			close(runningRequests.idle)
+		}
+	}
+	runningRequests.Unlock()
+
+	select {
+	case <-runningRequests.idle:
+		return nil
+	case <-ctx.Done():
+		return errors.New("timeout when waiting for the running requests")
+	}
+}
+
+// LogoutClient logout the user of the client even if it has executing txs, its db is closed, when obd is shutting down
+func LogoutClient(client *Client) {
+	if client.User == nil {
+		return
+	}
+	peerId := client.User.PeerId
+	err := service.UserService.UserLogout(client.User)
+	if err != nil {
+		log.Println("fail to logout user", peerId, err)
+	}
+	client.User = nil
+	if GlobalWsClientManager.OnlineClientMap[peerId] == client {
+		delete(GlobalWsClientManager.OnlineClientMap, peerId)
+		delete(service.OnlineUserMap, peerId)
+	}
+}
+
+// LogoutOnlineUsers logout all online users, their dbs are closed, and close the websocket connections
+func LogoutOnlineUsers() {
+	for peerId, client := range GlobalWsClientManager.OnlineClientMap {
+		LogoutClient(client)
+		delete(GlobalWsClientManager.OnlineClientMap, peerId)
+		delete(service.OnlineUserMap, peerId)
+	}
+	for client := range GlobalWsClientManager.ClientsMap {
+		if client.Socket != nil {
+			_ = client.Socket.Close()
+		}
+	}
+}
+
+// StopP2PNode close the streams to other obd nodes and the libp2p host
+func StopP2PNode() {
+	for nodeId, channel := range P2pChannelMap {
+		if channel.stream != nil {
+			_ = channel.stream.Close()
+		}
+		delete(P2pChannelMap, nodeId)
+	}
+	if kademliaDHT != nil {
+		_ = kademliaDHT.Close()
+	}
+	if hostNode != nil {
+		err := hostNode.Close()
+		if err != nil {
+			log.Println("fail to close p2p node", err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package lightclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStopRequests(t *testing.T) {
+	if beginRequest() == false {
+		t.Fatal("request is refused before shutdown")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := StopRequests(ctx); err == nil {
+		t.Fatal("stop requests without waiting for the running one")
+	}
+	if beginRequest() {
+		t.Fatal("request is accepted when shutting down")
+	}
+
+	endRequest()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := StopRequests(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,13 +2,13 @@ package lightclient
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/service"
 	"github.com/omnilaboratory/obd/tool"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/tidwall/gjson"
@@ -21,6 +21,20 @@ type Client struct {
 	Socket        *websocket.Conn
 	SendChannel   chan []byte
 	GrpcChan      chan []byte
+	// the nonce of the challenge login, single use
+	loginNonce   string
+	loginNonceAt time.Time
+	// the api credential of the connection, and the ip of the client
+	credential string
+	remoteIp   string
+	// the request id of the msg being handled, and the ones of the msgs sent to the other users
+	requestId     string
+	p2pRequestIds map[string]string
+	// the error of the msg being handled, whose code is replied to the client
+	lastError error
+	// the event types subscribed by -102016, and the pushed events waiting for the writing
+	subscriptions map[enum.EventType]bool
+	events        chan []byte
 }
 
 func (client *Client) Write() {
@@ -40,16 +54,32 @@ func (client *Client) Write() {
 				log.Println("fail to send data to client ", string(data))
 				log.Println(err)
 			}
+		case data := <-client.events:
+			err := client.Socket.WriteMessage(websocket.TextMessage, data)
+			if err != nil {
+				log.Println("fail to send event to client ", string(data))
+				log.Println(err)
+			}
 		}
 	}
 }
 
 func (client *Client) Read() {
+	// the previous request is finished when the loop comes back or exits
+	isHandling := false
 	defer func() {
+		if isHandling {
+			endRequest()
+		}
 		GlobalWsClientManager.Disconnected <- client
 	}()
 
 	for {
+		if isHandling {
+			endRequest()
+			isHandling = false
+		}
+		client.setRequestId("")
 		_, dataReq, err := client.Socket.ReadMessage()
 		if err != nil {
 			log.Println(err)
@@ -63,23 +93,31 @@ func (client *Client) Read() {
 		err = json.Unmarshal(dataReq, &temp)
 		if err != nil {
 			log.Println(err)
-			client.SendToMyself(enum.MsgType_Error_0, false, "error json format")
+			client.sendErrorToMyself(enum.MsgType_Error_0, enum.NewError(enum.ErrorCode_ws_errorJson))
 			continue
 		}
 		temp = nil
 
 		jsonParse := gjson.Parse(string(dataReq))
 		if jsonParse.Value() == nil || jsonParse.Exists() == false || jsonParse.IsObject() == false {
-			log.Println("wrong json input")
-			client.SendToMyself(enum.MsgType_Error_0, false, "wrong json input")
+			log.Println(enum.Tips_ws_wrongJson)
+			client.sendErrorToMyself(enum.MsgType_Error_0, enum.NewError(enum.ErrorCode_ws_wrongJson))
+			continue
+		}
+
+		msg.RequestId = jsonParse.Get("request_id").String()
+		if len(msg.RequestId) > maxRequestIdLength {
+			client.sendErrorToMyself(enum.MsgType_Error_0, enum.NewError(enum.ErrorCode_ws_longRequestId, maxRequestIdLength))
 			continue
 		}
+		client.setRequestId(msg.RequestId)
 
 		msg.Type = enum.MsgType(jsonParse.Get("type").Int())
-		if enum.CheckExist(msg.Type) == false {
-			data := "not exist the msg type"
-			log.Println(data)
-			client.SendToMyself(msg.Type, false, data)
+		msgInfo, ok := enum.GetMsgTypeInfo(msg.Type)
+		if ok == false {
+			err = enum.NewError(enum.ErrorCode_ws_notExistMsgType)
+			log.Println(err)
+			client.sendErrorToMyself(msg.Type, err)
 			continue
 		}
 
@@ -92,201 +130,65 @@ func (client *Client) Read() {
 		msg.RecipientNodePeerId = jsonParse.Get("recipient_node_peer_id").String()
 		msg.RecipientUserPeerId = jsonParse.Get("recipient_user_peer_id").String()
 
-		// check the Recipient is online
-		if tool.CheckIsString(&msg.RecipientUserPeerId) {
+		// check the Recipient is online, the p2p msgs to the offline users are queued
+		if tool.CheckIsString(&msg.RecipientUserPeerId) && msgInfo.RequireRecipient == false {
 			_, err = FindUserOnLine(msg)
 			if err != nil {
-				client.SendToMyself(msg.Type, false, fmt.Sprintf(enum.Tips_user_notExistOrOnline, msg.RecipientUserPeerId))
+				client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_user_notExistOrOnline, msg.RecipientUserPeerId))
 				continue
 			}
 		}
 		msg.Data = jsonParse.Get("data").String()
 
+		if err = client.checkCredential(msg); err != nil {
+			client.sendErrorToMyself(msg.Type, err)
+			continue
+		}
+
+		if beginRequest() == false {
+			client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_ws_shuttingDown))
+			continue
+		}
+		isHandling = true
+
 		var sendType = enum.SendTargetType_SendToNone
 		status := false
 		var dataOut []byte
-		var needLogin = true
 
-		if msg.Type <= enum.MsgType_UserLogin_2001 && msg.Type >= enum.MsgType_Common_End_2999 {
-			needLogin = false
+		if msgInfo.RequireLogin && client.User == nil {
+			client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_user_needLogin))
+			continue
 		}
 
-		if msg.Type <= enum.MsgType_UserLogin_2001 && msg.Type > enum.MsgType_User_End_2099 {
-			if msg.Type == enum.MsgType_GetMnemonic_2004 {
-				sendType, dataOut, status = client.HdWalletModule(msg)
-			} else {
-				sendType, dataOut, status = client.UserModule(msg)
+		if msgInfo.RequireRecipient {
+			if tool.CheckIsString(&msg.RecipientUserPeerId) == false {
+				client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_common_empty, " recipient_user_peer_id"))
+				continue
+			}
+			if tool.CheckIsString(&msg.RecipientNodePeerId) == false {
+				client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_common_empty, "error recipient_node_peer_id"))
+				continue
 			}
-			needLogin = false
-		}
-
-		if msg.Type <= enum.MsgType_Core_GetNewAddress_2101 && msg.Type > enum.MsgType_Core_Omni_End_2199 {
-			sendType, dataOut, status = client.omniCoreModule(msg)
-			needLogin = false
 		}
-
-		if needLogin {
-			//not login
-			if client.User == nil {
-				client.SendToMyself(msg.Type, false, "please login")
+		if msgInfo.ConnectRemoteNode && P2pChannelMap[msg.RecipientNodePeerId] == nil {
+			err = ScanAndConnNode(msg.RecipientNodePeerId)
+			if err != nil {
+				client.sendErrorToMyself(msg.Type, enum.NewError(enum.ErrorCode_common_errorObdPeerId, msg.RecipientNodePeerId))
 				continue
-			} else { // already login
-
-				if msg.Type == enum.MsgType_SendChannelOpen_32 || msg.Type == enum.MsgType_SendChannelAccept_33 ||
-					msg.Type == enum.MsgType_FundingCreate_SendBtcFundingCreated_340 || msg.Type == enum.MsgType_FundingSign_SendBtcSign_350 ||
-					msg.Type == enum.MsgType_FundingCreate_SendAssetFundingCreated_34 || msg.Type == enum.MsgType_FundingSign_SendAssetFundingSigned_35 ||
-					msg.Type == enum.MsgType_Funding_134 ||
-					msg.Type == enum.MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341 ||
-					msg.Type == enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351 ||
-					msg.Type == enum.MsgType_ClientSign_CommitmentTx_AliceSignC2a_360 ||
-					msg.Type == enum.MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352 ||
-					msg.Type == enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_361 ||
-					msg.Type == enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_Rd_363 ||
-					msg.Type == enum.MsgType_HTLC_SendAddHTLC_40 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Alice_C3a_100 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Bob_C3b_101 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Alice_C3bSub_103 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Alice_He_105 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Bob_HeSub_106 ||
-					msg.Type == enum.MsgType_HTLC_ClientSign_Alice_HeSub_46 ||
-					msg.Type == enum.MsgType_HTLC_SendVerifyR_45 ||
-					msg.Type == enum.MsgType_HTLC_Close_SendRequestCloseCurrTx_49 ||
-					msg.Type == enum.MsgType_HTLC_Close_ClientSign_Bob_C4b_111 ||
-					msg.Type == enum.MsgType_HTLC_Close_ClientSign_Alice_C4bSub_113 ||
-					msg.Type == enum.MsgType_HTLC_Close_SendCloseSigned_50 ||
-					msg.Type == enum.MsgType_Atomic_SendSwap_80 || msg.Type == enum.MsgType_Atomic_SendSwapAccept_81 {
-					if tool.CheckIsString(&msg.RecipientUserPeerId) == false {
-						client.SendToMyself(msg.Type, false, enum.Tips_common_empty+" recipient_user_peer_id")
-						continue
-					}
-					if tool.CheckIsString(&msg.RecipientNodePeerId) == false {
-						client.SendToMyself(msg.Type, false, enum.Tips_common_empty+"error recipient_node_peer_id")
-						continue
-					}
-					if P2pChannelMap[msg.RecipientNodePeerId] == nil {
-						err = ScanAndConnNode(msg.RecipientNodePeerId)
-						if err != nil {
-							client.SendToMyself(msg.Type, false, fmt.Sprintf(enum.Tips_common_errorObdPeerId, msg.RecipientNodePeerId))
-							continue
-						}
-					}
-				}
-
-				for {
-					//-3000 -3001
-					if msg.Type <= enum.MsgType_Mnemonic_CreateAddress_3000 &&
-						msg.Type >= enum.MsgType_Mnemonic_GetAddressByIndex_3001 {
-						sendType, dataOut, status = client.HdWalletModule(msg)
-						break
-					}
-
-					//-32 -33  -38 and query for channel
-					if msg.Type == enum.MsgType_SendChannelOpen_32 ||
-						msg.Type == enum.MsgType_SendChannelAccept_33 ||
-						msg.Type == enum.MsgType_SendCloseChannelRequest_38 ||
-						(msg.Type <= enum.MsgType_ChannelOpen_AllItem_3150 &&
-							msg.Type >= enum.MsgType_CheckChannelAddessExist_3156) {
-						sendType, dataOut, status = client.ChannelModule(msg)
-						break
-					}
-
-					//-34 -340 and query
-					if msg.Type == enum.MsgType_FundingCreate_SendAssetFundingCreated_34 ||
-						msg.Type == enum.MsgType_FundingCreate_SendBtcFundingCreated_340 ||
-						msg.Type == enum.MsgType_ClientSign_AssetFunding_AliceSignC1a_1034 ||
-						msg.Type == enum.MsgType_ClientSign_AssetFunding_AliceSignRD_1134 ||
-						msg.Type == enum.MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341 ||
-						msg.Type == enum.MsgType_Funding_134 ||
-						(msg.Type <= enum.MsgType_FundingCreate_Asset_AllItem_3100 &&
-							msg.Type >= enum.MsgType_FundingCreate_Btc_ItemByChannelId_3111) {
-						sendType, dataOut, status = client.FundingTransactionModule(msg)
-						break
-					}
-
-					//-35 -350
-					if msg.Type == enum.MsgType_FundingSign_SendAssetFundingSigned_35 ||
-						msg.Type == enum.MsgType_ClientSign_AssetFunding_RdAndBr_1035 ||
-						msg.Type == enum.MsgType_FundingSign_SendBtcSign_350 {
-						sendType, dataOut, status = client.fundingSignModule(msg)
-						break
-					}
-
-					//-351 及查询
-					if msg.Type == enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351 ||
-						msg.Type == enum.MsgType_ClientSign_CommitmentTx_AliceSignC2a_360 ||
-						msg.Type == enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_362 ||
-						msg.Type == enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_Rd_363 ||
-						(msg.Type <= enum.MsgType_CommitmentTx_ItemsByChanId_3200 &&
-							msg.Type >= enum.MsgType_CommitmentTx_DelItemByChanId_3209) {
-						sendType, dataOut, status = client.CommitmentTxModule(msg)
-						break
-					}
-
-					//-htlc query
-					if msg.Type <= enum.MsgType_Htlc_GetLatestHT1aOrHE1b_3250 &&
-						msg.Type >= enum.MsgType_Htlc_GetHT1aOrHE1bBySomeCommitmentId_3251 {
-						sendType, dataOut, status = client.htlcQueryModule(msg)
-						break
-					}
-
-					//-352
-					if msg.Type == enum.MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352 ||
-						msg.Type == enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_361 ||
-						msg.Type == enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_Rd_364 {
-						sendType, dataOut, status = client.commitmentTxSignModule(msg)
-						break
-					}
-
-					//-40 -41
-					if msg.Type == enum.MsgType_HTLC_FindPath_401 ||
-						msg.Type == enum.MsgType_HTLC_Invoice_402 ||
-						msg.Type == enum.MsgType_HTLC_ParseInvoice_403 ||
-						msg.Type == enum.MsgType_HTLC_SendAddHTLC_40 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Alice_C3a_100 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Bob_C3b_101 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Alice_C3b_102 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Alice_C3bSub_103 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Bob_C3bSub_104 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Alice_He_105 ||
-						msg.Type == enum.MsgType_HTLC_SendAddHTLCSigned_41 {
-						sendType, dataOut, status = client.HtlcHModule(msg)
-						break
-					}
-
-					//-42 -43 -44 -45 -46 -47
-					if msg.Type == enum.MsgType_HTLC_SendVerifyR_45 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Bob_HeSub_106 ||
-						msg.Type == enum.MsgType_HTLC_ClientSign_Alice_HeSub_46 {
-						sendType, dataOut, status = client.htlcTxModule(msg)
-						break
-					}
-
-					// -49 -50
-					if msg.Type == enum.MsgType_HTLC_Close_SendRequestCloseCurrTx_49 ||
-						msg.Type == enum.MsgType_HTLC_Close_ClientSign_Alice_C4a_110 ||
-						msg.Type == enum.MsgType_HTLC_Close_ClientSign_Bob_C4b_111 ||
-						msg.Type == enum.MsgType_HTLC_Close_ClientSign_Alice_C4b_112 ||
-						msg.Type == enum.MsgType_HTLC_Close_ClientSign_Alice_C4bSub_113 ||
-						msg.Type == enum.MsgType_HTLC_Close_ClientSign_Bob_C4bSubResult_114 ||
-						msg.Type == enum.MsgType_HTLC_Close_SendCloseSigned_50 {
-						sendType, dataOut, status = client.htlcCloseModule(msg)
-						break
-					}
-					// -80 -81
-					if msg.Type == enum.MsgType_Atomic_SendSwap_80 ||
-						msg.Type == enum.MsgType_Atomic_SendSwapAccept_81 {
-						sendType, dataOut, status = client.atomicSwapModule(msg)
-						break
-					}
-					break
-				}
 			}
 		}
 
+		if handler := getMsgHandler(msg.Type); handler != nil {
+			sendType, dataOut, status = handler(client, msg)
+		}
+		if status && msgInfo.RequireRecipient && len(msg.RequestId) > 0 {
+			client.addP2PRequestId(msg.RecipientUserPeerId, msg.RequestId)
+		}
+
 		if status == false && len(dataOut) == 0 && sendType == enum.SendTargetType_SendToNone {
-			data := "the msg type has no module"
-			log.Println(data)
-			client.SendToMyself(msg.Type, false, data)
+			err = enum.NewError(enum.ErrorCode_ws_noModule)
+			log.Println(err)
+			client.sendErrorToMyself(msg.Type, err)
 			continue
 		}
 
@@ -294,30 +196,48 @@ func (client *Client) Read() {
 			dataOut = dataReq
 		}
 
+		errorCode := enum.ErrorCodeOf(client.GetError(string(dataOut)))
 		//broadcast except me
 		if sendType == enum.SendTargetType_SendToExceptMe {
 			for itemClient := range GlobalWsClientManager.ClientsMap {
 				if itemClient != client {
-					jsonMessage := getReplyObj(string(dataOut), msg.Type, status, client, itemClient)
+					jsonMessage := getReplyObj(string(dataOut), msg.Type, status, errorCode, client, itemClient, "")
 					itemClient.SendChannel <- jsonMessage
 				}
 			}
 		}
 		//broadcast to all
 		if sendType == enum.SendTargetType_SendToAll {
-			jsonMessage := getReplyObj(string(dataOut), msg.Type, status, client, nil)
+			jsonMessage := getReplyObj(string(dataOut), msg.Type, status, errorCode, client, nil, "")
 			GlobalWsClientManager.Broadcast <- jsonMessage
 		}
 	}
 }
 
+// the credential is verified on every msg, so that it can be revoked for the connected clients
+func (client *Client) checkCredential(msg bean.RequestMessage) error {
+	if len(client.credential) == 0 {
+		return nil
+	}
+	credential, err := service.CredentialService.Verify(client.credential)
+	if err != nil {
+		return err
+	}
+	return credential.Check(enum.GetMsgTypeScope(msg.Type), client.remoteIp, msg.Data)
+}
+
+// SendToMyself the failed reply has the code of the error of the msg being handled, if the data is its message
 func (client *Client) SendToMyself(msgType enum.MsgType, status bool, data string) {
 	if client.SendChannel != nil {
-		jsonMessage := getReplyObj(data, msgType, status, client, client)
+		jsonMessage := getReplyObj(data, msgType, status, enum.ErrorCodeOf(client.GetError(data)), client, client, client.getRequestId())
 		client.SendChannel <- jsonMessage
 	}
 }
 
+func (client *Client) sendErrorToMyself(msgType enum.MsgType, err error) {
+	client.SendToMyself(msgType, false, client.errorData(err))
+}
+
 // send p2p msg, check whether they are at the same obd node
 func (client *Client) sendDataToP2PUser(msg bean.RequestMessage, status bool, data string) error {
 	msg.SenderUserPeerId = client.User.PeerId
@@ -325,43 +245,14 @@ func (client *Client) sendDataToP2PUser(msg bean.RequestMessage, status bool, da
 	if tool.CheckIsString(&msg.RecipientUserPeerId) && tool.CheckIsString(&msg.RecipientNodePeerId) {
 		//if they at the same obd node
 		if msg.RecipientNodePeerId == P2PLocalNodeId {
+			msg.Data = data
 			if _, err := FindUserOnLine(msg); err == nil {
 				itemClient := GlobalWsClientManager.OnlineClientMap[msg.RecipientUserPeerId]
 				if itemClient != nil && itemClient.User != nil {
-					if status {
-						retData, isGoOn, err := routerOfP2PNode(msg, data, itemClient)
-						if isGoOn == false {
-							return nil
-						}
-						if err != nil {
-							return err
-						} else {
-							if tool.CheckIsString(&retData) {
-								data = retData
-							}
-						}
-						data = p2pMiddleNodeTransferData(&msg, *itemClient, data, retData)
-						if len(data) == 0 {
-							return nil
-						}
-					}
-					fromId := msg.SenderUserPeerId + "@" + P2pChannelMap[msg.SenderNodePeerId].Address
-					toId := msg.RecipientUserPeerId + "@" + P2pChannelMap[msg.RecipientNodePeerId].Address
-					jsonMessage := getP2PReplyObj(data, msg.Type, status, fromId, toId)
-					if itemClient.SendChannel != nil {
-						itemClient.SendChannel <- jsonMessage
-					}
-					if itemClient.IsGRpcRequest && itemClient.GrpcChan != nil {
-						if msg.Type == enum.MsgType_RecvChannelAccept_33 ||
-							msg.Type == enum.MsgType_HTLC_FinishTransferH_43 {
-							go func() {
-								itemClient.GrpcChan <- jsonMessage
-							}()
-						}
-					}
-					return nil
+					return deliverP2PMsg(itemClient, msg, status)
 				}
 			}
+			return queueP2PMsg(msg, status)
 		} else { //at the different obd node,p2p transfer msg to other node
 			msgToOther := bean.RequestMessage{}
 			msgToOther.Type = msg.Type
@@ -376,57 +267,77 @@ func (client *Client) sendDataToP2PUser(msg bean.RequestMessage, status bool, da
 			}
 		}
 	}
-	return errors.New(fmt.Sprintf(enum.Tips_user_notExistOrOnline, msg.RecipientUserPeerId))
+	return enum.NewError(enum.ErrorCode_user_notExistOrOnline, msg.RecipientUserPeerId)
 }
 
 //当p2p收到消息后
 func getDataFromP2PSomeone(msg bean.RequestMessage) error {
+	if msg.Type == enum.MsgType_Outbox_Status_2019 {
+		deliverOutboxState(msg)
+		return nil
+	}
 	if tool.CheckIsString(&msg.RecipientUserPeerId) && tool.CheckIsString(&msg.RecipientNodePeerId) {
 		if msg.RecipientNodePeerId == P2PLocalNodeId {
 			if _, err := FindUserOnLine(msg); err == nil {
 				itemClient := GlobalWsClientManager.OnlineClientMap[msg.RecipientUserPeerId]
 				if itemClient != nil && itemClient.User != nil {
-					//收到数据后，需要对其进行加工
-					retData, isGoOn, err := routerOfP2PNode(msg, msg.Data, itemClient)
-					if isGoOn == false {
-						return nil
-					}
-					if err != nil {
-						return err
-					} else {
-						if tool.CheckIsString(&retData) {
-							msg.Data = retData
-						}
-					}
-
-					msg.Data = p2pMiddleNodeTransferData(&msg, *itemClient, msg.Data, retData)
-					if len(msg.Data) == 0 {
-						return nil
-					}
-
-					fromId := msg.SenderUserPeerId + "@" + P2pChannelMap[msg.SenderNodePeerId].Address
-					toId := msg.RecipientUserPeerId + "@" + P2pChannelMap[msg.RecipientNodePeerId].Address
-					jsonMessage := getP2PReplyObj(msg.Data, msg.Type, true, fromId, toId)
-					if itemClient.SendChannel != nil {
-						itemClient.SendChannel <- jsonMessage
-					}
-					if itemClient.IsGRpcRequest && itemClient.GrpcChan != nil {
-						if msg.Type == enum.MsgType_RecvChannelAccept_33 ||
-							msg.Type == enum.MsgType_HTLC_FinishTransferH_43 {
-							go func() {
-								itemClient.GrpcChan <- jsonMessage
-							}()
-						}
-					}
-					return nil
+					return deliverP2PMsg(itemClient, msg, true)
 				}
 			}
+			return queueP2PMsg(msg, true)
 		}
 	}
-	return errors.New(fmt.Sprintf(enum.Tips_user_notExistOrOnline, msg.RecipientUserPeerId))
+	return enum.NewError(enum.ErrorCode_user_notExistOrOnline, msg.RecipientUserPeerId)
 }
 
-func getReplyObj(data string, msgType enum.MsgType, status bool, fromClient, toClient *Client) []byte {
+// deliver the msg from the other user to the online user
+func deliverP2PMsg(itemClient *Client, msg bean.RequestMessage, status bool) error {
+	data := msg.Data
+	if status {
+		//收到数据后，需要对其进行加工
+		retData, isGoOn, err := routerOfP2PNode(msg, data, itemClient)
+		if isGoOn == false {
+			return nil
+		}
+		if err != nil {
+			return err
+		} else {
+			if tool.CheckIsString(&retData) {
+				data = retData
+			}
+		}
+		data = p2pMiddleNodeTransferData(&msg, *itemClient, data, retData)
+		if len(data) == 0 {
+			return nil
+		}
+	}
+
+	fromId := getP2PUserAddress(msg.SenderUserPeerId, msg.SenderNodePeerId)
+	toId := getP2PUserAddress(msg.RecipientUserPeerId, msg.RecipientNodePeerId)
+	jsonMessage := getP2PReplyObj(data, msg.Type, status, fromId, toId, itemClient.takeP2PRequestId(msg.SenderUserPeerId))
+	if itemClient.SendChannel != nil {
+		itemClient.SendChannel <- jsonMessage
+	}
+	if itemClient.IsGRpcRequest && itemClient.GrpcChan != nil {
+		if msg.Type == enum.MsgType_RecvChannelAccept_33 ||
+			msg.Type == enum.MsgType_HTLC_FinishTransferH_43 {
+			go func() {
+				itemClient.GrpcChan <- jsonMessage
+			}()
+		}
+	}
+	return nil
+}
+
+// the node of a queued msg may be disconnected now
+func getP2PUserAddress(userPeerId, nodePeerId string) string {
+	if channel := P2pChannelMap[nodePeerId]; channel != nil {
+		return userPeerId + "@" + channel.Address
+	}
+	return userPeerId + "@" + nodePeerId
+}
+
+func getReplyObj(data string, msgType enum.MsgType, status bool, errorCode enum.ErrorCode, fromClient, toClient *Client, requestId string) []byte {
 	var jsonMessage []byte
 
 	fromId := fromClient.Id
@@ -446,24 +357,27 @@ func getReplyObj(data string, msgType enum.MsgType, status bool, fromClient, toC
 		fromId = fromId + "@" + localServerDest
 	}
 
-	parse := gjson.Parse(data)
-	result := parse.Value()
-	if strings.HasPrefix(data, "{") == false && strings.HasPrefix(data, "[") == false {
-		result = data
-	}
+	jsonMessage, _ = json.Marshal(newReplyMessage(data, msgType, status, errorCode, fromId, toClientId, requestId))
 
-	jsonMessage, _ = json.Marshal(&bean.ReplyMessage{Type: msgType, Status: status, From: fromId, To: toClientId, Result: result})
+	return jsonMessage
+}
 
+func getP2PReplyObj(data string, msgType enum.MsgType, status bool, fromId, toClientId string, requestId string) []byte {
+	jsonMessage, _ := json.Marshal(newReplyMessage(data, msgType, status, enum.ErrorCode_unknown, fromId, toClientId, requestId))
 	return jsonMessage
 }
 
-func getP2PReplyObj(data string, msgType enum.MsgType, status bool, fromId, toClientId string) []byte {
+// the result is the json object of the data, or the data itself. the failed reply has the error code.
+func newReplyMessage(data string, msgType enum.MsgType, status bool, errorCode enum.ErrorCode, fromId, toClientId string, requestId string) *bean.ReplyMessage {
 	parse := gjson.Parse(data)
 	result := parse.Value()
 	if strings.HasPrefix(data, "{") == false && strings.HasPrefix(data, "[") == false {
 		result = data
 	}
 
-	jsonMessage, _ := json.Marshal(&bean.ReplyMessage{Type: msgType, Status: status, From: fromId, To: toClientId, Result: result})
-	return jsonMessage
+	reply := &bean.ReplyMessage{Type: msgType, Status: status, From: fromId, To: toClientId, RequestId: requestId, Result: result}
+	if status == false {
+		reply.ErrorCode = errorCode
+	}
+	return reply
 }
@@ -20,11 +20,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 		} else {
 			node, err := service.ChannelService.AliceOpenChannel(msg, client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				bytes, err := json.Marshal(node)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -39,7 +39,7 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 			msg.Type = enum.MsgType_ChannelOpen_32
 			err := client.sendDataToP2PUser(msg, status, data)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				status = false
 				msg.Type = enum.MsgType_RecvChannelAccept_33
 				client.SendToMyself(msg.Type, status, data)
@@ -49,11 +49,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_ChannelOpen_AllItem_3150:
 		pageData, err := service.ChannelService.AllItem(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(pageData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -64,11 +64,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_ChannelOpen_ItemByTempId_3151:
 		node, err := service.ChannelService.GetChannelByTemporaryChanId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -79,7 +79,7 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_ChannelOpen_Count_3152:
 		node, err := service.ChannelService.TotalCount(*client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = strconv.Itoa(node)
 			status = true
@@ -89,11 +89,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_ChannelOpen_DelItemByTempId_3153:
 		node, err := service.ChannelService.DelChannelByTemporaryChanId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -104,11 +104,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_GetChannelInfoByChannelId_3154:
 		node, err := service.ChannelService.GetChannelInfoByChannelId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -119,11 +119,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_GetChannelInfoByDbId_3155:
 		node, err := service.ChannelService.GetChannelInfoById(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -134,11 +134,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_CheckChannelAddessExist_3156:
 		node, err := service.ChannelService.BobCheckChannelAddressExist(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -150,18 +150,18 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_SendChannelAccept_33:
 		node, err := service.ChannelService.BobAcceptChannel(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 				msg.Type = enum.MsgType_ChannelAccept_33
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -172,11 +172,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_SendCloseChannelRequest_38:
 		node, err := service.ChannelService.ForceCloseChannel(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -192,11 +192,11 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 	case enum.MsgType_SendCloseChannelSign_39:
 		node, err := service.ChannelService.SignCloseChannel(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -204,7 +204,7 @@ func (client *Client) ChannelModule(msg bean.RequestMessage) (enum.SendTargetTyp
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 		}
@@ -20,7 +20,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 		if client.User.IsAdmin {
 			err := admin.RsmcAliceCreateTx(&msg, client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				log.Println(data)
 				msg.Type = enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351
 				client.SendToMyself(msg.Type, status, data)
@@ -29,11 +29,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 		}
 		retData, needSign, err := service.CommitmentTxService.CommitmentTransactionCreated(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(retData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -44,7 +44,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 				msg.Type = enum.MsgType_CommitmentTx_CommitmentTransactionCreated_351
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -52,14 +52,14 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 			if client.User.IsAdmin {
 				signedDataForC2a, err := admin.RsmcAliceFirstSignC2a(retData, client.User)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				} else {
 					marshal, _ := json.Marshal(signedDataForC2a)
 					msg.Data = string(marshal)
 					toAlice, retData, err := service.CommitmentTxService.OnAliceSignC2aRawTxAtAliceSide(msg, client.User)
 					if err != nil {
-						data = err.Error()
+						data = client.errorData(err)
 						status = false
 					} else {
 						bytes, _ := json.Marshal(retData)
@@ -67,13 +67,13 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 						data = string(bytes)
 						err = client.sendDataToP2PUser(msg, status, data)
 						if err != nil {
-							data = err.Error()
+							data = client.errorData(err)
 							status = false
 						}
 
 						bytes, err := json.Marshal(toAlice)
 						if err != nil {
-							data = err.Error()
+							data = client.errorData(err)
 						} else {
 							data = string(bytes)
 							status = true
@@ -91,11 +91,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_ClientSign_CommitmentTx_AliceSignC2a_360:
 		toAlice, retData, err := service.CommitmentTxService.OnAliceSignC2aRawTxAtAliceSide(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(retData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -105,7 +105,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 				msg.Type = enum.MsgType_CommitmentTx_CommitmentTransactionCreated_351
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -113,7 +113,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 			if status {
 				bytes, err := json.Marshal(toAlice)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -126,7 +126,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_ItemsByChanId_3200:
 		nodes, count, err := service.CommitmentTxService.GetItemsByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			page := make(map[string]interface{})
 			page["count"] = len(nodes)
@@ -134,7 +134,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 			page["body"] = nodes
 			bytes, err := json.Marshal(page)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -145,15 +145,15 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 		id, err := strconv.Atoi(msg.Data)
 		if err != nil {
 			log.Println(err)
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			node, err := service.CommitmentTxService.GetItemById(id, *client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				bytes, err := json.Marshal(node)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -164,7 +164,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_Count_3202:
 		count, err := service.CommitmentTxService.TotalCount(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = strconv.Itoa(count)
 			status = true
@@ -173,11 +173,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_LatestCommitmentTxByChanId_3203:
 		node, err := service.CommitmentTxService.GetLatestCommitmentTxByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -187,11 +187,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_LatestRDByChanId_3204:
 		node, err := service.CommitmentTxService.GetLatestRDTxByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -201,11 +201,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_LatestBRByChanId_3205:
 		node, err := service.CommitmentTxService.GetLatestBRTxByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -215,7 +215,7 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_DelItemByChanId_3209:
 		err := service.CommitmentTxService.DelItemByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = "success"
 			status = true
@@ -224,11 +224,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_SendSomeCommitmentById_3206:
 		node, err := service.CommitmentTxService.SendSomeCommitmentById(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -238,11 +238,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_AllRDByChanId_3207:
 		node, err := service.CommitmentTxService.GetAllRDByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -252,11 +252,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_CommitmentTx_AllBRByChanId_3208:
 		node, err := service.CommitmentTxService.GetAllBRByChannelId(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -266,11 +266,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_362:
 		node, err := service.CommitmentTxService.OnAliceSignedC2bTxAtAliceSide(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -280,11 +280,11 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 	case enum.MsgType_ClientSign_CommitmentTx_AliceSignC2b_Rd_363:
 		aliceData, bobData, _, err := service.CommitmentTxService.OnAliceSignedC2b_RDTxAtAliceSide(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bobBytes, err := json.Marshal(bobData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bobBytes)
 				status = true
@@ -293,14 +293,14 @@ func (client *Client) CommitmentTxModule(msg bean.RequestMessage) (enum.SendTarg
 				msg.Type = enum.MsgType_CommitmentTxSigned_SecondToBobSign_353
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
 
 			aliceBytes, err := json.Marshal(aliceData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(aliceBytes)
 				status = true
@@ -319,11 +319,11 @@ func (client *Client) commitmentTxSignModule(msg bean.RequestMessage) (enum.Send
 	case enum.MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352:
 		retData, needSignC2b, err := service.CommitmentTxSignedService.RevokeAndAcknowledgeCommitmentTransaction(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(retData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -335,7 +335,7 @@ func (client *Client) commitmentTxSignModule(msg bean.RequestMessage) (enum.Send
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 		}
@@ -345,11 +345,11 @@ func (client *Client) commitmentTxSignModule(msg bean.RequestMessage) (enum.Send
 	case enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_361:
 		toBobData, retData, err := service.CommitmentTxSignedService.OnBobSignC2bTransactionAtBobSide(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(retData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -360,14 +360,14 @@ func (client *Client) commitmentTxSignModule(msg bean.RequestMessage) (enum.Send
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 
 			if status {
 				bytes, err := json.Marshal(toBobData)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -379,11 +379,11 @@ func (client *Client) commitmentTxSignModule(msg bean.RequestMessage) (enum.Send
 	case enum.MsgType_ClientSign_CommitmentTx_BobSignC2b_Rd_364:
 		retData, err := service.CommitmentTxSignedService.BobSignC2bRdAtBobSide(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(retData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -0,0 +1,138 @@
+package lightclient
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/service"
+)
+
+// the subscriptions are read by the goroutine of the event listener
+var subscriptionLock sync.RWMutex
+
+// the max count of the events waiting for a client, the events are dropped if the client reads too slowly
+const eventQueueSize = 256
+
+func init() {
+	service.EventService.AddListener(pushEventToClient)
+}
+
+// push the event to the websocket client of the user, if it subscribes the event.
+// the listener of the events must not wait for a slow client, so the event is queued without blocking.
+func pushEventToClient(event bean.Event) {
+	client := GlobalWsClientManager.OnlineClientMap[event.UserPeerId]
+	if client == nil || client.events == nil || client.isSubscribed(event.Type) == false {
+		return
+	}
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	select {
+	case client.events <- getReplyObj(string(bytes), enum.MsgType_Event_Push_2018, true, enum.ErrorCode_none, client, client, ""):
+	default:
+		log.Println("the event queue of", event.UserPeerId, "is full, drop the event", event.Type)
+	}
+}
+
+func (client *Client) isSubscribed(eventType enum.EventType) bool {
+	subscriptionLock.RLock()
+	defer subscriptionLock.RUnlock()
+	return client.subscriptions[eventType]
+}
+
+// subscribe the event types, all types if it is empty
+func (client *Client) subscribeEvents(eventTypes []enum.EventType) error {
+	if len(eventTypes) == 0 {
+		eventTypes = allEventTypes
+	}
+	for _, eventType := range eventTypes {
+		if enum.CheckEventTypeExist(eventType) == false {
+			return enum.NewError(enum.ErrorCode_event_wrongType, string(eventType))
+		}
+	}
+	subscriptionLock.Lock()
+	defer subscriptionLock.Unlock()
+	if client.subscriptions == nil {
+		client.subscriptions = make(map[enum.EventType]bool)
+	}
+	for _, eventType := range eventTypes {
+		client.subscriptions[eventType] = true
+	}
+	return nil
+}
+
+// unsubscribe the event types, all types if it is empty
+func (client *Client) unsubscribeEvents(eventTypes []enum.EventType) {
+	subscriptionLock.Lock()
+	defer subscriptionLock.Unlock()
+	if len(eventTypes) == 0 {
+		client.subscriptions = nil
+		return
+	}
+	for _, eventType := range eventTypes {
+		delete(client.subscriptions, eventType)
+	}
+}
+
+func (client *Client) getSubscriptions() []enum.EventType {
+	subscriptionLock.RLock()
+	defer subscriptionLock.RUnlock()
+	eventTypes := make([]enum.EventType, 0)
+	for _, eventType := range allEventTypes {
+		if client.subscriptions[eventType] {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	return eventTypes
+}
+
+var allEventTypes = []enum.EventType{
+	enum.EventType_ChannelState,
+	enum.EventType_CommitmentTxSigned,
+	enum.EventType_HtlcAdded,
+	enum.EventType_HtlcSettled,
+	enum.EventType_HtlcFailed,
+	enum.EventType_HtlcCloseRequired,
+	enum.EventType_InvoicePaid,
+	enum.EventType_BreachRemedySent,
+	enum.EventType_PaymentSucceeded,
+	enum.EventType_PaymentFailed,
+	enum.EventType_PaymentRetried,
+}
+
+func (client *Client) eventModule(msg bean.RequestMessage) (enum.SendTargetType, []byte, bool) {
+	status := false
+	var sendType = enum.SendTargetType_SendToSomeone
+	data := ""
+	switch msg.Type {
+	case enum.MsgType_Event_Subscribe_2016, enum.MsgType_Event_Unsubscribe_2017:
+		reqData := &bean.EventSubscription{}
+		var err error
+		if len(msg.Data) > 0 {
+			err = json.Unmarshal([]byte(msg.Data), reqData)
+		}
+		if err == nil {
+			if msg.Type == enum.MsgType_Event_Subscribe_2016 {
+				err = client.subscribeEvents(reqData.EventTypes)
+			} else {
+				client.unsubscribeEvents(reqData.EventTypes)
+			}
+		}
+		if err != nil {
+			data = client.errorData(err)
+		} else {
+			bytes, _ := json.Marshal(&bean.EventSubscription{EventTypes: client.getSubscriptions()})
+			data = string(bytes)
+			status = true
+		}
+		client.SendToMyself(msg.Type, status, data)
+	default:
+		sendType = enum.SendTargetType_SendToNone
+	}
+	return sendType, []byte(data), status
+}
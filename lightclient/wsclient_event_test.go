@@ -0,0 +1,46 @@
+package lightclient
+
+import (
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+)
+
+func TestPushEventToClient(t *testing.T) {
+	client := &Client{Id: "client1", User: &bean.User{PeerId: "user1"}, events: make(chan []byte, 1)}
+	GlobalWsClientManager.OnlineClientMap["user1"] = client
+	defer delete(GlobalWsClientManager.OnlineClientMap, "user1")
+
+	event := bean.Event{Type: enum.EventType_InvoicePaid, UserPeerId: "user1"}
+	pushEventToClient(event)
+	if len(client.events) > 0 {
+		t.Fatal("push the event which is not subscribed")
+	}
+
+	if err := client.subscribeEvents([]enum.EventType{"unknown"}); err == nil {
+		t.Fatal("subscribe an unknown event type")
+	}
+	if err := client.subscribeEvents([]enum.EventType{enum.EventType_InvoicePaid}); err != nil {
+		t.Fatal(err)
+	}
+	pushEventToClient(event)
+	if len(client.events) != 1 {
+		t.Fatal("the subscribed event is not pushed")
+	}
+	<-client.events
+
+	// the event is dropped when the queue of the slow client is full
+	client.events <- nil
+	pushEventToClient(event)
+	if len(client.events) != 1 {
+		t.Fatal("the event is queued when the queue is full")
+	}
+	<-client.events
+
+	client.unsubscribeEvents(nil)
+	pushEventToClient(event)
+	if len(client.events) > 0 {
+		t.Fatal("push the event after unsubscribe")
+	}
+}
@@ -24,11 +24,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_SendBtcFundingCreated_340:
 		node, targetUser, err := service.FundingTransactionService.BtcFundingCreated(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -39,7 +39,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 				msg.Type = enum.MsgType_FundingCreate_BtcFundingCreated_340
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -61,15 +61,15 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 						err = client.sendDataToP2PUser(msg, status, data)
 						if err != nil {
 							status = false
-							data = err.Error()
+							data = client.errorData(err)
 						}
 					} else {
 						status = false
-						data = err.Error()
+						data = client.errorData(err)
 					}
 				} else {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 				if status == false {
 					msg.Type = enum.MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341
@@ -81,11 +81,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_ClientSign_Duplex_BtcFundingMinerRDTx_341:
 		node, _, err := service.FundingTransactionService.OnAliceSignBtcFundingMinerFeeRedeemTx(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -95,7 +95,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 			msg.Type = enum.MsgType_FundingCreate_BtcFundingCreated_340
 			err = client.sendDataToP2PUser(msg, status, data)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				status = false
 			}
 		}
@@ -104,11 +104,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_AllItem_3104:
 		node, err := service.FundingTransactionService.BtcFundingAllItem(*client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -124,11 +124,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 		}
 		node, err := service.FundingTransactionService.BtcFundingItemById(id, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -139,11 +139,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_ItemByTempChannelId_3106:
 		node, err := service.FundingTransactionService.BtcFundingItemByTempChannelId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -154,11 +154,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_ItemByChannelId_3111:
 		node, err := service.FundingTransactionService.BtcFundingItemByChannelId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -169,11 +169,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_RDAllItem_3107:
 		node, err := service.FundingTransactionService.BtcFundingRDAllItem(*client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -189,11 +189,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 		}
 		node, err := service.FundingTransactionService.BtcFundingRDItemById(id, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -204,11 +204,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_ItemRDByTempChannelId_3109:
 		node, err := service.FundingTransactionService.BtcFundingItemRDByTempChannelId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -219,11 +219,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Btc_ItemRDByTempChannelIdAndTxId_3110:
 		node, err := service.FundingTransactionService.BtcFundingItemRDByTempChannelIdAndFundingTxid(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -238,11 +238,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 		}
 		node, needSign, err := service.FundingTransactionService.AssetFundingCreated(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -250,7 +250,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 					msg.Type = enum.MsgType_FundingCreate_AssetFundingCreated_34
 					err = client.sendDataToP2PUser(msg, status, data)
 					if err != nil {
-						data = err.Error()
+						data = client.errorData(err)
 						status = false
 					}
 				}
@@ -260,14 +260,14 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 		if client.User.IsAdmin && needSign {
 			signedData, err := admin.AliceSignC1a(node, client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				status = false
 			}
 			marshal, _ := json.Marshal(signedData)
 			msg.Data = string(marshal)
 			p2pData, err := service.FundingTransactionService.OnAliceSignC1a(msg, client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				status = false
 			} else {
 				msg.Type = enum.MsgType_FundingCreate_AssetFundingCreated_34
@@ -276,7 +276,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 				data = msg.Data
 				err = client.sendDataToP2PUser(msg, status, msg.Data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 				if status == false {
@@ -291,11 +291,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_ClientSign_AssetFunding_AliceSignC1a_1034:
 		node, err := service.FundingTransactionService.OnAliceSignC1a(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -309,7 +309,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 			msg.Type = enum.MsgType_FundingCreate_AssetFundingCreated_34
 			err = client.sendDataToP2PUser(msg, status, data)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				msg.Type = enum.MsgType_FundingCreate_AssetFundingCreated_34
 				client.SendToMyself(msg.Type, status, data)
 			}
@@ -318,11 +318,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_ClientSign_AssetFunding_AliceSignRD_1134:
 		node, err := service.FundingTransactionService.OnAliceSignedRdAtAliceSide(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -333,11 +333,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Asset_AllItem_3100:
 		node, err := service.FundingTransactionService.AssetFundingAllItem(*client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -349,15 +349,15 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 		id, err := strconv.Atoi(msg.Data)
 		if err != nil {
 			log.Println(err)
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			node, err := service.FundingTransactionService.AssetFundingItemById(id, *client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				bytes, err := json.Marshal(node)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -369,11 +369,11 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Asset_ItemByChannelId_3102:
 		node, err := service.FundingTransactionService.AssetFundingItemByChannelId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -384,7 +384,7 @@ func (client *Client) FundingTransactionModule(msg bean.RequestMessage) (enum.Se
 	case enum.MsgType_FundingCreate_Asset_Count_3103:
 		count, err := service.FundingTransactionService.AssetFundingTotalCount(*client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = strconv.Itoa(count)
 			status = true
@@ -404,11 +404,11 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 	case enum.MsgType_FundingSign_SendBtcSign_350:
 		node, funder, err := service.FundingTransactionService.FundingBtcTxSigned(msg, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(node)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -427,7 +427,7 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 			}
 			err = client.sendDataToP2PUser(msg, status, data)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 				status = false
 			}
 			if status == false {
@@ -437,12 +437,12 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 	case enum.MsgType_FundingSign_SendAssetFundingSigned_35: //get openChannelReq from funder then send to fundee  create a funding tx
 		node, err := service.FundingTransactionService.AssetFundingSigned(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		}
 
 		bytes, err := json.Marshal(node)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		}
 		if len(data) == 0 {
 			data = string(bytes)
@@ -453,11 +453,11 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 	case enum.MsgType_ClientSign_AssetFunding_RdAndBr_1035:
 		aliceData, bobData, err := service.FundingTransactionService.OnBobSignedRDAndBR(msg.Data, client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(aliceData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -466,7 +466,7 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 				msg.Type = enum.MsgType_FundingSign_AssetFundingSigned_35
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -474,7 +474,7 @@ func (client *Client) fundingSignModule(msg bean.RequestMessage) (enum.SendTarge
 			if status {
 				bytes, err = json.Marshal(bobData)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -496,18 +496,18 @@ func channelFund(client Client, msg bean.RequestMessage) (status bool, data stri
 	channelInfo, err := service.FundingTransactionService.CheckChannelFund(msg, client.User)
 	if err != nil {
 		status = false
-		data = err.Error()
+		data = client.errorData(err)
 	} else {
 		funding := &bean.SendRequestFunding{}
 		_ = json.Unmarshal([]byte(msg.Data), funding)
-		minerFee := 0.00001
+		minerFee := omnicore.GetMinerFeeByPurpose(omnicore.FeePurpose_Funding, 1)
 		btcAmount, _ := decimal.NewFromFloat(funding.BtcAmount).Div(decimal.NewFromFloat(3.0)).Sub(decimal.NewFromFloat(minerFee)).Round(8).Float64()
 		for i := 0; i < 3; i++ {
 			go func() {
 				resp, err := omnicore.BtcCreateRawTransaction(channelInfo.FundingAddress, []bean.TransactionOutputItem{{channelInfo.ChannelAddress, btcAmount}}, minerFee, 0, nil)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					sendInfo := &bean.FundingBtc{}
 					sendInfo.FromAddress = channelInfo.FundingAddress
@@ -531,7 +531,7 @@ func channelFund(client Client, msg bean.RequestMessage) (status bool, data stri
 
 		respNode, err := omnicore.OmniCreateRawTransaction(channelInfo.FundingAddress, channelInfo.ChannelAddress, funding.PropertyId, funding.AssetAmount, minerFee)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 			status = false
 		} else {
 			sendInfo := &bean.FundingBtc{}
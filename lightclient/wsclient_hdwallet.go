@@ -16,7 +16,7 @@ func (client *Client) HdWalletModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_GetMnemonic_2004:
 		mnemonic, err := service.HDWalletService.Bip39GenMnemonic(256)
 		if err != nil { //get  successful.
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = mnemonic
 			status = true
@@ -26,11 +26,11 @@ func (client *Client) HdWalletModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Mnemonic_CreateAddress_3000:
 		wallet, err := service.HDWalletService.CreateNewAddress(client.User)
 		if err != nil { //get  successful.
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(wallet)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -46,11 +46,11 @@ func (client *Client) HdWalletModule(msg bean.RequestMessage) (enum.SendTargetTy
 		} else {
 			wallet, err := service.HDWalletService.GetAddressByIndex(client.User, uint32(index))
 			if err != nil { //get  successful.
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				bytes, err := json.Marshal(wallet)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
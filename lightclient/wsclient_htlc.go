@@ -5,9 +5,9 @@ import (
 	"github.com/omnilaboratory/obd/admin"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
-	conn2tracker "github.com/omnilaboratory/obd/conn"
 	"github.com/omnilaboratory/obd/service"
 	"log"
+	"strings"
 )
 
 var tempClientMap = make(map[string]*Client)
@@ -24,32 +24,72 @@ func htlcTrackerDealModule(msg bean.RequestMessage) {
 	case enum.MsgType_Tracker_GetHtlcPath_351:
 		respond, err := service.HtlcForwardTxService.GetResponseFromTrackerOfPayerRequestFindPath(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, _ := json.Marshal(respond)
 			data = string(bytes)
 			status = true
 			if client.User.IsAdmin {
 				invoiceInfo := respond.(map[string]interface{})
-				amountToPayee := invoiceInfo["amount"].(float64)
-				amount := invoiceInfo["amount_and_fee"].(float64)
-				invoiceInfo["amount"] = amount
-				invoiceInfo["amount_to_payee"] = amountToPayee
-				newMsg := bean.RequestMessage{Type: enum.MsgType_HTLC_SendAddHTLC_40}
-				newMsg.RecipientUserPeerId = invoiceInfo["next_node_peerId"].(string)
-				newMsg.RecipientNodePeerId = conn2tracker.GetUserP2pNodeId(newMsg.RecipientUserPeerId)
-				newMsg.SenderNodePeerId = client.User.P2PLocalPeerId
-				newMsg.SenderUserPeerId = client.User.PeerId
-				marshal, _ := json.Marshal(invoiceInfo)
-				newMsg.Data = string(marshal)
-				_, bytes, status = client.HtlcHModule(newMsg)
-				data = string(bytes)
+				if parts, ok := invoiceInfo["parts"].([]map[string]interface{}); ok {
+					// the parts of a multi-path payment share the h, the payee releases r when all of them arrive
+					for i, part := range parts {
+						part["h"] = invoiceInfo["h"]
+						part["is_private"] = invoiceInfo["is_private"]
+						part["property_id"] = invoiceInfo["property_id"]
+						part["memo"] = invoiceInfo["memo"]
+						bytes, status = client.addHtlcOfPath(part)
+						data = string(bytes)
+						if status == false {
+							// the parts after the failed one are not sent, the payment is retried when the parts sent are back
+							for _, rest := range parts[i+1:] {
+								service.PaymentService.OnAttemptFailed(invoiceInfo["h"].(string), rest["routing_packet"].(string), "", "", client.GetError(data).Error(), *client.User)
+							}
+							break
+						}
+					}
+				} else {
+					bytes, status = client.addHtlcOfPath(invoiceInfo)
+					data = string(bytes)
+				}
 			}
 		}
 		client.SendToMyself(enum.MsgType_HTLC_FindPath_401, status, data)
 	}
 }
 
+// the admin user pays by the path found by the tracker, the amount of the path is the amount to the payee and the fees
+func (client *Client) addHtlcOfPath(invoiceInfo map[string]interface{}) ([]byte, bool) {
+	amountToPayee := invoiceInfo["amount"].(float64)
+	amount := invoiceInfo["amount_and_fee"].(float64)
+	invoiceInfo["amount"] = amount
+	invoiceInfo["amount_to_payee"] = amountToPayee
+	newMsg := bean.RequestMessage{Type: enum.MsgType_HTLC_SendAddHTLC_40}
+	newMsg.RecipientUserPeerId = invoiceInfo["next_node_peerId"].(string)
+	newMsg.RecipientNodePeerId, _ = service.GetUserP2pNodeId(newMsg.RecipientUserPeerId)
+	newMsg.SenderNodePeerId = client.User.P2PLocalPeerId
+	newMsg.SenderUserPeerId = client.User.PeerId
+	marshal, _ := json.Marshal(invoiceInfo)
+	newMsg.Data = string(marshal)
+	_, bytes, status := client.HtlcHModule(newMsg)
+	return bytes, status
+}
+
+// the htlc of the payer is not added, the payment is retried by the other paths without the failed channel or node
+func (client *Client) retryPaymentOfHtlc(addHtlcData string, nextNodePeerId string, peerFailed bool, err error) {
+	requestData := bean.CreateHtlcTxForC3a{}
+	if json.Unmarshal([]byte(addHtlcData), &requestData) != nil || len(requestData.RoutingPacket) == 0 {
+		return
+	}
+	failedChannelId := strings.Split(requestData.RoutingPacket, ",")[0]
+	failedPeerId := ""
+	if peerFailed || enum.ErrorCodeOf(err) == enum.ErrorCode_user_notExistOrOnline {
+		failedChannelId = ""
+		failedPeerId = nextNodePeerId
+	}
+	service.PaymentService.OnAttemptFailed(requestData.H, requestData.RoutingPacket, failedChannelId, failedPeerId, err.Error(), *client.User)
+}
+
 //htlc h module
 func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType, []byte, bool) {
 	status := false
@@ -61,14 +101,14 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 		htlcHRequest := &bean.HtlcRequestInvoice{}
 		err := json.Unmarshal([]byte(msg.Data), htlcHRequest)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			msg.SenderNodePeerId = client.User.P2PLocalPeerId
 			msg.SenderUserPeerId = client.User.PeerId
 			if client.User.IsAdmin {
 				err := admin.HtlcCreateInvoice(&msg, client.User)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					client.SendToMyself(msg.Type, status, data)
 					sendType = enum.SendTargetType_SendToSomeone
 					break
@@ -76,7 +116,7 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 			}
 			respond, err := service.HtlcForwardTxService.CreateHtlcInvoice(msg, *client.User)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				status = true
 				data = respond.(string)
@@ -88,7 +128,7 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 		tempClientMap[client.User.PeerId] = client
 		respond, isPrivate, err := service.HtlcForwardTxService.PayerRequestFindPath(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 			client.SendToMyself(msg.Type, status, data)
 		} else {
 			bytes, _ := json.Marshal(respond)
@@ -97,19 +137,7 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 			if isPrivate {
 				client.SendToMyself(msg.Type, status, data)
 				if client.User.IsAdmin {
-					invoiceInfo := respond.(map[string]interface{})
-					amountToPayee := invoiceInfo["amount"].(float64)
-					amount := invoiceInfo["amount_and_fee"].(float64)
-					invoiceInfo["amount"] = amount
-					invoiceInfo["amount_to_payee"] = amountToPayee
-					newMsg := bean.RequestMessage{Type: enum.MsgType_HTLC_SendAddHTLC_40}
-					newMsg.RecipientUserPeerId = invoiceInfo["next_node_peerId"].(string)
-					newMsg.RecipientNodePeerId = conn2tracker.GetUserP2pNodeId(newMsg.RecipientUserPeerId)
-					newMsg.SenderNodePeerId = client.User.P2PLocalPeerId
-					newMsg.SenderUserPeerId = client.User.PeerId
-					marshal, _ := json.Marshal(invoiceInfo)
-					newMsg.Data = string(marshal)
-					sendType, bytes, status = client.HtlcHModule(newMsg)
+					bytes, status = client.addHtlcOfPath(respond.(map[string]interface{}))
 					data = string(bytes)
 				}
 			}
@@ -118,7 +146,7 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ParseInvoice_403:
 		invoice, err := service.HtlcForwardTxService.ParseInvoice(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, _ := json.Marshal(invoice)
 			data = string(bytes)
@@ -128,26 +156,31 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 		sendType = enum.SendTargetType_SendToSomeone
 
 	case enum.MsgType_HTLC_SendAddHTLC_40:
+		addHtlcData := msg.Data
+		// the next node is not reachable, it is excluded from the retry of the payment instead of the channel
+		peerFailed := false
 		if client.User.IsAdmin {
 			err := admin.HtlcBeforeAliceAddHtlcAtAliceSide(&msg, client.User)
 			if err == nil {
 				if P2pChannelMap[msg.RecipientNodePeerId] == nil {
 					err = ScanAndConnNode(msg.RecipientNodePeerId)
+					peerFailed = err != nil
 				}
 			}
 			if err != nil {
 				msg.Type = enum.MsgType_HTLC_SendAddHTLC_40
-				client.SendToMyself(msg.Type, false, err.Error())
+				client.sendErrorToMyself(msg.Type, err)
+				client.retryPaymentOfHtlc(addHtlcData, msg.RecipientUserPeerId, peerFailed, err)
 				break
 			}
 		}
 		respond, needSign, err := service.HtlcForwardTxService.AliceAddHtlcAtAliceSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(respond)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -158,7 +191,8 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 					err = client.sendDataToP2PUser(msg, true, data)
 					if err != nil {
 						status = false
-						data = err.Error()
+						data = client.errorData(err)
+						peerFailed = true
 					}
 				}
 			}
@@ -175,11 +209,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 					_, toBob, err := service.HtlcForwardTxService.OnAliceSignedC3aAtAliceSide(msg, *client.User)
 					if err != nil {
 						log.Println(err)
-						data = err.Error()
+						data = client.errorData(err)
 					} else {
 						bytes, err := json.Marshal(toBob)
 						if err != nil {
-							data = err.Error()
+							data = client.errorData(err)
 						} else {
 							data = string(bytes)
 							status = true
@@ -190,7 +224,8 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 							err = client.sendDataToP2PUser(msg, true, data)
 							if err != nil {
 								status = false
-								data = err.Error()
+								data = client.errorData(err)
+								peerFailed = true
 							}
 						}
 					}
@@ -199,15 +234,18 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 		}
 		msg.Type = enum.MsgType_HTLC_SendAddHTLC_40
 		client.SendToMyself(msg.Type, status, data)
+		if status == false {
+			client.retryPaymentOfHtlc(addHtlcData, msg.RecipientUserPeerId, peerFailed, client.GetError(data))
+		}
 
 	case enum.MsgType_HTLC_ClientSign_Alice_C3a_100:
 		toAlice, toBob, err := service.HtlcForwardTxService.OnAliceSignedC3aAtAliceSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toBob)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -218,14 +256,16 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 				err = client.sendDataToP2PUser(msg, true, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					// the next node is not reachable, the payment is retried without it like the one of the admin user
+					client.retryPaymentOfHtlc(data, msg.RecipientUserPeerId, true, err)
+					data = client.errorData(err)
 				}
 			}
 
 			if status {
 				bytes, err := json.Marshal(toAlice)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -238,11 +278,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_SendAddHTLCSigned_41:
 		returnData, err := service.HtlcForwardTxService.BobSignedAddHtlcAtBobSide(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(returnData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -252,11 +292,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ClientSign_Bob_C3b_101:
 		toAlice, toBob, err := service.HtlcForwardTxService.OnBobSignedC3bAtBobSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toAlice)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -266,13 +306,13 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 			if status {
 				bytes, err := json.Marshal(toBob)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -284,11 +324,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ClientSign_Alice_C3b_102:
 		returnData, err := service.HtlcForwardTxService.OnAliceSignC3bAtAliceSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(returnData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -298,11 +338,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ClientSign_Alice_C3bSub_103:
 		toAlice, toBob, err := service.HtlcForwardTxService.OnAliceSignedC3bSubTxAtAliceSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toBob)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -313,13 +353,13 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 			if status {
 				bytes, err := json.Marshal(toAlice)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -331,11 +371,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ClientSign_Bob_C3bSub_104:
 		returnData, err := service.HtlcForwardTxService.OnBobSignedC3bSubTxAtBobSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(returnData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -345,11 +385,11 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 	case enum.MsgType_HTLC_ClientSign_Alice_He_105:
 		toAlice, toBob, err := service.HtlcForwardTxService.OnBobSignHtRdAtBobSide_42(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toAlice)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -359,13 +399,13 @@ func (client *Client) HtlcHModule(msg bean.RequestMessage) (enum.SendTargetType,
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
 					status = false
-					data = err.Error()
+					data = client.errorData(err)
 				}
 			}
 			if status {
 				bytes, err := json.Marshal(toBob)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -387,11 +427,11 @@ func (client *Client) htlcQueryModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_Htlc_GetLatestHT1aOrHE1b_3250:
 		respond, err := service.HtlcQueryTxManager.GetLatestHT1aOrHE1b(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(respond)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -401,17 +441,37 @@ func (client *Client) htlcQueryModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_Htlc_GetHT1aOrHE1bBySomeCommitmentId_3251:
 		respond, err := service.HtlcQueryTxManager.GetHT1aOrHE1bBySomeCommitmentId(msg.Data, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(respond)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 			}
 		}
 		client.SendToMyself(msg.Type, status, data)
+	case enum.MsgType_Htlc_GetPayment_3252:
+		respond, err := service.PaymentService.GetPayment(msg.Data, *client.User)
+		if err != nil {
+			data = client.errorData(err)
+		} else {
+			bytes, _ := json.Marshal(respond)
+			data = string(bytes)
+			status = true
+		}
+		client.SendToMyself(msg.Type, status, data)
+	case enum.MsgType_Htlc_ListPayments_3253:
+		respond, err := service.PaymentService.ListPayments(msg.Data, *client.User)
+		if err != nil {
+			data = client.errorData(err)
+		} else {
+			bytes, _ := json.Marshal(respond)
+			data = string(bytes)
+			status = true
+		}
+		client.SendToMyself(msg.Type, status, data)
 	default:
 		sendType = enum.SendTargetType_SendToNone
 	}
@@ -427,11 +487,11 @@ func (client *Client) htlcTxModule(msg bean.RequestMessage) (enum.SendTargetType
 	case enum.MsgType_HTLC_SendVerifyR_45:
 		respond, err := service.HtlcBackwardTxService.SendRToPreviousNodeAtBobSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(respond)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -441,18 +501,18 @@ func (client *Client) htlcTxModule(msg bean.RequestMessage) (enum.SendTargetType
 	case enum.MsgType_HTLC_ClientSign_Bob_HeSub_106:
 		respond, err := service.HtlcBackwardTxService.OnBobSignedHeRdAtBobSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(respond)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 				msg.Type = enum.MsgType_HTLC_VerifyR_45
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -462,18 +522,18 @@ func (client *Client) htlcTxModule(msg bean.RequestMessage) (enum.SendTargetType
 	case enum.MsgType_HTLC_ClientSign_Alice_HeSub_46:
 		toAlice, toBob, err := service.HtlcBackwardTxService.OnAliceSignedHeRdAtAliceSide(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toBob)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 				msg.Type = enum.MsgType_HTLC_SendHerdHex_46
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -485,6 +545,23 @@ func (client *Client) htlcTxModule(msg bean.RequestMessage) (enum.SendTargetType
 		}
 		msg.Type = enum.MsgType_HTLC_ClientSign_Alice_HeSub_46
 		client.SendToMyself(msg.Type, status, data)
+	case enum.MsgType_HTLC_SendFailHtlc_47:
+		toSender, err := service.HtlcFailTxService.SendFailToPreviousNode(msg, *client.User)
+		if err != nil {
+			data = client.errorData(err)
+		} else {
+			bytes, _ := json.Marshal(toSender)
+			data = string(bytes)
+			status = true
+			msg.Type = enum.MsgType_HTLC_FailHtlc_47
+			err = client.sendDataToP2PUser(msg, status, data)
+			if err != nil {
+				data = client.errorData(err)
+				status = false
+			}
+		}
+		msg.Type = enum.MsgType_HTLC_SendFailHtlc_47
+		client.SendToMyself(msg.Type, status, data)
 	}
 	return sendType, []byte(data), status
 }
@@ -498,11 +575,11 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_SendRequestCloseCurrTx_49:
 		outData, needSign, err := service.HtlcCloseTxService.RequestCloseHtlc(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(outData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -510,7 +587,7 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 					msg.Type = enum.MsgType_HTLC_Close_RequestCloseCurrTx_49
 					err = client.sendDataToP2PUser(msg, status, data)
 					if err != nil {
-						data = err.Error()
+						data = client.errorData(err)
 						status = false
 					}
 				}
@@ -522,11 +599,11 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_ClientSign_Alice_C4a_110:
 		toAlice, toBob, err := service.HtlcCloseTxService.OnAliceSignedCxa(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toBob)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -535,7 +612,7 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 				msg.Type = enum.MsgType_HTLC_Close_RequestCloseCurrTx_49
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -543,7 +620,7 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 			if status {
 				bytes, err = json.Marshal(toAlice)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -557,7 +634,7 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_SendCloseSigned_50:
 		outData, err := service.HtlcCloseTxService.OnBobSignCloseHtlcRequest(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, _ := json.Marshal(outData)
 			data = string(bytes)
@@ -568,11 +645,11 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_ClientSign_Bob_C4b_111:
 		toAlice, toBob, err := service.HtlcCloseTxService.OnBobSignedCxb(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toAlice)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -581,14 +658,14 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 				msg.Type = enum.MsgType_HTLC_CloseHtlcRequestSignBR_50
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
 			if status {
 				bytes, err := json.Marshal(toBob)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -600,11 +677,11 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_ClientSign_Alice_C4b_112:
 		toAlice, err := service.HtlcCloseTxService.OnAliceSignedCxb(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toAlice)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -614,11 +691,11 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_ClientSign_Alice_C4bSub_113:
 		toAlice, toBob, err := service.HtlcCloseTxService.OnAliceSignedCxbBubTx(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(toBob)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
@@ -628,14 +705,14 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 				msg.Type = enum.MsgType_HTLC_CloseHtlcUpdateCnb_51
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
 			if status {
 				bytes, err := json.Marshal(toAlice)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = string(bytes)
 					status = true
@@ -647,7 +724,7 @@ func (client *Client) htlcCloseModule(msg bean.RequestMessage) (enum.SendTargetT
 	case enum.MsgType_HTLC_Close_ClientSign_Bob_C4bSubResult_114:
 		toBob, err := service.HtlcCloseTxService.OnBobSignedCxbSubTx(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, _ := json.Marshal(toBob)
 			data = string(bytes)
@@ -665,18 +742,18 @@ func (client *Client) atomicSwapModule(msg bean.RequestMessage) (enum.SendTarget
 	case enum.MsgType_Atomic_SendSwap_80:
 		outData, err := service.AtomicSwapService.AtomicSwap(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(outData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 				msg.Type = enum.MsgType_Atomic_Swap_80
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -687,18 +764,18 @@ func (client *Client) atomicSwapModule(msg bean.RequestMessage) (enum.SendTarget
 	case enum.MsgType_Atomic_SendSwapAccept_81:
 		outData, err := service.AtomicSwapService.AtomicSwapAccepted(msg, *client.User)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			bytes, err := json.Marshal(outData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = string(bytes)
 				status = true
 				msg.Type = enum.MsgType_Atomic_SwapAccept_81
 				err = client.sendDataToP2PUser(msg, status, data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 					status = false
 				}
 			}
@@ -2,7 +2,6 @@ package lightclient
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
@@ -83,10 +82,14 @@ func FindUserOnLine(msg bean.RequestMessage) (*Client, error) {
 		}
 
 		if msg.RecipientNodePeerId != P2PLocalNodeId {
-			if conn2tracker.GetUserState(msg.RecipientNodePeerId, msg.RecipientUserPeerId) > 0 {
+			state, err := conn2tracker.GetUserState(msg.RecipientNodePeerId, msg.RecipientUserPeerId)
+			if err == nil && state > 0 {
+				return nil, nil
+			}
+			if conn2tracker.IsTrackerUnavailable(err) && service.IsP2PNodeConnected(msg.RecipientNodePeerId) {
 				return nil, nil
 			}
 		}
 	}
-	return nil, errors.New(fmt.Sprintf(enum.Tips_user_notExistOrOnline, msg.RecipientUserPeerId))
+	return nil, enum.NewError(enum.ErrorCode_user_notExistOrOnline, msg.RecipientUserPeerId)
 }
@@ -1,6 +1,7 @@
 package lightclient
 
 import (
+	"context"
 	"encoding/json"
 	"github.com/omnilaboratory/obd/admin"
 	"github.com/omnilaboratory/obd/bean"
@@ -18,9 +19,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	switch msg.Type {
 	case enum.MsgType_Core_GetNewAddress_2101:
 		var label = msg.Data
-		address, err := GetNewAddress(label)
+		address, err := GetNewAddress(context.Background(), label)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = address
 			status = true
@@ -30,7 +31,7 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_GetMiningInfo_2102:
 		result, err := GetMiningInfo()
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = result
 			status = true
@@ -40,7 +41,7 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_GetNetworkInfo_2103:
 		result, err := GetNetworkInfo()
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = result
 			status = true
@@ -50,7 +51,7 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_Omni_ListProperties_2117:
 		result, err := OmniListProperties()
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = result
 			status = true
@@ -62,9 +63,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 		if propertyId == 0 {
 			data = "error propertyId"
 		} else {
-			result, err := OmniGetProperty(propertyId)
+			result, err := OmniGetProperty(context.Background(), propertyId)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = result
 				status = true
@@ -77,9 +78,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 		toAddress := gjson.Get(msg.Data, "to_address").String()
 		propertyId := gjson.Get(msg.Data, "property_id").Int()
 		amount := gjson.Get(msg.Data, "amount").Float()
-		result, err := OmniSend(fromAddress, toAddress, int(propertyId), amount)
+		result, err := OmniSend(context.Background(), fromAddress, toAddress, int(propertyId), amount)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = result
 			status = true
@@ -89,9 +90,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_Omni_GetTransaction_2118:
 		txid := gjson.Get(msg.Data, "txid").String()
 		if tool.CheckIsString(&txid) {
-			result, err := OmniGetTransaction(txid)
+			result, err := OmniGetTransaction(context.Background(), txid)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = result
 				status = true
@@ -104,8 +105,13 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_ListUnspent_2107:
 		address := gjson.Get(msg.Data, "address").String()
 		if tool.CheckIsString(&address) {
-			data = ListUnspent(address)
-			status = true
+			result, err := ListUnspent(context.Background(), address)
+			if err != nil {
+				data = client.errorData(err)
+			} else {
+				data = result
+				status = true
+			}
 		} else {
 			data = "error address"
 		}
@@ -114,9 +120,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_BalanceByAddress_2108:
 		address := gjson.Get(msg.Data, "address").String()
 		if tool.CheckIsString(&address) {
-			balance, err := GetBalanceByAddress(address)
+			balance, err := GetBalanceByAddress(context.Background(), address)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = tool.FloatToString(balance, 8)
 				status = true
@@ -129,9 +135,9 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_Omni_GetBalance_2112:
 		address := gjson.Get(msg.Data, "address").String()
 		if tool.CheckIsAddress(address) {
-			result := OmniGetAllBalancesByAddress(address)
-			if result == "" {
-				data = "empty result"
+			result, err := OmniGetAllBalancesByAddress(address)
+			if err != nil {
+				data = client.errorData(err)
 			} else {
 				data = result
 				status = true
@@ -146,11 +152,11 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 			reqData := &bean.OmniSendIssuanceFixed{}
 			err := json.Unmarshal([]byte(msg.Data), reqData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
-				result, err := OmniSendIssuanceFixed(reqData.FromAddress, reqData.Ecosystem, reqData.DivisibleType, reqData.Name, reqData.Data, reqData.Amount)
+				result, err := OmniSendIssuanceFixed(context.Background(), reqData.FromAddress, reqData.Ecosystem, reqData.DivisibleType, reqData.Name, reqData.Data, reqData.Amount)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = result
 					status = true
@@ -166,11 +172,11 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 			reqData := &bean.OmniSendIssuanceManaged{}
 			err := json.Unmarshal([]byte(msg.Data), reqData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
-				result, err := OmniSendIssuanceManaged(reqData.FromAddress, reqData.Ecosystem, reqData.DivisibleType, reqData.Name, reqData.Data)
+				result, err := OmniSendIssuanceManaged(context.Background(), reqData.FromAddress, reqData.Ecosystem, reqData.DivisibleType, reqData.Name, reqData.Data)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = result
 					status = true
@@ -186,11 +192,11 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 			reqData := &bean.OmniSendGrant{}
 			err := json.Unmarshal([]byte(msg.Data), reqData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
-				result, err := OmniSendGrant(reqData.FromAddress, reqData.PropertyId, reqData.Amount, reqData.Memo)
+				result, err := OmniSendGrant(context.Background(), reqData.FromAddress, reqData.PropertyId, reqData.Amount, reqData.Memo)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = result
 					status = true
@@ -206,11 +212,11 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 			reqData := &bean.OmniSendRevoke{}
 			err := json.Unmarshal([]byte(msg.Data), reqData)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
-				result, err := OmniSendRevoke(reqData.FromAddress, reqData.PropertyId, reqData.Amount, reqData.Memo)
+				result, err := OmniSendRevoke(context.Background(), reqData.FromAddress, reqData.PropertyId, reqData.Amount, reqData.Memo)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					data = result
 					status = true
@@ -224,18 +230,22 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 	case enum.MsgType_Core_GetTransactionByTxid_2122:
 		txid := gjson.Get(msg.Data, "txid").String()
 		if tool.CheckIsString(&txid) {
-			GetTransactionById(txid)
-			data = GetTransactionById(txid)
-			status = true
+			result, err := GetTransactionById(context.Background(), txid)
+			if err != nil {
+				data = client.errorData(err)
+			} else {
+				data = result
+				status = true
+			}
 		} else {
 			data = "error txid"
 		}
 		client.SendToMyself(msg.Type, status, data)
 		sendType = enum.SendTargetType_SendToSomeone
 	case enum.MsgType_Core_SignRawTransaction_2123:
-		result, err := BtcSignRawTransactionFromJson(msg.Data)
+		result, err := BtcSignRawTransactionFromJson(context.Background(), msg.Data)
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			data = result
 			status = true
@@ -253,7 +263,7 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 				sendInfo.Amount > 0 {
 				resp, err := omnicore.BtcCreateRawTransaction(sendInfo.FromAddress, []bean.TransactionOutputItem{{sendInfo.ToAddress, sendInfo.Amount}}, sendInfo.MinerFee, 0, nil)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					if client.User.IsAdmin {
 						resp, _ = admin.AliceSignFundBtc(msg, resp, client.User)
@@ -301,7 +311,7 @@ func (client *Client) omniCoreModule(msg bean.RequestMessage) (enum.SendTargetTy
 				sendInfo.Amount > 0 {
 				respNode, err := omnicore.OmniCreateRawTransaction(sendInfo.FromAddress, sendInfo.ToAddress, sendInfo.PropertyId, sendInfo.Amount, sendInfo.MinerFee)
 				if err != nil {
-					data = err.Error()
+					data = client.errorData(err)
 				} else {
 					respNode, _ = admin.AliceSignFundAsset(msg, respNode, client.User)
 					respNode["is_multisig"] = false
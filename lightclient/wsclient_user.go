@@ -9,6 +9,7 @@ import (
 	"github.com/omnilaboratory/obd/service"
 	"github.com/omnilaboratory/obd/tool"
 	"github.com/tidwall/gjson"
+	"time"
 )
 
 func loginRetData(client Client) string {
@@ -23,6 +24,25 @@ func loginRetData(client Client) string {
 	return string(bytes)
 }
 
+// the nonce of the challenge login expires after it
+const loginNonceTimeout = 2 * time.Minute
+
+func (client *Client) setLoginUser(user *bean.User) {
+	client.User = user
+	GlobalWsClientManager.OnlineClientMap[user.PeerId] = client
+	service.OnlineUserMap[user.PeerId] = user
+}
+
+func (client *Client) logoutCurrUser() error {
+	err := service.UserService.UserLogout(client.User)
+	sendInfoOnUserStateChange(client.User.PeerId)
+	delete(GlobalWsClientManager.OnlineClientMap, client.User.PeerId)
+	delete(service.OnlineUserMap, client.User.PeerId)
+	client.User = nil
+	client.unsubscribeEvents(nil)
+	return err
+}
+
 func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType, []byte, bool) {
 	status := false
 	var sendType = enum.SendTargetType_SendToNone
@@ -38,7 +58,12 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 			isAdmin = service.CheckIsAdmin(loginToken)
 		}
 
-		peerId := tool.GetUserPeerId(mnemonic)
+		peerId, err := service.HDWalletService.GetUserPeerId(mnemonic)
+		if err != nil {
+			client.SendToMyself(msg.Type, status, client.errorData(err))
+			sendType = enum.SendTargetType_SendToSomeone
+			break
+		}
 		if GlobalWsClientManager.OnlineClientMap[peerId] != nil {
 			if GlobalWsClientManager.OnlineClientMap[peerId].User.IsAdmin {
 				client.User = GlobalWsClientManager.OnlineClientMap[peerId].User
@@ -52,12 +77,8 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 			}
 		}
 		if client.User != nil {
-			if client.User.Mnemonic != mnemonic {
-				_ = service.UserService.UserLogout(client.User)
-				sendInfoOnUserStateChange(client.User.PeerId)
-				delete(GlobalWsClientManager.OnlineClientMap, client.User.PeerId)
-				delete(service.OnlineUserMap, client.User.PeerId)
-				client.User = nil
+			if client.User.PeerId != peerId {
+				_ = client.logoutCurrUser()
 			}
 		}
 
@@ -75,7 +96,7 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 			}
 			var err error = nil
 			if GlobalWsClientManager.OnlineClientMap[peerId] != nil {
-				err = errors.New("user has login at other node")
+				err = enum.NewError(enum.ErrorCode_user_loginAtOtherNode)
 			} else {
 				err = service.UserService.UserLogin(&user)
 				if err == nil {
@@ -83,18 +104,107 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 				}
 			}
 			if err == nil {
-				client.User = &user
-				GlobalWsClientManager.OnlineClientMap[user.PeerId] = client
-				service.OnlineUserMap[user.PeerId] = &user
+				client.setLoginUser(&user)
 				data = loginRetData(*client)
 				status = true
 				client.SendToMyself(msg.Type, status, data)
+				client.deliverOutbox()
 				sendType = enum.SendTargetType_SendToExceptMe
 			} else {
-				client.SendToMyself(msg.Type, status, err.Error())
+				client.SendToMyself(msg.Type, status, client.errorData(err))
 				sendType = enum.SendTargetType_SendToSomeone
 			}
 		}
+	case enum.MsgType_UserLoginChallenge_2011:
+		nonce, err := service.HDWalletService.CreateLoginNonce()
+		if err != nil {
+			data = client.errorData(err)
+		} else {
+			client.loginNonce = nonce
+			client.loginNonceAt = time.Now()
+			retData := make(map[string]interface{})
+			retData["nonce"] = nonce
+			retData["node_id"] = P2PLocalNodeId
+			retData["expire_in"] = int(loginNonceTimeout.Seconds())
+			bytes, _ := json.Marshal(retData)
+			data = string(bytes)
+			status = true
+		}
+		client.SendToMyself(msg.Type, status, data)
+		sendType = enum.SendTargetType_SendToSomeone
+	case enum.MsgType_UserLoginWithSignature_2012:
+		xpub := gjson.Get(msg.Data, "xpub").String()
+		signature := gjson.Get(msg.Data, "signature").String()
+
+		var err error = nil
+		nonce := client.loginNonce
+		// the nonce can be used only once
+		client.loginNonce = ""
+		if tool.CheckIsString(&nonce) == false || time.Now().Sub(client.loginNonceAt) > loginNonceTimeout {
+			err = enum.NewError(enum.ErrorCode_user_noLoginChallenge)
+		}
+
+		user := bean.User{
+			WalletXpub:      xpub,
+			P2PLocalAddress: localServerDest,
+			P2PLocalPeerId:  P2PLocalNodeId,
+		}
+		if err == nil {
+			if client.User != nil {
+				_ = client.logoutCurrUser()
+			}
+			err = service.UserService.UserLoginWithXpub(&user, nonce, signature)
+		}
+		if err == nil {
+			sendInfoOnUserStateChange(user.PeerId)
+			client.setLoginUser(&user)
+			data = loginRetData(*client)
+			status = true
+			client.SendToMyself(msg.Type, status, data)
+			client.deliverOutbox()
+			sendType = enum.SendTargetType_SendToExceptMe
+		} else {
+			client.SendToMyself(msg.Type, status, client.errorData(err))
+			sendType = enum.SendTargetType_SendToSomeone
+		}
+	case enum.MsgType_Credential_Bake_2013, enum.MsgType_Credential_Revoke_2014, enum.MsgType_Credential_List_2015:
+		// the admin logs in with the admin login token, or connects with an admin credential
+		if len(client.credential) == 0 && (client.User == nil || client.User.IsAdmin == false) {
+			data = errors.New("you are not the admin or login as admin").Error()
+		} else {
+			var retData interface{}
+			var err error
+			switch msg.Type {
+			case enum.MsgType_Credential_Bake_2013:
+				scopes := make([]string, 0)
+				for _, item := range gjson.Get(msg.Data, "scopes").Array() {
+					scopes = append(scopes, item.String())
+				}
+				caveats := make([]string, 0)
+				for _, item := range gjson.Get(msg.Data, "caveats").Array() {
+					caveats = append(caveats, item.String())
+				}
+				retData, err = service.CredentialService.Bake(scopes, caveats, gjson.Get(msg.Data, "note").String())
+			case enum.MsgType_Credential_Revoke_2014:
+				id := gjson.Get(msg.Data, "id").String()
+				err = service.CredentialService.Revoke(id)
+				retData = id
+			case enum.MsgType_Credential_List_2015:
+				retData, err = service.CredentialService.List()
+			}
+			if err != nil {
+				data = client.errorData(err)
+			} else if str, ok := retData.(string); ok {
+				data = str
+				status = true
+			} else {
+				bytes, _ := json.Marshal(retData)
+				data = string(bytes)
+				status = true
+			}
+		}
+		client.SendToMyself(msg.Type, status, data)
+		sendType = enum.SendTargetType_SendToSomeone
 	case enum.MsgType_User_GetInfo_2009:
 		if client.User != nil {
 			data = loginRetData(*client)
@@ -136,7 +246,7 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 		} else {
 			localP2PAddress, err := connP2PNode(remoteNodeAddress.Str)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				status = true
 				data = localP2PAddress
@@ -151,7 +261,7 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 		} else {
 			err := disConnP2PNode(remoteNodeAddress.Str)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				status = true
 				data = "success"
@@ -160,9 +270,9 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 		client.SendToMyself(msg.Type, status, data)
 		sendType = enum.SendTargetType_SendToSomeone
 	case enum.MsgType_GetObdNodeInfo_2005:
-		bytes, err := json.Marshal(bean.CurrObdNodeInfo)
+		bytes, err := json.Marshal(getObdNodeInfo())
 		if err != nil {
-			data = err.Error()
+			data = client.errorData(err)
 		} else {
 			status = true
 			data = string(bytes)
@@ -183,7 +293,7 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 		if client.User != nil && client.User.IsAdmin {
 			err := service.UpdateAdminLoginToken(oldLoginToken, newLoginToken)
 			if err != nil {
-				data = err.Error()
+				data = client.errorData(err)
 			} else {
 				data = newLoginToken
 				status = true
@@ -205,7 +315,7 @@ func (client *Client) UserModule(msg bean.RequestMessage) (enum.SendTargetType,
 				data = mnemonic
 				status = true
 			} else {
-				data = err.Error()
+				data = client.errorData(err)
 			}
 			client.SendToMyself(msg.Type, status, data)
 			sendType = enum.SendTargetType_SendToSomeone
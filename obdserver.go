@@ -1,17 +1,24 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"github.com/lestrrat-go/file-rotatelogs"
 	"github.com/omnilaboratory/obd/proxy/rpc"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
 	"github.com/omnilaboratory/obd/lightclient"
 	"github.com/omnilaboratory/obd/service"
 	"github.com/omnilaboratory/obd/tool"
@@ -39,20 +46,140 @@ func initObdLog() {
 	log.SetFlags(log.Ldate | log.Lmicroseconds | log.Lshortfile)
 }
 
+// export, import or rotate the node key, return true if one of the tasks was requested
+func manageNodeKey() bool {
+	if len(*config.ExportNodeKey) > 0 {
+		key, err := tool.ExportNodeKey(config.DataDirectory, config.NodeKeyPassphrase)
+		if err == nil {
+			err = ioutil.WriteFile(*config.ExportNodeKey, []byte(key), 0600)
+		}
+		if err != nil {
+			log.Println("fail to export node key:", err)
+		} else {
+			log.Println("export node key to " + *config.ExportNodeKey)
+		}
+		return true
+	}
+	if len(*config.ImportNodeKey) > 0 {
+		content, err := ioutil.ReadFile(*config.ImportNodeKey)
+		if err == nil {
+			_, err = tool.ImportNodeKey(config.DataDirectory, config.NodeKeyPassphrase, strings.TrimSpace(string(content)))
+		}
+		if err != nil {
+			log.Println("fail to import node key:", err)
+		} else {
+			log.Println("import node key, new node id: " + tool.GetObdNodeId())
+		}
+		return true
+	}
+	if *config.RotateNodeKey {
+		_, err := tool.RotateNodeKey(config.DataDirectory, config.NodeKeyPassphrase)
+		if err != nil {
+			log.Println("fail to rotate node key:", err)
+		} else {
+			log.Println("rotate node key, new node id: " + tool.GetObdNodeId())
+		}
+		return true
+	}
+	return false
+}
+
+// bake or revoke an api credential, return true if one of the tasks was requested
+func manageCredential() bool {
+	if len(*config.BakeCredential) > 0 {
+		caveats := make([]string, 0)
+		for _, item := range strings.Split(*config.CredentialCaveats, ";") {
+			if len(strings.TrimSpace(item)) > 0 {
+				caveats = append(caveats, strings.TrimSpace(item))
+			}
+		}
+		token, err := service.CredentialService.Bake(strings.Split(*config.BakeCredential, ","), caveats, "baked by command line")
+		if err != nil {
+			log.Println("fail to bake credential:", err)
+		} else {
+			log.Println("api credential: " + token)
+		}
+		_ = dao.DBService.CloseGlobalDB()
+		return true
+	}
+	if len(*config.RevokeCredential) > 0 {
+		err := service.CredentialService.Revoke(*config.RevokeCredential)
+		if err != nil {
+			log.Println("fail to revoke credential:", err)
+		} else {
+			log.Println("revoke credential " + *config.RevokeCredential)
+		}
+		_ = dao.DBService.CloseGlobalDB()
+		return true
+	}
+	return false
+}
+
+// migrate the user dbs of old versions, the mnemonics are read from stdin, one per line
+func migrateUserDb() {
+	macAddress := *config.LegacyMacAddress
+	if len(macAddress) == 0 {
+		macAddress = tool.GetMacAddrs()
+	}
+	serverPort := *config.LegacyServerPort
+	if serverPort == 0 {
+		serverPort = config.ServerPort
+	}
+
+	mnemonics := make([]string, 0)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		mnemonics = append(mnemonics, strings.TrimSpace(scanner.Text()))
+	}
+
+	migrations, err := service.MigrateLegacyUserDBs(mnemonics, macAddress, serverPort)
+	for _, item := range migrations {
+		log.Println("migrate user " + item.OldPeerId + " to " + item.NewPeerId)
+	}
+	if err != nil {
+		log.Println("fail to migrate user db:", err)
+		return
+	}
+	log.Println("migrate " + strconv.Itoa(len(migrations)) + " user dbs, the tracker will be noticed on the next start")
+}
+
+// use the configured certificate, or the self-signed one in the data directory
+func loadTLSCert() (err error) {
+	if config.TlsDisable {
+		log.Println("tls is disabled, never use it in production")
+		return nil
+	}
+	config.TlsCert, config.TlsKey, err = tool.LoadOrCreateTLSCert(config.DataDirectory, config.TlsCert, config.TlsKey, []string{config.P2P_hostIp})
+	return err
+}
+
 // gox compile  https://blog.csdn.net/han0373/article/details/81391455
 // gox -os "windows linux darwin" -arch amd64
 // gox -os "linux" -arch amd64
 func main() {
 	config.Init()
 	initObdLog()
+	if manageNodeKey() || manageCredential() {
+		return
+	}
+	if *config.MigrateUserDb {
+		migrateUserDb()
+		return
+	}
+	err := loadTLSCert()
+	if err != nil {
+		log.Println("fail to load tls certificate:", err)
+		return
+	}
 	//tracker
-	err := lightclient.ConnectToTracker()
+	err = lightclient.ConnectToTracker()
 	if err != nil {
-		log.Println("because fail to connect to tracker, obd fail to start")
+		log.Println("obd fail to start without tracker:", err)
 		return
 	}
 
 	routersInit := lightclient.InitRouter()
+	rpc.RegisterGateway(routersInit)
 	addr := ":" + strconv.Itoa(config.ServerPort)
 	server := &http.Server{
 		Addr:           addr,
@@ -67,17 +194,66 @@ func main() {
 	// Timer
 	service.ScheduleService.StartSchedule()
 
-	go rpc.StartGrpcServer()
+	err = rpc.StartGrpcServer()
+	if err != nil {
+		log.Println("fail to start grpc server:", err)
+		return
+	}
 
 	log.Println("obd " + tool.GetObdNodeId() + " start in " + config.ChainNodeType)
 	log.Println("wsAddress: " + bean.CurrObdNodeInfo.WebsocketLink)
 
-	//https://blog.csdn.net/Binary2014/article/details/103919365
-	//server.ListenAndServeTLS()
-	//err = server.ListenAndServeTLS("cert.pem", "key.pem")
-	//if err != nil {
-	//	log.Println(err)
-	//}
+	go func() {
+		var err error
+		if config.TlsDisable {
+			err = server.ListenAndServe()
+		} else {
+			err = server.ListenAndServeTLS(config.TlsCert, config.TlsKey)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Println("receive signal " + sig.String() + ", obd is shutting down")
+	shutdown(server)
+}
+
+// stop accepting requests, wait for the running ones, then logout users and close the p2p node and dbs.
+// the steps which take too long are skipped after config.ShutdownTimeout.
+func shutdown(server *http.Server) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
 
-	log.Fatal(server.ListenAndServe())
+	err := server.Shutdown(ctx)
+	if err != nil {
+		log.Println("fail to shutdown websocket server:", err)
+	}
+	// the dbs are left open if the running requests or jobs are not finished, they are never closed under them
+	err = rpc.StopGrpcServer(ctx)
+	if err != nil {
+		log.Println(err, ", obd stopped without closing the dbs")
+		return
+	}
+	err = lightclient.StopRequests(ctx)
+	if err != nil {
+		log.Println(err, ", obd stopped without closing the dbs")
+		return
+	}
+	err = service.ScheduleService.StopSchedule(ctx)
+	if err != nil {
+		log.Println("fail to wait for the schedule jobs:", err, ", obd stopped without closing the dbs")
+		return
+	}
+	rpc.LogoutGrpcSessions()
+	lightclient.LogoutOnlineUsers()
+	lightclient.StopP2PNode()
+	err = dao.DBService.CloseGlobalDB()
+	if err != nil {
+		log.Println("fail to close db:", err)
+	}
+	log.Println("obd stopped")
 }
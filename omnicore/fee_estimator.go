@@ -0,0 +1,264 @@
+package omnicore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/conn"
+	"github.com/omnilaboratory/obd/tool"
+	"github.com/shopspring/decimal"
+)
+
+// FeeEstimator estimate the fee rate in satoshi per byte, for the transaction to be confirmed in confTarget blocks
+type FeeEstimator interface {
+	EstimateFeeRate(confTarget int32) (float64, error)
+}
+
+// FeePurpose the kind of the transaction, every purpose has its own conf target
+type FeePurpose string
+
+const (
+	// the funding transactions of the channels
+	FeePurpose_Funding FeePurpose = "funding"
+	// the commitment transactions, and the htlc transactions spending them
+	FeePurpose_Commitment FeePurpose = "commitment"
+	// the RD, BR and the other transactions which sweep the outputs to the owners
+	FeePurpose_Sweep FeePurpose = "sweep"
+)
+
+// the types of the estimators in the config
+const (
+	FeeEstimatorType_Tracker = "tracker"
+	FeeEstimatorType_Static  = "static"
+	FeeEstimatorType_File    = "file"
+)
+
+// the estimated size of a transaction with one input, every more input adds 150 bytes
+func getTxSize(ins int) int {
+	if ins < 1 {
+		ins = 1
+	}
+	return ins*150 + 68 + 90
+}
+
+// obd pays 1/trackerSmartFeeDivisor of the smart fee of the tracker, which is the rate obd has always paid.
+// the smart fee of the full node is for the urgent transactions, the omni transactions of obd can wait longer.
+const trackerSmartFeeDivisor = 6
+
+// the fee rate of the tracker is estimateSmartFee of its full node
+type trackerFeeEstimator struct{}
+
+func (estimator *trackerFeeEstimator) EstimateFeeRate(confTarget int32) (float64, error) {
+	price, err := conn2tracker.EstimateSmartFee(context.Background(), confTarget)
+	if err != nil {
+		return 0, err
+	}
+	return price / trackerSmartFeeDivisor, nil
+}
+
+// the same fee rate for all conf targets, for the offline nodes and regtest
+type staticFeeEstimator struct {
+	feeRate float64
+}
+
+func (estimator *staticFeeEstimator) EstimateFeeRate(confTarget int32) (float64, error) {
+	return estimator.feeRate, nil
+}
+
+// the fee rates are read from a local json file or an http url, such as {"2": 20, "6": 10, "144": 2},
+// the keys are the conf targets and the values are satoshi per byte.
+// the rate of the largest target not greater than confTarget is used, the http source is cached for cacheTime.
+type fileFeeEstimator struct {
+	source    string
+	cacheTime time.Duration
+
+	mu       sync.Mutex
+	rates    map[int32]float64
+	loadedAt time.Time
+}
+
+func (estimator *fileFeeEstimator) EstimateFeeRate(confTarget int32) (float64, error) {
+	rates, err := estimator.load()
+	if err != nil {
+		return 0, err
+	}
+	return getFeeRateOfTarget(rates, confTarget)
+}
+
+func (estimator *fileFeeEstimator) isHttp() bool {
+	return strings.HasPrefix(estimator.source, "http://") || strings.HasPrefix(estimator.source, "https://")
+}
+
+func (estimator *fileFeeEstimator) load() (map[int32]float64, error) {
+	estimator.mu.Lock()
+	defer estimator.mu.Unlock()
+	if estimator.isHttp() && estimator.rates != nil && time.Since(estimator.loadedAt) < estimator.cacheTime {
+		return estimator.rates, nil
+	}
+
+	var body []byte
+	var err error
+	if estimator.isHttp() {
+		var resp *http.Response
+		client := http.Client{Timeout: 30 * time.Second}
+		resp, err = client.Get(estimator.source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, errors.New("fail to get the fee rates from " + estimator.source + ": " + resp.Status)
+		}
+		body, err = ioutil.ReadAll(resp.Body)
+	} else {
+		body, err = ioutil.ReadFile(estimator.source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]float64)
+	if err = json.Unmarshal(body, &values); err != nil {
+		return nil, err
+	}
+	rates := make(map[int32]float64)
+	for key, value := range values {
+		target, err := strconv.Atoi(key)
+		if err != nil || target <= 0 || value <= 0 {
+			return nil, fmt.Errorf("wrong fee rate %q: %v", key, value)
+		}
+		rates[int32(target)] = value
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("no fee rate in " + estimator.source)
+	}
+	estimator.rates = rates
+	estimator.loadedAt = time.Now()
+	return rates, nil
+}
+
+func getFeeRateOfTarget(rates map[int32]float64, confTarget int32) (float64, error) {
+	if len(rates) == 0 {
+		return 0, errors.New("no fee rate")
+	}
+	targets := make([]int, 0, len(rates))
+	for target := range rates {
+		targets = append(targets, int(target))
+	}
+	sort.Ints(targets)
+	// the targets shorter than the smallest one use its rate
+	result := rates[int32(targets[0])]
+	for _, target := range targets {
+		if int32(target) > confTarget {
+			break
+		}
+		result = rates[int32(target)]
+	}
+	return result, nil
+}
+
+// NewFeeEstimator create the estimator of the type: tracker, static or file, source is the file path or url of the file estimator
+func NewFeeEstimator(estimatorType string, feeRate float64, source string) (FeeEstimator, error) {
+	switch estimatorType {
+	case FeeEstimatorType_Tracker, "":
+		return &trackerFeeEstimator{}, nil
+	case FeeEstimatorType_Static:
+		if feeRate <= 0 {
+			return nil, errors.New("the fee rate of the static estimator must be greater than 0")
+		}
+		return &staticFeeEstimator{feeRate: feeRate}, nil
+	case FeeEstimatorType_File:
+		if len(source) == 0 {
+			return nil, errors.New("the source of the file estimator is empty")
+		}
+		return &fileFeeEstimator{source: source, cacheTime: 10 * time.Minute}, nil
+	}
+	return nil, errors.New("wrong fee estimator " + estimatorType)
+}
+
+var (
+	feeEstimatorLock sync.Mutex
+	feeEstimator     FeeEstimator
+)
+
+// SetFeeEstimator replace the estimator created by the config
+func SetFeeEstimator(estimator FeeEstimator) {
+	feeEstimatorLock.Lock()
+	feeEstimator = estimator
+	feeEstimatorLock.Unlock()
+}
+
+func getFeeEstimator() FeeEstimator {
+	feeEstimatorLock.Lock()
+	defer feeEstimatorLock.Unlock()
+	if feeEstimator == nil {
+		estimator, err := NewFeeEstimator(config.FeeEstimatorType, config.FeeRate, config.FeeSource)
+		if err != nil {
+			log.Println(err, ", use the tracker fee estimator")
+			estimator = &trackerFeeEstimator{}
+		}
+		feeEstimator = estimator
+	}
+	return feeEstimator
+}
+
+func getConfTarget(purpose FeePurpose) int32 {
+	switch purpose {
+	case FeePurpose_Funding:
+		return int32(config.FundingConfTarget)
+	case FeePurpose_Commitment:
+		return int32(config.CommitmentConfTarget)
+	case FeePurpose_Sweep:
+		return int32(config.SweepConfTarget)
+	}
+	return 10
+}
+
+// GetFeeRate the fee rate of the estimator, config.FeeRate if it fails, and never less than config.MinFeeRate
+func GetFeeRate(confTarget int32) float64 {
+	price, err := getFeeEstimator().EstimateFeeRate(confTarget)
+	if err != nil || price <= 0 {
+		price = config.FeeRate
+	}
+	if price < config.MinFeeRate {
+		price = config.MinFeeRate
+	}
+	return price
+}
+
+// GetMinerFeeByPurpose the miner fee in btc of the transaction with ins inputs
+func GetMinerFeeByPurpose(purpose FeePurpose, ins int) float64 {
+	return getMinerFeeOfRate(GetFeeRate(getConfTarget(purpose)), ins)
+}
+
+// the lowest miner fee in btc of the transaction with ins inputs, whatever the estimator is
+func getMinMinerFee(ins int) float64 {
+	return getMinerFeeOfRate(config.MinFeeRate, ins)
+}
+
+func getMinerFeeOfRate(price float64, ins int) float64 {
+	result, _ := decimal.NewFromFloat(float64(getTxSize(ins)) * price).Div(decimal.NewFromFloat(100000000)).Round(8).Float64()
+	return result
+}
+
+// GetMinerFeeOfBtc the estimated miner fee, but no more than the share of the btc which is paid for the miner fees,
+// such as the btc funded to the channel
+func GetMinerFeeOfBtc(purpose FeePurpose, ins int, btcAmount float64) float64 {
+	minerFee := GetMinerFeeByPurpose(purpose, ins)
+	maxMinerFee := tool.GetBtcMinerAmount(btcAmount)
+	if minerFee > maxMinerFee {
+		minerFee = maxMinerFee
+	}
+	return minerFee
+}
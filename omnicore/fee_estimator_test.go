@@ -0,0 +1,82 @@
+package omnicore
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/omnilaboratory/obd/config"
+)
+
+type failedFeeEstimator struct{}
+
+func (estimator *failedFeeEstimator) EstimateFeeRate(confTarget int32) (float64, error) {
+	return 0, errors.New("offline")
+}
+
+func TestFileFeeEstimator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "fee_rates.json")
+	if err = ioutil.WriteFile(source, []byte(`{"2": 20, "6": 10, "144": 2}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	estimator, err := NewFeeEstimator(FeeEstimatorType_File, 0, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		confTarget int32
+		feeRate    float64
+	}{
+		{1, 20},
+		{2, 20},
+		{10, 10},
+		{144, 2},
+		{1000, 2},
+	}
+	for _, test := range tests {
+		feeRate, err := estimator.EstimateFeeRate(test.confTarget)
+		if err != nil || feeRate != test.feeRate {
+			t.Errorf("the fee rate of %d is %v %v, want %v", test.confTarget, feeRate, err, test.feeRate)
+		}
+	}
+
+	_ = ioutil.WriteFile(source, []byte(`{"abc": 1}`), 0600)
+	if _, err = estimator.EstimateFeeRate(6); err == nil {
+		t.Error("the wrong fee rates are loaded")
+	}
+}
+
+func TestGetMinerFeeByPurpose(t *testing.T) {
+	defer SetFeeEstimator(nil)
+	config.MinFeeRate = 4
+	config.FeeRate = 6
+
+	SetFeeEstimator(&staticFeeEstimator{feeRate: 10})
+	if fee := GetMinerFeeByPurpose(FeePurpose_Funding, 1); fee != 0.0000308 {
+		t.Errorf("the miner fee is %v, want 0.0000308", fee)
+	}
+	if fee := GetMinerFeeByPurpose(FeePurpose_Sweep, 2); fee != 0.0000458 {
+		t.Errorf("the miner fee of 2 inputs is %v, want 0.0000458", fee)
+	}
+	// no more than the btc paid for the miner fees
+	if fee := GetMinerFeeOfBtc(FeePurpose_Sweep, 1, 0.0001); fee != 0.00001954 {
+		t.Errorf("the miner fee of the btc is %v, want 0.00001954", fee)
+	}
+
+	SetFeeEstimator(&staticFeeEstimator{feeRate: 1})
+	if feeRate := GetFeeRate(6); feeRate != config.MinFeeRate {
+		t.Errorf("the fee rate is %v, want the min fee rate", feeRate)
+	}
+	SetFeeEstimator(&failedFeeEstimator{})
+	if feeRate := GetFeeRate(6); feeRate != config.FeeRate {
+		t.Errorf("the fee rate is %v, want the fallback fee rate", feeRate)
+	}
+}
@@ -5,16 +5,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
-	"github.com/omnilaboratory/obd/conn"
 	"github.com/omnilaboratory/obd/tool"
-	"github.com/shopspring/decimal"
 	"github.com/tidwall/gjson"
 	"log"
 	"strconv"
@@ -197,19 +194,9 @@ func DecodeBtcRawTransaction(hex string) (result string, err error) {
 	return result, err
 }
 
+// GetMinerFee the miner fee in btc of the transaction with one input, to be confirmed in confTarget blocks
 func GetMinerFee(confTarget int32) float64 {
-	price := conn2tracker.EstimateSmartFee(confTarget)
-	if price == 0 {
-		price = 6
-	} else {
-		price = price / 6
-	}
-	if price < 4 {
-		price = 4
-	}
-	txSize := 150 + 68 + 90
-	result, _ := decimal.NewFromFloat(float64(txSize) * price).Div(decimal.NewFromFloat(100000000)).Round(8).Float64()
-	return result
+	return getMinerFeeOfRate(GetFeeRate(confTarget), 1)
 }
 
 func GetTxId(hex string) string {
@@ -245,19 +232,19 @@ func CheckMultiSign(hex string, step int) (pass bool, err error) {
 
 func GetPubKeyFromWifAndCheck(privKeyHex string, pubKey string) (pubKeyFromWif string, err error) {
 	if tool.CheckIsString(&privKeyHex) == false {
-		return "", errors.New(enum.Tips_common_empty + "private key")
+		return "", enum.NewError(enum.ErrorCode_common_empty, "private key")
 	}
 	if tool.CheckIsString(&pubKey) == false {
-		return "", errors.New(enum.Tips_common_empty + "pubKey")
+		return "", enum.NewError(enum.ErrorCode_common_empty, "pubKey")
 	}
 
 	wif, err := btcutil.DecodeWIF(privKeyHex)
 	if err != nil {
-		return "", errors.New(enum.Tips_common_wrong + "private key")
+		return "", enum.NewError(enum.ErrorCode_common_wrong, "private key")
 	}
 	pubKeyFromWif = hex.EncodeToString(wif.PrivKey.PubKey().SerializeCompressed())
 	if pubKeyFromWif != pubKey {
-		return "", errors.New(fmt.Sprintf(enum.Tips_rsmc_notPairPrivAndPubKey, privKeyHex, pubKey))
+		return "", enum.NewError(enum.ErrorCode_rsmc_notPairPrivAndPubKey, privKeyHex, pubKey)
 	}
 	return pubKeyFromWif, nil
 }
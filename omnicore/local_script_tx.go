@@ -1,10 +1,10 @@
 package omnicore
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/omnilaboratory/obd/bean"
-	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/conn"
 	"github.com/omnilaboratory/obd/tool"
 	"github.com/shopspring/decimal"
@@ -23,7 +23,7 @@ func BtcCreateRawTransactionForUnsendInputTx(fromBitCoinAddress string, inputIte
 	}
 
 	if minerFee <= tool.GetOmniDustBtc() {
-		minerFee = GetMinerFee(10)
+		minerFee = GetMinerFeeByPurpose(FeePurpose_Funding, len(inputItems))
 	}
 
 	outAmount := decimal.NewFromFloat(0)
@@ -107,9 +107,9 @@ func BtcCreateRawTransactionForUnsendInputTx(fromBitCoinAddress string, inputIte
 	dataToTracker["inputs"] = inputs
 	dataToTracker["outputs"] = output
 	bytes, err := json.Marshal(dataToTracker)
-	hex := conn2tracker.CreateRawTransaction(string(bytes))
-	if hex == "" {
-		return nil, errors.New("error createRawTransaction")
+	hex, err := conn2tracker.CreateRawTransaction(context.Background(), string(bytes))
+	if err != nil {
+		return nil, err
 	}
 
 	retMap = make(map[string]interface{})
@@ -130,7 +130,7 @@ func BtcCreateRawTransaction(fromBitCoinAddress string, outputItems []bean.Trans
 	}
 
 	if minerFee <= 0 {
-		minerFee = GetMinerFee(10)
+		minerFee = GetMinerFeeByPurpose(FeePurpose_Funding, 1)
 	}
 
 	outTotalAmount := decimal.NewFromFloat(0)
@@ -146,7 +146,10 @@ func BtcCreateRawTransaction(fromBitCoinAddress string, outputItems []bean.Trans
 		return nil, errors.New("minerFee too small")
 	}
 
-	result := conn2tracker.ListUnspent(fromBitCoinAddress)
+	result, err := conn2tracker.ListUnspent(context.Background(), fromBitCoinAddress)
+	if err != nil {
+		return nil, err
+	}
 	array := gjson.Parse(result).Array()
 	if len(array) == 0 {
 		return nil, errors.New("empty balance")
@@ -215,9 +218,9 @@ func BtcCreateRawTransaction(fromBitCoinAddress string, outputItems []bean.Trans
 	dataToTracker["inputs"] = inputs
 	dataToTracker["outputs"] = output
 	bytes, err := json.Marshal(dataToTracker)
-	hex := conn2tracker.CreateRawTransaction(string(bytes))
-	if hex == "" {
-		return nil, errors.New("error createRawTransaction")
+	hex, err := conn2tracker.CreateRawTransaction(context.Background(), string(bytes))
+	if err != nil {
+		return nil, err
 	}
 
 	retMap = make(map[string]interface{})
@@ -262,7 +265,7 @@ func OmniCreateRawTransactionUseSingleInput(resultListUnspent string, fromBitCoi
 				node["redeemScript"] = *redeemScript
 			}
 			balance, _ = decimal.NewFromFloat(balance).Add(decimal.NewFromFloat(node["amount"].(float64))).Round(8).Float64()
-			minerFee = tool.GetBtcMinerAmount(balance)
+			minerFee = GetMinerFeeOfBtc(FeePurpose_Commitment, 1, balance)
 			inputs = append(inputs, node)
 			break
 		}
@@ -272,7 +275,7 @@ func OmniCreateRawTransactionUseSingleInput(resultListUnspent string, fromBitCoi
 		return nil, "", errors.New("not found the miner fee input")
 	}
 
-	minMinerFee := config.GetMinMinerFee(len(inputs))
+	minMinerFee := getMinMinerFee(len(inputs))
 	if minerFee < minMinerFee {
 		minerFee = minMinerFee
 	}
@@ -320,7 +323,7 @@ func OmniCreateRawTransactionUseRestInput(txType int, resultListUnspent string,
 		}
 	}
 
-	minMinerFee := config.GetMinMinerFee(len(inputs))
+	minMinerFee := getMinMinerFee(len(inputs))
 	if minerFee < minMinerFee {
 		minerFee = minMinerFee
 	}
@@ -393,8 +396,8 @@ func GetInputInfo(fromBitCoinAddress string, txid, redeemScript string) (info ma
 		return nil, errors.New("fromBitCoinAddress is empty")
 	}
 
-	resultListUnspent := conn2tracker.ListUnspent(fromBitCoinAddress)
-	if resultListUnspent == "" {
+	resultListUnspent, err := conn2tracker.ListUnspent(context.Background(), fromBitCoinAddress)
+	if err != nil {
 		return nil, err
 	}
 
@@ -438,19 +441,17 @@ func OmniCreateRawTransaction(fromBitCoinAddress string, toBitCoinAddress string
 		minerFee = 0.00003
 	}
 
-	balanceResult := conn2tracker.OmniGetBalancesForAddress(fromBitCoinAddress, int(propertyId))
-	if balanceResult == "" {
-		return nil, errors.New("empty omni balance")
+	omniBalance, err := conn2tracker.OmniGetBalancesForAddress(context.Background(), fromBitCoinAddress, int(propertyId))
+	if err != nil {
+		return nil, err
 	}
-
-	omniBalance := gjson.Get(balanceResult, "balance").Float()
-	if omniBalance < amount {
+	if omniBalance.Balance < amount {
 		return nil, errors.New("not enough omni balance")
 	}
 
-	resultListUnspent := conn2tracker.ListUnspent(fromBitCoinAddress)
-	if resultListUnspent == "" {
-		return nil, errors.New("enmpty ListUnspent")
+	resultListUnspent, err := conn2tracker.ListUnspent(context.Background(), fromBitCoinAddress)
+	if err != nil {
+		return nil, err
 	}
 
 	arrayListUnspent := gjson.Parse(resultListUnspent).Array()
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	proxy "github.com/omnilaboratory/obd/proxy/pb"
+	"github.com/urfave/cli"
+)
+
+// the flags of the counterparty of a channel or a payment
+var recipientFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "recipient_node_peer_id",
+		Usage: "the peer id of the obd node of the counterparty",
+	},
+	cli.StringFlag{
+		Name:  "recipient_user_peer_id",
+		Usage: "the peer id of the counterparty",
+	},
+}
+
+func getRecipientInfo(ctx *cli.Context) (*proxy.RecipientNodeInfo, error) {
+	info := &proxy.RecipientNodeInfo{
+		RecipientNodePeerId: ctx.String("recipient_node_peer_id"),
+		RecipientUserPeerId: ctx.String("recipient_user_peer_id"),
+	}
+	if len(info.RecipientNodePeerId) == 0 {
+		return nil, fmt.Errorf("recipient_node_peer_id argument missing")
+	}
+	if len(info.RecipientUserPeerId) == 0 {
+		return nil, fmt.Errorf("recipient_user_peer_id argument missing")
+	}
+	return info, nil
+}
+
+var pageFlags = []cli.Flag{
+	cli.IntFlag{
+		Name:  "page_size",
+		Value: 10,
+		Usage: "the max count of the items in a page",
+	},
+	cli.IntFlag{
+		Name:  "page_index",
+		Value: 1,
+		Usage: "the index of the page, from 1",
+	},
+}
+
+var openChannelCommand = cli.Command{
+	Name:     "openchannel",
+	Category: "Channels",
+	Usage:    "Open a channel with the counterparty",
+	Description: "Open a channel with the counterparty, the funding pubkey must be one of the " +
+		"addresses of the current wallet, which can be created by the NewAddress of the grpc api.",
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "node_pubkey",
+			Usage: "the funding pubkey of the channel",
+		},
+		cli.BoolFlag{
+			Name:  "private",
+			Usage: "the channel is not announced to the tracker",
+		},
+	}, recipientFlags...),
+	Action: openChannel,
+}
+
+func openChannel(ctx *cli.Context) error {
+	recipientInfo, err := getRecipientInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ctx.String("node_pubkey")) == 0 {
+		return fmt.Errorf("node_pubkey argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.OpenChannel(context.Background(), &proxy.OpenChannelRequest{
+		NodePubkeyString: ctx.String("node_pubkey"),
+		Private:          ctx.Bool("private"),
+		RecipientInfo:    recipientInfo,
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var fundChannelCommand = cli.Command{
+	Name:     "fundchannel",
+	Category: "Channels",
+	Usage:    "Fund the channel with btc and the asset",
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "template_channel_id",
+			Usage: "the temporary channel id returned by openchannel",
+		},
+		cli.Float64Flag{
+			Name:  "btc_amount",
+			Usage: "the btc to pay the miner fees of the channel",
+		},
+		cli.Int64Flag{
+			Name:  "property_id",
+			Usage: "the omni property id of the asset",
+		},
+		cli.Float64Flag{
+			Name:  "asset_amount",
+			Usage: "the amount of the asset",
+		},
+	}, recipientFlags...),
+	Action: fundChannel,
+}
+
+func fundChannel(ctx *cli.Context) error {
+	recipientInfo, err := getRecipientInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ctx.String("template_channel_id")) == 0 {
+		return fmt.Errorf("template_channel_id argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.FundChannel(context.Background(), &proxy.FundChannelRequest{
+		TemplateChannelId: ctx.String("template_channel_id"),
+		BtcAmount:         ctx.Float64("btc_amount"),
+		PropertyId:        ctx.Int64("property_id"),
+		AssetAmount:       ctx.Float64("asset_amount"),
+		RecipientInfo:     recipientInfo,
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var closeChannelCommand = cli.Command{
+	Name:      "closechannel",
+	Category:  "Channels",
+	Usage:     "Close the channel, and broadcast its latest commitment transaction",
+	ArgsUsage: "<channel id>",
+	Action:    closeChannel,
+}
+
+func closeChannel(ctx *cli.Context) error {
+	channelId := ctx.Args().First()
+	if len(channelId) == 0 {
+		return fmt.Errorf("channel id argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.CloseChannel(context.Background(), &proxy.CloseChannelRequest{ChannelId: channelId})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var listChannelsCommand = cli.Command{
+	Name:     "listchannels",
+	Category: "Channels",
+	Usage:    "List the channels of the current user",
+	Flags:    pageFlags,
+	Action:   listChannels,
+}
+
+func listChannels(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.ListChannels(context.Background(), &proxy.ListChannelsRequest{
+		PageSize:  int32(ctx.Int("page_size")),
+		PageIndex: int32(ctx.Int("page_index")),
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var pendingChannelsCommand = cli.Command{
+	Name:     "pendingchannels",
+	Category: "Channels",
+	Usage:    "List the channels which are not funded yet",
+	Flags:    pageFlags,
+	Action:   pendingChannels,
+}
+
+func pendingChannels(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.PendingChannels(context.Background(), &proxy.PendingChannelsRequest{
+		PageSize:  int32(ctx.Int("page_size")),
+		PageIndex: int32(ctx.Int("page_index")),
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var channelBalanceCommand = cli.Command{
+	Name:     "channelbalance",
+	Category: "Channels",
+	Usage:    "Show the local and remote balances of all channels",
+	Action:   channelBalance,
+}
+
+func channelBalance(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.ChannelBalance(context.Background(), &proxy.ChannelBalanceRequest{})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	proxy "github.com/omnilaboratory/obd/proxy/pb"
+	"github.com/urfave/cli"
+)
+
+var addInvoiceCommand = cli.Command{
+	Name:     "addinvoice",
+	Category: "Invoices",
+	Usage:    "Add an invoice, the payer pays it by sendpayment",
+	Flags: []cli.Flag{
+		cli.Int64Flag{
+			Name:  "property_id",
+			Usage: "the omni property id of the asset",
+		},
+		cli.Float64Flag{
+			Name:  "value",
+			Usage: "the amount of the asset",
+		},
+		cli.StringFlag{
+			Name:  "memo",
+			Usage: "the description of the invoice",
+		},
+		cli.StringFlag{
+			Name:  "expiry",
+			Usage: "the expiry date of the invoice, such as 2021-12-31",
+		},
+		cli.BoolFlag{
+			Name:  "private",
+			Usage: "the invoice is paid by the private channels",
+		},
+	},
+	Action: addInvoice,
+}
+
+func addInvoice(ctx *cli.Context) error {
+	if len(ctx.String("expiry")) == 0 {
+		return fmt.Errorf("expiry argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Htlc.AddInvoice(context.Background(), &proxy.Invoice{
+		PropertyId: ctx.Int64("property_id"),
+		Value:      ctx.Float64("value"),
+		Memo:       ctx.String("memo"),
+		CltvExpiry: ctx.String("expiry"),
+		Private:    ctx.Bool("private"),
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var parseInvoiceCommand = cli.Command{
+	Name:      "parseinvoice",
+	Category:  "Invoices",
+	Usage:     "Show the detail of an invoice",
+	ArgsUsage: "<payment request>",
+	Action:    parseInvoice,
+}
+
+func parseInvoice(ctx *cli.Context) error {
+	payReq := ctx.Args().First()
+	if len(payReq) == 0 {
+		return fmt.Errorf("payment request argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Htlc.ParseInvoice(context.Background(), &proxy.ParseInvoiceRequest{PaymentRequest: payReq})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var listInvoicesCommand = cli.Command{
+	Name:     "listinvoices",
+	Category: "Invoices",
+	Usage:    "List the invoices added by the current user",
+	Flags: []cli.Flag{
+		cli.Uint64Flag{
+			Name:  "index_offset",
+			Usage: "the index of the invoice to start from",
+		},
+		cli.Uint64Flag{
+			Name:  "max_invoices",
+			Value: 100,
+			Usage: "the max count of the invoices",
+		},
+		cli.BoolFlag{
+			Name:  "reversed",
+			Usage: "list the invoices backwards from index_offset",
+		},
+	},
+	Action: listInvoices,
+}
+
+func listInvoices(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Htlc.ListInvoices(context.Background(), &proxy.ListInvoiceRequest{
+		IndexOffset:    ctx.Uint64("index_offset"),
+		NumMaxInvoices: ctx.Uint64("max_invoices"),
+		Reversed:       ctx.Bool("reversed"),
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var sendPaymentCommand = cli.Command{
+	Name:      "sendpayment",
+	Category:  "Payments",
+	Usage:     "Pay an invoice by htlc",
+	ArgsUsage: "<payment request>",
+	Action:    sendPayment,
+}
+
+func sendPayment(ctx *cli.Context) error {
+	payReq := ctx.Args().First()
+	if len(payReq) == 0 {
+		return fmt.Errorf("payment request argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Htlc.SendPayment(context.Background(), &proxy.SendRequest{PaymentRequest: payReq})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var rsmcPaymentCommand = cli.Command{
+	Name:     "rsmcpayment",
+	Category: "Payments",
+	Usage:    "Pay the counterparty of a channel directly",
+	Flags: append([]cli.Flag{
+		cli.StringFlag{
+			Name:  "channel_id",
+			Usage: "the channel to pay by",
+		},
+		cli.Float64Flag{
+			Name:  "amount",
+			Usage: "the amount of the asset",
+		},
+	}, recipientFlags...),
+	Action: rsmcPayment,
+}
+
+func rsmcPayment(ctx *cli.Context) error {
+	recipientInfo, err := getRecipientInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if len(ctx.String("channel_id")) == 0 {
+		return fmt.Errorf("channel_id argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Rsmc.RsmcPayment(context.Background(), &proxy.RsmcPaymentRequest{
+		ChannelId:     ctx.String("channel_id"),
+		Amount:        ctx.Float64("amount"),
+		RecipientInfo: recipientInfo,
+	})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	proxy "github.com/omnilaboratory/obd/proxy/pb"
+	"github.com/urfave/cli"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+var genSeedCommand = cli.Command{
+	Name:        "genseed",
+	Category:    "Wallet",
+	Usage:       "Generate a new mnemonic of a wallet",
+	Description: "Generate a new mnemonic, keep it safe, it is the only way to restore the wallet.",
+	Action:      genSeed,
+}
+
+func genSeed(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Wallet.GenSeed(context.Background(), &proxy.GenSeedRequest{})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var loginCommand = cli.Command{
+	Name:     "login",
+	Category: "Wallet",
+	Usage:    "Login obd with the mnemonic of a wallet",
+	Description: "Login obd with the mnemonic. If --mnemonic is not set, it is read from the stdin, " +
+		"so that it is not saved in the shell history. The session token is saved in --sessionpath for the other commands.",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "mnemonic",
+			Usage: "the mnemonic of the wallet",
+		},
+		cli.StringFlag{
+			Name:  "login_token",
+			Usage: "the admin login token of obd",
+		},
+	},
+	Action: login,
+}
+
+func login(ctx *cli.Context) error {
+	mnemonic := ctx.String("mnemonic")
+	if len(mnemonic) == 0 {
+		fmt.Print("Input the mnemonic: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && len(line) == 0 {
+			return err
+		}
+		mnemonic = strings.TrimSpace(line)
+	}
+	if len(mnemonic) == 0 {
+		return fmt.Errorf("mnemonic argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	var header metadata.MD
+	resp, err := client.Wallet.Login(context.Background(), &proxy.LoginRequest{
+		Mnemonic:   mnemonic,
+		LoginToken: ctx.String("login_token"),
+	}, grpc.Header(&header))
+	if err != nil {
+		return err
+	}
+	if values := header.Get("session"); len(values) > 0 {
+		if err = saveSession(ctx, values[0]); err != nil {
+			return err
+		}
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var logoutCommand = cli.Command{
+	Name:     "logout",
+	Category: "Wallet",
+	Usage:    "Logout the current user",
+	Action:   logout,
+}
+
+func logout(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Wallet.Logout(context.Background(), &proxy.LogoutRequest{})
+	if err != nil {
+		return err
+	}
+	removeSession(ctx)
+	printRespJSON(resp)
+	return nil
+}
+
+var getInfoCommand = cli.Command{
+	Name:     "getinfo",
+	Category: "Wallet",
+	Usage:    "Show the info of the current user and obd",
+	Action:   getInfo,
+}
+
+func getInfo(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Wallet.GetInfo(context.Background(), &proxy.GetInfoRequest{})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var connectCommand = cli.Command{
+	Name:      "connect",
+	Category:  "Peers",
+	Usage:     "Connect to a remote obd node",
+	ArgsUsage: "<p2p address>",
+	Description: "Connect to a remote obd node by its p2p address, such as " +
+		"/ip4/127.0.0.1/tcp/4001/p2p/QmZPzUh7Q6PQg6gXB4XheaoZMMhHA9JNeCrJsp3FWjFrAF",
+	Action: connectPeer,
+}
+
+func connectPeer(ctx *cli.Context) error {
+	addr := ctx.Args().First()
+	if len(addr) == 0 {
+		return fmt.Errorf("p2p address argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.ConnectPeer(context.Background(), &proxy.ConnectPeerRequest{Addr: addr})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var disconnectCommand = cli.Command{
+	Name:      "disconnect",
+	Category:  "Peers",
+	Usage:     "Disconnect a remote obd node",
+	ArgsUsage: "<p2p address>",
+	Action:    disconnectPeer,
+}
+
+func disconnectPeer(ctx *cli.Context) error {
+	addr := ctx.Args().First()
+	if len(addr) == 0 {
+		return fmt.Errorf("p2p address argument missing")
+	}
+
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.DisconnectPeer(context.Background(), &proxy.DisconnectPeerRequest{Addr: addr})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
+
+var listPeersCommand = cli.Command{
+	Name:     "listpeers",
+	Category: "Peers",
+	Usage:    "List the connected obd nodes",
+	Action:   listPeers,
+}
+
+func listPeers(ctx *cli.Context) error {
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Wallet.ListPeers(context.Background(), &proxy.ListPeersRequest{})
+	if err != nil {
+		return err
+	}
+	printRespJSON(resp)
+	return nil
+}
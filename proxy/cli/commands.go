@@ -3,68 +3,181 @@ package main
 import (
 	context "context"
 	fmt "fmt"
+	"io/ioutil"
 	"log"
 	"os"
-
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/mitchellh/go-homedir"
+	"github.com/omnilaboratory/obd/config"
 	proxy "github.com/omnilaboratory/obd/proxy/pb"
 	"github.com/urfave/cli"
 	grpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
-
 var HelloCommand = cli.Command{
-	Name:      "hello",
-	Category:  "testing",
-	Usage:     "Say Hello to Proxy Mode of OBD",
-	ArgsUsage: "your_name",
+	Name:        "hello",
+	Category:    "testing",
+	Usage:       "Say Hello to Proxy Mode of OBD",
+	ArgsUsage:   "your_name",
 	Description: "Say Hello to Proxy Mode of OBD",
-	Action: cliHello,
+	Action:      cliHello,
 }
 
 func cliHello(ctx *cli.Context) error {
-	
-	client, cleanUp := getClientConn(ctx)
-	defer cleanUp()
-
 	inputParam := ctx.Args().First()
-
-	var outputInfo string
-	switch inputParam {
-	case "":
-		outputInfo = "You can try to input anything."
-		return fmt.Errorf(outputInfo)
+	if inputParam == "" {
+		return fmt.Errorf("You can try to input anything.")
 	}
 
-	ctxb := context.Background()
-	resp, err := client.Hello(ctxb, &proxy.HelloRequest{
+	client, cleanUp := getClientConn(ctx)
+	defer cleanUp()
+
+	resp, err := client.Lightning.Hello(context.Background(), &proxy.HelloRequest{
 		Sayhi: inputParam,
 	})
-
 	if err != nil {
 		return err
 	}
-
-	fmt.Println(resp)
+	printRespJSON(resp)
 	return nil
 }
 
-func getClientConn(ctx *cli.Context) (proxy.ProxyClient, func()) {
-
-	fmt.Println("Get client connection ...")
+// the clients of all grpc services of obd, on one connection
+type obdClient struct {
+	Lightning proxy.LightningClient
+	Wallet    proxy.WalletClient
+	Rsmc      proxy.RsmcClient
+	Htlc      proxy.HtlcClient
+}
 
+func getClientConn(ctx *cli.Context) (*obdClient, func()) {
 	opts := grpc.WithInsecure()
-	cc, err := grpc.Dial("localhost:50051", opts)
+	if ctx.GlobalBool("notls") == false {
+		certPath := ctx.GlobalString("tlscertpath")
+		if len(certPath) == 0 {
+			certPath = defaultTLSCertPath(ctx.GlobalString("configpath"))
+		}
+		creds, err := credentials.NewClientTLSFromFile(certPath, "")
+		if err != nil {
+			fatal(fmt.Errorf("unable to read tls certificate: %v", err))
+		}
+		opts = grpc.WithTransportCredentials(creds)
+	}
+	dialOpts := []grpc.DialOption{opts}
+	md := apiMetadata{values: make(map[string]string), requireTLS: ctx.GlobalBool("notls") == false}
+	if credential := ctx.GlobalString("credential"); len(credential) > 0 {
+		md.values["credential"] = credential
+	}
+	if session := readSession(ctx); len(session) > 0 {
+		md.values["session"] = session
+	}
+	if len(md.values) > 0 {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(md))
+	}
+	cc, err := grpc.Dial(ctx.GlobalString("rpcserver"), dialOpts...)
 	if err != nil {
-		// log.Fatal(err)
-		fmt.Printf("unable to connect to RPC server: %v", err)
+		fatal(fmt.Errorf("unable to connect to RPC server: %v", err))
 	}
-	// defer cc.Close()
 
 	cleanUp := func() {
-		cc.Close()
+		_ = cc.Close()
+	}
+
+	return &obdClient{
+		Lightning: proxy.NewLightningClient(cc),
+		Wallet:    proxy.NewWalletClient(cc),
+		Rsmc:      proxy.NewRsmcClient(cc),
+		Htlc:      proxy.NewHtlcClient(cc),
+	}, cleanUp
+}
+
+// the api credential and the session are sent in the metadata of every request
+type apiMetadata struct {
+	values     map[string]string
+	requireTLS bool
+}
+
+func (m apiMetadata) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return m.values, nil
+}
+
+func (m apiMetadata) RequireTransportSecurity() bool {
+	return m.requireTLS
+}
+
+// the tls certificate of obd by its config file: tls_cert, or the self-signed tls.cert in its data directory
+func defaultTLSCertPath(configPath string) string {
+	cfg, err := ini.Load(configPath)
+	if err != nil {
+		cfg = ini.Empty()
+	}
+	section := cfg.Section("server")
+	if certPath := section.Key("tls_cert").String(); len(certPath) > 0 {
+		return certPath
+	}
+	dataDirectory, err := config.GetDataDirectory(section)
+	if err != nil {
+		return "tls.cert"
+	}
+	return filepath.Join(dataDirectory, "tls.cert")
+}
+
+func defaultSessionPath() string {
+	homeDirectory, err := homedir.Dir()
+	if err != nil {
+		return "obdcli.session"
 	}
+	return filepath.Join(homeDirectory, ".obd", "obdcli.session")
+}
 
-	return proxy.NewProxyClient(cc), cleanUp
+// the session token returned by login, --session is used if it is set
+func readSession(ctx *cli.Context) string {
+	if session := ctx.GlobalString("session"); len(session) > 0 {
+		return session
+	}
+	bytes, err := ioutil.ReadFile(ctx.GlobalString("sessionpath"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(bytes))
+}
+
+func saveSession(ctx *cli.Context, session string) error {
+	path := ctx.GlobalString("sessionpath")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(session), 0600)
+}
+
+func removeSession(ctx *cli.Context) {
+	_ = os.Remove(ctx.GlobalString("sessionpath"))
+}
+
+// print the response in json, the fields of zero value are printed too
+func printRespJSON(resp proto.Message) {
+	marshaler := jsonpb.Marshaler{
+		EmitDefaults: true,
+		OrigName:     true,
+		Indent:       "    ",
+	}
+	jsonStr, err := marshaler.MarshalToString(resp)
+	if err != nil {
+		fmt.Println("unable to decode response: ", err)
+		return
+	}
+	fmt.Println(jsonStr)
+}
+
+func fatal(err error) {
+	_, _ = fmt.Fprintf(os.Stderr, "[obdcli] %v\n", err)
+	os.Exit(1)
 }
 
 func main() {
@@ -72,11 +185,64 @@ func main() {
 	app.Name = "obdcli"
 	app.Version = "0.0.1-beta"
 	app.Usage = "Control plane for your Omni Bolt Daemon (obd)"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "rpcserver",
+			Value: "localhost:50051",
+			Usage: "host:port of the grpc server of obd",
+		},
+		cli.StringFlag{
+			Name:  "configpath",
+			Value: "config/conf.ini",
+			Usage: "path to the config file of obd, the default tls certificate is by its tls_cert or dataDirectory",
+		},
+		cli.StringFlag{
+			Name:  "tlscertpath",
+			Usage: "path to the tls certificate of obd, the default is the self-signed tls.cert in the data directory of obd",
+		},
+		cli.StringFlag{
+			Name:   "credential",
+			Usage:  "the api credential baked by obd",
+			EnvVar: "OBD_CREDENTIAL",
+		},
+		cli.StringFlag{
+			Name:   "session",
+			Usage:  "the session token returned by login",
+			EnvVar: "OBD_SESSION",
+		},
+		cli.StringFlag{
+			Name:  "sessionpath",
+			Value: defaultSessionPath(),
+			Usage: "path to the file where login saves the session token",
+		},
+		cli.BoolFlag{
+			Name:  "notls",
+			Usage: "connect without tls, when tls_disable is set in obd",
+		},
+	}
 	app.Commands = []cli.Command{
 		HelloCommand,
+		genSeedCommand,
+		loginCommand,
+		logoutCommand,
+		getInfoCommand,
+		connectCommand,
+		disconnectCommand,
+		listPeersCommand,
+		openChannelCommand,
+		fundChannelCommand,
+		closeChannelCommand,
+		listChannelsCommand,
+		pendingChannelsCommand,
+		channelBalanceCommand,
+		addInvoiceCommand,
+		parseInvoiceCommand,
+		listInvoicesCommand,
+		sendPaymentCommand,
+		rsmcPaymentCommand,
 	}
 
 	if err := app.Run(os.Args); err != nil {
 		log.Fatal(err)
 	}
-}
\ No newline at end of file
+}
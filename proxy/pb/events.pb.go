@@ -0,0 +1,757 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: events.proto
+
+package pb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type InvoiceSubscription struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvoiceSubscription) Reset()         { *m = InvoiceSubscription{} }
+func (m *InvoiceSubscription) String() string { return proto.CompactTextString(m) }
+func (*InvoiceSubscription) ProtoMessage()    {}
+func (*InvoiceSubscription) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{0}
+}
+
+func (m *InvoiceSubscription) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvoiceSubscription.Unmarshal(m, b)
+}
+func (m *InvoiceSubscription) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvoiceSubscription.Marshal(b, m, deterministic)
+}
+func (m *InvoiceSubscription) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvoiceSubscription.Merge(m, src)
+}
+func (m *InvoiceSubscription) XXX_Size() int {
+	return xxx_messageInfo_InvoiceSubscription.Size(m)
+}
+func (m *InvoiceSubscription) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvoiceSubscription.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvoiceSubscription proto.InternalMessageInfo
+
+type InvoiceUpdate struct {
+	PaymentRequest       string   `protobuf:"bytes,1,opt,name=payment_request,json=paymentRequest,proto3" json:"payment_request,omitempty"`
+	H                    string   `protobuf:"bytes,2,opt,name=h,proto3" json:"h,omitempty"`
+	R                    string   `protobuf:"bytes,3,opt,name=r,proto3" json:"r,omitempty"`
+	Value                float64  `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"`
+	ChannelId            string   `protobuf:"bytes,5,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvoiceUpdate) Reset()         { *m = InvoiceUpdate{} }
+func (m *InvoiceUpdate) String() string { return proto.CompactTextString(m) }
+func (*InvoiceUpdate) ProtoMessage()    {}
+func (*InvoiceUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{1}
+}
+
+func (m *InvoiceUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvoiceUpdate.Unmarshal(m, b)
+}
+func (m *InvoiceUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvoiceUpdate.Marshal(b, m, deterministic)
+}
+func (m *InvoiceUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvoiceUpdate.Merge(m, src)
+}
+func (m *InvoiceUpdate) XXX_Size() int {
+	return xxx_messageInfo_InvoiceUpdate.Size(m)
+}
+func (m *InvoiceUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvoiceUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvoiceUpdate proto.InternalMessageInfo
+
+func (m *InvoiceUpdate) GetPaymentRequest() string {
+	if m != nil {
+		return m.PaymentRequest
+	}
+	return ""
+}
+
+func (m *InvoiceUpdate) GetH() string {
+	if m != nil {
+		return m.H
+	}
+	return ""
+}
+
+func (m *InvoiceUpdate) GetR() string {
+	if m != nil {
+		return m.R
+	}
+	return ""
+}
+
+func (m *InvoiceUpdate) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *InvoiceUpdate) GetChannelId() string {
+	if m != nil {
+		return m.ChannelId
+	}
+	return ""
+}
+
+type ChannelEventSubscription struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChannelEventSubscription) Reset()         { *m = ChannelEventSubscription{} }
+func (m *ChannelEventSubscription) String() string { return proto.CompactTextString(m) }
+func (*ChannelEventSubscription) ProtoMessage()    {}
+func (*ChannelEventSubscription) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{2}
+}
+
+func (m *ChannelEventSubscription) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChannelEventSubscription.Unmarshal(m, b)
+}
+func (m *ChannelEventSubscription) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChannelEventSubscription.Marshal(b, m, deterministic)
+}
+func (m *ChannelEventSubscription) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChannelEventSubscription.Merge(m, src)
+}
+func (m *ChannelEventSubscription) XXX_Size() int {
+	return xxx_messageInfo_ChannelEventSubscription.Size(m)
+}
+func (m *ChannelEventSubscription) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChannelEventSubscription.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChannelEventSubscription proto.InternalMessageInfo
+
+type ChannelEventUpdate struct {
+	// channel_state or breach_remedy_sent
+	Type               string `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"`
+	ChannelId          string `protobuf:"bytes,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	TemporaryChannelId string `protobuf:"bytes,3,opt,name=temporary_channel_id,json=temporaryChannelId,proto3" json:"temporary_channel_id,omitempty"`
+	PropertyId         int64  `protobuf:"varint,4,opt,name=property_id,json=propertyId,proto3" json:"property_id,omitempty"`
+	CurrState          int32  `protobuf:"varint,5,opt,name=curr_state,json=currState,proto3" json:"curr_state,omitempty"`
+	// the txid and the amount of the breach remedy transaction
+	Txid                 string   `protobuf:"bytes,6,opt,name=txid,proto3" json:"txid,omitempty"`
+	Amount               float64  `protobuf:"fixed64,7,opt,name=amount,proto3" json:"amount,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ChannelEventUpdate) Reset()         { *m = ChannelEventUpdate{} }
+func (m *ChannelEventUpdate) String() string { return proto.CompactTextString(m) }
+func (*ChannelEventUpdate) ProtoMessage()    {}
+func (*ChannelEventUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{3}
+}
+
+func (m *ChannelEventUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ChannelEventUpdate.Unmarshal(m, b)
+}
+func (m *ChannelEventUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ChannelEventUpdate.Marshal(b, m, deterministic)
+}
+func (m *ChannelEventUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ChannelEventUpdate.Merge(m, src)
+}
+func (m *ChannelEventUpdate) XXX_Size() int {
+	return xxx_messageInfo_ChannelEventUpdate.Size(m)
+}
+func (m *ChannelEventUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_ChannelEventUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ChannelEventUpdate proto.InternalMessageInfo
+
+func (m *ChannelEventUpdate) GetType() string {
+	if m != nil {
+		return m.Type
+	}
+	return ""
+}
+
+func (m *ChannelEventUpdate) GetChannelId() string {
+	if m != nil {
+		return m.ChannelId
+	}
+	return ""
+}
+
+func (m *ChannelEventUpdate) GetTemporaryChannelId() string {
+	if m != nil {
+		return m.TemporaryChannelId
+	}
+	return ""
+}
+
+func (m *ChannelEventUpdate) GetPropertyId() int64 {
+	if m != nil {
+		return m.PropertyId
+	}
+	return 0
+}
+
+func (m *ChannelEventUpdate) GetCurrState() int32 {
+	if m != nil {
+		return m.CurrState
+	}
+	return 0
+}
+
+func (m *ChannelEventUpdate) GetTxid() string {
+	if m != nil {
+		return m.Txid
+	}
+	return ""
+}
+
+func (m *ChannelEventUpdate) GetAmount() float64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+type TrackPaymentRequest struct {
+	H                    string   `protobuf:"bytes,1,opt,name=h,proto3" json:"h,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TrackPaymentRequest) Reset()         { *m = TrackPaymentRequest{} }
+func (m *TrackPaymentRequest) String() string { return proto.CompactTextString(m) }
+func (*TrackPaymentRequest) ProtoMessage()    {}
+func (*TrackPaymentRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{4}
+}
+
+func (m *TrackPaymentRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TrackPaymentRequest.Unmarshal(m, b)
+}
+func (m *TrackPaymentRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TrackPaymentRequest.Marshal(b, m, deterministic)
+}
+func (m *TrackPaymentRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TrackPaymentRequest.Merge(m, src)
+}
+func (m *TrackPaymentRequest) XXX_Size() int {
+	return xxx_messageInfo_TrackPaymentRequest.Size(m)
+}
+func (m *TrackPaymentRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_TrackPaymentRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TrackPaymentRequest proto.InternalMessageInfo
+
+func (m *TrackPaymentRequest) GetH() string {
+	if m != nil {
+		return m.H
+	}
+	return ""
+}
+
+type PaymentUpdate struct {
+	H string `protobuf:"bytes,1,opt,name=h,proto3" json:"h,omitempty"`
+	// in_flight, succeeded or failed
+	State                string   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	ChannelId            string   `protobuf:"bytes,3,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	Amount               float64  `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	R                    string   `protobuf:"bytes,5,opt,name=r,proto3" json:"r,omitempty"`
+	FailureReason        string   `protobuf:"bytes,6,opt,name=failure_reason,json=failureReason,proto3" json:"failure_reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PaymentUpdate) Reset()         { *m = PaymentUpdate{} }
+func (m *PaymentUpdate) String() string { return proto.CompactTextString(m) }
+func (*PaymentUpdate) ProtoMessage()    {}
+func (*PaymentUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{5}
+}
+
+func (m *PaymentUpdate) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PaymentUpdate.Unmarshal(m, b)
+}
+func (m *PaymentUpdate) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PaymentUpdate.Marshal(b, m, deterministic)
+}
+func (m *PaymentUpdate) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PaymentUpdate.Merge(m, src)
+}
+func (m *PaymentUpdate) XXX_Size() int {
+	return xxx_messageInfo_PaymentUpdate.Size(m)
+}
+func (m *PaymentUpdate) XXX_DiscardUnknown() {
+	xxx_messageInfo_PaymentUpdate.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PaymentUpdate proto.InternalMessageInfo
+
+func (m *PaymentUpdate) GetH() string {
+	if m != nil {
+		return m.H
+	}
+	return ""
+}
+
+func (m *PaymentUpdate) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *PaymentUpdate) GetChannelId() string {
+	if m != nil {
+		return m.ChannelId
+	}
+	return ""
+}
+
+func (m *PaymentUpdate) GetAmount() float64 {
+	if m != nil {
+		return m.Amount
+	}
+	return 0
+}
+
+func (m *PaymentUpdate) GetR() string {
+	if m != nil {
+		return m.R
+	}
+	return ""
+}
+
+func (m *PaymentUpdate) GetFailureReason() string {
+	if m != nil {
+		return m.FailureReason
+	}
+	return ""
+}
+
+type TransactionSubscription struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TransactionSubscription) Reset()         { *m = TransactionSubscription{} }
+func (m *TransactionSubscription) String() string { return proto.CompactTextString(m) }
+func (*TransactionSubscription) ProtoMessage()    {}
+func (*TransactionSubscription) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8f22242cb04491f9, []int{6}
+}
+
+func (m *TransactionSubscription) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TransactionSubscription.Unmarshal(m, b)
+}
+func (m *TransactionSubscription) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TransactionSubscription.Marshal(b, m, deterministic)
+}
+func (m *TransactionSubscription) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TransactionSubscription.Merge(m, src)
+}
+func (m *TransactionSubscription) XXX_Size() int {
+	return xxx_messageInfo_TransactionSubscription.Size(m)
+}
+func (m *TransactionSubscription) XXX_DiscardUnknown() {
+	xxx_messageInfo_TransactionSubscription.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TransactionSubscription proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterType((*InvoiceSubscription)(nil), "proxy.InvoiceSubscription")
+	proto.RegisterType((*InvoiceUpdate)(nil), "proxy.InvoiceUpdate")
+	proto.RegisterType((*ChannelEventSubscription)(nil), "proxy.ChannelEventSubscription")
+	proto.RegisterType((*ChannelEventUpdate)(nil), "proxy.ChannelEventUpdate")
+	proto.RegisterType((*TrackPaymentRequest)(nil), "proxy.TrackPaymentRequest")
+	proto.RegisterType((*PaymentUpdate)(nil), "proxy.PaymentUpdate")
+	proto.RegisterType((*TransactionSubscription)(nil), "proxy.TransactionSubscription")
+}
+
+func init() {
+	proto.RegisterFile("events.proto", fileDescriptor_8f22242cb04491f9)
+}
+
+var fileDescriptor_8f22242cb04491f9 = []byte{
+	// 474 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x53, 0xd1, 0x6e, 0xd3, 0x30,
+	0x14, 0x95, 0xdb, 0xa6, 0xa8, 0x97, 0x76, 0x08, 0xaf, 0x1b, 0x59, 0x24, 0xd8, 0x14, 0x84, 0xd8,
+	0x53, 0x55, 0xc1, 0x1f, 0x6c, 0x42, 0xa8, 0x0f, 0x48, 0x28, 0x1b, 0x3c, 0xf0, 0x12, 0xb9, 0x89,
+	0x51, 0x23, 0x5a, 0xdb, 0x5c, 0x3b, 0x55, 0xf3, 0x09, 0x7c, 0x05, 0x3f, 0xc2, 0xef, 0xf0, 0x1f,
+	0x28, 0xb6, 0x9b, 0xa5, 0x25, 0x7b, 0xcb, 0x3d, 0xe7, 0xda, 0x3e, 0xf7, 0x9c, 0x1b, 0x18, 0xf3,
+	0x2d, 0x17, 0x46, 0xcf, 0x14, 0x4a, 0x23, 0x69, 0xa0, 0x50, 0xee, 0xaa, 0x68, 0x84, 0x2a, 0x73,
+	0x48, 0x7c, 0x06, 0xa7, 0x0b, 0xb1, 0x95, 0x45, 0xc6, 0xef, 0xca, 0xa5, 0xce, 0xb0, 0x50, 0xa6,
+	0x90, 0x22, 0xfe, 0x45, 0x60, 0xe2, 0xf1, 0x2f, 0x2a, 0x67, 0x86, 0xd3, 0xb7, 0xf0, 0x4c, 0xb1,
+	0x6a, 0xc3, 0x85, 0x49, 0x91, 0xff, 0x2c, 0xb9, 0x36, 0x21, 0xb9, 0x22, 0xd7, 0xa3, 0xe4, 0xc4,
+	0xc3, 0x89, 0x43, 0xe9, 0x18, 0xc8, 0x2a, 0xec, 0x59, 0x8a, 0xac, 0xea, 0x0a, 0xc3, 0xbe, 0xab,
+	0x90, 0x4e, 0x21, 0xd8, 0xb2, 0x75, 0xc9, 0xc3, 0xc1, 0x15, 0xb9, 0x26, 0x89, 0x2b, 0xe8, 0x4b,
+	0x80, 0x6c, 0xc5, 0x84, 0xe0, 0xeb, 0xb4, 0xc8, 0xc3, 0xc0, 0x36, 0x8f, 0x3c, 0xb2, 0xc8, 0xe3,
+	0x08, 0xc2, 0x5b, 0x57, 0x7c, 0xa8, 0x67, 0x39, 0xd0, 0xf9, 0x97, 0x00, 0x6d, 0x93, 0x5e, 0x2c,
+	0x85, 0x81, 0xa9, 0x14, 0xf7, 0x0a, 0xed, 0xf7, 0xd1, 0x2b, 0xbd, 0xa3, 0x57, 0xe8, 0x1c, 0xa6,
+	0x86, 0x6f, 0x94, 0x44, 0x86, 0x55, 0xda, 0x6a, 0x74, 0xda, 0x69, 0xc3, 0xdd, 0x36, 0x27, 0x2e,
+	0xe1, 0xa9, 0x42, 0xa9, 0x38, 0x9a, 0xaa, 0x6e, 0xac, 0x47, 0xea, 0x27, 0xb0, 0x87, 0x16, 0xb9,
+	0x7d, 0xb1, 0x44, 0x4c, 0xb5, 0x61, 0x86, 0xdb, 0xb9, 0x82, 0x64, 0x54, 0x23, 0x77, 0x66, 0x2f,
+	0x72, 0x57, 0xe4, 0xe1, 0xd0, 0x8b, 0xdc, 0x15, 0x39, 0x3d, 0x87, 0x21, 0xdb, 0xc8, 0x52, 0x98,
+	0xf0, 0x89, 0x75, 0xc8, 0x57, 0xf1, 0x6b, 0x38, 0xbd, 0x47, 0x96, 0xfd, 0xf8, 0xdc, 0xe1, 0x35,
+	0xf1, 0x5e, 0xc7, 0xbf, 0x09, 0x4c, 0x7c, 0x83, 0xf7, 0xe1, 0x80, 0xaf, 0xdd, 0x77, 0x52, 0xdc,
+	0xf0, 0xae, 0x38, 0xf2, 0xa5, 0x7f, 0xec, 0xcb, 0x83, 0xa2, 0x41, 0x5b, 0x91, 0x0b, 0x36, 0xd8,
+	0x07, 0xfb, 0x06, 0x4e, 0xbe, 0xb3, 0x62, 0x5d, 0x22, 0x4f, 0x91, 0x33, 0x2d, 0x85, 0x9f, 0x6a,
+	0xe2, 0xd1, 0xc4, 0x82, 0xf1, 0x05, 0xbc, 0xb8, 0x47, 0x26, 0x34, 0xcb, 0xea, 0xf4, 0xda, 0x49,
+	0xbe, 0xfb, 0xd3, 0x83, 0xa1, 0x8d, 0x50, 0xd3, 0x8f, 0xf0, 0xdc, 0x53, 0x4b, 0xee, 0x97, 0x50,
+	0xd3, 0x68, 0x66, 0x77, 0x77, 0xd6, 0xb1, 0xad, 0xd1, 0xf4, 0x90, 0x73, 0xc3, 0xcf, 0x09, 0xfd,
+	0x0a, 0xe7, 0xcd, 0x45, 0xed, 0x2d, 0xd1, 0xf4, 0xd2, 0x9f, 0x78, 0x6c, 0xb1, 0xa2, 0x8b, 0x8e,
+	0x86, 0xe6, 0xde, 0x1b, 0x18, 0xb7, 0xd3, 0x68, 0xb4, 0x75, 0x44, 0xd4, 0x68, 0x3b, 0x08, 0x66,
+	0x4e, 0xe8, 0x27, 0x38, 0x6b, 0xb4, 0xb5, 0x3c, 0xd1, 0xf4, 0xd5, 0xc3, 0x65, 0x5d, 0x46, 0x45,
+	0xf4, 0x7f, 0x7e, 0x4e, 0x6e, 0x06, 0xdf, 0x7a, 0x6a, 0xb9, 0x1c, 0xda, 0x9f, 0xfa, 0xfd, 0xbf,
+	0x01, 0x00, 0x61, 0x59, 0x2e, 0x7d, 0xf6, 0x03, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// EventsClient is the client API for Events service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type EventsClient interface {
+	//
+	// SubscribeInvoices sends the invoices of the current user when they are paid.
+	SubscribeInvoices(ctx context.Context, in *InvoiceSubscription, opts ...grpc.CallOption) (Events_SubscribeInvoicesClient, error)
+	//
+	// SubscribeChannelEvents sends the new states of the channels, and the breach remedy transactions.
+	SubscribeChannelEvents(ctx context.Context, in *ChannelEventSubscription, opts ...grpc.CallOption) (Events_SubscribeChannelEventsClient, error)
+	//
+	// TrackPayment sends the states of the htlc of the h, the stream ends when it is succeeded or failed.
+	TrackPayment(ctx context.Context, in *TrackPaymentRequest, opts ...grpc.CallOption) (Events_TrackPaymentClient, error)
+	//
+	// SubscribeTransactions sends the commitment transactions when they are signed by both sides.
+	SubscribeTransactions(ctx context.Context, in *TransactionSubscription, opts ...grpc.CallOption) (Events_SubscribeTransactionsClient, error)
+}
+
+type eventsClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewEventsClient(cc grpc.ClientConnInterface) EventsClient {
+	return &eventsClient{cc}
+}
+
+func (c *eventsClient) SubscribeInvoices(ctx context.Context, in *InvoiceSubscription, opts ...grpc.CallOption) (Events_SubscribeInvoicesClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Events_serviceDesc.Streams[0], "/proxy.Events/SubscribeInvoices", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsSubscribeInvoicesClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_SubscribeInvoicesClient interface {
+	Recv() (*InvoiceUpdate, error)
+	grpc.ClientStream
+}
+
+type eventsSubscribeInvoicesClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsSubscribeInvoicesClient) Recv() (*InvoiceUpdate, error) {
+	m := new(InvoiceUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsClient) SubscribeChannelEvents(ctx context.Context, in *ChannelEventSubscription, opts ...grpc.CallOption) (Events_SubscribeChannelEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Events_serviceDesc.Streams[1], "/proxy.Events/SubscribeChannelEvents", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsSubscribeChannelEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_SubscribeChannelEventsClient interface {
+	Recv() (*ChannelEventUpdate, error)
+	grpc.ClientStream
+}
+
+type eventsSubscribeChannelEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsSubscribeChannelEventsClient) Recv() (*ChannelEventUpdate, error) {
+	m := new(ChannelEventUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsClient) TrackPayment(ctx context.Context, in *TrackPaymentRequest, opts ...grpc.CallOption) (Events_TrackPaymentClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Events_serviceDesc.Streams[2], "/proxy.Events/TrackPayment", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsTrackPaymentClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_TrackPaymentClient interface {
+	Recv() (*PaymentUpdate, error)
+	grpc.ClientStream
+}
+
+type eventsTrackPaymentClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsTrackPaymentClient) Recv() (*PaymentUpdate, error) {
+	m := new(PaymentUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *eventsClient) SubscribeTransactions(ctx context.Context, in *TransactionSubscription, opts ...grpc.CallOption) (Events_SubscribeTransactionsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Events_serviceDesc.Streams[3], "/proxy.Events/SubscribeTransactions", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &eventsSubscribeTransactionsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Events_SubscribeTransactionsClient interface {
+	Recv() (*Transaction, error)
+	grpc.ClientStream
+}
+
+type eventsSubscribeTransactionsClient struct {
+	grpc.ClientStream
+}
+
+func (x *eventsSubscribeTransactionsClient) Recv() (*Transaction, error) {
+	m := new(Transaction)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// EventsServer is the server API for Events service.
+type EventsServer interface {
+	//
+	// SubscribeInvoices sends the invoices of the current user when they are paid.
+	SubscribeInvoices(*InvoiceSubscription, Events_SubscribeInvoicesServer) error
+	//
+	// SubscribeChannelEvents sends the new states of the channels, and the breach remedy transactions.
+	SubscribeChannelEvents(*ChannelEventSubscription, Events_SubscribeChannelEventsServer) error
+	//
+	// TrackPayment sends the states of the htlc of the h, the stream ends when it is succeeded or failed.
+	TrackPayment(*TrackPaymentRequest, Events_TrackPaymentServer) error
+	//
+	// SubscribeTransactions sends the commitment transactions when they are signed by both sides.
+	SubscribeTransactions(*TransactionSubscription, Events_SubscribeTransactionsServer) error
+}
+
+// UnimplementedEventsServer can be embedded to have forward compatible implementations.
+type UnimplementedEventsServer struct {
+}
+
+func (*UnimplementedEventsServer) SubscribeInvoices(req *InvoiceSubscription, srv Events_SubscribeInvoicesServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeInvoices not implemented")
+}
+func (*UnimplementedEventsServer) SubscribeChannelEvents(req *ChannelEventSubscription, srv Events_SubscribeChannelEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeChannelEvents not implemented")
+}
+func (*UnimplementedEventsServer) TrackPayment(req *TrackPaymentRequest, srv Events_TrackPaymentServer) error {
+	return status.Errorf(codes.Unimplemented, "method TrackPayment not implemented")
+}
+func (*UnimplementedEventsServer) SubscribeTransactions(req *TransactionSubscription, srv Events_SubscribeTransactionsServer) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeTransactions not implemented")
+}
+
+func RegisterEventsServer(s *grpc.Server, srv EventsServer) {
+	s.RegisterService(&_Events_serviceDesc, srv)
+}
+
+func _Events_SubscribeInvoices_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(InvoiceSubscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).SubscribeInvoices(m, &eventsSubscribeInvoicesServer{stream})
+}
+
+type Events_SubscribeInvoicesServer interface {
+	Send(*InvoiceUpdate) error
+	grpc.ServerStream
+}
+
+type eventsSubscribeInvoicesServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsSubscribeInvoicesServer) Send(m *InvoiceUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Events_SubscribeChannelEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ChannelEventSubscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).SubscribeChannelEvents(m, &eventsSubscribeChannelEventsServer{stream})
+}
+
+type Events_SubscribeChannelEventsServer interface {
+	Send(*ChannelEventUpdate) error
+	grpc.ServerStream
+}
+
+type eventsSubscribeChannelEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsSubscribeChannelEventsServer) Send(m *ChannelEventUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Events_TrackPayment_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TrackPaymentRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).TrackPayment(m, &eventsTrackPaymentServer{stream})
+}
+
+type Events_TrackPaymentServer interface {
+	Send(*PaymentUpdate) error
+	grpc.ServerStream
+}
+
+type eventsTrackPaymentServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsTrackPaymentServer) Send(m *PaymentUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Events_SubscribeTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(TransactionSubscription)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(EventsServer).SubscribeTransactions(m, &eventsSubscribeTransactionsServer{stream})
+}
+
+type Events_SubscribeTransactionsServer interface {
+	Send(*Transaction) error
+	grpc.ServerStream
+}
+
+type eventsSubscribeTransactionsServer struct {
+	grpc.ServerStream
+}
+
+func (x *eventsSubscribeTransactionsServer) Send(m *Transaction) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Events_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "proxy.Events",
+	HandlerType: (*EventsServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeInvoices",
+			Handler:       _Events_SubscribeInvoices_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeChannelEvents",
+			Handler:       _Events_SubscribeChannelEvents_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "TrackPayment",
+			Handler:       _Events_TrackPayment_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "SubscribeTransactions",
+			Handler:       _Events_SubscribeTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "events.proto",
+}
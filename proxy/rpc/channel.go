@@ -15,7 +15,7 @@ import (
 
 func (s *RpcServer) OpenChannel(ctx context.Context, in *pb.OpenChannelRequest) (*pb.OpenChannelResponse, error) {
 	log.Println("OpenChannel")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -33,8 +33,8 @@ func (s *RpcServer) OpenChannel(ctx context.Context, in *pb.OpenChannelRequest)
 	}
 
 	nodePubKeyIndex := -1
-	for i := 0; i < obcClient.User.CurrAddrIndex; i++ {
-		wallet, _ := service.HDWalletService.GetAddressByIndex(obcClient.User, uint32(i))
+	for i := 0; i < client.User.CurrAddrIndex; i++ {
+		wallet, _ := service.HDWalletService.GetAddressByIndex(client.User, uint32(i))
 		if wallet.PubKey == in.NodePubkeyString {
 			nodePubKeyIndex = i
 			break
@@ -53,8 +53,8 @@ func (s *RpcServer) OpenChannel(ctx context.Context, in *pb.OpenChannelRequest)
 	infoBytes, _ := json.Marshal(channelOpen)
 	requestMessage := bean.RequestMessage{
 		Type:                enum.MsgType_SendChannelOpen_32,
-		SenderNodePeerId:    obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId:    obcClient.User.PeerId,
+		SenderNodePeerId:    client.User.P2PLocalPeerId,
+		SenderUserPeerId:    client.User.PeerId,
 		RecipientNodePeerId: in.RecipientInfo.RecipientNodePeerId,
 		RecipientUserPeerId: in.RecipientInfo.RecipientUserPeerId,
 		Data:                string(infoBytes)}
@@ -64,21 +64,21 @@ func (s *RpcServer) OpenChannel(ctx context.Context, in *pb.OpenChannelRequest)
 		return nil, err
 	}
 
-	if obcClient.GrpcChan == nil {
-		obcClient.GrpcChan = make(chan []byte)
+	if client.GrpcChan == nil {
+		client.GrpcChan = make(chan []byte)
 	}
 
-	obcClient.ChannelModule(requestMessage)
+	client.ChannelModule(requestMessage)
 
-	message := <-obcClient.GrpcChan
+	message := <-client.GrpcChan
 
-	close(obcClient.GrpcChan)
-	obcClient.GrpcChan = nil
+	close(client.GrpcChan)
+	client.GrpcChan = nil
 
 	replyMessage := bean.ReplyMessage{}
 	_ = json.Unmarshal(message, &replyMessage)
 	if replyMessage.Status == false {
-		return nil, errors.New(replyMessage.Result.(string))
+		return nil, enum.WithCode(replyMessage.ErrorCode, replyMessage.Result.(string))
 	}
 
 	dataResult := replyMessage.Result.(map[string]interface{})
@@ -90,7 +90,7 @@ func (s *RpcServer) OpenChannel(ctx context.Context, in *pb.OpenChannelRequest)
 
 func (s *RpcServer) CloseChannel(ctx context.Context, in *pb.CloseChannelRequest) (*pb.CloseChannelResponse, error) {
 	log.Println("CloseChannel")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -100,16 +100,16 @@ func (s *RpcServer) CloseChannel(ctx context.Context, in *pb.CloseChannelRequest
 	marshal, _ := json.Marshal(in)
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_SendChannelOpen_32,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 		Data:             string(marshal)}
 
-	channel, err := service.ChannelService.ForceCloseChannel(requestMessage, obcClient.User)
+	channel, err := service.ChannelService.ForceCloseChannel(requestMessage, client.User)
 	if err != nil {
 		return nil, err
 	}
 
-	rsmcTxInfo, err := service.CommitmentTxService.GetLatestCommitmentTxByChannelId(string(marshal), obcClient.User)
+	rsmcTxInfo, err := service.CommitmentTxService.GetLatestCommitmentTxByChannelId(string(marshal), client.User)
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +126,7 @@ func (s *RpcServer) CloseChannel(ctx context.Context, in *pb.CloseChannelRequest
 
 func (s *RpcServer) GetChanInfo(ctx context.Context, in *pb.ChanInfoRequest) (*pb.ChannelEdge, error) {
 	log.Println("CloseChannel")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -134,7 +134,7 @@ func (s *RpcServer) GetChanInfo(ctx context.Context, in *pb.ChanInfoRequest) (*p
 		return nil, errors.New("wrong channel_id")
 	}
 
-	channelInfo, err := service.ChannelService.GetChannelInfoByChannelId(in.ChannelId, *obcClient.User)
+	channelInfo, err := service.ChannelService.GetChannelInfoByChannelId(in.ChannelId, *client.User)
 	if err != nil {
 		return nil, err
 	}
@@ -153,7 +153,7 @@ func (s *RpcServer) GetChanInfo(ctx context.Context, in *pb.ChanInfoRequest) (*p
 
 func (s *RpcServer) FundChannel(ctx context.Context, in *pb.FundChannelRequest) (*pb.FundChannelResponse, error) {
 	log.Println("FundChannel")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -187,8 +187,8 @@ func (s *RpcServer) FundChannel(ctx context.Context, in *pb.FundChannelRequest)
 	infoBytes, _ := json.Marshal(requestFunding)
 	requestMessage := bean.RequestMessage{
 		Type:                enum.MsgType_Funding_134,
-		SenderNodePeerId:    obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId:    obcClient.User.PeerId,
+		SenderNodePeerId:    client.User.P2PLocalPeerId,
+		SenderUserPeerId:    client.User.PeerId,
 		RecipientNodePeerId: in.RecipientInfo.RecipientNodePeerId,
 		RecipientUserPeerId: in.RecipientInfo.RecipientUserPeerId,
 		Data:                string(infoBytes)}
@@ -198,11 +198,11 @@ func (s *RpcServer) FundChannel(ctx context.Context, in *pb.FundChannelRequest)
 		return nil, err
 	}
 
-	_, dataBytes, status := obcClient.FundingTransactionModule(requestMessage)
+	_, dataBytes, status := client.FundingTransactionModule(requestMessage)
 
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	dataMap := make(map[string]interface{})
 	_ = json.Unmarshal(dataBytes, &dataMap)
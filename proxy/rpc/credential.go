@@ -0,0 +1,157 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// the scopes of the grpc methods, the methods not in it require the admin scope
+var grpcMethodScopes = map[string]string{
+	"/proxy.Lightning/Hello":               enum.Scope_Any,
+	"/proxy.Wallet/GenSeed":                enum.Scope_Any,
+	"/proxy.Wallet/Login":                  enum.Scope_Any,
+	"/proxy.Wallet/Logout":                 enum.Scope_Any,
+	"/proxy.Wallet/GetInfo":                enum.Scope_Read,
+	"/proxy.Wallet/EstimateFee":            enum.Scope_Read,
+	"/proxy.Wallet/GetAddressInfo":         enum.Scope_Read,
+	"/proxy.Wallet/ListPeers":              enum.Scope_Read,
+	"/proxy.Lightning/ClosedChannels":      enum.Scope_Read,
+	"/proxy.Lightning/ListChannels":        enum.Scope_Read,
+	"/proxy.Lightning/GetChanInfo":         enum.Scope_Read,
+	"/proxy.Lightning/PendingChannels":     enum.Scope_Read,
+	"/proxy.Lightning/LatestTransaction":   enum.Scope_Read,
+	"/proxy.Lightning/GetTransactions":     enum.Scope_Read,
+	"/proxy.Lightning/ChannelBalance":      enum.Scope_Read,
+	"/proxy.Htlc/ParseInvoice":             enum.Scope_Read,
+	"/proxy.Htlc/ListInvoices":             enum.Scope_Read,
+	"/proxy.Events/SubscribeInvoices":      enum.Scope_Read,
+	"/proxy.Events/SubscribeChannelEvents": enum.Scope_Read,
+	"/proxy.Events/TrackPayment":           enum.Scope_Read,
+	"/proxy.Events/SubscribeTransactions":  enum.Scope_Read,
+	"/proxy.Htlc/AddInvoice":               enum.Scope_Invoice,
+	"/proxy.Wallet/NextAddr":               enum.Scope_Payment,
+	"/proxy.Wallet/NewAddress":             enum.Scope_Payment,
+	"/proxy.Lightning/OpenChannel":         enum.Scope_Payment,
+	"/proxy.Lightning/CloseChannel":        enum.Scope_Payment,
+	"/proxy.Lightning/FundChannel":         enum.Scope_Payment,
+	"/proxy.Htlc/SendPayment":              enum.Scope_Payment,
+	"/proxy.Rsmc/RsmcPayment":              enum.Scope_Payment,
+	"/proxy.Lightning/ConnectPeer":         enum.Scope_Admin,
+	"/proxy.Lightning/DisconnectPeer":      enum.Scope_Admin,
+	"/proxy.Wallet/ChangePassword":         enum.Scope_Admin,
+}
+
+func getGrpcMethodScope(method string) string {
+	if scope, ok := grpcMethodScopes[method]; ok {
+		return scope
+	}
+	return enum.Scope_Admin
+}
+
+// check the api credential in the metadata "credential" before calling any method
+func credentialInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	credential, err := checkCredential(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkCredentialAmount(credential, info.FullMethod, req); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// credentialStreamInterceptor is the credentialInterceptor of the streams, the amount of every request received from the stream is checked
+func credentialStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	credential, err := checkCredential(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &credentialServerStream{ServerStream: ss, credential: credential, method: info.FullMethod})
+}
+
+type credentialServerStream struct {
+	grpc.ServerStream
+	credential *service.Credential
+	method     string
+}
+
+func (stream *credentialServerStream) RecvMsg(m interface{}) error {
+	if err := stream.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return checkCredentialAmount(stream.credential, stream.method, m)
+}
+
+func checkCredentialAmount(credential *service.Credential, method string, req interface{}) error {
+	if credential == nil {
+		return nil
+	}
+	requestData, _ := json.Marshal(req)
+	if err := credential.CheckAmount(getGrpcMethodScope(method), string(requestData)); err != nil {
+		return newStatusError(codes.PermissionDenied, err)
+	}
+	return nil
+}
+
+// checkCredential verify the credential of the request and check whether the method is allowed by it,
+// the credential is nil if the request has no credential and it is not required.
+func checkCredential(ctx context.Context, method string) (*service.Credential, error) {
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("credential"); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	if len(token) == 0 {
+		if config.ApiCredentialRequired {
+			return nil, newStatusError(codes.Unauthenticated, enum.NewError(enum.ErrorCode_credential_required))
+		}
+		return nil, nil
+	}
+
+	credential, err := service.CredentialService.Verify(token)
+	if err != nil {
+		return nil, newStatusError(codes.Unauthenticated, err)
+	}
+	remoteIp := ""
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteIp, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	err = credential.CheckAccess(getGrpcMethodScope(method), remoteIp)
+	if err != nil {
+		return nil, newStatusError(codes.PermissionDenied, err)
+	}
+	return credential, nil
+}
+
+// statusError the grpc status of the error, which keeps the error code of the error for the gateway
+type statusError struct {
+	code codes.Code
+	err  error
+}
+
+func newStatusError(code codes.Code, err error) error {
+	return &statusError{code: code, err: err}
+}
+
+func (err *statusError) Error() string {
+	return err.err.Error()
+}
+
+func (err *statusError) GRPCStatus() *status.Status {
+	return status.New(err.code, err.err.Error())
+}
+
+func (err *statusError) Unwrap() error {
+	return err.err
+}
@@ -0,0 +1,184 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/omnilaboratory/obd/bean/enum"
+	proxy "github.com/omnilaboratory/obd/proxy/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// the max size of the json body of a rest request
+const maxGatewayBodySize = 1 << 20
+
+// the grpc services exposed by the rest gateway
+var gatewayServices = []struct {
+	name   string
+	server reflect.Type
+}{
+	{"Lightning", reflect.TypeOf((*proxy.LightningServer)(nil)).Elem()},
+	{"Wallet", reflect.TypeOf((*proxy.WalletServer)(nil)).Elem()},
+	{"Rsmc", reflect.TypeOf((*proxy.RsmcServer)(nil)).Elem()},
+	{"Htlc", reflect.TypeOf((*proxy.HtlcServer)(nil)).Elem()},
+}
+
+var gatewayMarshaler = jsonpb.Marshaler{OrigName: true, EmitDefaults: true}
+
+// RegisterGateway add the rest routes of the grpc methods: POST /api/v1/<service>/<method>, such as /api/v1/lightning/openchannel.
+// the body is the json of the grpc request, and the credential and the session are in the headers of the same names as the grpc metadata.
+func RegisterGateway(router gin.IRouter) {
+	server := reflect.ValueOf(&RpcServer{})
+	group := router.Group("/api/v1")
+	for _, service := range gatewayServices {
+		for i := 0; i < service.server.NumMethod(); i++ {
+			method := service.server.Method(i)
+			// the unary methods are func(context.Context, *Request) (*Response, error)
+			if method.Type.NumIn() != 2 || method.Type.NumOut() != 2 {
+				continue
+			}
+			fullMethod := "/proxy." + service.name + "/" + method.Name
+			path := "/" + strings.ToLower(service.name) + "/" + strings.ToLower(method.Name)
+			group.POST(path, gatewayHandler(fullMethod, server.MethodByName(method.Name), method.Type.In(1).Elem()))
+		}
+	}
+}
+
+func gatewayHandler(fullMethod string, method reflect.Value, requestType reflect.Type) gin.HandlerFunc {
+	info := &grpc.UnaryServerInfo{Server: &RpcServer{}, FullMethod: fullMethod}
+	invoke := func(ctx context.Context, req interface{}) (interface{}, error) {
+		results := method.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
+		err, _ := results[1].Interface().(error)
+		return results[0].Interface(), err
+	}
+
+	return func(c *gin.Context) {
+		req := reflect.New(requestType).Interface().(proto.Message)
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxGatewayBodySize)
+		if err := jsonpb.Unmarshal(body, req); err != nil && err != io.EOF {
+			writeGatewayError(c, status.Error(codes.InvalidArgument, err.Error()))
+			return
+		}
+
+		stream := &gatewayTransportStream{method: fullMethod}
+		ctx := grpc.NewContextWithServerTransportStream(getGatewayContext(c), stream)
+		resp, err := credentialInterceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return sessionInterceptor(ctx, req, info, invoke)
+		})
+		if err != nil {
+			writeGatewayError(c, err)
+			return
+		}
+
+		for key, values := range stream.header {
+			if len(values) > 0 {
+				c.Header(key, values[0])
+			}
+		}
+		data := "{}"
+		if message, ok := resp.(proto.Message); ok && reflect.ValueOf(message).IsNil() == false {
+			data, err = gatewayMarshaler.MarshalToString(message)
+			if err != nil {
+				writeGatewayError(c, status.Error(codes.Internal, err.Error()))
+				return
+			}
+		}
+		c.Data(http.StatusOK, "application/json", []byte(data))
+	}
+}
+
+// the headers credential and session are the metadata of the grpc request, and the client ip is checked by the credential.
+// the ip is the one of the connection, the header X-Forwarded-For can be forged by the client.
+func getGatewayContext(c *gin.Context) context.Context {
+	md := metadata.MD{}
+	for _, key := range []string{"credential", sessionMetadataKey} {
+		if value := c.GetHeader(key); len(value) > 0 {
+			md.Set(key, value)
+		}
+	}
+	ctx := metadata.NewIncomingContext(c.Request.Context(), md)
+	remoteIp, _, err := net.SplitHostPort(c.Request.RemoteAddr)
+	if err != nil {
+		remoteIp = c.Request.RemoteAddr
+	}
+	return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(remoteIp)}})
+}
+
+// the failed reply has the error msg and its code, the same as the failed websocket reply. the error without code is
+// an unknown error.
+func writeGatewayError(c *gin.Context, err error) {
+	code := codes.Unknown
+	msg := err.Error()
+	if s, ok := status.FromError(err); ok {
+		code = s.Code()
+		msg = s.Message()
+	}
+	errorCode := enum.ErrorCodeOf(err)
+	c.JSON(getGatewayHttpStatus(code, errorCode), gin.H{"error": msg, "error_code": errorCode})
+}
+
+func getGatewayHttpStatus(code codes.Code, errorCode enum.ErrorCode) int {
+	switch code {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.Internal:
+		return http.StatusInternalServerError
+	}
+
+	// the methods return the error tips without the grpc codes
+	switch errorCode {
+	case enum.ErrorCode_unknown:
+		return http.StatusInternalServerError
+	case enum.ErrorCode_user_needLogin, enum.ErrorCode_user_wrongSession,
+		enum.ErrorCode_credential_required, enum.ErrorCode_credential_wrong,
+		enum.ErrorCode_credential_revoked, enum.ErrorCode_credential_expired:
+		return http.StatusUnauthorized
+	case enum.ErrorCode_credential_noScope, enum.ErrorCode_credential_wrongIp, enum.ErrorCode_credential_maxAmount:
+		return http.StatusForbidden
+	case enum.ErrorCode_common_notFound:
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// gatewayTransportStream keeps the headers set by the methods, such as the session of Login
+type gatewayTransportStream struct {
+	method string
+	header metadata.MD
+}
+
+func (stream *gatewayTransportStream) Method() string {
+	return stream.method
+}
+
+func (stream *gatewayTransportStream) SetHeader(md metadata.MD) error {
+	stream.header = metadata.Join(stream.header, md)
+	return nil
+}
+
+func (stream *gatewayTransportStream) SendHeader(md metadata.MD) error {
+	return stream.SetHeader(md)
+}
+
+func (stream *gatewayTransportStream) SetTrailer(md metadata.MD) error {
+	return nil
+}
@@ -15,7 +15,7 @@ import (
 
 func (s *RpcServer) AddInvoice(ctx context.Context, in *pb.Invoice) (*pb.AddInvoiceResponse, error) {
 	log.Println("AddInvoice")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -42,14 +42,14 @@ func (s *RpcServer) AddInvoice(ctx context.Context, in *pb.Invoice) (*pb.AddInvo
 	infoBytes, _ := json.Marshal(request)
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_HTLC_Invoice_402,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 		Data:             string(infoBytes)}
-	_, dataBytes, status := obcClient.HtlcHModule(requestMessage)
+	_, dataBytes, status := client.HtlcHModule(requestMessage)
 
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	dataMap := make(map[string]interface{})
 	_ = json.Unmarshal(dataBytes, &dataMap)
@@ -60,7 +60,7 @@ func (s *RpcServer) AddInvoice(ctx context.Context, in *pb.Invoice) (*pb.AddInvo
 
 func (s *RpcServer) ParseInvoice(ctx context.Context, in *pb.ParseInvoiceRequest) (*pb.ParseInvoiceResponse, error) {
 	log.Println("ParseInvoice")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -76,14 +76,14 @@ func (s *RpcServer) ParseInvoice(ctx context.Context, in *pb.ParseInvoiceRequest
 	infoBytes, _ := json.Marshal(request)
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_HTLC_ParseInvoice_403,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 		Data:             string(infoBytes)}
-	_, dataBytes, status := obcClient.HtlcHModule(requestMessage)
+	_, dataBytes, status := client.HtlcHModule(requestMessage)
 
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 
 	dataMap := make(map[string]interface{})
@@ -103,14 +103,14 @@ func (s *RpcServer) ParseInvoice(ctx context.Context, in *pb.ParseInvoiceRequest
 }
 func (s *RpcServer) ListInvoices(ctx context.Context, in *pb.ListInvoiceRequest) (*pb.ListInvoiceResponse, error) {
 	log.Println("ParseInvoice")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	infoBytes, _ := json.Marshal(in)
 
-	data, err := service.HtlcQueryTxManager.ListInvoices(string(infoBytes), *obcClient.User)
+	data, err := service.HtlcQueryTxManager.ListInvoices(string(infoBytes), *client.User)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +135,7 @@ func (s *RpcServer) ListInvoices(ctx context.Context, in *pb.ListInvoiceRequest)
 
 func (s *RpcServer) SendPayment(ctx context.Context, in *pb.SendRequest) (*pb.SendResponse, error) {
 	log.Println("SendPayment")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -150,24 +150,24 @@ func (s *RpcServer) SendPayment(ctx context.Context, in *pb.SendRequest) (*pb.Se
 	infoBytes, _ := json.Marshal(request)
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_HTLC_FindPath_401,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 		Data:             string(infoBytes)}
-	obcClient.HtlcHModule(requestMessage)
+	client.HtlcHModule(requestMessage)
 
-	if obcClient.GrpcChan == nil {
-		obcClient.GrpcChan = make(chan []byte)
+	if client.GrpcChan == nil {
+		client.GrpcChan = make(chan []byte)
 	}
 
-	message := <-obcClient.GrpcChan
+	message := <-client.GrpcChan
 
-	close(obcClient.GrpcChan)
-	obcClient.GrpcChan = nil
+	close(client.GrpcChan)
+	client.GrpcChan = nil
 
 	replyMessage := bean.ReplyMessage{}
 	_ = json.Unmarshal(message, &replyMessage)
 	if replyMessage.Status == false {
-		return nil, errors.New(replyMessage.Result.(string))
+		return nil, enum.WithCode(replyMessage.ErrorCode, replyMessage.Result.(string))
 	}
 
 	dataResult := replyMessage.Result.(map[string]interface{})
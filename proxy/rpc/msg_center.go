@@ -6,7 +6,6 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
-	"github.com/omnilaboratory/obd/lightclient"
 	"log"
 	"net/http/httptest"
 	"strings"
@@ -25,12 +24,7 @@ var (
 	onceRequestChan    = make(chan bean.ReplyMessage)
 )
 
-var obcClient *lightclient.Client
-
 func ConnToObd() (err error) {
-
-	obcClient = &lightclient.Client{IsGRpcRequest: true}
-
 	////u := url.URL{Scheme: "wss", Host: "127.0.0.1:60020", Path: "/ws" + config.ChainNodeType}
 	//u := url.URL{Scheme: "ws", Host: "127.0.0.1:60020", Path: "/ws" + config.ChainNodeType}
 	//log.Printf("grpc begin to connect to obd: %s", u.String())
@@ -44,10 +44,11 @@ func (s *RpcServer) ConnectPeer(ctx context.Context, in *pb.ConnectPeerRequest)
 	requestMessage := bean.RequestMessage{
 		Type: enum.MsgType_p2p_ConnectPeer_2003,
 		Data: string(marshal)}
-	_, bytes, status := obcClient.UserModule(requestMessage)
+	client := getSessionClient(ctx)
+	_, bytes, status := client.UserModule(requestMessage)
 	data := string(bytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	resp = &pb.ConnectPeerResponse{}
 	return resp, nil
@@ -64,10 +65,11 @@ func (s *RpcServer) DisconnectPeer(ctx context.Context, in *pb.DisconnectPeerReq
 	requestMessage := bean.RequestMessage{
 		Type: enum.MsgType_p2p_DisconnectPeer_2010,
 		Data: string(marshal)}
-	_, bytes, status := obcClient.UserModule(requestMessage)
+	client := getSessionClient(ctx)
+	_, bytes, status := client.UserModule(requestMessage)
 	data := string(bytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	resp = &pb.DisconnectPeerResponse{}
 	return resp, nil
@@ -76,7 +78,7 @@ func (s *RpcServer) DisconnectPeer(ctx context.Context, in *pb.DisconnectPeerReq
 func (s *RpcServer) PendingChannels(ctx context.Context, in *pb.PendingChannelsRequest) (resp *pb.ListChannelsResponse, err error) {
 	log.Println("PendingChannels")
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -118,7 +120,7 @@ func (s *RpcServer) PendingChannels(ctx context.Context, in *pb.PendingChannelsR
 }
 func (s *RpcServer) ListChannels(ctx context.Context, in *pb.ListChannelsRequest) (resp *pb.ListChannelsResponse, err error) {
 	log.Println("ListChannels")
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -161,7 +163,7 @@ func (s *RpcServer) LatestTransaction(ctx context.Context, in *pb.LatestTransact
 		return nil, errors.New("wrong channelId")
 	}
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -192,7 +194,7 @@ func (s *RpcServer) GetTransactions(ctx context.Context, in *pb.GetTransactionsR
 		return nil, errors.New("wrong channelId")
 	}
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -226,7 +228,7 @@ func (s *RpcServer) GetTransactions(ctx context.Context, in *pb.GetTransactionsR
 func (s *RpcServer) ChannelBalance(ctx context.Context, in *pb.ChannelBalanceRequest) (*pb.ChannelBalanceResponse, error) {
 	log.Println("ChannelBalance")
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -249,7 +251,7 @@ func (s *RpcServer) ChannelBalance(ctx context.Context, in *pb.ChannelBalanceReq
 func (s *RpcServer) ClosedChannels(ctx context.Context, in *pb.ClosedChannelsRequest) (resp *pb.ClosedChannelsResponse, err error) {
 	log.Println("ClosedChannels")
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
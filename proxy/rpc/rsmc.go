@@ -13,7 +13,7 @@ import (
 
 func (s *RpcServer) RsmcPayment(ctx context.Context, in *pb.RsmcPaymentRequest) (*pb.RsmcPaymentResponse, error) {
 	log.Println("RsmcPayment")
-	_, err := checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -42,8 +42,8 @@ func (s *RpcServer) RsmcPayment(ctx context.Context, in *pb.RsmcPaymentRequest)
 	infoBytes, _ := json.Marshal(request)
 	requestMessage := bean.RequestMessage{
 		Type:                enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351,
-		SenderNodePeerId:    obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId:    obcClient.User.PeerId,
+		SenderNodePeerId:    client.User.P2PLocalPeerId,
+		SenderUserPeerId:    client.User.PeerId,
 		RecipientNodePeerId: in.RecipientInfo.RecipientNodePeerId,
 		RecipientUserPeerId: in.RecipientInfo.RecipientUserPeerId,
 		Data:                string(infoBytes)}
@@ -53,11 +53,11 @@ func (s *RpcServer) RsmcPayment(ctx context.Context, in *pb.RsmcPaymentRequest)
 		return nil, err
 	}
 
-	_, dataBytes, status := obcClient.CommitmentTxModule(requestMessage)
+	_, dataBytes, status := client.CommitmentTxModule(requestMessage)
 
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	dataMap := make(map[string]interface{})
 	_ = json.Unmarshal(dataBytes, &dataMap)
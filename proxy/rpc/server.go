@@ -1,36 +1,85 @@
 package rpc
 
 import (
+	"context"
+	"errors"
 	"github.com/omnilaboratory/obd/config"
 	proxy "github.com/omnilaboratory/obd/proxy/pb"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/reflection"
 	"log"
 	"net"
 	"strconv"
 )
 
-func StartGrpcServer() {
+var grpcServer *grpc.Server
+
+// StartGrpcServer listen on the grpc port and serve in the background, the error is returned if the server can not start
+func StartGrpcServer() error {
 
 	log.Println("startGrpcServer")
 	err := ConnToObd()
 	if err != nil {
 		log.Println(err)
-		return
+		return err
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(credentialInterceptor, sessionInterceptor),
+		grpc.ChainStreamInterceptor(credentialStreamInterceptor, sessionStreamInterceptor),
+	}
+	if config.TlsDisable == false {
+		creds, err := credentials.NewServerTLSFromFile(config.TlsCert, config.TlsKey)
+		if err != nil {
+			log.Println("fail to load tls certificate:", err)
+			return err
+		}
+		opts = append(opts, grpc.Creds(creds))
 	}
 
 	address := "0.0.0.0:" + strconv.Itoa(config.GrpcServerPort)
 	lis, err := net.Listen("tcp", address)
 	if err != nil {
-		log.Fatalf("Error %v", err)
+		log.Println(err)
+		return err
 	}
 	log.Printf("grpc Server is listening on %v ...", address)
-
-	s := grpc.NewServer()
+	s := grpc.NewServer(opts...)
 	reflection.Register(s)
 	proxy.RegisterLightningServer(s, &RpcServer{})
 	proxy.RegisterWalletServer(s, &RpcServer{})
 	proxy.RegisterRsmcServer(s, &RpcServer{})
 	proxy.RegisterHtlcServer(s, &RpcServer{})
-	s.Serve(lis)
+	proxy.RegisterEventsServer(s, &RpcServer{})
+	grpcServer = s
+	grpcSessions.startExpireTimer()
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			log.Println("grpc server stops:", err)
+		}
+	}()
+	return nil
+}
+
+// StopGrpcServer stop accepting requests and wait for the running ones, the server is stopped forcibly when ctx is done,
+// and the error tells that some requests may be still running
+func StopGrpcServer(ctx context.Context) error {
+	if grpcServer == nil {
+		return nil
+	}
+	grpcSessions.stopExpireTimer()
+	grpcStreams.closeAll()
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		grpcServer.Stop()
+		return errors.New("grpc server is stopped forcibly, the running requests are not finished")
+	}
 }
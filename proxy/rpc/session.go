@@ -0,0 +1,226 @@
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/lightclient"
+	uuid "github.com/satori/go.uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+)
+
+// the metadata key of the session token, it is returned in the header of Login
+const sessionMetadataKey = "session"
+
+// every logged in grpc client has its own session, the user of the session is logged out when the session expires
+type grpcSession struct {
+	token      string
+	client     *lightclient.Client
+	lastActive time.Time
+}
+
+type sessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*grpcSession
+	quit     chan struct{}
+}
+
+var grpcSessions = sessionManager{sessions: make(map[string]*grpcSession)}
+
+type sessionContextKey struct{}
+
+// create a session for the logged in client, the other sessions of the same user are closed
+func (manager *sessionManager) create(client *lightclient.Client) (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for key, item := range manager.sessions {
+		if item.client.User == nil || item.client.User.PeerId == client.User.PeerId {
+			delete(manager.sessions, key)
+		}
+	}
+	manager.sessions[token] = &grpcSession{token: token, client: client, lastActive: time.Now()}
+	return token, nil
+}
+
+// get the session by the token, and keep it alive
+func (manager *sessionManager) get(token string) (*grpcSession, error) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	session := manager.sessions[token]
+	if session == nil {
+		return nil, enum.NewError(enum.ErrorCode_user_wrongSession)
+	}
+	if time.Since(session.lastActive) > config.GrpcSessionTimeout {
+		return nil, enum.NewError(enum.ErrorCode_user_wrongSession)
+	}
+	session.lastActive = time.Now()
+	return session, nil
+}
+
+// keep the session of an open stream alive, false if it is logged out or replaced
+func (manager *sessionManager) keepAlive(session *grpcSession) bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if manager.sessions[session.token] != session {
+		return false
+	}
+	session.lastActive = time.Now()
+	return true
+}
+
+func (manager *sessionManager) remove(token string) {
+	manager.mu.Lock()
+	delete(manager.sessions, token)
+	manager.mu.Unlock()
+}
+
+// logout the users of the expired sessions
+func (manager *sessionManager) expire() {
+	var expired []*grpcSession
+	manager.mu.Lock()
+	for token, session := range manager.sessions {
+		if time.Since(session.lastActive) > config.GrpcSessionTimeout {
+			expired = append(expired, session)
+			delete(manager.sessions, token)
+		}
+	}
+	manager.mu.Unlock()
+
+	for _, session := range expired {
+		log.Println("grpc session of", session.client.Id, "is expired")
+		logoutSession(session.client)
+	}
+}
+
+func (manager *sessionManager) startExpireTimer() {
+	manager.quit = make(chan struct{})
+	go func(quit chan struct{}) {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				manager.expire()
+			case <-quit:
+				return
+			}
+		}
+	}(manager.quit)
+}
+
+func (manager *sessionManager) stopExpireTimer() {
+	if manager.quit != nil {
+		close(manager.quit)
+		manager.quit = nil
+	}
+}
+
+// LogoutGrpcSessions close all sessions and logout their users, when obd is shutting down
+func LogoutGrpcSessions() {
+	grpcSessions.mu.Lock()
+	sessions := grpcSessions.sessions
+	grpcSessions.sessions = make(map[string]*grpcSession)
+	grpcSessions.mu.Unlock()
+
+	for _, session := range sessions {
+		lightclient.LogoutClient(session.client)
+	}
+}
+
+func logoutSession(client *lightclient.Client) {
+	if client.User == nil {
+		return
+	}
+	requestMessage := bean.RequestMessage{
+		Type:             enum.MsgType_UserLogout_2002,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
+	}
+	client.UserModule(requestMessage)
+}
+
+// find the session by the token in the metadata "session", the requests without the token have no session
+func sessionInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(sessionMetadataKey); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	if len(token) == 0 {
+		return handler(ctx, req)
+	}
+	session, err := grpcSessions.get(token)
+	if err != nil {
+		return nil, newStatusError(codes.Unauthenticated, err)
+	}
+	return handler(context.WithValue(ctx, sessionContextKey{}, session), req)
+}
+
+// sessionStreamInterceptor is the sessionInterceptor of the streams
+func sessionStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	token := ""
+	if md, ok := metadata.FromIncomingContext(ss.Context()); ok {
+		if values := md.Get(sessionMetadataKey); len(values) > 0 {
+			token = values[0]
+		}
+	}
+	if len(token) == 0 {
+		return handler(srv, ss)
+	}
+	session, err := grpcSessions.get(token)
+	if err != nil {
+		return newStatusError(codes.Unauthenticated, err)
+	}
+	return handler(srv, &contextServerStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), sessionContextKey{}, session)})
+}
+
+// the stream with the context of the interceptor
+type contextServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *contextServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func getSession(ctx context.Context) *grpcSession {
+	session, _ := ctx.Value(sessionContextKey{}).(*grpcSession)
+	return session
+}
+
+// the client of the session, or a new client for the requests which need not login
+func getSessionClient(ctx context.Context) *lightclient.Client {
+	if session := getSession(ctx); session != nil {
+		return session.client
+	}
+	return newGrpcClient()
+}
+
+func newGrpcClient() *lightclient.Client {
+	return &lightclient.Client{Id: uuid.NewV4().String(), IsGRpcRequest: true}
+}
+
+func checkLogin(ctx context.Context) (client *lightclient.Client, user *bean.User, err error) {
+	session := getSession(ctx)
+	if session == nil || session.client.User == nil {
+		return nil, nil, enum.NewError(enum.ErrorCode_user_needLogin)
+	}
+	return session.client, session.client.User, nil
+}
@@ -0,0 +1,259 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	proxy "github.com/omnilaboratory/obd/proxy/pb"
+	"github.com/omnilaboratory/obd/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// the max count of the events waiting for a stream, the events are dropped if the client reads too slowly
+const streamQueueSize = 256
+
+// the session of a stream is kept alive in this interval
+const streamKeepAliveInterval = time.Minute
+
+type eventStream struct {
+	userPeerId string
+	events     chan bean.Event
+	quit       chan struct{}
+}
+
+type streamManager struct {
+	mu        sync.RWMutex
+	streams   map[*eventStream]bool
+	closed    bool
+	startOnce sync.Once
+}
+
+// the streams of the grpc subscriptions, they receive the events of service.EventService
+var grpcStreams = streamManager{streams: make(map[*eventStream]bool)}
+
+func (manager *streamManager) subscribe(userPeerId string) (*eventStream, error) {
+	manager.startOnce.Do(func() {
+		service.EventService.AddListener(manager.push)
+	})
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	if manager.closed {
+		return nil, status.Error(codes.Unavailable, "the grpc server is stopping")
+	}
+	stream := &eventStream{
+		userPeerId: userPeerId,
+		events:     make(chan bean.Event, streamQueueSize),
+		quit:       make(chan struct{}),
+	}
+	manager.streams[stream] = true
+	return stream, nil
+}
+
+func (manager *streamManager) unsubscribe(stream *eventStream) {
+	manager.mu.Lock()
+	delete(manager.streams, stream)
+	manager.mu.Unlock()
+}
+
+func (manager *streamManager) push(event bean.Event) {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	for stream := range manager.streams {
+		if stream.userPeerId != event.UserPeerId {
+			continue
+		}
+		select {
+		case stream.events <- event:
+		default:
+			log.Println("the grpc stream of", stream.userPeerId, "is full, drop the event", event.Type)
+		}
+	}
+}
+
+// end all streams, so that the grpc server can be stopped gracefully
+func (manager *streamManager) closeAll() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.closed = true
+	for stream := range manager.streams {
+		close(stream.quit)
+		delete(manager.streams, stream)
+	}
+}
+
+// the data of all event types, see service/event.go
+type eventData struct {
+	TemporaryChannelId   string  `json:"temporary_channel_id"`
+	ChannelId            string  `json:"channel_id"`
+	PropertyId           int64   `json:"property_id"`
+	CurrState            int32   `json:"curr_state"`
+	CurrHash             string  `json:"curr_hash"`
+	TxType               int32   `json:"tx_type"`
+	AmountToRsmc         float64 `json:"amount_to_rsmc"`
+	AmountToCounterparty float64 `json:"amount_to_counterparty"`
+	AmountToHtlc         float64 `json:"amount_to_htlc"`
+	PeerIdA              string  `json:"peer_id_a"`
+	PeerIdB              string  `json:"peer_id_b"`
+	Invoice              string  `json:"invoice"`
+	H                    string  `json:"h"`
+	R                    string  `json:"r"`
+	Amount               float64 `json:"amount"`
+	HtlcSender           string  `json:"htlc_sender"`
+	Reason               string  `json:"reason"`
+	Txid                 string  `json:"txid"`
+}
+
+func getEventData(event bean.Event) (*eventData, error) {
+	data := &eventData{}
+	bytes, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(bytes, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.ChannelId) == 0 {
+		data.ChannelId = event.ChannelId
+	}
+	return data, nil
+}
+
+// send the events of the logged in user by handle until the client cancels the stream,
+// the session is kept alive while the stream is open, and handle returns true to end the stream
+func streamEvents(ctx context.Context, handle func(event bean.Event, data *eventData) (bool, error)) error {
+	_, user, err := checkLogin(ctx)
+	if err != nil {
+		return err
+	}
+	session := getSession(ctx)
+	stream, err := grpcStreams.subscribe(user.PeerId)
+	if err != nil {
+		return err
+	}
+	defer grpcStreams.unsubscribe(stream)
+
+	ticker := time.NewTicker(streamKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-stream.events:
+			data, err := getEventData(event)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			done, err := handle(event, data)
+			if err != nil || done {
+				return err
+			}
+		case <-ticker.C:
+			if grpcSessions.keepAlive(session) == false {
+				return newStatusError(codes.Unauthenticated, enum.NewError(enum.ErrorCode_user_wrongSession))
+			}
+		case <-stream.quit:
+			return status.Error(codes.Unavailable, "the grpc server is stopping")
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// SubscribeInvoices send the invoices of the current user when they are paid
+func (s *RpcServer) SubscribeInvoices(in *proxy.InvoiceSubscription, updateStream proxy.Events_SubscribeInvoicesServer) error {
+	return streamEvents(updateStream.Context(), func(event bean.Event, data *eventData) (bool, error) {
+		if event.Type != enum.EventType_InvoicePaid {
+			return false, nil
+		}
+		return false, updateStream.Send(&proxy.InvoiceUpdate{
+			PaymentRequest: data.Invoice,
+			H:              data.H,
+			R:              data.R,
+			Value:          data.Amount,
+			ChannelId:      data.ChannelId,
+		})
+	})
+}
+
+// SubscribeChannelEvents send the new states of the channels of the current user, and the breach remedy transactions
+func (s *RpcServer) SubscribeChannelEvents(in *proxy.ChannelEventSubscription, updateStream proxy.Events_SubscribeChannelEventsServer) error {
+	return streamEvents(updateStream.Context(), func(event bean.Event, data *eventData) (bool, error) {
+		if event.Type != enum.EventType_ChannelState && event.Type != enum.EventType_BreachRemedySent {
+			return false, nil
+		}
+		return false, updateStream.Send(&proxy.ChannelEventUpdate{
+			Type:               string(event.Type),
+			ChannelId:          data.ChannelId,
+			TemporaryChannelId: data.TemporaryChannelId,
+			PropertyId:         data.PropertyId,
+			CurrState:          data.CurrState,
+			Txid:               data.Txid,
+			Amount:             data.Amount,
+		})
+	})
+}
+
+const (
+	paymentState_InFlight  = "in_flight"
+	paymentState_Succeeded = "succeeded"
+	paymentState_Failed    = "failed"
+)
+
+// TrackPayment send the states of the htlc of the h, the stream ends when the htlc is settled or failed
+func (s *RpcServer) TrackPayment(in *proxy.TrackPaymentRequest, updateStream proxy.Events_TrackPaymentServer) error {
+	if len(in.H) == 0 {
+		return enum.NewError(enum.ErrorCode_common_empty, "h")
+	}
+	return streamEvents(updateStream.Context(), func(event bean.Event, data *eventData) (bool, error) {
+		if data.H != in.H {
+			return false, nil
+		}
+		update := &proxy.PaymentUpdate{
+			H:         data.H,
+			ChannelId: data.ChannelId,
+			Amount:    data.Amount,
+		}
+		switch event.Type {
+		case enum.EventType_HtlcAdded:
+			update.State = paymentState_InFlight
+		case enum.EventType_HtlcSettled:
+			update.State = paymentState_Succeeded
+			update.R = data.R
+		case enum.EventType_HtlcFailed:
+			update.State = paymentState_Failed
+			update.FailureReason = data.Reason
+		default:
+			return false, nil
+		}
+		err := updateStream.Send(update)
+		return update.State != paymentState_InFlight, err
+	})
+}
+
+// SubscribeTransactions send the commitment transactions of the current user when they are signed by both sides
+func (s *RpcServer) SubscribeTransactions(in *proxy.TransactionSubscription, updateStream proxy.Events_SubscribeTransactionsServer) error {
+	return streamEvents(updateStream.Context(), func(event bean.Event, data *eventData) (bool, error) {
+		if event.Type != enum.EventType_CommitmentTxSigned {
+			return false, nil
+		}
+		return false, updateStream.Send(&proxy.Transaction{
+			TxHash:     data.CurrHash,
+			ChannelId:  data.ChannelId,
+			AmountA:    data.AmountToRsmc,
+			AmountB:    data.AmountToCounterparty,
+			PeerA:      data.PeerIdA,
+			PeerB:      data.PeerIdB,
+			CurrState:  data.CurrState,
+			TxType:     data.TxType,
+			H:          data.H,
+			R:          data.R,
+			AmountHtlc: data.AmountToHtlc,
+		})
+	})
+}
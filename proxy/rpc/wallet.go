@@ -11,6 +11,8 @@ import (
 	"github.com/omnilaboratory/obd/omnicore"
 	"github.com/omnilaboratory/obd/proxy/pb"
 	"github.com/omnilaboratory/obd/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 	"log"
 	"strings"
 )
@@ -18,13 +20,6 @@ import (
 var connObd *websocket.Conn
 var currUserInfo *pb.LoginResponse
 
-func checkLogin() (user *bean.User, err error) {
-	if obcClient.User == nil {
-		return nil, errors.New("please login")
-	}
-	return obcClient.User, nil
-}
-
 func checkTargetUserIsOnline(requestMessage bean.RequestMessage) (err error) {
 	_, err = lightclient.FindUserOnLine(requestMessage)
 	if err != nil {
@@ -43,14 +38,13 @@ func (server *RpcServer) GenSeed(ctx context.Context, in *pb.GenSeedRequest) (re
 	log.Println("GenSeed")
 
 	requestMessage := bean.RequestMessage{
-		Type:             enum.MsgType_GetMnemonic_2004,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		Type: enum.MsgType_GetMnemonic_2004,
 	}
-	_, dataBytes, status := obcClient.HdWalletModule(requestMessage)
+	client := getSessionClient(ctx)
+	_, dataBytes, status := client.HdWalletModule(requestMessage)
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 	resp = &pb.GenSeedResponse{
 		CipherSeedMnemonic: data,
@@ -62,9 +56,11 @@ func (server *RpcServer) Login(ctx context.Context, in *pb.LoginRequest) (resp *
 
 	log.Println("Login")
 
-	if obcClient.User != nil {
-		if obcClient.User.Mnemonic != in.Mnemonic {
-			return nil, errors.New("user '" + in.Mnemonic + "' is online")
+	client := getSessionClient(ctx)
+	if client.User != nil {
+		peerId, _ := service.HDWalletService.GetUserPeerId(in.Mnemonic)
+		if client.User.PeerId != peerId {
+			return nil, errors.New("user '" + client.User.PeerId + "' is online")
 		}
 	}
 
@@ -78,10 +74,18 @@ func (server *RpcServer) Login(ctx context.Context, in *pb.LoginRequest) (resp *
 		Type: enum.MsgType_UserLogin_2001,
 		Data: string(infoBytes),
 	}
-	_, dataBytes, status := obcClient.UserModule(requestMessage)
+	_, dataBytes, status := client.UserModule(requestMessage)
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
+	}
+
+	if session := getSession(ctx); session == nil || session.client != client {
+		token, err := grpcSessions.create(client)
+		if err != nil {
+			return nil, err
+		}
+		_ = grpc.SetHeader(ctx, metadata.Pairs(sessionMetadataKey, token))
 	}
 
 	dataMap := make(map[string]interface{})
@@ -94,7 +98,6 @@ func (server *RpcServer) Login(ctx context.Context, in *pb.LoginRequest) (resp *
 		HtlcMaxFee:    dataMap["htlcMaxFee"].(float64),
 		ChainNodeType: dataMap["chainNodeType"].(string),
 	}
-	currUserInfo = resp
 	return resp, nil
 }
 
@@ -102,9 +105,10 @@ func (server *RpcServer) GetInfo(ctx context.Context, in *pb.GetInfoRequest) (re
 	requestMessage := bean.RequestMessage{
 		Type: enum.MsgType_User_GetInfo_2009,
 	}
-	_, dataBytes, status := obcClient.UserModule(requestMessage)
+	client := getSessionClient(ctx)
+	_, dataBytes, status := client.UserModule(requestMessage)
 	if status == false {
-		return nil, errors.New(string(dataBytes))
+		return nil, client.GetError(string(dataBytes))
 	}
 
 	dataMap := make(map[string]interface{})
@@ -123,7 +127,7 @@ func (server *RpcServer) GetInfo(ctx context.Context, in *pb.GetInfoRequest) (re
 func (server *RpcServer) NextAddr(ctx context.Context, in *pb.AddrRequest) (resp *pb.AddrResponse, err error) {
 	log.Println("NextAddr")
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -148,12 +152,12 @@ func (server *RpcServer) GetAddressInfo(ctx context.Context, in *pb.GetAddressIn
 		return nil, errors.New("empty addr")
 	}
 
-	user, err := checkLogin()
+	client, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	for i := 0; i < obcClient.User.CurrAddrIndex; i++ {
+	for i := 0; i < client.User.CurrAddrIndex; i++ {
 		wallet, _ := service.HDWalletService.GetAddressByIndex(user, uint32(i))
 		if wallet.Address == in.GetAddr() {
 			resp = &pb.AddrResponse{
@@ -173,7 +177,7 @@ func (server *RpcServer) GetAddressInfo(ctx context.Context, in *pb.GetAddressIn
 func (server *RpcServer) NewAddress(ctx context.Context, in *pb.NewAddressRequest) (resp *pb.NewAddressResponse, err error) {
 	log.Println("NextAddr")
 
-	user, err := checkLogin()
+	_, user, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -203,28 +207,29 @@ func (server *RpcServer) EstimateFee(ctx context.Context, in *pb.EstimateFeeRequ
 func (server *RpcServer) Logout(ctx context.Context, in *pb.LogoutRequest) (resp *pb.LogoutResponse, err error) {
 	log.Println("Logout")
 
-	_, err = checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_UserLogout_2002,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 	}
-	_, dataBytes, status := obcClient.UserModule(requestMessage)
+	_, dataBytes, status := client.UserModule(requestMessage)
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
+	grpcSessions.remove(getSession(ctx).token)
 	return &pb.LogoutResponse{}, nil
 }
 
 func (server *RpcServer) ChangePassword(ctx context.Context, in *pb.ChangePasswordRequest) (resp *pb.ChangePasswordResponse, err error) {
 	log.Println("ChangePassword")
 
-	_, err = checkLogin()
+	client, _, err := checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -242,13 +247,13 @@ func (server *RpcServer) ChangePassword(ctx context.Context, in *pb.ChangePasswo
 	infoBytes, _ := json.Marshal(token)
 	requestMessage := bean.RequestMessage{
 		Type:             enum.MsgType_User_UpdateAdminToken_2008,
-		SenderNodePeerId: obcClient.User.P2PLocalPeerId,
-		SenderUserPeerId: obcClient.User.PeerId,
+		SenderNodePeerId: client.User.P2PLocalPeerId,
+		SenderUserPeerId: client.User.PeerId,
 		Data:             string(infoBytes)}
-	_, dataBytes, status := obcClient.UserModule(requestMessage)
+	_, dataBytes, status := client.UserModule(requestMessage)
 	data := string(dataBytes)
 	if status == false {
-		return nil, errors.New(data)
+		return nil, client.GetError(data)
 	}
 
 	resp = &pb.ChangePasswordResponse{
@@ -259,7 +264,7 @@ func (server *RpcServer) ChangePassword(ctx context.Context, in *pb.ChangePasswo
 func (server *RpcServer) ListPeers(ctx context.Context, in *pb.ListPeersRequest) (resp *pb.ListPeersResponse, err error) {
 	log.Println("ListPeers")
 
-	_, err = checkLogin()
+	_, _, err = checkLogin(ctx)
 	if err != nil {
 		return nil, err
 	}
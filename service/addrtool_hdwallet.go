@@ -1,12 +1,16 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"github.com/asdine/storm/q"
 	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcutil"
 	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
 	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/dao"
 	"github.com/omnilaboratory/obd/tool"
@@ -55,6 +59,11 @@ func getWalletObj(addrIndexExtKey *bip32.Key, wallet *Wallet) (err error) {
 
 	wallet.PubKey = hex.EncodeToString(addrIndexExtKey.PublicKey().Key)
 
+	// the user logs in with the xpub, obd has no private key of the address
+	if addrIndexExtKey.IsPrivate == false {
+		return nil
+	}
+
 	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), addrIndexExtKey.Key)
 
 	//wallet.PrivateKey = hex.EncodeToString(privKey.Serialize())
@@ -93,6 +102,85 @@ func (service *hdWalletManager) CreateNewAddress(user *bean.User) (wallet *Walle
 	return wallet, nil
 }
 
+// GetUserPeerId the peer id of the user only depends on the wallet
+func (service *hdWalletManager) GetUserPeerId(mnemonic string) (string, error) {
+	changeExtKey, err := service.CreateChangeExtKey(mnemonic)
+	if err != nil {
+		return "", err
+	}
+	return tool.GetUserPeerId(changeExtKey.PublicKey().Key), nil
+}
+
+// the message of the login challenge, which is signed by the private key of m/44'/coinType'
+const loginChallengePrefix = "obd login challenge: "
+
+// the challenge is bound to the obd node, the signature for one obd can not be replayed to login at another one
+func getLoginChallenge(nodeId, nonce string) string {
+	return loginChallengePrefix + nodeId + ":" + nonce
+}
+
+// CreateLoginNonce a random single use nonce for the challenge login
+func (service *hdWalletManager) CreateLoginNonce() (string, error) {
+	nonce := make([]byte, 32)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(nonce), nil
+}
+
+// SignLoginNonce used by the client: return the xpub of m/44'/coinType' and the der signature of the nonce of the obd node
+func (service *hdWalletManager) SignLoginNonce(mnemonic, nodeId, nonce string) (xpub string, signature string, err error) {
+	changeExtKey, err := service.CreateChangeExtKey(mnemonic)
+	if err != nil {
+		return "", "", err
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), changeExtKey.Key)
+	sig, err := privKey.Sign(chainhash.DoubleHashB([]byte(getLoginChallenge(nodeId, nonce))))
+	if err != nil {
+		return "", "", err
+	}
+	return changeExtKey.PublicKey().B58Serialize(), hex.EncodeToString(sig.Serialize()), nil
+}
+
+// VerifyLoginSignature check the signature of the nonce of the obd node, and return the public ext key of m/44'/coinType'
+func (service *hdWalletManager) VerifyLoginSignature(xpub, nodeId, nonce, signature string) (changeExtKey *bip32.Key, err error) {
+	if tool.CheckIsString(&xpub) == false {
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "xpub")
+	}
+	changeExtKey, err = bip32.B58Deserialize(xpub)
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "xpub")
+	}
+	if changeExtKey.IsPrivate {
+		return nil, enum.NewError(enum.ErrorCode_user_privateExtKey)
+	}
+	coinType := uint32(0)
+	if strings.Contains(config.ChainNodeType, "main") == false {
+		coinType = 1
+	}
+	if changeExtKey.Depth != 2 || binary.BigEndian.Uint32(changeExtKey.ChildNumber) != bip32.FirstHardenedChild+coinType {
+		return nil, enum.NewError(enum.ErrorCode_user_wrongXpubPath)
+	}
+
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "signature")
+	}
+	sig, err := btcec.ParseDERSignature(sigBytes, btcec.S256())
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "signature")
+	}
+	pubKey, err := btcec.ParsePubKey(changeExtKey.Key, btcec.S256())
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "xpub")
+	}
+	if sig.Verify(chainhash.DoubleHashB([]byte(getLoginChallenge(nodeId, nonce))), pubKey) == false {
+		return nil, enum.NewError(enum.ErrorCode_user_wrongLoginSignature)
+	}
+	return changeExtKey, nil
+}
+
 func (service *hdWalletManager) CreateChangeExtKey(mnemonic string) (changeExtKey *bip32.Key, err error) {
 	if tool.CheckIsString(&mnemonic) == false {
 		return nil, errors.New("error mnemonic")
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/tool"
+)
+
+func TestVerifyLoginSignature(t *testing.T) {
+	config.ChainNodeType = "regtest"
+	mnemonic := "coyote antenna senior reward diesel vault into used veteran model throw relief"
+	nonce, err := HDWalletService.CreateLoginNonce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodeId := "QmNode"
+	xpub, signature, err := HDWalletService.SignLoginNonce(mnemonic, nodeId, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changeExtKey, err := HDWalletService.VerifyLoginSignature(xpub, nodeId, nonce, signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peerId, _ := HDWalletService.GetUserPeerId(mnemonic)
+	if tool.GetUserPeerId(changeExtKey.Key) != peerId {
+		t.Fatal("the peer id of the challenge login is not the one of the mnemonic login")
+	}
+
+	otherNonce, _ := HDWalletService.CreateLoginNonce()
+	if _, err = HDWalletService.VerifyLoginSignature(xpub, nodeId, otherNonce, signature); err == nil {
+		t.Fatal("verify the signature of another nonce")
+	}
+	if _, err = HDWalletService.VerifyLoginSignature(xpub, "QmOtherNode", nonce, signature); err == nil {
+		t.Fatal("verify the signature of the nonce at another obd node")
+	}
+
+	// the addresses are derived from the xpub without private keys
+	wallet, err := HDWalletService.GetAddressByIndex(&bean.User{ChangeExtKey: changeExtKey}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeExtKey, _ = HDWalletService.CreateChangeExtKey(mnemonic)
+	privateWallet, _ := HDWalletService.GetAddressByIndex(&bean.User{ChangeExtKey: changeExtKey}, 1)
+	if wallet.Address != privateWallet.Address || wallet.PubKey != privateWallet.PubKey || wallet.Wif != "" {
+		t.Fatal("wrong address derived from the xpub")
+	}
+}
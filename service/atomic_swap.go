@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/asdine/storm/q"
@@ -56,7 +57,7 @@ func (this *atomicSwapManager) AtomicSwap(msg bean.RequestMessage, user bean.Use
 		return nil, errors.New("error channel_id_from")
 	}
 
-	_, err = conn2tracker.OmniGetProperty(reqData.PropertySent)
+	_, err = conn2tracker.OmniGetProperty(context.Background(), reqData.PropertySent)
 	if err != nil {
 		log.Println(err)
 		return nil, errors.New("error property_sent")
@@ -78,7 +79,7 @@ func (this *atomicSwapManager) AtomicSwap(msg bean.RequestMessage, user bean.Use
 		return nil, errors.New("error channel_id_to")
 	}
 
-	_, err = conn2tracker.OmniGetProperty(reqData.PropertyReceived)
+	_, err = conn2tracker.OmniGetProperty(context.Background(), reqData.PropertyReceived)
 	if err != nil {
 		log.Println(err)
 		return nil, errors.New("error property_received")
@@ -91,7 +92,10 @@ func (this *atomicSwapManager) AtomicSwap(msg bean.RequestMessage, user bean.Use
 		return nil, errors.New("error transaction_id")
 	}
 
-	flag := conn2tracker.GetChannelState(reqData.ChannelIdTo)
+	flag, err := conn2tracker.GetChannelState(reqData.ChannelIdTo)
+	if err != nil {
+		return nil, err
+	}
 	if flag == 0 {
 		return nil, errors.New("not found this channel_id_to")
 	}
@@ -206,7 +210,7 @@ func (this *atomicSwapManager) AtomicSwapAccepted(msg bean.RequestMessage, user
 		return nil, errors.New("error channel_id_from")
 	}
 
-	_, err = conn2tracker.OmniGetProperty(reqData.PropertySent)
+	_, err = conn2tracker.OmniGetProperty(context.Background(), reqData.PropertySent)
 	if err != nil {
 		log.Println(err)
 		return nil, errors.New("error property_sent")
@@ -228,7 +232,7 @@ func (this *atomicSwapManager) AtomicSwapAccepted(msg bean.RequestMessage, user
 		return nil, errors.New("error channel_id_to")
 	}
 
-	_, err = conn2tracker.OmniGetProperty(reqData.PropertyReceived)
+	_, err = conn2tracker.OmniGetProperty(context.Background(), reqData.PropertyReceived)
 	if err != nil {
 		log.Println(err)
 		return nil, errors.New("error property_received")
@@ -256,7 +260,10 @@ func (this *atomicSwapManager) AtomicSwapAccepted(msg bean.RequestMessage, user
 		return nil, errors.New("error target_transaction_id")
 	}
 
-	flag := conn2tracker.GetChannelState(reqData.ChannelIdTo)
+	flag, err := conn2tracker.GetChannelState(reqData.ChannelIdTo)
+	if err != nil {
+		return nil, err
+	}
 	if flag == 0 {
 		return nil, errors.New("not found this channel_id_to")
 	}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/asdine/storm"
@@ -26,7 +27,7 @@ var ChannelService = channelManager{}
 // AliceOpenChannel init ChannelInfo
 func (this *channelManager) AliceOpenChannel(msg bean.RequestMessage, user *bean.User) (openChannelInfo *bean.RequestOpenChannel, err error) {
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "msg.data")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "msg.data")
 	}
 
 	reqData := &bean.SendChannelOpen{}
@@ -60,6 +61,9 @@ func (this *channelManager) AliceOpenChannel(msg bean.RequestMessage, user *bean
 	channelInfo.CreateBy = user.PeerId
 
 	err = user.Db.Save(channelInfo)
+	if err == nil {
+		publishChannelStateEvent(user.PeerId, *channelInfo)
+	}
 	return openChannelInfo, err
 }
 
@@ -69,7 +73,7 @@ func (this *channelManager) BeforeBobOpenChannelAtBobSide(msg string, user *bean
 	log.Println("BeforeBobOpenChannelAtBobSide")
 
 	if tool.CheckIsString(&msg) == false {
-		return errors.New(enum.Tips_common_wrong + "msg")
+		return enum.NewError(enum.ErrorCode_common_wrong, "msg")
 	}
 
 	aliceOpenChannelInfo := bean.RequestOpenChannel{}
@@ -88,6 +92,9 @@ func (this *channelManager) BeforeBobOpenChannelAtBobSide(msg string, user *bean
 	channelInfo.CreateAt = time.Now()
 	channelInfo.CreateBy = user.PeerId
 	err = user.Db.Save(channelInfo)
+	if err == nil {
+		publishChannelStateEvent(user.PeerId, *channelInfo)
+	}
 	return err
 }
 
@@ -100,7 +107,7 @@ func (this *channelManager) BobCheckChannelAddressExist(jsonData string, user *b
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		return false, errors.New(enum.Tips_common_wrong + " temporary_channel_id")
+		return false, enum.NewError(enum.ErrorCode_common_wrong, " temporary_channel_id")
 	}
 
 	channelInfo := &dao.ChannelInfo{}
@@ -111,11 +118,11 @@ func (this *channelManager) BobCheckChannelAddressExist(jsonData string, user *b
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return false, errors.New(enum.Tips_channel_notFoundChannelInCreate + reqData.TemporaryChannelId)
+		return false, enum.NewError(enum.ErrorCode_channel_notFoundChannelInCreate, reqData.TemporaryChannelId)
 	}
 
 	if channelInfo.PeerIdB != user.PeerId {
-		return false, errors.New(enum.Tips_rsmc_notTargetUser)
+		return false, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	channelInfo.PubKeyB = reqData.FundingPubKey
@@ -127,12 +134,13 @@ func (this *channelManager) BobCheckChannelAddressExist(jsonData string, user *b
 	channelAddress := gjson.Get(multiSig, "address").String()
 
 	existAddress := false
-	result := conn2tracker.ListReceivedByAddress(channelAddress)
-	if result != "" {
-		array := gjson.Parse(result).Array()
-		if len(array) > 0 {
-			existAddress = true
-		}
+	result, err := conn2tracker.ListReceivedByAddress(context.Background(), channelAddress)
+	if err != nil {
+		log.Println(err)
+		return false, err
+	}
+	if len(gjson.Parse(result).Array()) > 0 {
+		existAddress = true
 	}
 	count, _ := user.Db.Select(q.Eq("ChannelAddress", channelAddress)).Count(&dao.ChannelInfo{})
 	if count > 0 {
@@ -151,12 +159,12 @@ func (this *channelManager) BobAcceptChannel(msg bean.RequestMessage, user *bean
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "temporary_channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "temporary_channel_id")
 	}
 
 	if reqData.Approval {
 		if tool.CheckIsString(&reqData.FundingPubKey) == false {
-			return nil, errors.New(enum.Tips_common_wrong + "funding_pubkey")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "funding_pubkey")
 		}
 	}
 
@@ -168,15 +176,15 @@ func (this *channelManager) BobAcceptChannel(msg bean.RequestMessage, user *bean
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return nil, errors.New(enum.Tips_channel_notFoundChannelInCreate + reqData.TemporaryChannelId)
+		return nil, enum.NewError(enum.ErrorCode_channel_notFoundChannelInCreate, reqData.TemporaryChannelId)
 	}
 
 	if channelInfo.PeerIdB != user.PeerId {
-		return nil, errors.New(enum.Tips_channel_notThePeerIdB)
+		return nil, enum.NewError(enum.ErrorCode_channel_notThePeerIdB)
 	}
 
 	if channelInfo.PeerIdA != msg.RecipientUserPeerId {
-		return nil, errors.New(enum.Tips_common_wrong + msg.RecipientUserPeerId)
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, msg.RecipientUserPeerId)
 	}
 
 	if reqData.Approval {
@@ -196,6 +204,7 @@ func (this *channelManager) BobAcceptChannel(msg bean.RequestMessage, user *bean
 		log.Println(err)
 		return nil, err
 	}
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 	return channelInfo, err
 }
 
@@ -219,7 +228,7 @@ func (this *channelManager) AfterBobAcceptChannelAtAliceSide(jsonData string, us
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return nil, errors.New(enum.Tips_channel_notFoundChannelInCreate + bobChannelInfo.TemporaryChannelId)
+		return nil, enum.NewError(enum.ErrorCode_channel_notFoundChannelInCreate, bobChannelInfo.TemporaryChannelId)
 	}
 
 	if bobChannelInfo.CurrState == bean.ChannelState_WaitFundAsset {
@@ -239,6 +248,7 @@ func (this *channelManager) AfterBobAcceptChannelAtAliceSide(jsonData string, us
 		log.Println(err)
 		return nil, err
 	}
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 	return channelInfo, err
 }
 
@@ -367,8 +377,8 @@ func (this *channelManager) AllItem(jsonData string, user bean.User) (data *page
 			item.BtcFundingTimes = 3
 			if item.CurrState <= bean.ChannelState_WaitFundAsset {
 				item.BtcFundingTimes = 0
-				result := conn2tracker.ListReceivedByAddress(info.ChannelAddress)
-				if result != "" {
+				result, err := conn2tracker.ListReceivedByAddress(context.Background(), info.ChannelAddress)
+				if err == nil {
 					if len(gjson.Parse(result).Array()) > 0 {
 						btcFundingTimes := len(gjson.Parse(result).Array()[0].Get("txids").Array())
 						if btcFundingTimes > 3 {
@@ -409,7 +419,7 @@ func (this *channelManager) TotalCount(user bean.User) (count int, err error) {
 // GetChannelByTemporaryChanId
 func (this *channelManager) GetChannelByTemporaryChanId(jsonData string, user bean.User) (node *dao.ChannelInfo, err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "temporary_channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "temporary_channel_id")
 	}
 	node = &dao.ChannelInfo{}
 	err = user.Db.Select(
@@ -421,14 +431,14 @@ func (this *channelManager) GetChannelByTemporaryChanId(jsonData string, user be
 // DelChannelByTemporaryChanId
 func (this *channelManager) DelChannelByTemporaryChanId(jsonData string, user bean.User) (node *dao.ChannelInfo, err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "temporary_channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "temporary_channel_id")
 	}
 	node = &dao.ChannelInfo{}
 	err = user.Db.Select(
 		q.Eq("TemporaryChannelId", jsonData)).
 		First(node)
 	if tool.CheckIsString(&node.ChannelId) {
-		return nil, errors.New(enum.Tips_channel_cannotDelChannel)
+		return nil, enum.NewError(enum.ErrorCode_channel_cannotDelChannel)
 	}
 	if err == nil {
 		err = user.Db.DeleteStruct(node)
@@ -438,7 +448,7 @@ func (this *channelManager) DelChannelByTemporaryChanId(jsonData string, user be
 
 func (this *channelManager) GetChannelInfoByChannelId(jsonData string, user bean.User) (info *dao.ChannelInfo, err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "ChannelId")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "ChannelId")
 	}
 
 	info = &dao.ChannelInfo{}
@@ -602,6 +612,10 @@ func (this *channelManager) SignCloseChannel(msg bean.RequestMessage, user bean.
 	}
 	_ = tx.Commit()
 
+	if reqData.Approval {
+		publishChannelStateEvent(user.PeerId, *channelInfo)
+	}
+
 	retData = make(map[string]interface{})
 	retData["channel_id"] = reqData.ChannelId
 	retData["request_close_channel_hash"] = closeChannelStarterData.RequestHex
@@ -618,7 +632,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 	}
 
 	if tool.CheckIsString(&channelId) == false {
-		err = errors.New(enum.Tips_common_wrong + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 		log.Println(err)
 		return nil, err
 	}
@@ -655,7 +669,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 		latestCommitmentTx.CurrState != dao.TxInfoState_Htlc_GetH &&
 		latestCommitmentTx.CurrState != dao.TxInfoState_Htlc_GetR &&
 		latestCommitmentTx.CurrState != dao.TxInfoState_CreateAndSign {
-		return nil, errors.New(enum.Tips_channel_wrongLatestCommitmentTxState)
+		return nil, enum.NewError(enum.ErrorCode_channel_wrongLatestCommitmentTxState)
 	}
 
 	// 当前是处于htlc的状态，且是获取到H
@@ -668,17 +682,17 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 		if latestCommitmentTx.CurrState == dao.TxInfoState_Create {
 			err = tx.One("Id", latestCommitmentTx.LastCommitmentTxId, latestCommitmentTx)
 			if err != nil {
-				return nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+				return nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 			}
 		}
 
 		if latestCommitmentTx.CurrState != dao.TxInfoState_CreateAndSign {
-			return nil, errors.New(enum.Tips_channel_LatestCommitmentTxNotInReadySendState)
+			return nil, enum.NewError(enum.ErrorCode_channel_LatestCommitmentTxNotInReadySendState)
 		}
 
 		//region 广播承诺交易 最近的rsmc的资产分配交易 因为是omni资产，承诺交易被拆分成了两个独立的交易
 		if tool.CheckIsString(&latestCommitmentTx.RSMCTxHex) {
-			commitmentTxid, err := conn2tracker.SendRawTransaction(latestCommitmentTx.RSMCTxHex)
+			commitmentTxid, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.RSMCTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -686,7 +700,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 			log.Println(commitmentTxid)
 		}
 		if tool.CheckIsString(&latestCommitmentTx.ToCounterpartyTxHex) {
-			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(latestCommitmentTx.ToCounterpartyTxHex)
+			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.ToCounterpartyTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -705,7 +719,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 			First(latestRevocableDeliveryTx)
 
 		if latestRevocableDeliveryTx.Id > 0 {
-			_, err = conn2tracker.SendRawTransaction(latestRevocableDeliveryTx.TxHex)
+			_, err = conn2tracker.SendRawTransaction(context.Background(), latestRevocableDeliveryTx.TxHex)
 			if err != nil {
 				log.Println(err)
 				msg := err.Error()
@@ -753,6 +767,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 
 	//同步通道信息到tracker
 	sendChannelStateToTracker(*channelInfo, *latestCommitmentTx)
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	return channelInfo, nil
 
@@ -762,7 +777,7 @@ func (this *channelManager) ForceCloseChannel(msg bean.RequestMessage, user *bea
 func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string, user bean.User) (interface{}, error) {
 
 	if tool.CheckIsString(&jsonData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "inputData")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "inputData")
 	}
 	reqData := &bean.CloseChannelSign{}
 	err := json.Unmarshal([]byte(jsonData), reqData)
@@ -772,7 +787,7 @@ func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string,
 	}
 
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, err
 	}
@@ -840,7 +855,7 @@ func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string,
 	} else {
 		//region 广播承诺交易 最近的rsmc的资产分配交易 因为是omni资产，承诺交易被拆分成了两个独立的交易
 		if tool.CheckIsString(&latestCommitmentTx.RSMCTxHex) {
-			commitmentTxid, err := conn2tracker.SendRawTransaction(latestCommitmentTx.RSMCTxHex)
+			commitmentTxid, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.RSMCTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -848,7 +863,7 @@ func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string,
 			log.Println(commitmentTxid)
 		}
 		if tool.CheckIsString(&latestCommitmentTx.ToCounterpartyTxHex) {
-			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(latestCommitmentTx.ToCounterpartyTxHex)
+			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.ToCounterpartyTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -869,7 +884,7 @@ func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string,
 			return nil, err
 		}
 
-		_, err = conn2tracker.SendRawTransaction(latestRevocableDeliveryTx.TxHex)
+		_, err = conn2tracker.SendRawTransaction(context.Background(), latestRevocableDeliveryTx.TxHex)
 		if err != nil {
 			log.Println(err)
 			msg := err.Error()
@@ -919,6 +934,7 @@ func (this *channelManager) AfterBobSignCloseChannelAtAliceSide(jsonData string,
 
 	//同步通道信息到tracker
 	sendChannelStateToTracker(*channelInfo, *latestCommitmentTx)
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	return channelInfo, nil
 }
@@ -930,7 +946,7 @@ func (this *channelManager) CloseHtlcChannelSigned(tx storm.Node, latestCommitme
 
 	//region 广播主承诺交易 三笔
 	if tool.CheckIsString(&latestCommitmentTx.RSMCTxHex) {
-		commitmentTxid, err := conn2tracker.SendRawTransaction(latestCommitmentTx.RSMCTxHex)
+		commitmentTxid, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.RSMCTxHex)
 		if err != nil {
 			log.Println(err)
 			return err
@@ -952,7 +968,7 @@ func (this *channelManager) CloseHtlcChannelSigned(tx storm.Node, latestCommitme
 	}
 
 	if tool.CheckIsString(&latestCommitmentTx.ToCounterpartyTxHex) {
-		commitmentTxidToBob, err := conn2tracker.SendRawTransaction(latestCommitmentTx.ToCounterpartyTxHex)
+		commitmentTxidToBob, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.ToCounterpartyTxHex)
 		if err != nil {
 			log.Println(err)
 			return err
@@ -962,7 +978,7 @@ func (this *channelManager) CloseHtlcChannelSigned(tx storm.Node, latestCommitme
 
 	// htlc部分
 	if tool.CheckIsString(&latestCommitmentTx.HtlcTxHex) {
-		commitmentTxidToHtlc, err := conn2tracker.SendRawTransaction(latestCommitmentTx.HtlcTxHex)
+		commitmentTxidToHtlc, err := conn2tracker.SendRawTransaction(context.Background(), latestCommitmentTx.HtlcTxHex)
 		if err != nil {
 			log.Println(err)
 			return err
@@ -992,10 +1008,10 @@ func (this *channelManager) CloseHtlcChannelSigned(tx storm.Node, latestCommitme
 				First(htrd)
 			if htrd.Id > 0 && tool.CheckIsString(&ht1a.RSMCTxHex) {
 				//广播alice的ht1a
-				_, err = conn2tracker.SendRawTransaction(ht1a.RSMCTxHex)
+				_, err = conn2tracker.SendRawTransaction(context.Background(), ht1a.RSMCTxHex)
 				if err == nil { //如果已经超时 比如alice的3天超时，bob得到R后的交易的无等待锁定
 					if tool.CheckIsString(&htrd.TxHex) {
-						_, err = conn2tracker.SendRawTransaction(htrd.TxHex)
+						_, err = conn2tracker.SendRawTransaction(context.Background(), htrd.TxHex)
 						if err != nil {
 							log.Println(err)
 							msg := err.Error()
@@ -1028,7 +1044,7 @@ func (this *channelManager) CloseHtlcChannelSigned(tx storm.Node, latestCommitme
 			q.Eq("Owner", closeOpStarter)).
 			First(htdnx)
 		if htdnx.Id > 0 && tool.CheckIsString(&htdnx.TxHex) {
-			_, err = conn2tracker.SendRawTransaction(htdnx.TxHex)
+			_, err = conn2tracker.SendRawTransaction(context.Background(), htdnx.TxHex)
 			if err != nil {
 				log.Println(err)
 				msg := err.Error()
@@ -1123,12 +1139,13 @@ func createChannelAddress(channelInfo *dao.ChannelInfo, reqData *bean.SendSignOp
 	channelAddress := gjson.Get(multiSig, "address").String()
 
 	existAddress := false
-	result := conn2tracker.ListReceivedByAddress(channelAddress)
-	if result != "" {
-		array := gjson.Parse(result).Array()
-		if len(array) > 0 {
-			existAddress = true
-		}
+	result, err := conn2tracker.ListReceivedByAddress(context.Background(), channelAddress)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+	if len(gjson.Parse(result).Array()) > 0 {
+		existAddress = true
 	}
 
 	if existAddress == false {
@@ -1154,7 +1171,7 @@ func createChannelAddress(channelInfo *dao.ChannelInfo, reqData *bean.SendSignOp
 		channelInfo.ChannelAddressScriptPubKey = gjson.Get(multiSig, "scriptPubKey").String()
 		channelInfo.CurrState = bean.ChannelState_WaitFundAsset
 	} else {
-		return errors.New(enum.Tips_channel_changePubkeyForChannel + reqData.FundingPubKey)
+		return enum.NewError(enum.ErrorCode_channel_changePubkeyForChannel, reqData.FundingPubKey)
 	}
 	return nil
 }
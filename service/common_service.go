@@ -1,9 +1,9 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
@@ -28,12 +28,26 @@ func findUserIsOnline(nodePeerId, userPeerId string) error {
 			return nil
 		}
 		if nodePeerId != P2PLocalNodeId {
-			if conn2tracker.GetUserState(nodePeerId, userPeerId) > 0 {
+			state, err := conn2tracker.GetUserState(nodePeerId, userPeerId)
+			if err == nil && state > 0 {
+				return nil
+			}
+			// without the tracker, the user of a connected obd is taken as online, and its obd replies if not
+			if conn2tracker.IsTrackerUnavailable(err) && IsP2PNodeConnected(nodePeerId) {
 				return nil
 			}
 		}
 	}
-	return errors.New(fmt.Sprintf(enum.Tips_user_notExistOrOnline, userPeerId))
+	return enum.NewError(enum.ErrorCode_user_notExistOrOnline, userPeerId)
+}
+
+// GetUserP2pNodeId the p2p node id of the obd which the user is online on, the users of the local obd are found
+// without the tracker
+func GetUserP2pNodeId(userPeerId string) (string, error) {
+	if value, exists := OnlineUserMap[userPeerId]; exists && value != nil {
+		return P2PLocalNodeId, nil
+	}
+	return conn2tracker.GetUserP2pNodeId(userPeerId)
 }
 
 func checkBtcFundFinish(tx storm.Node, channel dao.ChannelInfo, isFundOmni bool) error {
@@ -47,7 +61,7 @@ func checkBtcFundFinish(tx storm.Node, channel dao.ChannelInfo, isFundOmni bool)
 	}
 	//log.Println("listunspent", array)
 	if count < config.BtcNeedFundTimes {
-		return errors.New(enum.Tips_funding_notEnoughBtcFundingTime)
+		return enum.NewError(enum.ErrorCode_funding_notEnoughBtcFundingTime)
 	}
 
 	return nil
@@ -55,7 +69,7 @@ func checkBtcFundFinish(tx storm.Node, channel dao.ChannelInfo, isFundOmni bool)
 
 func getAddressFromPubKey(pubKey string) (address string, err error) {
 	if tool.CheckIsString(&pubKey) == false {
-		return "", errors.New(enum.Tips_common_empty + "pubKey")
+		return "", enum.NewError(enum.ErrorCode_common_empty, "pubKey")
 	}
 	address, err = tool.GetAddressFromPubKey(pubKey)
 	if err != nil {
@@ -171,7 +185,7 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 
 	//vin
 	if jsonFundingTxHexDecode.Get("vin").IsArray() == false {
-		err = errors.New(enum.Tips_funding_noVin)
+		err = enum.NewError(enum.ErrorCode_funding_noVin)
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -183,13 +197,13 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 	}
 	split := strings.Split(asm, " ")
 	if split[0] == "0" {
-		return "", 0, 0, errors.New(enum.Tips_funding_noVin)
+		return "", 0, 0, enum.NewError(enum.ErrorCode_funding_noVin)
 	}
 
 	inTxid := vin1.Get("txid").String()
-	inputTx := conn2tracker.GetTransactionById(inTxid)
+	inputTx, err := conn2tracker.GetTransactionById(context.Background(), inTxid)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_wrongBtcHexVin + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_wrongBtcHexVin, err.Error())
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -198,7 +212,7 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 	flag := false
 	inputHexDecode, err := omnicore.DecodeBtcRawTransaction(jsonInputTxDecode.Get("hex").String())
 	if err != nil {
-		err = errors.New(enum.Tips_funding_wrongBtcHexVin + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_wrongBtcHexVin, err.Error())
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -225,7 +239,7 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 
 	if flag == false {
 		log.Println(inputHexDecode)
-		err = errors.New(enum.Tips_funding_wrongFunderAddressFromBtcHex)
+		err = enum.NewError(enum.ErrorCode_funding_wrongFunderAddressFromBtcHex)
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -233,7 +247,7 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 	//vout
 	flag = false
 	if jsonFundingTxHexDecode.Get("vout").IsArray() == false {
-		err = errors.New(enum.Tips_funding_notFoundVout)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundVout)
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -253,7 +267,7 @@ func checkBtcTxHex(btcFeeTxHexDecode string, channelInfo *dao.ChannelInfo, peerI
 	}
 	if flag == false {
 		log.Println(jsonFundingTxHexDecode)
-		err = errors.New(enum.Tips_funding_wrongChannelAddressFromBtcHex)
+		err = enum.NewError(enum.ErrorCode_funding_wrongChannelAddressFromBtcHex)
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -268,13 +282,13 @@ func checkOmniTxHex(fundingTxHexDecode string, channelInfo *dao.ChannelInfo, use
 
 	sendingAddress := jsonOmniTxHexDecode.Get("sendingaddress").String()
 	if sendingAddress != funderAddress {
-		err = errors.New(fmt.Sprintf(enum.Tips_funding_wrongFunderAddressFromAssetHex, sendingAddress))
+		err = enum.NewError(enum.ErrorCode_funding_wrongFunderAddressFromAssetHex, sendingAddress)
 		log.Println(err)
 		return "", 0, 0, err
 	}
 	referenceAddress := jsonOmniTxHexDecode.Get("referenceaddress").String()
 	if referenceAddress != channelInfo.ChannelAddress {
-		err = errors.New(fmt.Sprintf(enum.Tips_funding_wrongChannelAddressFromAssetHex, referenceAddress, channelInfo.ChannelAddress))
+		err = enum.NewError(enum.ErrorCode_funding_wrongChannelAddressFromAssetHex, referenceAddress, channelInfo.ChannelAddress)
 		log.Println(err)
 		return "", 0, 0, err
 	}
@@ -317,7 +331,7 @@ func getInputsForNextTxByParseTxHashVout(hex string, toAddress, scriptPubKey, re
 			return inputs, nil
 		}
 	}
-	return nil, errors.New(enum.Tips_common_failToParseInputsFromUnsendTx)
+	return nil, enum.NewError(enum.ErrorCode_common_failToParseInputsFromUnsendTx)
 }
 
 func getLatestCommitmentTxUseDbTx(tx storm.Node, channelId string, owner string) (commitmentTxInfo *dao.CommitmentTransaction, err error) {
@@ -388,7 +402,7 @@ func saveRdTx(tx storm.Node, channelInfo *dao.ChannelInfo, signedRsmcHex string,
 
 	aliceRdTxid := checkHexOutputAddressFromOmniDecode(signedRdHex, outputAddress)
 	if aliceRdTxid == "" {
-		return errors.New(enum.Tips_common_wrongAddressOfRD)
+		return enum.NewError(enum.ErrorCode_common_wrongAddressOfRD)
 	}
 	rdTransaction, err := createRDTx(user.PeerId, channelInfo, latestCommitmentTxInfo, outputAddress, user)
 	if err != nil {
@@ -462,7 +476,7 @@ func createCommitmentTxHex(dbTx storm.Node, isSender bool, reqData *bean.Request
 	// create Cna tx
 	fundingTransaction := getFundingTransactionByChannelId(dbTx, channelInfo.ChannelId, currUser.PeerId)
 	if fundingTransaction == nil {
-		err = errors.New(enum.Tips_funding_notFoundFundAssetTx)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundFundAssetTx)
 		return nil, rawTx, err
 	}
 
@@ -560,7 +574,7 @@ func createCommitmentTxHex(dbTx storm.Node, isSender bool, reqData *bean.Request
 			fundingTransaction.FunderAddress,
 			fundingTransaction.PropertyId,
 			commitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 			&channelInfo.ChannelAddressRedeemScript)
 		if err != nil {
 			log.Println(err)
@@ -604,16 +618,26 @@ func createCommitmentTxHex(dbTx storm.Node, isSender bool, reqData *bean.Request
 }
 
 func GetBtcMinerFundMiniAmount() float64 {
-	out, _ := decimal.NewFromFloat(omnicore.GetMinerFee(10)).Add(decimal.NewFromFloat(2 * tool.GetOmniDustBtc())).Mul(decimal.NewFromFloat(4.0)).Round(8).Float64()
+	out, _ := decimal.NewFromFloat(omnicore.GetMinerFeeByPurpose(omnicore.FeePurpose_Funding, 1)).Add(decimal.NewFromFloat(2 * tool.GetOmniDustBtc())).Mul(decimal.NewFromFloat(4.0)).Round(8).Float64()
 	return out
 }
 
-func getBtcMinerAmount(total float64) float64 {
-	return tool.GetBtcMinerAmount(total)
+// the miner fee of the channel transactions, they spend the reference and the change outputs of the previous ones.
+// total is the btc funded to the channel, which pays the miner fees
+func getMinerFee(purpose omnicore.FeePurpose, total float64) float64 {
+	return omnicore.GetMinerFeeOfBtc(purpose, 2, total)
 }
 
 func checkChannelOmniAssetAmount(channelInfo dao.ChannelInfo) (bool, error) {
-	balance := conn2tracker.GetOmniBalance(channelInfo.ChannelAddress, int(channelInfo.PropertyId))
+	balance, err := conn2tracker.GetOmniBalance(context.Background(), channelInfo.ChannelAddress, int(channelInfo.PropertyId))
+	if conn2tracker.IsTrackerUnavailable(err) {
+		// the payments of the channel go on without the tracker, the broadcast commitment tx is punished by the BR
+		log.Println("skip the check of the channel balance", channelInfo.ChannelId, err)
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
 	if balance == channelInfo.Amount {
 		return true, nil
 	}
@@ -698,7 +722,7 @@ func verifyCompleteSignHex(inputs interface{}, signedHex string) error {
 		items = append(items, inputItem)
 	}
 	if omnicore.VerifySignatureHex(items, signedHex) != nil {
-		return errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "signed_hex"))
+		return enum.NewError(enum.ErrorCode_common_failToSign, "signed_hex")
 	}
 	return nil
 }
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/dao"
 	trackerBean "github.com/omnilaboratory/obd/tracker/bean"
 	"github.com/tidwall/gjson"
+	"log"
 	"strings"
 )
 
@@ -23,6 +25,7 @@ func sendChannelStateToTracker(channelInfo dao.ChannelInfo, commitmentTx dao.Com
 	infoRequest.CurrState = channelInfo.CurrState
 	infoRequest.PeerIdA = channelInfo.PeerIdA
 	infoRequest.PeerIdB = channelInfo.PeerIdB
+	infoRequest.FeeRate = config.HtlcFeeRate
 
 	infoRequest.IsAlice = false
 	if commitmentTx.Id > 0 {
@@ -50,7 +53,7 @@ func noticeTrackerUserLogout(user dao.User) {
 	sendMsgToTracker(enum.MsgType_Tracker_UserLogout_305, loginRequest)
 }
 
-func sendMsgToTracker(msgType enum.MsgType, data interface{}) {
+func sendMsgToTracker(msgType enum.MsgType, data interface{}) bool {
 
 	message := trackerBean.RequestMessage{Type: msgType}
 
@@ -66,7 +69,10 @@ func sendMsgToTracker(msgType enum.MsgType, data interface{}) {
 	message.Data = result
 	//log.Println(message.Data)
 	bytes, _ := json.Marshal(message)
-	if TrackerChan != nil {
-		TrackerChan <- bytes
+	if TrackerChan == nil {
+		log.Println("obd is not connected to the trackers, drop the message", msgType)
+		return false
 	}
+	TrackerChan <- bytes
+	return true
 }
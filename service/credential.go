@@ -0,0 +1,382 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/tool"
+	"github.com/tidwall/gjson"
+)
+
+// the caveats of api credentials, in the form of "name = value"
+const (
+	Caveat_Scope     = "scope"      // scope = read,invoice
+	Caveat_Expire    = "expire"     // expire = unix seconds
+	Caveat_Ip        = "ip"         // ip = 127.0.0.1,192.168.1.0/24
+	Caveat_MaxAmount = "max_amount" // max_amount = 0.5
+)
+
+// the fields of the requests which are checked by the max_amount caveat
+var credentialAmountFields = map[string]bool{
+	"amount":          true,
+	"amount_to_payee": true,
+	"asset_amount":    true,
+	"btc_amount":      true,
+	"value":           true,
+}
+
+// the fields of the requests which are invoices, the amount in them is checked by the max_amount caveat
+var credentialInvoiceFields = map[string]bool{
+	"invoice":         true,
+	"payment_request": true,
+}
+
+// Credential macaroon style api credential: the signature is a hmac chain of the id and the caveats,
+// so that the holder can add caveats without the root key, but never remove one.
+type Credential struct {
+	Id        string   `json:"id"`
+	Caveats   []string `json:"caveats"`
+	Signature string   `json:"signature"`
+}
+
+type credentialManager struct{}
+
+var CredentialService credentialManager
+
+// Bake create a new credential with the scopes and the other caveats, it is saved in credentials.db so that it can be revoked.
+func (service *credentialManager) Bake(scopes []string, caveats []string, note string) (token string, err error) {
+	if len(scopes) == 0 {
+		return "", enum.NewError(enum.ErrorCode_common_empty, "scope")
+	}
+	for _, scope := range scopes {
+		if enum.CheckScopeExist(scope) == false {
+			return "", enum.NewError(enum.ErrorCode_common_wrong, "scope "+scope)
+		}
+	}
+	caveats = append([]string{Caveat_Scope + " = " + strings.Join(scopes, ",")}, caveats...)
+	for _, caveat := range caveats {
+		if _, _, err = parseCaveat(caveat); err != nil {
+			return "", err
+		}
+	}
+
+	rootKey, err := getCredentialRootKey()
+	if err != nil {
+		return "", err
+	}
+	idBytes := make([]byte, 16)
+	if _, err = rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	credential := &Credential{Id: hex.EncodeToString(idBytes)}
+	signature := credentialHmac(rootKey, []byte(credential.Id))
+	for _, caveat := range caveats {
+		signature = credentialHmac(signature, []byte(caveat))
+	}
+	credential.Caveats = caveats
+	credential.Signature = hex.EncodeToString(signature)
+
+	db, err := dao.DBService.GetCredentialDB()
+	if err != nil {
+		return "", err
+	}
+	err = db.Save(&dao.ApiCredential{Id: credential.Id, Caveats: caveats, Note: note, CreateAt: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	return credential.encode(), nil
+}
+
+// Attenuate add caveats to the credential, the root key is not required
+func (service *credentialManager) Attenuate(token string, caveats ...string) (string, error) {
+	credential, err := decodeCredential(token)
+	if err != nil {
+		return "", err
+	}
+	signature, err := hex.DecodeString(credential.Signature)
+	if err != nil {
+		return "", enum.NewError(enum.ErrorCode_credential_wrong)
+	}
+	for _, caveat := range caveats {
+		if _, _, err = parseCaveat(caveat); err != nil {
+			return "", err
+		}
+		signature = credentialHmac(signature, []byte(caveat))
+		credential.Caveats = append(credential.Caveats, caveat)
+	}
+	credential.Signature = hex.EncodeToString(signature)
+	return credential.encode(), nil
+}
+
+// Verify check the signature of the credential, and whether it is revoked
+func (service *credentialManager) Verify(token string) (*Credential, error) {
+	credential, err := decodeCredential(token)
+	if err != nil {
+		return nil, err
+	}
+	rootKey, err := getCredentialRootKey()
+	if err != nil {
+		return nil, err
+	}
+	signature := credentialHmac(rootKey, []byte(credential.Id))
+	for _, caveat := range credential.Caveats {
+		signature = credentialHmac(signature, []byte(caveat))
+	}
+	expected, err := hex.DecodeString(credential.Signature)
+	if err != nil || hmac.Equal(signature, expected) == false {
+		return nil, enum.NewError(enum.ErrorCode_credential_wrong)
+	}
+
+	db, err := dao.DBService.GetCredentialDB()
+	if err != nil {
+		return nil, err
+	}
+	info := &dao.ApiCredential{}
+	err = db.One("Id", credential.Id, info)
+	if err != nil || info.Revoked {
+		return nil, enum.NewError(enum.ErrorCode_credential_revoked)
+	}
+	return credential, nil
+}
+
+func (service *credentialManager) Revoke(id string) error {
+	db, err := dao.DBService.GetCredentialDB()
+	if err != nil {
+		return err
+	}
+	info := &dao.ApiCredential{}
+	err = db.One("Id", id, info)
+	if err != nil {
+		return enum.NewError(enum.ErrorCode_common_notFound)
+	}
+	info.Revoked = true
+	info.RevokeAt = time.Now()
+	return db.Update(info)
+}
+
+func (service *credentialManager) List() (credentials []dao.ApiCredential, err error) {
+	db, err := dao.DBService.GetCredentialDB()
+	if err != nil {
+		return nil, err
+	}
+	err = db.All(&credentials)
+	return credentials, err
+}
+
+// Check whether the request is allowed by all caveats of the credential.
+// remoteIp is the ip of the client, requestData is the json data of the request.
+func (credential *Credential) Check(scope string, remoteIp string, requestData string) error {
+	err := credential.CheckAccess(scope, remoteIp)
+	if err != nil {
+		return err
+	}
+	return credential.CheckAmount(scope, requestData)
+}
+
+// CheckAccess whether the scope is allowed from the ip by the credential, the amount of the request is not checked.
+func (credential *Credential) CheckAccess(scope string, remoteIp string) error {
+	for _, caveat := range credential.Caveats {
+		name, value, err := parseCaveat(caveat)
+		if err != nil {
+			return err
+		}
+		switch name {
+		case Caveat_Scope:
+			if scope == enum.Scope_Any {
+				continue
+			}
+			scopes := strings.Split(value, ",")
+			allowed := false
+			for _, item := range scopes {
+				item = strings.TrimSpace(item)
+				if item == scope || item == enum.Scope_Admin {
+					allowed = true
+					break
+				}
+			}
+			if allowed == false {
+				return enum.NewError(enum.ErrorCode_credential_noScope, scope)
+			}
+		case Caveat_Expire:
+			expire, _ := strconv.ParseInt(value, 10, 64)
+			if time.Now().Unix() > expire {
+				return enum.NewError(enum.ErrorCode_credential_expired)
+			}
+		case Caveat_Ip:
+			if checkCredentialIp(value, remoteIp) == false {
+				return enum.NewError(enum.ErrorCode_credential_wrongIp, remoteIp)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckAmount whether the amount in the request is allowed by the max_amount caveats.
+// The payments, whose amount can not be measured, are denied.
+func (credential *Credential) CheckAmount(scope string, requestData string) error {
+	for _, caveat := range credential.Caveats {
+		name, value, err := parseCaveat(caveat)
+		if err != nil {
+			return err
+		}
+		if name != Caveat_MaxAmount {
+			continue
+		}
+		amount, err := getRequestAmount(requestData)
+		if err != nil {
+			if scope == enum.Scope_Payment {
+				return enum.NewError(enum.ErrorCode_credential_noAmount)
+			}
+			continue
+		}
+		maxAmount, _ := strconv.ParseFloat(value, 64)
+		if amount > maxAmount {
+			return enum.NewError(enum.ErrorCode_credential_maxAmount, value)
+		}
+	}
+	return nil
+}
+
+func (credential *Credential) encode() string {
+	bytes, _ := json.Marshal(credential)
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func decodeCredential(token string) (*Credential, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(token))
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_credential_wrong)
+	}
+	credential := &Credential{}
+	err = json.Unmarshal(bytes, credential)
+	if err != nil || tool.CheckIsString(&credential.Id) == false {
+		return nil, enum.NewError(enum.ErrorCode_credential_wrong)
+	}
+	return credential, nil
+}
+
+func parseCaveat(caveat string) (name, value string, err error) {
+	items := strings.SplitN(caveat, "=", 2)
+	if len(items) != 2 {
+		return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+	}
+	name = strings.TrimSpace(items[0])
+	value = strings.TrimSpace(items[1])
+	switch name {
+	case Caveat_Scope:
+		for _, item := range strings.Split(value, ",") {
+			if enum.CheckScopeExist(strings.TrimSpace(item)) == false {
+				return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+			}
+		}
+	case Caveat_Expire:
+		if _, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+		}
+	case Caveat_Ip:
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if net.ParseIP(item) == nil {
+				if _, _, err = net.ParseCIDR(item); err != nil {
+					return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+				}
+			}
+		}
+	case Caveat_MaxAmount:
+		if _, err = strconv.ParseFloat(value, 64); err != nil {
+			return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+		}
+	default:
+		// unknown caveats are never satisfied
+		return "", "", enum.NewError(enum.ErrorCode_common_wrong, "caveat "+caveat)
+	}
+	return name, value, nil
+}
+
+func checkCredentialIp(allowed string, remoteIp string) bool {
+	ip := net.ParseIP(remoteIp)
+	if ip == nil {
+		return false
+	}
+	for _, item := range strings.Split(allowed, ",") {
+		item = strings.TrimSpace(item)
+		if allowedIp := net.ParseIP(item); allowedIp != nil {
+			if allowedIp.Equal(ip) {
+				return true
+			}
+		} else if _, ipNet, err := net.ParseCIDR(item); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// the max amount in the request, including the nested objects and the invoices.
+// The request without data has no amount, the request which is not json can not be measured.
+func getRequestAmount(requestData string) (amount float64, err error) {
+	if len(strings.TrimSpace(requestData)) == 0 {
+		return 0, nil
+	}
+	if gjson.Valid(requestData) == false {
+		return 0, errors.New("wrong request data")
+	}
+	return getJsonAmount(gjson.Parse(requestData))
+}
+
+func getJsonAmount(data gjson.Result) (amount float64, err error) {
+	data.ForEach(func(key, value gjson.Result) bool {
+		itemAmount := 0.0
+		if value.IsObject() || value.IsArray() {
+			itemAmount, err = getJsonAmount(value)
+		} else if credentialAmountFields[key.String()] {
+			itemAmount = value.Float()
+		} else if credentialInvoiceFields[key.String()] && len(value.String()) > 0 {
+			var invoice bean.HtlcRequestInvoice
+			invoice, err = tool.DecodeInvoiceObjFromCodes(value.String())
+			itemAmount = invoice.Amount
+		}
+		amount = math.Max(amount, itemAmount)
+		return err == nil
+	})
+	return amount, err
+}
+
+func credentialHmac(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func getCredentialRootKey() ([]byte, error) {
+	db, err := dao.DBService.GetCredentialDB()
+	if err != nil {
+		return nil, err
+	}
+	rootKey := &dao.CredentialRootKey{}
+	_ = db.Select().First(rootKey)
+	if rootKey.Id == 0 {
+		keyBytes := make([]byte, 32)
+		if _, err = rand.Read(keyBytes); err != nil {
+			return nil, err
+		}
+		rootKey.RootKey = hex.EncodeToString(keyBytes)
+		rootKey.CreateAt = time.Now()
+		if err = db.Save(rootKey); err != nil {
+			return nil, err
+		}
+	}
+	return hex.DecodeString(rootKey.RootKey)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+func TestCredential(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_credential")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.DataDirectory = dir
+	defer dao.DBService.CloseGlobalDB()
+
+	expire := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	token, err := CredentialService.Bake([]string{enum.Scope_Read, enum.Scope_Invoice}, []string{"expire = " + expire}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err := CredentialService.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = credential.Check(enum.Scope_Invoice, "127.0.0.1", `{"amount":1}`); err != nil {
+		t.Fatal(err)
+	}
+	if err = credential.Check(enum.Scope_Payment, "127.0.0.1", ""); err == nil {
+		t.Fatal("payment is allowed by an invoice credential")
+	}
+
+	// the holder adds caveats without the root key
+	attenuated, err := CredentialService.Attenuate(token, "ip = 127.0.0.0/8", "max_amount = 0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential, err = CredentialService.Verify(attenuated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = credential.Check(enum.Scope_Invoice, "10.0.0.1", `{"amount":0.1}`); err == nil {
+		t.Fatal("request from another ip is allowed")
+	}
+	if err = credential.Check(enum.Scope_Invoice, "127.0.0.1", `{"amount":1}`); err == nil {
+		t.Fatal("request exceeds the max amount is allowed")
+	}
+	if err = credential.Check(enum.Scope_Invoice, "127.0.0.1", `{"amount":0.1}`); err != nil {
+		t.Fatal(err)
+	}
+
+	// the amount of the invoice to pay is 0.8
+	invoice := "obcrt80000000s1pqzyfnqynodeuqyuserhqzhhxq8z35znuqtqp09q4"
+	if err = credential.CheckAmount(enum.Scope_Payment, `{"invoice":"`+invoice+`"}`); enum.ErrorCodeOf(err) != enum.ErrorCode_credential_maxAmount {
+		t.Fatal("got the error", err, "want the invoice exceeds the max amount")
+	}
+	if err = credential.CheckAmount(enum.Scope_Payment, `{"payment_request":"`+invoice+`"}`); enum.ErrorCodeOf(err) != enum.ErrorCode_credential_maxAmount {
+		t.Fatal("got the error", err, "want the payment request exceeds the max amount")
+	}
+	for _, requestData := range []string{`{"invoice":"obcrt-wrong"}`, "not json"} {
+		if err = credential.CheckAmount(enum.Scope_Payment, requestData); enum.ErrorCodeOf(err) != enum.ErrorCode_credential_noAmount {
+			t.Fatal("got the error", err, "want the payment", requestData, "denied")
+		}
+	}
+	if err = credential.CheckAmount(enum.Scope_Payment, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// removing a caveat breaks the signature
+	credential.Caveats = credential.Caveats[:len(credential.Caveats)-1]
+	if _, err = CredentialService.Verify(credential.encode()); err == nil {
+		t.Fatal("credential without the last caveat is verified")
+	}
+
+	err = CredentialService.Revoke(credential.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = CredentialService.Verify(token); err == nil {
+		t.Fatal("revoked credential is verified")
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+// the max count of the events waiting for the listeners
+const eventQueueSize = 1024
+
+type eventManager struct {
+	mu        sync.RWMutex
+	listeners []func(event bean.Event)
+	queue     chan bean.Event
+	startOnce sync.Once
+}
+
+// EventService push the events of the channels, payments and invoices to the listeners, such as the websocket clients
+var EventService = eventManager{queue: make(chan bean.Event, eventQueueSize)}
+
+// AddListener the listener is called in one goroutine, in the order of the events
+func (service *eventManager) AddListener(listener func(event bean.Event)) {
+	service.mu.Lock()
+	service.listeners = append(service.listeners, listener)
+	service.mu.Unlock()
+	service.startOnce.Do(func() {
+		go service.dispatch()
+	})
+}
+
+func (service *eventManager) dispatch() {
+	for event := range service.queue {
+		service.mu.RLock()
+		listeners := service.listeners
+		service.mu.RUnlock()
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
+
+// Publish the event is dropped if nobody listens, or the queue is full
+func (service *eventManager) Publish(userPeerId string, eventType enum.EventType, channelId string, data interface{}) {
+	service.mu.RLock()
+	hasListener := len(service.listeners) > 0
+	service.mu.RUnlock()
+	if hasListener == false {
+		return
+	}
+	event := bean.Event{Type: eventType, UserPeerId: userPeerId, ChannelId: channelId, Data: data, CreateAt: time.Now()}
+	select {
+	case service.queue <- event:
+	default:
+		log.Println("the event queue is full, drop the event", eventType, "of", userPeerId)
+	}
+}
+
+func publishChannelStateEvent(userPeerId string, channelInfo dao.ChannelInfo) {
+	EventService.Publish(userPeerId, enum.EventType_ChannelState, channelInfo.ChannelId, map[string]interface{}{
+		"temporary_channel_id": channelInfo.TemporaryChannelId,
+		"channel_id":           channelInfo.ChannelId,
+		"property_id":          channelInfo.PropertyId,
+		"curr_state":           channelInfo.CurrState,
+	})
+}
+
+func publishCommitmentTxSignedEvent(userPeerId string, commitmentTx dao.CommitmentTransaction) {
+	EventService.Publish(userPeerId, enum.EventType_CommitmentTxSigned, commitmentTx.ChannelId, map[string]interface{}{
+		"commitment_tx_id":       commitmentTx.Id,
+		"curr_hash":              commitmentTx.CurrHash,
+		"tx_type":                commitmentTx.TxType,
+		"amount_to_rsmc":         commitmentTx.AmountToRSMC,
+		"amount_to_counterparty": commitmentTx.AmountToCounterparty,
+		"amount_to_htlc":         commitmentTx.AmountToHtlc,
+		"peer_id_a":              commitmentTx.PeerIdA,
+		"peer_id_b":              commitmentTx.PeerIdB,
+		"curr_state":             commitmentTx.CurrState,
+		"h":                      commitmentTx.HtlcH,
+		"r":                      commitmentTx.HtlcR,
+	})
+}
+
+// htlcTx is the commitment transaction of the htlc
+func publishHtlcEvent(userPeerId string, eventType enum.EventType, htlcTx dao.CommitmentTransaction, reason string) {
+	data := map[string]interface{}{
+		"h":           htlcTx.HtlcH,
+		"amount":      htlcTx.HtlcAmountToPayee,
+		"htlc_sender": htlcTx.HtlcSender,
+	}
+	if eventType == enum.EventType_HtlcSettled {
+		data["r"] = htlcTx.HtlcR
+	}
+	if len(reason) > 0 {
+		data["reason"] = reason
+	}
+	EventService.Publish(userPeerId, eventType, htlcTx.ChannelId, data)
+}
+
+// the invoice of the user is paid, when the htlc of its h is settled
+func publishInvoicePaidEvent(user bean.User, htlcTx dao.CommitmentTransaction) {
+	if user.Db == nil || len(htlcTx.HtlcH) == 0 || htlcTx.HtlcSender == user.PeerId {
+		return
+	}
+	if invoice := getInvoiceByH(user.Db, htlcTx.HtlcH); invoice != nil {
+		EventService.Publish(user.PeerId, enum.EventType_InvoicePaid, htlcTx.ChannelId, map[string]interface{}{
+			"invoice": invoice.Invoice,
+			"h":       htlcTx.HtlcH,
+			"r":       htlcTx.HtlcR,
+			"amount":  htlcTx.HtlcAmountToPayee,
+		})
+	}
+}
+
+func publishBreachRemedySentEvent(userPeerId string, breachRemedy dao.BreachRemedyTransaction, txid string) {
+	EventService.Publish(userPeerId, enum.EventType_BreachRemedySent, breachRemedy.ChannelId, map[string]interface{}{
+		"commitment_tx_id": breachRemedy.CommitmentTxId,
+		"type":             breachRemedy.Type,
+		"amount":           breachRemedy.Amount,
+		"txid":             txid,
+	})
+}
+
+// the htlc is closed, and its amount is in the new rsmc commitment transaction
+func publishHtlcSettledEvents(user bean.User, channelInfo dao.ChannelInfo, commitmentTx, htlcTx dao.CommitmentTransaction) {
+	publishCommitmentTxSignedEvent(user.PeerId, commitmentTx)
+	if len(htlcTx.HtlcH) > 0 && len(htlcTx.HtlcR) == 0 {
+		// the htlc is closed without r, it failed
+		publishHtlcEvent(user.PeerId, enum.EventType_HtlcFailed, htlcTx, htlcTx.HtlcFailReason)
+		PaymentService.onHtlcClosed(htlcTx, user)
+	} else if len(htlcTx.HtlcH) > 0 {
+		publishHtlcEvent(user.PeerId, enum.EventType_HtlcSettled, htlcTx, "")
+		publishInvoicePaidEvent(user, htlcTx)
+	}
+	publishChannelStateEvent(user.PeerId, channelInfo)
+}
+
+// the htlc timeout transaction is broadcast, the payer gets back the amount of the htlc
+func publishHtlcTimeoutEvent(htnxId int) {
+	htnx := dao.HTLCTimeoutTxForAAndExecutionForB{}
+	if obdGlobalDB == nil || obdGlobalDB.One("Id", htnxId, &htnx) != nil {
+		return
+	}
+	EventService.Publish(htnx.Owner, enum.EventType_HtlcFailed, htnx.ChannelId, map[string]interface{}{
+		"commitment_tx_id": htnx.CommitmentTxId,
+		"amount":           htnx.RSMCOutAmount,
+		"reason":           "timeout",
+	})
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+func TestEventService(t *testing.T) {
+	events := make(chan bean.Event, 10)
+	EventService.AddListener(func(event bean.Event) {
+		events <- event
+	})
+
+	channelInfo := dao.ChannelInfo{ChannelId: "channel1"}
+	channelInfo.CurrState = bean.ChannelState_CanUse
+	publishChannelStateEvent("user1", channelInfo)
+	publishHtlcEvent("user1", enum.EventType_HtlcSettled, dao.CommitmentTransaction{ChannelId: "channel1", HtlcH: "h", HtlcR: "r"}, "")
+
+	for _, eventType := range []enum.EventType{enum.EventType_ChannelState, enum.EventType_HtlcSettled} {
+		select {
+		case event := <-events:
+			if event.Type != eventType || event.UserPeerId != "user1" || event.ChannelId != "channel1" {
+				t.Fatal("wrong event", event)
+			}
+			if eventType == enum.EventType_HtlcSettled && event.Data.(map[string]interface{})["r"] != "r" {
+				t.Fatal("no r in the settled event", event)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no event", eventType)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/asdine/storm/q"
@@ -34,28 +35,27 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "temporary_channel_id ")
+		err = enum.NewError(enum.ErrorCode_common_empty, "temporary_channel_id ")
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if tool.CheckIsString(&reqData.FundingTxHex) == false {
-		err = errors.New(enum.Tips_common_empty + " funding_tx_hex ")
+		err = enum.NewError(enum.ErrorCode_common_empty, " funding_tx_hex ")
 		log.Println(err)
 		return nil, false, err
 	}
-	testResult := conn2tracker.TestMemPoolAccept(reqData.FundingTxHex)
-	if testResult == "" {
-		err = errors.New(enum.Tips_common_empty + " funding_tx_hex ")
+	testResult, err := conn2tracker.TestMemPoolAccept(context.Background(), reqData.FundingTxHex)
+	if err != nil {
 		log.Println(err)
 		return nil, false, err
 	}
-	if gjson.Parse(testResult).Array()[0].Get("allowed").Bool() == false {
-		return nil, false, errors.New(gjson.Parse(testResult).Array()[0].Get("reject-reason").String())
+	if testResult.Allowed == false {
+		return nil, false, errors.New(testResult.RejectReason)
 	}
 
 	if _, err := getAddressFromPubKey(reqData.TempAddressPubKey); err != nil {
-		err = errors.New(enum.Tips_common_wrong + "temp_address_pub_key ")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "temp_address_pub_key ")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -76,13 +76,13 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 		OrderBy("CreateAt").Reverse().
 		First(channelInfo)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_notFoundChannelByTempId + reqData.TemporaryChannelId)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, reqData.TemporaryChannelId)
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_WaitFundAsset {
-		err = errors.New(enum.Tips_funding_notFundAssetState)
+		err = enum.NewError(enum.ErrorCode_funding_notFundAssetState)
 		log.Println(err)
 		return nil, false, err
 	}
@@ -94,9 +94,9 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 	}
 
 	// if alice launch funding
-	fundingTxHexDecode, err := conn2tracker.OmniDecodeTransaction(reqData.FundingTxHex)
+	fundingTxHexDecode, err := conn2tracker.OmniDecodeTransaction(context.Background(), reqData.FundingTxHex)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_failDecodeRawTransaction + " : " + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_failDecodeRawTransaction, " : "+err.Error())
 		log.Println(err)
 		return nil, false, err
 	}
@@ -137,7 +137,7 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 
 	fundingAssetTxHexDecode, err := omnicore.DecodeBtcRawTransaction(reqData.FundingTxHex)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_failDecodeRawTransaction + " funding_tx_hex " + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_failDecodeRawTransaction, " funding_tx_hex "+err.Error())
 		log.Println(err)
 		return nil, false, err
 	}
@@ -169,9 +169,13 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 	}
 
 	if needCreateC1a {
-		flag := conn2tracker.GetChannelState(fundingTransaction.ChannelId)
+		flag, err := conn2tracker.GetChannelState(fundingTransaction.ChannelId)
+		if err != nil {
+			log.Println(err)
+			return nil, false, err
+		}
 		if flag != 0 && flag != int(bean.ChannelState_WaitFundAsset) {
-			err = errors.New(enum.Tips_funding_needChangeFundTx)
+			err = enum.NewError(enum.ErrorCode_funding_needChangeFundTx)
 			log.Println(err)
 			return nil, false, err
 		}
@@ -211,9 +215,10 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 
 	var commitmentTxInfo *dao.CommitmentTransaction
 
-	unspent := conn2tracker.ListUnspent(channelInfo.ChannelAddress)
-	if unspent == "" {
-		return nil, false, errors.New("empty listunspent")
+	unspent, err := conn2tracker.ListUnspent(context.Background(), channelInfo.ChannelAddress)
+	if err != nil {
+		log.Println(err)
+		return nil, false, err
 	}
 
 	var c1aTxData map[string]interface{}
@@ -278,10 +283,10 @@ func (service *fundingTransactionManager) AssetFundingCreated(msg bean.RequestMe
 			OrderBy("CreateAt").Reverse().
 			First(commitmentTxInfo)
 		if err != nil {
-			return nil, false, errors.New(enum.Tips_common_notFound + ": CommitmentTransaction")
+			return nil, false, enum.NewError(enum.ErrorCode_common_notFound, ": CommitmentTransaction")
 		}
 		if commitmentTxInfo.LastHash != "" {
-			return nil, false, errors.New(enum.Tips_common_wrong + "CommitmentTransaction")
+			return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "CommitmentTransaction")
 		}
 
 		// 如果没有完成alice对C1a的签名
@@ -360,7 +365,7 @@ func (service *fundingTransactionManager) OnAliceSignC1a(msg bean.RequestMessage
 	_ = json.Unmarshal([]byte(msg.Data), &signedC1a)
 	hex := signedC1a.SignedC1aHex
 	if tool.CheckIsString(&hex) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "hex")
 	}
 
 	_, err = omnicore.CheckMultiSign(hex, 1)
@@ -394,7 +399,7 @@ func (service *fundingTransactionManager) OnAliceSignC1a(msg bean.RequestMessage
 		OrderBy("CreateAt").Reverse().
 		First(channelInfo)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_notFoundChannelByTempId + fundingAssetOfP2p.TemporaryChannelId)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, fundingAssetOfP2p.TemporaryChannelId)
 		log.Println(err)
 		return nil, err
 	}
@@ -406,13 +411,13 @@ func (service *fundingTransactionManager) OnAliceSignC1a(msg bean.RequestMessage
 		OrderBy("CreateAt").Reverse().
 		First(commitmentTxInfo)
 	if err != nil {
-		return nil, errors.New(enum.Tips_common_notFound + ": CommitmentTransaction")
+		return nil, enum.NewError(enum.ErrorCode_common_notFound, ": CommitmentTransaction")
 	}
 
 	fundingAssetOfP2p.ChannelId = channelInfo.ChannelId
 
 	// 检测 输出地址，数量是否一致
-	omniDecode, err := conn2tracker.OmniDecodeTransaction(hex)
+	omniDecode, err := conn2tracker.OmniDecodeTransaction(context.Background(), hex)
 	if err != nil {
 		return nil, err
 	}
@@ -514,7 +519,7 @@ func (service *fundingTransactionManager) BeforeSignAssetFundingCreateAtBobSide(
 		First(fundingTransaction)
 	if fundingTransaction.Id == 0 {
 		fundingTransaction.ChannelId = channelId
-		fundingTxHexDecode, err := conn2tracker.OmniDecodeTransaction(fundingTxHex)
+		fundingTxHexDecode, err := conn2tracker.OmniDecodeTransaction(context.Background(), fundingTxHex)
 		if err != nil {
 			err = errors.New("TxHex  parse fail " + err.Error())
 			log.Println(err)
@@ -622,11 +627,11 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		return nil, errors.New(enum.Tips_common_empty + "temporary_channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "temporary_channel_id")
 	}
 
 	if tool.CheckIsString(&reqData.SignedAliceRsmcHex) == false {
-		return nil, errors.New(enum.Tips_common_empty + "signed_alice_rsmc_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "signed_alice_rsmc_hex")
 	}
 
 	_, err = omnicore.CheckMultiSign(reqData.SignedAliceRsmcHex, 2)
@@ -655,7 +660,7 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 	}
 
 	if channelInfo == nil {
-		err = errors.New(enum.Tips_funding_notFoundChannelByTempId + reqData.TemporaryChannelId)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, reqData.TemporaryChannelId)
 		log.Println(err)
 		return nil, err
 	}
@@ -696,7 +701,7 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 	// 二次签名的验证
 	signedRsmcHex := reqData.SignedAliceRsmcHex
 
-	beforeSignAliceRsmcDecode, err := conn2tracker.OmniDecodeTransaction(fundingTransaction.FunderRsmcHex)
+	beforeSignAliceRsmcDecode, err := conn2tracker.OmniDecodeTransaction(context.Background(), fundingTransaction.FunderRsmcHex)
 	if err != nil {
 		return nil, err
 	}
@@ -705,7 +710,7 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 	beforeSignAliceRsmcReferenceaddress := gjson.Get(beforeSignAliceRsmcDecode, "referenceaddress").Str
 	beforeSignAliceRsmcAmount := gjson.Get(beforeSignAliceRsmcDecode, "amount").Float()
 
-	omniDecode, err := conn2tracker.OmniDecodeTransaction(signedRsmcHex)
+	omniDecode, err := conn2tracker.OmniDecodeTransaction(context.Background(), signedRsmcHex)
 	if err != nil {
 		return nil, err
 	}
@@ -749,7 +754,7 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 		changeToAddress,
 		fundingTransaction.PropertyId,
 		fundingTransaction.AmountA,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		1000,
 		&aliceRsmcRedeemScript)
 	if err != nil {
@@ -822,7 +827,7 @@ func (service *fundingTransactionManager) AssetFundingSigned(jsonData string, si
 // 协议号：101035 当bob完成了RD和BR的第一次签名
 func (service *fundingTransactionManager) OnBobSignedRDAndBR(data string, user *bean.User) (aliceData, bobData map[string]interface{}, err error) {
 	if tool.CheckIsString(&data) == false {
-		return nil, nil, errors.New(enum.Tips_common_empty + " input data")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_empty, " input data")
 	}
 
 	signRdAndBr := bean.SignRdAndBrOfAssetFunding{}
@@ -830,31 +835,31 @@ func (service *fundingTransactionManager) OnBobSignedRDAndBR(data string, user *
 
 	temporaryChannelId := signRdAndBr.TemporaryChannelId
 	if tool.CheckIsString(&temporaryChannelId) == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + " temporary_channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, " temporary_channel_id")
 	}
 
 	brId := signRdAndBr.BrId
 	if brId == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + " br_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, " br_id")
 	}
 
 	rdSignedHex := signRdAndBr.RdSignedHex
 	if tool.CheckIsString(&rdSignedHex) == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + " rd_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, " rd_signed_hex")
 	}
 	_, err = omnicore.CheckMultiSign(rdSignedHex, 1)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "rd_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "rd_signed_hex")
 	}
 
 	brSignedHex := signRdAndBr.BrSignedHex
 	if tool.CheckIsString(&brSignedHex) == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + " br_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, " br_signed_hex")
 	}
 
 	_, err = omnicore.CheckMultiSign(brSignedHex, 1)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "br_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "br_signed_hex")
 	}
 
 	// 发送之前 bob的obd获取缓存待返回给alice的数据
@@ -923,6 +928,7 @@ func (service *fundingTransactionManager) OnBobSignedRDAndBR(data string, user *
 		log.Println(err)
 		return nil, nil, err
 	}
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	// 把签名后的rd hex放入回传给alice的数据包里面
 	aliceData = make(map[string]interface{})
@@ -973,7 +979,7 @@ func (service *fundingTransactionManager) OnAliceSignedRdAtAliceSide(data string
 	signedRdHex := signedRD.RdSignedHex
 	_, err = omnicore.CheckMultiSign(signedRdHex, 2)
 	if err != nil {
-		return nil, errors.New(enum.Tips_common_wrong + "rd_signed_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "rd_signed_hex")
 	}
 
 	cacheData := tempAssetFundingAfterBobSignData[user.PeerId+"_"+channelId]
@@ -1060,14 +1066,13 @@ func (service *fundingTransactionManager) OnAliceSignedRdAtAliceSide(data string
 		return nil, err
 	}
 
-	result := conn2tracker.TestMemPoolAccept(signedRdHex)
-	if result == "" {
-		return nil, errors.New("wrong signedRdHex")
+	result, err := conn2tracker.TestMemPoolAccept(context.Background(), signedRdHex)
+	if err != nil {
+		log.Println(err)
+		return nil, err
 	}
-	if gjson.Parse(result).Array()[0].Get("allowed").Bool() == false {
-		if gjson.Parse(result).Array()[0].Get("reject-reason").String() != "missing-inputs" {
-			return nil, errors.New(gjson.Parse(result).Array()[0].Get("reject-reason").String())
-		}
+	if result.Allowed == false && result.RejectReason != "missing-inputs" {
+		return nil, errors.New(result.RejectReason)
 	}
 	txid := checkHexOutputAddressFromOmniDecode(signedRdHex, toAddress)
 	if txid == "" {
@@ -1096,7 +1101,7 @@ func (service *fundingTransactionManager) OnAliceSignedRdAtAliceSide(data string
 		return nil, err
 	}
 
-	_, err = conn2tracker.SendRawTransaction(fundingTransaction.FundingTxHex)
+	_, err = conn2tracker.SendRawTransaction(context.Background(), fundingTransaction.FundingTxHex)
 	if err != nil {
 		err = errors.New("fail to send")
 		log.Println(err)
@@ -1116,6 +1121,8 @@ func (service *fundingTransactionManager) OnAliceSignedRdAtAliceSide(data string
 
 	//同步通道信息到tracker
 	sendChannelStateToTracker(*channelInfo, *commitmentTxInfo)
+	publishCommitmentTxSignedEvent(user.PeerId, *commitmentTxInfo)
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	node["temporary_channel_id"] = channelInfo.TemporaryChannelId
 	node["channel_id"] = channelInfo.ChannelId
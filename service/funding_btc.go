@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/asdine/storm/q"
@@ -38,14 +39,14 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		err = errors.New(enum.Tips_common_wrong + " temporary_channel_id")
+		err = enum.NewError(enum.ErrorCode_common_wrong, " temporary_channel_id")
 		log.Println(err)
 		return nil, "", err
 	}
 
 	btcFeeTxHexDecode, err := omnicore.DecodeBtcRawTransaction(reqData.FundingTxHex)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_failDecodeRawTransaction + " funding_tx_hex: " + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_failDecodeRawTransaction, " funding_tx_hex: "+err.Error())
 		log.Println(err)
 		return nil, "", err
 	}
@@ -67,22 +68,23 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return nil, "", errors.New(enum.Tips_funding_notFoundChannelByTempId + reqData.TemporaryChannelId)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, reqData.TemporaryChannelId)
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_WaitFundAsset {
-		return nil, "", errors.New(enum.Tips_funding_notFundBtcState)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFundBtcState)
 	}
 
 	//check btc funding time
-	result := conn2tracker.ListReceivedByAddress(channelInfo.ChannelAddress)
-
-	if result != "" {
-		if len(gjson.Parse(result).Array()) > 0 {
-			btcFundingTimes := len(gjson.Parse(result).Array()[0].Get("txids").Array())
-			if btcFundingTimes >= config.BtcNeedFundTimes {
-				return nil, "", errors.New(enum.Tips_funding_enoughBtcFundingTime)
-			}
+	result, err := conn2tracker.ListReceivedByAddress(context.Background(), channelInfo.ChannelAddress)
+	if err != nil {
+		log.Println(err)
+		return nil, "", err
+	}
+	if len(gjson.Parse(result).Array()) > 0 {
+		btcFundingTimes := len(gjson.Parse(result).Array()[0].Get("txids").Array())
+		if btcFundingTimes >= config.BtcNeedFundTimes {
+			return nil, "", enum.NewError(enum.ErrorCode_funding_enoughBtcFundingTime)
 		}
 	}
 
@@ -94,7 +96,7 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 	}
 
 	if msg.RecipientUserPeerId != targetUser {
-		return nil, "", errors.New(enum.Tips_common_wrong + "recipient_user_peer_id")
+		return nil, "", enum.NewError(enum.ErrorCode_common_wrong, "recipient_user_peer_id")
 	}
 
 	//get btc miner Fee data from transaction
@@ -106,7 +108,7 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 
 	out := GetBtcMinerFundMiniAmount()
 	if amount < out {
-		err = errors.New(enum.Tips_funding_btcAmountMustGreater + tool.FloatToString(out, 8))
+		err = enum.NewError(enum.ErrorCode_funding_btcAmountMustGreater, tool.FloatToString(out, 8))
 		log.Println(err)
 		return nil, "", err
 	}
@@ -119,7 +121,7 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 		q.Eq("SignApproval", true)).
 		Count(&dao.FundingBtcRequest{})
 	if count != 0 {
-		err = errors.New(enum.Tips_funding_btcTxBeenSend)
+		err = enum.NewError(enum.ErrorCode_funding_btcTxBeenSend)
 		log.Println(err)
 		return nil, "", err
 	}
@@ -137,14 +139,16 @@ func (service *fundingTransactionManager) BtcFundingCreated(msg bean.RequestMess
 		Reverse().
 		First(latestBtcFundingRequest)
 	if latestBtcFundingRequest.Id > 0 && latestBtcFundingRequest.TxId != fundingTxid {
-		result = conn2tracker.TestMemPoolAccept(latestBtcFundingRequest.TxHash)
-		if result != "" {
-			allowed := gjson.Parse(result).Get("allowed").Bool()
-			if allowed == false {
+		testResult, err := conn2tracker.TestMemPoolAccept(context.Background(), latestBtcFundingRequest.TxHash)
+		if conn2tracker.IsTrackerUnavailable(err) {
+			return nil, "", err
+		}
+		if err == nil {
+			if testResult.Allowed == false {
 				latestBtcFundingRequest.IsFinish = true
 				_ = tx.Update(latestBtcFundingRequest)
 			} else {
-				return nil, "", errors.New(enum.Tips_funding_fundTxIsRunning)
+				return nil, "", enum.NewError(enum.ErrorCode_funding_fundTxIsRunning)
 			}
 		}
 	}
@@ -352,11 +356,11 @@ func (service *fundingTransactionManager) OnAliceSignBtcFundingMinerFeeRedeemTx(
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return nil, "", errors.New(enum.Tips_funding_notFoundChannelByTempId + fundingBtcOfP2p.TemporaryChannelId)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, fundingBtcOfP2p.TemporaryChannelId)
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_WaitFundAsset {
-		return nil, "", errors.New(enum.Tips_funding_notFundBtcState)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFundBtcState)
 	}
 
 	redeemToAddress := channelInfo.AddressA
@@ -427,17 +431,17 @@ func (service *fundingTransactionManager) BeforeSignBtcFundingCreatedAtBobSide(d
 	fundingBtcHex := fundingBtcOfP2p.FundingBtcHex
 	fundingRedeemHex := fundingBtcOfP2p.FundingRedeemHex
 	if tool.CheckIsString(&temporaryChannelId) == false {
-		err = errors.New(enum.Tips_common_wrong + "temporary_channel_id")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "temporary_channel_id")
 		log.Println(err)
 		return nil, err
 	}
 	if tool.CheckIsString(&fundingBtcHex) == false {
-		err = errors.New(enum.Tips_common_wrong + "funding_btc_hex")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "funding_btc_hex")
 		log.Println(err)
 		return nil, err
 	}
 	if tool.CheckIsString(&fundingRedeemHex) == false {
-		err = errors.New(enum.Tips_common_wrong + "funding_redeem_hex")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "funding_redeem_hex")
 		log.Println(err)
 		return nil, err
 	}
@@ -470,7 +474,7 @@ func (service *fundingTransactionManager) BeforeSignBtcFundingCreatedAtBobSide(d
 
 	btcFeeTxHexDecode, err := omnicore.DecodeBtcRawTransaction(fundingBtcHex)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_failDecodeRawTransaction + " funding_btc_hex " + err.Error())
+		err = enum.NewError(enum.ErrorCode_funding_failDecodeRawTransaction, " funding_btc_hex "+err.Error())
 		log.Println(err)
 		return nil, err
 	}
@@ -532,19 +536,19 @@ func (service *fundingTransactionManager) FundingBtcTxSigned(msg bean.RequestMes
 	}
 
 	if tool.CheckIsString(&reqData.TemporaryChannelId) == false {
-		err = errors.New(enum.Tips_common_wrong + " temporary_channel_id ")
+		err = enum.NewError(enum.ErrorCode_common_wrong, " temporary_channel_id ")
 		log.Println(err)
 		return nil, "", err
 	}
 
 	if tool.CheckIsString(&reqData.FundingTxid) == false {
-		err = errors.New(enum.Tips_common_wrong + "funding_txid ")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "funding_txid ")
 		log.Println(err)
 		return nil, "", err
 	}
 	if reqData.Approval {
 		if tool.CheckIsString(&reqData.SignedMinerRedeemTransactionHex) == false {
-			err = errors.New(enum.Tips_common_wrong + "signed_miner_redeem_transaction_hex ")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed_miner_redeem_transaction_hex ")
 			log.Println(err)
 			return nil, "", err
 		}
@@ -573,11 +577,11 @@ func (service *fundingTransactionManager) FundingBtcTxSigned(msg bean.RequestMes
 		First(channelInfo)
 	if err != nil {
 		log.Println(err)
-		return nil, "", errors.New(enum.Tips_funding_notFoundChannelByTempId + reqData.TemporaryChannelId)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFoundChannelByTempId, reqData.TemporaryChannelId)
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_WaitFundAsset {
-		return nil, "", errors.New(enum.Tips_funding_notFundAssetState)
+		return nil, "", enum.NewError(enum.ErrorCode_funding_notFundAssetState)
 	}
 
 	funder = channelInfo.PeerIdA
@@ -588,7 +592,7 @@ func (service *fundingTransactionManager) FundingBtcTxSigned(msg bean.RequestMes
 	}
 
 	if funder != msg.RecipientUserPeerId {
-		return nil, "", errors.New(enum.Tips_common_wrong + "recipient_user_peer_id")
+		return nil, "", enum.NewError(enum.ErrorCode_common_wrong, "recipient_user_peer_id")
 	}
 
 	fundingBtcRequest := &dao.FundingBtcRequest{}
@@ -600,7 +604,7 @@ func (service *fundingTransactionManager) FundingBtcTxSigned(msg bean.RequestMes
 	).
 		First(fundingBtcRequest)
 	if err != nil {
-		err = errors.New(enum.Tips_funding_notFoundFundBtcTx)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundFundBtcTx)
 		log.Println(err)
 		return nil, "", err
 	}
@@ -636,7 +640,7 @@ func (service *fundingTransactionManager) FundingBtcTxSigned(msg bean.RequestMes
 	}
 
 	//赎回交易签名成功后，广播交易
-	_, err = conn2tracker.SendRawTransaction(fundingBtcRequest.TxHash)
+	_, err = conn2tracker.SendRawTransaction(context.Background(), fundingBtcRequest.TxHash)
 	if err != nil {
 		if strings.Contains(err.Error(), "Transaction already in block chain") == false {
 			return nil, funder, err
@@ -838,12 +842,12 @@ func (service *fundingTransactionManager) BtcFundingItemRDByTempChannelId(tempCh
 
 func (service *fundingTransactionManager) BtcFundingItemByChannelId(channelId string, user bean.User) (node []dao.FundingBtcRequest, err error) {
 	if tool.CheckIsString(&channelId) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "channelId")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "channelId")
 	}
 	channelInfo := dao.ChannelInfo{}
 	_ = user.Db.Select(q.Eq("ChannelId", channelId)).First(&channelInfo)
 	if channelInfo.Id == 0 {
-		return nil, errors.New(enum.Tips_funding_notFoundChannelByChannelId + channelId)
+		return nil, enum.NewError(enum.ErrorCode_funding_notFoundChannelByChannelId, channelId)
 	}
 	var itemes []dao.FundingBtcRequest
 	err = user.Db.Select(q.Eq("TemporaryChannelId", channelInfo.TemporaryChannelId)).OrderBy("CreateAt").Reverse().Find(&itemes)
@@ -855,15 +859,15 @@ func (service *fundingTransactionManager) BtcFundingItemByChannelId(channelId st
 
 func (service *fundingTransactionManager) BtcFundingItemRDByTempChannelIdAndFundingTxid(jsonData string, user bean.User) (node *dao.MinerFeeRedeemTransaction, err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "request data")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "request data")
 	}
 	var tempChanId = gjson.Parse(jsonData).Get("temporaryChannelId").String()
 	if tool.CheckIsString(&tempChanId) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "temporaryChannelId")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "temporaryChannelId")
 	}
 	var fundingTxid = gjson.Parse(jsonData).Get("fundingTxid").String()
 	if tool.CheckIsString(&fundingTxid) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "fundingTxid")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "fundingTxid")
 	}
 
 	var item = &dao.MinerFeeRedeemTransaction{}
@@ -945,17 +949,19 @@ func (service *fundingTransactionManager) CheckChannelFund(msg bean.RequestMessa
 		return nil, errors.New("btc balance greater :" + tool.FloatToString(out, 8))
 	}
 
-	balanceByAddress, err := conn2tracker.GetBalanceByAddress(channelInfo.FundingAddress)
+	balanceByAddress, err := conn2tracker.GetBalanceByAddress(context.Background(), channelInfo.FundingAddress)
 	if err != nil {
 		return nil, err
 	}
 	if balanceByAddress < funding.BtcAmount {
 		return nil, errors.New("not enough btc balance in address :" + channelInfo.FundingAddress)
 	}
-	omniBalanceObj := conn2tracker.OmniGetBalancesForAddress(channelInfo.FundingAddress, int(funding.PropertyId))
-	omniBalance := gjson.Get(omniBalanceObj, "balance").Float()
-	if omniBalance < funding.AssetAmount {
-		return nil, errors.New("not enough omni balance in address :" + channelInfo.FundingAddress + ": balance is " + tool.FloatToString(omniBalance, 8))
+	omniBalance, err := conn2tracker.OmniGetBalancesForAddress(context.Background(), channelInfo.FundingAddress, int(funding.PropertyId))
+	if err != nil {
+		return nil, err
+	}
+	if omniBalance.Balance < funding.AssetAmount {
+		return nil, errors.New("not enough omni balance in address :" + channelInfo.FundingAddress + ": balance is " + tool.FloatToString(omniBalance.Balance, 8))
 	}
 
 	return channelInfo, nil
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/tool"
+	"github.com/shopspring/decimal"
+)
+
+// createHtlcOnion the payer wraps the instructions of every hop of the path: the next channel, and the amount and
+// the cltv expiry of the htlc on it. The hops only learn their own instructions, not the payer nor the payee.
+// The secrets shared with the hops are kept by the payer to read the failure of the htlc.
+// hopAmounts are the amounts of the htlcs on the channels by the fee rates of the hops which the tracker found the path by,
+// the fee rate of obd is charged by every hop if they are unknown.
+func createHtlcOnion(requestData bean.CreateHtlcTxForC3a, channelIds []string, hopKeys []string, hopAmounts []float64) (onion string, secrets []string, err error) {
+	totalStep := len(channelIds)
+	hops := make([]tool.OnionHop, totalStep)
+	for i := range channelIds {
+		hops[i].PubKey = hopKeys[i]
+		if i == 0 {
+			hops[i].AssocData = htlcOnionAssocData(requestData.H, channelIds[i], requestData.Amount)
+		} else {
+			hops[i].AssocData = htlcOnionAssocData(requestData.H, channelIds[i], hops[i-1].Payload.Amount)
+		}
+		if i == totalStep-1 {
+			hops[i].Payload = tool.OnionPayload{Amount: requestData.AmountToPayee, CltvExpiry: requestData.CltvExpiry - i}
+			continue
+		}
+		amount, _ := decimal.NewFromFloat(requestData.AmountToPayee).Mul(decimal.NewFromFloat(1 + config.HtlcFeeRate*float64(totalStep-i-2))).Round(8).Float64()
+		if hopAmounts != nil {
+			amount = hopAmounts[i+1]
+		}
+		hops[i].Payload = tool.OnionPayload{NextChannelId: channelIds[i+1], Amount: amount, CltvExpiry: requestData.CltvExpiry - i - 1}
+	}
+	return tool.CreateOnion(hops)
+}
+
+// htlcOnionAssocData the layer of a hop is bound to the h, the channel and the amount of the htlc it receives, so the
+// onion can not be used for another htlc, nor be replayed on another channel or with another amount
+func htlcOnionAssocData(h, channelId string, amount float64) []byte {
+	return []byte(h + "_" + channelId + "_" + tool.FloatToString(amount, 8))
+}
+
+// peelHtlcOnion the receiver of the htlc peels its layer of the onion. The routing packet is the channel of the htlc,
+// and the next channel if the receiver is not the payee, which is all the receiver knows about the path.
+func peelHtlcOnion(payerData bean.CreateHtlcTxForC3aOfP2p) (routingPacket string, payload *tool.OnionPayload, nextOnion string, err error) {
+	payload, nextOnion, err = tool.PeelOnion(payerData.Onion, htlcOnionAssocData(payerData.H, payerData.ChannelId, payerData.Amount))
+	if err != nil {
+		return "", nil, "", enum.NewError(enum.ErrorCode_htlc_wrongOnion, err.Error())
+	}
+	if payload.Amount > payerData.Amount {
+		return "", nil, "", enum.NewError(enum.ErrorCode_htlc_wrongOnion, "the amount of the htlc is less than the amount in it")
+	}
+	routingPacket = payerData.ChannelId
+	if len(payload.NextChannelId) == 0 {
+		if len(nextOnion) > 0 || payload.CltvExpiry > payerData.CltvExpiry {
+			return "", nil, "", enum.NewError(enum.ErrorCode_htlc_wrongOnion, "the payee has no next hop")
+		}
+		// the payee counts the amount bound in the onion, not the one claimed by the sender
+		if payload.Amount != payerData.AmountToPayee {
+			return "", nil, "", enum.NewError(enum.ErrorCode_htlc_wrongOnion, "the amount to payee is not the amount in it")
+		}
+		return routingPacket, payload, "", nil
+	}
+	if len(nextOnion) == 0 || payload.NextChannelId == payerData.ChannelId || payload.CltvExpiry >= payerData.CltvExpiry {
+		return "", nil, "", enum.NewError(enum.ErrorCode_htlc_wrongOnion, "wrong next hop")
+	}
+	return routingPacket + "," + payload.NextChannelId, payload, nextOnion, nil
+}
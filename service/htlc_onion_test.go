@@ -0,0 +1,75 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/tool"
+)
+
+func TestHtlcOnion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_htlc_onion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dataDirectory := config.DataDirectory
+	config.DataDirectory = dir
+	defer func() { config.DataDirectory = dataDirectory }()
+	if _, err = tool.LoadOrCreateNodeKey(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the hops are the users of the same obd node, the hop of c2 charges a higher fee rate than the one of c3
+	hopKey := tool.GetOnionPubKey()
+	requestData := bean.CreateHtlcTxForC3a{H: "h", Amount: 1.0031, AmountToPayee: 1, CltvExpiry: 10}
+	onion, secrets, err := createHtlcOnion(requestData, []string{"c1", "c2", "c3"}, []string{hopKey, hopKey, hopKey}, []float64{1.0031, 1.001, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the onion is bound to the channel and the amount of the htlc
+	payerData := bean.CreateHtlcTxForC3aOfP2p{ChannelId: "c1", H: "h", Amount: 1, CltvExpiry: 10, Onion: onion}
+	if _, _, _, err = peelHtlcOnion(payerData); err == nil {
+		t.Fatal("the amount of the htlc is not the amount of the onion, want an error")
+	}
+	payerData.Amount = 1.0031
+	payerData.ChannelId = "c4"
+	if _, _, _, err = peelHtlcOnion(payerData); err == nil {
+		t.Fatal("the onion is replayed on another channel, want an error")
+	}
+	payerData.ChannelId = "c1"
+	// every hop only knows its channel and the next one, the payee gets the cltv expiry of its htlc
+	want := []struct {
+		routingPacket string
+		amount        float64
+		cltvExpiry    int
+	}{{"c1,c2", 1.001, 9}, {"c2,c3", 1, 8}, {"c3", 1, 8}}
+	for i, hop := range want {
+		routingPacket, payload, nextOnion, err := peelHtlcOnion(payerData)
+		if err != nil {
+			t.Fatalf("hop %d: %v", i, err)
+		}
+		if secret, _ := tool.OnionSharedSecret(payerData.Onion); secret != secrets[i] {
+			t.Fatalf("hop %d got another shared secret than the payer", i)
+		}
+		if routingPacket != hop.routingPacket || payload.Amount != hop.amount || payload.CltvExpiry != hop.cltvExpiry {
+			t.Fatalf("hop %d got %s and %+v, want %+v", i, routingPacket, *payload, hop)
+		}
+		if len(nextOnion) == 0 {
+			// the payee is not paid the amount to payee claimed by the sender of the htlc
+			payerData.AmountToPayee = 0.9
+			if _, _, _, err = peelHtlcOnion(payerData); err == nil {
+				t.Fatal("the amount to payee is not the amount of the onion, want an error")
+			}
+		}
+		payerData = bean.CreateHtlcTxForC3aOfP2p{ChannelId: payload.NextChannelId, H: "h", Amount: payload.Amount,
+			AmountToPayee: 1, CltvExpiry: payload.CltvExpiry, Onion: nextOnion}
+	}
+	if payerData.Amount != 1 || len(payerData.Onion) != 0 {
+		t.Fatalf("the payee got %f and the next onion %t, want the amount to payee and no onion", payerData.Amount, len(payerData.Onion) > 0)
+	}
+}
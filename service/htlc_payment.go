@@ -0,0 +1,441 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/tool"
+	trackerBean "github.com/omnilaboratory/obd/tracker/bean"
+	"github.com/shopspring/decimal"
+	"github.com/tidwall/gjson"
+)
+
+type paymentManager struct {
+	mu sync.Mutex
+}
+
+// PaymentService the payments of the payers by the paths of the tracker. A failed attempt is retried by another path,
+// which avoids the failed channels and nodes, until the attempts, the fees or the time of the payment are used up.
+var PaymentService paymentManager
+
+// start the payment of h with the budget of the request, the former failed payment of h is restarted
+func (service *paymentManager) start(requestData bean.HtlcRequestFindPath, pathInfo bean.HtlcRequestFindPathInfo, user bean.User) (*dao.HtlcPayment, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := &dao.HtlcPayment{}
+	_ = user.Db.Select(q.Eq("H", pathInfo.H)).First(payment)
+	if payment.State == dao.PaymentState_Succeeded {
+		return nil, enum.NewError(enum.ErrorCode_htlc_paymentSucceeded)
+	}
+	payment.H = pathInfo.H
+	payment.RecipientUserPeerId = pathInfo.RecipientUserPeerId
+	payment.PropertyId = pathInfo.PropertyId
+	payment.Amount = pathInfo.Amount
+	payment.MaxParts = requestData.MaxParts
+	payment.MaxAttempts = requestData.MaxAttempts
+	if payment.MaxAttempts < 1 {
+		payment.MaxAttempts = config.HtlcRetryAttempts
+	}
+	payment.FeeLimit = requestData.FeeLimit
+	if payment.FeeLimit <= 0 {
+		payment.FeeLimit = config.HtlcMaxFee
+	}
+	timeout := time.Duration(requestData.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = config.HtlcRetryTimeout
+	}
+	payment.CreateAt = time.Now()
+	payment.Deadline = payment.CreateAt.Add(timeout)
+	payment.ExcludedChannels = nil
+	payment.ExcludedPeers = nil
+	payment.Attempts = nil
+	payment.State = dao.PaymentState_InFlight
+	payment.R = ""
+	payment.FailureReason = ""
+	payment.FinishAt = time.Time{}
+	if err := user.Db.Save(payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// ask the tracker for a path of the payment, without the channels and the nodes failed before. It is false if obd
+// is not connected to the trackers.
+func (service *paymentManager) sendPathRequest(payment dao.HtlcPayment, user bean.User) bool {
+	pathRequest := trackerBean.HtlcPathRequest{}
+	pathRequest.H = payment.H
+	pathRequest.PropertyId = payment.PropertyId
+	pathRequest.Amount = payment.Amount
+	pathRequest.RealPayerPeerId = user.PeerId
+	pathRequest.PayerObdNodeId = tool.GetObdNodeId()
+	pathRequest.PayeePeerId = payment.RecipientUserPeerId
+	pathRequest.MaxParts = payment.MaxParts
+	pathRequest.ExcludedChannels = payment.ExcludedChannels
+	pathRequest.ExcludedPeers = payment.ExcludedPeers
+	return sendMsgToTracker(enum.MsgType_Tracker_GetHtlcPath_351, pathRequest)
+}
+
+// a new attempt by the path of the tracker, the payment fails if the fee of the path is over its limit
+func (service *paymentManager) onPathFound(h, path, hopKeys, hopAmounts string, fee float64, user bean.User) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(h, user)
+	if payment == nil {
+		return nil
+	}
+	if fee > payment.FeeLimit {
+		reason := fmt.Sprintf(enum.Tips_htlc_feeOverLimit, tool.FloatToString(fee, 8), tool.FloatToString(payment.FeeLimit, 8))
+		service.finish(payment, dao.PaymentState_Failed, reason, user)
+		return errors.New(reason)
+	}
+	payment.Attempts = append(payment.Attempts, dao.HtlcAttempt{Path: path, HopKeys: hopKeys, HopAmounts: hopAmounts, Fee: fee, State: dao.PaymentState_InFlight, CreateAt: time.Now()})
+	_ = user.Db.Update(payment)
+	return nil
+}
+
+// OnPathNotFound the trackers have no path for the payment any more, or none of them can answer its path request
+func (service *paymentManager) OnPathNotFound(h string, reason string, user bean.User) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if payment := service.getInFlight(h, user); payment != nil {
+		service.finish(payment, dao.PaymentState_Failed, reason, user)
+	}
+}
+
+// OnAttemptFailed the htlc of the payment by the path is rejected by a hop, the failed channel and node are excluded,
+// and the payment is retried by another path if its budget is not used up. The attempt of a multi-path payment is
+// retried when all its parts are back. It is false if h is not a payment in flight of the user, or it is not retried.
+func (service *paymentManager) OnAttemptFailed(h, path, failedChannelId, failedPeerId, reason string, user bean.User) (retried bool) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(h, user)
+	if payment == nil {
+		return false
+	}
+	return service.attemptFailed(payment, path, failedChannelId, failedPeerId, reason, user)
+}
+
+// attemptFailed the caller holds the lock of the service
+func (service *paymentManager) attemptFailed(payment *dao.HtlcPayment, path, failedChannelId, failedPeerId, reason string, user bean.User) bool {
+	var attempt *dao.HtlcAttempt
+	if count := len(payment.Attempts); count > 0 && payment.Attempts[count-1].State == dao.PaymentState_InFlight {
+		attempt = &payment.Attempts[count-1]
+		if len(failedChannelId) > 0 || len(failedPeerId) > 0 {
+			attempt.FailedChannelId = failedChannelId
+			attempt.FailedPeerId = failedPeerId
+		}
+		if len(reason) > 0 {
+			attempt.Error = reason
+		}
+	}
+	if len(failedChannelId) > 0 && containsString(payment.ExcludedChannels, failedChannelId) == false {
+		payment.ExcludedChannels = append(payment.ExcludedChannels, failedChannelId)
+		reportChannelFailure(payment.H, path, failedChannelId)
+	}
+	if len(failedPeerId) > 0 && failedPeerId != payment.RecipientUserPeerId && containsString(payment.ExcludedPeers, failedPeerId) == false {
+		payment.ExcludedPeers = append(payment.ExcludedPeers, failedPeerId)
+	}
+
+	if attempt != nil {
+		// the other parts of a multi-path payment are still on their way, until the payee cancels them
+		if routes := partRoutes(attempt.Path); len(routes) > 1 {
+			if containsString(routes, path) && containsString(attempt.ClosedParts, path) == false {
+				attempt.ClosedParts = append(attempt.ClosedParts, path)
+			}
+			if len(attempt.ClosedParts) < len(routes) {
+				_ = user.Db.Update(payment)
+				return false
+			}
+		}
+		attempt.State = dao.PaymentState_Failed
+	}
+	if len(payment.Attempts) >= payment.MaxAttempts {
+		service.finish(payment, dao.PaymentState_Failed, fmt.Sprintf(enum.Tips_htlc_attemptsUsedUp, len(payment.Attempts)), user)
+		return false
+	}
+	if time.Now().After(payment.Deadline) {
+		service.finish(payment, dao.PaymentState_Failed, enum.Tips_htlc_retryTimeOut, user)
+		return false
+	}
+	_ = user.Db.Update(payment)
+	log.Println("retry the payment", payment.H, "without", payment.ExcludedChannels, payment.ExcludedPeers)
+	if service.sendPathRequest(*payment, user) == false {
+		service.finish(payment, dao.PaymentState_Failed, enum.Tips_htlc_noTrackerForPath, user)
+		return false
+	}
+	return true
+}
+
+// the routes of the parts of the path, like "c1,c2" and "c3" of "c1,c2:0.5;c3:0.5"
+func partRoutes(path string) []string {
+	routes := make([]string, 0)
+	for _, item := range strings.Split(path, ";") {
+		routes = append(routes, strings.Split(item, ":")[0])
+	}
+	return routes
+}
+
+// the tracker counts the failure of the channel in its history, the channel is ranked lower in the next paths
+func reportChannelFailure(h, path, failedChannelId string) {
+	txStateRequest := trackerBean.UpdateHtlcTxStateRequest{}
+	txStateRequest.Path = path
+	txStateRequest.H = h
+	txStateRequest.CurrChannelId = failedChannelId
+	txStateRequest.FailedChannelId = failedChannelId
+	sendMsgToTracker(enum.MsgType_Tracker_UpdateHtlcTxState_352, txStateRequest)
+}
+
+// the payer gets r from the first channel of the path, the payment succeeded
+func (service *paymentManager) onHtlcGetR(htlcTx dao.CommitmentTransaction, user bean.User) {
+	if htlcTx.HtlcSender != user.PeerId || strings.HasPrefix(htlcTx.HtlcRoutingPacket, htlcTx.ChannelId) == false {
+		return
+	}
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(htlcTx.HtlcH, user)
+	if payment == nil {
+		return
+	}
+	payment.R = htlcTx.HtlcR
+	for i := range payment.Attempts {
+		if payment.Attempts[i].State == dao.PaymentState_InFlight {
+			payment.Attempts[i].State = dao.PaymentState_Succeeded
+		}
+	}
+	service.finish(payment, dao.PaymentState_Succeeded, "", user)
+}
+
+// the failure of the htlc comes back to the payer, it is kept on the attempt until the htlc of the payer is closed
+func (service *paymentManager) onHtlcFailed(h string, failure bean.HtlcFailure, user bean.User) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(h, user)
+	if payment == nil {
+		return
+	}
+	if count := len(payment.Attempts); count > 0 && payment.Attempts[count-1].State == dao.PaymentState_InFlight {
+		attempt := &payment.Attempts[count-1]
+		attempt.FailedChannelId = failure.FailedChannelId
+		attempt.FailedPeerId = failure.FailedPeerId
+		attempt.Error = failure.Reason
+		_ = user.Db.Update(payment)
+	}
+}
+
+// the payer closed its htlc without r, the payment is retried by the failure of the attempt, so that the amount of
+// the first channel is back before the next attempt
+func (service *paymentManager) onHtlcClosed(htlcTx dao.CommitmentTransaction, user bean.User) {
+	if htlcTx.HtlcSender != user.PeerId || len(htlcTx.HtlcR) > 0 || strings.HasPrefix(htlcTx.HtlcRoutingPacket, htlcTx.ChannelId) == false {
+		return
+	}
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(htlcTx.HtlcH, user)
+	if payment == nil || len(payment.Attempts) == 0 {
+		return
+	}
+	attempt := payment.Attempts[len(payment.Attempts)-1]
+	if attempt.State != dao.PaymentState_InFlight {
+		return
+	}
+	reason := attempt.Error
+	if len(reason) == 0 {
+		reason = htlcTx.HtlcFailReason
+	}
+	service.attemptFailed(payment, htlcTx.HtlcRoutingPacket, attempt.FailedChannelId, attempt.FailedPeerId, reason, user)
+}
+
+// the hop keys of the path of the payment in flight, nil if the path is not found by the tracker. The payment fails
+// if the tracker did not send the keys of all the hops of a path of more than one channel.
+func (service *paymentManager) getHopKeys(h, path string, user bean.User) ([]string, error) {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(h, user)
+	if payment == nil || len(payment.Attempts) == 0 {
+		return nil, nil
+	}
+	attempt := payment.Attempts[len(payment.Attempts)-1]
+	routes := partRoutes(attempt.Path)
+	if containsString(routes, path) == false {
+		return nil, nil
+	}
+	channelCount := len(strings.Split(path, ","))
+	hopKeys := strings.Split(attempt.HopKeys, ";")
+	for i, route := range routes {
+		if route != path || len(hopKeys) != len(routes) {
+			continue
+		}
+		if keys := strings.Split(hopKeys[i], ","); len(keys) == channelCount && len(hopKeys[i]) > 0 {
+			return keys, nil
+		}
+	}
+	if channelCount == 1 {
+		return nil, nil
+	}
+	err := enum.NewError(enum.ErrorCode_htlc_noHopKeys)
+	service.finish(payment, dao.PaymentState_Failed, err.Error(), user)
+	return nil, err
+}
+
+// the hop amounts of the path of the payment in flight, nil if the tracker did not send them
+func (service *paymentManager) getHopAmounts(h, path string, user bean.User) []float64 {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	payment := service.getInFlight(h, user)
+	if payment == nil || len(payment.Attempts) == 0 {
+		return nil
+	}
+	attempt := payment.Attempts[len(payment.Attempts)-1]
+	for i, route := range partRoutes(attempt.Path) {
+		if route == path {
+			return parseHopAmounts(attempt.HopAmounts, i, path)
+		}
+	}
+	return nil
+}
+
+// parseHopAmounts the hop amounts of the index-th route of the path of the tracker, nil if they are not of every channel
+// of the route
+func parseHopAmounts(hopAmounts string, index int, route string) []float64 {
+	items := strings.Split(hopAmounts, ";")
+	if len(hopAmounts) == 0 || index >= len(items) {
+		return nil
+	}
+	values := strings.Split(items[index], ",")
+	if len(values) != len(strings.Split(route, ",")) {
+		return nil
+	}
+	amounts := make([]float64, 0, len(values))
+	for _, value := range values {
+		amount, err := strconv.ParseFloat(value, 64)
+		if err != nil || amount <= 0 {
+			return nil
+		}
+		amounts = append(amounts, amount)
+	}
+	return amounts
+}
+
+// the attempts of the payment in flight
+func (service *paymentManager) attemptCount(h string, user bean.User) int {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if payment := service.getInFlight(h, user); payment != nil {
+		return len(payment.Attempts)
+	}
+	return 0
+}
+
+func (service *paymentManager) getInFlight(h string, user bean.User) *dao.HtlcPayment {
+	if user.Db == nil {
+		return nil
+	}
+	payment := &dao.HtlcPayment{}
+	err := user.Db.Select(q.Eq("H", h), q.Eq("State", dao.PaymentState_InFlight)).First(payment)
+	if err != nil {
+		return nil
+	}
+	return payment
+}
+
+func (service *paymentManager) finish(payment *dao.HtlcPayment, state dao.PaymentState, reason string, user bean.User) {
+	payment.State = state
+	payment.FailureReason = reason
+	payment.FinishAt = time.Now()
+	_ = user.Db.Update(payment)
+	if state == dao.PaymentState_Succeeded {
+		publishPaymentEvent(user.PeerId, enum.EventType_PaymentSucceeded, *payment)
+	} else {
+		publishPaymentEvent(user.PeerId, enum.EventType_PaymentFailed, *payment)
+	}
+}
+
+// GetPayment the payment of h, with the attempts of it
+func (service *paymentManager) GetPayment(jsonData string, user bean.User) (payment *dao.HtlcPayment, err error) {
+	h := gjson.Get(jsonData, "h").String()
+	if tool.CheckIsString(&h) == false {
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "h")
+	}
+	payment = &dao.HtlcPayment{}
+	err = user.Db.Select(q.Eq("H", h)).First(payment)
+	if err != nil {
+		return nil, enum.NewError(enum.ErrorCode_common_notFound, "payment by "+h)
+	}
+	return payment, nil
+}
+
+// ListPayments the payments of the user, the latest first, filtered by the state if it is not empty
+func (service *paymentManager) ListPayments(jsonData string, user bean.User) (data map[string]interface{}, err error) {
+	indexOffset := gjson.Get(jsonData, "index_offset").Int()
+	if indexOffset < 0 {
+		indexOffset = 0
+	}
+	numMaxPayments := gjson.Get(jsonData, "num_max_payments").Int()
+	if numMaxPayments < 1 {
+		numMaxPayments = 20
+	}
+	query := user.Db.Select()
+	if state := gjson.Get(jsonData, "state").String(); len(state) > 0 {
+		query = user.Db.Select(q.Eq("State", dao.PaymentState(state)))
+	}
+	var list []dao.HtlcPayment
+	err = query.OrderBy("Id").Reverse().Skip(int(indexOffset)).Limit(int(numMaxPayments)).Find(&list)
+	if err != nil && err.Error() != "not found" {
+		return nil, err
+	}
+	data = make(map[string]interface{})
+	data["payments"] = list
+	data["first_index_offset"] = indexOffset
+	data["last_index_offset"] = indexOffset + int64(len(list))
+	return data, nil
+}
+
+func publishPaymentEvent(userPeerId string, eventType enum.EventType, payment dao.HtlcPayment) {
+	fee := decimal.Zero
+	for _, attempt := range payment.Attempts {
+		if attempt.State == dao.PaymentState_Succeeded {
+			fee = fee.Add(decimal.NewFromFloat(attempt.Fee))
+		}
+	}
+	data := map[string]interface{}{
+		"h":        payment.H,
+		"amount":   payment.Amount,
+		"attempts": len(payment.Attempts),
+	}
+	if eventType == enum.EventType_PaymentSucceeded {
+		data["r"] = payment.R
+		data["fee"], _ = fee.Round(8).Float64()
+	} else {
+		data["reason"] = payment.FailureReason
+	}
+	EventService.Publish(userPeerId, eventType, "", data)
+}
+
+func containsString(items []string, item string) bool {
+	for _, value := range items {
+		if value == item {
+			return true
+		}
+	}
+	return false
+}
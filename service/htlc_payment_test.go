@@ -0,0 +1,168 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/tidwall/gjson"
+)
+
+func TestRetryPayment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_htlc_payment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := storm.Open(dir + "/user_alice.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	user := bean.User{PeerId: "alice", Db: db}
+
+	trackerChan := TrackerChan
+	TrackerChan = make(chan []byte, 8)
+	defer func() { TrackerChan = trackerChan }()
+
+	requestData := bean.HtlcRequestFindPath{MaxAttempts: 3, FeeLimit: 0.01}
+	pathInfo := bean.HtlcRequestFindPathInfo{H: "h", RecipientUserPeerId: "dave", PropertyId: 1, Amount: 1}
+	payment, err := PaymentService.start(requestData, pathInfo, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	PaymentService.sendPathRequest(*payment, user)
+	<-TrackerChan
+
+	// the first channel fails, it is reported to the tracker, and the tracker is asked again without it
+	if err = PaymentService.onPathFound("h", "c1,c2", "", "", 0.001, user); err != nil {
+		t.Fatal(err)
+	}
+	if PaymentService.OnAttemptFailed("h", "c1,c2", "c1", "", "c1 failed", user) == false {
+		t.Fatal("want a retry after the first attempt")
+	}
+	failureReport := gjson.ParseBytes(<-TrackerChan)
+	if failureReport.Get("type").Int() != int64(enum.MsgType_Tracker_UpdateHtlcTxState_352) || failureReport.Get("data.failed_channel_id").String() != "c1" {
+		t.Fatalf("got %s, want the failure of c1 to the tracker", failureReport.Raw)
+	}
+	pathRequest := gjson.ParseBytes(<-TrackerChan).Get("data")
+	if excluded := pathRequest.Get("excluded_channels").Array(); len(excluded) != 1 || excluded[0].String() != "c1" {
+		t.Fatalf("got the excluded channels %v, want [c1]", excluded)
+	}
+
+	// the payee is never excluded, bob is
+	_ = PaymentService.onPathFound("h", "c3,c4", "", "", 0.001, user)
+	PaymentService.OnAttemptFailed("h", "c3,c4", "", "dave", "dave is offline", user)
+	<-TrackerChan
+	_ = PaymentService.onPathFound("h", "c5,c6", "", "", 0.001, user)
+	if PaymentService.OnAttemptFailed("h", "c5,c6", "", "bob", "bob is offline", user) {
+		t.Fatal("the attempts are used up, want no retry")
+	}
+	payment, _ = PaymentService.GetPayment(`{"h":"h"}`, user)
+	if payment.State != dao.PaymentState_Failed || len(payment.Attempts) != 3 {
+		t.Fatalf("got the payment %s with %d attempts, want failed with 3", payment.State, len(payment.Attempts))
+	}
+	if len(payment.ExcludedPeers) != 1 || payment.ExcludedPeers[0] != "bob" {
+		t.Fatalf("got the excluded peers %v, want [bob]", payment.ExcludedPeers)
+	}
+
+	// the failed payment is restarted, and fails if the fee of the path is over the limit
+	if _, err = PaymentService.start(requestData, pathInfo, user); err != nil {
+		t.Fatal(err)
+	}
+	if err = PaymentService.onPathFound("h", "c7,c8", "", "", 0.02, user); err == nil {
+		t.Fatal("the fee is over the limit, want an error")
+	}
+
+	// the payer gets r, the payment of h can not be paid again
+	_, _ = PaymentService.start(requestData, pathInfo, user)
+	_ = PaymentService.onPathFound("h", "c1,c2", "", "", 0.001, user)
+	PaymentService.onHtlcGetR(dao.CommitmentTransaction{ChannelId: "c1", HtlcSender: "alice", HtlcH: "h", HtlcR: "r", HtlcRoutingPacket: "c1,c2"}, user)
+	payment, _ = PaymentService.GetPayment(`{"h":"h"}`, user)
+	if payment.State != dao.PaymentState_Succeeded || payment.R != "r" {
+		t.Fatalf("got the payment %s with r %s, want succeeded with r", payment.State, payment.R)
+	}
+	if _, err = PaymentService.start(requestData, pathInfo, user); err == nil {
+		t.Fatal("the payment succeeded, want an error")
+	}
+
+	// a multi-path attempt is retried when all its parts are back
+	pathInfo.H = "h2"
+	_, _ = PaymentService.start(requestData, pathInfo, user)
+	_ = PaymentService.onPathFound("h2", "c1,c2:0.5;c3,c4:0.5", "", "", 0.001, user)
+	if PaymentService.OnAttemptFailed("h2", "c1,c2", "c2", "", "c2 failed", user) {
+		t.Fatal("the other part is on its way, want no retry")
+	}
+	<-TrackerChan
+	payment, _ = PaymentService.GetPayment(`{"h":"h2"}`, user)
+	if payment.State != dao.PaymentState_InFlight || payment.Attempts[0].State != dao.PaymentState_InFlight {
+		t.Fatalf("got the payment %s with the attempt %s, want both in flight", payment.State, payment.Attempts[0].State)
+	}
+	if PaymentService.OnAttemptFailed("h2", "c3,c4", "", "", "timed out", user) == false {
+		t.Fatal("all parts are back, want a retry")
+	}
+	pathRequest = gjson.ParseBytes(<-TrackerChan).Get("data")
+	if excluded := pathRequest.Get("excluded_channels").Array(); len(excluded) != 1 || excluded[0].String() != "c2" {
+		t.Fatalf("got the excluded channels %v, want [c2]", excluded)
+	}
+
+	// obd is not connected to the trackers, the payment fails instead of waiting for a path
+	TrackerChan = nil
+	_ = PaymentService.onPathFound("h2", "c5,c6", "", "", 0.001, user)
+	if PaymentService.OnAttemptFailed("h2", "c5,c6", "", "", "c5 failed", user) {
+		t.Fatal("no tracker can be asked, want no retry")
+	}
+	payment, _ = PaymentService.GetPayment(`{"h":"h2"}`, user)
+	if payment.State != dao.PaymentState_Failed || payment.FailureReason != enum.Tips_htlc_noTrackerForPath {
+		t.Fatalf("got the payment %s by %s, want failed with no tracker", payment.State, payment.FailureReason)
+	}
+}
+
+func TestHopKeysOfPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_htlc_hop_keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := storm.Open(dir + "/user_alice.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	user := bean.User{PeerId: "alice", Db: db}
+
+	requestData := bean.HtlcRequestFindPath{MaxAttempts: 3, FeeLimit: 0.01}
+	pathInfo := bean.HtlcRequestFindPathInfo{H: "h", RecipientUserPeerId: "dave", PropertyId: 1, Amount: 1}
+	_, _ = PaymentService.start(requestData, pathInfo, user)
+	_ = PaymentService.onPathFound("h", "c1,c2:0.5;c3:0.5", "key_bob,key_dave;", "0.50100000,0.50000000;0.50000000", 0.001, user)
+	if keys, err := PaymentService.getHopKeys("h", "c1,c2", user); err != nil || len(keys) != 2 || keys[1] != "key_dave" {
+		t.Fatalf("got the hop keys %v and %v, want the keys of bob and dave", keys, err)
+	}
+	// the fee of bob is by its fee rate in the path of the tracker
+	if amounts := PaymentService.getHopAmounts("h", "c1,c2", user); len(amounts) != 2 || amounts[0] != 0.501 || amounts[1] != 0.5 {
+		t.Fatalf("got the hop amounts %v, want the fee of bob in the first channel", amounts)
+	}
+	if amounts := PaymentService.getHopAmounts("h", "c3", user); len(amounts) != 1 || amounts[0] != 0.5 {
+		t.Fatalf("got the hop amounts %v of the direct channel, want the part", amounts)
+	}
+	// the direct channel to the payee has no onion
+	if keys, err := PaymentService.getHopKeys("h", "c3", user); err != nil || keys != nil {
+		t.Fatalf("got the hop keys %v and %v, want none", keys, err)
+	}
+
+	// the keys of a path are unknown, the payment fails instead of sending the path in plain text
+	pathInfo.H = "h2"
+	_, _ = PaymentService.start(requestData, pathInfo, user)
+	_ = PaymentService.onPathFound("h2", "c1,c2", "", "", 0.001, user)
+	if _, err = PaymentService.getHopKeys("h2", "c1,c2", user); enum.ErrorCodeOf(err) != enum.ErrorCode_htlc_noHopKeys {
+		t.Fatalf("got %v, want no hop keys", err)
+	}
+	payment, _ := PaymentService.GetPayment(`{"h":"h2"}`, user)
+	if payment.State != dao.PaymentState_Failed {
+		t.Fatalf("got the payment %s, want failed", payment.State)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/asdine/storm"
@@ -28,6 +29,21 @@ type htlcBackwardTxManager struct {
 // HTLC Reverse pass the R (Preimage R)
 var HtlcBackwardTxService htlcBackwardTxManager
 
+// GetPendingHtlcParts the htlcs of h received by the payee, which are waiting for r
+func (service *htlcBackwardTxManager) GetPendingHtlcParts(h string, user bean.User) (parts []dao.CommitmentTransaction) {
+	for _, part := range getArrivedHtlcParts(user.Db, h, user) {
+		if part.CurrState == dao.TxInfoState_Htlc_GetH {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// CheckHtlcPartsArrived whether the parts of the htlc have carried the full amount to the payee
+func (service *htlcBackwardTxManager) CheckHtlcPartsArrived(htlcTx dao.CommitmentTransaction, user bean.User) error {
+	return checkHtlcPartsArrived(user.Db, htlcTx, user)
+}
+
 // step 1 bob -100045 收款方收到R，发送R到obd进行验证
 func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.RequestMessage, user bean.User) (retData interface{}, err error) {
 	totalDurationClient += time.Now().Sub(beginTime).Milliseconds()
@@ -35,7 +51,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 	log.Println("back step 1 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 
 	reqData := &bean.HtlcBobSendR{}
@@ -54,7 +70,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 
 	// region Check data inputed from websocket client of sender.
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id ")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id ")
 		log.Println(err)
 		return nil, err
 	}
@@ -66,7 +82,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 			q.Eq("PeerIdA", user.PeerId),
 			q.Eq("PeerIdB", user.PeerId))).First(channelInfo)
 	if err != nil {
-		err = errors.New(enum.Tips_common_notFound + "channelInfo by " + reqData.ChannelId)
+		err = enum.NewError(enum.ErrorCode_common_notFound, "channelInfo by "+reqData.ChannelId)
 		log.Println(err)
 		return nil, err
 	}
@@ -83,7 +99,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 	}
 
 	if payerPeerId != msg.RecipientUserPeerId {
-		return nil, errors.New(enum.Tips_rsmc_notTargetUser)
+		return nil, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	err = findUserIsOnline(msg.RecipientNodePeerId, payerPeerId)
@@ -93,45 +109,59 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 	}
 
 	if tool.CheckIsString(&reqData.R) == false {
-		err = errors.New(enum.Tips_common_empty + "r")
+		err = enum.NewError(enum.ErrorCode_common_empty, "r")
 		log.Println(err)
 		return nil, err
 	}
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, reqData.ChannelId, user.PeerId)
 	if err != nil {
-		err = errors.New(enum.Tips_common_notFound + "latestCommitmentTxInfo")
+		err = enum.NewError(enum.ErrorCode_common_notFound, "latestCommitmentTxInfo")
 		log.Println(err)
 		return nil, err
 	}
 	if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Htlc_GetH {
-		err = errors.New(enum.Tips_rsmc_errorCommitmentTxState + strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
+		err = enum.NewError(enum.ErrorCode_rsmc_errorCommitmentTxState, strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
 		log.Println(err)
 		return nil, err
 	}
 
 	if latestCommitmentTxInfo.HtlcSender != msg.RecipientUserPeerId {
-		err = errors.New(enum.Tips_htlc_notTheHltcSender)
+		err = enum.NewError(enum.ErrorCode_htlc_notTheHltcSender)
 		log.Println(err)
 		return nil, err
 	}
 
 	_, err = omnicore.GetPubKeyFromWifAndCheck(reqData.R, latestCommitmentTxInfo.HtlcH)
 	if err != nil {
-		return nil, errors.New(enum.Tips_htlc_wrongRForH)
+		return nil, enum.NewError(enum.ErrorCode_htlc_wrongRForH)
+	}
+
+	// the payee of a multi-path payment waits for all the parts
+	if strings.HasSuffix(latestCommitmentTxInfo.HtlcRoutingPacket, channelInfo.ChannelId) {
+		err = checkHtlcPartsArrived(tx, *latestCommitmentTxInfo, user)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
 	}
 
 	latestCommitmentTxInfo.HtlcR = reqData.R
 
 	// endregion
 
-	currBlockHeight := conn2tracker.GetBlockCount()
+	currBlockHeight, err := conn2tracker.GetBlockCount(context.Background())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
 
 	htlcTimeOut := latestCommitmentTxInfo.HtlcCltvExpiry
 	maxHeight := latestCommitmentTxInfo.BeginBlockHeight + htlcTimeOut
 	if strings.Contains(config.ChainNodeType, "main") {
 		if currBlockHeight > maxHeight {
-			return nil, errors.New(enum.Tips_htlc_timeOut)
+			publishHtlcEvent(user.PeerId, enum.EventType_HtlcFailed, *latestCommitmentTxInfo, enum.Tips_htlc_timeOut)
+			return nil, enum.NewError(enum.ErrorCode_htlc_timeOut)
 		}
 	}
 
@@ -163,7 +193,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		latestCommitmentTxInfo.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		1000,
 		&he1b.RSMCRedeemScript)
 	if err != nil {
@@ -198,7 +228,7 @@ func (service *htlcBackwardTxManager) SendRToPreviousNodeAtBobSide(msg bean.Requ
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		latestCommitmentTxInfo.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		0,
 		&he1b.RSMCRedeemScript)
 	if err != nil {
@@ -287,7 +317,7 @@ func (service *htlcBackwardTxManager) OnGetHeSubTxDataAtAliceObdAtAliceSide(msg
 	}
 
 	if tool.CheckIsString(&dataFrom45P.R) == false {
-		return nil, errors.New(enum.Tips_common_empty + "R")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "R")
 	}
 
 	tx, err := user.Db.Begin(true)
@@ -302,15 +332,15 @@ func (service *htlcBackwardTxManager) OnGetHeSubTxDataAtAliceObdAtAliceSide(msg
 		return nil, err
 	}
 	if _, err = omnicore.GetPubKeyFromWifAndCheck(dataFrom45P.R, latestCommitmentTx.HtlcH); err != nil {
-		return nil, errors.New(enum.Tips_htlc_wrongRForH)
+		return nil, enum.NewError(enum.ErrorCode_htlc_wrongRForH)
 	}
 
 	if tool.CheckIsString(&dataFrom45P.C3bHtlcTempAddressForHePubKey) == false {
-		return nil, errors.New(enum.Tips_common_empty + "c3b_htlc_temp_address_for_he_pub_key")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "c3b_htlc_temp_address_for_he_pub_key")
 	}
 
 	if tool.CheckIsString(&dataFrom45P.HeCompleteSignedHex) == false {
-		return nil, errors.New(enum.Tips_common_empty + "he_complete_signed_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "he_complete_signed_hex")
 	}
 
 	cacheDataForTx := &dao.CacheDataForTx{}
@@ -378,7 +408,7 @@ func (service *htlcBackwardTxManager) OnAliceSignedHeRdAtAliceSide(msg bean.Requ
 	defer tx.Rollback()
 
 	if tool.CheckIsString(&herdSignedResult.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id ")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id ")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -390,7 +420,7 @@ func (service *htlcBackwardTxManager) OnAliceSignedHeRdAtAliceSide(msg bean.Requ
 			q.Eq("PeerIdA", user.PeerId),
 			q.Eq("PeerIdB", user.PeerId))).First(channelInfo)
 	if err != nil {
-		err = errors.New(enum.Tips_common_notFound + "channelInfo by " + herdSignedResult.ChannelId)
+		err = enum.NewError(enum.ErrorCode_common_notFound, "channelInfo by "+herdSignedResult.ChannelId)
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -440,6 +470,19 @@ func (service *htlcBackwardTxManager) OnAliceSignedHeRdAtAliceSide(msg bean.Requ
 	latestCommitment.CurrState = dao.TxInfoState_Htlc_GetR
 	_ = tx.Update(latestCommitment)
 	_ = tx.Commit()
+	// the payer gets r, the payment succeeded
+	PaymentService.onHtlcGetR(*latestCommitment, user)
+	// the hops of the onion do not know the path, the payer updates the htlc state of the path on tracker with r
+	if channelInfo.IsPrivate == false && latestCommitment.HtlcSender == user.PeerId &&
+		strings.HasPrefix(latestCommitment.HtlcRoutingPacket, channelInfo.ChannelId) {
+		txStateRequest := trackerBean.UpdateHtlcTxStateRequest{}
+		txStateRequest.Path = latestCommitment.HtlcRoutingPacket
+		txStateRequest.H = latestCommitment.HtlcH
+		txStateRequest.R = latestCommitment.HtlcR
+		txStateRequest.DirectionFlag = trackerBean.HtlcTxState_ConfirmPayMoney
+		txStateRequest.CurrChannelId = channelInfo.ChannelId
+		sendMsgToTracker(enum.MsgType_Tracker_UpdateHtlcTxState_352, txStateRequest)
+	}
 
 	totalDurationObd += time.Now().Sub(beginTime).Milliseconds()
 	beginTime = time.Now()
@@ -3,7 +3,6 @@ package service
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
@@ -30,6 +29,15 @@ type htlcCloseTxManager struct {
 // htlc 关闭当前htlc交易
 var HtlcCloseTxService htlcCloseTxManager
 
+// RequireHtlcClose the htlc can not be settled any more, it is closed to give the amount back to its sender.
+// The client of the user closes it after the htlc_close_required event, obd closes it for the admin user.
+func RequireHtlcClose(htlcTx dao.CommitmentTransaction, reason string, user bean.User) {
+	publishHtlcEvent(user.PeerId, enum.EventType_HtlcCloseRequired, htlcTx, reason)
+	if user.IsAdmin {
+		go CloseHtlcByObd(htlcTx, user)
+	}
+}
+
 // step1 Alice 100049 请求关闭htlc交易 -100049 request close htlc
 func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, user bean.User) (data interface{}, needSign bool, err error) {
 	totalDurationClient += time.Now().Sub(beginTime).Milliseconds()
@@ -37,7 +45,7 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 	log.Println("close step 1 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 
 	reqData := &bean.HtlcCloseRequestCurrTx{}
@@ -49,23 +57,23 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 
 	// region check data
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if tool.CheckIsString(&reqData.CurrTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_temp_address_pub_key")
 		log.Println(err)
 		return nil, false, err
 	}
 	if tool.CheckIsString(&reqData.LastHtlcTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_htlc_temp_address_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_htlc_temp_address_private_key")
 		log.Println(err)
 		return nil, false, err
 	}
 	if tool.CheckIsString(&reqData.LastRsmcTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_rsmc_temp_address_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_rsmc_temp_address_private_key")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -91,7 +99,7 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_HtlcTx {
-		return nil, false, errors.New(fmt.Sprintf(enum.Tips_htlc_wrongChannelState, channelInfo.CurrState, bean.ChannelState_HtlcTx))
+		return nil, false, enum.NewError(enum.ErrorCode_htlc_wrongChannelState, channelInfo.CurrState, bean.ChannelState_HtlcTx)
 	}
 
 	targetUser := channelInfo.PeerIdB
@@ -99,7 +107,7 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 		targetUser = channelInfo.PeerIdA
 	}
 	if msg.RecipientUserPeerId != targetUser {
-		return nil, false, errors.New(enum.Tips_rsmc_notTargetUser)
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	if err := findUserIsOnline(msg.RecipientNodePeerId, targetUser); err != nil {
@@ -115,7 +123,7 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 		tx.DeleteStruct(latestCommitmentTxInfo)
 		latestCommitmentTxInfo, err = getLatestCommitmentTxUseDbTx(tx, reqData.ChannelId, user.PeerId)
 		if err != nil {
-			return nil, false, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+			return nil, false, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 		}
 	}
 
@@ -123,14 +131,14 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 	if latestCommitmentTxInfo.TxType == dao.CommitmentTransactionType_Htlc {
 		if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Htlc_GetH &&
 			latestCommitmentTxInfo.CurrState != dao.TxInfoState_Htlc_GetR {
-			return nil, false, errors.New(enum.Tips_channel_wrongLatestCommitmentTxState + ": should be 11 or 12")
+			return nil, false, enum.NewError(enum.ErrorCode_channel_wrongLatestCommitmentTxState, ": should be 11 or 12")
 		}
 	}
 
 	//如果是第二次发起的请求，前面的请求失败了
 	if latestCommitmentTxInfo.TxType == dao.CommitmentTransactionType_Rsmc {
 		if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Create {
-			return nil, false, errors.New(enum.Tips_channel_wrongLatestCommitmentTxState + ": should be 5")
+			return nil, false, enum.NewError(enum.ErrorCode_channel_wrongLatestCommitmentTxState, ": should be 5")
 		}
 	}
 
@@ -158,7 +166,7 @@ func (service *htlcCloseTxManager) RequestCloseHtlc(msg bean.RequestMessage, use
 		}
 	} else {
 		if reqData.CurrTempAddressPubKey != latestCommitmentTxInfo.RSMCTempAddressPubKey {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 
 		lastCommitmentTxInfo := &dao.CommitmentTransaction{}
@@ -270,7 +278,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxa(msg bean.RequestMessage, use
 	log.Println("close step 2 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 
 	if tool.CheckIsString(&msg.Data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -278,19 +286,19 @@ func (service *htlcCloseTxManager) OnAliceSignedCxa(msg bean.RequestMessage, use
 	_ = json.Unmarshal([]byte(msg.Data), &signedData)
 
 	if tool.CheckIsString(&signedData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, nil, err
 	}
 
 	p2pData := service.tempDataSendTo49PAtAliceSide[user.PeerId+"_"+signedData.ChannelId]
 	if &p2pData == nil {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&signedData.RsmcPartialSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.RsmcPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "rsmc_partial_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "rsmc_partial_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -298,7 +306,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxa(msg bean.RequestMessage, use
 
 	if tool.CheckIsString(&signedData.CounterpartyPartialSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.CounterpartyPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "counterparty_partial_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "counterparty_partial_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -313,7 +321,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxa(msg bean.RequestMessage, use
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, signedData.ChannelId, user.PeerId)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	if len(latestCommitmentTxInfo.RSMCTxHex) > 0 {
@@ -388,6 +396,7 @@ func (service *htlcCloseTxManager) OnObdOfBobGet49PData(data string, user bean.U
 	}
 	messageHash := messageService.saveMsgUseTx(tx, senderPeerId, user.PeerId, data)
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	closeHtlcTxOfWs := &bean.AliceRequestCloseHtlcCurrTxOfP2pToBobClient{}
 	closeHtlcTxOfWs.C4aRsmcPartialSignedData = closeHtlcTxOfP2p.RsmcPartialSignedData
@@ -410,7 +419,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	log.Println("close step 4 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 	reqData := &bean.HtlcBobSignCloseCurrTx{}
 	err = json.Unmarshal([]byte(msg.Data), reqData)
@@ -421,7 +430,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 
 	// region check data
 	if tool.CheckIsString(&reqData.MsgHash) == false {
-		err = errors.New(enum.Tips_common_empty + "msg_hash")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg_hash")
 		log.Println(err)
 		return nil, err
 	}
@@ -435,33 +444,33 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 
 	message, err := messageService.getMsgUseTx(tx, reqData.MsgHash)
 	if err != nil {
-		return nil, errors.New(enum.Tips_common_wrong + "msg_hash")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "msg_hash")
 	}
 	if message.Receiver != user.PeerId {
-		return nil, errors.New(enum.Tips_rsmc_notTargetUser)
+		return nil, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	dataFrom49POfP2p := bean.AliceRequestCloseHtlcCurrTxOfP2p{}
 	_ = json.Unmarshal([]byte(message.Data), &dataFrom49POfP2p)
 
 	if tool.CheckIsString(&reqData.CurrTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_temp_address_pub_key")
 		log.Println(err)
 		return nil, err
 	}
 
 	if tool.CheckIsString(&reqData.LastHtlcTempAddressForHtnxPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_htlc_temp_address_for_htnx_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_htlc_temp_address_for_htnx_private_key")
 		log.Println(err)
 		return nil, err
 	}
 	if tool.CheckIsString(&reqData.LastHtlcTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_htlc_temp_address_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_htlc_temp_address_private_key")
 		log.Println(err)
 		return nil, err
 	}
 	if tool.CheckIsString(&reqData.LastRsmcTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_rsmc_temp_address_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_rsmc_temp_address_private_key")
 		log.Println(err)
 		return nil, err
 	}
@@ -500,14 +509,14 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	if latestCommitmentTxInfo.TxType == dao.CommitmentTransactionType_Htlc {
 		if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Htlc_GetH &&
 			latestCommitmentTxInfo.CurrState != dao.TxInfoState_Htlc_GetR {
-			return nil, errors.New(enum.Tips_channel_wrongLatestCommitmentTxState + ": should be 11 or 12")
+			return nil, enum.NewError(enum.ErrorCode_channel_wrongLatestCommitmentTxState, ": should be 11 or 12")
 		}
 	}
 
 	if latestCommitmentTxInfo.TxType == dao.CommitmentTransactionType_Rsmc {
 		if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Create {
 			//如果是第二次收到的请求，前面的请求有异常了
-			return nil, errors.New(enum.Tips_channel_wrongLatestCommitmentTxState + ": should be 5")
+			return nil, enum.NewError(enum.ErrorCode_channel_wrongLatestCommitmentTxState, ": should be 5")
 		}
 	}
 
@@ -515,7 +524,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	if latestCommitmentTxInfo.TxType == dao.CommitmentTransactionType_Htlc {
 		_, err = omnicore.GetPubKeyFromWifAndCheck(reqData.LastRsmcTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		if err != nil {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, reqData.LastRsmcTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, reqData.LastRsmcTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 		_, err = omnicore.GetPubKeyFromWifAndCheck(reqData.LastHtlcTempAddressPrivateKey, latestCommitmentTxInfo.HTLCTempAddressPubKey)
 		if err != nil {
@@ -534,7 +543,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 		}
 	} else {
 		if reqData.CurrTempAddressPubKey != latestCommitmentTxInfo.RSMCTempAddressPubKey {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 		lastCommitTxInfo := dao.CommitmentTransaction{}
 		err = tx.One("Id", latestCommitmentTxInfo.LastCommitmentTxId, &lastCommitTxInfo)
@@ -588,7 +597,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	// get the funding transaction
 	fundingTransaction := getFundingTransactionByChannelId(tx, channelInfo.ChannelId, user.PeerId)
 	if fundingTransaction == nil {
-		return nil, errors.New(enum.Tips_funding_notFoundFundAssetTx)
+		return nil, enum.NewError(enum.ErrorCode_funding_notFoundFundAssetTx)
 	}
 
 	// region 签名requester的承诺交易
@@ -599,7 +608,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	if tool.CheckIsString(&dataFrom49POfP2p.RsmcPartialSignedData.Hex) {
 		signedRsmcHex = reqData.C4aRsmcCompleteSignedHex
 		if pass, _ := omnicore.CheckMultiSign(signedRsmcHex, 2); pass == false {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c4a_rsmc_complete_signed_hex"))
+			return nil, enum.NewError(enum.ErrorCode_common_failToSign, "c4a_rsmc_complete_signed_hex")
 		}
 		aliceRsmcTxId = omnicore.GetTxId(signedRsmcHex)
 
@@ -621,7 +630,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 	if tool.CheckIsString(&dataFrom49POfP2p.CounterpartyPartialSignedData.Hex) {
 		signedToOtherHex := reqData.C4aCounterpartyCompleteSignedHex
 		if pass, _ := omnicore.CheckMultiSign(signedToOtherHex, 2); pass == false {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c4a_counterparty_complete_signed_hex"))
+			return nil, enum.NewError(enum.ErrorCode_common_failToSign, "c4a_counterparty_complete_signed_hex")
 		}
 		dataSendTo50P.C4aCounterpartyCompleteSignedHex = signedToOtherHex
 	}
@@ -724,12 +733,12 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			amountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&aliceRsmcRedeemScript)
 		if err != nil {
 			log.Println(err)
-			return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_failToCreate, "rd raw transaction"))
+			return nil, enum.NewError(enum.ErrorCode_rsmc_failToCreate, "rd raw transaction")
 		}
 		c2aRdRawData := bean.NeedClientSignTxData{}
 		c2aRdRawData.Hex = senderRdTx["hex"].(string)
@@ -751,6 +760,7 @@ func (service *htlcCloseTxManager) OnBobSignCloseHtlcRequest(msg bean.RequestMes
 		log.Println(err)
 		return nil, err
 	}
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	// 缓存数据
 	if service.tempDataSendTo50PAtBobSide == nil {
@@ -775,7 +785,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 	log.Println("close step 5 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 
 	if tool.CheckIsString(&msg.Data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -784,7 +794,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 	_ = json.Unmarshal([]byte(msg.Data), &signedData)
 
 	if tool.CheckIsString(&signedData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -792,12 +802,12 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 	//得到step4缓存的数据
 	p2pData := service.tempDataSendTo50PAtBobSide[user.PeerId+"_"+signedData.ChannelId]
 	if len(p2pData.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&p2pData.C4bRsmcPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.C4bRsmcPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c4b_rsmc_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c4b_rsmc_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -805,7 +815,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 
 	if tool.CheckIsString(&p2pData.C4bCounterpartyPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.C4bCounterpartyPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_counterparty_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_counterparty_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -813,7 +823,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 
 	if tool.CheckIsString(&p2pData.C4aRdPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.C4aRdPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c4a_rd_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c4a_rd_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -821,13 +831,13 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 
 	if tool.CheckIsString(&signedData.C4aBrPartialSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedData.C4aBrPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "c2a_br_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c2a_br_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
 
 		if signedData.C4aBrId == 0 {
-			err = errors.New(enum.Tips_common_wrong + "c4a_br_id")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c4a_br_id")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -842,7 +852,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, signedData.ChannelId, user.PeerId)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	senderPeerId := latestCommitmentTxInfo.PeerIdA
@@ -850,7 +860,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxb(msg bean.RequestMessage, user
 		senderPeerId = latestCommitmentTxInfo.PeerIdB
 	}
 	if senderPeerId != msg.RecipientUserPeerId {
-		return nil, nil, errors.New(enum.Tips_common_userNotInTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_common_userNotInTx)
 	}
 
 	if len(signedData.C4bRsmcPartialSignedHex) > 0 {
@@ -917,13 +927,14 @@ func (service *htlcCloseTxManager) OnObdOfAliceGet50PData(data string, user bean
 	}
 
 	if channelInfo.CurrState != bean.ChannelState_HtlcTx {
-		return nil, false, errors.New(fmt.Sprintf(enum.Tips_htlc_wrongChannelState, channelInfo.CurrState, bean.ChannelState_HtlcTx))
+		return nil, false, enum.NewError(enum.ErrorCode_htlc_wrongChannelState, channelInfo.CurrState, bean.ChannelState_HtlcTx)
 	}
 
 	channelInfo.CurrState = bean.ChannelState_NewTx
 	_ = tx.Update(channelInfo)
 
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	if service.tempDataFromTo50PAtAliceSide == nil {
 		service.tempDataFromTo50PAtAliceSide = make(map[string]bean.CloseeSignCloseHtlcTxOfP2p)
@@ -956,12 +967,12 @@ func (service *htlcCloseTxManager) OnAliceSignedCxb(msg bean.RequestMessage, use
 
 	dataFromP2p50P := service.tempDataFromTo50PAtAliceSide[user.PeerId+"_"+aliceSignedData.ChannelId]
 	if len(dataFromP2p50P.ChannelId) == 0 {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if tool.CheckIsString(&dataFromP2p50P.C4bRsmcPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedData.C4bRsmcCompleteSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c4b_rsmc_complete_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c4b_rsmc_complete_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
@@ -970,7 +981,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxb(msg bean.RequestMessage, use
 
 	if tool.CheckIsString(&dataFromP2p50P.C4bCounterpartyPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedData.C4bCounterpartyCompleteSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed C4bCounterpartyCompleteSignedHex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed C4bCounterpartyCompleteSignedHex")
 			log.Println(err)
 			return nil, err
 		}
@@ -979,7 +990,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxb(msg bean.RequestMessage, use
 
 	if tool.CheckIsString(&dataFromP2p50P.C4aRdPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedData.C4aRdCompleteSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c4a_rd_complete_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c4a_rd_complete_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
@@ -1062,7 +1073,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxb(msg bean.RequestMessage, use
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			latestCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&cnbRsmcRedeemScript)
 		if err != nil {
@@ -1127,7 +1138,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 
 	dataFromP2p50P := service.tempDataFromTo50PAtAliceSide[user.PeerId+"_"+aliceSignedRdTxForCnb.ChannelId]
 	if len(dataFromP2p50P.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	//region 检测传入数据
@@ -1182,28 +1193,28 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 	var c2aSignedRsmcHex = dataFromP2p50P.C4aRsmcCompleteSignedHex
 	if tool.CheckIsString(&c2aSignedRsmcHex) {
 		if pass, _ := omnicore.CheckMultiSign(c2aSignedRsmcHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4a_rsmc_complete_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4a_rsmc_complete_signed_hex")
 		}
 	}
 
 	var signedToCounterpartyHex = dataFromP2p50P.C4aCounterpartyCompleteSignedHex
 	if tool.CheckIsString(&signedToCounterpartyHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedToCounterpartyHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4a_counterparty_complete_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4a_counterparty_complete_signed_hex")
 		}
 	}
 
 	var aliceRdHex = dataFromP2p50P.C4aRdPartialSignedData.Hex
 	if tool.CheckIsString(&aliceRdHex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceRdHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4a_rd_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4a_rd_signed_hex")
 		}
 	}
 
 	var bobRsmcHex = dataFromP2p50P.C4bRsmcPartialSignedData.Hex
 	if tool.CheckIsString(&bobRsmcHex) {
 		if pass, _ := omnicore.CheckMultiSign(bobRsmcHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4b_rsmc_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4b_rsmc_signed_hex")
 		}
 	}
 
@@ -1217,7 +1228,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 	var c2bToCounterpartyTxHex = dataFromP2p50P.C4bCounterpartyPartialSignedData.Hex
 	if len(c2bToCounterpartyTxHex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(c2bToCounterpartyTxHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4b_counterparty_tx_data_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4b_counterparty_tx_data_hex")
 		}
 	}
 	//endregion
@@ -1307,7 +1318,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 	cnbSignedRsmcHex := dataFromP2p50P.C4bRsmcPartialSignedData.Hex
 	if len(cnbSignedRsmcHex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(cnbSignedRsmcHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c4b_rsmc_tx_data_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c4b_rsmc_tx_data_hex")
 		}
 		err = checkBobRsmcData(cnbSignedRsmcHex, cnbRsmcMultiAddress, latestCommitmentTxInfo)
 		if err != nil {
@@ -1336,7 +1347,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			latestCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&cnbRsmcRedeemScript)
 		if err != nil {
@@ -1362,6 +1373,7 @@ func (service *htlcCloseTxManager) OnAliceSignedCxbBubTx(msg bean.RequestMessage
 
 	//endregion
 	_ = tx.Commit()
+	publishHtlcSettledEvents(user, *channelInfo, *latestCommitmentTxInfo, lastCommitmentTxInfo)
 
 	bobData.C4bCounterpartyCompleteSignedHex = c2bToCounterpartyTxHex
 	bobData.ChannelId = channelId
@@ -1416,12 +1428,12 @@ func (service *htlcCloseTxManager) OnBobSignedCxbSubTx(msg bean.RequestMessage,
 
 	dataFrom51P := service.tempDataFromTo51PAtBobSide[user.PeerId+"_"+bobSignedRdData.ChannelId]
 	if len(dataFrom51P.ChannelId) == 0 {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if tool.CheckIsString(&dataFrom51P.C4bRdPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(bobSignedRdData.C4bRdCompleteSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c4b_rd_complete_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c4b_rd_complete_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
@@ -1505,6 +1517,7 @@ func (service *htlcCloseTxManager) OnBobSignedCxbSubTx(msg bean.RequestMessage,
 
 	//同步通道信息到tracker
 	sendChannelStateToTracker(*channelInfo, *latestCommitmentTxInfo)
+	publishHtlcSettledEvents(user, *channelInfo, *latestCommitmentTxInfo, lastCommitmentTxInfo)
 
 	totalDurationObd += time.Now().Sub(beginTime).Milliseconds()
 	beginTime = time.Now()
@@ -5,10 +5,15 @@ import (
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/dao"
 	"github.com/omnilaboratory/obd/omnicore"
+	"github.com/shopspring/decimal"
 	"github.com/tidwall/gjson"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -141,7 +146,7 @@ func createHT1aForAlice(channelInfo dao.ChannelInfo, aliceDataJson bean.CreateHt
 		aliceHt1aMultiAddress,
 		propertyId,
 		amountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 		htlcTimeOut,
 		&aliceHtlcRedeemScript)
 	if err != nil {
@@ -221,7 +226,7 @@ func createHtlcLockByHForBobAtPayeeSide(channelInfo dao.ChannelInfo, aliceDataJs
 		htlcLockByHMultiAddress,
 		propertyId,
 		amountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 		0,
 		&aliceHtlcRedeemScript)
 	if err != nil {
@@ -311,7 +316,7 @@ func saveHtlcHeTxForPayee(tx storm.Node, channelInfo dao.ChannelInfo, commitment
 		c3bHeMultiAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 		0,
 		&c3bHlockRedeemScript)
 	if err != nil {
@@ -404,7 +409,7 @@ func createHtlcLockByHForBobAtPayerSide(channelInfo dao.ChannelInfo, bobDataJson
 		htlcLockByHMultiAddress,
 		propertyId,
 		amountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 		0,
 		&bobHtlcRedeemScript)
 	if err != nil {
@@ -493,3 +498,102 @@ func saveHtRD1a(tx storm.Node, aliceHt1aRDhex string, latestCommitmentTransactio
 	}
 	return htrd, nil
 }
+
+// getArrivedHtlcParts the htlcs of h received by the payee and not closed yet, the latest commitment tx of every
+// channel whose htlc ends at the payee. There are several of them when the payment is split over several routes.
+func getArrivedHtlcParts(tx storm.Node, h string, user bean.User) (parts []dao.CommitmentTransaction) {
+	var items []dao.CommitmentTransaction
+	_ = tx.Select(q.Eq("HtlcH", h), q.Eq("Owner", user.PeerId)).Find(&items)
+	checked := make(map[string]bool)
+	for _, item := range items {
+		if checked[item.ChannelId] {
+			continue
+		}
+		checked[item.ChannelId] = true
+		latest, err := getLatestCommitmentTxUseDbTx(tx, item.ChannelId, user.PeerId)
+		if err != nil || latest.HtlcH != h || latest.HtlcSender == user.PeerId ||
+			strings.HasSuffix(latest.HtlcRoutingPacket, latest.ChannelId) == false {
+			continue
+		}
+		if latest.CurrState == dao.TxInfoState_Htlc_GetH || latest.CurrState == dao.TxInfoState_Htlc_GetR {
+			parts = append(parts, *latest)
+		}
+	}
+	return parts
+}
+
+// getInvoiceByH the invoice of h created by the user, nil if there is none
+func getInvoiceByH(tx storm.Node, h string) *dao.InvoiceInfo {
+	invoice := &dao.InvoiceInfo{}
+	if len(h) == 0 || tx.One("H", h, invoice) != nil {
+		return nil
+	}
+	return invoice
+}
+
+// the amount the payee waits for, only by its own invoice, the amounts in the htlcs are set by the payer.
+// It is false if the payee has no invoice of h.
+func getExpectedHtlcAmount(tx storm.Node, h string) (float64, bool) {
+	if invoice := getInvoiceByH(tx, h); invoice != nil {
+		return invoice.Detail.Amount, true
+	}
+	return 0, false
+}
+
+// checkHtlcPartsArrived the payee releases r only when the amounts locked in the parts of h have reached the amount
+// of its invoice. A part claiming more to the payee than it locks is not counted.
+func checkHtlcPartsArrived(tx storm.Node, htlcTx dao.CommitmentTransaction, user bean.User) error {
+	amount, ok := getExpectedHtlcAmount(tx, htlcTx.HtlcH)
+	if ok == false {
+		// a single htlc without invoice is paid as agreed with the payer, the parts can not be checked
+		if htlcTx.HtlcTotalAmount > 0 {
+			return enum.NewError(enum.ErrorCode_htlc_unknownPaymentHash)
+		}
+		return nil
+	}
+	expected := decimal.NewFromFloat(amount)
+	arrived := decimal.Zero
+	for _, part := range getArrivedHtlcParts(tx, htlcTx.HtlcH, user) {
+		if part.HtlcAmountToPayee > part.AmountToHtlc {
+			continue
+		}
+		arrived = arrived.Add(decimal.NewFromFloat(part.AmountToHtlc))
+	}
+	if arrived.LessThan(expected) {
+		return enum.NewError(enum.ErrorCode_htlc_partsNotArrived, arrived.String(), expected.String())
+	}
+	return nil
+}
+
+// the payees waiting for the parts of multi-path payments, by user.PeerId + "_" + h
+var htlcPartsWatches = make(map[string]bool)
+var htlcPartsWatchLock sync.Mutex
+
+// watchHtlcParts the payee of a multi-path payment waits config.HtlcPartsTimeout for all the parts of h, then the parts
+// arrived are failed, and cancelled by closing their htlcs
+func watchHtlcParts(htlcTx dao.CommitmentTransaction, user bean.User) {
+	if htlcTx.HtlcTotalAmount == 0 || strings.HasSuffix(htlcTx.HtlcRoutingPacket, htlcTx.ChannelId) == false {
+		return
+	}
+	key := user.PeerId + "_" + htlcTx.HtlcH
+	htlcPartsWatchLock.Lock()
+	defer htlcPartsWatchLock.Unlock()
+	if htlcPartsWatches[key] {
+		return
+	}
+	htlcPartsWatches[key] = true
+	time.AfterFunc(config.HtlcPartsTimeout, func() {
+		htlcPartsWatchLock.Lock()
+		delete(htlcPartsWatches, key)
+		htlcPartsWatchLock.Unlock()
+
+		parts := HtlcBackwardTxService.GetPendingHtlcParts(htlcTx.HtlcH, user)
+		if len(parts) == 0 || checkHtlcPartsArrived(user.Db, parts[0], user) == nil {
+			return
+		}
+		for _, part := range parts {
+			publishHtlcEvent(user.PeerId, enum.EventType_HtlcFailed, part, enum.Tips_htlc_partsTimeOut)
+			RequireHtlcClose(part, enum.Tips_htlc_partsTimeOut, user)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+func TestCheckHtlcPartsArrived(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_htlc_parts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := storm.Open(dir + "/user_bob.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	user := bean.User{PeerId: "bob", Db: db}
+
+	invoice := &dao.InvoiceInfo{Invoice: "invoice", CreateAt: time.Now()}
+	invoice.Detail.H = "h"
+	invoice.Detail.Amount = 8
+	if err = db.Save(invoice); err != nil {
+		t.Fatal(err)
+	}
+	// the invoice saved before its h is indexed is found by h after the migration
+	if getInvoiceByH(db, "h") != nil {
+		t.Fatal("the h of the invoice is not indexed yet, want no invoice")
+	}
+	migrateInvoiceH(db)
+	if invoice = getInvoiceByH(db, "h"); invoice == nil || invoice.Detail.Amount != 8 {
+		t.Fatalf("got the invoice %v of h, want the invoice of 8", invoice)
+	}
+	now := time.Now()
+	saveTx := func(channelId, path string, amount float64, state dao.TxInfoState, createAt time.Time) dao.CommitmentTransaction {
+		htlcTx := dao.CommitmentTransaction{ChannelId: channelId, Owner: "bob", HtlcH: "h", HtlcSender: "carol",
+			HtlcRoutingPacket: path, AmountToHtlc: amount, HtlcAmountToPayee: amount, HtlcTotalAmount: 8, CurrState: state, CreateAt: createAt}
+		if err := db.Save(&htlcTx); err != nil {
+			t.Fatal(err)
+		}
+		return htlcTx
+	}
+	saveTx("c1", "a,c1", 5, dao.TxInfoState_Create, now.Add(-time.Minute))
+	part := saveTx("c1", "a,c1", 5, dao.TxInfoState_Htlc_GetH, now)
+	// bob forwards the htlc of the channel to the next node, it is not a part
+	saveTx("c2", "c2,b", 3, dao.TxInfoState_Htlc_GetH, now)
+	if err = checkHtlcPartsArrived(db, part, user); err == nil {
+		t.Fatal("5 of 8 arrived, want an error")
+	}
+	// a part claims more to the payee than it locks, it is not counted
+	fake := dao.CommitmentTransaction{ChannelId: "c4", Owner: "bob", HtlcH: "h", HtlcSender: "carol", HtlcRoutingPacket: "c4",
+		AmountToHtlc: 1, HtlcAmountToPayee: 3, HtlcTotalAmount: 8, CurrState: dao.TxInfoState_Htlc_GetH, CreateAt: now}
+	if err = db.Save(&fake); err != nil {
+		t.Fatal(err)
+	}
+	if err = checkHtlcPartsArrived(db, part, user); err == nil {
+		t.Fatal("the part of c4 locks 1 but claims 3, want an error")
+	}
+	_ = db.DeleteStruct(&fake)
+	// the total amount of the parts is not trusted without the invoice of the payee
+	noInvoice := part
+	noInvoice.HtlcH = "h2"
+	if err = checkHtlcPartsArrived(db, noInvoice, user); err == nil {
+		t.Fatal("the payee has no invoice of h2, want an error")
+	}
+
+	// the parts do not arrive in time, obd closes the part of the admin user
+	partsTimeout, closeHtlcByObd := config.HtlcPartsTimeout, CloseHtlcByObd
+	defer func() { config.HtlcPartsTimeout, CloseHtlcByObd = partsTimeout, closeHtlcByObd }()
+	config.HtlcPartsTimeout = 10 * time.Millisecond
+	closed := make(chan dao.CommitmentTransaction, 1)
+	CloseHtlcByObd = func(htlcTx dao.CommitmentTransaction, user bean.User) { closed <- htlcTx }
+	admin := user
+	admin.IsAdmin = true
+	watchHtlcParts(part, admin)
+	select {
+	case htlcTx := <-closed:
+		if htlcTx.Id != part.Id {
+			t.Fatalf("got the htlc %d closed, want the part %d", htlcTx.Id, part.Id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the part is not closed after the timeout")
+	}
+
+	saveTx("c3", "c3", 3, dao.TxInfoState_Htlc_GetH, now)
+	if err = checkHtlcPartsArrived(db, part, user); err != nil {
+		t.Fatal(err)
+	}
+	if parts := HtlcBackwardTxService.GetPendingHtlcParts("h", user); len(parts) != 2 {
+		t.Fatalf("got %d pending parts, want 2", len(parts))
+	}
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	conn2tracker "github.com/omnilaboratory/obd/conn"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/tool"
+)
+
+type htlcFailTxManager struct{}
+
+// HtlcFailTxService the failure of an htlc goes back hop by hop to the payer, and every sender of the failed htlcs
+// closes its htlc by 100049 to get back the amount. The failure is encrypted to the payer if the htlc has an onion.
+var HtlcFailTxService htlcFailTxManager
+
+// SendFailToPreviousNode 100047 the receiver of the htlc can not forward it, or the payee rejects it.
+// The failure is sent to the sender of the htlc by 47.
+func (service *htlcFailTxManager) SendFailToPreviousNode(msg bean.RequestMessage, user bean.User) (toSender *bean.HtlcFail, err error) {
+	if tool.CheckIsString(&msg.Data) == false {
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msg data")
+	}
+	reqData := &bean.HtlcSendFail{}
+	if err = json.Unmarshal([]byte(msg.Data), reqData); err != nil {
+		return nil, err
+	}
+	if tool.CheckIsString(&reqData.ChannelId) == false {
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
+	}
+	if len(enum.GetErrorTips(reqData.FailureCode)) == 0 {
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "failure_code")
+	}
+	if len(reqData.Reason) == 0 {
+		reqData.Reason = enum.GetErrorTips(reqData.FailureCode)
+	}
+
+	htlcTx := getHtlcToFail(reqData.ChannelId, "", user)
+	if htlcTx == nil || htlcTx.HtlcSender == user.PeerId {
+		return nil, enum.NewError(enum.ErrorCode_htlc_notFoundHtlcToFail, reqData.ChannelId)
+	}
+	if msg.RecipientUserPeerId != htlcTx.HtlcSender {
+		return nil, enum.NewError(enum.ErrorCode_htlc_notTheHltcSender)
+	}
+
+	toSender = &bean.HtlcFail{ChannelId: htlcTx.ChannelId, H: htlcTx.HtlcH}
+	if len(htlcTx.HtlcOnionSecret) > 0 {
+		failure, _ := json.Marshal(reqData.HtlcFailure)
+		toSender.Onion, err = tool.CreateOnionFailure(htlcTx.HtlcOnionSecret, failure)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		toSender.HtlcFailure = reqData.HtlcFailure
+	}
+	_ = user.Db.UpdateField(htlcTx, "HtlcFailReason", reqData.Reason)
+	log.Println("fail the htlc", htlcTx.HtlcH, "of", htlcTx.ChannelId, reqData.Reason)
+
+	// the tracker only counts the failure reported by the obd of the channel, the hop is one end of the next channel
+	if len(reqData.FailedChannelId) > 0 {
+		channelInfo := &dao.ChannelInfo{}
+		if user.Db.Select(q.Eq("ChannelId", reqData.FailedChannelId)).First(channelInfo) == nil {
+			reportChannelFailure(htlcTx.HtlcH, htlcTx.HtlcRoutingPacket, reqData.FailedChannelId)
+		}
+	}
+	return toSender, nil
+}
+
+// OnGetFailAtSenderSide 47 the sender of the failed htlc gets the failure, and closes the htlc by 100049 after the
+// htlc_close_required event. A hop passes the failure on to the sender of its previous htlc by toPrevious, the payer
+// reads the failure and retries the payment when its htlc is closed.
+func (service *htlcFailTxManager) OnGetFailAtSenderSide(data string, user bean.User) (failure *bean.HtlcFail, failedHtlc *dao.CommitmentTransaction, toPrevious *bean.RequestMessage, err error) {
+	failure = &bean.HtlcFail{}
+	if err = json.Unmarshal([]byte(data), failure); err != nil {
+		return nil, nil, nil, err
+	}
+	failedHtlc = getHtlcToFail(failure.ChannelId, failure.H, user)
+	if failedHtlc == nil || failedHtlc.HtlcSender != user.PeerId {
+		return nil, nil, nil, enum.NewError(enum.ErrorCode_htlc_notFoundHtlcToFail, failure.ChannelId)
+	}
+
+	previousHtlc := getPreviousHtlcToFail(*failedHtlc, user)
+	if previousHtlc == nil {
+		// the payer reads the failure by the secrets of the onion, the htlc is closed even if the failure is unreadable
+		if len(failure.Onion) > 0 {
+			failure.HtlcFailure = bean.HtlcFailure{FailureCode: enum.ErrorCode_htlc_failedByNextHops, Reason: enum.Tips_htlc_failedByNextHops}
+			_, message, err := tool.ParseOnionFailure(strings.Split(failedHtlc.HtlcOnionSecrets, ","), failure.Onion)
+			if err == nil {
+				err = json.Unmarshal(message, &failure.HtlcFailure)
+			}
+			if err != nil {
+				log.Println(err)
+			}
+		}
+		PaymentService.onHtlcFailed(failedHtlc.HtlcH, failure.HtlcFailure, user)
+		_ = user.Db.UpdateField(failedHtlc, "HtlcFailReason", failure.Reason)
+		RequireHtlcClose(*failedHtlc, failure.Reason, user)
+		return failure, failedHtlc, nil, nil
+	}
+
+	reason := failure.Reason
+	toPreviousData := bean.HtlcFail{ChannelId: previousHtlc.ChannelId, H: failure.H, HtlcFailure: failure.HtlcFailure}
+	if len(failure.Onion) > 0 {
+		if len(previousHtlc.HtlcOnionSecret) == 0 {
+			return nil, nil, nil, enum.NewError(enum.ErrorCode_htlc_wrongOnion, "no secret of the previous htlc")
+		}
+		toPreviousData.Onion, err = tool.WrapOnionFailure(previousHtlc.HtlcOnionSecret, failure.Onion)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		reason = enum.Tips_htlc_failedByNextHops
+	}
+	_ = user.Db.UpdateField(failedHtlc, "HtlcFailReason", reason)
+	_ = user.Db.UpdateField(previousHtlc, "HtlcFailReason", reason)
+
+	toPrevious = &bean.RequestMessage{Type: enum.MsgType_HTLC_FailHtlc_47}
+	toPrevious.RecipientUserPeerId = previousHtlc.HtlcSender
+	toPrevious.RecipientNodePeerId, _ = GetUserP2pNodeId(previousHtlc.HtlcSender)
+	toPrevious.SenderNodePeerId = user.P2PLocalPeerId
+	toPrevious.SenderUserPeerId = user.PeerId
+	marshal, _ := json.Marshal(toPreviousData)
+	toPrevious.Data = string(marshal)
+	RequireHtlcClose(*failedHtlc, reason, user)
+	return failure, failedHtlc, toPrevious, nil
+}
+
+// GetForwardFailure the failure of the htlc which the hop can not forward to the next channel, by the error of the
+// forwarding. The error is nil if the next node is not found.
+func (service *htlcFailTxManager) GetForwardFailure(htlcTx dao.CommitmentTransaction, err error, user bean.User) bean.HtlcFailure {
+	nextChannelId := getNextChannelOfHtlc(htlcTx)
+	if len(nextChannelId) == 0 {
+		return bean.HtlcFailure{FailureCode: enum.ErrorCode_htlc_unknownPaymentHash, Reason: enum.Tips_htlc_unknownPaymentHash}
+	}
+	failure := bean.HtlcFailure{FailedChannelId: nextChannelId}
+	code := enum.ErrorCodeOf(err)
+	switch {
+	case err == nil || code == enum.ErrorCode_user_notExistOrOnline:
+		nextPeerId := ""
+		channelInfo := &dao.ChannelInfo{}
+		if user.Db.Select(q.Eq("ChannelId", nextChannelId)).First(channelInfo) == nil {
+			nextPeerId = channelInfo.PeerIdA
+			if nextPeerId == user.PeerId {
+				nextPeerId = channelInfo.PeerIdB
+			}
+		}
+		failure.FailureCode = enum.ErrorCode_htlc_unknownNextPeer
+		failure.Reason = fmt.Sprintf(enum.Tips_htlc_unknownNextPeer, nextPeerId)
+		if len(nextPeerId) > 0 {
+			failure.FailedChannelId = ""
+			failure.FailedPeerId = nextPeerId
+		}
+	case code == enum.ErrorCode_htlc_insufficientBalance || code == enum.ErrorCode_htlc_expiryTooSoon:
+		failure.FailureCode = code
+		failure.Reason = err.Error()
+	default:
+		failure.FailureCode = enum.ErrorCode_htlc_forwardFailed
+		failure.Reason = fmt.Sprintf(enum.Tips_htlc_forwardFailed, err.Error())
+	}
+	return failure
+}
+
+// CheckHtlcExpiry the htlc to the next channel must expire before the htlc of the hop, so that the hop can get back
+// the amount from the previous channel after it pays the next one
+func (service *htlcFailTxManager) CheckHtlcExpiry(htlcTx dao.CommitmentTransaction, forwardCltvExpiry int) *bean.HtlcFailure {
+	blockHeight, err := conn2tracker.GetBlockCount(context.Background())
+	if err != nil {
+		blockHeight = 0
+	}
+	return checkHtlcExpiry(htlcTx, forwardCltvExpiry, blockHeight)
+}
+
+func checkHtlcExpiry(htlcTx dao.CommitmentTransaction, forwardCltvExpiry int, blockHeight int) *bean.HtlcFailure {
+	remaining := htlcTx.HtlcCltvExpiry
+	if blockHeight > 0 && htlcTx.BeginBlockHeight > 0 {
+		remaining -= blockHeight - htlcTx.BeginBlockHeight
+	}
+	if forwardCltvExpiry > 0 && forwardCltvExpiry < remaining {
+		return nil
+	}
+	return &bean.HtlcFailure{
+		FailureCode:     enum.ErrorCode_htlc_expiryTooSoon,
+		Reason:          fmt.Sprintf(enum.Tips_htlc_expiryTooSoon, forwardCltvExpiry),
+		FailedChannelId: getNextChannelOfHtlc(htlcTx)}
+}
+
+// CheckHtlcAtPayeeSide the payee rejects the htlc of an expired invoice
+func (service *htlcFailTxManager) CheckHtlcAtPayeeSide(htlcTx dao.CommitmentTransaction, user bean.User) *bean.HtlcFailure {
+	invoice := getInvoiceByH(user.Db, htlcTx.HtlcH)
+	if invoice == nil {
+		return nil
+	}
+	expiryTime := time.Time(invoice.Detail.ExpiryTime)
+	if expiryTime.IsZero() == false && time.Now().After(expiryTime) {
+		return &bean.HtlcFailure{FailureCode: enum.ErrorCode_htlc_invoiceExpired, Reason: enum.Tips_htlc_invoiceExpired}
+	}
+	return nil
+}
+
+// the htlc of the channel waiting for r, h is empty for the latest htlc of the channel
+func getHtlcToFail(channelId, h string, user bean.User) *dao.CommitmentTransaction {
+	if user.Db == nil {
+		return nil
+	}
+	htlcTx, err := getLatestCommitmentTxUseDbTx(user.Db, channelId, user.PeerId)
+	if err != nil || htlcTx.TxType != dao.CommitmentTransactionType_Htlc || htlcTx.CurrState != dao.TxInfoState_Htlc_GetH {
+		return nil
+	}
+	if len(h) > 0 && htlcTx.HtlcH != h {
+		return nil
+	}
+	return htlcTx
+}
+
+// the htlc which the hop received and forwarded by the failed htlc, nil at the payer
+func getPreviousHtlcToFail(failedHtlc dao.CommitmentTransaction, user bean.User) *dao.CommitmentTransaction {
+	var htlcTxs []dao.CommitmentTransaction
+	_ = user.Db.Select(q.Eq("HtlcH", failedHtlc.HtlcH), q.Eq("CurrState", dao.TxInfoState_Htlc_GetH)).Find(&htlcTxs)
+	for _, item := range htlcTxs {
+		if item.HtlcSender == user.PeerId || getNextChannelOfHtlc(item) != failedHtlc.ChannelId {
+			continue
+		}
+		if htlcTx := getHtlcToFail(item.ChannelId, item.HtlcH, user); htlcTx != nil && htlcTx.Id == item.Id {
+			return htlcTx
+		}
+	}
+	return nil
+}
+
+// the channel after the channel of the htlc in its routing packet, empty at the payee
+func getNextChannelOfHtlc(htlcTx dao.CommitmentTransaction) string {
+	channelIds := strings.Split(htlcTx.HtlcRoutingPacket, ",")
+	for i := 0; i < len(channelIds)-1; i++ {
+		if channelIds[i] == htlcTx.ChannelId {
+			return channelIds[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/tidwall/gjson"
+)
+
+func TestHtlcFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_htlc_fail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	trackerHosts := config.TrackerHosts
+	config.TrackerHosts = nil
+	defer func() { config.TrackerHosts = trackerHosts }()
+	trackerChan := TrackerChan
+	TrackerChan = make(chan []byte, 8)
+	defer func() { TrackerChan = trackerChan }()
+
+	users := make(map[string]bean.User)
+	for _, peerId := range []string{"alice", "bob", "carol"} {
+		db, err := storm.Open(dir + "/user_" + peerId + ".db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		users[peerId] = bean.User{PeerId: peerId, Db: db}
+	}
+	alice, bob, carol := users["alice"], users["bob"], users["carol"]
+
+	// alice pays carol by c1 and c2, bob and carol share the secrets of the onion with alice
+	bobSecret, carolSecret := strings.Repeat("11", 32), strings.Repeat("22", 32)
+	htlcTxs := []struct {
+		user   bean.User
+		htlcTx dao.CommitmentTransaction
+	}{
+		{alice, dao.CommitmentTransaction{ChannelId: "c1", HtlcSender: "alice", HtlcRoutingPacket: "c1,c2", HtlcOnionSecrets: bobSecret + "," + carolSecret}},
+		{bob, dao.CommitmentTransaction{ChannelId: "c1", HtlcSender: "alice", HtlcRoutingPacket: "c1,c2", HtlcOnionSecret: bobSecret}},
+		{bob, dao.CommitmentTransaction{ChannelId: "c2", HtlcSender: "bob", HtlcRoutingPacket: "c1,c2"}},
+		{carol, dao.CommitmentTransaction{ChannelId: "c2", HtlcSender: "bob", HtlcRoutingPacket: "c2", HtlcOnionSecret: carolSecret}},
+	}
+	for i := range htlcTxs {
+		item := &htlcTxs[i]
+		item.htlcTx.Owner = item.user.PeerId
+		item.htlcTx.HtlcH = "h"
+		item.htlcTx.HtlcCltvExpiry = 10
+		item.htlcTx.BeginBlockHeight = 100
+		item.htlcTx.TxType = dao.CommitmentTransactionType_Htlc
+		item.htlcTx.CurrState = dao.TxInfoState_Htlc_GetH
+		item.htlcTx.CreateAt = time.Now()
+		if err = item.user.Db.Save(&item.htlcTx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requestData := bean.HtlcRequestFindPath{MaxAttempts: 3, FeeLimit: 0.01}
+	pathInfo := bean.HtlcRequestFindPathInfo{H: "h", RecipientUserPeerId: "carol", PropertyId: 1, Amount: 1}
+	if _, err = PaymentService.start(requestData, pathInfo, alice); err != nil {
+		t.Fatal(err)
+	}
+	_ = PaymentService.onPathFound("h", "c1,c2", "", "", 0.001, alice)
+
+	// the next htlc must expire before the htlc of the hop
+	if failure := checkHtlcExpiry(htlcTxs[1].htlcTx, 9, 0); failure != nil {
+		t.Fatalf("got %+v, want no failure", *failure)
+	}
+	failure := checkHtlcExpiry(htlcTxs[1].htlcTx, 9, 101)
+	if failure == nil || failure.FailureCode != enum.ErrorCode_htlc_expiryTooSoon || failure.FailedChannelId != "c2" {
+		t.Fatalf("got %+v, want the expiry too soon of c2", failure)
+	}
+
+	// carol fails the htlc of c2, the failure is encrypted to alice
+	sendFail, _ := json.Marshal(bean.HtlcSendFail{ChannelId: "c2", HtlcFailure: *failure})
+	toBob, err := HtlcFailTxService.SendFailToPreviousNode(bean.RequestMessage{RecipientUserPeerId: "bob", Data: string(sendFail)}, carol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(toBob.Onion) == 0 || toBob.FailureCode != 0 {
+		t.Fatalf("got the failure %+v in plain text, want it in the onion", toBob.HtlcFailure)
+	}
+
+	// every sender on the way closes its failed htlc, obd closes the htlcs of the admin users
+	closeHtlcByObd := CloseHtlcByObd
+	defer func() { CloseHtlcByObd = closeHtlcByObd }()
+	closed := make(chan dao.CommitmentTransaction, 2)
+	CloseHtlcByObd = func(htlcTx dao.CommitmentTransaction, user bean.User) { closed <- htlcTx }
+	bob.IsAdmin, alice.IsAdmin = true, true
+
+	// bob can not read the failure, and passes it on to alice
+	toBobData, _ := json.Marshal(toBob)
+	readByBob, failedHtlc, toAlice, err := HtlcFailTxService.OnGetFailAtSenderSide(string(toBobData), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failedHtlc.ChannelId != "c2" || toAlice == nil || toAlice.RecipientUserPeerId != "alice" || toAlice.Type != enum.MsgType_HTLC_FailHtlc_47 {
+		t.Fatalf("got the failed htlc of %s and %+v, want c2 and the failure to alice", failedHtlc.ChannelId, toAlice)
+	}
+	if readByBob.FailureCode != 0 || gjson.Get(toAlice.Data, "channel_id").String() != "c1" {
+		t.Fatalf("bob got the failure %+v and sends %s, want nothing and the failure of c1", readByBob.HtlcFailure, toAlice.Data)
+	}
+
+	// alice reads the failing channel, and retries the payment without it when her htlc is closed
+	readByAlice, failedHtlc, toPrevious, err := HtlcFailTxService.OnGetFailAtSenderSide(toAlice.Data, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toPrevious != nil || failedHtlc.ChannelId != "c1" || readByAlice.FailureCode != enum.ErrorCode_htlc_expiryTooSoon {
+		t.Fatalf("got %+v, want the expiry too soon at the payer", readByAlice.HtlcFailure)
+	}
+	closedChannels := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		select {
+		case htlcTx := <-closed:
+			closedChannels[htlcTx.ChannelId] = htlcTx.Owner
+		case <-time.After(time.Second):
+			t.Fatalf("got the closed htlcs %v, want the htlcs of c1 and c2", closedChannels)
+		}
+	}
+	if closedChannels["c1"] != "alice" || closedChannels["c2"] != "bob" {
+		t.Fatalf("got the closed htlcs %v, want c1 by alice and c2 by bob", closedChannels)
+	}
+
+	failedHtlc.HtlcFailReason = readByAlice.Reason
+	PaymentService.onHtlcClosed(*failedHtlc, alice)
+	if failureReport := gjson.ParseBytes(<-TrackerChan); failureReport.Get("data.failed_channel_id").String() != "c2" {
+		t.Fatalf("got %s, want the failure of c2 to the tracker", failureReport.Raw)
+	}
+	pathRequest := gjson.ParseBytes(<-TrackerChan).Get("data")
+	if excluded := pathRequest.Get("excluded_channels").Array(); len(excluded) != 1 || excluded[0].String() != "c2" {
+		t.Fatalf("got the excluded channels %v, want [c2]", excluded)
+	}
+	payment, _ := PaymentService.GetPayment(`{"h":"h"}`, alice)
+	if attempt := payment.Attempts[0]; attempt.State != dao.PaymentState_Failed || attempt.Error != readByAlice.Reason {
+		t.Fatalf("got the attempt %s by %s, want failed by %s", attempt.State, attempt.Error, readByAlice.Reason)
+	}
+
+	// a hop which can not find the next peer fails the htlc with it
+	if failure := HtlcFailTxService.GetForwardFailure(htlcTxs[1].htlcTx, nil, bob); failure.FailureCode != enum.ErrorCode_htlc_unknownNextPeer {
+		t.Fatalf("got %+v, want the unknown next peer", failure)
+	}
+	forwardErr := enum.NewError(enum.ErrorCode_htlc_insufficientBalance)
+	if failure := HtlcFailTxService.GetForwardFailure(htlcTxs[1].htlcTx, forwardErr, bob); failure.FailureCode != enum.ErrorCode_htlc_insufficientBalance || failure.FailedChannelId != "c2" {
+		t.Fatalf("got %+v, want the insufficient balance of c2", failure)
+	}
+	if failure := HtlcFailTxService.GetForwardFailure(htlcTxs[3].htlcTx, nil, carol); failure.FailureCode != enum.ErrorCode_htlc_unknownPaymentHash {
+		t.Fatalf("got %+v, want the unknown h at the payee", failure)
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -42,7 +43,7 @@ var HtlcForwardTxService htlcForwardTxManager
 func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage, user bean.User) (data interface{}, err error) {
 
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, errors.New(enum.Tips_common_empty + "msd data")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msd data")
 	}
 
 	requestData := &bean.HtlcRequestInvoice{}
@@ -64,7 +65,7 @@ func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage,
 		addr = "obcrt"
 	}
 	if requestData.Amount < tool.GetOmniDustBtc() {
-		return nil, errors.New(enum.Tips_common_wrong + "amount")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "amount")
 	} else {
 		mul := decimal.NewFromFloat(requestData.Amount).Mul(decimal.NewFromInt(100000000))
 		temp := mul.IntPart()
@@ -74,7 +75,7 @@ func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage,
 	addr += "1"
 
 	if requestData.PropertyId < 0 {
-		return nil, errors.New(enum.Tips_common_wrong + "property_id")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "property_id")
 	}
 	propertyId := ""
 	tool.ConvertNumToString(int(requestData.PropertyId), &propertyId)
@@ -97,7 +98,7 @@ func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage,
 	addr += "u" + code + msg.SenderUserPeerId
 
 	if tool.CheckIsString(&requestData.H) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "h")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "h")
 	} else {
 		//ph payment H
 		code, err = tool.GetMsgLengthFromInt(len(requestData.H))
@@ -108,10 +109,10 @@ func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage,
 	}
 
 	if time.Time(requestData.ExpiryTime).IsZero() {
-		return nil, errors.New(enum.Tips_common_wrong + "expiry_time")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "expiry_time")
 	} else {
 		if time.Now().After(time.Time(requestData.ExpiryTime)) {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_htlc_expiryTimeAfterNow, "expiry_time"))
+			return nil, enum.NewError(enum.ErrorCode_htlc_expiryTimeAfterNow, "expiry_time")
 		}
 		expiryTime := ""
 		tool.ConvertNumToString(int(time.Time(requestData.ExpiryTime).Unix()), &expiryTime)
@@ -153,6 +154,7 @@ func (service *htlcForwardTxManager) CreateHtlcInvoice(msg bean.RequestMessage,
 	invoiceInfo := &dao.InvoiceInfo{}
 	err = user.Db.Select(q.Eq("Invoice", addr)).First(invoiceInfo)
 	if err != nil {
+		invoiceInfo.H = requestData.H
 		invoiceInfo.Detail = *requestData
 		invoiceInfo.Invoice = addr
 		invoiceInfo.CreateAt = time.Now()
@@ -179,7 +181,7 @@ func (service *htlcForwardTxManager) ParseInvoice(msgData string, user bean.User
 // 401 htlc find path
 func (service *htlcForwardTxManager) PayerRequestFindPath(msgData string, user bean.User) (data interface{}, isPrivate bool, err error) {
 	if tool.CheckIsString(&msgData) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 
 	requestData := &bean.HtlcRequestFindPath{}
@@ -194,7 +196,7 @@ func (service *htlcForwardTxManager) PayerRequestFindPath(msgData string, user b
 	if tool.CheckIsString(&requestData.Invoice) {
 		htlcRequestInvoice, err := tool.DecodeInvoiceObjFromCodes(requestData.Invoice)
 		if err != nil {
-			return nil, false, errors.New(enum.Tips_common_wrong + "invoice")
+			return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "invoice")
 		}
 		if err = findUserIsOnline(htlcRequestInvoice.RecipientNodePeerId, htlcRequestInvoice.RecipientUserPeerId); err != nil {
 			return nil, requestFindPathInfo.IsPrivate, err
@@ -203,10 +205,10 @@ func (service *htlcForwardTxManager) PayerRequestFindPath(msgData string, user b
 	} else {
 		requestFindPathInfo = requestData.HtlcRequestFindPathInfo
 		if tool.CheckIsString(&requestFindPathInfo.RecipientNodePeerId) == false {
-			return nil, requestFindPathInfo.IsPrivate, errors.New(enum.Tips_common_wrong + "recipient_node_peer_id")
+			return nil, requestFindPathInfo.IsPrivate, enum.NewError(enum.ErrorCode_common_wrong, "recipient_node_peer_id")
 		}
 		if tool.CheckIsString(&requestFindPathInfo.RecipientUserPeerId) == false {
-			return nil, requestFindPathInfo.IsPrivate, errors.New(enum.Tips_common_wrong + "recipient_user_peer_id")
+			return nil, requestFindPathInfo.IsPrivate, enum.NewError(enum.ErrorCode_common_wrong, "recipient_user_peer_id")
 		}
 
 		if err = findUserIsOnline(requestFindPathInfo.RecipientNodePeerId, requestFindPathInfo.RecipientUserPeerId); err != nil {
@@ -229,28 +231,25 @@ func (service *htlcForwardTxManager) PayerRequestFindPath(msgData string, user b
 	}
 
 	if requestFindPathInfo.PropertyId < 0 {
-		return nil, requestFindPathInfo.IsPrivate, errors.New(enum.Tips_common_wrong + "property_id")
+		return nil, requestFindPathInfo.IsPrivate, enum.NewError(enum.ErrorCode_common_wrong, "property_id")
 	}
 
 	if requestFindPathInfo.Amount < tool.GetOmniDustBtc() {
-		return nil, requestFindPathInfo.IsPrivate, errors.New(enum.Tips_common_wrong + "amount")
+		return nil, requestFindPathInfo.IsPrivate, enum.NewError(enum.ErrorCode_common_wrong, "amount")
 	}
 
 	if time.Now().After(time.Time(requestFindPathInfo.ExpiryTime)) {
-		return nil, requestFindPathInfo.IsPrivate, errors.New(fmt.Sprintf(enum.Tips_htlc_expiryTimeAfterNow, "expiry_time"))
+		return nil, requestFindPathInfo.IsPrivate, enum.NewError(enum.ErrorCode_htlc_expiryTimeAfterNow, "expiry_time")
 	}
 
 	if requestFindPathInfo.IsPrivate == false {
 		//tracker find path
-		pathRequest := trackerBean.HtlcPathRequest{}
-		pathRequest.H = requestFindPathInfo.H
-		pathRequest.PropertyId = requestFindPathInfo.PropertyId
-		pathRequest.Amount = requestFindPathInfo.Amount
-		pathRequest.RealPayerPeerId = user.PeerId
-		pathRequest.PayerObdNodeId = tool.GetObdNodeId()
-		pathRequest.PayeePeerId = requestFindPathInfo.RecipientUserPeerId
-
-		cacheDataForTx.KeyName = user.PeerId + "_" + pathRequest.H
+		payment, err := PaymentService.start(*requestData, requestFindPathInfo, user)
+		if err != nil {
+			return nil, requestFindPathInfo.IsPrivate, err
+		}
+
+		cacheDataForTx.KeyName = user.PeerId + "_" + payment.H
 		err = user.Db.Select(q.Eq("KeyName", cacheDataForTx.KeyName)).First(cacheDataForTx)
 		if cacheDataForTx.Id != 0 {
 			_ = user.Db.DeleteStruct(cacheDataForTx)
@@ -258,12 +257,16 @@ func (service *htlcForwardTxManager) PayerRequestFindPath(msgData string, user b
 
 		cacheDataForTx.CreateAt = time.Now()
 		cacheDataForTx.IsFinish = false
-		cacheDataForTx.KeyName = user.PeerId + "_" + pathRequest.H
+		cacheDataForTx.KeyName = user.PeerId + "_" + payment.H
 		bytes, _ := json.Marshal(requestFindPathInfo)
 		cacheDataForTx.Data = bytes
 		_ = user.Db.Save(cacheDataForTx)
 
-		sendMsgToTracker(enum.MsgType_Tracker_GetHtlcPath_351, pathRequest)
+		if PaymentService.sendPathRequest(*payment, user) == false {
+			err = enum.NewError(enum.ErrorCode_htlc_noTrackerForPath)
+			PaymentService.OnPathNotFound(payment.H, err.Error(), user)
+			return nil, requestFindPathInfo.IsPrivate, err
+		}
 		return make(map[string]interface{}), requestFindPathInfo.IsPrivate, nil
 	} else {
 		requestData.HtlcRequestFindPathInfo = requestFindPathInfo
@@ -315,7 +318,7 @@ func getPrivateChannelForHtlc(requestData *bean.HtlcRequestFindPath, user bean.U
 	}
 	_ = tx.Commit()
 	if len(retData) == 0 {
-		return nil, true, errors.New(enum.Tips_htlc_noPrivatePath)
+		return nil, true, enum.NewError(enum.ErrorCode_htlc_noPrivatePath)
 	}
 	return retData, true, nil
 }
@@ -328,10 +331,19 @@ func (service *htlcForwardTxManager) GetResponseFromTrackerOfPayerRequestFindPat
 	}
 
 	log.Println("channelPath " + channelPath)
+	// h_path_amount, and the onion public keys and the amounts of the hops of the path if the tracker knows them
 	dataArr := strings.Split(channelPath, "_")
-	if len(dataArr) != 3 {
+	if len(dataArr) < 3 || len(dataArr) > 5 {
 		return nil, errors.New("no channel path")
 	}
+	hopKeys := ""
+	if len(dataArr) > 3 {
+		hopKeys = dataArr[3]
+	}
+	hopAmounts := ""
+	if len(dataArr) > 4 {
+		hopAmounts = dataArr[4]
+	}
 
 	h := dataArr[0]
 
@@ -351,10 +363,75 @@ func (service *htlcForwardTxManager) GetResponseFromTrackerOfPayerRequestFindPat
 		return nil, err
 	}
 
-	splitArr := strings.Split(dataArr[1], ",")
+	if len(dataArr[1]) == 0 {
+		err = errors.New("has no channel path")
+		PaymentService.OnPathNotFound(h, err.Error(), user)
+		return nil, err
+	}
+
+	var retData map[string]interface{}
+	fee := decimal.Zero
+	if strings.Contains(dataArr[1], ":") {
+		// a multi-path payment, every part is a route and the amount to the payee
+		parts := make([]map[string]interface{}, 0)
+		totalAmount := decimal.Zero
+		for index, item := range strings.Split(dataArr[1], ";") {
+			partArr := strings.Split(item, ":")
+			if len(partArr) != 2 {
+				return nil, errors.New("wrong channel path of parts")
+			}
+			amount, err := strconv.ParseFloat(partArr[1], 64)
+			if err != nil {
+				return nil, errors.New("wrong channel path of parts")
+			}
+			part, err := getHtlcPathInfo(partArr[0], amount, parseHopAmounts(hopAmounts, index, partArr[0]), user)
+			if err != nil {
+				return nil, err
+			}
+			part["total_amount"] = requestFindPathInfo.Amount
+			parts = append(parts, part)
+			fee = fee.Add(decimal.NewFromFloat(part["amount_and_fee"].(float64))).Sub(decimal.NewFromFloat(amount))
+			totalAmount = totalAmount.Add(decimal.NewFromFloat(amount))
+		}
+		if totalAmount.Equal(decimal.NewFromFloat(requestFindPathInfo.Amount)) == false {
+			return nil, errors.New("the parts do not add up to the amount")
+		}
+		retData = make(map[string]interface{})
+		retData["amount"] = requestFindPathInfo.Amount
+		retData["total_amount"] = requestFindPathInfo.Amount
+		retData["parts"] = parts
+	} else {
+		retData, err = getHtlcPathInfo(dataArr[1], requestFindPathInfo.Amount, parseHopAmounts(hopAmounts, 0, dataArr[1]), user)
+		if err != nil {
+			return nil, err
+		}
+		fee = decimal.NewFromFloat(retData["amount_and_fee"].(float64)).Sub(decimal.NewFromFloat(requestFindPathInfo.Amount))
+	}
+	feeOfPath, _ := fee.Round(8).Float64()
+	if err = PaymentService.onPathFound(h, dataArr[1], hopKeys, hopAmounts, feeOfPath, user); err != nil {
+		return nil, err
+	}
+	retData["h"] = h
+	retData["is_private"] = false
+	retData["property_id"] = requestFindPathInfo.PropertyId
+	retData["memo"] = requestFindPathInfo.Description
+
+	_ = user.Db.UpdateField(cacheDataForTx, "IsFinish", true)
+	// the client of the user adds the htlcs of the new path of the retried payment, obd adds them for the admin user
+	if PaymentService.attemptCount(h, user) > 1 {
+		EventService.Publish(user.PeerId, enum.EventType_PaymentRetried, "", retData)
+	}
+
+	return retData, nil
+}
+
+// the first channel of the path is the channel of the payer, the amount of the payer is the amount to the payee and the fees.
+// The fees are by the fee rates of the hops if the tracker sent the hop amounts, or by the fee rate of obd.
+func getHtlcPathInfo(path string, amountToPayee float64, hopAmounts []float64, user bean.User) (retData map[string]interface{}, err error) {
+	channelIds := strings.Split(path, ",")
 	currChannelInfo := dao.ChannelInfo{}
 	err = user.Db.Select(
-		q.Eq("ChannelId", splitArr[0]),
+		q.Eq("ChannelId", channelIds[0]),
 		q.Or(q.Eq("CurrState", bean.ChannelState_CanUse),
 			q.Eq("CurrState", bean.ChannelState_LockByTracker)),
 		q.Or(
@@ -370,23 +447,16 @@ func (service *htlcForwardTxManager) GetResponseFromTrackerOfPayerRequestFindPat
 		nextNodePeerId = currChannelInfo.PeerIdA
 	}
 
-	arrLength := len(strings.Split(dataArr[1], ","))
-	retData := make(map[string]interface{})
-	retData["h"] = h
-	retData["is_private"] = false
-	retData["property_id"] = requestFindPathInfo.PropertyId
-	retData["amount"] = requestFindPathInfo.Amount
-	channelIds := strings.Split(dataArr[1], ",")
 	totalStep := len(channelIds)
-	tempAmount, _ := decimal.NewFromFloat(requestFindPathInfo.Amount).Mul(decimal.NewFromFloat(1 + config.HtlcFeeRate*float64(totalStep-1))).Round(8).Float64()
-	retData["amount_and_fee"] = tempAmount
-	retData["routing_packet"] = dataArr[1]
-	retData["min_cltv_expiry"] = arrLength
+	retData = make(map[string]interface{})
+	retData["amount"] = amountToPayee
+	retData["amount_and_fee"], _ = decimal.NewFromFloat(amountToPayee).Mul(decimal.NewFromFloat(1 + config.HtlcFeeRate*float64(totalStep-1))).Round(8).Float64()
+	if hopAmounts != nil {
+		retData["amount_and_fee"] = hopAmounts[0]
+	}
+	retData["routing_packet"] = path
+	retData["min_cltv_expiry"] = totalStep
 	retData["next_node_peerId"] = nextNodePeerId
-	retData["memo"] = requestFindPathInfo.Description
-
-	_ = user.Db.UpdateField(cacheDataForTx, "IsFinish", true)
-
 	return retData, nil
 }
 
@@ -400,7 +470,7 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 	totalDurationObd = 0
 	totalDurationClient = 0
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 
 	requestData := &bean.CreateHtlcTxForC3a{}
@@ -409,6 +479,21 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		log.Println(err.Error())
 		return nil, false, err
 	}
+	// the onion keys and the amounts of the hops are only known by the payer of the path found by the tracker
+	var hopKeys []string
+	var hopAmounts []float64
+	if len(requestData.Onion) == 0 {
+		hopKeys, err = PaymentService.getHopKeys(requestData.H, requestData.RoutingPacket, user)
+		if err != nil {
+			log.Println(err)
+			return nil, false, err
+		}
+		hopAmounts = PaymentService.getHopAmounts(requestData.H, requestData.RoutingPacket, user)
+		// the last hop is paid the amount to the payee
+		if hopAmounts != nil && hopAmounts[len(hopAmounts)-1] != requestData.AmountToPayee {
+			return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "amount_to_payee")
+		}
+	}
 
 	tx, err := user.Db.Begin(true)
 	if err != nil {
@@ -419,13 +504,13 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 
 	//region check input data 检测输入输入数据
 	if requestData.Amount < tool.GetOmniDustBtc() {
-		return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_amountMustGreater, tool.GetOmniDustBtc()))
+		return nil, false, enum.NewError(enum.ErrorCode_common_amountMustGreater, tool.GetOmniDustBtc())
 	}
 	if tool.CheckIsString(&requestData.H) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "h")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "h")
 	}
 	if tool.CheckIsString(&requestData.RoutingPacket) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "routing_packet")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "routing_packet")
 	}
 
 	if requestData.AmountToPayee < tool.GetOmniDustBtc() {
@@ -447,14 +532,17 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		}
 	}
 	if channelInfo == nil {
-		return nil, false, errors.New(enum.Tips_htlc_noChanneFromRountingPacket)
+		return nil, false, enum.NewError(enum.ErrorCode_htlc_noChanneFromRountingPacket)
 	}
 
 	if channelInfo.CurrState == bean.ChannelState_NewTx {
-		return nil, false, errors.New(enum.Tips_common_newTxMsg)
+		return nil, false, enum.NewError(enum.ErrorCode_common_newTxMsg)
 	}
 
 	tempAmount, _ := decimal.NewFromFloat(requestData.AmountToPayee).Mul(decimal.NewFromFloat(1 + config.HtlcFeeRate*float64(totalStep-currStep-1))).Round(8).Float64()
+	if hopAmounts != nil {
+		tempAmount = hopAmounts[currStep]
+	}
 	maxAmount, _ := decimal.NewFromFloat(requestData.AmountToPayee).Add(decimal.NewFromFloat(config.HtlcMaxFee)).Round(8).Float64()
 	if tempAmount > maxAmount {
 		tempAmount = maxAmount
@@ -471,7 +559,7 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 	//		return nil, false, err
 	//	}
 	//	if pass == false {
-	//		err = errors.New(enum.Tips_rsmc_broadcastedChannel)
+	//		err = enum.NewError(enum.ErrorCode_rsmc_broadcastedChannel)
 	//		log.Println(err)
 	//		return nil, false, err
 	//	}
@@ -481,12 +569,22 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		requestData.CltvExpiry = totalStep - currStep
 	}
 
+	// the payer wraps the path in the onion, the channels of the path are not sent to the hops
+	var onionSecrets []string
+	if hopKeys != nil && currStep == 0 && totalStep > 1 {
+		requestData.Onion, onionSecrets, err = createHtlcOnion(*requestData, channelIds, hopKeys, hopAmounts)
+		if err != nil {
+			log.Println(err)
+			return nil, false, err
+		}
+	}
+
 	if channelInfo.CurrState < bean.ChannelState_NewTx {
 		return nil, false, errors.New("do not finish funding")
 	}
 
 	if tool.CheckIsString(&requestData.LastTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "last_temp_address_private_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "last_temp_address_private_key")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -501,11 +599,11 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		if latestCommitmentTx.CurrState == dao.TxInfoState_CreateAndSign {
 			_, err = omnicore.GetPubKeyFromWifAndCheck(requestData.LastTempAddressPrivateKey, latestCommitmentTx.RSMCTempAddressPubKey)
 			if err != nil {
-				return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, latestCommitmentTx.RSMCTempAddressPubKey))
+				return nil, false, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, latestCommitmentTx.RSMCTempAddressPubKey)
 			}
 
 			if requestData.Amount > latestCommitmentTx.AmountToRSMC {
-				return nil, false, errors.New("not enough balance ,your balance is " + tool.FloatToString(latestCommitmentTx.AmountToRSMC, 8))
+				return nil, false, enum.NewError(enum.ErrorCode_htlc_insufficientBalance, tool.FloatToString(latestCommitmentTx.AmountToRSMC, 8))
 			}
 		}
 		if latestCommitmentTx.CurrState == dao.TxInfoState_Create {
@@ -514,11 +612,11 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 			}
 
 			if requestData.CurrRsmcTempAddressPubKey != latestCommitmentTx.RSMCTempAddressPubKey {
-				return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, requestData.CurrRsmcTempAddressPubKey, latestCommitmentTx.RSMCTempAddressPubKey))
+				return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, requestData.CurrRsmcTempAddressPubKey, latestCommitmentTx.RSMCTempAddressPubKey)
 			}
 
 			if requestData.CurrHtlcTempAddressPubKey != latestCommitmentTx.HTLCTempAddressPubKey {
-				return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressPubKey, latestCommitmentTx.HTLCTempAddressPubKey))
+				return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressPubKey, latestCommitmentTx.HTLCTempAddressPubKey)
 			}
 
 			if latestCommitmentTx.LastCommitmentTxId > 0 {
@@ -526,26 +624,26 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 				_ = tx.One("Id", latestCommitmentTx.LastCommitmentTxId, lastCommitmentTx)
 				_, err = omnicore.GetPubKeyFromWifAndCheck(requestData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey)
 				if err != nil {
-					return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey))
+					return nil, false, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey)
 				}
 			}
 		}
 	}
 
 	if tool.CheckIsString(&requestData.CurrRsmcTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_rsmc_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_rsmc_temp_address_pub_key")
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if tool.CheckIsString(&requestData.CurrHtlcTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_htlc_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_htlc_temp_address_pub_key")
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if tool.CheckIsString(&requestData.CurrHtlcTempAddressForHt1aPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_htlc_temp_address_for_ht1a_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_htlc_temp_address_for_ht1a_pub_key")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -596,7 +694,7 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		}
 	} else {
 		if requestData.CurrHtlcTempAddressForHt1aPubKey != htlcRequestInfo.CurrHtlcTempAddressForHt1aPubKey {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressForHt1aPubKey, htlcRequestInfo.CurrHtlcTempAddressForHt1aPubKey))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressForHt1aPubKey, htlcRequestInfo.CurrHtlcTempAddressForHt1aPubKey)
 		}
 		_ = tx.Select(q.Eq("CommitmentTxId", latestCommitmentTx.Id)).First(&rawTx)
 		if rawTx.Id == 0 {
@@ -604,13 +702,24 @@ func (service *htlcForwardTxManager) AliceAddHtlcAtAliceSide(msg bean.RequestMes
 		}
 	}
 
+	// the payer reads the failure of the htlc by the secrets of the onion
+	if len(onionSecrets) > 0 {
+		latestCommitmentTx.HtlcOnionSecrets = strings.Join(onionSecrets, ",")
+		_ = tx.Update(latestCommitmentTx)
+	}
+
 	c3aP2pData := &bean.CreateHtlcTxForC3aOfP2p{}
 	c3aP2pData.RoutingPacket = requestData.RoutingPacket
+	if len(requestData.Onion) > 0 {
+		c3aP2pData.RoutingPacket = channelInfo.ChannelId
+		c3aP2pData.Onion = requestData.Onion
+	}
 	c3aP2pData.ChannelId = channelInfo.ChannelId
 	c3aP2pData.IsPayInvoice = requestData.IsPayInvoice
 	c3aP2pData.H = requestData.H
 	c3aP2pData.Amount = requestData.Amount
 	c3aP2pData.AmountToPayee = requestData.AmountToPayee
+	c3aP2pData.TotalAmount = requestData.TotalAmount
 	c3aP2pData.Memo = requestData.Memo
 	c3aP2pData.CltvExpiry = requestData.CltvExpiry
 	c3aP2pData.LastTempAddressPrivateKey = requestData.LastTempAddressPrivateKey
@@ -660,7 +769,7 @@ func (service *htlcForwardTxManager) OnAliceSignedC3aAtAliceSide(msg bean.Reques
 	beginTime = time.Now()
 	log.Println("step 2 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 	if tool.CheckIsString(&msg.Data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -669,7 +778,7 @@ func (service *htlcForwardTxManager) OnAliceSignedC3aAtAliceSide(msg bean.Reques
 	_ = json.Unmarshal([]byte(msg.Data), &signedDataForC3a)
 
 	if tool.CheckIsString(&signedDataForC3a.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -684,38 +793,38 @@ func (service *htlcForwardTxManager) OnAliceSignedC3aAtAliceSide(msg bean.Reques
 	cacheDataForTx := &dao.CacheDataForTx{}
 	_ = tx.Select(q.Eq("KeyName", user.PeerId+"_"+signedDataForC3a.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	dataTo40P := &bean.CreateHtlcTxForC3aOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, dataTo40P)
 
 	if len(dataTo40P.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&signedDataForC3a.C3aRsmcPartialSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC3a.C3aRsmcPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "c3a_rsmc_partial_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c3a_rsmc_partial_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
 	}
 
 	if tool.CheckIsString(&signedDataForC3a.C3aHtlcPartialSignedHex) == false {
-		err = errors.New(enum.Tips_common_empty + "c3a_htlc_partial_signed_hex")
+		err = enum.NewError(enum.ErrorCode_common_empty, "c3a_htlc_partial_signed_hex")
 		log.Println(err)
 		return nil, nil, err
 	}
 	if pass, _ := omnicore.CheckMultiSign(signedDataForC3a.C3aHtlcPartialSignedHex, 1); pass == false {
-		err = errors.New(enum.Tips_common_wrong + "counterparty_signed_hex")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "counterparty_signed_hex")
 		log.Println(err)
 		return nil, nil, err
 	}
 
 	if tool.CheckIsString(&signedDataForC3a.C3aCounterpartyPartialSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC3a.C3aCounterpartyPartialSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "c3a_counterparty_partial_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c3a_counterparty_partial_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -723,7 +832,7 @@ func (service *htlcForwardTxManager) OnAliceSignedC3aAtAliceSide(msg bean.Reques
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, signedDataForC3a.ChannelId, user.PeerId)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	if len(latestCommitmentTxInfo.RSMCTxHex) > 0 {
@@ -783,6 +892,18 @@ func (service *htlcForwardTxManager) BeforeBobSignAddHtlcRequestAtBobSide_40(msg
 	requestAddHtlc := &bean.CreateHtlcTxForC3aOfP2p{}
 	_ = json.Unmarshal([]byte(msgData), requestAddHtlc)
 	channelId := requestAddHtlc.ChannelId
+	if len(requestAddHtlc.Onion) > 0 {
+		if _, _, _, err = peelHtlcOnion(*requestAddHtlc); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+	}
+	// the amount to payee is claimed by the sender, it is never more than the amount locked in the htlc
+	if requestAddHtlc.AmountToPayee > requestAddHtlc.Amount {
+		err = enum.NewError(enum.ErrorCode_htlc_wrongAmountToPayee, tool.FloatToString(requestAddHtlc.AmountToPayee, 8), tool.FloatToString(requestAddHtlc.Amount, 8))
+		log.Println(err)
+		return nil, err
+	}
 
 	tx, err := user.Db.Begin(true)
 	if err != nil {
@@ -809,6 +930,7 @@ func (service *htlcForwardTxManager) BeforeBobSignAddHtlcRequestAtBobSide_40(msg
 	_ = tx.Save(cacheDataForTx)
 
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	toBobData := &bean.CreateHtlcTxForC3aToBob{}
 	toBobData.ChannelId = requestAddHtlc.ChannelId
@@ -834,7 +956,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 	beginTime = time.Now()
 	log.Println("step 4 ", "totalDurationObd", totalDurationObd, "totalDurationClient", totalDurationClient)
 	if tool.CheckIsString(&jsonData) == false {
-		err := errors.New(enum.Tips_common_empty + "msg data")
+		err := enum.NewError(enum.ErrorCode_common_empty, "msg data")
 		log.Println(err)
 		return nil, err
 	}
@@ -854,17 +976,17 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 	defer tx.Rollback()
 
 	if tool.CheckIsString(&requestData.PayerCommitmentTxHash) == false {
-		return nil, errors.New(enum.Tips_common_empty + "payer_commitment_tx_hash")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "payer_commitment_tx_hash")
 	}
 
 	cacheDataForTx := &dao.CacheDataForTx{}
 	err = tx.Select(q.Eq("KeyName", requestData.PayerCommitmentTxHash)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, errors.New(enum.Tips_common_wrong + "payer_commitment_tx_hash")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "payer_commitment_tx_hash")
 	}
 	aliceMsg := string(cacheDataForTx.Data)
 	if tool.CheckIsString(&aliceMsg) == false {
-		return nil, errors.New(enum.Tips_common_wrong + "payer_commitment_tx_hash")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "payer_commitment_tx_hash")
 	}
 
 	payerRequestAddHtlc := &bean.CreateHtlcTxForC3aOfP2p{}
@@ -872,7 +994,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 
 	if len(payerRequestAddHtlc.C3aRsmcPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedRsmcHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_empty + "c3a_complete_signed_rsmc_hex")
+			err = enum.NewError(enum.ErrorCode_common_empty, "c3a_complete_signed_rsmc_hex")
 			log.Println(err)
 			return nil, err
 		}
@@ -880,7 +1002,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedHtlcHex, 2); pass == false {
-		err = errors.New(enum.Tips_common_empty + "c3a_complete_signed_htlc_hex")
+		err = enum.NewError(enum.ErrorCode_common_empty, "c3a_complete_signed_htlc_hex")
 		log.Println(err)
 		return nil, err
 	}
@@ -888,7 +1010,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 
 	if len(payerRequestAddHtlc.C3aCounterpartyPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedCounterpartyHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_empty + "c3a_complete_signed_counterparty_hex")
+			err = enum.NewError(enum.ErrorCode_common_empty, "c3a_complete_signed_counterparty_hex")
 			log.Println(err)
 			return nil, err
 		}
@@ -908,19 +1030,19 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 
 	if len(payerRequestAddHtlc.C3aRsmcPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedRsmcHex, 2); pass == false {
-			return nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_rsmc_hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_rsmc_hex")
 		}
 	}
 	toAliceDataOf41P.C3aCompleteSignedRsmcHex = requestData.C3aCompleteSignedRsmcHex
 
 	if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedHtlcHex, 2); pass == false {
-		return nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_htlc_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_htlc_hex")
 	}
 	toAliceDataOf41P.C3aCompleteSignedHtlcHex = requestData.C3aCompleteSignedHtlcHex
 
 	if len(payerRequestAddHtlc.C3aCounterpartyPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(requestData.C3aCompleteSignedCounterpartyHex, 2); pass == false {
-			return nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_counterparty_hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_counterparty_hex")
 		}
 	}
 	toAliceDataOf41P.C3aCompleteSignedCounterpartyHex = requestData.C3aCompleteSignedCounterpartyHex
@@ -935,7 +1057,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 			q.Eq("PeerIdB", user.PeerId))).
 		First(channelInfo)
 	if err != nil {
-		return nil, errors.New(enum.Tips_htlc_noChanneFromRountingPacket)
+		return nil, enum.NewError(enum.ErrorCode_htlc_noChanneFromRountingPacket)
 	}
 
 	if channelInfo.CurrState < bean.ChannelState_NewTx {
@@ -957,7 +1079,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 
 	if latestCommitmentTxInfo.Id > 0 {
 		if tool.CheckIsString(&requestData.LastTempAddressPrivateKey) == false {
-			err = errors.New(enum.Tips_common_empty + "last_temp_address_private_key")
+			err = enum.NewError(enum.ErrorCode_common_empty, "last_temp_address_private_key")
 			log.Println(err)
 			return nil, err
 		}
@@ -965,7 +1087,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 		if latestCommitmentTxInfo.CurrState == dao.TxInfoState_CreateAndSign {
 			_, err = omnicore.GetPubKeyFromWifAndCheck(requestData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 			if err != nil {
-				return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+				return nil, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, requestData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 			}
 		}
 		if latestCommitmentTxInfo.CurrState == dao.TxInfoState_Create {
@@ -974,10 +1096,10 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 			}
 
 			if requestData.CurrRsmcTempAddressPubKey != latestCommitmentTxInfo.RSMCTempAddressPubKey {
-				return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, requestData.CurrRsmcTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+				return nil, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, requestData.CurrRsmcTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 			}
 			if requestData.CurrHtlcTempAddressPubKey != latestCommitmentTxInfo.HTLCTempAddressPubKey {
-				return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressPubKey, latestCommitmentTxInfo.HTLCTempAddressPubKey))
+				return nil, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, requestData.CurrHtlcTempAddressPubKey, latestCommitmentTxInfo.HTLCTempAddressPubKey)
 			}
 
 			if latestCommitmentTxInfo.LastCommitmentTxId > 0 {
@@ -992,14 +1114,14 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 		toAliceDataOf41P.PayeeLastTempAddressPrivateKey = requestData.LastTempAddressPrivateKey
 	}
 	if tool.CheckIsString(&requestData.CurrRsmcTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_rsmc_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_rsmc_temp_address_pub_key")
 		log.Println(err)
 		return nil, err
 	}
 	toAliceDataOf41P.PayeeCurrRsmcTempAddressPubKey = requestData.CurrRsmcTempAddressPubKey
 
 	if tool.CheckIsString(&requestData.CurrHtlcTempAddressPubKey) == false {
-		err = errors.New(enum.Tips_common_empty + "curr_htlc_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "curr_htlc_temp_address_pub_key")
 		log.Println(err)
 		return nil, err
 	}
@@ -1026,7 +1148,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 			return nil, err
 		}
 		if len(c3aRsmcOutputs) == 0 {
-			return nil, errors.New(enum.Tips_common_wrong + "payer rsmc hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "payer rsmc hex")
 		}
 		//endregion
 	}
@@ -1091,7 +1213,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 
 		fundingTransaction := getFundingTransactionByChannelId(tx, channelInfo.ChannelId, user.PeerId)
 		if fundingTransaction == nil {
-			return nil, errors.New(enum.Tips_common_notFound + "fundingTransaction")
+			return nil, enum.NewError(enum.ErrorCode_common_notFound, "fundingTransaction")
 		}
 
 		//region 5、创建C3b
@@ -1151,12 +1273,12 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			amountToOther,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&c3aRsmcRedeemScript)
 		if err != nil {
 			log.Println(err)
-			return nil, errors.New(fmt.Sprintf(enum.Tips_rsmc_failToCreate, "RD raw transacation"))
+			return nil, enum.NewError(enum.ErrorCode_rsmc_failToCreate, "RD raw transacation")
 		}
 
 		signHexData := bean.NeedClientSignTxData{}
@@ -1223,6 +1345,7 @@ func (service *htlcForwardTxManager) BobSignedAddHtlcAtBobSide(jsonData string,
 	}
 	service.tempDataSendTo41PAtBobSide[user.PeerId+"_"+channelInfo.ChannelId] = toAliceDataOf41P
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	totalDurationObd += time.Now().Sub(beginTime).Milliseconds()
 	beginTime = time.Now()
@@ -1246,7 +1369,7 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 	toAliceDataOfP2p := service.tempDataSendTo41PAtBobSide[key]
 
 	if len(toAliceDataOfP2p.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	tx, err := user.Db.Begin(true)
@@ -1259,12 +1382,12 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 	cacheDataForTx := dao.CacheDataForTx{}
 	err = tx.Select(q.Eq("KeyName", toAliceDataOfP2p.PayerCommitmentTxHash)).First(&cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "payer_commitment_tx_hash")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "payer_commitment_tx_hash")
 	}
 
 	aliceMsg := string(cacheDataForTx.Data)
 	if tool.CheckIsString(&aliceMsg) == false {
-		return nil, nil, errors.New(enum.Tips_common_empty + "payer_commitment_tx_hash")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_empty, "payer_commitment_tx_hash")
 	}
 
 	payerRequestAddHtlc := &bean.CreateHtlcTxForC3aOfP2p{}
@@ -1272,17 +1395,17 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 
 	if len(payerRequestAddHtlc.C3aRsmcPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(toAliceDataOfP2p.C3aCompleteSignedRsmcHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_rsmc_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_rsmc_hex")
 		}
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(toAliceDataOfP2p.C3aCompleteSignedHtlcHex, 2); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_htlc_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_htlc_hex")
 	}
 
 	if len(payerRequestAddHtlc.C3aCounterpartyPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(toAliceDataOfP2p.C3aCompleteSignedCounterpartyHex, 2); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_complete_signed_counterparty_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_complete_signed_counterparty_hex")
 		}
 	}
 
@@ -1294,12 +1417,12 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 			q.Eq("PeerIdB", user.PeerId))).
 		First(channelInfo)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_htlc_noChanneFromRountingPacket)
+		return nil, nil, enum.NewError(enum.ErrorCode_htlc_noChanneFromRountingPacket)
 	}
 
 	latestCommitmentTxInfo, _ := getLatestCommitmentTxUseDbTx(tx, channelInfo.ChannelId, user.PeerId)
 	if latestCommitmentTxInfo.CurrState != dao.TxInfoState_Init {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	bobChannelPubKey := channelInfo.PubKeyB
@@ -1311,12 +1434,12 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 
 	if tool.CheckIsString(&toAliceDataOfP2p.C3aRsmcRdPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(c3bResult.C3aRsmcRdPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_rsmc_rd_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_rsmc_rd_partial_signed_hex")
 		}
 		toAliceDataOfP2p.C3aRsmcRdPartialSignedData.Hex = c3bResult.C3aRsmcRdPartialSignedHex
 
 		if pass, _ := omnicore.CheckMultiSign(c3bResult.C3aRsmcBrPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_rsmc_br_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_rsmc_br_partial_signed_hex")
 		}
 
 		c3aSignedRsmcHex := toAliceDataOfP2p.C3aCompleteSignedRsmcHex
@@ -1346,17 +1469,17 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(c3bResult.C3aHtlcHtPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_ht_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_ht_partial_signed_hex")
 	}
 	toAliceDataOfP2p.C3aHtlcHtPartialSignedData.Hex = c3bResult.C3aHtlcHtPartialSignedHex
 
 	if pass, _ := omnicore.CheckMultiSign(c3bResult.C3aHtlcHlockPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_hlock_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_hlock_partial_signed_hex")
 	}
 	toAliceDataOfP2p.C3aHtlcHlockPartialSignedData.Hex = c3bResult.C3aHtlcHlockPartialSignedHex
 
 	if pass, _ := omnicore.CheckMultiSign(c3bResult.C3aHtlcBrPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_br_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_br_partial_signed_hex")
 	}
 
 	c3aSignedHtlcHex := toAliceDataOfP2p.C3aCompleteSignedHtlcHex
@@ -1384,19 +1507,19 @@ func (service *htlcForwardTxManager) OnBobSignedC3bAtBobSide(msg bean.RequestMes
 
 	if tool.CheckIsString(&toAliceDataOfP2p.C3bRsmcPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(c3bResult.C3bRsmcPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_rsmc_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_rsmc_partial_signed_hex")
 		}
 		toAliceDataOfP2p.C3bRsmcPartialSignedData.Hex = c3bResult.C3bRsmcPartialSignedHex
 	}
 	if tool.CheckIsString(&toAliceDataOfP2p.C3bCounterpartyPartialSignedData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(c3bResult.C3bCounterpartyPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_counterparty_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_counterparty_partial_signed_hex")
 		}
 		toAliceDataOfP2p.C3bCounterpartyPartialSignedData.Hex = c3bResult.C3bCounterpartyPartialSignedHex
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(c3bResult.C3bHtlcPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_htlc_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_htlc_partial_signed_hex")
 	}
 	toAliceDataOfP2p.C3bHtlcPartialSignedData.Hex = c3bResult.C3bHtlcPartialSignedHex
 
@@ -1542,7 +1665,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 	var c3bSignedRsmcHex, c3bSignedRsmcTxid, c3bSignedToCounterpartyTxHex string
 	if len(dataFromBob.C3bRsmcPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bRsmcCompleteSignedHex, 2); pass == false {
-			return nil, errors.New(enum.Tips_common_wrong + "c3b_rsmc_complete_signed_hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_rsmc_complete_signed_hex")
 		}
 		bobRsmcHexIsExist = true
 		c3bSignedRsmcHex = aliceSignedC3b.C3bRsmcCompleteSignedHex
@@ -1552,29 +1675,29 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 
 	if len(dataFromBob.C3bCounterpartyPartialSignedData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bCounterpartyCompleteSignedHex, 2); pass == false {
-			return nil, errors.New(enum.Tips_common_wrong + "c3b_counterparty_complete_signed_hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_counterparty_complete_signed_hex")
 		}
 		c3bSignedToCounterpartyTxHex = aliceSignedC3b.C3bCounterpartyCompleteSignedHex
 		dataFromBob.C3bCounterpartyPartialSignedData.Hex = c3bSignedToCounterpartyTxHex
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bHtlcCompleteSignedHex, 2); pass == false {
-		return nil, errors.New(enum.Tips_common_wrong + "c3b_htlc_complete_signed_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_htlc_complete_signed_hex")
 	}
 	c3bSignedHtlcHex := aliceSignedC3b.C3bHtlcCompleteSignedHex
 	dataFromBob.C3bHtlcPartialSignedData.Hex = c3bSignedHtlcHex
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3aHtlcHtCompleteSignedHex, 2); pass == false {
-		return nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_ht_complete_signed_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_ht_complete_signed_hex")
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3aHtlcHlockCompleteSignedHex, 2); pass == false {
-		return nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_hlock_complete_signed_hex")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_hlock_complete_signed_hex")
 	}
 
 	if tool.CheckIsString(&aliceSignedC3b.C3aRsmcRdCompleteSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3aRsmcRdCompleteSignedHex, 2); pass == false {
-			return nil, errors.New(enum.Tips_common_wrong + "c3a_rsmc_rd_complete_signed_hex")
+			return nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_rsmc_rd_complete_signed_hex")
 		}
 	}
 
@@ -1623,7 +1746,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			commitmentTransaction.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&c3bRsmcRedeemScript)
 		if err != nil {
@@ -1680,7 +1803,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		htlcTimeOut,
 		&bobHtlcRedeemScript)
 	if err != nil {
@@ -1715,7 +1838,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		0,
 		&bobHtlcRedeemScript)
 	if err != nil {
@@ -1749,7 +1872,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		1000,
 		&c3aHtRedeemScript)
 	if err != nil {
@@ -1777,7 +1900,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 		channelInfo.FundingAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		0,
 		&c3aHtRedeemScript)
 	if err != nil {
@@ -1812,7 +1935,7 @@ func (service *htlcForwardTxManager) OnAliceSignC3bAtAliceSide(msg bean.RequestM
 		payeeChannelAddress,
 		channelInfo.PropertyId,
 		commitmentTransaction.AmountToHtlc,
-		getBtcMinerAmount(channelInfo.BtcAmount),
+		getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 		0,
 		&c3aHlockRedeemScript)
 	if err != nil {
@@ -1860,32 +1983,32 @@ func (service *htlcForwardTxManager) OnAliceSignedC3bSubTxAtAliceSide(msg bean.R
 	bobRsmcHexIsExist := false
 	if len(needBobSignData.C3bRsmcRdPartialData.Hex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bRsmcRdPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_rsmc_rd_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_rsmc_rd_partial_signed_hex")
 		}
 		needBobSignData.C3bRsmcRdPartialData.Hex = aliceSignedC3b.C3bRsmcRdPartialSignedHex
 
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bRsmcBrPartialSignedHex, 1); pass == false {
-			return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_rsmc_br_partial_signed_hex")
+			return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_rsmc_br_partial_signed_hex")
 		}
 		bobRsmcHexIsExist = true
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bHtlcHtdPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_htlc_htd_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_htlc_htd_partial_signed_hex")
 	}
 	needBobSignData.C3bHtlcHtdPartialData.Hex = aliceSignedC3b.C3bHtlcHtdPartialSignedHex
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bHtlcBrPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_htlc_br_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_htlc_br_partial_signed_hex")
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3bHtlcHlockPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3b_htlc_hlock_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3b_htlc_hlock_partial_signed_hex")
 	}
 	needBobSignData.C3bHtlcHlockPartialData.Hex = aliceSignedC3b.C3bHtlcHlockPartialSignedHex
 
 	if pass, _ := omnicore.CheckMultiSign(aliceSignedC3b.C3aHtlcHtrdPartialSignedHex, 1); pass == false {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "c3a_htlc_htrd_partial_signed_hex")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "c3a_htlc_htrd_partial_signed_hex")
 	}
 	needBobSignData.C3aHtlcHtrdPartialData.Hex = aliceSignedC3b.C3aHtlcHtrdPartialSignedHex
 
@@ -2069,11 +2192,11 @@ func (service *htlcForwardTxManager) OnBobSignedC3bSubTxAtBobSide(msg bean.Reque
 	_ = json.Unmarshal([]byte(msg.Data), &jsonObj)
 
 	if tool.CheckIsString(&jsonObj.ChannelId) == false {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if tool.CheckIsString(&jsonObj.CurrHtlcTempAddressForHePubKey) == false {
-		return nil, errors.New(enum.Tips_common_empty + "curr_htlc_temp_address_for_he_pub_key")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "curr_htlc_temp_address_for_he_pub_key")
 	}
 
 	c3bCacheData := service.tempDataFrom42PAtBobSide[user.PeerId+"_"+jsonObj.ChannelId]
@@ -2165,12 +2288,12 @@ func (service *htlcForwardTxManager) OnBobSignHtRdAtBobSide_42(msgData string, u
 	_ = json.Unmarshal([]byte(msgData), &jsonObj)
 
 	if tool.CheckIsString(&jsonObj.ChannelId) == false {
-		return nil, nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	dataFrom42P := service.tempDataFrom42PAtBobSide[user.PeerId+"_"+jsonObj.ChannelId]
 	if len(dataFrom42P.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(jsonObj.C3bHtlcHlockHePartialSignedHex, 1); pass == false {
@@ -2255,6 +2378,8 @@ func (service *htlcForwardTxManager) OnBobSignHtRdAtBobSide_42(msgData string, u
 	_ = tx.Update(channelInfo)
 
 	_ = tx.Commit()
+	publishHtlcEvent(user.PeerId, enum.EventType_HtlcAdded, *latestCommitmentTx, "")
+	watchHtlcParts(*latestCommitmentTx, user)
 
 	key := user.PeerId + "_" + channelInfo.ChannelId
 	delete(service.tempDataFrom42PAtBobSide, key)
@@ -2276,7 +2401,7 @@ func (service *htlcForwardTxManager) OnGetHtrdTxDataFromBobAtAliceSide_43(msgDat
 	_ = json.Unmarshal([]byte(msgData), &c3aHtrdData)
 
 	if tool.CheckIsString(&c3aHtrdData.ChannelId) == false {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(c3aHtrdData.C3aHtlcHedPartialSignedHex, 1); pass == false {
@@ -2344,6 +2469,8 @@ func (service *htlcForwardTxManager) OnGetHtrdTxDataFromBobAtAliceSide_43(msgDat
 	delete(service.tempDataFrom41PAtAliceSide, key)
 	delete(service.tempDataSendTo42PAtAliceSide, key)
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, channelInfo)
+	publishHtlcEvent(user.PeerId, enum.EventType_HtlcAdded, *latestCommitmentTx, "")
 
 	totalDurationObd += time.Now().Sub(beginTime).Milliseconds()
 	beginTime = time.Now()
@@ -2444,9 +2571,14 @@ func htlcPayerCreateCommitmentTx_C3a(tx storm.Node, channelInfo *dao.ChannelInfo
 		allUsedTxidTemp += "," + usedTxid
 		newCommitmentTxInfo.HtlcRoutingPacket = requestData.RoutingPacket
 		newCommitmentTxInfo.HtlcAmountToPayee = requestData.AmountToPayee
+		newCommitmentTxInfo.HtlcTotalAmount = requestData.TotalAmount
 
 		newCommitmentTxInfo.HtlcCltvExpiry = requestData.CltvExpiry
-		newCommitmentTxInfo.BeginBlockHeight = conn2tracker.GetBlockCount()
+		newCommitmentTxInfo.BeginBlockHeight, err = conn2tracker.GetBlockCount(context.Background())
+		if err != nil {
+			log.Println(err)
+			return nil, rawTx, err
+		}
 
 		newCommitmentTxInfo.HtlcTxHex = htlcTxData["hex"].(string)
 		newCommitmentTxInfo.HtlcMemo = requestData.Memo
@@ -2478,7 +2610,7 @@ func htlcPayerCreateCommitmentTx_C3a(tx storm.Node, channelInfo *dao.ChannelInfo
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			newCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 			&channelInfo.ChannelAddressRedeemScript)
 		if err != nil {
 			log.Println(err)
@@ -2616,9 +2748,28 @@ func htlcPayeeCreateCommitmentTx_C3b(tx storm.Node, channelInfo *dao.ChannelInfo
 		}
 		allUsedTxidTemp += "," + usedTxid
 		newCommitmentTxInfo.HtlcRoutingPacket = payerData.RoutingPacket
+		if len(payerData.Onion) > 0 {
+			// bob only knows the channel of the htlc and the next channel in the onion
+			routingPacket, payload, nextOnion, err := peelHtlcOnion(payerData)
+			if err != nil {
+				return nil, rawTx, err
+			}
+			newCommitmentTxInfo.HtlcRoutingPacket = routingPacket
+			newCommitmentTxInfo.HtlcOnion = nextOnion
+			newCommitmentTxInfo.HtlcOnionSecret, _ = tool.OnionSharedSecret(payerData.Onion)
+			if len(nextOnion) > 0 {
+				newCommitmentTxInfo.HtlcForwardAmount = payload.Amount
+				newCommitmentTxInfo.HtlcForwardCltv = payload.CltvExpiry
+			}
+		}
 		newCommitmentTxInfo.HtlcAmountToPayee = payerData.AmountToPayee
+		newCommitmentTxInfo.HtlcTotalAmount = payerData.TotalAmount
 		newCommitmentTxInfo.HtlcCltvExpiry = payerData.CltvExpiry
-		newCommitmentTxInfo.BeginBlockHeight = conn2tracker.GetBlockCount()
+		newCommitmentTxInfo.BeginBlockHeight, err = conn2tracker.GetBlockCount(context.Background())
+		if err != nil {
+			log.Println(err)
+			return nil, rawTx, err
+		}
 		newCommitmentTxInfo.HtlcTxHex = htlcTxData["hex"].(string)
 		newCommitmentTxInfo.HtlcMemo = payerData.Memo
 
@@ -2648,7 +2799,7 @@ func htlcPayeeCreateCommitmentTx_C3b(tx storm.Node, channelInfo *dao.ChannelInfo
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			newCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Commitment, channelInfo.BtcAmount),
 			&channelInfo.ChannelAddressRedeemScript)
 		if err != nil {
 			log.Println(err)
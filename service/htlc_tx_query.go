@@ -47,23 +47,23 @@ func (service *htlcQueryTxManager) ListInvoices(jsonData string, user bean.User)
 
 func (service *htlcQueryTxManager) GetLatestHT1aOrHE1b(msgData string, user bean.User) (data interface{}, err error) {
 	if tool.CheckIsString(&msgData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 	channelId := gjson.Get(msgData, "channel_id").Str
 	if tool.CheckIsString(&channelId) == false {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 	tx, _ := user.Db.Begin(true)
 	defer tx.Rollback()
 
 	commitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, channelId, user.PeerId)
 	if err != nil {
-		return nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 	ht1aOrHe1b := dao.HTLCTimeoutTxForAAndExecutionForB{}
 	_ = tx.Select(q.Eq("ChannelId", channelId), q.Eq("CommitmentTxId", commitmentTxInfo.Id)).First(&ht1aOrHe1b)
 	if ht1aOrHe1b.Id == 0 {
-		return nil, errors.New(enum.Tips_common_notFound)
+		return nil, enum.NewError(enum.ErrorCode_common_notFound)
 	}
 	_ = tx.Commit()
 	return ht1aOrHe1b, nil
@@ -71,16 +71,16 @@ func (service *htlcQueryTxManager) GetLatestHT1aOrHE1b(msgData string, user bean
 
 func (service *htlcQueryTxManager) GetHT1aOrHE1bBySomeCommitmentId(msgData string, user bean.User) (data interface{}, err error) {
 	if tool.CheckIsString(&msgData) == false {
-		return nil, errors.New(enum.Tips_common_empty + "msg data")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "msg data")
 	}
 
 	channelId := gjson.Get(msgData, "channel_id").Str
 	if tool.CheckIsString(&channelId) == false {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 	commitmentTxId := gjson.Get(msgData, "commitment_tx_id").Int()
 	if commitmentTxId < 1 {
-		return nil, errors.New(enum.Tips_common_wrong + "commitment_tx_id")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "commitment_tx_id")
 	}
 	tx, _ := user.Db.Begin(true)
 	defer tx.Rollback()
@@ -93,7 +93,7 @@ func (service *htlcQueryTxManager) GetHT1aOrHE1bBySomeCommitmentId(msgData strin
 	ht1aOrHe1b := dao.HTLCTimeoutTxForAAndExecutionForB{}
 	_ = tx.Select(q.Eq("ChannelId", channelId), q.Eq("CommitmentTxId", commitmentTransaction.Id)).First(&ht1aOrHe1b)
 	if ht1aOrHe1b.Id == 0 {
-		return nil, errors.New(enum.Tips_common_notFound)
+		return nil, enum.NewError(enum.ErrorCode_common_notFound)
 	}
 	_ = tx.Commit()
 	return ht1aOrHe1b, nil
@@ -2,6 +2,7 @@ package service
 
 import (
 	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/dao"
 )
 
 type commitmentTxOutputBean struct {
@@ -16,4 +17,10 @@ type commitmentTxOutputBean struct {
 
 var P2PLocalNodeId string
 
+// IsP2PNodeConnected whether the obd of the node id is connected by p2p, it is set when the p2p node starts
+var IsP2PNodeConnected = func(nodePeerId string) bool { return false }
+
+// CloseHtlcByObd close the htlc of the admin user by the keys kept by obd, it is set by the light client
+var CloseHtlcByObd = func(htlcTx dao.CommitmentTransaction, user bean.User) {}
+
 var OnlineUserMap = make(map[string]*bean.User)
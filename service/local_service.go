@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
@@ -72,14 +73,14 @@ func UpdateAdminLoginToken(oldToken, newToken string) error {
 
 func addRDTxToWaitDB(lastRevocableDeliveryTx *dao.RevocableDeliveryTransaction) (err error) {
 	if lastRevocableDeliveryTx == nil || tool.CheckIsString(&lastRevocableDeliveryTx.TxHex) == false {
-		return errors.New(enum.Tips_common_empty + "tx hex")
+		return enum.NewError(enum.ErrorCode_common_empty, "tx hex")
 	}
 	node := &dao.RDTxWaitingSend{}
 	count, err := obdGlobalDB.Select(
 		q.Eq("TransactionHex", lastRevocableDeliveryTx.TxHex)).
 		Count(node)
 	if count > 0 {
-		return errors.New(enum.Tips_common_savedBefore)
+		return enum.NewError(enum.ErrorCode_common_savedBefore)
 	}
 	node.TransactionHex = lastRevocableDeliveryTx.TxHex
 	node.Type = 0
@@ -192,9 +193,10 @@ func sendRdTx() {
 
 	for _, node := range nodes {
 		if tool.CheckIsString(&node.TransactionHex) {
-			_, err := conn2tracker.SendRawTransaction(node.TransactionHex)
+			_, err := conn2tracker.SendRawTransaction(context.Background(), node.TransactionHex)
 			if err == nil {
 				if node.Type == 1 {
+					publishHtlcTimeoutEvent(node.HtnxIdAndHtnxRdId[0])
 					_ = addHTRD1aTxToWaitDB(node.HtnxIdAndHtnxRdId)
 				}
 				_ = obdGlobalDB.UpdateField(&node, "IsEnable", false)
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+// the max count of the msgs waiting for one offline user
+const maxOutboxSizePerUser = 1024
+
+// the max count of the unexpired msgs from one sender, so that a sender can not fill the global db
+var maxOutboxSizePerSender = 256
+
+type outboxManager struct {
+	mu        sync.Mutex
+	listeners []func(item dao.OutboxMessage)
+}
+
+// OutboxService keep the p2p msgs to the offline users in the global db, until the users login or the msgs expire
+var OutboxService = outboxManager{}
+
+// AddListener the listener is called when the state of a msg is changed, so that the sender can be told
+func (service *outboxManager) AddListener(listener func(item dao.OutboxMessage)) {
+	service.mu.Lock()
+	service.listeners = append(service.listeners, listener)
+	service.mu.Unlock()
+}
+
+func (service *outboxManager) notify(item dao.OutboxMessage) {
+	service.mu.Lock()
+	listeners := service.listeners
+	service.mu.Unlock()
+	for _, listener := range listeners {
+		listener(item)
+	}
+}
+
+// Enqueue save the msg to the offline recipient, it expires after config.OutboxTTL.
+// the recipient must be a user who has logged in this obd.
+func (service *outboxManager) Enqueue(msg bean.RequestMessage, status bool) (*dao.OutboxMessage, error) {
+	if obdGlobalDB == nil {
+		return nil, enum.NewError(enum.ErrorCode_common_notFound, "global db")
+	}
+	if dao.DBService.ExistUserDB(msg.RecipientUserPeerId) == false {
+		return nil, enum.NewError(enum.ErrorCode_user_notExistOrOnline, msg.RecipientUserPeerId)
+	}
+	service.mu.Lock()
+	count, err := obdGlobalDB.Select(
+		q.Eq("RecipientUserPeerId", msg.RecipientUserPeerId),
+		q.Eq("State", dao.OutboxState_Queued)).Count(&dao.OutboxMessage{})
+	if err == nil && count >= maxOutboxSizePerUser {
+		service.mu.Unlock()
+		return nil, enum.NewError(enum.ErrorCode_user_outboxFull, msg.RecipientUserPeerId)
+	}
+	count, err = obdGlobalDB.Select(
+		q.Eq("SenderUserPeerId", msg.SenderUserPeerId),
+		q.Eq("State", dao.OutboxState_Queued),
+		q.Gt("ExpireAt", time.Now())).Count(&dao.OutboxMessage{})
+	if err == nil && count >= maxOutboxSizePerSender {
+		service.mu.Unlock()
+		return nil, enum.NewError(enum.ErrorCode_user_senderOutboxFull, msg.SenderUserPeerId)
+	}
+	item := &dao.OutboxMessage{
+		RecipientUserPeerId: msg.RecipientUserPeerId,
+		RecipientNodePeerId: msg.RecipientNodePeerId,
+		SenderUserPeerId:    msg.SenderUserPeerId,
+		SenderNodePeerId:    msg.SenderNodePeerId,
+		MsgType:             int(msg.Type),
+		Status:              status,
+		Data:                msg.Data,
+		State:               dao.OutboxState_Queued,
+		CreateAt:            time.Now(),
+		ExpireAt:            time.Now().Add(config.OutboxTTL),
+	}
+	err = obdGlobalDB.Save(item)
+	service.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	service.notify(*item)
+	return item, nil
+}
+
+// TakeQueued return the unexpired msgs to the user in the order they are queued, and expire the others.
+// the caller must Finish every returned msg.
+func (service *outboxManager) TakeQueued(userPeerId string) (items []dao.OutboxMessage) {
+	if obdGlobalDB == nil {
+		return nil
+	}
+	var queued []dao.OutboxMessage
+	service.mu.Lock()
+	_ = obdGlobalDB.Select(
+		q.Eq("RecipientUserPeerId", userPeerId),
+		q.Eq("State", dao.OutboxState_Queued)).OrderBy("Id").Find(&queued)
+	var expired []dao.OutboxMessage
+	for _, item := range queued {
+		if time.Now().After(item.ExpireAt) {
+			if service.updateState(&item, dao.OutboxState_Expired) == nil {
+				expired = append(expired, item)
+			}
+			continue
+		}
+		items = append(items, item)
+	}
+	service.mu.Unlock()
+	for _, item := range expired {
+		service.notify(item)
+	}
+	return items
+}
+
+// Finish the msg is delivered, or failed to deliver
+func (service *outboxManager) Finish(item dao.OutboxMessage, err error) {
+	state := dao.OutboxState_Delivered
+	if err != nil {
+		log.Println("fail to deliver the queued msg", item.Id, "to", item.RecipientUserPeerId, err)
+		state = dao.OutboxState_Failed
+	}
+	if obdGlobalDB == nil || service.updateState(&item, state) != nil {
+		return
+	}
+	service.notify(item)
+}
+
+func (service *outboxManager) updateState(item *dao.OutboxMessage, state dao.OutboxState) error {
+	item.State = state
+	item.FinishAt = time.Now()
+	return obdGlobalDB.Update(item)
+}
+
+// expire the msgs to the users who do not login in time
+func expireOutbox() {
+	if obdGlobalDB == nil {
+		return
+	}
+	var queued []dao.OutboxMessage
+	var expired []dao.OutboxMessage
+	OutboxService.mu.Lock()
+	_ = obdGlobalDB.Select(
+		q.Eq("State", dao.OutboxState_Queued),
+		q.Lt("ExpireAt", time.Now())).OrderBy("Id").Find(&queued)
+	for _, item := range queued {
+		if OutboxService.updateState(&item, dao.OutboxState_Expired) == nil {
+			expired = append(expired, item)
+		}
+	}
+	OutboxService.mu.Unlock()
+	for _, item := range expired {
+		OutboxService.notify(item)
+	}
+}
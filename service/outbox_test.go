@@ -0,0 +1,109 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm/q"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+)
+
+func TestOutboxService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.DataDirectory = dir
+	obdGlobalDB, err = dao.DBService.GetGlobalDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = dao.DBService.CloseGlobalDB()
+		obdGlobalDB = nil
+	}()
+
+	// the msgs are queued only for the users of this obd
+	if err = ioutil.WriteFile(dir+"/"+config.ChainNodeType+"/user_bob.db", nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	unknownMsg := bean.RequestMessage{Type: enum.MsgType_ChannelOpen_32, SenderUserPeerId: "alice", RecipientUserPeerId: "carol"}
+	if _, err = OutboxService.Enqueue(unknownMsg, true); enum.ErrorCodeOf(err) != enum.ErrorCode_user_notExistOrOnline {
+		t.Fatalf("got %v, want the unknown recipient", err)
+	}
+
+	var states []dao.OutboxState
+	OutboxService.AddListener(func(item dao.OutboxMessage) {
+		states = append(states, item.State)
+	})
+
+	msg := bean.RequestMessage{Type: enum.MsgType_ChannelOpen_32, SenderUserPeerId: "alice", RecipientUserPeerId: "bob", Data: "1"}
+	if _, err = OutboxService.Enqueue(msg, true); err != nil {
+		t.Fatal(err)
+	}
+	msg.Data = "2"
+	if _, err = OutboxService.Enqueue(msg, true); err != nil {
+		t.Fatal(err)
+	}
+	config.OutboxTTL = -time.Second
+	msg.Data = "3"
+	if _, err = OutboxService.Enqueue(msg, true); err != nil {
+		t.Fatal(err)
+	}
+	config.OutboxTTL = 24 * time.Hour
+
+	items := OutboxService.TakeQueued("bob")
+	if len(items) != 2 || items[0].Data != "1" || items[1].Data != "2" {
+		t.Fatal("wrong queued msgs", items)
+	}
+	for _, item := range items {
+		OutboxService.Finish(item, nil)
+	}
+	if items = OutboxService.TakeQueued("bob"); len(items) != 0 {
+		t.Fatal("the delivered msgs are queued again", items)
+	}
+
+	expected := []dao.OutboxState{dao.OutboxState_Queued, dao.OutboxState_Queued, dao.OutboxState_Queued,
+		dao.OutboxState_Expired, dao.OutboxState_Delivered, dao.OutboxState_Delivered}
+	if len(states) != len(expected) {
+		t.Fatal("wrong states", states)
+	}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Fatal("wrong states", states)
+		}
+	}
+
+	// a sender can not queue more msgs than its cap, until its msgs expire
+	maxOutboxSize := maxOutboxSizePerSender
+	maxOutboxSizePerSender = 2
+	defer func() { maxOutboxSizePerSender = maxOutboxSize }()
+	msg = bean.RequestMessage{Type: enum.MsgType_ChannelOpen_32, SenderUserPeerId: "mallory", RecipientUserPeerId: "bob"}
+	for i := 0; i < maxOutboxSizePerSender; i++ {
+		if _, err = OutboxService.Enqueue(msg, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = OutboxService.Enqueue(msg, true); enum.ErrorCodeOf(err) != enum.ErrorCode_user_senderOutboxFull {
+		t.Fatalf("got %v, want the full outbox of the sender", err)
+	}
+	msg.SenderUserPeerId = "alice"
+	if _, err = OutboxService.Enqueue(msg, true); err != nil {
+		t.Fatal(err)
+	}
+	var queued []dao.OutboxMessage
+	_ = obdGlobalDB.Select(q.Eq("SenderUserPeerId", "mallory")).Find(&queued)
+	for i := range queued {
+		_ = obdGlobalDB.UpdateField(&queued[i], "ExpireAt", time.Now().Add(-time.Second))
+	}
+	msg.SenderUserPeerId = "mallory"
+	if _, err = OutboxService.Enqueue(msg, true); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,7 +3,6 @@ package service
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
@@ -64,13 +63,14 @@ func (this *commitmentTxSignedManager) BeforeBobSignCommitmentTransactionAtBobSi
 	sendChannelStateToTracker(*channelInfo, *commitmentTxInfo)
 
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 	return retData, nil
 }
 
 // step 4 协议号：100352 bob签收这次转账
 func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction(msg bean.RequestMessage, signer *bean.User) (retData interface{}, needSignC2b bool, err error) {
 	if tool.CheckIsString(&msg.Data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -83,7 +83,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	}
 
 	if tool.CheckIsString(&reqData.MsgHash) == false {
-		err = errors.New(enum.Tips_common_wrong + "msg_hash")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "msg_hash")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -98,10 +98,10 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	//region 确认是给自己的信息
 	message, err := messageService.getMsgUseTx(tx, reqData.MsgHash)
 	if err != nil {
-		return nil, false, errors.New(enum.Tips_common_invilidMsgHash)
+		return nil, false, enum.NewError(enum.ErrorCode_common_invilidMsgHash)
 	}
 	if message.Receiver != signer.PeerId {
-		return nil, false, errors.New(enum.Tips_rsmc_notTargetUser)
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	c2aDataJson := &bean.AliceRequestToCreateCommitmentTxOfP2p{}
@@ -110,14 +110,14 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	//endregion
 
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, false, err
 	}
 
 	channelInfo := getChannelInfoByChannelId(tx, reqData.ChannelId, signer.PeerId)
 	if channelInfo == nil {
-		err = errors.New(enum.Tips_funding_notFoundChannelByChannelId + reqData.ChannelId)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundChannelByChannelId, reqData.ChannelId)
 		log.Println(err)
 		return nil, false, err
 	}
@@ -129,7 +129,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	payeeRevokeAndAcknowledgeCommitment := &dao.PayeeRevokeAndAcknowledgeCommitment{}
 	_ = tx.Select(q.Eq("ChannelId", channelInfo.ChannelId), q.Eq("CommitmentTxHash", reqData.MsgHash)).First(payeeRevokeAndAcknowledgeCommitment)
 	if payeeRevokeAndAcknowledgeCommitment.Id > 0 {
-		return nil, false, errors.New(enum.Tips_rsmc_notDoItAgain)
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_notDoItAgain)
 	}
 
 	//Make sure who creates the transaction, who will sign the transaction.
@@ -143,7 +143,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	}
 
 	if targetUser != msg.RecipientUserPeerId {
-		return nil, false, errors.New(enum.Tips_rsmc_notTargetUser)
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_notTargetUser)
 	}
 
 	toAliceP2pData := bean.PayeeSignCommitmentTxOfP2p{}
@@ -169,6 +169,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 			log.Println(err)
 			return nil, false, err
 		}
+		publishChannelStateEvent(signer.PeerId, *channelInfo)
 		return retData, false, nil
 	}
 
@@ -185,7 +186,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 
 	//for rsmc
 	if _, err = getAddressFromPubKey(reqData.CurrTempAddressPubKey); err != nil {
-		err = errors.New(enum.Tips_common_wrong + "curr_temp_address_pub_key")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "curr_temp_address_pub_key")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -193,7 +194,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	//for br
 	creatorLastTempAddressPrivateKey := c2aDataJson.LastTempAddressPrivateKey
 	if tool.CheckIsString(&creatorLastTempAddressPrivateKey) == false {
-		err = errors.New(enum.Tips_common_empty + "the starter's last temp address private key")
+		err = enum.NewError(enum.ErrorCode_common_empty, "the starter's last temp address private key")
 		log.Println(err)
 		return nil, false, err
 	}
@@ -201,7 +202,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	// get the funding transaction
 	fundingTransaction := getFundingTransactionByChannelId(tx, channelInfo.ChannelId, signer.PeerId)
 	if fundingTransaction == nil {
-		return nil, false, errors.New(enum.Tips_common_notFound + "fundingTransaction")
+		return nil, false, enum.NewError(enum.ErrorCode_common_notFound, "fundingTransaction")
 	}
 
 	toAliceP2pData.LastTempAddressPrivateKey = reqData.LastTempAddressPrivateKey
@@ -213,11 +214,11 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	if tool.CheckIsString(&c2aDataJson.RsmcRawData.Hex) {
 		signedRsmcHex = reqData.C2aRsmcSignedHex
 		if pass, _ := omnicore.CheckMultiSign(signedRsmcHex, 2); pass == false {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_rsmc_signed_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_rsmc_signed_hex")
 		}
 
 		if verifyCompleteSignHex(c2aDataJson.RsmcRawData.Inputs, signedRsmcHex) != nil {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_rsmc_signed_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_rsmc_signed_hex")
 		}
 
 		aliceRsmcTxId = omnicore.GetTxId(signedRsmcHex)
@@ -244,11 +245,11 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	signedToCounterpartyTxHex := reqData.C2aCounterpartySignedHex
 	if tool.CheckIsString(&c2aDataJson.CounterpartyRawData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedToCounterpartyTxHex, 2); pass == false {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "to_counterparty_tx_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "to_counterparty_tx_hex")
 		}
 
 		if verifyCompleteSignHex(c2aDataJson.CounterpartyRawData.Inputs, signedToCounterpartyTxHex) != nil {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_rsmc_signed_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_rsmc_signed_hex")
 		}
 	}
 	toAliceP2pData.C2aSignedToCounterpartyTxHex = signedToCounterpartyTxHex
@@ -259,29 +260,29 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 	isFirstRequest := false
 	if latestCommitmentTxInfo != nil && latestCommitmentTxInfo.Id > 0 {
 		if latestCommitmentTxInfo.TxType != dao.CommitmentTransactionType_Rsmc {
-			return nil, false, errors.New(enum.Tips_rsmc_errorCommitmentTxType + strconv.Itoa(int(latestCommitmentTxInfo.TxType)))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_errorCommitmentTxType, strconv.Itoa(int(latestCommitmentTxInfo.TxType)))
 		}
 
 		if latestCommitmentTxInfo.CurrState != dao.TxInfoState_CreateAndSign && latestCommitmentTxInfo.CurrState != dao.TxInfoState_Create {
-			return nil, false, errors.New(enum.Tips_rsmc_errorCommitmentTxState + strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_errorCommitmentTxState, strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
 		}
 
 		if latestCommitmentTxInfo.CurrState == dao.TxInfoState_Create && latestCommitmentTxInfo.LastCommitmentTxId > 0 {
 			lastCommitmentTx := &dao.CommitmentTransaction{}
 			err = tx.Select(q.Eq("Id", latestCommitmentTxInfo.LastCommitmentTxId)).First(lastCommitmentTx)
 			if err != nil {
-				return nil, false, errors.New(enum.Tips_common_notFound + "lastCommitmentTx")
+				return nil, false, enum.NewError(enum.ErrorCode_common_notFound, "lastCommitmentTx")
 			}
 			_, err = omnicore.GetPubKeyFromWifAndCheck(reqData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey)
 			if err != nil {
-				return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey))
+				return nil, false, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, lastCommitmentTx.RSMCTempAddressPubKey)
 			}
 		}
 
 		if latestCommitmentTxInfo.CurrState == dao.TxInfoState_CreateAndSign { //有上一次的承诺交易
 			_, err = omnicore.GetPubKeyFromWifAndCheck(reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 			if err != nil {
-				return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+				return nil, false, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 			}
 			isFirstRequest = true
 		}
@@ -321,7 +322,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 		//endregion
 	} else {
 		if reqData.CurrTempAddressPubKey != latestCommitmentTxInfo.RSMCTempAddressPubKey {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 
 		c2bAmountToCounterparty = latestCommitmentTxInfo.AmountToCounterparty
@@ -353,12 +354,12 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			c2bAmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&c2aRsmcRedeemScript)
 		if err != nil {
 			log.Println(err)
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_failToCreate, "RD raw transacation"))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_failToCreate, "RD raw transacation")
 		}
 
 		c2aRdRawData := bean.NeedClientSignTxData{}
@@ -433,7 +434,7 @@ func (this *commitmentTxSignedManager) RevokeAndAcknowledgeCommitmentTransaction
 // step 5 协议号：100361 完成后推送352协议 bob对C2b的Rsmc，toBob和c2a的Rd和C2a的Br进行签名
 func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data string, user *bean.User) (toBob, retData interface{}, err error) {
 	if tool.CheckIsString(&data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -441,7 +442,7 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 	_ = json.Unmarshal([]byte(data), &signedDataForC2b)
 
 	if tool.CheckIsString(&signedDataForC2b.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -457,18 +458,18 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 	cacheDataForTx := &dao.CacheDataForTx{}
 	tx.Select(q.Eq("KeyName", user.PeerId+"_"+signedDataForC2b.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	p2pData := &bean.PayeeSignCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, p2pData)
 
 	if len(p2pData.ChannelId) == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&p2pData.C2bRsmcTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC2b.C2bRsmcSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_rsmc_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_rsmc_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -476,7 +477,7 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 
 	if tool.CheckIsString(&p2pData.C2bCounterpartyTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC2b.C2bCounterpartySignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_counterparty_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_counterparty_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -484,7 +485,7 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 
 	if tool.CheckIsString(&p2pData.C2aRdTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC2b.C2aRdSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2a_rd_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2a_rd_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -492,13 +493,13 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 
 	if tool.CheckIsString(&signedDataForC2b.C2aBrSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC2b.C2aBrSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "c2a_br_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c2a_br_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
 
 		if signedDataForC2b.C2aBrId == 0 {
-			err = errors.New(enum.Tips_common_wrong + "c2a_br_id")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "c2a_br_id")
 			log.Println(err)
 			return nil, nil, err
 		}
@@ -506,7 +507,7 @@ func (this *commitmentTxSignedManager) OnBobSignC2bTransactionAtBobSide(data str
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, signedDataForC2b.ChannelId, user.PeerId)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	if len(signedDataForC2b.C2bRsmcSignedHex) > 0 {
@@ -551,20 +552,20 @@ func (this *commitmentTxSignedManager) OnGetAliceSignC2bTransactionAtBobSide(dat
 	cacheDataForTx := &dao.CacheDataForTx{}
 	user.Db.Select(q.Eq("KeyName", user.PeerId+"_"+aliceSignedC2bTxDataP2p.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return retData, errors.New(enum.Tips_common_wrong + "channel_id")
+		return retData, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	cacheDataFrom352 := &bean.PayeeSignCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, cacheDataFrom352)
 
 	if tool.CheckIsString(&cacheDataFrom352.C2bRsmcTxData.Hex) {
 		if verifyCompleteSignHex(cacheDataFrom352.C2bRsmcTxData.Inputs, aliceSignedC2bTxDataP2p.C2bRsmcSignedHex) != nil {
-			return retData, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2b_rsmc_signed_hex"))
+			return retData, enum.NewError(enum.ErrorCode_common_failToSign, "c2b_rsmc_signed_hex")
 		}
 	}
 
 	if tool.CheckIsString(&cacheDataFrom352.C2bCounterpartyTxData.Hex) {
 		if verifyCompleteSignHex(cacheDataFrom352.C2bCounterpartyTxData.Inputs, aliceSignedC2bTxDataP2p.C2bCounterpartySignedHex) != nil {
-			return retData, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2b_signed_to_counterparty_tx_hex"))
+			return retData, enum.NewError(enum.ErrorCode_common_failToSign, "c2b_signed_to_counterparty_tx_hex")
 		}
 	}
 
@@ -595,24 +596,24 @@ func (this *commitmentTxSignedManager) BobSignC2bRdAtBobSide(data string, user *
 	cacheDataForTx := &dao.CacheDataForTx{}
 	user.Db.Select(q.Eq("KeyName", user.PeerId+"_353_"+bobSignedRdTxForC2b.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	dataFrom353 := &bean.AliceSignedC2bTxDataP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, dataFrom353)
 	if len(dataFrom353.ChannelId) == 0 {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if tool.CheckIsString(&dataFrom353.C2bRdPartialData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(bobSignedRdTxForC2b.C2bRdSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_rd_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_rd_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
 
 		if tool.CheckIsString(&dataFrom353.C2bRdPartialData.Hex) {
 			if verifyCompleteSignHex(dataFrom353.C2bRdPartialData.Inputs, bobSignedRdTxForC2b.C2bRdSignedHex) != nil {
-				return retData, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2b_rd_signed_hex"))
+				return retData, enum.NewError(enum.ErrorCode_common_failToSign, "c2b_rd_signed_hex")
 			}
 		}
 	}
@@ -695,6 +696,8 @@ func (this *commitmentTxSignedManager) BobSignC2bRdAtBobSide(data string, user *
 
 	//同步通道信息到tracker
 	sendChannelStateToTracker(*channelInfo, *latestCommitmentTxInfo)
+	publishCommitmentTxSignedEvent(user.PeerId, *latestCommitmentTxInfo)
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 	log.Println("end rsmc step 10", time.Now())
 	return latestCommitmentTxInfo, nil
 }
@@ -1,9 +1,9 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
 	"github.com/omnilaboratory/obd/conn"
@@ -28,7 +28,7 @@ var CommitmentTxService commitmentTxManager
 // step 1 协议号：100351  当发起转账人alice申请发起转账
 func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMessage, creator *bean.User) (retData interface{}, needSign bool, err error) {
 	if tool.CheckIsString(&msg.Data) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "msg.Data")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "msg.Data")
 	}
 	log.Println("begin rsmc step 1 ", time.Now())
 	reqData := &bean.RequestCreateCommitmentTx{}
@@ -37,15 +37,15 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 		return nil, false, err
 	}
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + " channel_id")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, " channel_id")
 	}
 
 	if tool.CheckIsString(&reqData.LastTempAddressPrivateKey) == false {
-		return nil, false, errors.New(enum.Tips_common_empty + "last_temp_address_private_key")
+		return nil, false, enum.NewError(enum.ErrorCode_common_empty, "last_temp_address_private_key")
 	}
 
 	if reqData.Amount <= 0 {
-		return nil, false, errors.New(enum.Tips_common_wrong + "payment amount")
+		return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "payment amount")
 	}
 
 	tx, err := creator.Db.Begin(true)
@@ -57,13 +57,13 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 
 	channelInfo := getChannelInfoByChannelId(tx, reqData.ChannelId, creator.PeerId)
 	if channelInfo == nil {
-		err = errors.New(enum.Tips_funding_notFoundChannelByChannelId + reqData.ChannelId)
+		err = enum.NewError(enum.ErrorCode_funding_notFoundChannelByChannelId, reqData.ChannelId)
 		log.Println(err)
 		return nil, false, err
 	}
 
 	if channelInfo.CurrState == bean.ChannelState_NewTx {
-		return nil, false, errors.New(enum.Tips_common_newTxMsg)
+		return nil, false, enum.NewError(enum.ErrorCode_common_newTxMsg)
 	}
 
 	fundingTransaction := getFundingTransactionByChannelId(tx, channelInfo.ChannelId, creator.PeerId)
@@ -74,7 +74,7 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 			return nil, false, err
 		}
 		if pass == false {
-			err = errors.New(enum.Tips_rsmc_broadcastedChannel)
+			err = enum.NewError(enum.ErrorCode_rsmc_broadcastedChannel)
 			log.Println(err)
 			return nil, false, err
 		}
@@ -90,29 +90,29 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 	}
 
 	if targetUser != msg.RecipientUserPeerId {
-		return nil, false, errors.New(enum.Tips_rsmc_notTargetUser + msg.RecipientUserPeerId)
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_notTargetUser, msg.RecipientUserPeerId)
 	}
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, reqData.ChannelId, creator.PeerId)
 	if err != nil {
-		return nil, false, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, false, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	if latestCommitmentTxInfo.CurrState == dao.TxInfoState_Init {
 		_ = tx.DeleteStruct(latestCommitmentTxInfo)
 		latestCommitmentTxInfo, err = getLatestCommitmentTxUseDbTx(tx, reqData.ChannelId, creator.PeerId)
 		if err != nil {
-			return nil, false, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+			return nil, false, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 		}
 	}
 
 	if latestCommitmentTxInfo.TxType != dao.CommitmentTransactionType_Rsmc {
-		return nil, false, errors.New(enum.Tips_rsmc_errorCommitmentTxType + strconv.Itoa(int(latestCommitmentTxInfo.TxType)))
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_errorCommitmentTxType, strconv.Itoa(int(latestCommitmentTxInfo.TxType)))
 	}
 
 	if latestCommitmentTxInfo.CurrState != dao.TxInfoState_CreateAndSign &&
 		latestCommitmentTxInfo.CurrState != dao.TxInfoState_Create {
-		return nil, false, errors.New(enum.Tips_rsmc_errorCommitmentTxState + strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
+		return nil, false, enum.NewError(enum.ErrorCode_rsmc_errorCommitmentTxState, strconv.Itoa(int(latestCommitmentTxInfo.CurrState)))
 	}
 
 	//region check input data 检测输入数据
@@ -120,15 +120,15 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 	if latestCommitmentTxInfo.CurrState == dao.TxInfoState_CreateAndSign {
 		balance := latestCommitmentTxInfo.AmountToRSMC
 		if balance < reqData.Amount {
-			return nil, false, errors.New(enum.Tips_rsmc_notEnoughBalance)
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_notEnoughBalance)
 		}
 
 		if _, err = omnicore.GetPubKeyFromWifAndCheck(reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey); err != nil {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_wrongPrivateKeyForLast, reqData.LastTempAddressPrivateKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 	} else {
 		if reqData.CurrTempAddressPubKey != latestCommitmentTxInfo.RSMCTempAddressPubKey {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey))
+			return nil, false, enum.NewError(enum.ErrorCode_rsmc_notSameValueWhenCreate, reqData.CurrTempAddressPubKey, latestCommitmentTxInfo.RSMCTempAddressPubKey)
 		}
 		lastCommitmentTx := &dao.CommitmentTransaction{}
 		_ = tx.One("Id", latestCommitmentTxInfo.LastCommitmentTxId, lastCommitmentTx)
@@ -138,7 +138,7 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 	}
 
 	if _, err = getAddressFromPubKey(reqData.CurrTempAddressPubKey); err != nil {
-		return nil, false, errors.New(enum.Tips_common_wrong + "curr_temp_address_pub_key")
+		return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "curr_temp_address_pub_key")
 	}
 	//endregion
 
@@ -213,7 +213,7 @@ func (this *commitmentTxManager) CommitmentTransactionCreated(msg bean.RequestMe
 func (this *commitmentTxManager) OnAliceSignC2aRawTxAtAliceSide(msg bean.RequestMessage, user *bean.User) (toAlice, retData interface{}, err error) {
 	log.Println("rsmc step 2 ", time.Now())
 	if tool.CheckIsString(&msg.Data) == false {
-		err = errors.New(enum.Tips_common_empty + "msg.data")
+		err = enum.NewError(enum.ErrorCode_common_empty, "msg.data")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -221,7 +221,7 @@ func (this *commitmentTxManager) OnAliceSignC2aRawTxAtAliceSide(msg bean.Request
 	_ = json.Unmarshal([]byte(msg.Data), &signedDataForC2a)
 
 	if tool.CheckIsString(&signedDataForC2a.ChannelId) == false {
-		err = errors.New(enum.Tips_common_empty + "channel_id")
+		err = enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 		log.Println(err)
 		return nil, nil, err
 	}
@@ -236,37 +236,37 @@ func (this *commitmentTxManager) OnAliceSignC2aRawTxAtAliceSide(msg bean.Request
 	cacheDataForTx := &dao.CacheDataForTx{}
 	_ = tx.Select(q.Eq("KeyName", user.PeerId+"_"+signedDataForC2a.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	p2pData := &bean.AliceRequestToCreateCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, p2pData)
 	if &p2pData == nil {
-		return nil, nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&signedDataForC2a.RsmcSignedHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedDataForC2a.RsmcSignedHex, 1); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "rsmc_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "rsmc_signed_hex")
 			log.Println(err)
 			return nil, nil, err
 		}
 	}
 
 	if tool.CheckIsString(&signedDataForC2a.CounterpartySignedHex) == false {
-		err = errors.New(enum.Tips_common_empty + "counterparty_signed_hex")
+		err = enum.NewError(enum.ErrorCode_common_empty, "counterparty_signed_hex")
 		log.Println(err)
 		return nil, nil, err
 	}
 
 	if pass, _ := omnicore.CheckMultiSign(signedDataForC2a.CounterpartySignedHex, 1); pass == false {
-		err = errors.New(enum.Tips_common_wrong + "counterparty_signed_hex")
+		err = enum.NewError(enum.ErrorCode_common_wrong, "counterparty_signed_hex")
 		log.Println(err)
 		return nil, nil, err
 	}
 
 	latestCommitmentTxInfo, err := getLatestCommitmentTxUseDbTx(tx, signedDataForC2a.ChannelId, user.PeerId)
 	if err != nil {
-		return nil, nil, errors.New(enum.Tips_channel_notFoundLatestCommitmentTx)
+		return nil, nil, enum.NewError(enum.ErrorCode_channel_notFoundLatestCommitmentTx)
 	}
 
 	if len(latestCommitmentTxInfo.RSMCTxHex) > 0 {
@@ -331,23 +331,23 @@ func (this *commitmentTxManager) OnGetBobC2bPartialSignTxAtAliceSide(msg bean.Re
 	cacheDataForTx := &dao.CacheDataForTx{}
 	tx.Select(q.Eq("KeyName", user.PeerId+"_"+channelInfo.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, false, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	cacheDataFrom351 := &bean.AliceRequestToCreateCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, cacheDataFrom351)
 	if &cacheDataFrom351 == nil {
-		return nil, false, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, false, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 
 	if tool.CheckIsString(&cacheDataFrom351.RsmcRawData.Hex) {
 		if verifyCompleteSignHex(cacheDataFrom351.RsmcRawData.Inputs, dataFromP2p352.C2aSignedRsmcHex) != nil {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_rsmc_signed_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_rsmc_signed_hex")
 		}
 	}
 
 	if tool.CheckIsString(&cacheDataFrom351.CounterpartyRawData.Hex) {
 		if verifyCompleteSignHex(cacheDataFrom351.CounterpartyRawData.Inputs, dataFromP2p352.C2aSignedToCounterpartyTxHex) != nil {
-			return nil, false, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_signed_to_counterparty_tx_hex"))
+			return nil, false, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_signed_to_counterparty_tx_hex")
 		}
 	}
 
@@ -365,6 +365,8 @@ func (this *commitmentTxManager) OnGetBobC2bPartialSignTxAtAliceSide(msg bean.Re
 	_ = tx.Save(cacheDataForTx)
 
 	_ = tx.Commit()
+	publishChannelStateEvent(user.PeerId, *channelInfo)
+
 	needAliceSignRmscTxForC2b := bean.NeedAliceSignRsmcTxForC2b{}
 	needAliceSignRmscTxForC2b.ChannelId = dataFromP2p352.ChannelId
 	needAliceSignRmscTxForC2b.C2bRsmcPartialData = dataFromP2p352.C2bRsmcTxData
@@ -390,50 +392,50 @@ func (this *commitmentTxManager) OnAliceSignedC2bTxAtAliceSide(data string, user
 	cacheDataForTx := &dao.CacheDataForTx{}
 	tx.Select(q.Eq("KeyName", user.PeerId+"_352_"+aliceSignedRmscTxForC2b.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, errors.New(enum.Tips_common_wrong + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_wrong, "channel_id")
 	}
 	dataFromP2p352 := &bean.PayeeSignCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, dataFromP2p352)
 
 	if len(dataFromP2p352.ChannelId) == 0 {
-		return nil, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	if tool.CheckIsString(&dataFromP2p352.C2bRsmcTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedRmscTxForC2b.C2bRsmcSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_rsmc_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_rsmc_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
 
 		if verifyCompleteSignHex(dataFromP2p352.C2bRsmcTxData.Inputs, aliceSignedRmscTxForC2b.C2bRsmcSignedHex) != nil {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2b_rsmc_signed_hex"))
+			return nil, enum.NewError(enum.ErrorCode_common_failToSign, "c2b_rsmc_signed_hex")
 		}
 	}
 	dataFromP2p352.C2bRsmcTxData.Hex = aliceSignedRmscTxForC2b.C2bRsmcSignedHex
 
 	if tool.CheckIsString(&dataFromP2p352.C2bCounterpartyTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedRmscTxForC2b.C2bCounterpartySignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2b_counterparty_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2b_counterparty_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
 
 		if verifyCompleteSignHex(dataFromP2p352.C2bCounterpartyTxData.Inputs, aliceSignedRmscTxForC2b.C2bCounterpartySignedHex) != nil {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2b_counterparty_signed_hex"))
+			return nil, enum.NewError(enum.ErrorCode_common_failToSign, "c2b_counterparty_signed_hex")
 		}
 	}
 	dataFromP2p352.C2bCounterpartyTxData.Hex = aliceSignedRmscTxForC2b.C2bCounterpartySignedHex
 
 	if tool.CheckIsString(&dataFromP2p352.C2aRdTxData.Hex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceSignedRmscTxForC2b.C2aRdSignedHex, 2); pass == false {
-			err = errors.New(enum.Tips_common_wrong + "signed c2a_rd_signed_hex")
+			err = enum.NewError(enum.ErrorCode_common_wrong, "signed c2a_rd_signed_hex")
 			log.Println(err)
 			return nil, err
 		}
 
 		if verifyCompleteSignHex(dataFromP2p352.C2aRdTxData.Inputs, aliceSignedRmscTxForC2b.C2aRdSignedHex) != nil {
-			return nil, errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "c2a_rd_signed_hex"))
+			return nil, enum.NewError(enum.ErrorCode_common_failToSign, "c2a_rd_signed_hex")
 		}
 
 	}
@@ -510,7 +512,7 @@ func (this *commitmentTxManager) OnAliceSignedC2bTxAtAliceSide(data string, user
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			latestCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&c2bRsmcRedeemScript)
 		if err != nil {
@@ -574,12 +576,12 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 	cacheDataForTx := &dao.CacheDataForTx{}
 	tx.Select(q.Eq("KeyName", user.PeerId+"_352_"+aliceSignedRdTxForC2b.ChannelId)).First(cacheDataForTx)
 	if cacheDataForTx.Id == 0 {
-		return nil, nil, false, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, nil, false, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 	dataFromP2p352 := &bean.PayeeSignCommitmentTxOfP2p{}
 	_ = json.Unmarshal(cacheDataForTx.Data, dataFromP2p352)
 	if len(dataFromP2p352.ChannelId) == 0 {
-		return nil, nil, false, errors.New(enum.Tips_common_empty + "channel_id")
+		return nil, nil, false, enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 
 	//region 检测传入数据
@@ -627,28 +629,28 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 	var c2aSignedRsmcHex = dataFromP2p352.C2aSignedRsmcHex
 	if tool.CheckIsString(&c2aSignedRsmcHex) {
 		if pass, _ := omnicore.CheckMultiSign(c2aSignedRsmcHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2a_signed_rsmc_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2a_signed_rsmc_hex")
 		}
 	}
 
 	var signedToCounterpartyHex = dataFromP2p352.C2aSignedToCounterpartyTxHex
 	if tool.CheckIsString(&signedToCounterpartyHex) {
 		if pass, _ := omnicore.CheckMultiSign(signedToCounterpartyHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2a_signed_to_counterparty_tx_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2a_signed_to_counterparty_tx_hex")
 		}
 	}
 
 	var aliceRdHex = dataFromP2p352.C2aRdTxData.Hex
 	if tool.CheckIsString(&aliceRdHex) {
 		if pass, _ := omnicore.CheckMultiSign(aliceRdHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2a_rd_signed_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2a_rd_signed_hex")
 		}
 	}
 
 	var bobRsmcHex = dataFromP2p352.C2bRsmcTxData.Hex
 	if tool.CheckIsString(&bobRsmcHex) {
 		if pass, _ := omnicore.CheckMultiSign(bobRsmcHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2b_rsmc_signed_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2b_rsmc_signed_hex")
 		}
 	}
 
@@ -662,7 +664,7 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 	var c2bToCounterpartyTxHex = dataFromP2p352.C2bCounterpartyTxData.Hex
 	if len(c2bToCounterpartyTxHex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(c2bToCounterpartyTxHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2b_counterparty_tx_data_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2b_counterparty_tx_data_hex")
 		}
 	}
 	//endregion
@@ -742,7 +744,7 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 	c2bSignedRsmcHex := dataFromP2p352.C2bRsmcTxData.Hex
 	if len(c2bSignedRsmcHex) > 0 {
 		if pass, _ := omnicore.CheckMultiSign(c2bSignedRsmcHex, 2); pass == false {
-			return nil, nil, false, errors.New(enum.Tips_common_wrong + "c2b_rsmc_tx_data_hex")
+			return nil, nil, false, enum.NewError(enum.ErrorCode_common_wrong, "c2b_rsmc_tx_data_hex")
 		}
 		err = checkBobRsmcData(c2bSignedRsmcHex, c2bRsmcMultiAddress, latestCommitmentTxInfo)
 		if err != nil {
@@ -771,7 +773,7 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			latestCommitmentTxInfo.AmountToCounterparty,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			1000,
 			&c2bRsmcRedeemScript)
 		if err != nil {
@@ -798,6 +800,8 @@ func (this *commitmentTxManager) OnAliceSignedC2b_RDTxAtAliceSide(data string, u
 
 	//endregion
 	_ = tx.Commit()
+	publishCommitmentTxSignedEvent(user.PeerId, *latestCommitmentTxInfo)
+	publishChannelStateEvent(user.PeerId, *channelInfo)
 
 	bobData.C2bCounterpartySignedHex = c2bToCounterpartyTxHex
 	bobData.ChannelId = channelId
@@ -835,7 +839,7 @@ func (this *commitmentTxManager) SendSomeCommitmentById(data string, user *bean.
 		return nil, errors.New("channel has been closed before by someone")
 	}
 
-	transactionsStr, err := conn2tracker.OmniListTransactions(channelInfo.ChannelAddress)
+	transactionsStr, err := conn2tracker.OmniListTransactions(context.Background(), channelInfo.ChannelAddress)
 	if err != nil || transactionsStr == "" {
 		return nil, err
 	}
@@ -843,7 +847,7 @@ func (this *commitmentTxManager) SendSomeCommitmentById(data string, user *bean.
 	//region 广播承诺交易 最近的rsmc的资产分配交易 因为是omni资产，承诺交易被拆分成了两个独立的交易
 	if commitmentTransaction.TxType == dao.CommitmentTransactionType_Rsmc {
 		if tool.CheckIsString(&commitmentTransaction.RSMCTxHex) {
-			commitmentTxid, err := conn2tracker.SendRawTransaction(commitmentTransaction.RSMCTxHex)
+			commitmentTxid, err := conn2tracker.SendRawTransaction(context.Background(), commitmentTransaction.RSMCTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -851,7 +855,7 @@ func (this *commitmentTxManager) SendSomeCommitmentById(data string, user *bean.
 			log.Println(commitmentTxid)
 		}
 		if tool.CheckIsString(&commitmentTransaction.ToCounterpartyTxHex) {
-			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(commitmentTransaction.ToCounterpartyTxHex)
+			commitmentTxidToBob, err := conn2tracker.SendRawTransaction(context.Background(), commitmentTransaction.ToCounterpartyTxHex)
 			if err != nil {
 				log.Println(err)
 				return nil, err
@@ -17,7 +17,7 @@ type requestGetInfoByChannelId struct {
 
 func getChannelIdFromJson(jsonData string) (channelId string, err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return "", errors.New(enum.Tips_common_empty + "input data")
+		return "", enum.NewError(enum.ErrorCode_common_empty, "input data")
 	}
 	var reqData = &requestGetInfoByChannelId{}
 	err = json.Unmarshal([]byte(jsonData), reqData)
@@ -26,14 +26,14 @@ func getChannelIdFromJson(jsonData string) (channelId string, err error) {
 	}
 
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		return "", errors.New(enum.Tips_common_empty + "channel_id")
+		return "", enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 	return reqData.ChannelId, nil
 }
 
 func (this *commitmentTxManager) DelItemByChannelId(jsonData string, user *bean.User) (err error) {
 	if tool.CheckIsString(&jsonData) == false {
-		return errors.New(enum.Tips_common_empty + "input data")
+		return enum.NewError(enum.ErrorCode_common_empty, "input data")
 	}
 	var reqData = &requestGetInfoByChannelId{}
 	err = json.Unmarshal([]byte(jsonData), reqData)
@@ -41,7 +41,7 @@ func (this *commitmentTxManager) DelItemByChannelId(jsonData string, user *bean.
 		return err
 	}
 	if tool.CheckIsString(&reqData.ChannelId) == false {
-		return errors.New(enum.Tips_common_empty + "channel_id")
+		return enum.NewError(enum.ErrorCode_common_empty, "channel_id")
 	}
 	tx, err := user.Db.Begin(true)
 	if err != nil {
@@ -2,7 +2,6 @@ package service
 
 import (
 	"errors"
-	"fmt"
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
@@ -45,7 +44,7 @@ func createCurrCommitmentTxRawBR(tx storm.Node, brType dao.BRType, channelInfo *
 				channelInfo.FundingAddress,
 				channelInfo.PropertyId,
 				breachRemedyTransaction.Amount,
-				getBtcMinerAmount(channelInfo.BtcAmount),
+				getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 				0,
 				&commitmentTx.RSMCRedeemScript)
 			if err != nil {
@@ -66,7 +65,7 @@ func createCurrCommitmentTxRawBR(tx storm.Node, brType dao.BRType, channelInfo *
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			breachRemedyTransaction.Amount,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			0,
 			&commitmentTx.RSMCRedeemScript)
 		if err != nil {
@@ -131,7 +130,7 @@ func createRawBR(brType dao.BRType, channelInfo *dao.ChannelInfo, commitmentTx *
 			channelInfo.FundingAddress,
 			channelInfo.PropertyId,
 			breachRemedyTransaction.Amount,
-			getBtcMinerAmount(channelInfo.BtcAmount),
+			getMinerFee(omnicore.FeePurpose_Sweep, channelInfo.BtcAmount),
 			0,
 			&commitmentTx.RSMCRedeemScript)
 		if err != nil {
@@ -215,7 +214,7 @@ func signLastBR(tx storm.Node, brType dao.BRType, channelInfo dao.ChannelInfo, u
 			lastBreachRemedyTransaction.InputRedeemScript)
 		if err != nil {
 			log.Println(err)
-			return errors.New(fmt.Sprintf(enum.Tips_rsmc_failToGetInput, "breachRemedyTransaction"))
+			return enum.NewError(enum.ErrorCode_rsmc_failToGetInput, "breachRemedyTransaction")
 		}
 
 		if lastBreachRemedyTransaction.CurrState == dao.TxInfoState_CreateAndSign {
@@ -224,7 +223,7 @@ func signLastBR(tx storm.Node, brType dao.BRType, channelInfo dao.ChannelInfo, u
 
 		signedBRTxid, signedBRHex, err := omnicore.OmniSignRawTransactionForUnsend(lastBreachRemedyTransaction.BrTxHex, inputs, lastTempAddressPrivateKey)
 		if err != nil {
-			return errors.New(fmt.Sprintf(enum.Tips_common_failToSign, "breachRemedyTransaction"))
+			return enum.NewError(enum.ErrorCode_common_failToSign, "breachRemedyTransaction")
 		}
 		lastBreachRemedyTransaction.Txid = signedBRTxid
 		lastBreachRemedyTransaction.BrTxHex = signedBRHex
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"github.com/asdine/storm"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
@@ -12,15 +13,24 @@ import (
 	"io/ioutil"
 	"log"
 	"strings"
+	"sync"
 	"time"
 )
 
-type scheduleManager struct{}
+type scheduleManager struct {
+	mu   sync.Mutex
+	quit chan struct{}
+	// the running jobs, which use the dbs of users
+	jobs sync.WaitGroup
+}
 
 var ScheduleService = scheduleManager{}
 
 func (service *scheduleManager) StartSchedule() {
-	go func() {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	service.quit = make(chan struct{})
+	go func(quit chan struct{}) {
 		ticker8m := time.NewTicker(8 * time.Minute)
 		defer ticker8m.Stop()
 
@@ -28,13 +38,45 @@ func (service *scheduleManager) StartSchedule() {
 			select {
 			case t := <-ticker8m.C:
 				log.Println("timer 8m", t)
-				go sendRdTx()
-				go checkBR()
+				service.runJob(sendRdTx)
+				service.runJob(checkBR)
+				service.runJob(expireOutbox)
+			case <-quit:
+				return
 			}
 		}
+	}(service.quit)
+}
+
+func (service *scheduleManager) runJob(job func()) {
+	service.jobs.Add(1)
+	go func() {
+		defer service.jobs.Done()
+		job()
 	}()
 }
 
+// StopSchedule stop the timer, and wait for the running jobs until ctx is done
+func (service *scheduleManager) StopSchedule(ctx context.Context) error {
+	service.mu.Lock()
+	if service.quit != nil {
+		close(service.quit)
+		service.quit = nil
+	}
+	service.mu.Unlock()
+	finished := make(chan struct{})
+	go func() {
+		service.jobs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 //检查通道地址的金额是否变动了，根据交易的txid，广播br
 func checkBR() {
 	log.Println("checkBR")
@@ -58,34 +100,36 @@ func checkBR() {
 	for _, peerId := range userPeerIds {
 		user, _ := OnlineUserMap[peerId]
 		if user != nil {
-			checkRsmcAndSendBR(user.Db)
+			checkRsmcAndSendBR(peerId, user.Db)
 		}
 	}
 
 	for _, dbName := range dbNames {
 		db, err := storm.Open(_dir + "/" + dbName)
 		if err == nil {
-			checkRsmcAndSendBR(db)
+			peerId := strings.TrimSuffix(strings.TrimPrefix(dbName, "user_"), ".db")
+			checkRsmcAndSendBR(peerId, db)
 			_ = db.Close()
 		}
 	}
 }
 
-func checkRsmcAndSendBR(db storm.Node) {
+func checkRsmcAndSendBR(peerId string, db storm.Node) {
 	var channelInfos []dao.ChannelInfo
 	err := db.All(&channelInfos)
 	if err == nil {
 		for _, channelInfo := range channelInfos {
 			if len(channelInfo.ChannelId) > 0 {
 				if channelInfo.CurrState == bean.ChannelState_CanUse || channelInfo.CurrState == bean.ChannelState_HtlcTx {
-					result := conn2tracker.OmniGetBalancesForAddress(channelInfo.ChannelAddress, int(channelInfo.PropertyId))
-					if result == "" {
+					// skip the channel if the tracker is down, the balance is unknown rather than zero
+					balance, err := conn2tracker.OmniGetBalancesForAddress(context.Background(), channelInfo.ChannelAddress, int(channelInfo.PropertyId))
+					if err != nil {
+						log.Println(err)
 						continue
 					}
-					balance := gjson.Get(result, "balance").Float()
 
-					if balance < channelInfo.Amount {
-						transactionsStr, err := conn2tracker.OmniListTransactions(channelInfo.ChannelAddress)
+					if balance.Balance < channelInfo.Amount {
+						transactionsStr, err := conn2tracker.OmniListTransactions(context.Background(), channelInfo.ChannelAddress)
 						if transactionsStr == "" {
 							continue
 						}
@@ -98,12 +142,13 @@ func checkRsmcAndSendBR(db storm.Node) {
 							rsmcBreachRemedy := &dao.BreachRemedyTransaction{}
 							_ = db.Select(q.Eq("CurrState", dao.TxInfoState_CreateAndSign), q.Eq("InputTxid", txid)).First(rsmcBreachRemedy)
 							if rsmcBreachRemedy.Id > 0 {
-								txid, err = conn2tracker.SendRawTransaction(rsmcBreachRemedy.BrTxHex)
+								txid, err = conn2tracker.SendRawTransaction(context.Background(), rsmcBreachRemedy.BrTxHex)
 								if err == nil {
 									log.Println("timer send rsmcBr BreachRemedyTransaction id:", rsmcBreachRemedy.Id, txid)
 									rsmcBreachRemedy.CurrState = dao.TxInfoState_SendHex
 									rsmcBreachRemedy.SendAt = time.Now()
 									_ = db.Update(rsmcBreachRemedy)
+									publishBreachRemedySentEvent(peerId, *rsmcBreachRemedy, txid)
 								}
 
 								// htlc htlcbr
@@ -114,12 +159,13 @@ func checkRsmcAndSendBR(db storm.Node) {
 									q.Eq("ChannelId", rsmcBreachRemedy.ChannelId),
 									q.Eq("CommitmentTxId", rsmcBreachRemedy.CommitmentTxId)).First(htlcBreachRemedy)
 								if htlcBreachRemedy.Id > 0 {
-									txid, err = conn2tracker.SendRawTransaction(htlcBreachRemedy.BrTxHex)
+									txid, err = conn2tracker.SendRawTransaction(context.Background(), htlcBreachRemedy.BrTxHex)
 									if err == nil {
 										log.Println("timer send htlcBr BreachRemedyTransaction id:", htlcBreachRemedy.Id, txid)
 										htlcBreachRemedy.CurrState = dao.TxInfoState_SendHex
 										htlcBreachRemedy.SendAt = time.Now()
 										_ = db.Update(htlcBreachRemedy)
+										publishBreachRemedySentEvent(peerId, *htlcBreachRemedy, txid)
 									}
 								}
 							} else {
@@ -134,12 +180,13 @@ func checkRsmcAndSendBR(db storm.Node) {
 										q.Eq("ChannelId", sentRsmcBreachRemedy.ChannelId),
 										q.Eq("CommitmentTxId", sentRsmcBreachRemedy.CommitmentTxId)).First(htBreachRemedy)
 									if htBreachRemedy.Id > 0 {
-										txid, err = conn2tracker.SendRawTransaction(htBreachRemedy.BrTxHex)
+										txid, err = conn2tracker.SendRawTransaction(context.Background(), htBreachRemedy.BrTxHex)
 										if err == nil {
 											log.Println("timer send htBr BreachRemedyTransaction id: ", htBreachRemedy.Id, txid)
 											htBreachRemedy.CurrState = dao.TxInfoState_SendHex
 											htBreachRemedy.SendAt = time.Now()
 											_ = db.Update(htBreachRemedy)
+											publishBreachRemedySentEvent(peerId, *htBreachRemedy, txid)
 										}
 									}
 									// 或者 htlc payee方的hebr
@@ -150,12 +197,13 @@ func checkRsmcAndSendBR(db storm.Node) {
 										q.Eq("ChannelId", sentRsmcBreachRemedy.ChannelId),
 										q.Eq("CommitmentTxId", sentRsmcBreachRemedy.CommitmentTxId)).First(heBreachRemedy)
 									if heBreachRemedy.Id > 0 {
-										txid, err = conn2tracker.SendRawTransaction(heBreachRemedy.BrTxHex)
+										txid, err = conn2tracker.SendRawTransaction(context.Background(), heBreachRemedy.BrTxHex)
 										if err != nil {
 											log.Println("timer send heBr BreachRemedyTransaction id: ", heBreachRemedy.Id, txid)
 											heBreachRemedy.CurrState = dao.TxInfoState_SendHex
 											heBreachRemedy.SendAt = time.Now()
 											_ = db.Update(heBreachRemedy)
+											publishBreachRemedySentEvent(peerId, *heBreachRemedy, txid)
 										}
 									}
 								}
@@ -166,6 +214,7 @@ func checkRsmcAndSendBR(db storm.Node) {
 						channelInfo.CloseAt = time.Now()
 						_ = db.Update(&channelInfo)
 						sendChannelStateToTracker(channelInfo, dao.CommitmentTransaction{})
+						publishChannelStateEvent(peerId, channelInfo)
 					}
 				}
 			}
@@ -1,12 +1,12 @@
 package service
 
 import (
-	"errors"
 	"github.com/asdine/storm/q"
 	"github.com/omnilaboratory/obd/bean"
 	"github.com/omnilaboratory/obd/bean/enum"
 	"github.com/omnilaboratory/obd/dao"
 	"github.com/omnilaboratory/obd/tool"
+	"github.com/tyler-smith/go-bip32"
 	"github.com/tyler-smith/go-bip39"
 	"log"
 	"time"
@@ -19,22 +19,50 @@ var UserService = UserManager{}
 
 func (service *UserManager) UserLogin(user *bean.User) error {
 	if user == nil {
-		return errors.New(enum.Tips_user_nilUser)
+		return enum.NewError(enum.ErrorCode_user_nilUser)
 	}
 	if tool.CheckIsString(&user.Mnemonic) == false || bip39.IsMnemonicValid(user.Mnemonic) == false {
-		return errors.New(enum.Tips_common_wrong + "mnemonic")
+		return enum.NewError(enum.ErrorCode_common_wrong, "mnemonic")
 	}
 
 	changeExtKey, err := HDWalletService.CreateChangeExtKey(user.Mnemonic)
 	if err != nil {
 		return err
 	}
+	user.PeerId = tool.GetUserPeerId(changeExtKey.PublicKey().Key)
+	err = migrateLegacyUserDB(user.Mnemonic, user.PeerId)
+	if err != nil {
+		log.Println(err)
+	}
+	// the mnemonic is not kept in the session
+	user.Mnemonic = ""
+	return service.login(user, changeExtKey)
+}
+
+// UserLoginWithXpub the user signs the login nonce with the key of m/44'/coinType',
+// obd only keeps the xpub and derives the addresses and pubkeys from it.
+func (service *UserManager) UserLoginWithXpub(user *bean.User, nonce, signature string) error {
+	if user == nil {
+		return enum.NewError(enum.ErrorCode_user_nilUser)
+	}
+	changeExtKey, err := HDWalletService.VerifyLoginSignature(user.WalletXpub, user.P2PLocalPeerId, nonce, signature)
+	if err != nil {
+		return err
+	}
+	user.PeerId = tool.GetUserPeerId(changeExtKey.Key)
+	if OnlineUserMap[user.PeerId] != nil {
+		return enum.NewError(enum.ErrorCode_user_loginAtOtherNode)
+	}
+	return service.login(user, changeExtKey)
+}
+
+func (service *UserManager) login(user *bean.User, changeExtKey *bip32.Key) error {
 	var node dao.User
-	user.PeerId = tool.GetUserPeerId(user.Mnemonic)
 	userDB, err := dao.DBService.GetUserDB(user.PeerId)
 	if err != nil {
 		return err
 	}
+	migrateInvoiceH(userDB)
 	err = userDB.Select(q.Eq("PeerId", user.PeerId)).First(&node)
 	if node.Id == 0 {
 		node = dao.User{}
@@ -74,7 +102,7 @@ func (service *UserManager) UserLogin(user *bean.User) error {
 
 func (service *UserManager) UserLogout(user *bean.User) error {
 	if user == nil {
-		return errors.New(enum.Tips_user_nilUser)
+		return enum.NewError(enum.ErrorCode_user_nilUser)
 	}
 	var node dao.User
 	err := user.Db.Select(q.Eq("PeerId", user.PeerId)).First(&node)
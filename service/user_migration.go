@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/tool"
+)
+
+// the tables of the user db which keep peer ids of users
+var userDbTablesWithPeerId = []interface{}{
+	dao.User{},
+	dao.UserLoginLog{},
+	dao.ChannelInfo{},
+	dao.CloseChannel{},
+	dao.FundingTransaction{},
+	dao.FundingBtcRequest{},
+	dao.MinerFeeRedeemTransaction{},
+	dao.CommitmentTransaction{},
+	dao.RevocableDeliveryTransaction{},
+	dao.BreachRemedyTransaction{},
+	dao.AtomicSwapInfo{},
+	dao.AtomicSwapAcceptedInfo{},
+	dao.AddHtlcRequestInfo{},
+	dao.HTLCTimeoutTxForAAndExecutionForB{},
+	dao.HTLCTimeoutDeliveryTxB{},
+	dao.HtlcLockTxByH{},
+	dao.HTLCExecutionDeliveryOfR{},
+}
+
+// MigrateLegacyUserDBs re-key the user dbs created by old versions, whose peer id was
+// sha256(mnemonic@mac:port in chainType), to the peer id derived from the wallet.
+// macAddress and serverPort are the ones of the host which created the dbs.
+// obd must be stopped while migrating, because the dbs are opened exclusively.
+func MigrateLegacyUserDBs(mnemonics []string, macAddress string, serverPort int) (migrations []dao.UserPeerIdMigration, err error) {
+	files, err := ioutil.ReadDir(config.DataDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	currChainNodeType := config.ChainNodeType
+	defer func() { config.ChainNodeType = currChainNodeType }()
+
+	for _, chainDir := range files {
+		if chainDir.IsDir() == false {
+			continue
+		}
+		// the address derivation depends on the chain, set it before computing peer ids.
+		config.ChainNodeType = chainDir.Name()
+		for _, mnemonic := range mnemonics {
+			if tool.CheckIsString(&mnemonic) == false {
+				continue
+			}
+			newPeerId, err := HDWalletService.GetUserPeerId(mnemonic)
+			if err != nil {
+				return migrations, err
+			}
+			oldPeerId := tool.GetLegacyUserPeerId(mnemonic, macAddress, serverPort, config.ChainNodeType)
+			migration, err := migrateUserPeerId(oldPeerId, newPeerId)
+			if err != nil {
+				return migrations, err
+			}
+			if migration != nil {
+				migration.OldObdNodeId = tool.GetLegacyObdNodeId(macAddress, serverPort, config.ChainNodeType)
+				err = saveUserPeerIdMigration(migration)
+				if err != nil {
+					return migrations, err
+				}
+				migrations = append(migrations, *migration)
+			}
+		}
+	}
+	return migrations, nil
+}
+
+func saveUserPeerIdMigration(migration *dao.UserPeerIdMigration) error {
+	globalDb, err := storm.Open(config.DataDirectory + "/" + config.ChainNodeType + "/" + config.DBname)
+	if err != nil {
+		return err
+	}
+	defer globalDb.Close()
+	return globalDb.Save(migration)
+}
+
+// migrate the db of the user, who logs in on the same host and port as the old version.
+func migrateLegacyUserDB(mnemonic, newPeerId string) error {
+	oldPeerId := tool.GetLegacyUserPeerId(mnemonic, tool.GetMacAddrs(), config.ServerPort, config.ChainNodeType)
+	migration, err := migrateUserPeerId(oldPeerId, newPeerId)
+	if err != nil {
+		return err
+	}
+	if migration != nil {
+		migration.OldObdNodeId = tool.GetLegacyObdNodeId(tool.GetMacAddrs(), config.ServerPort, config.ChainNodeType)
+		db, err := dao.DBService.GetGlobalDB()
+		if err != nil {
+			return err
+		}
+		err = db.Save(migration)
+		if err != nil {
+			return err
+		}
+		NoticeTrackerUserPeerIdMigrations()
+	}
+	return nil
+}
+
+func migrateUserPeerId(oldPeerId, newPeerId string) (*dao.UserPeerIdMigration, error) {
+	if oldPeerId == newPeerId {
+		return nil, nil
+	}
+	_dir := config.DataDirectory + "/" + config.ChainNodeType
+	oldPath := _dir + "/user_" + oldPeerId + ".db"
+	newPath := _dir + "/user_" + newPeerId + ".db"
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		return nil, nil
+	}
+	if _, err := os.Stat(newPath); err == nil {
+		return nil, errors.New("both " + oldPath + " and " + newPath + " exist, can not migrate")
+	}
+
+	log.Println("migrate user db", oldPath, "to", newPath)
+	err := os.Rename(oldPath, newPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// the user db of the user self and the ones of the local counterparties
+	files, err := ioutil.ReadDir(_dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.Name(), "user_") && strings.HasSuffix(f.Name(), ".db") {
+			peerId := strings.TrimSuffix(strings.TrimPrefix(f.Name(), "user_"), ".db")
+			// the db of an online user is opened already
+			if user, ok := OnlineUserMap[peerId]; ok && user.Db != nil {
+				err = rekeyUserDB(user.Db, oldPeerId, newPeerId)
+			} else {
+				var db *storm.DB
+				db, err = storm.Open(_dir + "/" + f.Name())
+				if err != nil {
+					return nil, err
+				}
+				err = rekeyUserDB(db, oldPeerId, newPeerId)
+				_ = db.Close()
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &dao.UserPeerIdMigration{OldPeerId: oldPeerId, NewPeerId: newPeerId, CreateAt: time.Now()}, nil
+}
+
+func rekeyUserDB(db *storm.DB, oldPeerId, newPeerId string) error {
+	for _, table := range userDbTablesWithPeerId {
+		records := reflect.New(reflect.SliceOf(reflect.TypeOf(table)))
+		err := db.All(records.Interface())
+		if err != nil {
+			return err
+		}
+		for i := 0; i < records.Elem().Len(); i++ {
+			record := records.Elem().Index(i)
+			if replacePeerIdFields(record, oldPeerId, newPeerId) {
+				err = db.Save(record.Addr().Interface())
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// replace the value of the peer id fields, including the ones of embedded structs
+func replacePeerIdFields(record reflect.Value, oldPeerId, newPeerId string) (changed bool) {
+	for i := 0; i < record.NumField(); i++ {
+		field := record.Field(i)
+		fieldType := record.Type().Field(i)
+		if fieldType.Anonymous && field.Kind() == reflect.Struct {
+			if replacePeerIdFields(field, oldPeerId, newPeerId) {
+				changed = true
+			}
+			continue
+		}
+		if field.Kind() != reflect.String || field.CanSet() == false {
+			continue
+		}
+		if strings.Contains(fieldType.Name, "PeerId") || fieldType.Name == "Owner" || fieldType.Name == "CreateBy" {
+			if field.String() == oldPeerId {
+				field.SetString(newPeerId)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// NoticeTrackerUserPeerIdMigrations send the migrations, which some of the trackers have not acknowledged, to the
+// connected trackers. The trackers which miss them get them again by GetUserPeerIdMigrationsToNotice when they connect.
+func NoticeTrackerUserPeerIdMigrations() {
+	requests := make([]bean.UserPeerIdMigrationRequest, 0)
+	for _, item := range getUserPeerIdMigrations() {
+		for _, host := range config.TrackerHosts {
+			if containsString(item.NoticedTrackers, host) == false {
+				requests = append(requests, bean.UserPeerIdMigrationRequest{OldPeerId: item.OldPeerId, NewPeerId: item.NewPeerId, OldObdNodeId: item.OldObdNodeId})
+				break
+			}
+		}
+	}
+	if len(requests) > 0 {
+		go sendMsgToTracker(enum.MsgType_Tracker_UpdateUserPeerId_354, requests)
+	}
+}
+
+// GetUserPeerIdMigrationsToNotice the migrations which the tracker has not acknowledged
+func GetUserPeerIdMigrationsToNotice(trackerHost string) []bean.UserPeerIdMigrationRequest {
+	requests := make([]bean.UserPeerIdMigrationRequest, 0)
+	for _, item := range getUserPeerIdMigrations() {
+		if containsString(item.NoticedTrackers, trackerHost) == false {
+			requests = append(requests, bean.UserPeerIdMigrationRequest{OldPeerId: item.OldPeerId, NewPeerId: item.NewPeerId, OldObdNodeId: item.OldObdNodeId})
+		}
+	}
+	return requests
+}
+
+// OnTrackerNoticedUserPeerIdMigrations the tracker acknowledged the migrations, they are not sent to it again
+func OnTrackerNoticedUserPeerIdMigrations(trackerHost string, requests []bean.UserPeerIdMigrationRequest) {
+	db, err := dao.DBService.GetGlobalDB()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, request := range requests {
+		var migrations []dao.UserPeerIdMigration
+		_ = db.Select(q.Eq("OldPeerId", request.OldPeerId), q.Eq("NewPeerId", request.NewPeerId)).Find(&migrations)
+		for _, item := range migrations {
+			if containsString(item.NoticedTrackers, trackerHost) {
+				continue
+			}
+			item.NoticedTrackers = append(item.NoticedTrackers, trackerHost)
+			_ = db.Update(&item)
+		}
+	}
+}
+
+func getUserPeerIdMigrations() []dao.UserPeerIdMigration {
+	var migrations []dao.UserPeerIdMigration
+	db, err := dao.DBService.GetGlobalDB()
+	if err != nil {
+		log.Println(err)
+		return migrations
+	}
+	_ = db.All(&migrations)
+	return migrations
+}
+
+// the invoices created before their h is indexed, the h of the detail is copied for finding them by h
+func migrateInvoiceH(db storm.Node) {
+	var invoices []dao.InvoiceInfo
+	_ = db.Select(q.Eq("H", "")).Find(&invoices)
+	for _, item := range invoices {
+		if len(item.Detail.H) == 0 {
+			continue
+		}
+		item.H = item.Detail.H
+		if err := db.Update(&item); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/asdine/storm"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/tool"
+)
+
+func TestMigrateLegacyUserDBs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_user_db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config.DataDirectory = dir
+	chainDir := dir + "/regtest"
+	_ = tool.PathExistsAndCreate(chainDir)
+
+	mnemonic := "coyote antenna senior reward diesel vault into used veteran model throw relief"
+	oldPeerId := tool.GetLegacyUserPeerId(mnemonic, "00:11:22:33:44:55", 60020, "regtest")
+	counterparty := "counterparty"
+
+	userDb, err := storm.Open(chainDir + "/user_" + oldPeerId + ".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = userDb.Save(&dao.User{PeerId: oldPeerId})
+	_ = userDb.Save(&dao.ChannelInfo{ChannelId: "channel", PeerIdA: oldPeerId, PeerIdB: counterparty})
+	_ = userDb.Close()
+
+	counterpartyDb, err := storm.Open(chainDir + "/user_" + counterparty + ".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = counterpartyDb.Save(&dao.ChannelInfo{ChannelId: "channel", PeerIdA: oldPeerId, PeerIdB: counterparty})
+	_ = counterpartyDb.Close()
+
+	migrations, err := MigrateLegacyUserDBs([]string{mnemonic}, "00:11:22:33:44:55", 60020)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 1 || migrations[0].OldPeerId != oldPeerId {
+		t.Fatal("wrong migrations", migrations)
+	}
+
+	config.ChainNodeType = "regtest"
+	newPeerId, _ := HDWalletService.GetUserPeerId(mnemonic)
+	if migrations[0].NewPeerId != newPeerId {
+		t.Fatal("wrong new peer id", migrations[0].NewPeerId)
+	}
+	if _, err = os.Stat(chainDir + "/user_" + oldPeerId + ".db"); os.IsNotExist(err) == false {
+		t.Fatal("legacy user db still exists")
+	}
+
+	for _, peerId := range []string{newPeerId, counterparty} {
+		db, err := storm.Open(chainDir + "/user_" + peerId + ".db")
+		if err != nil {
+			t.Fatal(err)
+		}
+		channelInfo := dao.ChannelInfo{}
+		_ = db.One("ChannelId", "channel", &channelInfo)
+		_ = db.Close()
+		if channelInfo.PeerIdA != newPeerId || channelInfo.PeerIdB != counterparty {
+			t.Fatal("channel of", peerId, "is not migrated", channelInfo.PeerIdA, channelInfo.PeerIdB)
+		}
+	}
+
+	// the migration is sent to every tracker until it acknowledges it
+	_ = dao.DBService.CloseGlobalDB()
+	defer dao.DBService.CloseGlobalDB()
+	toNotice := GetUserPeerIdMigrationsToNotice("tracker1")
+	if len(toNotice) != 1 || toNotice[0].NewPeerId != newPeerId {
+		t.Fatal("wrong migrations to notice", toNotice)
+	}
+	// the trackers keep the users under the node id of the obd which created the dbs
+	if toNotice[0].OldObdNodeId != tool.GetLegacyObdNodeId("00:11:22:33:44:55", 60020, "regtest") {
+		t.Fatal("wrong legacy obd node id", toNotice[0].OldObdNodeId)
+	}
+	OnTrackerNoticedUserPeerIdMigrations("tracker1", toNotice)
+	if toNotice = GetUserPeerIdMigrationsToNotice("tracker1"); len(toNotice) != 0 {
+		t.Fatal("the migration is acknowledged by tracker1", toNotice)
+	}
+	if toNotice = GetUserPeerIdMigrationsToNotice("tracker2"); len(toNotice) != 1 {
+		t.Fatal("the migration is not acknowledged by tracker2", toNotice)
+	}
+}
@@ -0,0 +1,405 @@
+package regtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// the simulated time between two blocks
+const blockInterval = 10 * time.Minute
+
+// the min relay fee in satoshi per byte
+const minRelayFeeRate = 1
+
+// rejectError the reject reason of a transaction, the same as the one of bitcoin core
+type rejectError struct {
+	reason string
+}
+
+func (err *rejectError) Error() string {
+	return err.reason
+}
+
+func reject(format string, a ...interface{}) error {
+	return &rejectError{reason: fmt.Sprintf(format, a...)}
+}
+
+type txRecord struct {
+	tx   *wire.MsgTx
+	hash chainhash.Hash
+	// the order in which the transaction entered the mempool
+	seq uint64
+	// 0 if the transaction is in the mempool
+	height int32
+	// the transactions of the faucet have no real inputs
+	faucet bool
+	omni   *omniTx
+}
+
+// Chain an in-memory regtest chain: the utxos, the mempool, the blocks and the omni balances.
+// The transactions are checked as bitcoin core does when they enter the mempool: the inputs, the fees,
+// the lock times of BIP65/BIP68 and the scripts, and the omni simple sends are applied when they are mined.
+type Chain struct {
+	mu     sync.Mutex
+	params *chaincfg.Params
+
+	blockHashes []chainhash.Hash
+	blockTimes  []time.Time
+
+	txs map[chainhash.Hash]*txRecord
+	// the outpoints spent by the confirmed and the mempool transactions
+	spends  map[wire.OutPoint]chainhash.Hash
+	mempool []*txRecord
+
+	txCount     uint64
+	faucetCount uint32
+	// satoshi per byte, returned by estimateSmartFee
+	feeRate float64
+
+	omni *omniState
+}
+
+// NewChain a chain with the genesis block of regtest only
+func NewChain() *Chain {
+	params := &chaincfg.RegressionNetParams
+	return &Chain{
+		params:      params,
+		blockHashes: []chainhash.Hash{*params.GenesisHash},
+		blockTimes:  []time.Time{time.Now().Truncate(time.Second)},
+		txs:         make(map[chainhash.Hash]*txRecord),
+		spends:      make(map[wire.OutPoint]chainhash.Hash),
+		feeRate:     10,
+		omni:        newOmniState(),
+	}
+}
+
+// Params the chain params of the addresses
+func (chain *Chain) Params() *chaincfg.Params {
+	return chain.params
+}
+
+// Height the height of the best block
+func (chain *Chain) Height() int32 {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	return chain.height()
+}
+
+func (chain *Chain) height() int32 {
+	return int32(len(chain.blockHashes) - 1)
+}
+
+func (chain *Chain) tipTime() time.Time {
+	return chain.blockTimes[len(chain.blockTimes)-1]
+}
+
+// SetFeeRate set the fee rate of estimateSmartFee, in satoshi per byte
+func (chain *Chain) SetFeeRate(feeRate float64) {
+	chain.mu.Lock()
+	chain.feeRate = feeRate
+	chain.mu.Unlock()
+}
+
+// Fund send the btc to the address by a faucet transaction in the mempool, it is confirmed by the next block
+func (chain *Chain) Fund(address string, amount float64) (txid string, err error) {
+	pkScript, err := chain.payToAddress(address)
+	if err != nil {
+		return "", err
+	}
+	value, err := btcutil.NewAmount(amount)
+	if err != nil || value <= 0 {
+		return "", errors.New("wrong amount")
+	}
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	chain.faucetCount++
+	// a coinbase like transaction, the script makes its txid unique
+	sigScript := make([]byte, 8)
+	binary.BigEndian.PutUint32(sigScript, uint32(chain.height()))
+	binary.BigEndian.PutUint32(sigScript[4:], chain.faucetCount)
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), sigScript, nil))
+	tx.AddTxOut(wire.NewTxOut(int64(value), pkScript))
+
+	chain.txCount++
+	record := &txRecord{tx: tx, hash: tx.TxHash(), seq: chain.txCount, faucet: true}
+	chain.txs[record.hash] = record
+	chain.mempool = append(chain.mempool, record)
+	return record.hash.String(), nil
+}
+
+// Mine mine the blocks, the first one confirms all the transactions in the mempool
+func (chain *Chain) Mine(blocks int) []string {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	hashes := make([]string, 0, blocks)
+	for i := 0; i < blocks; i++ {
+		height := chain.height() + 1
+		var buf bytes.Buffer
+		buf.Write(chain.blockHashes[height-1][:])
+		_ = binary.Write(&buf, binary.BigEndian, height)
+		for _, record := range chain.mempool {
+			record.height = height
+			buf.Write(record.hash[:])
+		}
+		blockHash := chainhash.DoubleHashH(buf.Bytes())
+		chain.blockHashes = append(chain.blockHashes, blockHash)
+		chain.blockTimes = append(chain.blockTimes, chain.tipTime().Add(blockInterval))
+		for index, record := range chain.mempool {
+			chain.omni.apply(record, index)
+		}
+		chain.mempool = nil
+		hashes = append(hashes, blockHash.String())
+	}
+	return hashes
+}
+
+// TestMempoolAccept check the transaction as sendRawTransaction does, but not add it to the mempool
+func (chain *Chain) TestMempoolAccept(txHex string) (txid string, err error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	tx, err := decodeTx(txHex)
+	if err != nil {
+		return "", err
+	}
+	return tx.TxHash().String(), chain.check(tx)
+}
+
+// SendRawTransaction add the transaction to the mempool if it is accepted
+func (chain *Chain) SendRawTransaction(txHex string) (txid string, err error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	tx, err := decodeTx(txHex)
+	if err != nil {
+		return "", err
+	}
+	if err = chain.check(tx); err != nil {
+		return "", err
+	}
+	chain.txCount++
+	record := &txRecord{tx: tx, hash: tx.TxHash(), seq: chain.txCount}
+	record.omni = chain.omni.decode(chain, record)
+	chain.txs[record.hash] = record
+	for _, txIn := range tx.TxIn {
+		chain.spends[txIn.PreviousOutPoint] = record.hash
+	}
+	chain.mempool = append(chain.mempool, record)
+	return record.hash.String(), nil
+}
+
+func decodeTx(txHex string) (*wire.MsgTx, error) {
+	txBytes, err := hex.DecodeString(txHex)
+	if err != nil {
+		return nil, reject("TX decode failed")
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err = tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, reject("TX decode failed")
+	}
+	return tx, nil
+}
+
+// the previous output of the input, and the height of its transaction, 0 if it is in the mempool
+func (chain *Chain) getPrevOut(outPoint wire.OutPoint) (*wire.TxOut, int32, bool) {
+	record, ok := chain.txs[outPoint.Hash]
+	if ok == false || int(outPoint.Index) >= len(record.tx.TxOut) {
+		return nil, 0, false
+	}
+	return record.tx.TxOut[outPoint.Index], record.height, true
+}
+
+// the checks of bitcoin core before a transaction enters the mempool
+func (chain *Chain) check(tx *wire.MsgTx) error {
+	txHash := tx.TxHash()
+	if record, ok := chain.txs[txHash]; ok {
+		if record.height == 0 {
+			return reject("txn-already-in-mempool")
+		}
+		return reject("txn-already-known")
+	}
+	if len(tx.TxIn) == 0 {
+		return reject("bad-txns-vin-empty")
+	}
+	if len(tx.TxOut) == 0 {
+		return reject("bad-txns-vout-empty")
+	}
+
+	nextHeight := chain.height() + 1
+	if chain.isFinal(tx, nextHeight) == false {
+		return reject("non-final")
+	}
+
+	var valueIn int64
+	prevOuts := make([]*wire.TxOut, len(tx.TxIn))
+	prevHeights := make([]int32, len(tx.TxIn))
+	for index, txIn := range tx.TxIn {
+		prevOut, height, ok := chain.getPrevOut(txIn.PreviousOutPoint)
+		if ok == false {
+			return reject("missing-inputs")
+		}
+		if spender, ok := chain.spends[txIn.PreviousOutPoint]; ok {
+			if chain.txs[spender].height == 0 {
+				return reject("txn-mempool-conflict")
+			}
+			return reject("missing-inputs")
+		}
+		if height == 0 {
+			height = nextHeight
+		}
+		prevOuts[index] = prevOut
+		prevHeights[index] = height
+		valueIn += prevOut.Value
+	}
+
+	var valueOut int64
+	for _, txOut := range tx.TxOut {
+		if txOut.Value < 0 {
+			return reject("bad-txns-vout-negative")
+		}
+		valueOut += txOut.Value
+	}
+	if valueIn < valueOut {
+		return reject("bad-txns-in-belowout, value in (%s) < value out (%s)", btcutil.Amount(valueIn), btcutil.Amount(valueOut))
+	}
+	fee := valueIn - valueOut
+	minFee := int64(tx.SerializeSize()) * minRelayFeeRate
+	if fee < minFee {
+		return reject("min relay fee not met, %d < %d", fee, minFee)
+	}
+
+	if err := chain.checkSequenceLocks(tx, prevHeights, nextHeight); err != nil {
+		return err
+	}
+
+	hashCache := txscript.NewTxSigHashes(tx)
+	for index := range tx.TxIn {
+		vm, err := txscript.NewEngine(prevOuts[index].PkScript, tx, index, txscript.StandardVerifyFlags, nil, hashCache, prevOuts[index].Value)
+		if err == nil {
+			err = vm.Execute()
+		}
+		if err != nil {
+			return reject("mandatory-script-verify-flag-failed (%v)", err)
+		}
+	}
+	return nil
+}
+
+// BIP65: the lock time of the transaction is the height or the time before which it can not be mined
+func (chain *Chain) isFinal(tx *wire.MsgTx, nextHeight int32) bool {
+	if tx.LockTime == 0 {
+		return true
+	}
+	if tx.LockTime < txscript.LockTimeThreshold {
+		if int64(tx.LockTime) < int64(nextHeight) {
+			return true
+		}
+	} else if int64(tx.LockTime) < chain.tipTime().Unix() {
+		return true
+	}
+	for _, txIn := range tx.TxIn {
+		if txIn.Sequence != wire.MaxTxInSequenceNum {
+			return false
+		}
+	}
+	return true
+}
+
+// BIP68: the sequence of an input is the count of the blocks or the time,
+// in the unit of 512 seconds, for which the previous output must be confirmed before it is spent
+func (chain *Chain) checkSequenceLocks(tx *wire.MsgTx, prevHeights []int32, nextHeight int32) error {
+	if tx.Version < 2 {
+		return nil
+	}
+	for index, txIn := range tx.TxIn {
+		if txIn.Sequence&wire.SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		lock := int64(txIn.Sequence & wire.SequenceLockTimeMask)
+		confirmedBlocks := int64(nextHeight - prevHeights[index])
+		if txIn.Sequence&wire.SequenceLockTimeIsSeconds != 0 {
+			// the time of the block before the one which confirmed the previous output
+			beginTime := chain.blockTimes[prevHeights[index]-1]
+			if int64(chain.tipTime().Sub(beginTime).Seconds()) < lock<<wire.SequenceLockTimeGranularity {
+				return reject("non-BIP68-final")
+			}
+		} else if confirmedBlocks < lock {
+			return reject("non-BIP68-final")
+		}
+	}
+	return nil
+}
+
+func (chain *Chain) payToAddress(address string) ([]byte, error) {
+	addr, err := btcutil.DecodeAddress(address, chain.params)
+	if err != nil {
+		return nil, errors.New("Invalid address: " + address)
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// the address of the output script, empty if it is not a standard one
+func (chain *Chain) getAddress(pkScript []byte) string {
+	_, addresses, _, err := txscript.ExtractPkScriptAddrs(pkScript, chain.params)
+	if err != nil || len(addresses) != 1 {
+		return ""
+	}
+	return addresses[0].EncodeAddress()
+}
+
+// the confirmations of the transaction, 0 if it is in the mempool
+func (chain *Chain) confirmations(record *txRecord) int32 {
+	if record.height == 0 {
+		return 0
+	}
+	return chain.height() - record.height + 1
+}
+
+type unspent struct {
+	outPoint      wire.OutPoint
+	output        *wire.TxOut
+	confirmations int32
+}
+
+// the outputs of the address which are spent by neither the blocks nor the mempool
+func (chain *Chain) listUnspent(address string) []unspent {
+	result := make([]unspent, 0)
+	for _, record := range chain.sortedTxs() {
+		for index, txOut := range record.tx.TxOut {
+			outPoint := wire.OutPoint{Hash: record.hash, Index: uint32(index)}
+			if _, spent := chain.spends[outPoint]; spent {
+				continue
+			}
+			if chain.getAddress(txOut.PkScript) == address {
+				result = append(result, unspent{outPoint: outPoint, output: txOut, confirmations: chain.confirmations(record)})
+			}
+		}
+	}
+	return result
+}
+
+// the transactions in the order of the blocks, then the mempool
+func (chain *Chain) sortedTxs() []*txRecord {
+	confirmed := make([]*txRecord, 0, len(chain.txs))
+	for _, record := range chain.txs {
+		if record.height > 0 {
+			confirmed = append(confirmed, record)
+		}
+	}
+	sort.Slice(confirmed, func(i, j int) bool {
+		return confirmed[i].seq < confirmed[j].seq
+	})
+	return append(confirmed, chain.mempool...)
+}
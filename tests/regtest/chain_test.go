@@ -0,0 +1,129 @@
+package regtest
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+type testKey struct {
+	wif     *btcutil.WIF
+	pubKey  string
+	address string
+}
+
+func newTestKey(t *testing.T, chain *Chain) *testKey {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, _ := btcutil.NewWIF(privKey, chain.Params(), true)
+	pubKey := privKey.PubKey().SerializeCompressed()
+	address, _ := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), chain.Params())
+	return &testKey{wif: wif, pubKey: hex.EncodeToString(pubKey), address: address.EncodeAddress()}
+}
+
+// a transaction spending the output of the key to the address, signed by the key
+func newTestTx(t *testing.T, chain *Chain, key *testKey, txid string, vout uint32, value int64, to string, sequence uint32) string {
+	hash, _ := chainhash.NewHashFromStr(txid)
+	tx := wire.NewMsgTx(2)
+	txIn := wire.NewTxIn(wire.NewOutPoint(hash, vout), nil, nil)
+	txIn.Sequence = sequence
+	tx.AddTxIn(txIn)
+	pkScript, err := chain.payToAddress(to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.AddTxOut(wire.NewTxOut(value, pkScript))
+
+	prevPkScript, _ := chain.payToAddress(key.address)
+	sigScript, err := txscript.SignatureScript(tx, 0, prevPkScript, txscript.SigHashAll, key.wif.PrivKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.TxIn[0].SignatureScript = sigScript
+	return txToHex(tx)
+}
+
+// the reject reason starts with the reason
+func assertRejected(t *testing.T, chain *Chain, txHex string, reason string) {
+	t.Helper()
+	_, err := chain.TestMempoolAccept(txHex)
+	if err == nil || strings.HasPrefix(err.Error(), reason) == false {
+		t.Fatalf("the transaction is rejected by %v, want %s", err, reason)
+	}
+}
+
+func TestMempoolAccept(t *testing.T) {
+	chain := NewChain()
+	alice := newTestKey(t, chain)
+	bob := newTestKey(t, chain)
+	fundingTxid, err := chain.Fund(alice.address, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a relative lock time of 3 blocks, it can be mined in the third block after the one confirming the funding
+	lockedHex := newTestTx(t, chain, alice, fundingTxid, 0, 90000, bob.address, 3)
+	assertRejected(t, chain, lockedHex, "non-BIP68-final")
+	chain.Mine(2)
+	assertRejected(t, chain, lockedHex, "non-BIP68-final")
+	chain.Mine(1)
+	if _, err = chain.TestMempoolAccept(lockedHex); err != nil {
+		t.Fatal(err)
+	}
+
+	assertRejected(t, chain, newTestTx(t, chain, bob, fundingTxid, 0, 90000, bob.address, wire.MaxTxInSequenceNum), "mandatory-script-verify-flag-failed (OP_EQUALVERIFY failed)")
+	assertRejected(t, chain, newTestTx(t, chain, alice, fundingTxid, 0, 100000, bob.address, wire.MaxTxInSequenceNum), "min relay fee not met")
+	assertRejected(t, chain, newTestTx(t, chain, alice, fundingTxid, 1, 90000, bob.address, wire.MaxTxInSequenceNum), "missing-inputs")
+
+	txHex := newTestTx(t, chain, alice, fundingTxid, 0, 90000, bob.address, wire.MaxTxInSequenceNum)
+	txid, err := chain.SendRawTransaction(txHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRejected(t, chain, txHex, "txn-already-in-mempool")
+	assertRejected(t, chain, newTestTx(t, chain, alice, fundingTxid, 0, 80000, alice.address, wire.MaxTxInSequenceNum), "txn-mempool-conflict")
+
+	chain.Mine(1)
+	assertRejected(t, chain, txHex, "txn-already-known")
+	unspents := chain.listUnspent(bob.address)
+	if len(unspents) != 1 || unspents[0].outPoint.Hash.String() != txid || unspents[0].confirmations != 1 {
+		t.Fatalf("the unspents of bob are %v", unspents)
+	}
+	if len(chain.listUnspent(alice.address)) != 0 {
+		t.Fatal("the spent output of alice is unspent")
+	}
+}
+
+func TestLockTime(t *testing.T) {
+	chain := NewChain()
+	alice := newTestKey(t, chain)
+	fundingTxid, _ := chain.Fund(alice.address, 0.001)
+	chain.Mine(1)
+
+	hash, _ := chainhash.NewHashFromStr(fundingTxid)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	tx.TxIn[0].Sequence = wire.MaxTxInSequenceNum - 1
+	pkScript, _ := chain.payToAddress(alice.address)
+	tx.AddTxOut(wire.NewTxOut(90000, pkScript))
+	tx.LockTime = 3
+	sigScript, _ := txscript.SignatureScript(tx, 0, pkScript, txscript.SigHashAll, alice.wif.PrivKey, true)
+	tx.TxIn[0].SignatureScript = sigScript
+
+	// it can be mined in the block 4
+	assertRejected(t, chain, txToHex(tx), "non-final")
+	chain.Mine(1)
+	assertRejected(t, chain, txToHex(tx), "non-final")
+	chain.Mine(1)
+	if _, err := chain.TestMempoolAccept(txToHex(tx)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,373 @@
+package regtest
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/conn"
+	"github.com/omnilaboratory/obd/omnicore"
+	"github.com/omnilaboratory/obd/tool"
+	"github.com/tidwall/gjson"
+)
+
+// start the chain as the tracker of conn2tracker
+func startTracker(t *testing.T) (*Chain, func()) {
+	chain := NewChain()
+	server := NewServer(chain)
+	host, err := server.Start("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trackerHosts := config.TrackerHosts
+	config.TrackerHosts = []string{host}
+	config.ChainNodeType = "regtest"
+	conn2tracker.SetChainBackend(nil)
+	estimator, _ := omnicore.NewFeeEstimator(omnicore.FeeEstimatorType_Static, 10, "")
+	omnicore.SetFeeEstimator(estimator)
+	return chain, func() {
+		omnicore.SetFeeEstimator(nil)
+		config.TrackerHosts = trackerHosts
+		_ = server.Close()
+	}
+}
+
+type multiSig struct {
+	address      string
+	redeemScript string
+	scriptPubKey string
+}
+
+func newMultiSig(t *testing.T, keys ...*testKey) multiSig {
+	result, err := omnicore.CreateMultiSig(2, []string{keys[0].pubKey, keys[1].pubKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return multiSig{
+		address:      gjson.Get(result, "address").Str,
+		redeemScript: gjson.Get(result, "redeemScript").Str,
+		scriptPubKey: gjson.Get(result, "scriptPubKey").Str,
+	}
+}
+
+// sign the inputs of the transaction by the keys one by one
+func signTx(t *testing.T, txHex string, inputs []bean.RawTxInputItem, keys ...*testKey) string {
+	var err error
+	for _, key := range keys {
+		txHex, err = omnicore.SignRawHex(inputs, txHex, key.wif.String(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return txHex
+}
+
+func getRawTxInputs(inputs interface{}, redeemScript string) []bean.RawTxInputItem {
+	items := make([]bean.RawTxInputItem, 0)
+	for _, input := range inputs.([]map[string]interface{}) {
+		items = append(items, bean.RawTxInputItem{ScriptPubKey: input["scriptPubKey"].(string), RedeemScript: redeemScript})
+	}
+	return items
+}
+
+// the outputs of the unsent transaction to the multisig address, they are spent by the RD and the BR
+func getUnsentOutputs(t *testing.T, chain *Chain, txHex string, to multiSig) []bean.TransactionInputItem {
+	tx, err := decodeTx(txHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := make([]bean.TransactionInputItem, 0)
+	for index, txOut := range tx.TxOut {
+		if chain.getAddress(txOut.PkScript) == to.address {
+			items = append(items, bean.TransactionInputItem{
+				Txid:         tx.TxHash().String(),
+				Vout:         uint32(index),
+				Amount:       float64(txOut.Value) / 1e8,
+				ScriptPubKey: hex.EncodeToString(txOut.PkScript),
+				RedeemScript: to.redeemScript,
+			})
+		}
+	}
+	return items
+}
+
+func sendTx(t *testing.T, txHex string) string {
+	txid, err := conn2tracker.SendRawTransaction(context.Background(), txHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txid
+}
+
+func getOmniBalance(t *testing.T, address string, propertyId int64) float64 {
+	balance, err := conn2tracker.OmniGetBalancesForAddress(context.Background(), address, int(propertyId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance.Balance
+}
+
+// open and fund a channel of an omni asset, and then break it by the revoked commitment transaction:
+// the RD of the cheater is locked by its sequence, and the BR of the counterparty takes the asset at once
+func TestChannelBreach(t *testing.T) {
+	chain, stop := startTracker(t)
+	defer stop()
+
+	alice := newTestKey(t, chain)
+	aliceTemp := newTestKey(t, chain)
+	bob := newTestKey(t, chain)
+	if _, err := chain.Fund(alice.address, 1); err != nil {
+		t.Fatal(err)
+	}
+	propertyId, err := chain.IssueProperty(alice.address, 2, "USDT", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = chain.Grant(propertyId, alice.address, 100); err != nil {
+		t.Fatal(err)
+	}
+	chain.Mine(1)
+	channel := newMultiSig(t, alice, bob)
+
+	// fund the btc for the miner fees
+	retMap, err := omnicore.BtcCreateRawTransaction(alice.address, []bean.TransactionOutputItem{{ToBitCoinAddress: channel.address, Amount: 0.0004}}, 0, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendTx(t, signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), alice))
+	chain.Mine(1)
+
+	// fund the asset
+	retMap, err = omnicore.OmniCreateRawTransaction(alice.address, channel.address, int64(propertyId), 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fundingHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), alice)
+	decoded, err := conn2tracker.OmniDecodeTransaction(context.Background(), fundingHex)
+	if err != nil || gjson.Get(decoded, "referenceaddress").Str != channel.address || gjson.Get(decoded, "amount").Float() != 50 {
+		t.Fatalf("the funding transaction is decoded as %s %v", decoded, err)
+	}
+	sendTx(t, fundingHex)
+	chain.Mine(1)
+	if balance := getOmniBalance(t, channel.address, int64(propertyId)); balance != 50 {
+		t.Fatalf("the asset of the channel is %v, want 50", balance)
+	}
+
+	// the commitment transaction C1 sends all the asset to the RSMC of alice, signed by both sides
+	rsmc := newMultiSig(t, aliceTemp, bob)
+	unspent, err := conn2tracker.ListUnspent(context.Background(), channel.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retMap, _, err = omnicore.OmniCreateRawTransactionUseSingleInput(unspent, channel.address, rsmc.address, int64(propertyId), 50, 0, 0, &channel.redeemScript, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1Hex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+	rsmcOutputs := getUnsentOutputs(t, chain, c1Hex, rsmc)
+
+	// RD of alice is locked for 1000 blocks, BR of bob is signed with the temp key which alice revoked
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(rsmc.address, rsmcOutputs, alice.address, alice.address, int64(propertyId), 50, 0.00003, 1000, &rsmc.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], rsmc.redeemScript), aliceTemp, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(rsmc.address, rsmcOutputs, bob.address, bob.address, int64(propertyId), 50, 0.00003, 0, &rsmc.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	brHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], rsmc.redeemScript), aliceTemp, bob)
+
+	// alice broadcasts the revoked commitment transaction
+	c1Txid := sendTx(t, c1Hex)
+	chain.Mine(1)
+	transactions, err := conn2tracker.OmniListTransactions(context.Background(), channel.address)
+	if err != nil || gjson.Parse(transactions).Array()[0].Get("txid").Str != c1Txid || gjson.Parse(transactions).Array()[0].Get("valid").Bool() == false {
+		t.Fatalf("the transactions of the channel are %s %v", transactions, err)
+	}
+	if balance := getOmniBalance(t, rsmc.address, int64(propertyId)); balance != 50 {
+		t.Fatalf("the asset of the rsmc is %v, want 50", balance)
+	}
+	result, err := conn2tracker.TestMemPoolAccept(context.Background(), rdHex)
+	if err != nil || result.Allowed || result.RejectReason != "non-BIP68-final" {
+		t.Fatalf("the RD is accepted before its sequence: %+v %v", result, err)
+	}
+
+	// bob punishes alice by the BR
+	sendTx(t, brHex)
+	chain.Mine(1)
+	if balance := getOmniBalance(t, bob.address, int64(propertyId)); balance != 50 {
+		t.Fatalf("the asset of bob is %v, want 50", balance)
+	}
+	chain.Mine(1000)
+	if _, err = conn2tracker.SendRawTransaction(context.Background(), rdHex); err == nil || err.Error() != "missing-inputs" {
+		t.Fatalf("the RD is sent after the BR: %v", err)
+	}
+}
+
+// the same steps as the service layer of obd: the commitment transactions are built by the omnicore builders from the
+// unspent outputs of the channel, and signed by both peers. the peers pay by RSMC and HTLC, and then alice closes the
+// channel by the latest commitment transaction: bob gets the htlc by R, and alice gets her RSMC after its sequence.
+func TestChannelLifecycle(t *testing.T) {
+	chain, stop := startTracker(t)
+	defer stop()
+
+	alice := newTestKey(t, chain)
+	bob := newTestKey(t, chain)
+	if _, err := chain.Fund(alice.address, 1); err != nil {
+		t.Fatal(err)
+	}
+	propertyId, err := chain.IssueProperty(alice.address, 2, "USDT", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = chain.Grant(propertyId, alice.address, 100); err != nil {
+		t.Fatal(err)
+	}
+	chain.Mine(1)
+	channel := newMultiSig(t, alice, bob)
+
+	// open: the btc of the miner fees is funded three times, one for every output of the commitment transactions
+	btcAmount := 0.0004
+	minerFee := tool.GetBtcMinerAmount(btcAmount)
+	for i := 0; i < 3; i++ {
+		retMap, err := omnicore.BtcCreateRawTransaction(alice.address, []bean.TransactionOutputItem{{ToBitCoinAddress: channel.address, Amount: btcAmount}}, 0, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sendTx(t, signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), alice))
+		chain.Mine(1)
+	}
+	retMap, err := omnicore.OmniCreateRawTransaction(alice.address, channel.address, int64(propertyId), 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendTx(t, signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), alice))
+	chain.Mine(1)
+	unspent, err := conn2tracker.ListUnspent(context.Background(), channel.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C1 sends all the asset to the RSMC of alice
+	aliceTemp1 := newTestKey(t, chain)
+	rsmc1 := newMultiSig(t, aliceTemp1, bob)
+	retMap, _, err = omnicore.OmniCreateRawTransactionUseSingleInput(unspent, channel.address, rsmc1.address, int64(propertyId), 50, 0, 0, &channel.redeemScript, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1RsmcHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+
+	// RSMC: alice pays 10 to bob by C2, the change of the channel goes back to the funder
+	aliceTemp2 := newTestKey(t, chain)
+	rsmc2 := newMultiSig(t, aliceTemp2, bob)
+	retMap, usedTxid, err := omnicore.OmniCreateRawTransactionUseSingleInput(unspent, channel.address, rsmc2.address, int64(propertyId), 40, 0, 0, &channel.redeemScript, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2RsmcHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseRestInput(0, unspent, channel.address, usedTxid, bob.address, alice.address, int64(propertyId), 10, minerFee, &channel.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2ToBobHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+	if c2ToBobHex == c1RsmcHex {
+		t.Fatal("C2 is the same as C1")
+	}
+
+	// HTLC: alice pays 10 more to bob by C3, the htlc is locked by H, whose private key R is known by bob at last
+	aliceTemp3, aliceHtlcTemp, aliceHt1aTemp, htlcKey := newTestKey(t, chain), newTestKey(t, chain), newTestKey(t, chain), newTestKey(t, chain)
+	rsmc3 := newMultiSig(t, aliceTemp3, bob)
+	htlc3 := newMultiSig(t, aliceHtlcTemp, bob)
+	retMap, usedTxid, err = omnicore.OmniCreateRawTransactionUseSingleInput(unspent, channel.address, rsmc3.address, int64(propertyId), 30, 0, 0, &channel.redeemScript, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	allUsedTxid := usedTxid
+	c3RsmcHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+	retMap, usedTxid, err = omnicore.OmniCreateRawTransactionUseSingleInput(unspent, channel.address, htlc3.address, int64(propertyId), 10, 0, 0, &channel.redeemScript, allUsedTxid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allUsedTxid += "," + usedTxid
+	c3HtlcHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseRestInput(0, unspent, channel.address, allUsedTxid, bob.address, alice.address, int64(propertyId), 10, minerFee, &channel.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c3ToBobHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], channel.redeemScript), alice, bob)
+
+	// alice gets back the htlc by HT1a after its timeout, bob gets it by H-lock and HE with R before the timeout
+	htlcOutputs := getUnsentOutputs(t, chain, c3HtlcHex, htlc3)
+	ht1a := newMultiSig(t, aliceHt1aTemp, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(htlc3.address, htlcOutputs, ht1a.address, ht1a.address, int64(propertyId), 10, minerFee, 100, &htlc3.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ht1aHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], htlc3.redeemScript), aliceHtlcTemp, bob)
+	hlock := newMultiSig(t, htlcKey, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(htlc3.address, htlcOutputs, hlock.address, hlock.address, int64(propertyId), 10, minerFee, 0, &htlc3.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hlockHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], htlc3.redeemScript), aliceHtlcTemp, bob)
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(hlock.address, getUnsentOutputs(t, chain, hlockHex, hlock), bob.address, bob.address, int64(propertyId), 10, minerFee, 0, &hlock.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], hlock.redeemScript), htlcKey, bob)
+
+	// the RD of alice of C3 is locked for 1000 blocks
+	retMap, err = omnicore.OmniCreateRawTransactionUseUnsendInput(rsmc3.address, getUnsentOutputs(t, chain, c3RsmcHex, rsmc3), alice.address, alice.address, int64(propertyId), 30, minerFee, 1000, &rsmc3.redeemScript)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdHex := signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], rsmc3.redeemScript), aliceTemp3, bob)
+
+	// close: alice broadcasts the latest commitment transaction C3
+	for _, txHex := range []string{c3RsmcHex, c3HtlcHex, c3ToBobHex} {
+		sendTx(t, txHex)
+	}
+	chain.Mine(1)
+	for address, amount := range map[string]float64{rsmc3.address: 30, htlc3.address: 10, bob.address: 10, channel.address: 0} {
+		if balance := getOmniBalance(t, address, int64(propertyId)); balance != amount {
+			t.Fatalf("the asset of %s is %v, want %v", address, balance, amount)
+		}
+	}
+	// the revoked commitment transactions spend the same outputs of the channel
+	for _, txHex := range []string{c1RsmcHex, c2RsmcHex, c2ToBobHex} {
+		if _, err = conn2tracker.SendRawTransaction(context.Background(), txHex); err == nil || err.Error() != "missing-inputs" {
+			t.Fatalf("the revoked commitment transaction is sent after the close: %v", err)
+		}
+	}
+
+	// bob gets the htlc by R before the timeout of alice
+	result, err := conn2tracker.TestMemPoolAccept(context.Background(), ht1aHex)
+	if err != nil || result.Allowed || result.RejectReason != "non-BIP68-final" {
+		t.Fatalf("the HT1a is accepted before its timeout: %+v %v", result, err)
+	}
+	sendTx(t, hlockHex)
+	chain.Mine(1)
+	sendTx(t, heHex)
+	chain.Mine(1)
+	if balance := getOmniBalance(t, bob.address, int64(propertyId)); balance != 20 {
+		t.Fatalf("the asset of bob is %v, want 20", balance)
+	}
+
+	// alice gets her RSMC after its sequence, the HT1a is useless now
+	result, err = conn2tracker.TestMemPoolAccept(context.Background(), rdHex)
+	if err != nil || result.Allowed || result.RejectReason != "non-BIP68-final" {
+		t.Fatalf("the RD is accepted before its sequence: %+v %v", result, err)
+	}
+	chain.Mine(1000)
+	if _, err = conn2tracker.SendRawTransaction(context.Background(), ht1aHex); err == nil || err.Error() != "missing-inputs" {
+		t.Fatalf("the HT1a is sent after the HE: %v", err)
+	}
+	sendTx(t, rdHex)
+	chain.Mine(1)
+	if balance := getOmniBalance(t, alice.address, int64(propertyId)); balance != 80 {
+		t.Fatalf("the asset of alice is %v, want 80", balance)
+	}
+}
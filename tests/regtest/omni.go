@@ -0,0 +1,299 @@
+package regtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// the marker of the omni class C transactions in the op_return output
+var omniMarker = []byte("omni")
+
+const (
+	omniTxType_SimpleSend = 0
+
+	// the first property ids of the main and the test ecosystems, 1 and 2 are OMNI and TOMNI
+	firstMainPropertyId = 3
+	firstTestPropertyId = 2147483651
+)
+
+type omniProperty struct {
+	id           uint32
+	name         string
+	category     string
+	data         string
+	divisible    bool
+	issuer       string
+	creationTxid string
+	managed      bool
+	totalTokens  int64
+}
+
+// omniTx the simple send of an omni transaction, it is valid only if the sender has enough balance when it is mined
+type omniTx struct {
+	sender     string
+	reference  string
+	propertyId uint32
+	amount     int64
+	fee        int64
+
+	valid           bool
+	invalidReason   string
+	positionInBlock int
+}
+
+type omniState struct {
+	properties map[uint32]*omniProperty
+	// address -> property id -> the amount in the smallest unit
+	balances       map[string]map[uint32]int64
+	nextPropertyId map[int]uint32
+	issuanceCount  uint32
+}
+
+func newOmniState() *omniState {
+	return &omniState{
+		properties:     make(map[uint32]*omniProperty),
+		balances:       make(map[string]map[uint32]int64),
+		nextPropertyId: map[int]uint32{1: firstMainPropertyId, 2: firstTestPropertyId},
+	}
+}
+
+func (state *omniState) balance(address string, propertyId uint32) int64 {
+	return state.balances[address][propertyId]
+}
+
+func (state *omniState) addBalance(address string, propertyId uint32, amount int64) {
+	if state.balances[address] == nil {
+		state.balances[address] = make(map[uint32]int64)
+	}
+	state.balances[address][propertyId] += amount
+}
+
+// the amount in the smallest unit of the property, the divisible ones have 8 decimals
+func toOmniAmount(amount float64, divisible bool) (int64, error) {
+	if divisible == false {
+		if amount != float64(int64(amount)) {
+			return 0, errors.New("Invalid amount, the property is indivisible")
+		}
+		return int64(amount), nil
+	}
+	value, err := btcutil.NewAmount(amount)
+	return int64(value), err
+}
+
+func formatOmniAmount(amount int64, divisible bool) string {
+	if divisible == false {
+		return strconv.FormatInt(amount, 10)
+	}
+	return strconv.FormatFloat(btcutil.Amount(amount).ToBTC(), 'f', 8, 64)
+}
+
+func (state *omniState) getProperty(propertyId uint32) (*omniProperty, error) {
+	property, ok := state.properties[propertyId]
+	if ok == false {
+		return nil, errors.New("Property identifier does not exist")
+	}
+	return property, nil
+}
+
+// the simple send in the op_return output of the transaction, nil if it is not an omni transaction
+func (state *omniState) decode(chain *Chain, record *txRecord) *omniTx {
+	var payload []byte
+	for _, txOut := range record.tx.TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) != txscript.NullDataTy {
+			continue
+		}
+		pushes, err := txscript.PushedData(txOut.PkScript)
+		if err != nil {
+			continue
+		}
+		data := bytes.Join(pushes, nil)
+		if bytes.HasPrefix(data, omniMarker) {
+			payload = data[len(omniMarker):]
+			break
+		}
+	}
+	// version, type, property id and amount
+	if len(payload) < 16 || binary.BigEndian.Uint16(payload[2:4]) != omniTxType_SimpleSend {
+		return nil
+	}
+	result := &omniTx{
+		propertyId: binary.BigEndian.Uint32(payload[4:8]),
+		amount:     int64(binary.BigEndian.Uint64(payload[8:16])),
+	}
+
+	// the sender is the address which contributes the most to the inputs
+	contributions := make(map[string]int64)
+	var valueIn, valueOut int64
+	for _, txIn := range record.tx.TxIn {
+		prevOut, _, ok := chain.getPrevOut(txIn.PreviousOutPoint)
+		if ok == false {
+			return nil
+		}
+		contributions[chain.getAddress(prevOut.PkScript)] += prevOut.Value
+		valueIn += prevOut.Value
+	}
+	addresses := make([]string, 0, len(contributions))
+	for address := range contributions {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		if result.sender == "" || contributions[address] > contributions[result.sender] {
+			result.sender = address
+		}
+	}
+
+	// the reference is the last output which does not pay the sender, or the sender itself
+	for _, txOut := range record.tx.TxOut {
+		valueOut += txOut.Value
+		address := chain.getAddress(txOut.PkScript)
+		if address == "" {
+			continue
+		}
+		if address != result.sender || result.reference == "" {
+			result.reference = address
+		}
+	}
+	result.fee = valueIn - valueOut
+	return result
+}
+
+// apply the simple send of the transaction when it is mined
+func (state *omniState) apply(record *txRecord, positionInBlock int) {
+	tx := record.omni
+	if tx == nil {
+		return
+	}
+	tx.positionInBlock = positionInBlock
+	tx.valid = false
+	if _, err := state.getProperty(tx.propertyId); err != nil {
+		tx.invalidReason = "Property does not exist"
+		return
+	}
+	if tx.amount <= 0 {
+		tx.invalidReason = "Value out of range or zero"
+		return
+	}
+	if state.balance(tx.sender, tx.propertyId) < tx.amount {
+		tx.invalidReason = "Sender has insufficient balance"
+		return
+	}
+	state.addBalance(tx.sender, tx.propertyId, -tx.amount)
+	state.addBalance(tx.reference, tx.propertyId, tx.amount)
+	tx.valid = true
+	tx.invalidReason = ""
+}
+
+// IssueProperty create a property of the ecosystem, 1 for main and 2 for test, and give the amount to the issuer.
+// The issuance is applied at once without a bitcoin transaction, the property is managed if the amount is 0.
+func (chain *Chain) IssueProperty(issuer string, ecosystem int, name string, divisible bool, amount float64) (propertyId uint32, err error) {
+	if _, err = chain.payToAddress(issuer); err != nil {
+		return 0, err
+	}
+	if ecosystem != 1 && ecosystem != 2 {
+		return 0, errors.New("Invalid ecosystem (1 = main, 2 = test only)")
+	}
+	value, err := toOmniAmount(amount, divisible)
+	if err != nil || value < 0 {
+		return 0, errors.New("Invalid amount")
+	}
+
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	state := chain.omni
+	state.issuanceCount++
+	propertyId = state.nextPropertyId[ecosystem]
+	state.nextPropertyId[ecosystem]++
+	creationTxid := chainhash.DoubleHashH([]byte(name + strconv.Itoa(int(state.issuanceCount))))
+	state.properties[propertyId] = &omniProperty{
+		id:           propertyId,
+		name:         name,
+		divisible:    divisible,
+		issuer:       issuer,
+		creationTxid: creationTxid.String(),
+		managed:      value == 0,
+		totalTokens:  value,
+	}
+	if value > 0 {
+		state.addBalance(issuer, propertyId, value)
+	}
+	return propertyId, nil
+}
+
+// Grant give the tokens of the managed property to the address at once
+func (chain *Chain) Grant(propertyId uint32, address string, amount float64) error {
+	if _, err := chain.payToAddress(address); err != nil {
+		return err
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	property, err := chain.omni.getProperty(propertyId)
+	if err != nil {
+		return err
+	}
+	if property.managed == false {
+		return errors.New("Property does not have managed issuance")
+	}
+	value, err := toOmniAmount(amount, property.divisible)
+	if err != nil || value <= 0 {
+		return errors.New("Invalid amount")
+	}
+	property.totalTokens += value
+	chain.omni.addBalance(address, propertyId, value)
+	return nil
+}
+
+// OmniBalance the confirmed balance of the property of the address
+func (chain *Chain) OmniBalance(address string, propertyId uint32) (float64, error) {
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	property, err := chain.omni.getProperty(propertyId)
+	if err != nil {
+		return 0, err
+	}
+	balance := chain.omni.balance(address, propertyId)
+	if property.divisible {
+		return btcutil.Amount(balance).ToBTC(), nil
+	}
+	return float64(balance), nil
+}
+
+// the json of omni_gettransaction, or omni_decodetransaction if the transaction is not sent
+func (chain *Chain) omniTxInfo(record *txRecord, tx *omniTx) map[string]interface{} {
+	divisible := true
+	if property, err := chain.omni.getProperty(tx.propertyId); err == nil {
+		divisible = property.divisible
+	}
+	info := map[string]interface{}{
+		"txid":             record.hash.String(),
+		"fee":              formatOmniAmount(tx.fee, true),
+		"sendingaddress":   tx.sender,
+		"referenceaddress": tx.reference,
+		"ismine":           false,
+		"version":          0,
+		"type_int":         omniTxType_SimpleSend,
+		"type":             "Simple Send",
+		"propertyid":       tx.propertyId,
+		"divisible":        divisible,
+		"amount":           formatOmniAmount(tx.amount, divisible),
+		"confirmations":    chain.confirmations(record),
+	}
+	if record.height > 0 {
+		info["valid"] = tx.valid
+		if tx.valid == false {
+			info["invalidreason"] = tx.invalidReason
+		}
+		info["blockhash"] = chain.blockHashes[record.height].String()
+		info["blocktime"] = chain.blockTimes[record.height].Unix()
+		info["positioninblock"] = tx.positionInBlock
+		info["block"] = record.height
+	}
+	return info
+}
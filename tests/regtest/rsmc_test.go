@@ -0,0 +1,264 @@
+package regtest
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/btcsuite/btcutil"
+	"github.com/omnilaboratory/obd/admin"
+	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/bean/enum"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/omnilaboratory/obd/conn"
+	"github.com/omnilaboratory/obd/dao"
+	"github.com/omnilaboratory/obd/omnicore"
+	"github.com/omnilaboratory/obd/service"
+	"github.com/tidwall/gjson"
+)
+
+// the user of obd with the hd wallet, the address of the index 1 is its channel address
+func newWalletUser(t *testing.T, dir string, peerId string) (*bean.User, *testKey) {
+	mnemonic, err := service.HDWalletService.Bip39GenMnemonic(128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeExtKey, err := service.HDWalletService.CreateChangeExtKey(mnemonic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := storm.Open(dir + "/user_" + peerId + ".db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &bean.User{PeerId: peerId, P2PLocalPeerId: "node", Mnemonic: mnemonic, ChangeExtKey: changeExtKey, Db: db}
+	wallet, err := service.HDWalletService.CreateNewAddress(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wif, err := btcutil.DecodeWIF(wallet.Wif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user, &testKey{wif: wif, pubKey: wallet.PubKey, address: wallet.Address}
+}
+
+func toJson(data interface{}) string {
+	bytes, _ := json.Marshal(data)
+	return string(bytes)
+}
+
+// alice pays bob by RSMC through the service layer of obd when no tracker is configured, both peers are online on the
+// same obd. the channel is funded on the simulator at first, and the commitment transaction C2a is checked by it at last.
+func TestRsmcWithoutTracker(t *testing.T) {
+	chain, stop := startTracker(t)
+	defer stop()
+	dir, err := ioutil.TempDir("", "obd_rsmc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	alice, aliceKey := newWalletUser(t, dir, "alice")
+	defer alice.Db.Close()
+	bob, bobKey := newWalletUser(t, dir, "bob")
+	defer bob.Db.Close()
+	if _, err = chain.Fund(aliceKey.address, 1); err != nil {
+		t.Fatal(err)
+	}
+	propertyId, err := chain.IssueProperty(aliceKey.address, 2, "USDT", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = chain.Grant(propertyId, aliceKey.address, 100); err != nil {
+		t.Fatal(err)
+	}
+	chain.Mine(1)
+
+	// fund the channel as TestChannelLifecycle
+	channel := newMultiSig(t, aliceKey, bobKey)
+	btcAmount := 0.0004
+	for i := 0; i < 3; i++ {
+		retMap, err := omnicore.BtcCreateRawTransaction(aliceKey.address, []bean.TransactionOutputItem{{ToBitCoinAddress: channel.address, Amount: btcAmount}}, 0, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sendTx(t, signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), aliceKey))
+		chain.Mine(1)
+	}
+	retMap, err := omnicore.OmniCreateRawTransaction(aliceKey.address, channel.address, int64(propertyId), 50, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendTx(t, signTx(t, retMap["hex"].(string), getRawTxInputs(retMap["inputs"], ""), aliceKey))
+	chain.Mine(1)
+	unspent, err := conn2tracker.ListUnspent(context.Background(), channel.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// both peers keep the channel, its unspent outputs and the funding transaction, which is older than the check of
+	// the channel balance by the tracker
+	channelInfo := dao.ChannelInfo{ChannelId: "channel", PeerIdA: "alice", PeerIdB: "bob",
+		PubKeyA: aliceKey.pubKey, AddressA: aliceKey.address, PubKeyB: bobKey.pubKey, AddressB: bobKey.address,
+		FundeeAddressIndex: 1, ChannelAddress: channel.address, ChannelAddressRedeemScript: channel.redeemScript,
+		ChannelAddressScriptPubKey: channel.scriptPubKey, PropertyId: int64(propertyId), Amount: 50, BtcAmount: btcAmount,
+		CurrState: bean.ChannelState_CanUse, CreateBy: "alice", CreateAt: time.Now()}
+	channelInfo.FunderAddressIndex = 1
+	channelInfo.FundingAddress = aliceKey.address
+	fundingTx := dao.FundingTransaction{ChannelId: "channel", CurrState: dao.FundingTransactionState_Accept,
+		PeerIdA: "alice", PeerIdB: "bob", PropertyId: int64(propertyId), AmountA: 50, FunderAddress: aliceKey.address,
+		CreateBy: "alice", CreateAt: time.Now().Add(-time.Hour)}
+	for _, user := range []*bean.User{alice, bob} {
+		channelInfo.Id, fundingTx.Id = 0, 0
+		fundingTx.Owner = user.PeerId
+		if err = user.Db.Save(&channelInfo); err != nil {
+			t.Fatal(err)
+		}
+		if err = user.Db.Save(&fundingTx); err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range gjson.Parse(unspent).Array() {
+			output := dao.ChannelAddressListUnspent{ChannelId: "channel", Txid: item.Get("txid").Str, Vout: uint32(item.Get("vout").Uint()),
+				ScriptPubKey: item.Get("scriptPubKey").Str, Amount: item.Get("amount").Float()}
+			if err = user.Db.Save(&output); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// C1a sends all the asset to the rsmc of alice
+	aliceTemp, err := service.HDWalletService.CreateNewAddress(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1a := dao.CommitmentTransaction{ChannelId: "channel", PeerIdA: "alice", PeerIdB: "bob", PropertyId: int64(propertyId),
+		TxType: dao.CommitmentTransactionType_Rsmc, RSMCTempAddressIndex: aliceTemp.Index, RSMCTempAddressPubKey: aliceTemp.PubKey,
+		AmountToRSMC: 50, CurrState: dao.TxInfoState_CreateAndSign, Owner: "alice", CreateAt: time.Now()}
+	if err = alice.Db.Save(&c1a); err != nil {
+		t.Fatal(err)
+	}
+
+	// no tracker from now on, the users of the local obd are found without it
+	config.TrackerHosts = nil
+	service.P2PLocalNodeId = "node"
+	defer func() { service.P2PLocalNodeId = "" }()
+	service.OnlineUserMap["bob"] = bob
+	defer delete(service.OnlineUserMap, "bob")
+	if nodeId, err := service.GetUserP2pNodeId("bob"); err != nil || nodeId != "node" {
+		t.Fatalf("got the node %s of bob by %v, want the local node", nodeId, err)
+	}
+	if _, err = service.GetUserP2pNodeId("carol"); conn2tracker.IsTrackerUnavailable(err) == false {
+		t.Fatalf("got %v for the remote user, want the tracker unavailable", err)
+	}
+
+	// step 1 and 2: alice creates and signs C2a
+	msg := bean.RequestMessage{Type: enum.MsgType_CommitmentTx_SendCommitmentTransactionCreated_351,
+		SenderUserPeerId: "alice", SenderNodePeerId: "node", RecipientUserPeerId: "bob", RecipientNodePeerId: "node",
+		Data: toJson(bean.RequestCreateCommitmentTx{ChannelId: "channel", Amount: 10})}
+	if err = admin.RsmcAliceCreateTx(&msg, alice); err != nil {
+		t.Fatal(err)
+	}
+	needSign, _, err := service.CommitmentTxService.CommitmentTransactionCreated(msg, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedC2a, err := admin.RsmcAliceFirstSignC2a(needSign, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg.Data = toJson(signedC2a)
+	_, toBob, err := service.CommitmentTxService.OnAliceSignC2aRawTxAtAliceSide(msg, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// step 3 to 5: bob signs C2a, and creates C2b
+	requestToBob, err := service.CommitmentTxSignedService.BeforeBobSignCommitmentTransactionAtBobSide(toJson(toBob), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedByBob, err := admin.RsmcBobSecondSignC2a(requestToBob, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg = bean.RequestMessage{Type: enum.MsgType_CommitmentTxSigned_SendRevokeAndAcknowledgeCommitmentTransaction_352,
+		SenderUserPeerId: "bob", SenderNodePeerId: "node", RecipientUserPeerId: "alice", RecipientNodePeerId: "node",
+		Data: toJson(signedByBob)}
+	c2b, _, err := service.CommitmentTxSignedService.RevokeAndAcknowledgeCommitmentTransaction(msg, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedC2b, err := admin.RsmcBobFirstSignC2b(c2b, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, toAlice, err := service.CommitmentTxSignedService.OnBobSignC2bTransactionAtBobSide(toJson(signedC2b), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// step 6 to 8: alice signs C2b and its RD
+	msg.Type = enum.MsgType_CommitmentTxSigned_ToAliceSign_352
+	msg.Data = toJson(toAlice)
+	partialC2b, _, err := service.CommitmentTxService.OnGetBobC2bPartialSignTxAtAliceSide(msg, msg.Data, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedC2bByAlice, err := admin.RsmcAliceSignC2b(partialC2b, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdOfC2b, err := service.CommitmentTxService.OnAliceSignedC2bTxAtAliceSide(toJson(signedC2bByAlice), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedRdOfC2b, err := admin.RsmcAliceSignRdOfC2b(rdOfC2b, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, toBob, _, err = service.CommitmentTxService.OnAliceSignedC2b_RDTxAtAliceSide(toJson(signedRdOfC2b), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// step 9 and 10: bob signs the RD of C2b
+	needBobSignRd, err := service.CommitmentTxSignedService.OnGetAliceSignC2bTransactionAtBobSide(toJson(toBob), bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedRdByBob, err := admin.RsmcBobSignRdOfC2b(needBobSignRd, bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = service.CommitmentTxSignedService.BobSignC2bRdAtBobSide(toJson(signedRdByBob), bob); err != nil {
+		t.Fatal(err)
+	}
+
+	// both peers have the signed C2, alice 40 and bob 10
+	for _, item := range []struct {
+		user                 *bean.User
+		toRsmc, counterparty float64
+	}{{alice, 40, 10}, {bob, 10, 40}} {
+		c2 := dao.CommitmentTransaction{}
+		if err = item.user.Db.Select(q.Eq("ChannelId", "channel")).OrderBy("CreateAt").Reverse().First(&c2); err != nil {
+			t.Fatal(err)
+		}
+		if c2.CurrState != dao.TxInfoState_CreateAndSign || c2.AmountToRSMC != item.toRsmc || c2.AmountToCounterparty != item.counterparty {
+			t.Fatalf("the C2 of %s is %v with %v and %v, want signed with %v and %v", item.user.PeerId, c2.CurrState,
+				c2.AmountToRSMC, c2.AmountToCounterparty, item.toRsmc, item.counterparty)
+		}
+		if item.user == alice {
+			for _, txHex := range []string{c2.RSMCTxHex, c2.ToCounterpartyTxHex} {
+				if _, err = chain.TestMempoolAccept(txHex); err != nil {
+					t.Fatalf("the C2a is rejected: %v", err)
+				}
+			}
+		}
+	}
+}
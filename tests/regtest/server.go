@@ -0,0 +1,584 @@
+package regtest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// Server serves the /api/rpc methods of the tracker by the chain, so that obd and conn2tracker can use it as the tracker,
+// and /api/regtest to fund the addresses and to mine the blocks.
+type Server struct {
+	chain    *Chain
+	router   *gin.Engine
+	listener net.Listener
+	server   *http.Server
+}
+
+// NewServer the http server of the chain, it is started by Start, or used as an http.Handler
+func NewServer(chain *Chain) *Server {
+	gin.SetMode(gin.ReleaseMode)
+	server := &Server{chain: chain, router: gin.New()}
+	server.router.Use(gin.Recovery())
+
+	rpc := server.router.Group("/api/rpc/")
+	{
+		rpc.GET("getChainNodeType", server.getChainNodeType)
+		rpc.GET("getBlockCount", server.getBlockCount)
+		rpc.GET("getOmniBalance", server.getOmniBalance)
+		rpc.GET("getBalanceByAddress", server.getBalanceByAddress)
+		rpc.GET("importAddress", server.importAddress)
+		rpc.GET("listReceivedByAddress", server.listReceivedByAddress)
+		rpc.GET("getTransactionById", server.getTransactionById)
+		rpc.GET("omniGettransaction", server.omniGettransaction)
+		rpc.GET("omniGetProperty", server.omniGetProperty)
+		rpc.POST("createRawTransaction", server.createRawTransaction)
+		rpc.GET("estimateSmartFee", server.estimateSmartFee)
+		rpc.GET("listUnspent", server.listUnspent)
+		rpc.GET("omniGetAllBalancesForAddress", server.omniGetAllBalancesForAddress)
+		rpc.GET("omniGetBalancesForAddress", server.omniGetBalancesForAddress)
+		rpc.GET("testMemPoolAccept", server.testMemPoolAccept)
+		rpc.GET("sendRawTransaction", server.sendRawTransaction)
+		rpc.GET("omniDecodeTransaction", server.omniDecodeTransaction)
+		rpc.GET("omniListTransactions", server.omniListTransactions)
+		rpc.GET("omniListProperties", server.omniListProperties)
+		rpc.GET("omniSendIssuanceFixed", server.omniSendIssuanceFixed)
+		rpc.GET("omniSendIssuanceManaged", server.omniSendIssuanceManaged)
+		rpc.GET("omniSendGrant", server.omniSendGrant)
+		rpc.GET("getMiningInfo", server.getMiningInfo)
+		rpc.GET("getNetworkInfo", server.getNetworkInfo)
+	}
+
+	regtest := server.router.Group("/api/regtest/")
+	{
+		regtest.GET("fund", server.fund)
+		regtest.GET("generate", server.generate)
+	}
+	return server
+}
+
+// Handler the http handler of the routes
+func (server *Server) Handler() http.Handler {
+	return server.router
+}
+
+// Start listen on the address, such as 127.0.0.1:0, and return the host to be set as the tracker host
+func (server *Server) Start(address string) (host string, err error) {
+	server.listener, err = net.Listen("tcp", address)
+	if err != nil {
+		return "", err
+	}
+	server.server = &http.Server{Handler: server.router}
+	go func() {
+		_ = server.server.Serve(server.listener)
+	}()
+	return server.listener.Addr().String(), nil
+}
+
+// Close stop the server started by Start
+func (server *Server) Close() error {
+	if server.server == nil {
+		return nil
+	}
+	return server.server.Close()
+}
+
+func replyData(context *gin.Context, msg string, data interface{}) {
+	context.JSON(http.StatusOK, gin.H{
+		"msg":  msg,
+		"data": data,
+	})
+}
+
+// the results of the omnicore node are json strings in the data
+func replyJson(context *gin.Context, msg string, result interface{}, err error) {
+	if err != nil {
+		replyData(context, err.Error(), "")
+		return
+	}
+	bytes, _ := json.Marshal(result)
+	replyData(context, msg, string(bytes))
+}
+
+func replyError(context *gin.Context, err error) {
+	context.JSON(http.StatusInternalServerError, gin.H{
+		"msg": err.Error(),
+	})
+}
+
+func (server *Server) getChainNodeType(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{
+		"msg":               "",
+		"chainNodeType":     "regtest",
+		"trackerP2pAddress": "",
+	})
+}
+
+func (server *Server) getBlockCount(context *gin.Context) {
+	replyData(context, "blockCount", server.chain.Height())
+}
+
+func getPropertyId(context *gin.Context) uint32 {
+	propertyId, _ := strconv.ParseUint(context.Query("propertyId"), 10, 32)
+	return uint32(propertyId)
+}
+
+func (server *Server) getOmniBalance(context *gin.Context) {
+	balance, _ := server.chain.OmniBalance(context.Query("address"), getPropertyId(context))
+	replyData(context, "OmniGetbalance", balance)
+}
+
+func (server *Server) getBalanceByAddress(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	var balance btcutil.Amount
+	for _, item := range chain.listUnspent(context.Query("address")) {
+		balance += btcutil.Amount(item.output.Value)
+	}
+	replyData(context, "", strconv.FormatFloat(balance.ToBTC(), 'f', 8, 64))
+}
+
+// all addresses are watched by the chain
+func (server *Server) importAddress(context *gin.Context) {
+	context.JSON(http.StatusOK, gin.H{
+		"msg": "importAddress",
+	})
+}
+
+func (server *Server) listReceivedByAddress(context *gin.Context) {
+	address := context.Query("address")
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+
+	result := make([]map[string]interface{}, 0)
+	var amount btcutil.Amount
+	txids := make([]string, 0)
+	confirmations := int32(-1)
+	for _, record := range chain.sortedTxs() {
+		received := false
+		for _, txOut := range record.tx.TxOut {
+			if chain.getAddress(txOut.PkScript) == address {
+				amount += btcutil.Amount(txOut.Value)
+				received = true
+			}
+		}
+		if received {
+			txids = append(txids, record.hash.String())
+			confirmations = chain.confirmations(record)
+		}
+	}
+	if len(txids) > 0 {
+		result = append(result, map[string]interface{}{
+			"involvesWatchonly": true,
+			"address":           address,
+			"amount":            amount.ToBTC(),
+			"confirmations":     confirmations,
+			"label":             "",
+			"txids":             txids,
+		})
+	}
+	replyJson(context, "listReceivedByAddress", result, nil)
+}
+
+func (server *Server) getTx(txid string) (*txRecord, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, errors.New("Invalid or non-wallet transaction id")
+	}
+	record, ok := server.chain.txs[*hash]
+	if ok == false {
+		return nil, errors.New("Invalid or non-wallet transaction id")
+	}
+	return record, nil
+}
+
+func (server *Server) getTransactionById(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	record, err := server.getTx(context.Query("txid"))
+	if err != nil {
+		replyError(context, err)
+		return
+	}
+	var valueOut btcutil.Amount
+	for _, txOut := range record.tx.TxOut {
+		valueOut += btcutil.Amount(txOut.Value)
+	}
+	result := map[string]interface{}{
+		"amount":        valueOut.ToBTC(),
+		"confirmations": chain.confirmations(record),
+		"txid":          record.hash.String(),
+		"hex":           txToHex(record.tx),
+	}
+	if record.height > 0 {
+		result["blockhash"] = chain.blockHashes[record.height].String()
+		result["blockheight"] = record.height
+		result["blocktime"] = chain.blockTimes[record.height].Unix()
+	}
+	replyJson(context, "GetTransactionById", result, nil)
+}
+
+func txToHex(tx *wire.MsgTx) string {
+	buf := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
+	_ = tx.Serialize(buf)
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func (server *Server) omniGettransaction(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	record, err := server.getTx(context.Query("txid"))
+	if err == nil && record.omni == nil {
+		err = errors.New("Not a Master Protocol transaction")
+	}
+	if err != nil {
+		replyJson(context, "", nil, err)
+		return
+	}
+	replyJson(context, "", chain.omniTxInfo(record, record.omni), nil)
+}
+
+func (server *Server) omniGetProperty(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	property, err := chain.omni.getProperty(getPropertyId(context))
+	if err != nil {
+		replyJson(context, "", nil, err)
+		return
+	}
+	replyJson(context, "", getPropertyInfo(property), nil)
+}
+
+func getPropertyInfo(property *omniProperty) map[string]interface{} {
+	return map[string]interface{}{
+		"propertyid":      property.id,
+		"name":            property.name,
+		"category":        property.category,
+		"subcategory":     "",
+		"data":            property.data,
+		"url":             "",
+		"divisible":       property.divisible,
+		"issuer":          property.issuer,
+		"creationtxid":    property.creationTxid,
+		"fixedissuance":   property.managed == false,
+		"managedissuance": property.managed,
+		"totaltokens":     formatOmniAmount(property.totalTokens, property.divisible),
+	}
+}
+
+// the body is {"inputs": [{"txid": "", "vout": 0, "sequence": 0}], "outputs": {"address": amount, "data": "hex"}}
+func (server *Server) createRawTransaction(context *gin.Context) {
+	body, err := ioutil.ReadAll(context.Request.Body)
+	if err != nil || gjson.ValidBytes(body) == false {
+		replyError(context, errors.New("error data"))
+		return
+	}
+	data := gjson.ParseBytes(body)
+	tx := wire.NewMsgTx(2)
+	for _, input := range data.Get("inputs").Array() {
+		hash, err := chainhash.NewHashFromStr(input.Get("txid").String())
+		if err != nil {
+			replyError(context, errors.New("txid must be hexadecimal string"))
+			return
+		}
+		txIn := wire.NewTxIn(wire.NewOutPoint(hash, uint32(input.Get("vout").Uint())), nil, nil)
+		if input.Get("sequence").Exists() {
+			txIn.Sequence = uint32(input.Get("sequence").Uint())
+		}
+		tx.AddTxIn(txIn)
+	}
+
+	// the outputs are in the order of the json keys, the same as bitcoin core
+	data.Get("outputs").ForEach(func(key, value gjson.Result) bool {
+		if key.String() == "data" {
+			var payload []byte
+			payload, err = hex.DecodeString(value.String())
+			if err == nil {
+				var script []byte
+				script, err = txscript.NullDataScript(payload)
+				tx.AddTxOut(wire.NewTxOut(0, script))
+			}
+			return err == nil
+		}
+		var pkScript []byte
+		pkScript, err = server.chain.payToAddress(key.String())
+		if err != nil {
+			return false
+		}
+		var amount btcutil.Amount
+		amount, err = btcutil.NewAmount(value.Float())
+		if err != nil {
+			return false
+		}
+		tx.AddTxOut(wire.NewTxOut(int64(amount), pkScript))
+		return true
+	})
+	if err != nil {
+		replyError(context, err)
+		return
+	}
+	replyData(context, "CreateRawTransaction", txToHex(tx))
+}
+
+func (server *Server) estimateSmartFee(context *gin.Context) {
+	server.chain.mu.Lock()
+	feeRate := server.chain.feeRate
+	server.chain.mu.Unlock()
+	replyData(context, "EstimateSmartFee", feeRate)
+}
+
+func (server *Server) listUnspent(context *gin.Context) {
+	address := context.Query("address")
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	result := make([]map[string]interface{}, 0)
+	for _, item := range chain.listUnspent(address) {
+		result = append(result, map[string]interface{}{
+			"txid":          item.outPoint.Hash.String(),
+			"vout":          item.outPoint.Index,
+			"address":       address,
+			"label":         "",
+			"scriptPubKey":  hex.EncodeToString(item.output.PkScript),
+			"amount":        btcutil.Amount(item.output.Value).ToBTC(),
+			"confirmations": item.confirmations,
+			"spendable":     false,
+			"solvable":      false,
+			"safe":          true,
+		})
+	}
+	replyJson(context, "ListUnspent", result, nil)
+}
+
+func (server *Server) omniGetAllBalancesForAddress(context *gin.Context) {
+	address := context.Query("address")
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	propertyIds := make([]int, 0)
+	for propertyId, balance := range chain.omni.balances[address] {
+		if balance > 0 {
+			propertyIds = append(propertyIds, int(propertyId))
+		}
+	}
+	sort.Ints(propertyIds)
+	result := make([]map[string]interface{}, 0)
+	for _, propertyId := range propertyIds {
+		property := chain.omni.properties[uint32(propertyId)]
+		result = append(result, map[string]interface{}{
+			"propertyid": propertyId,
+			"name":       property.name,
+			"balance":    formatOmniAmount(chain.omni.balance(address, property.id), property.divisible),
+			"reserved":   formatOmniAmount(0, property.divisible),
+			"frozen":     formatOmniAmount(0, property.divisible),
+		})
+	}
+	replyJson(context, "OmniGetAllBalancesForAddress", result, nil)
+}
+
+func (server *Server) omniGetBalancesForAddress(context *gin.Context) {
+	address := context.Query("address")
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	property, err := chain.omni.getProperty(getPropertyId(context))
+	if err != nil {
+		replyError(context, err)
+		return
+	}
+	replyJson(context, "OmniGetAllBalancesForAddress", map[string]interface{}{
+		"balance":  formatOmniAmount(chain.omni.balance(address, property.id), property.divisible),
+		"reserved": formatOmniAmount(0, property.divisible),
+		"frozen":   formatOmniAmount(0, property.divisible),
+	}, nil)
+}
+
+func (server *Server) testMemPoolAccept(context *gin.Context) {
+	txid, err := server.chain.TestMempoolAccept(context.Query("hex"))
+	if _, ok := err.(*rejectError); err != nil && ok == false {
+		replyError(context, err)
+		return
+	}
+	item := map[string]interface{}{
+		"txid":    txid,
+		"allowed": err == nil,
+	}
+	if err != nil {
+		item["reject-reason"] = err.Error()
+	}
+	replyJson(context, "TestMemPoolAccept", []interface{}{item}, nil)
+}
+
+func (server *Server) sendRawTransaction(context *gin.Context) {
+	txid, err := server.chain.SendRawTransaction(context.Query("hex"))
+	msg := ""
+	if err != nil {
+		msg = err.Error()
+	}
+	replyData(context, msg, txid)
+}
+
+func (server *Server) omniDecodeTransaction(context *gin.Context) {
+	chain := server.chain
+	tx, err := decodeTx(context.Query("hex"))
+	if err != nil {
+		replyJson(context, "", nil, err)
+		return
+	}
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	record := &txRecord{tx: tx, hash: tx.TxHash()}
+	if known, ok := chain.txs[record.hash]; ok {
+		record = known
+	}
+	omni := chain.omni.decode(chain, record)
+	if omni == nil {
+		replyJson(context, "", nil, errors.New("Not a Master Protocol transaction"))
+		return
+	}
+	replyJson(context, "", chain.omniTxInfo(record, omni), nil)
+}
+
+// the omni transactions sent or received by the address, the newest ones first
+func (server *Server) omniListTransactions(context *gin.Context) {
+	address := context.Query("address")
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	result := make([]map[string]interface{}, 0)
+	txs := chain.sortedTxs()
+	for i := len(txs) - 1; i >= 0 && len(result) < 100; i-- {
+		omni := txs[i].omni
+		if omni != nil && (omni.sender == address || omni.reference == address) {
+			result = append(result, chain.omniTxInfo(txs[i], omni))
+		}
+	}
+	if len(result) == 0 {
+		replyData(context, "no tx", "")
+		return
+	}
+	replyJson(context, "", result, nil)
+}
+
+func (server *Server) omniListProperties(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	propertyIds := make([]int, 0, len(chain.omni.properties))
+	for propertyId := range chain.omni.properties {
+		propertyIds = append(propertyIds, int(propertyId))
+	}
+	sort.Ints(propertyIds)
+	result := make([]map[string]interface{}, 0, len(propertyIds))
+	for _, propertyId := range propertyIds {
+		result = append(result, getPropertyInfo(chain.omni.properties[uint32(propertyId)]))
+	}
+	replyJson(context, "", result, nil)
+}
+
+func (server *Server) issueProperty(context *gin.Context, amount float64) {
+	ecosystem, _ := strconv.Atoi(context.Query("ecosystem"))
+	// 1 for indivisible and 2 for divisible, the same as omni_sendissuancefixed
+	divisibleType, _ := strconv.Atoi(context.Query("divisibleType"))
+	chain := server.chain
+	propertyId, err := chain.IssueProperty(context.Query("fromAddress"), ecosystem, context.Query("name"), divisibleType == 2, amount)
+	if err != nil {
+		replyData(context, err.Error(), "")
+		return
+	}
+	chain.mu.Lock()
+	property := chain.omni.properties[propertyId]
+	property.data = context.Query("data")
+	chain.mu.Unlock()
+	replyData(context, "", property.creationTxid)
+}
+
+func (server *Server) omniSendIssuanceFixed(context *gin.Context) {
+	amount, err := strconv.ParseFloat(context.Query("amount"), 64)
+	if err != nil || amount <= 0 {
+		replyData(context, "wrong amount", "")
+		return
+	}
+	server.issueProperty(context, amount)
+}
+
+func (server *Server) omniSendIssuanceManaged(context *gin.Context) {
+	server.issueProperty(context, 0)
+}
+
+func (server *Server) omniSendGrant(context *gin.Context) {
+	amount, _ := strconv.ParseFloat(context.Query("amount"), 64)
+	propertyId, _ := strconv.ParseUint(context.Query("propertyId"), 10, 32)
+	address := context.Query("fromAddress")
+	if context.Query("toAddress") != "" {
+		address = context.Query("toAddress")
+	}
+	err := server.chain.Grant(uint32(propertyId), address, amount)
+	if err != nil {
+		replyData(context, err.Error(), "")
+		return
+	}
+	txid := chainhash.DoubleHashH([]byte(context.Request.URL.RawQuery))
+	replyData(context, "", txid.String())
+}
+
+func (server *Server) getMiningInfo(context *gin.Context) {
+	chain := server.chain
+	chain.mu.Lock()
+	defer chain.mu.Unlock()
+	replyJson(context, "", map[string]interface{}{
+		"blocks":        chain.height(),
+		"difficulty":    0,
+		"networkhashps": 0,
+		"pooledtx":      len(chain.mempool),
+		"chain":         "regtest",
+		"warnings":      "",
+	}, nil)
+}
+
+func (server *Server) getNetworkInfo(context *gin.Context) {
+	replyJson(context, "", map[string]interface{}{
+		"version":         180100,
+		"subversion":      "/Satoshi:0.18.1/",
+		"protocolversion": 70015,
+		"networkactive":   true,
+		"connections":     0,
+		"warnings":        "",
+	}, nil)
+}
+
+func (server *Server) fund(context *gin.Context) {
+	amount, _ := strconv.ParseFloat(context.Query("amount"), 64)
+	txid, err := server.chain.Fund(context.Query("address"), amount)
+	if err != nil {
+		replyData(context, err.Error(), "")
+		return
+	}
+	replyData(context, "", txid)
+}
+
+func (server *Server) generate(context *gin.Context) {
+	blocks, _ := strconv.Atoi(context.Query("blocks"))
+	if blocks <= 0 {
+		blocks = 1
+	}
+	replyData(context, "", server.chain.Mine(blocks))
+}
@@ -0,0 +1,282 @@
+package tool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/omnilaboratory/obd/config"
+	"golang.org/x/crypto/scrypt"
+)
+
+// the libp2p identity of a node, stored in the data directory
+const NodeKeyFileName = "node_key.json"
+
+const nodeKeyFileVersion = 1
+
+// node_key.json
+type nodeKeyFile struct {
+	Version   int       `json:"version"`
+	Encrypted bool      `json:"encrypted"`
+	Salt      string    `json:"salt,omitempty"`
+	Nonce     string    `json:"nonce,omitempty"`
+	Key       string    `json:"key"`
+	CreateAt  time.Time `json:"create_at"`
+}
+
+var nodeKeyMutex sync.Mutex
+var nodePrivateKey crypto.PrivKey
+
+// LoadOrCreateNodeKey read the node key from dir, a new random key is generated and saved on first start.
+// if passphrase is not empty, the key is encrypted on disk with it.
+func LoadOrCreateNodeKey(dir, passphrase string) (crypto.PrivKey, error) {
+	nodeKeyMutex.Lock()
+	defer nodeKeyMutex.Unlock()
+
+	path := filepath.Join(dir, NodeKeyFileName)
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		prvKey, _, err := crypto.GenerateECDSAKeyPair(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		err = saveNodeKey(dir, passphrase, prvKey)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("generate new node key in", path)
+		nodePrivateKey = prvKey
+		return prvKey, nil
+	}
+
+	prvKey, err := readNodeKey(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	nodePrivateKey = prvKey
+	return prvKey, nil
+}
+
+// ExportNodeKey return the base64 encoded node key, which can be used by ImportNodeKey on another host.
+func ExportNodeKey(dir, passphrase string) (string, error) {
+	prvKey, err := readNodeKey(filepath.Join(dir, NodeKeyFileName), passphrase)
+	if err != nil {
+		return "", err
+	}
+	bytes, err := crypto.MarshalPrivateKey(prvKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(bytes), nil
+}
+
+// ImportNodeKey replace the node key with an exported one, the current key is kept as a backup file.
+func ImportNodeKey(dir, passphrase, exportedKey string) (crypto.PrivKey, error) {
+	bytes, err := base64.StdEncoding.DecodeString(exportedKey)
+	if err != nil {
+		return nil, errors.New("invalid node key")
+	}
+	prvKey, err := crypto.UnmarshalPrivateKey(bytes)
+	if err != nil {
+		return nil, errors.New("invalid node key")
+	}
+
+	nodeKeyMutex.Lock()
+	defer nodeKeyMutex.Unlock()
+	err = backupNodeKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = saveNodeKey(dir, passphrase, prvKey)
+	if err != nil {
+		return nil, err
+	}
+	nodePrivateKey = prvKey
+	return prvKey, nil
+}
+
+// RotateNodeKey generate a new node key, the current key is kept as a backup file.
+func RotateNodeKey(dir, passphrase string) (crypto.PrivKey, error) {
+	prvKey, _, err := crypto.GenerateECDSAKeyPair(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeKeyMutex.Lock()
+	defer nodeKeyMutex.Unlock()
+	err = backupNodeKey(dir)
+	if err != nil {
+		return nil, err
+	}
+	err = saveNodeKey(dir, passphrase, prvKey)
+	if err != nil {
+		return nil, err
+	}
+	nodePrivateKey = prvKey
+	return prvKey, nil
+}
+
+// GetNodeKeyPeerId return the libp2p peer id of the key
+func GetNodeKeyPeerId(prvKey crypto.PrivKey) (string, error) {
+	id, err := peer.IDFromPrivateKey(prvKey)
+	if err != nil {
+		return "", err
+	}
+	return id.Pretty(), nil
+}
+
+func readNodeKey(path, passphrase string) (crypto.PrivKey, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keyFile := &nodeKeyFile{}
+	err = json.Unmarshal(content, keyFile)
+	if err != nil {
+		return nil, errors.New("fail to parse node key file " + path)
+	}
+	bytes, err := base64.StdEncoding.DecodeString(keyFile.Key)
+	if err != nil {
+		return nil, errors.New("fail to parse node key file " + path)
+	}
+
+	if keyFile.Encrypted {
+		if len(passphrase) == 0 {
+			return nil, errors.New("node key is encrypted, please set the passphrase")
+		}
+		bytes, err = decryptNodeKey(bytes, keyFile.Salt, keyFile.Nonce, passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return crypto.UnmarshalPrivateKey(bytes)
+}
+
+func saveNodeKey(dir, passphrase string, prvKey crypto.PrivKey) error {
+	err := PathExistsAndCreate(dir)
+	if err != nil {
+		return err
+	}
+	bytes, err := crypto.MarshalPrivateKey(prvKey)
+	if err != nil {
+		return err
+	}
+
+	keyFile := &nodeKeyFile{Version: nodeKeyFileVersion, CreateAt: time.Now()}
+	if len(passphrase) > 0 {
+		keyFile.Encrypted = true
+		bytes, keyFile.Salt, keyFile.Nonce, err = encryptNodeKey(bytes, passphrase)
+		if err != nil {
+			return err
+		}
+	}
+	keyFile.Key = base64.StdEncoding.EncodeToString(bytes)
+
+	content, err := json.MarshalIndent(keyFile, "", "  ")
+	if err != nil {
+		return err
+	}
+	// write to a temp file first, so that a crash never leaves a half written key
+	path := filepath.Join(dir, NodeKeyFileName)
+	err = ioutil.WriteFile(path+".tmp", content, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func backupNodeKey(dir string) error {
+	path := filepath.Join(dir, NodeKeyFileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	backupPath := path + "." + strconv.FormatInt(time.Now().Unix(), 10) + ".bak"
+	log.Println("backup node key to", backupPath)
+	return os.Rename(path, backupPath)
+}
+
+func nodeKeyCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptNodeKey(plain []byte, passphrase string) (data []byte, salt, nonce string, err error) {
+	saltBytes := make([]byte, 16)
+	if _, err = rand.Read(saltBytes); err != nil {
+		return nil, "", "", err
+	}
+	gcm, err := nodeKeyCipher(passphrase, saltBytes)
+	if err != nil {
+		return nil, "", "", err
+	}
+	nonceBytes := make([]byte, gcm.NonceSize())
+	if _, err = rand.Read(nonceBytes); err != nil {
+		return nil, "", "", err
+	}
+	data = gcm.Seal(nil, nonceBytes, plain, nil)
+	return data, base64.StdEncoding.EncodeToString(saltBytes), base64.StdEncoding.EncodeToString(nonceBytes), nil
+}
+
+func decryptNodeKey(data []byte, salt, nonce, passphrase string) ([]byte, error) {
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil {
+		return nil, errors.New("fail to parse node key salt")
+	}
+	nonceBytes, err := base64.StdEncoding.DecodeString(nonce)
+	if err != nil {
+		return nil, errors.New("fail to parse node key nonce")
+	}
+	gcm, err := nodeKeyCipher(passphrase, saltBytes)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonceBytes) != gcm.NonceSize() {
+		return nil, errors.New("fail to parse node key nonce")
+	}
+	plain, err := gcm.Open(nil, nonceBytes, data, nil)
+	if err != nil {
+		return nil, errors.New("wrong node key passphrase")
+	}
+	return plain, nil
+}
+
+// get obd node id: the libp2p peer id of the node key
+func GetObdNodeId() string {
+	nodeKeyMutex.Lock()
+	prvKey := nodePrivateKey
+	nodeKeyMutex.Unlock()
+
+	if prvKey == nil {
+		var err error
+		prvKey, err = LoadOrCreateNodeKey(config.DataDirectory, config.NodeKeyPassphrase)
+		if err != nil {
+			log.Println(err)
+			return ""
+		}
+	}
+	nodeId, err := GetNodeKeyPeerId(prvKey)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return nodeId
+}
@@ -0,0 +1,77 @@
+package tool
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoadOrCreateNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_node_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := LoadOrCreateNodeKey(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadOrCreateNodeKey(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Equals(again) == false {
+		t.Fatal("node key changed after reload")
+	}
+
+	rotated, err := RotateNodeKey(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Equals(rotated) {
+		t.Fatal("node key not changed after rotate")
+	}
+}
+
+func TestEncryptedNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_node_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := LoadOrCreateNodeKey(dir, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadOrCreateNodeKey(dir, "wrong"); err == nil {
+		t.Fatal("load encrypted node key with wrong passphrase")
+	}
+	if _, err = LoadOrCreateNodeKey(dir, ""); err == nil {
+		t.Fatal("load encrypted node key without passphrase")
+	}
+
+	exported, err := ExportNodeKey(dir, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDir, err := ioutil.TempDir("", "obd_node_key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(otherDir)
+
+	imported, err := ImportNodeKey(otherDir, "", exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Equals(imported) == false {
+		t.Fatal("imported node key is not the exported one")
+	}
+	keyId, _ := GetNodeKeyPeerId(key)
+	importedId, _ := GetNodeKeyPeerId(imported)
+	if keyId != importedId {
+		t.Fatal("imported node key has another peer id")
+	}
+}
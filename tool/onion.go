@@ -0,0 +1,343 @@
+package tool
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/omnilaboratory/obd/config"
+	"github.com/shopspring/decimal"
+)
+
+// the onion of an htlc is a sphinx packet of fixed size: every hop peels a layer by its onion key, and learns only its
+// own instructions and the onion for the next hop, not the other hops nor its position in the path.
+const (
+	onionVersion     byte = 0
+	OnionMaxHops          = 20
+	onionHmacSize         = 32
+	onionHopSize          = 128
+	onionPayloadSize      = onionHopSize - onionHmacSize
+	onionRoutingSize      = OnionMaxHops * onionHopSize
+	onionPacketSize       = 1 + btcec.PubKeyBytesLenCompressed + onionRoutingSize + onionHmacSize
+
+	// the failure of an htlc is padded to a fixed size, so the hops can not learn it from its length
+	onionFailureSize       = 256
+	onionFailurePacketSize = onionHmacSize + 2 + onionFailureSize
+)
+
+// OnionPayload the instructions for a hop: the next channel, and the amount and the cltv expiry of the htlc on it.
+// The payee has no next channel, the amount is the amount to it.
+type OnionPayload struct {
+	NextChannelId string  `json:"next_channel_id,omitempty"`
+	Amount        float64 `json:"amount"`
+	CltvExpiry    int     `json:"cltv_expiry"`
+}
+
+// OnionHop the onion public key of the obd node of a hop, the payload for it, and the associated data of the htlc
+// received by the hop, which its layer is bound to
+type OnionHop struct {
+	PubKey    string
+	Payload   OnionPayload
+	AssocData []byte
+}
+
+// CreateOnion the payer wraps the payloads of the hops from the payee back to the first hop, the layer of every hop is
+// bound to its associated data, so the onion can not be used for another htlc. The shared secrets with the hops are
+// kept by the payer to read the failure of the htlc.
+func CreateOnion(hops []OnionHop) (onion string, secrets []string, err error) {
+	if len(hops) == 0 || len(hops) > OnionMaxHops {
+		return "", nil, errors.New("wrong number of the onion hops")
+	}
+	sessionKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the shared secrets with the hops, the ephemeral key is blinded hop by hop as the hops do
+	curve := btcec.S256()
+	ephemeral := new(big.Int).Set(sessionKey.D)
+	var firstPubKey []byte
+	hopSecrets := make([][]byte, len(hops))
+	for i, hop := range hops {
+		pubKeyBytes, err := hex.DecodeString(hop.PubKey)
+		if err != nil {
+			return "", nil, errors.New("wrong onion public key of the hop")
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, curve)
+		if err != nil {
+			return "", nil, errors.New("wrong onion public key of the hop")
+		}
+		alphaX, alphaY := curve.ScalarBaseMult(ephemeral.Bytes())
+		alpha := (&btcec.PublicKey{Curve: curve, X: alphaX, Y: alphaY}).SerializeCompressed()
+		if i == 0 {
+			firstPubKey = alpha
+		}
+		hopSecrets[i] = onionSharedSecret(pubKey, ephemeral.Bytes())
+		ephemeral.Mul(ephemeral, onionBlindingFactor(alpha, hopSecrets[i]))
+		ephemeral.Mod(ephemeral, curve.N)
+	}
+
+	// the tail of the routing info at the last hop, which is left by the former hops when they peel their layers
+	filler := make([]byte, 0, (len(hops)-1)*onionHopSize)
+	for i := 0; i < len(hops)-1; i++ {
+		filler = append(filler, make([]byte, onionHopSize)...)
+		stream := onionCipherStream(onionKey("rho", hopSecrets[i]), onionRoutingSize+onionHopSize)
+		xorBytes(filler, stream[onionRoutingSize-i*onionHopSize:])
+	}
+
+	routingInfo := make([]byte, onionRoutingSize)
+	if _, err = rand.Read(routingInfo); err != nil {
+		return "", nil, err
+	}
+	nextHmac := make([]byte, onionHmacSize)
+	for i := len(hops) - 1; i >= 0; i-- {
+		frame, err := encodeOnionPayload(hops[i].Payload)
+		if err != nil {
+			return "", nil, err
+		}
+		copy(routingInfo[onionHopSize:], routingInfo[:onionRoutingSize-onionHopSize])
+		copy(routingInfo, frame)
+		copy(routingInfo[onionPayloadSize:], nextHmac)
+		xorBytes(routingInfo, onionCipherStream(onionKey("rho", hopSecrets[i]), onionRoutingSize))
+		if i == len(hops)-1 {
+			copy(routingInfo[onionRoutingSize-len(filler):], filler)
+		}
+		nextHmac = onionHmac(onionKey("mu", hopSecrets[i]), routingInfo, hops[i].AssocData)
+	}
+
+	packet := make([]byte, 0, onionPacketSize)
+	packet = append(packet, onionVersion)
+	packet = append(packet, firstPubKey...)
+	packet = append(packet, routingInfo...)
+	packet = append(packet, nextHmac...)
+	secrets = make([]string, len(hops))
+	for i := range hopSecrets {
+		secrets[i] = hex.EncodeToString(hopSecrets[i])
+	}
+	return hex.EncodeToString(packet), secrets, nil
+}
+
+// PeelOnion the hop peels its layer of the onion by the onion key of the obd node, the next onion is empty at the payee
+func PeelOnion(onion string, assocData []byte) (payload *OnionPayload, nextOnion string, err error) {
+	privKey, err := getOnionPrivateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	return peelOnion(privKey, onion, assocData)
+}
+
+func peelOnion(privKey *btcec.PrivateKey, onion string, assocData []byte) (payload *OnionPayload, nextOnion string, err error) {
+	packet, err := hex.DecodeString(onion)
+	if err != nil || len(packet) != onionPacketSize || packet[0] != onionVersion {
+		return nil, "", errors.New("wrong onion packet")
+	}
+	curve := btcec.S256()
+	alpha := packet[1 : 1+btcec.PubKeyBytesLenCompressed]
+	routingInfo := packet[1+btcec.PubKeyBytesLenCompressed : onionPacketSize-onionHmacSize]
+	packetHmac := packet[onionPacketSize-onionHmacSize:]
+	ephemeralKey, err := btcec.ParsePubKey(alpha, curve)
+	if err != nil {
+		return nil, "", errors.New("wrong onion packet")
+	}
+
+	secret := onionSharedSecret(ephemeralKey, privKey.D.Bytes())
+	if hmac.Equal(packetHmac, onionHmac(onionKey("mu", secret), routingInfo, assocData)) == false {
+		return nil, "", errors.New("the onion is not for this htlc or this node")
+	}
+
+	padded := append(append(make([]byte, 0, onionRoutingSize+onionHopSize), routingInfo...), make([]byte, onionHopSize)...)
+	xorBytes(padded, onionCipherStream(onionKey("rho", secret), onionRoutingSize+onionHopSize))
+	payload, err = decodeOnionPayload(padded[:onionPayloadSize])
+	if err != nil {
+		return nil, "", err
+	}
+	nextHmac := padded[onionPayloadSize:onionHopSize]
+	if bytes.Equal(nextHmac, make([]byte, onionHmacSize)) {
+		return payload, "", nil
+	}
+
+	nextX, nextY := curve.ScalarMult(ephemeralKey.X, ephemeralKey.Y, onionBlindingFactor(alpha, secret).Bytes())
+	next := make([]byte, 0, onionPacketSize)
+	next = append(next, onionVersion)
+	next = append(next, (&btcec.PublicKey{Curve: curve, X: nextX, Y: nextY}).SerializeCompressed()...)
+	next = append(next, padded[onionHopSize:]...)
+	next = append(next, nextHmac...)
+	return payload, hex.EncodeToString(next), nil
+}
+
+// OnionSharedSecret the shared secret of the hop with the payer of the onion, by which the hop encrypts the failure of
+// the htlc to the payer
+func OnionSharedSecret(onion string) (string, error) {
+	privKey, err := getOnionPrivateKey()
+	if err != nil {
+		return "", err
+	}
+	return onionPacketSecret(privKey, onion)
+}
+
+func onionPacketSecret(privKey *btcec.PrivateKey, onion string) (string, error) {
+	packet, err := hex.DecodeString(onion)
+	if err != nil || len(packet) != onionPacketSize || packet[0] != onionVersion {
+		return "", errors.New("wrong onion packet")
+	}
+	ephemeralKey, err := btcec.ParsePubKey(packet[1:1+btcec.PubKeyBytesLenCompressed], btcec.S256())
+	if err != nil {
+		return "", errors.New("wrong onion packet")
+	}
+	return hex.EncodeToString(onionSharedSecret(ephemeralKey, privKey.D.Bytes())), nil
+}
+
+// CreateOnionFailure the failing hop authenticates the failure by its shared secret with the payer, and encrypts it
+func CreateOnionFailure(secret string, failure []byte) (string, error) {
+	if len(failure) > onionFailureSize {
+		return "", errors.New("the failure of the htlc is too long")
+	}
+	key, err := hex.DecodeString(secret)
+	if err != nil {
+		return "", errors.New("wrong onion secret")
+	}
+	packet := make([]byte, onionFailurePacketSize)
+	binary.BigEndian.PutUint16(packet[onionHmacSize:], uint16(len(failure)))
+	copy(packet[onionHmacSize+2:], failure)
+	copy(packet, onionHmac(onionKey("um", key), packet[onionHmacSize:], nil))
+	return WrapOnionFailure(secret, hex.EncodeToString(packet))
+}
+
+// WrapOnionFailure every hop on the way back to the payer encrypts the failure again by its shared secret
+func WrapOnionFailure(secret string, failure string) (string, error) {
+	key, err := hex.DecodeString(secret)
+	if err != nil {
+		return "", errors.New("wrong onion secret")
+	}
+	packet, err := hex.DecodeString(failure)
+	if err != nil || len(packet) != onionFailurePacketSize {
+		return "", errors.New("wrong onion failure")
+	}
+	xorBytes(packet, onionCipherStream(onionKey("ammag", key), onionFailurePacketSize))
+	return hex.EncodeToString(packet), nil
+}
+
+// ParseOnionFailure the payer decrypts the failure by the secrets of the hops in the order of the path, the index is
+// the hop whose hmac matches, which is the failing hop
+func ParseOnionFailure(secrets []string, failure string) (index int, message []byte, err error) {
+	packet, err := hex.DecodeString(failure)
+	if err != nil || len(packet) != onionFailurePacketSize {
+		return -1, nil, errors.New("wrong onion failure")
+	}
+	for i, secret := range secrets {
+		key, err := hex.DecodeString(secret)
+		if err != nil {
+			return -1, nil, errors.New("wrong onion secret")
+		}
+		xorBytes(packet, onionCipherStream(onionKey("ammag", key), onionFailurePacketSize))
+		if hmac.Equal(packet[:onionHmacSize], onionHmac(onionKey("um", key), packet[onionHmacSize:], nil)) {
+			length := int(binary.BigEndian.Uint16(packet[onionHmacSize:]))
+			if length > onionFailureSize {
+				return -1, nil, errors.New("wrong onion failure")
+			}
+			return i, packet[onionHmacSize+2 : onionHmacSize+2+length], nil
+		}
+	}
+	return -1, nil, errors.New("the failure is not from the hops of the onion")
+}
+
+// GetOnionPubKey the onion public key of the obd node, announced to the tracker with the node login
+func GetOnionPubKey() string {
+	privKey, err := getOnionPrivateKey()
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(privKey.PubKey().SerializeCompressed())
+}
+
+// the onion key is derived from the node key, so it is rotated with the node key
+func getOnionPrivateKey() (*btcec.PrivateKey, error) {
+	nodeKeyMutex.Lock()
+	prvKey := nodePrivateKey
+	nodeKeyMutex.Unlock()
+	if prvKey == nil {
+		var err error
+		prvKey, err = LoadOrCreateNodeKey(config.DataDirectory, config.NodeKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	raw, err := prvKey.Raw()
+	if err != nil {
+		return nil, err
+	}
+	seed := sha256.Sum256(append([]byte("obd onion key"), raw...))
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), seed[:])
+	return privKey, nil
+}
+
+// the length of the next channel id, the next channel id, the amount in satoshi and the cltv expiry
+func encodeOnionPayload(payload OnionPayload) ([]byte, error) {
+	if len(payload.NextChannelId) > onionPayloadSize-13 {
+		return nil, errors.New("wrong next channel id of the onion payload")
+	}
+	frame := make([]byte, onionPayloadSize)
+	frame[0] = byte(len(payload.NextChannelId))
+	copy(frame[1:], payload.NextChannelId)
+	offset := 1 + len(payload.NextChannelId)
+	binary.BigEndian.PutUint64(frame[offset:], uint64(decimal.NewFromFloat(payload.Amount).Shift(8).Round(0).IntPart()))
+	binary.BigEndian.PutUint32(frame[offset+8:], uint32(payload.CltvExpiry))
+	return frame, nil
+}
+
+func decodeOnionPayload(frame []byte) (*OnionPayload, error) {
+	length := int(frame[0])
+	if length > onionPayloadSize-13 {
+		return nil, errors.New("wrong onion payload")
+	}
+	payload := &OnionPayload{NextChannelId: string(frame[1 : 1+length])}
+	offset := 1 + length
+	payload.Amount, _ = decimal.New(int64(binary.BigEndian.Uint64(frame[offset:])), -8).Float64()
+	payload.CltvExpiry = int(binary.BigEndian.Uint32(frame[offset+8:]))
+	return payload, nil
+}
+
+func onionSharedSecret(pubKey *btcec.PublicKey, privKey []byte) []byte {
+	x, y := btcec.S256().ScalarMult(pubKey.X, pubKey.Y, privKey)
+	secret := sha256.Sum256((&btcec.PublicKey{Curve: btcec.S256(), X: x, Y: y}).SerializeCompressed())
+	return secret[:]
+}
+
+func onionBlindingFactor(ephemeralPubKey, secret []byte) *big.Int {
+	factor := sha256.Sum256(append(append(make([]byte, 0, len(ephemeralPubKey)+len(secret)), ephemeralPubKey...), secret...))
+	return new(big.Int).SetBytes(factor[:])
+}
+
+func onionKey(keyType string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(keyType))
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+func onionHmac(key, routingInfo, assocData []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(routingInfo)
+	mac.Write(assocData)
+	return mac.Sum(nil)
+}
+
+func onionCipherStream(key []byte, length int) []byte {
+	block, _ := aes.NewCipher(key)
+	stream := make([]byte, length)
+	cipher.NewCTR(block, make([]byte, aes.BlockSize)).XORKeyStream(stream, stream)
+	return stream
+}
+
+func xorBytes(dst, src []byte) {
+	for i := range dst {
+		dst[i] ^= src[i]
+	}
+}
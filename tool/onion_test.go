@@ -0,0 +1,95 @@
+package tool
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+)
+
+func TestOnion(t *testing.T) {
+	payloads := []OnionPayload{
+		{NextChannelId: "c2", Amount: 1.0002, CltvExpiry: 9},
+		{NextChannelId: "c3", Amount: 1.0001, CltvExpiry: 8},
+		{Amount: 1, CltvExpiry: 7},
+	}
+	assocData := [][]byte{[]byte("h_c1"), []byte("h_c2"), []byte("h_c3")}
+	keys := make([]*btcec.PrivateKey, len(payloads))
+	hops := make([]OnionHop, len(payloads))
+	for i := range payloads {
+		keys[i], _ = btcec.NewPrivateKey(btcec.S256())
+		hops[i] = OnionHop{PubKey: hex.EncodeToString(keys[i].PubKey().SerializeCompressed()), Payload: payloads[i], AssocData: assocData[i]}
+	}
+	onion, secrets, err := CreateOnion(hops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hopSecrets := make([]string, len(keys))
+	for i, key := range keys {
+		hopSecrets[i], _ = onionPacketSecret(key, onion)
+		if hopSecrets[i] != secrets[i] {
+			t.Fatalf("hop %d got another shared secret than the payer", i)
+		}
+		if len(onion) != onionPacketSize*2 {
+			t.Fatalf("hop %d got an onion of %d bytes, want the fixed size", i, len(onion)/2)
+		}
+		if _, _, err := peelOnion(key, onion, []byte("another h")); err == nil {
+			t.Fatalf("hop %d peeled the onion of another htlc", i)
+		}
+		if i+1 < len(keys) {
+			if _, _, err := peelOnion(keys[i+1], onion, assocData[i]); err == nil {
+				t.Fatalf("hop %d peeled the layer of hop %d", i+1, i)
+			}
+			if _, _, err := peelOnion(key, onion, assocData[i+1]); err == nil {
+				t.Fatalf("hop %d peeled its layer with the data of hop %d", i, i+1)
+			}
+		}
+		payload, next, err := peelOnion(key, onion, assocData[i])
+		if err != nil {
+			t.Fatalf("hop %d: %v", i, err)
+		}
+		if *payload != payloads[i] {
+			t.Fatalf("hop %d got %+v, want %+v", i, *payload, payloads[i])
+		}
+		if (next == "") != (i == len(keys)-1) {
+			t.Fatalf("hop %d got the next onion %t, want it only before the payee", i, next != "")
+		}
+		onion = next
+	}
+}
+
+func TestOnionFailure(t *testing.T) {
+	keys := make([]*btcec.PrivateKey, 3)
+	hops := make([]OnionHop, len(keys))
+	for i := range keys {
+		keys[i], _ = btcec.NewPrivateKey(btcec.S256())
+		hops[i] = OnionHop{PubKey: hex.EncodeToString(keys[i].PubKey().SerializeCompressed()), Payload: OnionPayload{Amount: 1}, AssocData: []byte("h")}
+	}
+	_, secrets, err := CreateOnion(hops)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second hop fails, the first hop wraps the failure on the way back
+	failure, err := CreateOnionFailure(secrets[1], []byte("no balance"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failure) != onionFailurePacketSize*2 {
+		t.Fatalf("got a failure of %d bytes, want the fixed size", len(failure)/2)
+	}
+	if failure, err = WrapOnionFailure(secrets[0], failure); err != nil {
+		t.Fatal(err)
+	}
+	index, message, err := ParseOnionFailure(secrets, failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != 1 || string(message) != "no balance" {
+		t.Fatalf("got the failure %q of hop %d, want the failure of hop 1", message, index)
+	}
+	if _, _, err = ParseOnionFailure(secrets[2:], failure); err == nil {
+		t.Fatal("the failure is read without the secrets of the hops before the failing hop")
+	}
+}
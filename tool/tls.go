@@ -0,0 +1,118 @@
+package tool
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// the self-signed certificate generated in the data directory, when no certificate is configured
+const (
+	TLSCertFileName = "tls.cert"
+	TLSKeyFileName  = "tls.key"
+)
+
+const tlsCertValidity = 14 * 30 * 24 * time.Hour
+
+// LoadOrCreateTLSCert return the paths of the certificate and key.
+// if certPath and keyPath are empty, a self-signed certificate for hosts is generated in dir,
+// and generated again after it expires.
+func LoadOrCreateTLSCert(dir, certPath, keyPath string, hosts []string) (string, string, error) {
+	if len(certPath) > 0 || len(keyPath) > 0 {
+		if len(certPath) == 0 || len(keyPath) == 0 {
+			return "", "", errors.New("both tls_cert and tls_key must be set")
+		}
+		_, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return "", "", err
+		}
+		return certPath, keyPath, nil
+	}
+
+	certPath = filepath.Join(dir, TLSCertFileName)
+	keyPath = filepath.Join(dir, TLSKeyFileName)
+	if keyPair, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil {
+		cert, err := x509.ParseCertificate(keyPair.Certificate[0])
+		if err == nil && time.Now().Before(cert.NotAfter) {
+			return certPath, keyPath, nil
+		}
+		log.Println("tls certificate " + certPath + " is expired, generate a new one")
+	} else if os.IsNotExist(err) == false {
+		_, certErr := os.Stat(certPath)
+		_, keyErr := os.Stat(keyPath)
+		if certErr == nil || keyErr == nil {
+			return "", "", err
+		}
+	}
+
+	err := generateTLSCert(certPath, keyPath, hosts)
+	if err != nil {
+		return "", "", err
+	}
+	log.Println("generate self-signed tls certificate in", certPath)
+	return certPath, keyPath, nil
+}
+
+func generateTLSCert(certPath, keyPath string, hosts []string) error {
+	err := PathExistsAndCreate(filepath.Dir(certPath))
+	if err != nil {
+		return err
+	}
+	prvKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	notBefore := time.Now().Add(-time.Hour)
+	template := x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"obd autogenerated cert"}, CommonName: "obd"},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(tlsCertValidity),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	hosts = append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+	for _, host := range hosts {
+		if len(host) == 0 {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &prvKey.PublicKey, prvKey)
+	if err != nil {
+		return err
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(prvKey)
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0644)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+}
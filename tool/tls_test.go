@@ -0,0 +1,62 @@
+package tool
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestLoadOrCreateTLSCert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obd_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPath, keyPath, err := LoadOrCreateTLSCert(dir, "", "", []string{"62.234.216.108"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the generated certificate is reused
+	_, _, err = LoadOrCreateTLSCert(dir, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := tls.LoadX509KeyPair(certPath, keyPath)
+	if string(again.Certificate[0]) != string(keyPair.Certificate[0]) {
+		t.Fatal("tls certificate is generated again")
+	}
+
+	content, _ := ioutil.ReadFile(certPath)
+	certPool := x509.NewCertPool()
+	certPool.AppendCertsFromPEM(content)
+	clientConfig := &tls.Config{RootCAs: certPool, ServerName: "62.234.216.108"}
+	server, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{keyPair}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go func() {
+		conn, err := server.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+			_ = conn.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", server.Addr().String(), clientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	if _, _, err = LoadOrCreateTLSCert(dir, certPath, "", nil); err == nil {
+		t.Fatal("load tls certificate without key")
+	}
+}
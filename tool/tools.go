@@ -134,15 +134,21 @@ func GetMacAddrs() (macAddrs string) {
 	return macAddrs
 }
 
-func GetUserPeerId(mnemonic string) string {
-	source := mnemonic + "@" + GetMacAddrs() + ":" + strconv.Itoa(config.ServerPort) + "in" + config.ChainNodeType
+// user peer id: sha256 of the pubkey of the wallet key m/44'/coinType', it does not depend on the host
+func GetUserPeerId(walletPubKey []byte) string {
+	return SignMsgWithSha256(walletPubKey)
+}
+
+// the user peer id of old versions, only used to migrate the user dbs created by them
+func GetLegacyUserPeerId(mnemonic, macAddress string, serverPort int, chainNodeType string) string {
+	source := mnemonic + "@" + macAddress + ":" + strconv.Itoa(serverPort) + "in" + chainNodeType
 	return SignMsgWithSha256([]byte(source))
 }
 
-// get obd node id
-func GetObdNodeId() string {
-	source := "obd:" + GetMacAddrs() + ":" + strconv.Itoa(config.ServerPort)
-	return SignMsgWithSha256([]byte(source)) + config.ChainNodeType
+// the obd node id of old versions, the trackers keep the users of the obd under it
+func GetLegacyObdNodeId(macAddress string, serverPort int, chainNodeType string) string {
+	source := "obd:" + macAddress + ":" + strconv.Itoa(serverPort)
+	return SignMsgWithSha256([]byte(source)) + chainNodeType
 }
 
 func GetCoreNet() *chaincfg.Params {
@@ -26,6 +26,8 @@ func CheckExist(msgType enum.MsgType) bool {
 		return true
 	case enum.MsgType_Tracker_UpdateUserInfo_353:
 		return true
+	case enum.MsgType_Tracker_UpdateUserPeerId_354:
+		return true
 	}
 	return false
 }
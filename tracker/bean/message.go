@@ -26,6 +26,11 @@ type HtlcPathRequest struct {
 	PropertyId      int64   `json:"property_id"`
 	H               string  `json:"h"`
 	Amount          float64 `json:"amount"`
+	// the amount is split over at most MaxParts routes when no route carries it
+	MaxParts int `json:"max_parts,omitempty"`
+	// the channels and the peers failed the payment before, the retry of the payer avoids them
+	ExcludedChannels []string `json:"excluded_channels,omitempty"`
+	ExcludedPeers    []string `json:"excluded_peers,omitempty"`
 }
 
 const (
@@ -51,6 +56,8 @@ type UpdateHtlcTxStateRequest struct {
 	//0 0:forward h 1:backword
 	DirectionFlag int    `json:"direction_flag"`
 	CurrChannelId string `json:"curr_channel_id"`
+	// the channel which failed the htlc, reported by the payer when the failure is back
+	FailedChannelId string `json:"failed_channel_id,omitempty"`
 }
 
 //GetChannelStateRequest
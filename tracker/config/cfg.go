@@ -32,11 +32,20 @@ var (
 	TrackerServerPort = 60060
 
 	HtlcFeeRate = 0.0001
+	// the cltv delta of the node which does not announce it
+	HtlcTimeLockDelta = 1
+	// the ranked routes returned by the path finding, and the max channels of a route
+	HtlcRouteCandidates = 3
+	HtlcMaxHops         = 6
 
 	P2P_hostIp      = "127.0.0.1"
 	P2P_sourcePort  = 60801
 	BootstrapPeers  addrList
 	P2pLocalAddress = ""
+	// encrypt the node key on disk when it is not empty
+	NodeKeyPassphrase = ""
+
+	DataDirectory = "dbdata"
 
 	ChainNode_Type = "test"
 	ChainNode_Host = "62.234.216.108:18332"
@@ -73,6 +82,9 @@ func init() {
 		return
 	}
 	HtlcFeeRate = htlcNode.Key("feeRate").MustFloat64(0.0001)
+	HtlcTimeLockDelta = htlcNode.Key("timeLockDelta").MustInt(1)
+	HtlcRouteCandidates = htlcNode.Key("routeCandidates").MustInt(3)
+	HtlcMaxHops = htlcNode.Key("maxHops").MustInt(6)
 
 	p2pNode, err := Cfg.GetSection("p2p")
 	if err != nil {
@@ -84,6 +96,7 @@ func init() {
 	P2P_sourcePort = p2pNode.Key("sourcePort").MustInt(60801)
 	bootstrapPeers := p2pNode.Key("bootstrapPeers").MustString("")
 	BootstrapPeers, _ = StringsToAddrs(strings.Split(bootstrapPeers, ","))
+	NodeKeyPassphrase = p2pNode.Key("nodeKeyPassphrase").String()
 
 	chainNode, err := Cfg.GetSection("chainNode")
 	if err != nil {
@@ -4,6 +4,7 @@ import (
 	"github.com/asdine/storm"
 	"github.com/omnilaboratory/obd/config"
 	"github.com/omnilaboratory/obd/tool"
+	cfg "github.com/omnilaboratory/obd/tracker/config"
 	"log"
 )
 
@@ -15,7 +16,7 @@ var DBService dbManager
 
 func (manager dbManager) GetTrackerDB(chainType string) (*storm.DB, error) {
 	if DBService.Db == nil {
-		_dir := "dbdata" + "/" + chainType
+		_dir := cfg.DataDirectory + "/" + chainType
 		_ = tool.PathExistsAndCreate(_dir)
 		db, e := storm.Open(_dir + "/" + config.TrackerDbName)
 		if e != nil {
@@ -33,18 +33,26 @@ type UserInfo struct {
 	cbean.ObdNodeUserLoginRequest
 }
 type ChannelInfo struct {
-	Id           int                `storm:"id,increment" json:"id"`
-	ObdNodeIdA   string             `json:"obd_node_ida"`
-	ObdNodeIdB   string             `json:"obd_node_idb"`
-	ChannelId    string             `json:"channel_id"`
-	PropertyId   int64              `json:"property_id"`
-	CurrState    cbean.ChannelState `json:"curr_state"`
-	PeerIdA      string             `json:"peer_ida"`
-	PeerIdB      string             `json:"peer_idb"`
-	AmountA      float64            `json:"amount_a"`
-	AmountB      float64            `json:"amount_b"`
-	LatestEditAt time.Time          `json:"latest_edit_at"`
-	CreateAt     time.Time          `json:"create_at"`
+	Id         int                `storm:"id,increment" json:"id"`
+	ObdNodeIdA string             `json:"obd_node_ida"`
+	ObdNodeIdB string             `json:"obd_node_idb"`
+	ChannelId  string             `json:"channel_id"`
+	PropertyId int64              `json:"property_id"`
+	CurrState  cbean.ChannelState `json:"curr_state"`
+	PeerIdA    string             `json:"peer_ida"`
+	PeerIdB    string             `json:"peer_idb"`
+	AmountA    float64            `json:"amount_a"`
+	AmountB    float64            `json:"amount_b"`
+	// the fee rates and the cltv deltas announced by the obd of each side, the defaults of the tracker if they are 0
+	FeeRateA       float64 `json:"fee_rate_a"`
+	FeeRateB       float64 `json:"fee_rate_b"`
+	TimeLockDeltaA int     `json:"time_lock_delta_a"`
+	TimeLockDeltaB int     `json:"time_lock_delta_b"`
+	// the payments through the channel which are finished, and the ones failed by a hop of it or failed to lock it
+	SuccessCount int       `json:"success_count"`
+	FailureCount int       `json:"failure_count"`
+	LatestEditAt time.Time `json:"latest_edit_at"`
+	CreateAt     time.Time `json:"create_at"`
 }
 
 type HtlcTxInfo struct {
@@ -2,9 +2,22 @@ package rpc
 
 import (
 	"log"
+	"os"
 	"testing"
+
+	cfg "github.com/omnilaboratory/obd/tracker/config"
 )
 
+// the tests use the omnicore node of the tracker config, as the tracker does
+func TestMain(m *testing.M) {
+	SetConnConfig(ConnConfig{
+		Host: cfg.ChainNode_Host,
+		User: cfg.ChainNode_User,
+		Pass: cfg.ChainNode_Pass,
+	})
+	os.Exit(m.Run())
+}
+
 func TestClient_OmniListTransactions(t *testing.T) {
 	client := NewClient()
 
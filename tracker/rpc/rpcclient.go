@@ -2,10 +2,10 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/omnilaboratory/obd/tracker/config"
 	"github.com/tidwall/gjson"
 	"io/ioutil"
 	"log"
@@ -15,14 +15,14 @@ import (
 	"sync/atomic"
 )
 
-var connConfig *ConnConfig
+// the omnicore node of NewClient, it is set by SetConnConfig, such as the chainNode of the tracker config.
+// the package does not read the tracker config itself, because obd uses it with its own config.
+var connConfig = &ConnConfig{}
 
-func init() {
-	connConfig = &ConnConfig{
-		Host: cfg.ChainNode_Host,
-		User: cfg.ChainNode_User,
-		Pass: cfg.ChainNode_Pass,
-	}
+// SetConnConfig set the omnicore node of NewClient, the shared client is created again by the next NewClient
+func SetConnConfig(config ConnConfig) {
+	connConfig = &config
+	client = nil
 }
 
 type ConnConfig struct {
@@ -40,6 +40,8 @@ type Client struct {
 	config *ConnConfig
 	// httpClient is the underlying HTTP client to use when running in HTTP POST mode.
 	httpClient http.Client
+	// ctx cancels the requests of the client, it is set by WithContext.
+	ctx context.Context
 }
 
 type Request struct {
@@ -72,38 +74,52 @@ type RPCError struct {
 
 var client *Client
 
+// NewClient the shared client of the omnicore node set by SetConnConfig
 func NewClient() *Client {
 	if client == nil {
-		httpClient := http.Client{
-			Transport: &http.Transport{
-				Proxy:           nil,
-				TLSClientConfig: nil,
-			},
-		}
-		connConfig.Host = "http://" + connConfig.Host
-		client = &Client{
-			config:     connConfig,
-			httpClient: httpClient,
-		}
+		client = NewClientWithConfig(*connConfig)
 	}
 	return client
 }
 
+// NewClientWithConfig a client of the omnicore node, which does not share the node of NewClient
+func NewClientWithConfig(config ConnConfig) *Client {
+	httpClient := http.Client{
+		Transport: &http.Transport{
+			Proxy:           nil,
+			TLSClientConfig: nil,
+		},
+	}
+	if strings.HasPrefix(config.Host, "http://") == false && strings.HasPrefix(config.Host, "https://") == false {
+		config.Host = "http://" + config.Host
+	}
+	return &Client{
+		config:     &config,
+		httpClient: httpClient,
+	}
+}
+
+// WithContext a copy of the client, whose requests are canceled by the ctx, such as by its deadline
+func (client *Client) WithContext(ctx context.Context) *Client {
+	return &Client{id: client.NextID(), config: client.config, httpClient: client.httpClient, ctx: ctx}
+}
+
 func (client *Client) NextID() uint64 {
 	return atomic.AddUint64(&client.id, 1)
 }
 
-func (client *Client) CheckVersion() error {
+// CheckVersion check the version of the omnicore node, and return its chain: main, test or regtest
+func (client *Client) CheckVersion() (chain string, err error) {
 
 	result, err := client.GetBlockChainInfo()
 	if err != nil {
-		return err
+		return "", err
 	}
-	cfg.ChainNode_Type = gjson.Get(result, "chain").Str
+	chain = gjson.Get(result, "chain").Str
 
 	result, err = client.OmniGetInfo()
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	omniCoreVersion := gjson.Get(result, "omnicoreversion").String()
@@ -113,14 +129,14 @@ func (client *Client) CheckVersion() error {
 	infoes := strings.Split(btcCoreVersion, ".")
 	tempInt, _ := strconv.Atoi(infoes[0])
 	if tempInt >= 0 {
-		return nil
+		return chain, nil
 	}
 	tempInt, _ = strconv.Atoi(infoes[1])
 	if tempInt >= 18 {
-		return nil
+		return chain, nil
 	}
 
-	return errors.New("error bitcoinCore version " + btcCoreVersion)
+	return "", errors.New("error bitcoinCore version " + btcCoreVersion)
 }
 
 func (client *Client) send(method string, params []interface{}) (result string, err error) {
@@ -147,7 +163,14 @@ func (client *Client) send(method string, params []interface{}) (result string,
 
 	bodyReader := bytes.NewReader(marshaledJSON)
 
-	httpReq, err := http.NewRequest("POST", client.config.Host, bodyReader)
+	ctx := client.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", client.config.Host, bodyReader)
+	if err != nil {
+		return "", err
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.SetBasicAuth(client.config.User, client.config.Pass)
 	httpResponse, err := client.httpClient.Do(httpReq)
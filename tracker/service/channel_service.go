@@ -54,8 +54,12 @@ func (manager *channelManager) updateChannelInfo(obdP2pNodeId string, msgData st
 			channelInfo.AmountB = item.AmountB
 			if item.IsAlice {
 				channelInfo.ObdNodeIdA = obdP2pNodeId
+				channelInfo.FeeRateA = item.FeeRate
+				channelInfo.TimeLockDeltaA = item.TimeLockDelta
 			} else {
 				channelInfo.ObdNodeIdB = obdP2pNodeId
+				channelInfo.FeeRateB = item.FeeRate
+				channelInfo.TimeLockDeltaB = item.TimeLockDelta
 			}
 			channelInfo.CreateAt = time.Now()
 			channelInfo.LatestEditAt = time.Now()
@@ -72,8 +76,12 @@ func (manager *channelManager) updateChannelInfo(obdP2pNodeId string, msgData st
 
 			if item.IsAlice {
 				channelInfo.ObdNodeIdA = obdP2pNodeId
+				channelInfo.FeeRateA = item.FeeRate
+				channelInfo.TimeLockDeltaA = item.TimeLockDelta
 			} else {
 				channelInfo.ObdNodeIdB = obdP2pNodeId
+				channelInfo.FeeRateB = item.FeeRate
+				channelInfo.TimeLockDeltaB = item.TimeLockDelta
 			}
 			channelInfo.LatestEditAt = time.Now()
 
@@ -2,8 +2,6 @@ package service
 
 import (
 	"encoding/json"
-	"errors"
-	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/omnilaboratory/obd/bean/enum"
 	"github.com/omnilaboratory/obd/tool"
@@ -11,20 +9,24 @@ import (
 	"github.com/omnilaboratory/obd/tracker/config"
 	"github.com/tidwall/gjson"
 	"log"
-	"strconv"
 	"strings"
 )
 
 var tracker *ObdNode
 
-func init() {
-	tracker = &ObdNode{Id: GetTrackerNodeId()}
-}
-
-// get tracker node id
+// get tracker node id: the libp2p peer id of the tracker node key
 func GetTrackerNodeId() string {
-	source := "tracker:" + tool.GetMacAddrs() + ":" + strconv.Itoa(cfg.TrackerServerPort)
-	return tool.SignMsgWithSha256([]byte(source)) + cfg.ChainNode_Type
+	prvKey, err := tool.LoadOrCreateNodeKey(cfg.DataDirectory, cfg.NodeKeyPassphrase)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	nodeId, err := tool.GetNodeKeyPeerId(prvKey)
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return nodeId
 }
 
 type ObdNode struct {
@@ -47,7 +49,7 @@ func (this *ObdNode) findSomeNode(nodeId *string) (*ObdNode, error) {
 			return itemClient, nil
 		}
 	}
-	return nil, errors.New(fmt.Sprintf(enum.Tips_user_notExistOrOnline, nodeId))
+	return nil, enum.NewError(enum.ErrorCode_user_notExistOrOnline, *nodeId)
 }
 
 func (this *ObdNode) Read() {
@@ -95,6 +97,9 @@ func (this *ObdNode) Read() {
 			_ = HtlcService.updateHtlcInfo(this, msgData)
 		case enum.MsgType_Tracker_UpdateUserInfo_353:
 			_ = NodeAccountService.updateUsers(this, msgData)
+		case enum.MsgType_Tracker_UpdateUserPeerId_354:
+			migrations, err := NodeAccountService.updateUserPeerIds(this, msgData)
+			sendDataBackToSender(this, msgType, migrations, err)
 		}
 	}
 }
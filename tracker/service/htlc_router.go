@@ -0,0 +1,360 @@
+package service
+
+import (
+	"container/heap"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// routeEdge a direction of a channel, the payment goes from From to To by the balance of From
+type routeEdge struct {
+	ChannelId string
+	From      string
+	To        string
+	Balance   float64
+	// charged and required by From when it forwards the payment
+	FeeRate       float64
+	TimeLockDelta int
+	// the laplace estimate of the payments through the channel
+	SuccessRate float64
+}
+
+// routeWeights the cost of a route is the fees plus the risks in the unit of the amount
+type routeWeights struct {
+	// per hop, the shorter route wins when the fees are the same
+	Hop float64
+	// per cltv delta, the locked amount is the risk of the long timelock
+	TimeLock float64
+	// per the share of the balance taken by the payment, the channel near its limit fails more often
+	Liquidity float64
+	// per the failure rate of the channel
+	Failure float64
+}
+
+var defaultRouteWeights = routeWeights{
+	Hop:       0.00001,
+	TimeLock:  0.00001,
+	Liquidity: 0.001,
+	Failure:   0.01,
+}
+
+// Route a candidate route of the payment, the channels are in the order from the payer to the payee
+type Route struct {
+	Path string `json:"path"`
+	// sent by the payer, the amount to the payee and the fees
+	Amount      float64 `json:"amount"`
+	Fee         float64 `json:"fee"`
+	Cltv        int     `json:"cltv"`
+	Probability float64 `json:"probability"`
+	Cost        float64 `json:"cost"`
+	// the share of the payee when the route is a part of a multi-path payment
+	AmountToPayee float64 `json:"amount_to_payee,omitempty"`
+
+	channelIds []string
+	// the receiving peer of every channel, they peel the layers of the onion
+	peerIds []string
+	// the amount of the htlc on every channel, by the fee rates of the hops
+	amounts []float64
+	// the id of the dao.LockHtlcPath when the channels are locked for the payment
+	lockPathId int
+}
+
+// channelGraph the snapshot of the usable channels of a property. The search goes from the payee back to the payer,
+// so the edges are indexed by the receiving peer and the fees are added to the amount hop by hop.
+type channelGraph struct {
+	edgesTo map[string][]*routeEdge
+	weights routeWeights
+}
+
+func newChannelGraph(edges []*routeEdge) *channelGraph {
+	graph := &channelGraph{edgesTo: make(map[string][]*routeEdge), weights: defaultRouteWeights}
+	for _, edge := range edges {
+		graph.edgesTo[edge.To] = append(graph.edgesTo[edge.To], edge)
+	}
+	return graph
+}
+
+// exclude the channels and the peers, the payer and the payee are kept
+func (graph *channelGraph) exclude(channelIds, peerIds []string, payer, payee string) *channelGraph {
+	if len(channelIds) == 0 && len(peerIds) == 0 {
+		return graph
+	}
+	excludedChannels := make(map[string]bool)
+	for _, channelId := range channelIds {
+		excludedChannels[channelId] = true
+	}
+	excludedPeers := make(map[string]bool)
+	for _, peerId := range peerIds {
+		excludedPeers[peerId] = peerId != payer && peerId != payee
+	}
+	edges := make([]*routeEdge, 0)
+	for _, items := range graph.edgesTo {
+		for _, edge := range items {
+			if excludedChannels[edge.ChannelId] || excludedPeers[edge.From] || excludedPeers[edge.To] {
+				continue
+			}
+			edges = append(edges, edge)
+		}
+	}
+	excluded := newChannelGraph(edges)
+	excluded.weights = graph.weights
+	return excluded
+}
+
+// routeLabel the state of the search at a peer: the amount it receives, and the cost of the route to the payee
+type routeLabel struct {
+	peerId      string
+	received    float64
+	cost        float64
+	cltv        int
+	probability float64
+	hops        int
+	// the edges from the peer to the payee
+	edges []*routeEdge
+	// the amounts of the htlcs on the edges, the fees of the hops after them are included
+	amounts []float64
+}
+
+// extend the route from the receiving peer back to the sender of the edge, nil if the balance is not enough
+func (graph *channelGraph) extend(label *routeLabel, edge *routeEdge, payer string, amount float64) *routeLabel {
+	if edge.Balance < label.received {
+		return nil
+	}
+	next := &routeLabel{
+		peerId:      edge.From,
+		received:    label.received,
+		cltv:        label.cltv + edge.TimeLockDelta,
+		probability: label.probability * edge.SuccessRate,
+		hops:        label.hops + 1,
+		edges:       append(append(make([]*routeEdge, 0, len(label.edges)+1), label.edges...), edge),
+		amounts:     append(append(make([]float64, 0, len(label.amounts)+1), label.amounts...), label.received),
+	}
+	cost := graph.weights.Hop*amount +
+		graph.weights.TimeLock*label.received*float64(edge.TimeLockDelta) +
+		graph.weights.Liquidity*label.received*label.received/edge.Balance +
+		graph.weights.Failure*label.received*(1-edge.SuccessRate)
+	// the fee of the hop is on the amount it forwards, which has the fees of the hops after it
+	if edge.From != payer {
+		fee := label.received * edge.FeeRate
+		next.received += fee
+		cost += fee
+	}
+	next.cost = label.cost + cost
+	return next
+}
+
+// walk the edges from the payee, the label of the last sender
+func (graph *channelGraph) walk(edges []*routeEdge, payer, payee string, amount float64) *routeLabel {
+	label := &routeLabel{peerId: payee, received: amount, probability: 1}
+	for _, edge := range edges {
+		label = graph.extend(label, edge, payer, amount)
+		if label == nil {
+			return nil
+		}
+	}
+	return label
+}
+
+// shortestPath dijkstra from the start label back to the payer, without the excluded channels and peers
+func (graph *channelGraph) shortestPath(start *routeLabel, payer string, amount float64, maxHops int, excludedEdges map[*routeEdge]bool, excludedPeers map[string]bool) *routeLabel {
+	best := map[string]float64{start.peerId: start.cost}
+	done := make(map[string]bool)
+	queue := &routeQueue{start}
+	for queue.Len() > 0 {
+		label := heap.Pop(queue).(*routeLabel)
+		if label.peerId == payer {
+			return label
+		}
+		if done[label.peerId] {
+			continue
+		}
+		done[label.peerId] = true
+		if label.hops >= maxHops {
+			continue
+		}
+		for _, edge := range graph.edgesTo[label.peerId] {
+			if excludedEdges[edge] || excludedPeers[edge.From] || done[edge.From] {
+				continue
+			}
+			next := graph.extend(label, edge, payer, amount)
+			if next == nil {
+				continue
+			}
+			if cost, ok := best[edge.From]; ok && cost <= next.cost {
+				continue
+			}
+			best[edge.From] = next.cost
+			heap.Push(queue, next)
+		}
+	}
+	return nil
+}
+
+// findRoutes the k cheapest routes from the payer to the payee by yen's algorithm, ranked by the cost
+func (graph *channelGraph) findRoutes(payer, payee string, amount float64, k int, maxHops int) []*Route {
+	routes := make([]*Route, 0, k)
+	if payer == payee || k < 1 {
+		return routes
+	}
+	first := graph.shortestPath(&routeLabel{peerId: payee, received: amount, probability: 1}, payer, amount, maxHops, nil, nil)
+	if first == nil {
+		return routes
+	}
+	found := []*routeLabel{first}
+	candidates := make([]*routeLabel, 0)
+	for len(found) < k {
+		prev := found[len(found)-1]
+		// the spur peer is the i-th peer from the payee, the root is the route from the payee to it
+		for i := 0; i < len(prev.edges); i++ {
+			root := prev.edges[:i]
+			rootLabel := graph.walk(root, payer, payee, amount)
+			if rootLabel == nil {
+				continue
+			}
+			excludedEdges := make(map[*routeEdge]bool)
+			for _, route := range found {
+				if len(route.edges) > i && sameEdges(route.edges[:i], root) {
+					excludedEdges[route.edges[i]] = true
+				}
+			}
+			excludedPeers := map[string]bool{payee: i > 0}
+			for _, edge := range root[:maxInt(i-1, 0)] {
+				excludedPeers[edge.From] = true
+			}
+			spur := graph.shortestPath(rootLabel, payer, amount, maxHops, excludedEdges, excludedPeers)
+			if spur == nil || containsRoute(found, spur.edges) || containsRoute(candidates, spur.edges) {
+				continue
+			}
+			candidates = append(candidates, spur)
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		cheapest := 0
+		for index, candidate := range candidates {
+			if candidate.cost < candidates[cheapest].cost {
+				cheapest = index
+			}
+		}
+		found = append(found, candidates[cheapest])
+		candidates = append(candidates[:cheapest], candidates[cheapest+1:]...)
+	}
+
+	for _, label := range found {
+		routes = append(routes, newRoute(label, amount))
+	}
+	return routes
+}
+
+// splitPayment split the amount over the routes without a channel in common, as a channel carries one htlc at a time.
+// The part is halved when no route carries it, nil if the amount can not be carried by maxParts parts of minPart at least.
+func (graph *channelGraph) splitPayment(payer, payee string, amount, minPart float64, maxParts int, maxHops int) []*Route {
+	if payer == payee || maxParts < 2 {
+		return nil
+	}
+	parts := make([]*Route, 0, maxParts)
+	usedChannels := make(map[string]bool)
+	remaining := decimal.NewFromFloat(amount)
+	part := remaining
+	for remaining.IsPositive() {
+		if len(parts) == maxParts || part.LessThan(decimal.NewFromFloat(minPart)) ||
+			part.Mul(decimal.New(int64(maxParts-len(parts)), 0)).LessThan(remaining) {
+			return nil
+		}
+		partAmount, _ := part.Float64()
+		label := graph.shortestPath(&routeLabel{peerId: payee, received: partAmount, probability: 1}, payer, partAmount, maxHops, graph.edgesOfChannels(usedChannels), nil)
+		if label == nil {
+			part = part.Div(decimal.New(2, 0)).Round(8)
+			continue
+		}
+		route := newRoute(label, partAmount)
+		route.AmountToPayee = partAmount
+		for _, channelId := range route.channelIds {
+			usedChannels[channelId] = true
+		}
+		parts = append(parts, route)
+		remaining = remaining.Sub(part)
+		part = remaining
+	}
+	return parts
+}
+
+// both directions of the channels
+func (graph *channelGraph) edgesOfChannels(channelIds map[string]bool) map[*routeEdge]bool {
+	edges := make(map[*routeEdge]bool)
+	if len(channelIds) == 0 {
+		return edges
+	}
+	for _, items := range graph.edgesTo {
+		for _, edge := range items {
+			if channelIds[edge.ChannelId] {
+				edges[edge] = true
+			}
+		}
+	}
+	return edges
+}
+
+func newRoute(label *routeLabel, amount float64) *Route {
+	route := &Route{Cltv: label.cltv, channelIds: make([]string, 0, len(label.edges)), peerIds: make([]string, 0, len(label.edges)),
+		amounts: make([]float64, 0, len(label.edges))}
+	for i := len(label.edges) - 1; i > -1; i-- {
+		route.channelIds = append(route.channelIds, label.edges[i].ChannelId)
+		route.peerIds = append(route.peerIds, label.edges[i].To)
+		hopAmount, _ := decimal.NewFromFloat(label.amounts[i]).Round(8).Float64()
+		route.amounts = append(route.amounts, hopAmount)
+	}
+	route.Path = strings.Join(route.channelIds, ",")
+	route.Amount, _ = decimal.NewFromFloat(label.received).Round(8).Float64()
+	route.Fee, _ = decimal.NewFromFloat(label.received).Sub(decimal.NewFromFloat(amount)).Round(8).Float64()
+	route.Probability, _ = decimal.NewFromFloat(label.probability).Round(4).Float64()
+	route.Cost, _ = decimal.NewFromFloat(label.cost).Round(8).Float64()
+	return route
+}
+
+func sameEdges(a, b []*routeEdge) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsRoute(labels []*routeLabel, edges []*routeEdge) bool {
+	for _, label := range labels {
+		if sameEdges(label.edges, edges) {
+			return true
+		}
+	}
+	return false
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// routeQueue the priority queue of the labels by the cost
+type routeQueue []*routeLabel
+
+func (queue routeQueue) Len() int           { return len(queue) }
+func (queue routeQueue) Less(i, j int) bool { return queue[i].cost < queue[j].cost }
+func (queue routeQueue) Swap(i, j int)      { queue[i], queue[j] = queue[j], queue[i] }
+
+func (queue *routeQueue) Push(x interface{}) {
+	*queue = append(*queue, x.(*routeLabel))
+}
+
+func (queue *routeQueue) Pop() interface{} {
+	old := *queue
+	label := old[len(old)-1]
+	*queue = old[:len(old)-1]
+	return label
+}
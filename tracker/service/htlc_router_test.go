@@ -0,0 +1,273 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/asdine/storm"
+	cbean "github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/tracker/dao"
+)
+
+// both directions of the channel with the same balance and policy
+func testChannel(channelId, peerA, peerB string, balance, feeRate float64, successRate float64) []*routeEdge {
+	return []*routeEdge{
+		{ChannelId: channelId, From: peerA, To: peerB, Balance: balance, FeeRate: feeRate, TimeLockDelta: 1, SuccessRate: successRate},
+		{ChannelId: channelId, From: peerB, To: peerA, Balance: balance, FeeRate: feeRate, TimeLockDelta: 1, SuccessRate: successRate},
+	}
+}
+
+func newTestGraph(channels ...[]*routeEdge) *channelGraph {
+	edges := make([]*routeEdge, 0)
+	for _, channel := range channels {
+		edges = append(edges, channel...)
+	}
+	return newChannelGraph(edges)
+}
+
+func TestFindRoutesRankedByFee(t *testing.T) {
+	// alice -> carol -> bob is cheaper than alice -> dave -> bob, alice -> bob directly has no fee
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.5),
+		testChannel("cb", "carol", "bob", 10, 0.001, 0.5),
+		testChannel("ad", "alice", "dave", 10, 0.01, 0.5),
+		testChannel("db", "dave", "bob", 10, 0.01, 0.5),
+		testChannel("ab", "alice", "bob", 10, 0.01, 0.5),
+	)
+	routes := graph.findRoutes("alice", "bob", 1, 5, 6)
+	if len(routes) != 3 {
+		t.Fatalf("got %d routes, want 3", len(routes))
+	}
+	want := []struct {
+		path string
+		fee  float64
+		cltv int
+	}{{"ab", 0, 1}, {"ac,cb", 0.001, 2}, {"ad,db", 0.01, 2}}
+	for i, route := range routes {
+		if route.Path != want[i].path || route.Fee != want[i].fee || route.Amount != 1+want[i].fee || route.Cltv != want[i].cltv {
+			t.Fatalf("the route %d is %+v, want %+v", i, *route, want[i])
+		}
+		if i > 0 && route.Cost < routes[i-1].Cost {
+			t.Fatalf("the routes are not ranked by the cost: %+v %+v", *routes[i-1], *route)
+		}
+	}
+	// the payer sends the amount and the fees, the hop forwards the amount to the payee
+	if amounts := hopAmounts(routes[2]); amounts != "1.01000000,1.00000000" {
+		t.Fatalf("got the hop amounts %s of the route ad,db, want the fee of dave in the first channel", amounts)
+	}
+}
+
+func TestFindRoutesLiquidityAndHistory(t *testing.T) {
+	// the fees of the routes are the same, the channel to dave fails more often
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.9),
+		testChannel("cb", "carol", "bob", 10, 0.001, 0.9),
+		testChannel("ad", "alice", "dave", 10, 0.001, 0.1),
+		testChannel("db", "dave", "bob", 10, 0.001, 0.9),
+	)
+	routes := graph.findRoutes("alice", "bob", 1, 2, 6)
+	if len(routes) != 2 || routes[0].Path != "ac,cb" || routes[0].Probability <= routes[1].Probability {
+		t.Fatalf("the routes are %v, want the route by carol first", routes)
+	}
+
+	// the balance of carol is not enough for the amount and the fee of carol
+	graph = newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.9),
+		testChannel("cb", "carol", "bob", 5, 0.001, 0.9),
+		testChannel("ad", "alice", "dave", 10, 0.001, 0.1),
+		testChannel("db", "dave", "bob", 10, 0.001, 0.9),
+	)
+	routes = graph.findRoutes("alice", "bob", 6, 2, 6)
+	if len(routes) != 1 || routes[0].Path != "ad,db" {
+		t.Fatalf("the routes are %v, want the route by dave only", routes)
+	}
+}
+
+func TestFindRoutesMaxHops(t *testing.T) {
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.5),
+		testChannel("cd", "carol", "dave", 10, 0.001, 0.5),
+		testChannel("db", "dave", "bob", 10, 0.001, 0.5),
+	)
+	if routes := graph.findRoutes("alice", "bob", 1, 3, 2); len(routes) != 0 {
+		t.Fatalf("the routes are %v, the max hops is 2", routes)
+	}
+	// dave charges on 1, carol charges on 1.001 which she forwards to dave
+	routes := graph.findRoutes("alice", "bob", 1, 3, 3)
+	if len(routes) != 1 || routes[0].Path != "ac,cd,db" || routes[0].Fee != 0.002001 || routes[0].Cltv != 3 {
+		t.Fatalf("the routes are %v", routes)
+	}
+}
+
+func TestSplitPayment(t *testing.T) {
+	// no route carries 8, the routes by carol and dave carry 6 and 5
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.5),
+		testChannel("cb", "carol", "bob", 6, 0.001, 0.5),
+		testChannel("ad", "alice", "dave", 10, 0.001, 0.5),
+		testChannel("db", "dave", "bob", 5, 0.001, 0.5),
+	)
+	if routes := graph.findRoutes("alice", "bob", 8, 3, 6); len(routes) != 0 {
+		t.Fatalf("the routes are %v, want no route", routes)
+	}
+	parts := graph.splitPayment("alice", "bob", 8, 0.1, 4, 6)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want 2", len(parts))
+	}
+	used := make(map[string]bool)
+	total := 0.0
+	for _, part := range parts {
+		for _, channelId := range part.channelIds {
+			if used[channelId] {
+				t.Fatalf("the channel %s is used by two parts", channelId)
+			}
+			used[channelId] = true
+		}
+		if part.Amount != part.AmountToPayee+part.Fee {
+			t.Fatalf("the part %+v", *part)
+		}
+		total += part.AmountToPayee
+	}
+	if total != 8 {
+		t.Fatalf("the parts carry %v, want 8", total)
+	}
+
+	// the routes carry 11 at most
+	if parts := graph.splitPayment("alice", "bob", 12, 0.1, 4, 6); parts != nil {
+		t.Fatalf("the parts are %v, want nil", parts)
+	}
+	if parts := graph.splitPayment("alice", "bob", 8, 0.1, 1, 6); parts != nil {
+		t.Fatalf("the parts are %v, want nil for one part", parts)
+	}
+}
+
+func TestExcludeChannelsAndPeers(t *testing.T) {
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.5),
+		testChannel("cb", "carol", "bob", 10, 0.001, 0.5),
+		testChannel("ad", "alice", "dave", 10, 0.01, 0.5),
+		testChannel("db", "dave", "bob", 10, 0.01, 0.5),
+		testChannel("ae", "alice", "erin", 10, 0.02, 0.5),
+		testChannel("eb", "erin", "bob", 10, 0.02, 0.5),
+	)
+	routes := graph.exclude([]string{"cb"}, nil, "alice", "bob").findRoutes("alice", "bob", 1, 3, 6)
+	if len(routes) != 2 || routes[0].Path != "ad,db" {
+		t.Fatalf("the routes are %v, want no route by the channel cb", routes)
+	}
+	routes = graph.exclude(nil, []string{"dave", "bob"}, "alice", "bob").findRoutes("alice", "bob", 1, 3, 6)
+	if len(routes) != 2 || routes[0].Path != "ac,cb" || routes[1].Path != "ae,eb" {
+		t.Fatalf("the routes are %v, want no route by dave", routes)
+	}
+}
+
+func TestHopKeys(t *testing.T) {
+	graph := newTestGraph(
+		testChannel("ac", "alice", "carol", 10, 0.001, 0.5),
+		testChannel("cb", "carol", "bob", 10, 0.001, 0.5),
+	)
+	routes := graph.findRoutes("alice", "bob", 1, 1, 6)
+	if len(routes) != 1 {
+		t.Fatalf("got %d routes, want 1", len(routes))
+	}
+
+	users, nodes := userOfOnlineMap, obdOnlineNodesMap
+	defer func() { userOfOnlineMap, obdOnlineNodesMap = users, nodes }()
+	userOfOnlineMap = make(map[string]dao.UserInfo)
+	obdOnlineNodesMap = make(map[string]*dao.ObdNodeInfo)
+	for _, peerId := range []string{"carol", "bob"} {
+		userOfOnlineMap[peerId] = dao.UserInfo{ObdP2pNodeId: "node_" + peerId}
+		obdOnlineNodesMap["node_"+peerId] = &dao.ObdNodeInfo{}
+	}
+	// the key of bob is unknown, the route is not sent to the payer, only the direct channel to bob is
+	obdOnlineNodesMap["node_carol"].OnionPubKey = "key_carol"
+	if keys, ok := hopKeys(routes[0]); ok {
+		t.Fatalf("got the hop keys %s, want none", keys)
+	}
+	if excluded := peersWithoutOnionKey(); len(excluded) != 1 || excluded[0] != "bob" {
+		t.Fatalf("got the peers without the onion keys %v, want [bob]", excluded)
+	}
+	direct := newTestGraph(testChannel("ab", "alice", "bob", 10, 0.001, 0.5)).findRoutes("alice", "bob", 1, 1, 6)
+	if withKeys := routesWithHopKeys(append(routes, direct...)); len(withKeys) != 1 || withKeys[0].Path != "ab" {
+		t.Fatalf("got the routes %v, want the direct channel", withKeys)
+	}
+	obdOnlineNodesMap["node_bob"].OnionPubKey = "key_bob"
+	if keys, ok := hopKeys(routes[0]); ok == false || keys != "key_carol,key_bob" {
+		t.Fatalf("got the hop keys %s, want the keys of carol and bob", keys)
+	}
+}
+
+// the db of the tracker in a temp dir, the db before is back after the test
+func openTestDb(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "tracker_db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trackerDb := db
+	db, err = storm.Open(dir + "/tracker.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return func() {
+		_ = db.Close()
+		db = trackerDb
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestFailureHistory(t *testing.T) {
+	defer openTestDb(t)()
+	var err error
+	users := userOfOnlineMap
+	defer func() { userOfOnlineMap = users }()
+	userOfOnlineMap = map[string]dao.UserInfo{"alice": {}, "bob": {}}
+	_ = db.Save(&dao.ChannelInfo{ChannelId: "ab", PropertyId: 1, CurrState: cbean.ChannelState_CanUse, PeerIdA: "alice", PeerIdB: "bob", AmountA: 10, AmountB: 10, ObdNodeIdA: "obdA"})
+
+	// an obd which is not an end of the channel reports the failure, it is not counted
+	if err = HtlcService.updateHtlcInfo(&ObdNode{ObdP2pNodeId: "obdC"}, `{"path":"ab","h":"h","curr_channel_id":"ab","failed_channel_id":"ab"}`); err != nil {
+		t.Fatal(err)
+	}
+	channelInfo := &dao.ChannelInfo{}
+	_ = db.One("ChannelId", "ab", channelInfo)
+	if channelInfo.FailureCount != 0 {
+		t.Fatalf("got %d failures of the channel reported by another obd, want 0", channelInfo.FailureCount)
+	}
+
+	// the obd of the channel reports the failure, it is less likely to succeed in the next search
+	if err = HtlcService.updateHtlcInfo(&ObdNode{ObdP2pNodeId: "obdA"}, `{"path":"ab","h":"h","curr_channel_id":"ab","failed_channel_id":"ab"}`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.One("ChannelId", "ab", channelInfo)
+	if channelInfo.FailureCount != 1 {
+		t.Fatalf("got %d failures of the channel, want 1", channelInfo.FailureCount)
+	}
+	edges := loadChannelGraph(1).edgesTo["bob"]
+	if len(edges) != 1 || edges[0].SuccessRate != float64(1)/3 {
+		t.Fatalf("got the edges %v to bob, want the success rate 1/3", edges)
+	}
+	if count, _ := db.Count(&dao.HtlcTxInfo{}); count != 0 {
+		t.Fatalf("got %d htlc infos, the failure is not a state of the htlc", count)
+	}
+}
+
+func TestLockPartsFailed(t *testing.T) {
+	defer openTestDb(t)()
+	_ = db.Save(&dao.ChannelInfo{ChannelId: "ab", PropertyId: 1, CurrState: cbean.ChannelState_CanUse, PeerIdA: "alice", PeerIdB: "bob"})
+	_ = db.Save(&dao.ChannelInfo{ChannelId: "ac", PropertyId: 1, CurrState: cbean.ChannelState_LockByTracker, PeerIdA: "alice", PeerIdB: "carol"})
+
+	// the channel of the second part is locked by another payment, the first part is unlocked
+	parts := []*Route{{Path: "ab", channelIds: []string{"ab"}}, {Path: "ac", channelIds: []string{"ac"}}}
+	if HtlcService.lockParts(parts) {
+		t.Fatal("the channel ac is locked, want the parts not locked")
+	}
+	channelInfo := &dao.ChannelInfo{}
+	_ = db.One("ChannelId", "ab", channelInfo)
+	if channelInfo.CurrState != cbean.ChannelState_CanUse {
+		t.Fatalf("got the state %d of the channel ab, want it usable", channelInfo.CurrState)
+	}
+	var lockPaths []dao.LockHtlcPath
+	_ = db.All(&lockPaths)
+	if len(lockPaths) != 1 || lockPaths[0].CurrState != 2 {
+		t.Fatalf("got the lock paths %v, want the lock of ab finished", lockPaths)
+	}
+}
@@ -18,29 +18,13 @@ import (
 	"time"
 )
 
-type graphEdge struct {
-	ParentNodeIndex int
-	PathPeerIds     string
-	ChannelIds      string
-	PathIndexArr    []int
-	ChannelId       string
-	Level           uint16
-	CurrNodePeerId  string
-	IsRoot          bool
-	IsTarget        bool
-}
-
 type htlcManager struct {
-	mu       sync.Mutex
-	openList []*graphEdge
+	mu sync.Mutex
 }
 
 var HtlcService htlcManager
 
 func (manager *htlcManager) getPath(obdClient *ObdNode, msgData string) (path interface{}, err error) {
-	manager.mu.Lock()
-	defer manager.mu.Unlock()
-
 	log.Println("getPath", msgData)
 	if tool.CheckIsString(&msgData) == false {
 		return "", errors.New("wrong inputData")
@@ -61,76 +45,330 @@ func (manager *htlcManager) getPath(obdClient *ObdNode, msgData string) (path in
 		return "", errors.New("wrong amount")
 	}
 
-	manager.createChannelNetwork(pathRequest.RealPayerPeerId, pathRequest.PayeePeerId, pathRequest.PropertyId, pathRequest.Amount, nil, true)
-	resultIndex := manager.getPathIndex()
+	// search on the snapshot of the channels, the lock is only for locking the channels of the route.
+	// The path is wrapped in the onion, the hops without the onion keys are not on it.
+	excludedPeers := append(peersWithoutOnionKey(), pathRequest.ExcludedPeers...)
+	graph := loadChannelGraph(pathRequest.PropertyId).
+		exclude(pathRequest.ExcludedChannels, excludedPeers, pathRequest.RealPayerPeerId, pathRequest.PayeePeerId)
+	routes := routesWithHopKeys(graph.findRoutes(pathRequest.RealPayerPeerId, pathRequest.PayeePeerId, pathRequest.Amount, cfg.HtlcRouteCandidates, cfg.HtlcMaxHops))
 
 	retNode := make(map[string]interface{})
 	retNode["senderPeerId"] = pathRequest.RealPayerPeerId
 	retNode["h"] = pathRequest.H
 	retNode["amount"] = pathRequest.Amount
 	retNode["path"] = ""
-	if resultIndex != -1 {
-		splitArr := strings.Split(manager.openList[resultIndex].ChannelIds, ",")
-		path := ""
-		for i := len(splitArr) - 1; i > -1; i-- {
-			path += splitArr[i] + ","
+	retNode["routes"] = routes
+
+	// no route carries the whole amount, split it into the parts of a multi-path payment
+	if len(routes) == 0 && pathRequest.MaxParts > 1 {
+		parts := graph.splitPayment(pathRequest.RealPayerPeerId, pathRequest.PayeePeerId, pathRequest.Amount, tool.GetOmniDustBtc(), pathRequest.MaxParts, cfg.HtlcMaxHops)
+		if len(parts) > 0 && len(routesWithHopKeys(parts)) == len(parts) && manager.lockParts(parts) {
+			retNode["path"] = partsPath(parts)
+			retNode["hop_keys"] = partsHopKeys(parts)
+			retNode["hop_amounts"] = partsHopAmounts(parts)
+			retNode["parts"] = parts
+			fee := decimal.Zero
+			cltv := 0
+			for _, part := range parts {
+				fee = fee.Add(decimal.NewFromFloat(part.Fee))
+				if part.Cltv > cltv {
+					cltv = part.Cltv
+				}
+			}
+			retNode["fee"], _ = fee.Round(8).Float64()
+			retNode["cltv"] = cltv
 		}
-		path = strings.TrimSuffix(path, ",")
-		retNode["path"] = path
+		log.Println("return parts info", retNode)
+		return retNode, nil
+	}
+
+	route := manager.lockRoute(routes)
+	if route != nil {
+		retNode["path"] = route.Path
+		retNode["hop_keys"], _ = hopKeys(route)
+		retNode["hop_amounts"] = hopAmounts(route)
+		retNode["fee"] = route.Fee
+		retNode["cltv"] = route.Cltv
 	}
 	log.Println("return path info", retNode)
 	return retNode, nil
 }
 
-func (manager *htlcManager) getPathIndex() int {
-	resultIndex := -1
-	minLength := 99
-	for index, node := range manager.openList {
-		if node.IsTarget {
-			log.Println(node.ChannelIds)
-			tempLength := len(strings.Split(node.ChannelIds, ","))
-			if tempLength < minLength {
-				resultIndex = index
-				minLength = tempLength
-			}
+// loadChannelGraph the usable channels of the property between the online users, both directions of a channel
+// are the edges if its sender is online on the tracker
+func loadChannelGraph(propertyId int64) *channelGraph {
+	var channels []dao.ChannelInfo
+	_ = db.Select(
+		q.Eq("PropertyId", propertyId),
+		q.Eq("CurrState", cbean.ChannelState_CanUse)).OrderBy("Id").Reverse().
+		Find(&channels)
+
+	edges := make([]*routeEdge, 0, len(channels)*2)
+	for _, item := range channels {
+		// check userOnline
+		if getUserState(item.ObdNodeIdA, item.PeerIdA) == false || getUserState(item.ObdNodeIdB, item.PeerIdB) == false {
+			continue
+		}
+		successRate := float64(item.SuccessCount+1) / float64(item.SuccessCount+item.FailureCount+2)
+		if _, ok := getOnlineUser(item.PeerIdA); ok {
+			edges = append(edges, &routeEdge{
+				ChannelId:     item.ChannelId,
+				From:          item.PeerIdA,
+				To:            item.PeerIdB,
+				Balance:       item.AmountA,
+				FeeRate:       feeRateOrDefault(item.FeeRateA),
+				TimeLockDelta: timeLockDeltaOrDefault(item.TimeLockDeltaA),
+				SuccessRate:   successRate,
+			})
+		}
+		if _, ok := getOnlineUser(item.PeerIdB); ok {
+			edges = append(edges, &routeEdge{
+				ChannelId:     item.ChannelId,
+				From:          item.PeerIdB,
+				To:            item.PeerIdA,
+				Balance:       item.AmountB,
+				FeeRate:       feeRateOrDefault(item.FeeRateB),
+				TimeLockDelta: timeLockDeltaOrDefault(item.TimeLockDeltaB),
+				SuccessRate:   successRate,
+			})
 		}
 	}
-	if resultIndex != -1 {
-		channelIdArr := strings.Split(manager.openList[resultIndex].ChannelIds, ",")
-		lockResult := true
-		for _, item := range channelIdArr {
-			channelInfo := &dao.ChannelInfo{}
-			err := db.Select(q.Eq("ChannelId", item), q.Eq("CurrState", cbean.ChannelState_CanUse)).First(channelInfo)
-			if err == nil {
-				if len(channelInfo.ObdNodeIdA) > 0 && sendChannelLockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdA, channelInfo.ObdNodeIdA) == false {
-					lockResult = false
-					break
-				}
+	return newChannelGraph(edges)
+}
 
-				if len(channelInfo.ObdNodeIdB) > 0 && sendChannelLockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdB, channelInfo.ObdNodeIdB) == false {
-					lockResult = false
-					break
-				}
-			}
+func feeRateOrDefault(feeRate float64) float64 {
+	if feeRate > 0 {
+		return feeRate
+	}
+	return cfg.HtlcFeeRate
+}
+
+func timeLockDeltaOrDefault(timeLockDelta int) int {
+	if timeLockDelta > 0 {
+		return timeLockDelta
+	}
+	return cfg.HtlcTimeLockDelta
+}
+
+// lockRoute lock the channels of the first route which are still usable and locked by their obd nodes,
+// the channel refused by its obd node counts as a failure of it. The channels are taken from the usable ones under
+// manager.mu, the obd nodes are asked without it, so the other payments are not blocked by the network.
+func (manager *htlcManager) lockRoute(routes []*Route) *Route {
+	for _, route := range routes {
+		channelInfos := manager.takeChannels(route)
+		if channelInfos == nil {
+			continue
 		}
-		if lockResult == false {
-			manager.openList = append(manager.openList[:resultIndex], manager.openList[resultIndex+1:]...)
-			return manager.getPathIndex()
-		} else {
-			for _, item := range channelIdArr {
-				channelInfo := &dao.ChannelInfo{}
-				err := db.Select(q.Eq("ChannelId", item), q.Eq("CurrState", cbean.ChannelState_CanUse)).First(channelInfo)
-				if err == nil {
-					channelInfo.CurrState = cbean.ChannelState_LockByTracker
-					channelInfo.LatestEditAt = time.Now()
-					_ = db.Update(channelInfo)
-				}
+		locked := make([]*dao.ChannelInfo, 0, len(channelInfos))
+		var refused *dao.ChannelInfo
+		for _, channelInfo := range channelInfos {
+			if lockChannelAtObds(channelInfo) == false {
+				refused = channelInfo
+				break
 			}
-			htlcPath := dao.LockHtlcPath{Path: channelIdArr, CurrState: 0, CreateAt: time.Now()}
+			locked = append(locked, channelInfo)
+		}
+		if refused == nil {
+			manager.mu.Lock()
+			htlcPath := dao.LockHtlcPath{Path: route.channelIds, CurrState: 0, CreateAt: time.Now()}
 			_ = db.Save(&htlcPath)
+			route.lockPathId = htlcPath.Id
+			manager.mu.Unlock()
+			return route
+		}
+		// the channels locked by their obd nodes before the failure are unlocked
+		for _, channelInfo := range locked {
+			unlockChannelAtObds(channelInfo)
+		}
+		manager.releaseChannels(route, channelInfos, refused)
+	}
+	return nil
+}
+
+// takeChannels the channels of the route are locked by the tracker if all of them are usable, nil if not
+func (manager *htlcManager) takeChannels(route *Route) []*dao.ChannelInfo {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	channelInfos := make([]*dao.ChannelInfo, 0, len(route.channelIds))
+	for _, item := range route.channelIds {
+		channelInfo := &dao.ChannelInfo{}
+		err := db.Select(q.Eq("ChannelId", item), q.Eq("CurrState", cbean.ChannelState_CanUse)).First(channelInfo)
+		if err != nil {
+			return nil
+		}
+		channelInfos = append(channelInfos, channelInfo)
+	}
+	for _, channelInfo := range channelInfos {
+		channelInfo.CurrState = cbean.ChannelState_LockByTracker
+		channelInfo.LatestEditAt = time.Now()
+		_ = db.Update(channelInfo)
+	}
+	return channelInfos
+}
+
+// releaseChannels the channels taken by takeChannels are usable again, the refused one counts a failure
+func (manager *htlcManager) releaseChannels(route *Route, channelInfos []*dao.ChannelInfo, refused *dao.ChannelInfo) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+
+	for _, channelInfo := range channelInfos {
+		current := &dao.ChannelInfo{}
+		if db.One("Id", channelInfo.Id, current) != nil {
+			continue
+		}
+		if current.CurrState == cbean.ChannelState_LockByTracker {
+			_ = db.UpdateField(current, "CurrState", cbean.ChannelState_CanUse)
+		}
+		if channelInfo == refused {
+			_ = db.UpdateField(current, "FailureCount", current.FailureCount+1)
+		}
+	}
+	if route.lockPathId > 0 {
+		_ = db.UpdateField(&dao.LockHtlcPath{Id: route.lockPathId}, "CurrState", 2)
+		route.lockPathId = 0
+	}
+}
+
+// lockParts lock the routes of all the parts, the parts locked before a failure are unlocked
+func (manager *htlcManager) lockParts(parts []*Route) bool {
+	for i, part := range parts {
+		if manager.lockRoute([]*Route{part}) == nil {
+			for _, locked := range parts[:i] {
+				manager.unlockRoute(locked)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// lockChannelAtObds both obd nodes of the channel lock it, the channel locked by one of them is unlocked if the
+// other one refuses it
+func lockChannelAtObds(channelInfo *dao.ChannelInfo) bool {
+	if len(channelInfo.ObdNodeIdA) > 0 && sendChannelLockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdA, channelInfo.ObdNodeIdA) == false {
+		return false
+	}
+	if len(channelInfo.ObdNodeIdB) > 0 && sendChannelLockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdB, channelInfo.ObdNodeIdB) == false {
+		if len(channelInfo.ObdNodeIdA) > 0 {
+			_ = sendChannelUnlockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdA, channelInfo.ObdNodeIdA)
+		}
+		return false
+	}
+	return true
+}
+
+// unlockRoute the channels of the route locked by lockRoute are usable again, and the lock of the path is finished
+func (manager *htlcManager) unlockRoute(route *Route) {
+	channelInfos := make([]*dao.ChannelInfo, 0, len(route.channelIds))
+	for _, item := range route.channelIds {
+		channelInfo := &dao.ChannelInfo{}
+		if db.Select(q.Eq("ChannelId", item)).First(channelInfo) != nil {
+			continue
+		}
+		unlockChannelAtObds(channelInfo)
+		channelInfos = append(channelInfos, channelInfo)
+	}
+	manager.releaseChannels(route, channelInfos, nil)
+}
+
+func unlockChannelAtObds(channelInfo *dao.ChannelInfo) {
+	if len(channelInfo.ObdNodeIdA) > 0 {
+		_ = sendChannelUnlockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdA, channelInfo.ObdNodeIdA)
+	}
+	if len(channelInfo.ObdNodeIdB) > 0 {
+		_ = sendChannelUnlockInfoToObd(channelInfo.ChannelId, channelInfo.PeerIdB, channelInfo.ObdNodeIdB)
+	}
+}
+
+// partsPath the path of the parts for the obd of the payer: the routes separated by ";",
+// each route is its channels and the amount to the payee, like "c1,c2:0.5;c3:0.5"
+func partsPath(parts []*Route) string {
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		items = append(items, part.Path+":"+tool.FloatToString(part.AmountToPayee, 8))
+	}
+	return strings.Join(items, ";")
+}
+
+// hopKeys the onion public keys of the obd nodes of the hops separated by ",", in the order of the path. It is false
+// if a node did not announce its key.
+func hopKeys(route *Route) (string, bool) {
+	keys := make([]string, 0, len(route.peerIds))
+	for _, peerId := range route.peerIds {
+		onionPubKey := getOnionPubKey(peerId)
+		if len(onionPubKey) == 0 {
+			return "", false
+		}
+		keys = append(keys, onionPubKey)
+	}
+	return strings.Join(keys, ","), true
+}
+
+// routesWithHopKeys the routes whose hops all have the onion keys, the payer does not send a path in plain text.
+// A route of one channel has no onion, its payee needs no key.
+func routesWithHopKeys(routes []*Route) []*Route {
+	items := make([]*Route, 0, len(routes))
+	for _, route := range routes {
+		if _, ok := hopKeys(route); ok || len(route.channelIds) == 1 {
+			items = append(items, route)
+		}
+	}
+	return items
+}
+
+// partsHopKeys the hop keys of the parts separated by ";", in the order of partsPath
+func partsHopKeys(parts []*Route) string {
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		keys, _ := hopKeys(part)
+		items = append(items, keys)
+	}
+	return strings.Join(items, ";")
+}
+
+// hopAmounts the amounts of the htlcs on the channels separated by ",", in the order of the path. The payer wraps
+// them in the onion, every hop forwards the amount it is paid for by its fee rate.
+func hopAmounts(route *Route) string {
+	amounts := make([]string, 0, len(route.amounts))
+	for _, amount := range route.amounts {
+		amounts = append(amounts, tool.FloatToString(amount, 8))
+	}
+	return strings.Join(amounts, ",")
+}
+
+// partsHopAmounts the hop amounts of the parts separated by ";", in the order of partsPath
+func partsHopAmounts(parts []*Route) string {
+	items := make([]string, 0, len(parts))
+	for _, part := range parts {
+		items = append(items, hopAmounts(part))
+	}
+	return strings.Join(items, ";")
+}
+
+// the payment through the path is finished, every channel of it counts a success
+func recordPathSuccess(path string) {
+	for _, item := range strings.Split(path, ",") {
+		channelInfo := &dao.ChannelInfo{}
+		if db.Select(q.Eq("ChannelId", item)).First(channelInfo) == nil {
+			_ = db.UpdateField(channelInfo, "SuccessCount", channelInfo.SuccessCount+1)
 		}
 	}
-	return resultIndex
+}
+
+// a hop failed the htlc in the channel, it counts a failure of the channel. Only the obd of an end of the channel
+// reports it, so that other obds can not rank down the channels of others.
+func recordChannelFailure(obdClient *ObdNode, channelId string) {
+	if obdClient == nil || len(obdClient.ObdP2pNodeId) == 0 {
+		return
+	}
+	channelInfo := &dao.ChannelInfo{}
+	if db.Select(q.Eq("ChannelId", channelId)).First(channelInfo) != nil {
+		return
+	}
+	if channelInfo.ObdNodeIdA == obdClient.ObdP2pNodeId || channelInfo.ObdNodeIdB == obdClient.ObdP2pNodeId {
+		_ = db.UpdateField(channelInfo, "FailureCount", channelInfo.FailureCount+1)
+	}
 }
 
 func (manager *htlcManager) updateHtlcInfo(obdClient *ObdNode, msgData string) (err error) {
@@ -146,6 +384,10 @@ func (manager *htlcManager) updateHtlcInfo(obdClient *ObdNode, msgData string) (
 		return err
 	}
 
+	if tool.CheckIsString(&reqData.FailedChannelId) {
+		recordChannelFailure(obdClient, reqData.FailedChannelId)
+		return nil
+	}
 	if tool.CheckIsString(&reqData.Path) == false {
 		return errors.New("path")
 	}
@@ -168,6 +410,7 @@ func (manager *htlcManager) updateHtlcInfo(obdClient *ObdNode, msgData string) (
 	} else {
 		if tool.CheckIsString(&htlcTxInfo.R) == false && tool.CheckIsString(&reqData.R) {
 			htlcTxInfo.R = reqData.R
+			recordPathSuccess(reqData.Path)
 		}
 		htlcTxInfo.DirectionFlag = reqData.DirectionFlag
 		htlcTxInfo.CurrChannelId = reqData.CurrChannelId
@@ -208,111 +451,3 @@ func (manager *htlcManager) GetHtlcCurrState(context *gin.Context) {
 		"data": retData,
 	})
 }
-
-func (manager *htlcManager) createChannelNetwork(realPayerPeerId, currPayeePeerId string, propertyId int64, amount float64, currNode *graphEdge, isBegin bool) {
-	if isBegin {
-		manager.openList = make([]*graphEdge, 0)
-		realPayeeEdge := &graphEdge{
-			ParentNodeIndex: -1,
-			CurrNodePeerId:  currPayeePeerId,
-			PathPeerIds:     "",
-			ChannelIds:      "",
-			PathIndexArr:    make([]int, 0),
-			Level:           0,
-			IsRoot:          true,
-			IsTarget:        false,
-		}
-		manager.openList = append(manager.openList, realPayeeEdge)
-	}
-
-	if currNode == nil {
-		currNode = manager.openList[0]
-	}
-	//如果当前用户已经在备选的用户列表中出现了，就return
-	if strings.Contains(currNode.PathPeerIds, currNode.CurrNodePeerId) {
-		return
-	}
-
-	if currNode.Level > 0 {
-		amount, _ = decimal.NewFromFloat(amount).Mul(decimal.NewFromFloat(1 + cfg.HtlcFeeRate*float64(currNode.Level))).Round(8).Float64()
-	}
-
-	currNodeIndex := 0
-	for index, tempNode := range manager.openList {
-		if tempNode == currNode {
-			currNodeIndex = index
-			break
-		}
-	}
-
-	pathPeerIds := currNode.PathPeerIds + "," + currNode.CurrNodePeerId
-	pathIndexArr := currNode.PathIndexArr
-	if currNode.PathPeerIds == "" {
-		pathPeerIds = currNode.CurrNodePeerId
-		pathIndexArr = make([]int, 0)
-	}
-	pathIndexArr = append(pathIndexArr, currNodeIndex)
-
-	var nodes []dao.ChannelInfo
-	err := errors.New("no channel")
-
-	err = db.Select(
-		q.Eq("PropertyId", propertyId),
-		q.Eq("CurrState", cbean.ChannelState_CanUse),
-		q.Or(
-			q.Eq("PeerIdB", currPayeePeerId),
-			q.Eq("PeerIdA", currPayeePeerId))).OrderBy("Id").Reverse().
-		Find(&nodes)
-
-	if err == nil {
-		for _, item := range nodes {
-
-			// check userOnline
-			if getUserState(item.ObdNodeIdA, item.PeerIdA) == false {
-				continue
-			}
-			if getUserState(item.ObdNodeIdB, item.PeerIdB) == false {
-				continue
-			}
-
-			interSender := item.PeerIdA
-			leftAmount := item.AmountA
-			if item.PeerIdA == currPayeePeerId {
-				interSender = item.PeerIdB
-				leftAmount = item.AmountB
-			}
-
-			if leftAmount >= amount {
-				if _, ok := userOfOnlineMap[interSender]; ok == false {
-					continue
-				}
-				channelIds := item.ChannelId
-				if tool.CheckIsString(&currNode.ChannelIds) {
-					channelIds = currNode.ChannelIds + "," + item.ChannelId
-				}
-
-				newEdge := graphEdge{
-					ParentNodeIndex: currNodeIndex,
-					PathPeerIds:     pathPeerIds,
-					PathIndexArr:    pathIndexArr,
-					Level:           currNode.Level + 1,
-					IsRoot:          false,
-					IsTarget:        false,
-					CurrNodePeerId:  interSender,
-					ChannelId:       item.ChannelId,
-					ChannelIds:      channelIds,
-				}
-				manager.openList = append(manager.openList, &newEdge)
-
-				if interSender == realPayerPeerId {
-					newEdge.IsTarget = true
-					newEdge.PathPeerIds += "," + newEdge.CurrNodePeerId
-				} else {
-					if newEdge.Level < 6 {
-						manager.createChannelNetwork(realPayerPeerId, interSender, propertyId, amount, &newEdge, false)
-					}
-				}
-			}
-		}
-	}
-}
@@ -19,6 +19,9 @@ import (
 
 //普通在线用户
 var userOfOnlineMap map[string]dao.UserInfo
+
+// userOfOnlineLock guards userOfOnlineMap, the obd nodes log their users in and out while the paths are searched
+var userOfOnlineLock sync.RWMutex
 var obdOnlineNodesMap = make(map[string]*dao.ObdNodeInfo)
 
 var db *storm.DB
@@ -30,6 +33,7 @@ func Start(chainType string) {
 		log.Println(err)
 	}
 	userOfOnlineMap = make(map[string]dao.UserInfo)
+	tracker = &ObdNode{Id: GetTrackerNodeId()}
 }
 
 type obdNodeAccountManager struct {
@@ -53,6 +57,7 @@ func (service *obdNodeAccountManager) login(obdClient *ObdNode, msgData string)
 	info.LatestLoginAt = time.Now()
 	info.LatestLoginIp = obdClient.Socket.RemoteAddr().String()
 	info.P2PAddress = reqData.P2PAddress
+	info.OnionPubKey = reqData.OnionPubKey
 	if info.Id == 0 {
 		info.NodeId = reqData.NodeId
 		info.IsOnline = true
@@ -92,6 +97,7 @@ func (service *obdNodeAccountManager) logout(obdClient *ObdNode) (err error) {
 	_ = db.Update(info)
 	_ = db.UpdateField(info, "IsOnline", info.IsOnline)
 
+	userOfOnlineLock.Lock()
 	for userId, item := range userOfOnlineMap {
 		if item.ObdNodeId == obdClient.Id {
 			delete(userOfOnlineMap, userId)
@@ -106,6 +112,7 @@ func (service *obdNodeAccountManager) logout(obdClient *ObdNode) (err error) {
 			_ = db.UpdateField(userInfo, "IsOnline", userInfo.IsOnline)
 		}
 	}
+	userOfOnlineLock.Unlock()
 
 	if info.Id > 0 {
 		split := strings.Split(info.P2PAddress, "/")
@@ -152,7 +159,9 @@ func (service *obdNodeAccountManager) updateUserInfo(obdP2pNodeId, obdClientId,
 		}
 	}
 	log.Println(info.UserId, info.ObdP2pNodeId, "login successfully")
+	userOfOnlineLock.Lock()
 	userOfOnlineMap[info.UserId] = *info
+	userOfOnlineLock.Unlock()
 	retData = "login successfully"
 	return retData, err
 }
@@ -183,11 +192,88 @@ func (service *obdNodeAccountManager) updateUsers(obdClient *ObdNode, msgData st
 				_ = db.Update(userInfo)
 			}
 		}
+		userOfOnlineLock.Lock()
 		userOfOnlineMap[userInfo.UserId] = *userInfo
+		userOfOnlineLock.Unlock()
 	}
 	return err
 }
 
+// the obd migrated the peer ids of its users, the migrations are replied to acknowledge them
+func (service *obdNodeAccountManager) updateUserPeerIds(obdClient *ObdNode, msgData string) (migrations []bean.UserPeerIdMigrationRequest, err error) {
+	if obdClient.IsLogin == false {
+		return nil, errors.New("obd need to login first")
+	}
+	if tool.CheckIsString(&msgData) == false {
+		return nil, errors.New("wrong inputData")
+	}
+	migrations = make([]bean.UserPeerIdMigrationRequest, 0)
+	err = json.Unmarshal([]byte(msgData), &migrations)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	for _, item := range migrations {
+		if tool.CheckIsString(&item.OldPeerId) == false || tool.CheckIsString(&item.NewPeerId) == false {
+			continue
+		}
+		log.Println("user peer id migrate from", item.OldPeerId, "to", item.NewPeerId)
+
+		// the users of the obd created by old versions are kept under its legacy node id
+		obdNodeIds := []string{obdClient.Id}
+		if tool.CheckIsString(&item.OldObdNodeId) {
+			obdNodeIds = append(obdNodeIds, item.OldObdNodeId)
+		}
+		newUserInfo := &dao.UserInfo{}
+		_ = db.Select(q.Eq("ObdNodeId", obdClient.Id), q.Eq("UserId", item.NewPeerId)).First(newUserInfo)
+		var userInfos []dao.UserInfo
+		_ = db.Select(q.In("ObdNodeId", obdNodeIds), q.Eq("UserId", item.OldPeerId)).Find(&userInfos)
+		for _, userInfo := range userInfos {
+			// the user has logged in with the new peer id already
+			if newUserInfo.Id > 0 {
+				err = db.DeleteStruct(&userInfo)
+			} else {
+				userInfo.UserId = item.NewPeerId
+				userInfo.ObdNodeId = obdClient.Id
+				err = db.Update(&userInfo)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		userOfOnlineLock.Lock()
+		if info, ok := userOfOnlineMap[item.OldPeerId]; ok && (info.ObdNodeId == obdClient.Id || info.ObdNodeId == item.OldObdNodeId) {
+			delete(userOfOnlineMap, item.OldPeerId)
+			info.UserId = item.NewPeerId
+			info.ObdNodeId = obdClient.Id
+			userOfOnlineMap[item.NewPeerId] = info
+		}
+		userOfOnlineLock.Unlock()
+
+		// only the side of the channel, which the obd announced, is migrated
+		var channelInfos []dao.ChannelInfo
+		_ = db.Select(q.Or(
+			q.And(q.Eq("PeerIdA", item.OldPeerId), q.Eq("ObdNodeIdA", obdClient.ObdP2pNodeId)),
+			q.And(q.Eq("PeerIdB", item.OldPeerId), q.Eq("ObdNodeIdB", obdClient.ObdP2pNodeId)))).Find(&channelInfos)
+		for _, channelInfo := range channelInfos {
+			if channelInfo.PeerIdA == item.OldPeerId && channelInfo.ObdNodeIdA == obdClient.ObdP2pNodeId {
+				channelInfo.PeerIdA = item.NewPeerId
+			}
+			if channelInfo.PeerIdB == item.OldPeerId && channelInfo.ObdNodeIdB == obdClient.ObdP2pNodeId {
+				channelInfo.PeerIdB = item.NewPeerId
+			}
+			err = db.Update(&channelInfo)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return migrations, nil
+}
+
 func (service *obdNodeAccountManager) userLogout(obdClient *ObdNode, msgData string) (err error) {
 	if obdClient.IsLogin == false {
 		return errors.New("obd need to login first")
@@ -211,7 +297,9 @@ func (service *obdNodeAccountManager) userLogout(obdClient *ObdNode, msgData str
 	info.IsOnline = false
 	err = db.UpdateField(info, "IsOnline", info.IsOnline)
 
+	userOfOnlineLock.Lock()
 	delete(userOfOnlineMap, info.UserId)
+	userOfOnlineLock.Unlock()
 
 	return err
 }
@@ -254,10 +342,10 @@ func (service *obdNodeAccountManager) GetUserState(context *gin.Context) {
 
 	retData := make(map[string]interface{})
 	retData["state"] = 0
-	if _, ok := userOfOnlineMap[reqData.UserId]; ok == true {
+	if _, ok := getOnlineUser(reqData.UserId); ok == true {
 		retData["state"] = 1
 	} else {
-		log.Println("currTracker userOfOnlineMap:", userOfOnlineMap)
+		log.Println("currTracker has no online user", reqData.UserId)
 		if _, ok := userOnlineOfOtherObdMap[reqData.P2pNodeId]; ok == true {
 			if _, ok := userOnlineOfOtherObdMap[reqData.P2pNodeId][reqData.UserId]; ok == true {
 				retData["state"] = 1
@@ -283,8 +371,8 @@ func (service *obdNodeAccountManager) GetUserP2pNodeId(context *gin.Context) {
 
 	retData := make(map[string]interface{})
 	retData["info"] = nil
-	if _, ok := userOfOnlineMap[reqData.UserId]; ok == true {
-		retData["info"] = userOfOnlineMap[reqData.UserId].ObdP2pNodeId
+	if userInfo, ok := getOnlineUser(reqData.UserId); ok == true {
+		retData["info"] = userInfo.ObdP2pNodeId
 	} else {
 		for _, item := range userOnlineOfOtherObdMap {
 			if _, ok := item[reqData.UserId]; ok == true {
@@ -300,8 +388,41 @@ func (service *obdNodeAccountManager) GetUserP2pNodeId(context *gin.Context) {
 	})
 }
 
+// getOnlineUser the user online on the obd nodes of this tracker
+func getOnlineUser(userId string) (dao.UserInfo, bool) {
+	userOfOnlineLock.RLock()
+	defer userOfOnlineLock.RUnlock()
+	userInfo, ok := userOfOnlineMap[userId]
+	return userInfo, ok
+}
+
+// getOnionPubKey the onion public key of the obd node of the online user, empty if the node did not announce it
+func getOnionPubKey(userId string) string {
+	userInfo, ok := getOnlineUser(userId)
+	if ok == false {
+		return ""
+	}
+	if node, ok := obdOnlineNodesMap[userInfo.ObdP2pNodeId]; ok {
+		return node.OnionPubKey
+	}
+	return ""
+}
+
+// peersWithoutOnionKey the online users whose obd nodes did not announce the onion keys, they can not be the hops
+func peersWithoutOnionKey() []string {
+	userOfOnlineLock.RLock()
+	defer userOfOnlineLock.RUnlock()
+	peerIds := make([]string, 0)
+	for userId, userInfo := range userOfOnlineMap {
+		if node, ok := obdOnlineNodesMap[userInfo.ObdP2pNodeId]; ok == false || len(node.OnionPubKey) == 0 {
+			peerIds = append(peerIds, userId)
+		}
+	}
+	return peerIds
+}
+
 func getUserState(obdP2pNodeId, userId string) bool {
-	if _, ok := userOfOnlineMap[userId]; ok == true {
+	if _, ok := getOnlineUser(userId); ok == true {
 		return true
 	} else {
 		if _, ok := userOnlineOfOtherObdMap[obdP2pNodeId]; ok == true {
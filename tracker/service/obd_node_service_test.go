@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+
+	cbean "github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/tracker/dao"
+)
+
+func TestUpdateUserPeerIds(t *testing.T) {
+	defer openTestDb(t)()
+	users := userOfOnlineMap
+	defer func() { userOfOnlineMap = users }()
+	userOfOnlineMap = map[string]dao.UserInfo{"old": {ObdNodeId: "legacyNode"}}
+
+	// the user of the obd is kept under the legacy node id, the user of another obd has the same old peer id
+	_ = db.Save(&dao.UserInfo{ObdNodeId: "legacyNode", ObdNodeUserLoginRequest: cbean.ObdNodeUserLoginRequest{UserId: "old"}})
+	_ = db.Save(&dao.UserInfo{ObdNodeId: "otherNode", ObdNodeUserLoginRequest: cbean.ObdNodeUserLoginRequest{UserId: "old"}})
+	_ = db.Save(&dao.ChannelInfo{ChannelId: "mine", ObdNodeIdA: "p2pNode", PeerIdA: "old", PeerIdB: "bob"})
+	_ = db.Save(&dao.ChannelInfo{ChannelId: "other", ObdNodeIdA: "otherP2pNode", PeerIdA: "old", PeerIdB: "bob"})
+
+	obdClient := &ObdNode{Id: "node", ObdP2pNodeId: "p2pNode", IsLogin: true}
+	migrations, err := NodeAccountService.updateUserPeerIds(obdClient, `[{"old_peer_id":"old","new_peer_id":"new","old_obd_node_id":"legacyNode"}]`)
+	if err != nil || len(migrations) != 1 {
+		t.Fatal("got the migrations", migrations, err)
+	}
+
+	userInfo := &dao.UserInfo{}
+	_ = db.One("Id", 1, userInfo)
+	if userInfo.UserId != "new" || userInfo.ObdNodeId != "node" {
+		t.Fatalf("got the user %s of %s, want the user new of node", userInfo.UserId, userInfo.ObdNodeId)
+	}
+	_ = db.One("Id", 2, userInfo)
+	if userInfo.UserId != "old" {
+		t.Fatalf("got the user %s of otherNode, want it not migrated", userInfo.UserId)
+	}
+	if info, ok := userOfOnlineMap["new"]; !ok || info.ObdNodeId != "node" {
+		t.Fatal("the online user is not migrated", userOfOnlineMap)
+	}
+
+	channelInfo := &dao.ChannelInfo{}
+	_ = db.One("ChannelId", "mine", channelInfo)
+	if channelInfo.PeerIdA != "new" {
+		t.Fatalf("got the peer %s of the channel announced by the obd, want new", channelInfo.PeerIdA)
+	}
+	_ = db.One("ChannelId", "other", channelInfo)
+	if channelInfo.PeerIdA != "old" {
+		t.Fatalf("got the peer %s of the channel announced by another obd, want old", channelInfo.PeerIdA)
+	}
+}
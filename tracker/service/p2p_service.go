@@ -3,13 +3,11 @@ package service
 import (
 	"bufio"
 	"context"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"github.com/asdine/storm/q"
 	"github.com/libp2p/go-libp2p"
 	circuit "github.com/libp2p/go-libp2p-circuit"
-	"github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/host"
 	"github.com/libp2p/go-libp2p-core/network"
 	"github.com/libp2p/go-libp2p-core/peer"
@@ -17,10 +15,10 @@ import (
 	dht "github.com/libp2p/go-libp2p-kad-dht"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/omnilaboratory/obd/bean"
+	"github.com/omnilaboratory/obd/tool"
 	cfg "github.com/omnilaboratory/obd/tracker/config"
 	"github.com/omnilaboratory/obd/tracker/dao"
 	"log"
-	"math/rand"
 	"strconv"
 	"strings"
 	"sync"
@@ -38,9 +36,7 @@ var hostNode host.Host
 
 func StartP2PNode() {
 
-	nodeId := int64(binary.BigEndian.Uint64([]byte(GetTrackerNodeId())))
-	r := rand.New(rand.NewSource(nodeId))
-	prvKey, _, err := crypto.GenerateECDSAKeyPair(r)
+	prvKey, err := tool.LoadOrCreateNodeKey(cfg.DataDirectory, cfg.NodeKeyPassphrase)
 	if err != nil {
 		log.Println(err)
 		return
@@ -37,10 +37,13 @@ func (manager *rpcManager) GetOmniBalance(context *gin.Context) {
 	}
 
 	getbalance, err := rpc.NewClient().OmniGetbalance(reqData.Address, reqData.PropertyId)
-	balance := 0.0
-	if err == nil {
-		balance = gjson.Get(getbalance, "balance").Float()
+	if err != nil {
+		context.JSON(http.StatusInternalServerError, gin.H{
+			"msg": err.Error(),
+		})
+		return
 	}
+	balance := gjson.Get(getbalance, "balance").Float()
 	context.JSON(http.StatusOK, gin.H{
 		"msg":  "OmniGetbalance",
 		"data": balance,
@@ -563,7 +566,13 @@ func (manager *rpcManager) GetBlockCount(context *gin.Context) {
 		}
 	}
 	if cacheBlockCount == 0 {
-		blockCount, _ := rpc.NewClient().GetBlockCount()
+		blockCount, err := rpc.NewClient().GetBlockCount()
+		if err != nil {
+			context.JSON(http.StatusInternalServerError, gin.H{
+				"msg": err.Error(),
+			})
+			return
+		}
 		cacheBlockCount = blockCount
 		blockSpanTime = time.Now()
 	}
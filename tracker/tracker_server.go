@@ -41,12 +41,18 @@ func initTrackerLog() {
 func main() {
 	initTrackerLog()
 
-	err := rpc.NewClient().CheckVersion()
+	rpc.SetConnConfig(rpc.ConnConfig{
+		Host: cfg.ChainNode_Host,
+		User: cfg.ChainNode_User,
+		Pass: cfg.ChainNode_Pass,
+	})
+	chainNodeType, err := rpc.NewClient().CheckVersion()
 	if err != nil {
 		log.Println(err)
 		log.Println("because get wrong omniCore version, tracker fail to start")
 		return
 	}
+	cfg.ChainNode_Type = chainNodeType
 	service.Start(cfg.ChainNode_Type)
 
 	service.StartP2PNode()